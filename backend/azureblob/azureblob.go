@@ -71,6 +71,7 @@ func init() {
 		Name:        "azureblob",
 		Description: "Microsoft Azure Blob Storage",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Options: []fs.Option{{
 			Name: "account",
 			Help: "Storage Account Name (leave blank to use SAS URL or Emulator)",
@@ -127,7 +128,56 @@ rclone performs "Set Tier" operation on blobs while uploading, if objects
 are not modified, specifying "access tier" to new one will have no effect.
 If blobs are in "archive tier" at remote, trying to perform data transfer
 operations from remote will not be allowed. User should first restore by
-tiering blob to "Hot" or "Cool".`,
+tiering blob to "Hot" or "Cool", eg with the "rehydrate" backend
+command, or by setting --azureblob-rehydrate-wait.`,
+			Advanced: true,
+		}, {
+			Name: "rehydrate_priority",
+			Help: `Priority to rehydrate blobs from the archive tier with.
+
+Standard priority rehydration may take up to 15 hours. High priority
+rehydration may finish in under an hour for blobs under 10 GB but
+costs more.
+
+This is used when reading archived blobs with --azureblob-rehydrate-wait
+and is the default for the "rehydrate" backend command.`,
+			Default: string(azblob.RehydratePriorityStandard),
+			Examples: []fs.OptionExample{{
+				Value: string(azblob.RehydratePriorityStandard),
+				Help:  "Standard priority",
+			}, {
+				Value: string(azblob.RehydratePriorityHigh),
+				Help:  "High priority",
+			}},
+			Advanced: true,
+		}, {
+			Name: "rehydrate_wait",
+			Help: `How long to wait for archived blobs to be rehydrated when reading them.
+
+Normally reading a blob in the archive tier is an error. If this is
+set then rclone starts rehydrating the blob, to the access_tier if
+that is hot or cool or to hot otherwise, and waits up to this long
+for it to finish before reading it. The rehydration status is checked
+every minute.
+
+As rehydration can take many hours this is mostly useful with
+--transfers set high enough to rehydrate all the blobs at once.`,
+			Default:  fs.Duration(0),
+			Advanced: true,
+		}, {
+			Name: "dfs_acl",
+			Help: `Read and write owners, permissions and ACLs as metadata.
+
+This needs a storage account with hierarchical namespace enabled
+(ADLS Gen2). The owner, group, POSIX permissions and ACL of each blob
+are read from and written to the DFS endpoint of the account as the
+"owner", "group", "permissions" and "acl" metadata keys, so they can
+be copied with --metadata. The permissions are also read as the
+"mode" key and set from it if there are no permissions.
+
+The ACLs of directories can be read and set with the "acl" backend
+command.`,
+			Default:  false,
 			Advanced: true,
 		}, {
 			Name: "disable_checksum",
@@ -175,7 +225,10 @@ type Options struct {
 	ChunkSize           fs.SizeSuffix        `config:"chunk_size"`
 	ListChunkSize       uint                 `config:"list_chunk"`
 	AccessTier          string               `config:"access_tier"`
+	RehydratePriority   string               `config:"rehydrate_priority"`
+	RehydrateWait       fs.Duration          `config:"rehydrate_wait"`
 	UseEmulator         bool                 `config:"use_emulator"`
+	DFSACL              bool                 `config:"dfs_acl"`
 	DisableCheckSum     bool                 `config:"disable_checksum"`
 	MemoryPoolFlushTime fs.Duration          `config:"memory_pool_flush_time"`
 	MemoryPoolUseMmap   bool                 `config:"memory_pool_use_mmap"`
@@ -199,18 +252,22 @@ type Fs struct {
 	pacer         *fs.Pacer                       // To pace and retry the API calls
 	uploadToken   *pacer.TokenDispenser           // control concurrency
 	pool          *pool.Pool                      // memory pool
+	credential    *azblob.SharedKeyCredential     // to sign SAS URLs if using account and key
+	dfsURL        *url.URL                        // DFS endpoint if dfs_acl is set
+	dfsPipeline   pipeline.Pipeline               // pipeline for the DFS endpoint
 }
 
 // Object describes an azure object
 type Object struct {
-	fs         *Fs                   // what this object is part of
-	remote     string                // The remote path
-	modTime    time.Time             // The modified time of the object if known
-	md5        string                // MD5 hash if known
-	size       int64                 // Size of the object
-	mimeType   string                // Content-Type of the object
-	accessTier azblob.AccessTierType // Blob Access Tier
-	meta       map[string]string     // blob metadata
+	fs            *Fs                      // what this object is part of
+	remote        string                   // The remote path
+	modTime       time.Time                // The modified time of the object if known
+	md5           string                   // MD5 hash if known
+	size          int64                    // Size of the object
+	mimeType      string                   // Content-Type of the object
+	accessTier    azblob.AccessTierType    // Blob Access Tier
+	archiveStatus azblob.ArchiveStatusType // Rehydration status if in the archive tier
+	meta          map[string]string        // blob metadata
 }
 
 // ------------------------------------------------------------
@@ -365,6 +422,7 @@ func (f *Fs) newPipeline(c azblob.Credential, o azblob.PipelineOptions) pipeline
 		azblob.NewTelemetryPolicyFactory(o.Telemetry),
 		azblob.NewUniqueRequestIDPolicyFactory(),
 		azblob.NewRetryPolicyFactory(o.Retry),
+		rehydratePriorityFactory(), // must be before the credential so the header is signed
 		c,
 		pipeline.MethodFactoryMarker(), // indicates at what stage in the pipeline the method factory is invoked
 		azblob.NewRequestLogPolicyFactory(o.RequestLog),
@@ -409,6 +467,11 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		return nil, errors.Errorf("Azure Blob: Supported access tiers are %s, %s and %s",
 			string(azblob.AccessTierHot), string(azblob.AccessTierCool), string(azblob.AccessTierArchive))
 	}
+	rehydratePriority, err := parseRehydratePriority(opt.RehydratePriority)
+	if err != nil {
+		return nil, errors.Wrap(err, "azure")
+	}
+	opt.RehydratePriority = string(rehydratePriority)
 
 	f := &Fs{
 		name:        name,
@@ -451,6 +514,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		}
 		pipeline := f.newPipeline(credential, azblob.PipelineOptions{Retry: azblob.RetryOptions{TryTimeout: maxTryTimeout}})
 		serviceURL = azblob.NewServiceURL(*u, pipeline)
+		f.credential = credential
 	case opt.Account != "" && opt.Key != "":
 		credential, err := azblob.NewSharedKeyCredential(opt.Account, opt.Key)
 		if err != nil {
@@ -463,6 +527,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		}
 		pipeline := f.newPipeline(credential, azblob.PipelineOptions{Retry: azblob.RetryOptions{TryTimeout: maxTryTimeout}})
 		serviceURL = azblob.NewServiceURL(*u, pipeline)
+		f.credential = credential
 	case opt.SASURL != "":
 		u, err = url.Parse(opt.SASURL)
 		if err != nil {
@@ -486,6 +551,15 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		return nil, errors.New("Need account+key or connectionString or sasURL")
 	}
 	f.svcURL = &serviceURL
+	if opt.DFSACL {
+		if opt.UseEmulator {
+			return nil, errors.New("dfs_acl isn't supported with the emulator")
+		}
+		err = f.setupDFS(u)
+		if err != nil {
+			return nil, errors.Wrap(err, "dfs_acl")
+		}
+	}
 
 	if f.rootContainer != "" && f.rootDirectory != "" {
 		// Check to see if the (container,directory) is actually an existing file
@@ -1118,6 +1192,7 @@ func (o *Object) decodeMetaDataFromPropertiesResponse(info *azblob.BlobGetProper
 	o.size = size
 	o.modTime = info.LastModified()
 	o.accessTier = azblob.AccessTierType(info.AccessTier())
+	o.archiveStatus = azblob.ArchiveStatusType(info.ArchiveStatus())
 	o.setMetadata(metadata)
 
 	return nil
@@ -1136,6 +1211,7 @@ func (o *Object) decodeMetaDataFromBlob(info *azblob.BlobItem) (err error) {
 	o.size = size
 	o.modTime = info.Properties.LastModified
 	o.accessTier = info.Properties.AccessTier
+	o.archiveStatus = info.Properties.ArchiveStatus
 	o.setMetadata(metadata)
 	return nil
 }
@@ -1225,7 +1301,10 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 	var offset int64
 	var count int64
 	if o.AccessTier() == azblob.AccessTierArchive {
-		return nil, errors.Errorf("Blob in archive tier, you need to set tier to hot or cool first")
+		err = o.rehydrateForRead(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 	fs.FixRangeOption(options, o.size)
 	for _, option := range options {
@@ -1357,7 +1436,12 @@ func (o *Object) uploadMultipart(ctx context.Context, in io.Reader, size int64,
 	for part := 0; !finished; part++ {
 		// Get a block of memory from the pool and a token which limits concurrency
 		o.fs.uploadToken.Get()
-		buf := memPool.Get()
+		buf, getErr := memPool.GetContext(gCtx)
+		if getErr != nil {
+			// gCtx was cancelled while waiting for memory
+			o.fs.uploadToken.Put()
+			break
+		}
 
 		free := func() {
 			memPool.Put(buf)       // return the buf
@@ -1505,13 +1589,21 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return err
 	}
 
+	// Use the tier asked for in the options if any
+	accessTier := o.fs.opt.AccessTier
+	for _, option := range options {
+		if tierOption, ok := option.(*fs.TierOption); ok {
+			accessTier = tierOption.Tier
+		}
+	}
+
 	// If tier is not changed or not specified, do not attempt to invoke `SetBlobTier` operation
-	if o.fs.opt.AccessTier == string(defaultAccessTier) || o.fs.opt.AccessTier == string(o.AccessTier()) {
+	if accessTier == string(defaultAccessTier) || strings.EqualFold(accessTier, string(o.AccessTier())) {
 		return nil
 	}
 
 	// Now, set blob tier based on configured access tier
-	return o.SetTier(o.fs.opt.AccessTier)
+	return o.SetTier(accessTier)
 }
 
 // Remove an object
@@ -1570,6 +1662,163 @@ func (o *Object) GetTier() string {
 	return string(o.accessTier)
 }
 
+var commandHelp = []fs.CommandHelp{{
+	Name:  "rehydrate",
+	Short: "Rehydrate blobs from the archive tier",
+	Long: `This command starts rehydrating blobs in the archive tier to the hot
+or cool tier so they can be read.
+
+Usage Examples:
+
+    rclone backend rehydrate azureblob:container/path/to/object [-o tier=TIER] [-o priority=PRIORITY] [-o wait=DURATION]
+    rclone backend rehydrate azureblob:container/path/to/directory [-o tier=TIER] [-o priority=PRIORITY] [-o wait=DURATION]
+
+This obeys the filters. Test first with -i/--interactive or --dry-run
+
+    rclone -i backend rehydrate --include "*.txt" azureblob:container/path -o priority=High
+
+The tier is Hot (the default) or Cool and the priority is Standard or
+High, defaulting to --azureblob-rehydrate-priority. Standard priority
+rehydration can take up to 15 hours, High priority is quicker for
+small blobs but costs more.
+
+If wait is set, eg -o wait=24h, then rclone waits up to that long for
+the blobs to be rehydrated before returning so the command can be
+followed by a copy in a script.
+
+It returns a list of status dictionaries for the archived blobs. The
+Status will be OK if rehydration was started, "Already rehydrating" if
+it was in progress or an error message.
+
+    [
+        {
+            "Remote": "file.txt",
+            "Tier": "Archive",
+            "ArchiveStatus": "rehydrate-pending-to-hot",
+            "Status": "OK"
+        }
+    ]
+`,
+	Opts: map[string]string{
+		"tier":     "Tier to rehydrate to: Hot|Cool",
+		"priority": "Priority of rehydration: Standard|High",
+		"wait":     "Wait this long for the rehydration to finish",
+	},
+}, {
+	Name:  "archive-status",
+	Short: "Show the blobs in the archive tier and their rehydration status",
+	Long: `This command lists the blobs which are in the archive tier along with
+their rehydration status.
+
+    rclone backend archive-status azureblob:container/path
+
+This obeys the filters. The ArchiveStatus is blank if the blob isn't
+being rehydrated, otherwise it shows the tier it is being rehydrated
+to.
+
+    [
+        {
+            "Remote": "file.txt",
+            "Tier": "Archive",
+            "ArchiveStatus": "rehydrate-pending-to-hot"
+        },
+        {
+            "Remote": "file2.txt",
+            "Tier": "Archive"
+        }
+    ]
+`,
+}, {
+	Name:  "sas",
+	Short: "Generate SAS URLs for the container or blobs",
+	Long: `This command signs Shared Access Signature (SAS) URLs with the
+account key which give access to the container or blobs without any
+other credentials, eg to share files or to allow uploads.
+
+Usage Examples:
+
+    rclone backend sas azureblob:container
+    rclone backend sas azureblob:container/path file1.txt file2.txt -o permissions=rw -o expire=7d
+
+With no arguments it returns a URL for the container of the remote,
+otherwise it returns a URL for each blob named in the arguments,
+relative to the remote.
+
+The permissions are letters from r (read), a (add), c (create),
+w (write), d (delete) and, for containers only, l (list). They
+default to "r" for blobs and "rl" for containers.
+
+The URLs expire after 1h unless expire is set. They can only be made
+if the remote is configured with an account and key.
+`,
+	Opts: map[string]string{
+		"permissions": "Permissions to grant, eg rwl",
+		"expire":      "How long the URLs are valid for, eg 1h or 30d",
+	},
+}, {
+	Name:  "acl",
+	Short: "Read or set the owner, permissions and ACL of paths",
+	Long: `This command reads the owner, group, permissions and ACL of files or
+directories in a storage account with hierarchical namespace enabled
+(ADLS Gen2). It needs --azureblob-dfs-acl to be set.
+
+Usage Examples:
+
+    rclone backend acl azureblob:container/dir
+    rclone backend acl azureblob:container dir file.txt
+    rclone backend acl azureblob:container/dir -o acl=user::rwx,group::r-x,other::---
+
+With no arguments it reads the path of the remote, otherwise the
+paths named in the arguments relative to the remote.
+
+If any of the options are given they are set on the paths first. The
+permissions are ignored if the acl is set as the acl includes them.
+Setting the owner needs superuser rights.
+
+It returns a list like this
+
+    [
+        {
+            "remote": "dir",
+            "owner": "$superuser",
+            "group": "$superuser",
+            "permissions": "rwxr-x---",
+            "acl": "user::rwx,group::r-x,other::---"
+        }
+    ]
+`,
+	Opts: map[string]string{
+		"owner":       "Set the owner",
+		"group":       "Set the owning group",
+		"permissions": "Set the permissions, eg rwxr-x---",
+		"acl":         "Set the ACL, eg user::rwx,group::r-x,other::---",
+	},
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "rehydrate":
+		return f.rehydrateCommand(ctx, opt)
+	case "archive-status":
+		return f.archiveStatusCommand(ctx)
+	case "sas":
+		return f.sasCommand(arg, opt)
+	case "acl":
+		return f.aclCommand(ctx, arg, opt)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs          = &Fs{}
@@ -1577,6 +1826,7 @@ var (
 	_ fs.PutStreamer = &Fs{}
 	_ fs.Purger      = &Fs{}
 	_ fs.ListRer     = &Fs{}
+	_ fs.Commander   = &Fs{}
 	_ fs.Object      = &Object{}
 	_ fs.MimeTyper   = &Object{}
 	_ fs.GetTierer   = &Object{}
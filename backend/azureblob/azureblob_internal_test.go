@@ -3,9 +3,19 @@
 package azureblob
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/pacer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func (f *Fs) InternalTest(t *testing.T) {
@@ -33,3 +43,209 @@ func TestIncrement(t *testing.T) {
 		assert.Equal(t, test.want, test.in)
 	}
 }
+
+func TestParseRehydratePriority(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    azblob.RehydratePriorityType
+		wantErr bool
+	}{
+		{"", azblob.RehydratePriorityStandard, false},
+		{"Standard", azblob.RehydratePriorityStandard, false},
+		{"high", azblob.RehydratePriorityHigh, false},
+		{"HIGH", azblob.RehydratePriorityHigh, false},
+		{"urgent", azblob.RehydratePriorityNone, true},
+	} {
+		got, err := parseRehydratePriority(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestParseRehydrateTier(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    azblob.AccessTierType
+		wantErr bool
+	}{
+		{"", azblob.AccessTierHot, false},
+		{"Hot", azblob.AccessTierHot, false},
+		{"cool", azblob.AccessTierCool, false},
+		{"Archive", azblob.AccessTierNone, true},
+	} {
+		got, err := parseRehydrateTier(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestRehydratePriorityFactory(t *testing.T) {
+	var header string
+	last := pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			header = request.Header.Get(rehydratePriorityHeader)
+			return pipeline.NewHTTPResponse(&http.Response{StatusCode: http.StatusOK}), nil
+		}
+	})
+	p := pipeline.NewPipeline([]pipeline.Factory{rehydratePriorityFactory(), last}, pipeline.Options{})
+	for _, test := range []struct {
+		query    string
+		priority azblob.RehydratePriorityType
+		want     string
+	}{
+		{"comp=tier", azblob.RehydratePriorityHigh, "High"},
+		{"comp=tier", azblob.RehydratePriorityNone, ""},
+		{"comp=tier", "", ""},
+		{"comp=metadata", azblob.RehydratePriorityHigh, ""},
+	} {
+		ctx := context.Background()
+		if test.priority != "" {
+			ctx = withRehydratePriority(ctx, test.priority)
+		}
+		u, err := url.Parse("https://account.blob.core.windows.net/container/blob?" + test.query)
+		require.NoError(t, err)
+		request, err := pipeline.NewRequest("PUT", *u, nil)
+		require.NoError(t, err)
+		header = "unset"
+		_, err = p.Do(ctx, nil, request)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, header, test.query)
+	}
+}
+
+func TestSASCommand(t *testing.T) {
+	credential, err := azblob.NewSharedKeyCredential(emulatorAccount, emulatorAccountKey)
+	require.NoError(t, err)
+	u, err := url.Parse("https://account.blob.core.windows.net")
+	require.NoError(t, err)
+	serviceURL := azblob.NewServiceURL(*u, azblob.NewPipeline(credential, azblob.PipelineOptions{}))
+	f := &Fs{
+		svcURL:      &serviceURL,
+		cntURLcache: map[string]*azblob.ContainerURL{},
+		credential:  credential,
+	}
+	f.setRoot("container/dir")
+
+	out, err := f.sasCommand(nil, map[string]string{"expire": "2d"})
+	require.NoError(t, err)
+	containerURL, err := url.Parse(out.(string))
+	require.NoError(t, err)
+	assert.Equal(t, "/container", containerURL.Path)
+	query := containerURL.Query()
+	assert.Equal(t, "rl", query.Get("sp"))
+	assert.Equal(t, "c", query.Get("sr"))
+	assert.Equal(t, "https", query.Get("spr"))
+	assert.NotEqual(t, "", query.Get("sig"))
+	expiry, err := time.Parse(azblob.SASTimeFormat, query.Get("se"))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), expiry, time.Minute)
+
+	out, err = f.sasCommand([]string{"file.txt"}, map[string]string{"permissions": "rw"})
+	require.NoError(t, err)
+	urls := out.([]string)
+	require.Len(t, urls, 1)
+	blobURL, err := url.Parse(urls[0])
+	require.NoError(t, err)
+	assert.Equal(t, "/container/dir/file.txt", blobURL.Path)
+	assert.Equal(t, "rw", blobURL.Query().Get("sp"))
+	assert.Equal(t, "b", blobURL.Query().Get("sr"))
+
+	_, err = f.sasCommand([]string{"file.txt"}, map[string]string{"permissions": "l"})
+	assert.Error(t, err)
+	_, err = f.sasCommand(nil, map[string]string{"expire": "potato"})
+	assert.Error(t, err)
+
+	f.credential = nil
+	_, err = f.sasCommand(nil, nil)
+	assert.Error(t, err)
+}
+
+func TestPermissionsMode(t *testing.T) {
+	for _, test := range []struct {
+		permissions string
+		mode        string
+	}{
+		{"rwxr-x---", "0750"},
+		{"rw-r--r--", "0644"},
+		{"---------", "0000"},
+		{"rwxrwxrwt", "1777"},
+		{"rwxr-x--T", "1750"},
+	} {
+		mode, err := modeFromPermissions(test.permissions)
+		require.NoError(t, err, test.permissions)
+		assert.Equal(t, test.mode, mode, test.permissions)
+		permissions, err := permissionsFromMode(test.mode)
+		require.NoError(t, err, test.mode)
+		assert.Equal(t, test.permissions, permissions, test.mode)
+	}
+	for _, permissions := range []string{"", "rwx", "rwxrwxrwxx", "xwrxwrxwr", "rwtrwxrwx"} {
+		_, err := modeFromPermissions(permissions)
+		assert.Error(t, err, permissions)
+	}
+	for _, mode := range []string{"", "potato", "9", "2755"} {
+		_, err := permissionsFromMode(mode)
+		assert.Error(t, err, mode)
+	}
+}
+
+func TestDFSEndpoint(t *testing.T) {
+	u, err := url.Parse("https://account.blob.core.windows.net/container?sv=2019&sig=xyz")
+	require.NoError(t, err)
+	dfs, err := dfsEndpoint(u)
+	require.NoError(t, err)
+	assert.Equal(t, "https://account.dfs.core.windows.net?sv=2019&sig=xyz", dfs.String())
+
+	u, err = url.Parse(emulatorBlobEndpoint)
+	require.NoError(t, err)
+	_, err = dfsEndpoint(u)
+	assert.Error(t, err)
+}
+
+func TestAccessControl(t *testing.T) {
+	var (
+		gotMethod string
+		gotURL    *url.URL
+		gotHeader http.Header
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotURL, gotHeader = r.Method, r.URL, r.Header
+		w.Header().Set("x-ms-owner", "owner-id")
+		w.Header().Set("x-ms-group", "group-id")
+		w.Header().Set("x-ms-permissions", "rwxr-x---+")
+		w.Header().Set("x-ms-acl", "user::rwx,user:bob:r-x,group::r-x,mask::r-x,other::---")
+	}))
+	defer ts.Close()
+	f := &Fs{
+		client: ts.Client(),
+		pacer:  fs.NewPacer(pacer.NewS3(pacer.MinSleep(minSleep))),
+	}
+	f.dfsURL, _ = url.Parse(ts.URL)
+	f.dfsPipeline = f.newPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{})
+	ctx := context.Background()
+
+	ac, err := f.getAccessControl(ctx, "container", "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "HEAD", gotMethod)
+	assert.Equal(t, "/container/dir/file.txt", gotURL.Path)
+	assert.Equal(t, "getAccessControl", gotURL.Query().Get("action"))
+	assert.Equal(t, &accessControl{
+		Owner:       "owner-id",
+		Group:       "group-id",
+		Permissions: "rwxr-x---",
+		ACL:         "user::rwx,user:bob:r-x,group::r-x,mask::r-x,other::---",
+	}, ac)
+
+	err = f.setAccessControl(ctx, "container", "dir", &accessControl{Group: "group-id", Permissions: "rwx------"})
+	require.NoError(t, err)
+	assert.Equal(t, "PATCH", gotMethod)
+	assert.Equal(t, "/container/dir", gotURL.Path)
+	assert.Equal(t, "setAccessControl", gotURL.Query().Get("action"))
+	assert.Equal(t, "", gotHeader.Get("x-ms-owner"))
+	assert.Equal(t, "group-id", gotHeader.Get("x-ms-group"))
+	assert.Equal(t, "rwx------", gotHeader.Get("x-ms-permissions"))
+
+	err = f.setAccessControl(ctx, "container", "dir", &accessControl{Permissions: "rwx------", ACL: "user::rwx,group::---,other::---"})
+	require.NoError(t, err)
+	assert.Equal(t, "", gotHeader.Get("x-ms-permissions"))
+	assert.Equal(t, "user::rwx,group::---,other::---", gotHeader.Get("x-ms-acl"))
+}
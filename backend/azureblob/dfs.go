@@ -0,0 +1,317 @@
+// Owners, permissions and ACLs with the ADLS Gen2 DFS endpoint
+
+// +build !plan9,!solaris,!js,go1.13
+
+package azureblob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// Metadata keys for the access control of a blob
+const (
+	metadataOwner       = "owner"        // owner of the blob - an object ID or user principal name
+	metadataGroup       = "group"        // owning group of the blob
+	metadataPermissions = "permissions"  // POSIX permissions, eg "rwxr-x---"
+	metadataACL         = fs.MetadataACL // POSIX access control list, eg "user::rwx,group::r-x,other::---"
+)
+
+// accessControl is the owner, group, permissions and ACL of a path
+// with hierarchical namespace enabled
+type accessControl struct {
+	Owner       string `json:"owner,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+	ACL         string `json:"acl,omitempty"`
+}
+
+// isEmpty returns true if nothing is set in ac
+func (ac *accessControl) isEmpty() bool {
+	return *ac == accessControl{}
+}
+
+// dfsEndpoint returns the DFS endpoint of the storage account with the
+// blob endpoint u, keeping any SAS in the query
+func dfsEndpoint(u *url.URL) (*url.URL, error) {
+	if !strings.Contains(u.Host, ".blob.") {
+		return nil, errors.Errorf("can't find the DFS endpoint for %q", u.Host)
+	}
+	dfs := *u
+	dfs.Host = strings.Replace(u.Host, ".blob.", ".dfs.", 1)
+	dfs.Path = ""
+	return &dfs, nil
+}
+
+// setupDFS sets up the pipeline for the DFS endpoint of the storage
+// account with the blob endpoint u
+func (f *Fs) setupDFS(u *url.URL) (err error) {
+	f.dfsURL, err = dfsEndpoint(u)
+	if err != nil {
+		return err
+	}
+	var credential azblob.Credential = azblob.NewAnonymousCredential()
+	if f.credential != nil {
+		credential = f.credential
+	}
+	f.dfsPipeline = f.newPipeline(credential, azblob.PipelineOptions{Retry: azblob.RetryOptions{TryTimeout: maxTryTimeout}})
+	return nil
+}
+
+// dfsCall calls action on containerPath in container with the DFS
+// endpoint returning the response headers
+func (f *Fs) dfsCall(ctx context.Context, method, container, containerPath, action string, headers http.Header) (respHeaders http.Header, err error) {
+	u := *f.dfsURL
+	u.Path = "/" + container + "/" + containerPath
+	query := u.Query()
+	query.Set("action", action)
+	u.RawQuery = query.Encode()
+	err = f.pacer.Call(func() (bool, error) {
+		request, err := pipeline.NewRequest(method, u, nil)
+		if err != nil {
+			return false, err
+		}
+		for key, values := range headers {
+			request.Header[key] = values
+		}
+		request.Header.Set("x-ms-version", azblob.ServiceVersion)
+		response, err := f.dfsPipeline.Do(ctx, nil, request)
+		if err != nil {
+			return f.shouldRetry(err)
+		}
+		resp := response.Response()
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return f.shouldRetry(azblob.NewResponseError(nil, resp, action+" failed"))
+		}
+		respHeaders = resp.Header
+		return false, nil
+	})
+	return respHeaders, err
+}
+
+// getAccessControl reads the access control of containerPath in
+// container
+func (f *Fs) getAccessControl(ctx context.Context, container, containerPath string) (*accessControl, error) {
+	respHeaders, err := f.dfsCall(ctx, "HEAD", container, containerPath, "getAccessControl", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read access control")
+	}
+	return &accessControl{
+		Owner:       respHeaders.Get("x-ms-owner"),
+		Group:       respHeaders.Get("x-ms-group"),
+		Permissions: strings.TrimSuffix(respHeaders.Get("x-ms-permissions"), "+"),
+		ACL:         respHeaders.Get("x-ms-acl"),
+	}, nil
+}
+
+// setAccessControl sets the parts of ac which are set on
+// containerPath in container
+//
+// The permissions are only set if the ACL isn't as the ACL sets them
+// too.
+func (f *Fs) setAccessControl(ctx context.Context, container, containerPath string, ac *accessControl) error {
+	headers := http.Header{}
+	if ac.Owner != "" {
+		headers.Set("x-ms-owner", ac.Owner)
+	}
+	if ac.Group != "" {
+		headers.Set("x-ms-group", ac.Group)
+	}
+	if ac.ACL != "" {
+		headers.Set("x-ms-acl", ac.ACL)
+	} else if ac.Permissions != "" {
+		headers.Set("x-ms-permissions", ac.Permissions)
+	}
+	_, err := f.dfsCall(ctx, "PATCH", container, containerPath, "setAccessControl", headers)
+	if err != nil {
+		return errors.Wrap(err, "failed to set access control")
+	}
+	return nil
+}
+
+// permissionBits are the bits of the mode in the order they appear in
+// the permissions string
+var permissionBits = [9]uint64{0400, 0200, 0100, 040, 020, 010, 04, 02, 01}
+
+// modeFromPermissions converts permissions, eg "rwxr-x--T", to an
+// octal mode, eg "1750"
+func modeFromPermissions(permissions string) (string, error) {
+	if len(permissions) != 9 {
+		return "", errors.Errorf("invalid permissions %q", permissions)
+	}
+	var mode uint64
+	for i := range permissions {
+		c := permissions[i]
+		switch {
+		case c == '-':
+		case i == 8 && (c == 't' || c == 'T'):
+			mode |= 01000
+			if c == 't' {
+				mode |= permissionBits[i]
+			}
+		case c == "rwx"[i%3]:
+			mode |= permissionBits[i]
+		default:
+			return "", errors.Errorf("invalid permissions %q", permissions)
+		}
+	}
+	return fmt.Sprintf("%04o", mode), nil
+}
+
+// permissionsFromMode converts an octal mode, eg "1750", to
+// permissions, eg "rwxr-x--T"
+func permissionsFromMode(mode string) (string, error) {
+	bits, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || bits > 01777 {
+		return "", errors.Errorf("invalid mode %q", mode)
+	}
+	permissions := []byte("---------")
+	for i, bit := range permissionBits {
+		if bits&bit != 0 {
+			permissions[i] = "rwx"[i%3]
+		}
+	}
+	if bits&01000 != 0 {
+		if permissions[8] == 'x' {
+			permissions[8] = 't'
+		} else {
+			permissions[8] = 'T'
+		}
+	}
+	return string(permissions), nil
+}
+
+// Metadata returns the modification time and content type of the blob
+// and, if dfs_acl is set, its owner, group, permissions and ACL
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	err := o.readMetaData()
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(fs.Metadata, 7)
+	metadata.SetTime(fs.MetadataMtime, o.modTime)
+	if o.mimeType != "" {
+		metadata[fs.MetadataContentType] = o.mimeType
+	}
+	if o.fs.opt.DFSACL {
+		container, containerPath := o.split()
+		ac, err := o.fs.getAccessControl(ctx, container, containerPath)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range map[string]string{
+			metadataOwner:       ac.Owner,
+			metadataGroup:       ac.Group,
+			metadataPermissions: ac.Permissions,
+			metadataACL:         ac.ACL,
+		} {
+			if value != "" {
+				metadata[key] = value
+			}
+		}
+		if mode, err := modeFromPermissions(ac.Permissions); err == nil {
+			metadata[fs.MetadataMode] = mode
+		}
+	}
+	return metadata, nil
+}
+
+// SetMetadata sets the modification time and, if dfs_acl is set, the
+// owner, group, permissions and ACL of the blob from metadata. The
+// permissions are set from the mode if there aren't any. The other
+// keys are ignored.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	err := o.readMetaData()
+	if err != nil {
+		return err
+	}
+	if modTime, ok := metadata.Time(fs.MetadataMtime); ok && !modTime.Equal(o.modTime) {
+		err = o.SetModTime(ctx, modTime)
+		if err != nil {
+			return err
+		}
+	}
+	if !o.fs.opt.DFSACL {
+		return nil
+	}
+	ac := accessControl{
+		Owner:       metadata[metadataOwner],
+		Group:       metadata[metadataGroup],
+		Permissions: metadata[metadataPermissions],
+		ACL:         metadata[metadataACL],
+	}
+	if mode, ok := metadata[fs.MetadataMode]; ok && ac.Permissions == "" {
+		ac.Permissions, err = permissionsFromMode(mode)
+		if err != nil {
+			return err
+		}
+	}
+	if ac.isEmpty() {
+		return nil
+	}
+	container, containerPath := o.split()
+	return o.fs.setAccessControl(ctx, container, containerPath, &ac)
+}
+
+// aclResult is the access control of a path returned by the acl
+// backend command
+type aclResult struct {
+	Remote string `json:"remote"`
+	accessControl
+}
+
+// aclCommand reads, and sets if any options are given, the access
+// control of the paths in arg relative to the Fs, or of the Fs if
+// there aren't any
+func (f *Fs) aclCommand(ctx context.Context, arg []string, opt map[string]string) (out []aclResult, err error) {
+	if f.dfsURL == nil {
+		return nil, errors.New("need dfs_acl set to read and write ACLs")
+	}
+	set := accessControl{
+		Owner:       opt["owner"],
+		Group:       opt["group"],
+		Permissions: opt["permissions"],
+		ACL:         opt["acl"],
+	}
+	if len(arg) == 0 {
+		arg = []string{""}
+	}
+	out = make([]aclResult, 0, len(arg))
+	for _, remote := range arg {
+		container, containerPath := f.split(remote)
+		if container == "" {
+			return nil, errors.New("need a container to read ACLs from")
+		}
+		if !set.isEmpty() {
+			err = f.setAccessControl(ctx, container, containerPath, &set)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%q", remote)
+			}
+		}
+		ac, err := f.getAccessControl(ctx, container, containerPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%q", remote)
+		}
+		out = append(out, aclResult{Remote: remote, accessControl: *ac})
+	}
+	return out, nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Metadataer    = &Object{}
+	_ fs.SetMetadataer = &Object{}
+)
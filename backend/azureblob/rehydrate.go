@@ -0,0 +1,260 @@
+// Rehydrating blobs from the archive tier
+
+// +build !plan9,!solaris,!js,go1.13
+
+package azureblob
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// rehydratePollInterval is how often to check whether a blob has
+// been rehydrated when waiting for it
+const rehydratePollInterval = time.Minute
+
+// rehydratePriorityHeader is the header which sets the priority of a
+// Set Tier request
+const rehydratePriorityHeader = "x-ms-rehydrate-priority"
+
+// rehydratePriorityKey is the context key for the rehydrate priority
+type rehydratePriorityKey struct{}
+
+// withRehydratePriority returns a context which makes Set Tier
+// requests made with it rehydrate with priority
+//
+// This is needed as azblob.BlobURL.SetTier doesn't take a priority.
+func withRehydratePriority(ctx context.Context, priority azblob.RehydratePriorityType) context.Context {
+	return context.WithValue(ctx, rehydratePriorityKey{}, priority)
+}
+
+// rehydratePriorityFactory makes a pipeline policy which adds the
+// rehydrate priority to Set Tier requests made with a context from
+// withRehydratePriority
+//
+// It must go before the credential in the pipeline so the header is
+// signed.
+func rehydratePriorityFactory() pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			priority, ok := ctx.Value(rehydratePriorityKey{}).(azblob.RehydratePriorityType)
+			if ok && priority != azblob.RehydratePriorityNone && request.URL.Query().Get("comp") == "tier" {
+				request.Header.Set(rehydratePriorityHeader, string(priority))
+			}
+			return next.Do(ctx, request)
+		}
+	})
+}
+
+// parseRehydratePriority checks the rehydrate priority is valid
+func parseRehydratePriority(priority string) (azblob.RehydratePriorityType, error) {
+	switch strings.ToLower(priority) {
+	case "", "standard":
+		return azblob.RehydratePriorityStandard, nil
+	case "high":
+		return azblob.RehydratePriorityHigh, nil
+	}
+	return azblob.RehydratePriorityNone, errors.Errorf("rehydrate priority must be Standard or High not %q", priority)
+}
+
+// parseRehydrateTier checks tier is one blobs can be rehydrated to
+func parseRehydrateTier(tier string) (azblob.AccessTierType, error) {
+	switch strings.ToLower(tier) {
+	case "", "hot":
+		return azblob.AccessTierHot, nil
+	case "cool":
+		return azblob.AccessTierCool, nil
+	}
+	return azblob.AccessTierNone, errors.Errorf("can only rehydrate to Hot or Cool not %q", tier)
+}
+
+// rehydratePriority returns the priority from rehydrate_priority
+func (f *Fs) rehydratePriority() azblob.RehydratePriorityType {
+	return azblob.RehydratePriorityType(f.opt.RehydratePriority)
+}
+
+// rehydrateTier returns the tier to rehydrate blobs to when reading
+// them - the access_tier if it is hot or cool otherwise hot
+func (f *Fs) rehydrateTier() azblob.AccessTierType {
+	tier, err := parseRehydrateTier(f.opt.AccessTier)
+	if err != nil {
+		return azblob.AccessTierHot
+	}
+	return tier
+}
+
+// isArchived returns true if the blob is in the archive tier
+func (o *Object) isArchived() bool {
+	return o.accessTier == azblob.AccessTierArchive
+}
+
+// isRehydrating returns true if the blob is being rehydrated
+func (o *Object) isRehydrating() bool {
+	return o.archiveStatus != azblob.ArchiveStatusNone
+}
+
+// rehydrate starts rehydrating the archived blob to tier
+func (o *Object) rehydrate(ctx context.Context, tier azblob.AccessTierType, priority azblob.RehydratePriorityType) error {
+	blob := o.getBlobReference()
+	ctx = withRehydratePriority(ctx, priority)
+	err := o.fs.pacer.Call(func() (bool, error) {
+		_, err := blob.SetTier(ctx, tier, azblob.LeaseAccessConditions{})
+		return o.fs.shouldRetry(err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to start rehydration")
+	}
+	o.archiveStatus = azblob.ArchiveStatusType("rehydrate-pending-to-" + strings.ToLower(string(tier)))
+	fs.Infof(o, "Started rehydration to %s tier with %s priority", tier, priority)
+	return nil
+}
+
+// waitForRehydration waits up to timeout for all the objects to leave
+// the archive tier, checking every rehydratePollInterval
+func waitForRehydration(ctx context.Context, objs []*Object, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var pending []*Object
+		for _, o := range objs {
+			if o.isArchived() {
+				pending = append(pending, o)
+			} else {
+				fs.Infof(o, "Rehydrated to %s tier", o.accessTier)
+			}
+		}
+		objs = pending
+		if len(objs) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("%d blobs still in archive tier after waiting %v", len(objs), timeout)
+		}
+		fs.Infof(nil, "Waiting for rehydration of %d blobs to finish", len(objs))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rehydratePollInterval):
+		}
+		for _, o := range objs {
+			o.clearMetaData()
+			err := o.readMetaData()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rehydrateForRead makes the archived blob readable, starting the
+// rehydration if necessary, if rehydrate_wait is set
+func (o *Object) rehydrateForRead(ctx context.Context) error {
+	if o.fs.opt.RehydrateWait <= 0 {
+		return errors.New("blob in archive tier, you need to set tier to hot or cool first, eg with the rehydrate backend command, or use --azureblob-rehydrate-wait")
+	}
+	if !o.isRehydrating() {
+		err := o.rehydrate(ctx, o.fs.rehydrateTier(), o.fs.rehydratePriority())
+		if err != nil {
+			return err
+		}
+	}
+	return waitForRehydration(ctx, []*Object{o}, time.Duration(o.fs.opt.RehydrateWait))
+}
+
+// rehydrateStatus is the status of a blob returned by the backend
+// commands
+type rehydrateStatus struct {
+	Remote        string
+	Tier          string
+	ArchiveStatus string `json:",omitempty"`
+	Status        string `json:",omitempty"`
+}
+
+// rehydrateCommand starts rehydrating the archived blobs in the Fs
+// which match the filters
+func (f *Fs) rehydrateCommand(ctx context.Context, opt map[string]string) (out []rehydrateStatus, err error) {
+	tier, err := parseRehydrateTier(opt["tier"])
+	if err != nil {
+		return nil, err
+	}
+	priority := f.rehydratePriority()
+	if value, ok := opt["priority"]; ok {
+		priority, err = parseRehydratePriority(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var wait time.Duration
+	if value, ok := opt["wait"]; ok {
+		wait, err = fs.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad wait")
+		}
+	}
+	var (
+		outMu     sync.Mutex
+		toWaitFor []*Object
+	)
+	out = []rehydrateStatus{}
+	err = operations.ListFn(ctx, f, func(obj fs.Object) {
+		// Remember this is run --checkers times concurrently
+		o, ok := obj.(*Object)
+		if !ok || !o.isArchived() {
+			return
+		}
+		st := rehydrateStatus{Remote: o.remote, Tier: string(o.accessTier), Status: "OK"}
+		defer func() {
+			st.ArchiveStatus = string(o.archiveStatus)
+			outMu.Lock()
+			out = append(out, st)
+			if st.Status == "OK" || o.isRehydrating() {
+				toWaitFor = append(toWaitFor, o)
+			}
+			outMu.Unlock()
+		}()
+		if o.isRehydrating() {
+			st.Status = "Already rehydrating"
+			return
+		}
+		if operations.SkipDestructive(ctx, obj, "rehydrate") {
+			st.Status = "Skipped"
+			return
+		}
+		err := o.rehydrate(ctx, tier, priority)
+		if err != nil {
+			st.Status = err.Error()
+		}
+	})
+	if err != nil || wait <= 0 {
+		return out, err
+	}
+	return out, waitForRehydration(ctx, toWaitFor, wait)
+}
+
+// archiveStatusCommand lists the blobs in the Fs which match the
+// filters and are archived or being rehydrated
+func (f *Fs) archiveStatusCommand(ctx context.Context) (out []rehydrateStatus, err error) {
+	var outMu sync.Mutex
+	out = []rehydrateStatus{}
+	err = operations.ListFn(ctx, f, func(obj fs.Object) {
+		o, ok := obj.(*Object)
+		if !ok || !o.isArchived() {
+			return
+		}
+		outMu.Lock()
+		out = append(out, rehydrateStatus{
+			Remote:        o.remote,
+			Tier:          string(o.accessTier),
+			ArchiveStatus: string(o.archiveStatus),
+		})
+		outMu.Unlock()
+	})
+	return out, err
+}
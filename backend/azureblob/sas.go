@@ -0,0 +1,89 @@
+// Generating Shared Access Signature URLs
+
+// +build !plan9,!solaris,!js,go1.13
+
+package azureblob
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+const (
+	defaultSASExpire         = fs.Duration(time.Hour) // how long SAS URLs are valid for by default
+	defaultBlobSASPerms      = "r"                    // default permissions for blob SAS URLs
+	defaultContainerSASPerms = "rl"                   // default permissions for container SAS URLs
+)
+
+// sasURL signs u, the URL of the container or blob blobName in
+// container, with a SAS granting permissions until expiry
+func (f *Fs) sasURL(u url.URL, container, blobName, permissions string, expiry time.Time) (string, error) {
+	values := azblob.BlobSASSignatureValues{
+		Protocol:      azblob.SASProtocolHTTPS,
+		ExpiryTime:    expiry,
+		Permissions:   permissions,
+		ContainerName: container,
+		BlobName:      blobName,
+	}
+	if f.opt.UseEmulator {
+		values.Protocol = azblob.SASProtocolHTTPSandHTTP
+	}
+	sas, err := values.NewSASQueryParameters(f.credential)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid permissions %q", permissions)
+	}
+	parts := azblob.NewBlobURLParts(u)
+	parts.SAS = sas
+	signed := parts.URL()
+	return signed.String(), nil
+}
+
+// sasCommand makes SAS URLs for the container of the Fs or for the
+// blobs named in arg relative to the Fs
+func (f *Fs) sasCommand(arg []string, opt map[string]string) (out interface{}, err error) {
+	if f.credential == nil {
+		return nil, errors.New("SAS URLs can only be generated if the remote is configured with an account and key")
+	}
+	expire := defaultSASExpire
+	if value, ok := opt["expire"]; ok {
+		err = expire.Set(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad expire")
+		}
+	}
+	if expire <= 0 {
+		return nil, errors.New("expire must be positive")
+	}
+	expiry := time.Now().Add(time.Duration(expire)).UTC()
+	permissions, hasPermissions := opt["permissions"]
+	if len(arg) == 0 {
+		if f.rootContainer == "" {
+			return nil, errors.New("need a container to generate a SAS URL for")
+		}
+		if !hasPermissions {
+			permissions = defaultContainerSASPerms
+		}
+		container := f.opt.Enc.FromStandardName(f.rootContainer)
+		return f.sasURL(f.cntURL(container).URL(), container, "", permissions, expiry)
+	}
+	if !hasPermissions {
+		permissions = defaultBlobSASPerms
+	}
+	urls := make([]string, 0, len(arg))
+	for _, remote := range arg {
+		container, containerPath := f.split(remote)
+		if container == "" || containerPath == "" {
+			return nil, errors.Errorf("%q isn't a blob", remote)
+		}
+		u, err := f.sasURL(f.getBlobReference(container, containerPath).URL(), container, containerPath, permissions, expiry)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
@@ -33,10 +33,20 @@ var _ fserrors.Fataler = (*Error)(nil)
 
 // Bucket describes a B2 bucket
 type Bucket struct {
-	ID        string `json:"bucketId"`
-	AccountID string `json:"accountId"`
-	Name      string `json:"bucketName"`
-	Type      string `json:"bucketType"`
+	ID             string          `json:"bucketId"`
+	AccountID      string          `json:"accountId"`
+	Name           string          `json:"bucketName"`
+	Type           string          `json:"bucketType"`
+	LifecycleRules []LifecycleRule `json:"lifecycleRules,omitempty"`
+}
+
+// LifecycleRule is a rule which hides and deletes files automatically
+//
+// A nil number of days means that step doesn't happen.
+type LifecycleRule struct {
+	DaysFromHidingToDeleting  *int   `json:"daysFromHidingToDeleting"`  // days after a file is hidden that it is deleted
+	DaysFromUploadingToHiding *int   `json:"daysFromUploadingToHiding"` // days after a file is uploaded that it is hidden
+	FileNamePrefix            string `json:"fileNamePrefix"`            // the rule applies to files whose names start with this
 }
 
 // Timestamp is a UTC time when this file was uploaded. It is a base
@@ -225,6 +235,36 @@ type CreateBucketRequest struct {
 	Type      string `json:"bucketType"`
 }
 
+// UpdateBucketRequest is used to update a bucket with b2_update_bucket
+type UpdateBucketRequest struct {
+	AccountID      string          `json:"accountId"`            // The account that the bucket is in
+	ID             string          `json:"bucketId"`             // The unique ID of the bucket
+	Type           string          `json:"bucketType,omitempty"` // If set the new bucket type
+	LifecycleRules []LifecycleRule `json:"lifecycleRules"`       // The new lifecycle rules for the bucket
+}
+
+// CreateKeyRequest is used to create an application key with b2_create_key
+type CreateKeyRequest struct {
+	AccountID              string   `json:"accountId"`                        // The account to create the key in
+	Capabilities           []string `json:"capabilities"`                     // The capabilities the key will have
+	KeyName                string   `json:"keyName"`                          // A name for the key
+	ValidDurationInSeconds int64    `json:"validDurationInSeconds,omitempty"` // If set the key expires this many seconds after it is created
+	BucketID               string   `json:"bucketId,omitempty"`               // If set the key can only access this bucket
+	NamePrefix             string   `json:"namePrefix,omitempty"`             // If set the key can only access files starting with this
+}
+
+// Key is an application key as returned by b2_create_key
+type Key struct {
+	KeyName             string     `json:"keyName"`             // The name of the key
+	ApplicationKeyID    string     `json:"applicationKeyId"`    // The ID of the key - used as the account when authorizing
+	ApplicationKey      string     `json:"applicationKey"`      // The secret part of the key - only returned when the key is created
+	Capabilities        []string   `json:"capabilities"`        // The capabilities the key has
+	AccountID           string     `json:"accountId"`           // The account the key is in
+	ExpirationTimestamp *Timestamp `json:"expirationTimestamp"` // When the key expires or nil if it doesn't
+	BucketID            string     `json:"bucketId"`            // The bucket the key is restricted to or "" if none
+	NamePrefix          string     `json:"namePrefix"`          // The prefix the key is restricted to or "" if none
+}
+
 // DeleteBucketRequest is used to create a bucket
 type DeleteBucketRequest struct {
 	ID        string `json:"bucketId"`
@@ -302,6 +342,19 @@ type UploadPartResponse struct {
 	SHA1       string `json:"contentSha1"`   // The SHA1 of the bytes stored in the file.
 }
 
+// ListPartsRequest is passed to b2_list_parts
+type ListPartsRequest struct {
+	ID              string `json:"fileId"`                    // The unique identifier of the large file being uploaded.
+	StartPartNumber int64  `json:"startPartNumber,omitempty"` // The first part to return.
+	MaxPartCount    int    `json:"maxPartCount,omitempty"`    // The maximum number of parts to return from this call. The default value is 100, and the maximum allowed is 1000.
+}
+
+// ListPartsResponse is the response to b2_list_parts
+type ListPartsResponse struct {
+	Parts          []UploadPartResponse `json:"parts"`          // The parts which have been uploaded so far.
+	NextPartNumber *int64               `json:"nextPartNumber"` // What to pass in to startPartNumber for the next search to continue where this one left off, or null if there are no more parts.
+}
+
 // FinishLargeFileRequest is passed to b2_finish_large_file
 //
 // The response is a FileInfo object (with extra AccountID and BucketID fields which we ignore).
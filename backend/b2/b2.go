@@ -29,6 +29,7 @@ import (
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/bucket"
 	"github.com/rclone/rclone/lib/encoder"
@@ -56,6 +57,8 @@ const (
 	defaultChunkSize    = 96 * fs.MebiByte
 	defaultUploadCutoff = 200 * fs.MebiByte
 	largeFileCopyCutoff = 4 * fs.GibiByte          // 5E9 is the max
+	maxCopyChunkSize    = fs.SizeSuffix(5e9)       // the largest part b2_copy_part can copy
+	copyConcurrency     = 4                        // default number of chunks to copy at once
 	memoryPoolFlushTime = fs.Duration(time.Minute) // flush the cached buffers after this long
 	memoryPoolUseMmap   = false
 )
@@ -71,6 +74,7 @@ func init() {
 		Name:        "b2",
 		Description: "Backblaze B2",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Options: []fs.Option{{
 			Name:     "account",
 			Help:     "Account ID or Application Key ID",
@@ -122,11 +126,24 @@ This value should be set no larger than 4.657GiB (== 5GB).`,
 			Help: `Cutoff for switching to multipart copy
 
 Any files larger than this that need to be server side copied will be
-copied in chunks of this size.
+copied in chunks of this size with b2_copy_part so they are copied
+without being downloaded. If the file would need more than 10,000
+chunks then the chunk size is increased to fit.
 
-The minimum is 0 and the maximum is 4.6GB.`,
+The minimum is 5M and the maximum is 4.656G (== 5GB). Values outside
+this range are changed to the nearest limit with a warning.`,
 			Default:  largeFileCopyCutoff,
 			Advanced: true,
+		}, {
+			Name: "copy_concurrency",
+			Help: `Number of chunks of a large file to server side copy at once.
+
+Large files are server side copied in chunks of "--b2-copy-cutoff"
+and this many chunks of each file are copied in parallel. As the data
+isn't transferred through rclone, increasing this can speed up copies
+and moves of large files a lot without using more memory.`,
+			Default:  copyConcurrency,
+			Advanced: true,
 		}, {
 			Name: "chunk_size",
 			Help: `Upload chunk size. Must fit in memory.
@@ -200,6 +217,7 @@ type Options struct {
 	HardDelete                    bool                 `config:"hard_delete"`
 	UploadCutoff                  fs.SizeSuffix        `config:"upload_cutoff"`
 	CopyCutoff                    fs.SizeSuffix        `config:"copy_cutoff"`
+	CopyConcurrency               int                  `config:"copy_concurrency"`
 	ChunkSize                     fs.SizeSuffix        `config:"chunk_size"`
 	DisableCheckSum               bool                 `config:"disable_checksum"`
 	DownloadURL                   string               `config:"download_url"`
@@ -229,6 +247,7 @@ type Fs struct {
 	authMu          sync.Mutex                             // lock for authorizing the account
 	pacer           *fs.Pacer                              // To pace and retry the API calls
 	uploadToken     *pacer.TokenDispenser                  // control concurrency
+	copyToken       *pacer.TokenDispenser                  // control concurrency of server side copies
 	pool            *pool.Pool                             // memory pool
 }
 
@@ -376,6 +395,23 @@ func checkUploadCutoff(opt *Options, cs fs.SizeSuffix) error {
 	return nil
 }
 
+// clampCopyCutoff returns cs limited to the chunk sizes b2_copy_part
+// can copy, warning if it had to be changed so configs made before
+// the limits were checked still work.
+func clampCopyCutoff(cs fs.SizeSuffix) fs.SizeSuffix {
+	clamped := cs
+	if clamped < minChunkSize {
+		clamped = minChunkSize
+	}
+	if clamped > maxCopyChunkSize {
+		clamped = maxCopyChunkSize
+	}
+	if clamped != cs {
+		fs.Logf(nil, "b2: copy cutoff %s is out of range - using %s", cs, clamped)
+	}
+	return clamped
+}
+
 func (f *Fs) setUploadCutoff(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
 	err = checkUploadCutoff(&f.opt, cs)
 	if err == nil {
@@ -407,6 +443,10 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "b2: chunk size")
 	}
+	opt.CopyCutoff = clampCopyCutoff(opt.CopyCutoff)
+	if opt.CopyConcurrency < 1 {
+		return nil, errors.Errorf("b2: copy concurrency must be at least 1 not %d", opt.CopyConcurrency)
+	}
 	if opt.Account == "" {
 		return nil, errors.New("account not found")
 	}
@@ -426,6 +466,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		uploads:     make(map[string][]*api.GetUploadURLResponse),
 		pacer:       fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
 		uploadToken: pacer.NewTokenDispenser(fs.Config.Transfers),
+		copyToken:   pacer.NewTokenDispenser(opt.CopyConcurrency),
 		pool: pool.New(
 			time.Duration(opt.MemoryPoolFlushTime),
 			int(opt.ChunkSize),
@@ -570,22 +611,32 @@ func (f *Fs) clearUploadURL(bucketID string) {
 
 // getBuf gets a buffer of f.opt.ChunkSize and an upload token
 //
-// If noBuf is set then it just gets an upload token
-func (f *Fs) getBuf(noBuf bool) (buf []byte) {
+// If noBuf is set then it just gets a copy token as server side
+// copies don't need a buffer. It returns an error if ctx is cancelled
+// while waiting for the buffer.
+func (f *Fs) getBuf(ctx context.Context, noBuf bool) (buf []byte, err error) {
+	if noBuf {
+		f.copyToken.Get()
+		return nil, nil
+	}
 	f.uploadToken.Get()
-	if !noBuf {
-		buf = f.pool.Get()
+	buf, err = f.pool.GetContext(ctx)
+	if err != nil {
+		f.uploadToken.Put()
+		return nil, err
 	}
-	return buf
+	return buf, nil
 }
 
 // putBuf returns a buffer to the memory pool and an upload token
 //
-// If noBuf is set then it just returns the upload token
+// If noBuf is set then it just returns the copy token
 func (f *Fs) putBuf(buf []byte, noBuf bool) {
-	if !noBuf {
-		f.pool.Put(buf)
+	if noBuf {
+		f.copyToken.Put()
+		return
 	}
+	f.pool.Put(buf)
 	f.uploadToken.Put()
 }
 
@@ -1234,6 +1285,18 @@ func (f *Fs) CleanUp(ctx context.Context) error {
 	return f.purge(ctx, "", true)
 }
 
+// copyChunkSize returns the chunk size to server side copy a file of
+// size in, increasing chunkSize if needed so it takes no more than
+// maxParts chunks
+func copyChunkSize(chunkSize fs.SizeSuffix, size int64) fs.SizeSuffix {
+	if size > int64(chunkSize)*maxParts {
+		chunkSize = fs.SizeSuffix((size + maxParts - 1) / maxParts)
+		// round up to a whole MiB
+		chunkSize = (chunkSize + fs.MebiByte - 1) / fs.MebiByte * fs.MebiByte
+	}
+	return chunkSize
+}
+
 // copy does a server side copy from dstObj <- srcObj
 //
 // If newInfo is nil then the metadata will be copied otherwise it
@@ -1246,7 +1309,7 @@ func (f *Fs) copy(ctx context.Context, dstObj *Object, srcObj *Object, newInfo *
 				return err
 			}
 		}
-		up, err := f.newLargeUpload(ctx, dstObj, nil, srcObj, f.opt.CopyCutoff, true, newInfo)
+		up, err := f.newLargeUpload(ctx, dstObj, nil, srcObj, copyChunkSize(f.opt.CopyCutoff, srcObj.size), true, newInfo)
 		if err != nil {
 			return err
 		}
@@ -1765,7 +1828,10 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	}
 	if size == -1 {
 		// Check if the file is large enough for a chunked upload (needs to be at least two chunks)
-		buf := o.fs.getBuf(false)
+		buf, err := o.fs.getBuf(ctx, false)
+		if err != nil {
+			return err
+		}
 
 		n, err := io.ReadFull(in, buf)
 		if err == nil {
@@ -1927,6 +1993,339 @@ func (o *Object) ID() string {
 	return o.id
 }
 
+// lifecycleRule makes the rule described by opt for files starting
+// with prefix. It returns nil if opt has no rule in.
+func lifecycleRule(prefix string, opt map[string]string) (rule *api.LifecycleRule, err error) {
+	rule = &api.LifecycleRule{
+		FileNamePrefix: prefix,
+	}
+	found := false
+	for key, days := range map[string]**int{
+		"daysFromHidingToDeleting":  &rule.DaysFromHidingToDeleting,
+		"daysFromUploadingToHiding": &rule.DaysFromUploadingToHiding,
+	} {
+		value, ok := opt[key]
+		if !ok {
+			continue
+		}
+		found = true
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad %s", key)
+		}
+		if n < 1 {
+			return nil, errors.Errorf("%s must be at least 1 day not %d", key, n)
+		}
+		*days = &n
+	}
+	if !found {
+		return nil, nil
+	}
+	return rule, nil
+}
+
+// setLifecycleRule returns rules with the rule for prefix replaced
+// with rule, or removed if rule is nil
+func setLifecycleRule(rules []api.LifecycleRule, prefix string, rule *api.LifecycleRule) []api.LifecycleRule {
+	newRules := []api.LifecycleRule{}
+	for _, oldRule := range rules {
+		if oldRule.FileNamePrefix != prefix {
+			newRules = append(newRules, oldRule)
+		}
+	}
+	if rule != nil {
+		newRules = append(newRules, *rule)
+	}
+	return newRules
+}
+
+// lifecycle reads and sets the lifecycle rules of the root bucket
+func (f *Fs) lifecycle(ctx context.Context, opt map[string]string) (rules []api.LifecycleRule, err error) {
+	if f.rootBucket == "" {
+		return nil, errors.New("need a bucket to read or set lifecycle rules")
+	}
+	prefix := ""
+	if f.rootDirectory != "" {
+		prefix = f.opt.Enc.FromStandardPath(f.rootDirectory) + "/"
+	}
+	rule, err := lifecycleRule(prefix, opt)
+	if err != nil {
+		return nil, err
+	}
+	_, clear := opt["clear"]
+	if rule != nil && clear {
+		return nil, errors.New("can't set and clear a lifecycle rule at the same time")
+	}
+
+	var bucket *api.Bucket
+	err = f.listBucketsToFn(ctx, func(b *api.Bucket) error {
+		if b.Name == f.rootBucket {
+			bucket = b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		return nil, fs.ErrorDirNotFound
+	}
+	if rule == nil && !clear {
+		if bucket.LifecycleRules == nil {
+			return []api.LifecycleRule{}, nil
+		}
+		return bucket.LifecycleRules, nil
+	}
+
+	rules = setLifecycleRule(bucket.LifecycleRules, prefix, rule)
+	if operations.SkipDestructive(ctx, f.rootBucket, "update lifecycle rules") {
+		return rules, nil
+	}
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "/b2_update_bucket",
+	}
+	var request = api.UpdateBucketRequest{
+		AccountID:      f.info.AccountID,
+		ID:             bucket.ID,
+		LifecycleRules: rules,
+	}
+	var response api.Bucket
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err := f.srv.CallJSON(ctx, &opts, &request, &response)
+		return f.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update lifecycle rules")
+	}
+	if response.LifecycleRules == nil {
+		return []api.LifecycleRule{}, nil
+	}
+	return response.LifecycleRules, nil
+}
+
+// defaultKeyCapabilities are the capabilities of keys made by
+// create-key if none are given - enough to sync to and from a bucket
+var defaultKeyCapabilities = []string{"listBuckets", "listFiles", "readFiles", "shareFiles", "writeFiles", "deleteFiles"}
+
+// maxKeyDuration is the longest a key can be valid for
+const maxKeyDuration = 1000 * 24 * time.Hour
+
+// createKeyResult is returned by the create-key command
+type createKeyResult struct {
+	Account      string   `json:"account"`           // the application key ID for the account config
+	Key          string   `json:"key"`               // the application key for the key config
+	Name         string   `json:"name"`              // name of the key
+	Capabilities []string `json:"capabilities"`      // what the key can do
+	Bucket       string   `json:"bucket,omitempty"`  // bucket the key is restricted to
+	Prefix       string   `json:"prefix,omitempty"`  // prefix the key is restricted to
+	Expires      string   `json:"expires,omitempty"` // when the key expires if it does
+}
+
+// parseKeyCapabilities parses a comma separated list of capabilities
+func parseKeyCapabilities(in string) (capabilities []string) {
+	for _, capability := range strings.Split(in, ",") {
+		capability = strings.TrimSpace(capability)
+		if capability != "" {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// createKey makes an application key called name restricted to the
+// root of the Fs
+func (f *Fs) createKey(ctx context.Context, name string, opt map[string]string) (out *createKeyResult, err error) {
+	var request = api.CreateKeyRequest{
+		AccountID:    f.info.AccountID,
+		KeyName:      name,
+		Capabilities: defaultKeyCapabilities,
+	}
+	if capabilities, ok := opt["capabilities"]; ok {
+		request.Capabilities = parseKeyCapabilities(capabilities)
+		if len(request.Capabilities) == 0 {
+			return nil, errors.New("need at least one capability")
+		}
+	}
+	if duration, ok := opt["duration"]; ok {
+		d, err := fs.ParseDuration(duration)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad duration")
+		}
+		if d < time.Second || d > maxKeyDuration {
+			return nil, errors.Errorf("duration must be between 1s and %v not %v", maxKeyDuration, d)
+		}
+		request.ValidDurationInSeconds = int64(d / time.Second)
+	}
+	if f.rootBucket != "" {
+		request.BucketID, err = f.getBucketID(ctx, f.rootBucket)
+		if err != nil {
+			return nil, err
+		}
+		if f.rootDirectory != "" {
+			request.NamePrefix = f.opt.Enc.FromStandardPath(f.rootDirectory) + "/"
+		}
+	}
+	if operations.SkipDestructive(ctx, name, "create application key") {
+		return nil, nil
+	}
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "/b2_create_key",
+	}
+	var response api.Key
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err := f.srv.CallJSON(ctx, &opts, &request, &response)
+		return f.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create key")
+	}
+	out = &createKeyResult{
+		Account:      response.ApplicationKeyID,
+		Key:          response.ApplicationKey,
+		Name:         response.KeyName,
+		Capabilities: response.Capabilities,
+		Bucket:       f.rootBucket,
+		Prefix:       response.NamePrefix,
+	}
+	if response.ExpirationTimestamp != nil {
+		out.Expires = time.Time(*response.ExpirationTimestamp).Format(time.RFC3339)
+	}
+	return out, nil
+}
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "lifecycle",
+	Short: "Read or set the lifecycle rules of a bucket",
+	Long: `This command reads or sets the lifecycle rules of a bucket which
+B2 uses to hide and delete files automatically.
+
+To show the current lifecycle rules:
+
+    rclone backend lifecycle b2:bucket
+
+This returns the rules as JSON, or [] if there are none (the default).
+
+    [
+        {
+            "daysFromHidingToDeleting": 30,
+            "daysFromUploadingToHiding": null,
+            "fileNamePrefix": ""
+        }
+    ]
+
+To keep old versions of files for 30 days after they are overwritten
+or deleted then delete them:
+
+    rclone backend lifecycle b2:bucket -o daysFromHidingToDeleting=30
+
+To hide files 7 days after they are uploaded then delete them a day
+later:
+
+    rclone backend lifecycle b2:bucket -o daysFromUploadingToHiding=7 -o daysFromHidingToDeleting=1
+
+To remove the rule:
+
+    rclone backend lifecycle b2:bucket -o clear
+
+If a path is given as well as the bucket then the rule applies to the
+files in that directory only, so
+
+    rclone backend lifecycle b2:bucket/logs -o daysFromHidingToDeleting=7
+
+sets the rule for files starting with "logs/". Setting or clearing a
+rule only replaces the rule for the same path - rules for other paths
+are left alone. Rclone prints the new rules when it has set them.
+
+You can't turn off versioning in B2. The nearest you can get is
+daysFromHidingToDeleting=1, or --b2-hard-delete which stops deletions
+making old versions, though overwrites still do.
+
+Use -i/--interactive or --dry-run to see the new rules without
+setting them.
+
+See: https://www.backblaze.com/b2/docs/lifecycle_rules.html
+`,
+	Opts: map[string]string{
+		"daysFromHidingToDeleting":  "Number of days after a file is hidden (overwritten or deleted) that it is deleted",
+		"daysFromUploadingToHiding": "Number of days after a file is uploaded that it is hidden",
+		"clear":                     "Remove the lifecycle rule",
+	},
+}, {
+	Name:  "create-key",
+	Short: "Create an application key restricted to a bucket or path",
+	Long: `This command creates a new application key which can only access the
+bucket and path given. It needs the name of the key as an argument.
+
+    rclone backend create-key b2:bucket/path name
+
+The key can be limited further with these options
+
+    rclone backend create-key b2:bucket/path name -o capabilities=listBuckets,listFiles,readFiles -o duration=30d
+
+The capabilities default to those needed to sync to and from the
+bucket: listBuckets, listFiles, readFiles, shareFiles, writeFiles and
+deleteFiles. See the B2 docs for the full list.
+
+The duration is how long the key is valid for, eg 12h, 30d or 52w, up
+to 1000 days. By default keys don't expire.
+
+If no bucket is given the key can access all the buckets in the
+account, and if no path is given the key can access the whole bucket.
+
+The key used by rclone to run this must have the writeKeys
+capability, which the master application key has.
+
+It returns the new key as JSON. The account and key values are what
+to put in the config of a remote which uses it. The key is only shown
+once so keep it safe.
+
+    {
+        "account": "0025d31d6e1a4cd0000000003",
+        "key": "K002xxxxxxxxxxxxxxxxxxxxxxxxxxx",
+        "name": "name",
+        "capabilities": [
+            "listBuckets",
+            "listFiles",
+            "readFiles",
+            "shareFiles",
+            "writeFiles",
+            "deleteFiles"
+        ],
+        "bucket": "bucket",
+        "prefix": "path/"
+    }
+`,
+	Opts: map[string]string{
+		"capabilities": "Comma separated list of capabilities for the key",
+		"duration":     "How long the key is valid for, eg 30d",
+	},
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "lifecycle":
+		return f.lifecycle(ctx, opt)
+	case "create-key":
+		if len(arg) != 1 {
+			return nil, errors.New("need the name of the key as the only argument")
+		}
+		return f.createKey(ctx, arg[0], opt)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs           = &Fs{}
@@ -1936,6 +2335,7 @@ var (
 	_ fs.CleanUpper   = &Fs{}
 	_ fs.ListRer      = &Fs{}
 	_ fs.PublicLinker = &Fs{}
+	_ fs.Commander    = &Fs{}
 	_ fs.Object       = &Object{}
 	_ fs.MimeTyper    = &Object{}
 	_ fs.IDer         = &Object{}
@@ -1,10 +1,14 @@
 package b2
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/rclone/rclone/backend/b2/api"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
 )
 
 // Test b2 string encoding
@@ -168,3 +172,79 @@ func TestParseTimeString(t *testing.T) {
 	}
 
 }
+
+func TestCopyChunkSize(t *testing.T) {
+	for _, test := range []struct {
+		chunkSize fs.SizeSuffix
+		size      int64
+		want      fs.SizeSuffix
+	}{
+		{largeFileCopyCutoff, 10 * int64(fs.GibiByte), largeFileCopyCutoff},
+		{minChunkSize, int64(minChunkSize) * maxParts, minChunkSize},
+		{minChunkSize, int64(minChunkSize)*maxParts + 1, 6 * fs.MebiByte},
+		{minChunkSize, 10 * 1024 * int64(fs.GibiByte), 1049 * fs.MebiByte},
+	} {
+		got := copyChunkSize(test.chunkSize, test.size)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%v %d", test.chunkSize, test.size))
+		parts := (test.size + int64(got) - 1) / int64(got)
+		assert.True(t, parts <= maxParts, "too many parts %d", parts)
+	}
+}
+
+func TestClampCopyCutoff(t *testing.T) {
+	assert.Equal(t, largeFileCopyCutoff, clampCopyCutoff(largeFileCopyCutoff))
+	assert.Equal(t, minChunkSize, clampCopyCutoff(minChunkSize))
+	assert.Equal(t, maxCopyChunkSize, clampCopyCutoff(maxCopyChunkSize))
+	assert.Equal(t, minChunkSize, clampCopyCutoff(0))
+	assert.Equal(t, maxCopyChunkSize, clampCopyCutoff(maxCopyChunkSize+1))
+}
+
+func TestLifecycleRule(t *testing.T) {
+	rule, err := lifecycleRule("", map[string]string{})
+	assert.NoError(t, err)
+	assert.Nil(t, rule)
+
+	rule, err = lifecycleRule("logs/", map[string]string{"daysFromHidingToDeleting": "30"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, rule) {
+		assert.Equal(t, "logs/", rule.FileNamePrefix)
+		assert.Equal(t, 30, *rule.DaysFromHidingToDeleting)
+		assert.Nil(t, rule.DaysFromUploadingToHiding)
+	}
+
+	rule, err = lifecycleRule("", map[string]string{"daysFromHidingToDeleting": "1", "daysFromUploadingToHiding": "7"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, rule) {
+		assert.Equal(t, 1, *rule.DaysFromHidingToDeleting)
+		assert.Equal(t, 7, *rule.DaysFromUploadingToHiding)
+	}
+
+	_, err = lifecycleRule("", map[string]string{"daysFromHidingToDeleting": "potato"})
+	assert.Error(t, err)
+	_, err = lifecycleRule("", map[string]string{"daysFromUploadingToHiding": "0"})
+	assert.Error(t, err)
+}
+
+func TestSetLifecycleRule(t *testing.T) {
+	days := 30
+	rules := []api.LifecycleRule{
+		{FileNamePrefix: ""},
+		{FileNamePrefix: "logs/"},
+	}
+	newRule := &api.LifecycleRule{FileNamePrefix: "logs/", DaysFromHidingToDeleting: &days}
+
+	got := setLifecycleRule(rules, "logs/", newRule)
+	assert.Equal(t, []api.LifecycleRule{{FileNamePrefix: ""}, *newRule}, got)
+
+	got = setLifecycleRule(rules, "logs/", nil)
+	assert.Equal(t, []api.LifecycleRule{{FileNamePrefix: ""}}, got)
+
+	got = setLifecycleRule(nil, "", nil)
+	assert.Equal(t, []api.LifecycleRule{}, got)
+}
+
+func TestParseKeyCapabilities(t *testing.T) {
+	assert.Equal(t, []string(nil), parseKeyCapabilities(""))
+	assert.Equal(t, []string{"listFiles"}, parseKeyCapabilities("listFiles"))
+	assert.Equal(t, []string{"listFiles", "readFiles"}, parseKeyCapabilities(" listFiles, readFiles,,"))
+}
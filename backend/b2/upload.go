@@ -22,6 +22,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/rclone/rclone/lib/rest"
+	"github.com/rclone/rclone/lib/resume"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -84,6 +85,40 @@ type largeUpload struct {
 	uploads   []*api.GetUploadPartURLResponse // result of get upload URL calls
 	chunkSize int64                           // chunk size to use
 	src       *Object                         // if copying, object we are reading from
+	resumer   *resume.Upload                  // state for resuming the upload, may be nil
+	doneParts map[int64]string                // SHA1s of parts uploaded by a previous attempt
+}
+
+// listParts returns the SHA1s of the parts already uploaded to the
+// large file id indexed by part number
+func (f *Fs) listParts(ctx context.Context, id string) (sha1s map[int64]string, err error) {
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "/b2_list_parts",
+	}
+	var request = api.ListPartsRequest{
+		ID:           id,
+		MaxPartCount: 1000,
+	}
+	sha1s = make(map[int64]string)
+	for {
+		var response api.ListPartsResponse
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(ctx, &opts, &request, &response)
+			return f.shouldRetry(ctx, resp, err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range response.Parts {
+			sha1s[part.PartNumber] = part.SHA1
+		}
+		if response.NextPartNumber == nil {
+			break
+		}
+		request.StartPartNumber = *response.NextPartNumber
+	}
+	return sha1s, nil
 }
 
 // newLargeUpload starts an upload of object o from in with metadata in src
@@ -136,24 +171,48 @@ func (f *Fs) newLargeUpload(ctx context.Context, o *Object, in io.Reader, src fs
 		request.ContentType = newInfo.ContentType
 		request.Info = newInfo.Info
 	}
-	var response api.StartLargeFileResponse
-	err = f.pacer.Call(func() (bool, error) {
-		resp, err := f.srv.CallJSON(ctx, &opts, &request, &response)
-		return f.shouldRetry(ctx, resp, err)
-	})
-	if err != nil {
-		return nil, err
+	// See if we can resume a previous upload
+	var (
+		id        string
+		doneParts map[int64]string
+		resumer   *resume.Upload
+	)
+	if !doCopy {
+		resumer = resume.New(ctx, f, remote, src)
+	}
+	if resumeID, ok := resumer.Resume(int64(chunkSize)); ok {
+		doneParts, err = f.listParts(ctx, resumeID)
+		if err != nil {
+			fs.Debugf(o, "Can't resume large file upload - starting again: %v", err)
+			resumer.Done()
+		} else {
+			id = resumeID
+		}
+	}
+	if id == "" {
+		var response api.StartLargeFileResponse
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(ctx, &opts, &request, &response)
+			return f.shouldRetry(ctx, resp, err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		id = response.ID
+		resumer.Start(id, int64(chunkSize))
 	}
 	up = &largeUpload{
 		f:         f,
 		o:         o,
 		doCopy:    doCopy,
 		what:      "upload",
-		id:        response.ID,
+		id:        id,
 		size:      size,
 		parts:     parts,
 		sha1s:     make([]string, sha1SliceSize),
 		chunkSize: int64(chunkSize),
+		resumer:   resumer,
+		doneParts: doneParts,
 	}
 	// unwrap the accounting from the input, we use wrap to put it
 	// back on after the buffering
@@ -271,6 +330,7 @@ func (up *largeUpload) transferChunk(ctx context.Context, part int64, body []byt
 		fs.Debugf(up.o, "Error sending chunk %d: %v", part, err)
 	} else {
 		fs.Debugf(up.o, "Done sending chunk %d", part)
+		up.resumer.AddPart(part, (part-1)*up.chunkSize, body, up.sha1s[part-1])
 	}
 	return err
 }
@@ -326,6 +386,7 @@ func (up *largeUpload) finish(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	up.resumer.Done()
 	return up.o.decodeMetaDataFileInfo(&response)
 }
 
@@ -370,7 +431,10 @@ func (up *largeUpload) Stream(ctx context.Context, initialUploadBlock []byte) (e
 			if part == 1 {
 				buf = initialUploadBlock
 			} else {
-				buf = up.f.getBuf(false)
+				buf, err = up.f.getBuf(gCtx, false)
+				if err != nil {
+					return err
+				}
 			}
 
 			// Fail fast, in case an errgroup managed function returns an error
@@ -427,7 +491,18 @@ func (up *largeUpload) Stream(ctx context.Context, initialUploadBlock []byte) (e
 
 // Upload uploads the chunks from the input
 func (up *largeUpload) Upload(ctx context.Context) (err error) {
-	defer atexit.OnError(&err, func() { _ = up.cancel(ctx) })()
+	defer atexit.OnError(&err, func() {
+		// Leave the parts for the next attempt if resuming
+		retry := false
+		if apiErr, ok := errors.Cause(err).(*api.Error); ok {
+			for _, code := range retryErrorCodes {
+				retry = retry || apiErr.Status == code
+			}
+		}
+		if !up.resumer.Keep(err, retry) {
+			_ = up.cancel(ctx)
+		}
+	})()
 	fs.Debugf(up.o, "Starting %s of large file in %d chunks (id %q)", up.what, up.parts, up.id)
 	var (
 		g, gCtx   = errgroup.WithContext(ctx)
@@ -436,7 +511,10 @@ func (up *largeUpload) Upload(ctx context.Context) (err error) {
 	g.Go(func() error {
 		for part := int64(1); part <= up.parts; part++ {
 			// Get a block of memory from the pool and token which limits concurrency.
-			buf := up.f.getBuf(up.doCopy)
+			buf, err := up.f.getBuf(gCtx, up.doCopy)
+			if err != nil {
+				return err
+			}
 
 			// Fail fast, in case an errgroup managed function returns an error
 			// gCtx is cancelled. There is no point in uploading all the other parts.
@@ -464,6 +542,12 @@ func (up *largeUpload) Upload(ctx context.Context) (err error) {
 			g.Go(func() (err error) {
 				defer up.f.putBuf(buf, up.doCopy)
 				if !up.doCopy {
+					// skip the part if a previous attempt uploaded it
+					if sha1, ok := up.resumer.Verify(part, buf); ok && up.doneParts[part] == sha1 {
+						fs.Debugf(up.o, "Skipping chunk %d already uploaded", part)
+						up.sha1s[part-1] = sha1
+						return nil
+					}
 					err = up.transferChunk(gCtx, part, buf)
 				} else {
 					err = up.copyChunk(gCtx, part, reqSize)
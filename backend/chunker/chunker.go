@@ -4,12 +4,9 @@ package chunker
 import (
 	"bytes"
 	"context"
-	"crypto/md5"
-	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	gohash "hash"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -136,6 +133,7 @@ func init() {
 		Name:        "chunker",
 		Description: "Transparently chunk/split large files",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Options: []fs.Option{{
 			Name:     "remote",
 			Required: true,
@@ -175,7 +173,7 @@ Metadata is a small JSON file named after the composite file.`,
 			}, {
 				Value: "simplejson",
 				Help: `Simple JSON supports hash sums and chunk validation.
-It has the following fields: ver, size, nchunks, md5, sha1.`,
+It has the following fields: ver, size, nchunks, md5, sha1, htype, hash.`,
 			}},
 		}, {
 			Name:     "hash_type",
@@ -203,6 +201,18 @@ It has the following fields: ver, size, nchunks, md5, sha1.`,
 			}, {
 				Value: "sha1quick",
 				Help:  `Similar to "md5quick" but prefers SHA1 over MD5`,
+			}, {
+				Value: "blake3",
+				Help:  `BLAKE3 for composite files`,
+			}, {
+				Value: "xxh3",
+				Help:  `XXH3 for composite files`,
+			}, {
+				Value: "blake3all",
+				Help:  `BLAKE3 for all files`,
+			}, {
+				Value: "xxh3all",
+				Help:  `XXH3 for all files`,
 			}},
 		}, {
 			Name:     "fail_hard",
@@ -319,6 +329,7 @@ type Fs struct {
 	useMeta      bool           // false if metadata format is 'none'
 	useMD5       bool           // mutually exclusive with useSHA1
 	useSHA1      bool           // mutually exclusive with useMD5
+	xhashType    hash.Type      // hash used instead of MD5 and SHA1, eg BLAKE3, or hash.None
 	hashFallback bool           // allows fallback from MD5 to SHA1 and vice versa
 	hashAll      bool           // hash all files, mutually exclusive with hashFallback
 	dataNameFmt  string         // name format of data chunks
@@ -371,6 +382,7 @@ func (f *Fs) setMetaFormat(metaFormat string) error {
 func (f *Fs) setHashType(hashType string) error {
 	f.useMD5 = false
 	f.useSHA1 = false
+	f.xhashType = hash.None
 	f.hashFallback = false
 	f.hashAll = false
 	requireMetaHash := true
@@ -394,6 +406,11 @@ func (f *Fs) setHashType(hashType string) error {
 	case "sha1all":
 		f.useSHA1 = true
 		f.hashAll = !f.base.Hashes().Contains(hash.SHA1)
+	case "blake3", "xxh3":
+		f.xhashType = xhashTypes[hashType]
+	case "blake3all", "xxh3all":
+		f.xhashType = xhashTypes[strings.TrimSuffix(hashType, "all")]
+		f.hashAll = !f.base.Hashes().Contains(f.xhashType)
 	default:
 		return fmt.Errorf("unsupported hash type '%s'", hashType)
 	}
@@ -403,6 +420,29 @@ func (f *Fs) setHashType(hashType string) error {
 	return nil
 }
 
+// xhashTypes are the hash types other than MD5 and SHA1 which can be
+// used by chunker, keyed by the name recorded in the metadata.
+var xhashTypes = map[string]hash.Type{
+	"blake3": hash.BLAKE3,
+	"xxh3":   hash.XXH3,
+}
+
+// xhashName returns the name of hashType in the metadata or "" if
+// chunker doesn't support it.
+func xhashName(hashType hash.Type) string {
+	for name, ht := range xhashTypes {
+		if ht == hashType {
+			return name
+		}
+	}
+	return ""
+}
+
+// usesHash returns false if the hash type is "none"
+func (f *Fs) usesHash() bool {
+	return f.useMD5 || f.useSHA1 || f.xhashType != hash.None
+}
+
 // setChunkNameFormat converts pattern based chunk name format
 // into Printf format and Regular expressions for data and
 // control chunks.
@@ -914,6 +954,8 @@ func (o *Object) readMetadata(ctx context.Context) error {
 		}
 		o.md5 = metaInfo.md5
 		o.sha1 = metaInfo.sha1
+		o.xhashType = metaInfo.xhashType
+		o.xhash = metaInfo.xhash
 	}
 
 	o.isFull = true
@@ -1073,7 +1115,7 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, remote st
 	switch f.opt.MetaFormat {
 	case "simplejson":
 		c.updateHashes()
-		metadata, err = marshalSimpleJSON(ctx, sizeTotal, len(c.chunks), c.md5, c.sha1)
+		metadata, err = marshalSimpleJSON(ctx, sizeTotal, len(c.chunks), c.md5, c.sha1, f.xhashType, c.xhash)
 	}
 	if err == nil {
 		metaInfo := f.wrapInfo(src, baseRemote, int64(len(metadata)))
@@ -1104,9 +1146,11 @@ type chunkingReader struct {
 	expectSingle bool
 	smallHead    []byte
 	fs           *Fs
-	hasher       gohash.Hash
+	hasher       *hash.MultiHasher
+	hashType     hash.Type // type calculated by hasher
 	md5          string
 	sha1         string
+	xhash        string // sum of fs.xhashType
 }
 
 func (f *Fs) newChunkingReader(src fs.ObjectInfo) *chunkingReader {
@@ -1126,21 +1170,29 @@ func (c *chunkingReader) wrapStream(ctx context.Context, in io.Reader, src fs.Ob
 
 	switch {
 	case c.fs.useMD5:
-		if c.md5, _ = src.Hash(ctx, hash.MD5); c.md5 == "" {
-			if c.fs.hashFallback {
-				c.sha1, _ = src.Hash(ctx, hash.SHA1)
-			} else {
-				c.hasher = md5.New()
-			}
+		if c.md5, _ = src.Hash(ctx, hash.MD5); c.md5 == "" && c.fs.hashFallback {
+			c.sha1, _ = src.Hash(ctx, hash.SHA1)
 		}
 	case c.fs.useSHA1:
-		if c.sha1, _ = src.Hash(ctx, hash.SHA1); c.sha1 == "" {
-			if c.fs.hashFallback {
-				c.md5, _ = src.Hash(ctx, hash.MD5)
-			} else {
-				c.hasher = sha1.New()
-			}
+		if c.sha1, _ = src.Hash(ctx, hash.SHA1); c.sha1 == "" && c.fs.hashFallback {
+			c.md5, _ = src.Hash(ctx, hash.MD5)
+		}
+	case c.fs.xhashType != hash.None:
+		c.xhash, _ = src.Hash(ctx, c.fs.xhashType)
+	}
+
+	// Composite files always record a whole-file hash in their
+	// metadata, whatever the hash type, so they can be verified.
+	if c.md5 == "" && c.sha1 == "" && c.xhash == "" && c.fs.useMeta {
+		switch {
+		case c.fs.useSHA1:
+			c.hashType = hash.SHA1
+		case c.fs.xhashType != hash.None:
+			c.hashType = c.fs.xhashType
+		default:
+			c.hashType = hash.MD5
 		}
+		c.hasher, _ = hash.NewMultiHasherTypes(hash.NewHashSet(c.hashType))
 	}
 
 	if c.hasher != nil {
@@ -1154,11 +1206,14 @@ func (c *chunkingReader) updateHashes() {
 	if c.hasher == nil {
 		return
 	}
-	switch {
-	case c.fs.useMD5:
-		c.md5 = hex.EncodeToString(c.hasher.Sum(nil))
-	case c.fs.useSHA1:
-		c.sha1 = hex.EncodeToString(c.hasher.Sum(nil))
+	sum := c.hasher.Sums()[c.hashType]
+	switch c.hashType {
+	case hash.MD5:
+		c.md5 = sum
+	case hash.SHA1:
+		c.sha1 = sum
+	default:
+		c.xhash = sum
 	}
 }
 
@@ -1318,6 +1373,9 @@ func (f *Fs) Hashes() hash.Set {
 	if f.useSHA1 && !f.hashFallback && (f.hashAll || f.base.Hashes().Contains(hash.SHA1)) {
 		return hash.NewHashSet(hash.SHA1)
 	}
+	if f.xhashType != hash.None && (f.hashAll || f.base.Hashes().Contains(f.xhashType)) {
+		return hash.NewHashSet(f.xhashType)
+	}
 	return hash.NewHashSet() // can't provide strong guarantees
 }
 
@@ -1421,7 +1479,7 @@ func (o *Object) Remove(ctx context.Context) (err error) {
 }
 
 // copyOrMove implements copy or move
-func (f *Fs) copyOrMove(ctx context.Context, o *Object, remote string, do copyMoveFn, md5, sha1, opName string) (fs.Object, error) {
+func (f *Fs) copyOrMove(ctx context.Context, o *Object, remote string, do copyMoveFn, md5, sha1, xhash, opName string) (fs.Object, error) {
 	if err := f.forbidChunk(o, remote); err != nil {
 		return nil, errors.Wrapf(err, "can't %s", opName)
 	}
@@ -1485,7 +1543,7 @@ func (f *Fs) copyOrMove(ctx context.Context, o *Object, remote string, do copyMo
 	var metadata []byte
 	switch f.opt.MetaFormat {
 	case "simplejson":
-		metadata, err = marshalSimpleJSON(ctx, newObj.size, len(newChunks), md5, sha1)
+		metadata, err = marshalSimpleJSON(ctx, newObj.size, len(newChunks), md5, sha1, f.xhashType, xhash)
 		if err == nil {
 			metaInfo := f.wrapInfo(metaObject, "", int64(len(metadata)))
 			err = newObj.main.Update(ctx, bytes.NewReader(metadata), metaInfo)
@@ -1506,7 +1564,7 @@ func (f *Fs) copyOrMove(ctx context.Context, o *Object, remote string, do copyMo
 
 type copyMoveFn func(context.Context, fs.Object, string) (fs.Object, error)
 
-func (f *Fs) okForServerSide(ctx context.Context, src fs.Object, opName string) (obj *Object, md5, sha1 string, ok bool) {
+func (f *Fs) okForServerSide(ctx context.Context, src fs.Object, opName string) (obj *Object, md5, sha1, xhash string, ok bool) {
 	var diff string
 	obj, ok = src.(*Object)
 
@@ -1549,6 +1607,9 @@ func (f *Fs) okForServerSide(ctx context.Context, src fs.Object, opName string)
 			md5, _ = obj.Hash(ctx, hash.MD5)
 			ok = md5 != ""
 		}
+	case f.xhashType != hash.None:
+		xhash, _ = obj.Hash(ctx, f.xhashType)
+		ok = xhash != ""
 	default:
 		ok = false
 	}
@@ -1572,11 +1633,11 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	if baseCopy == nil {
 		return nil, fs.ErrorCantCopy
 	}
-	obj, md5, sha1, ok := f.okForServerSide(ctx, src, "copy")
+	obj, md5, sha1, xhash, ok := f.okForServerSide(ctx, src, "copy")
 	if !ok {
 		return nil, fs.ErrorCantCopy
 	}
-	return f.copyOrMove(ctx, obj, remote, baseCopy, md5, sha1, "copy")
+	return f.copyOrMove(ctx, obj, remote, baseCopy, md5, sha1, xhash, "copy")
 }
 
 // Move src to this remote using server side move operations.
@@ -1592,11 +1653,11 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	baseMove := func(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
 		return f.baseMove(ctx, src, remote, delNever)
 	}
-	obj, md5, sha1, ok := f.okForServerSide(ctx, src, "move")
+	obj, md5, sha1, xhash, ok := f.okForServerSide(ctx, src, "move")
 	if !ok {
 		return nil, fs.ErrorCantMove
 	}
-	return f.copyOrMove(ctx, obj, remote, baseMove, md5, sha1, "move")
+	return f.copyOrMove(ctx, obj, remote, baseMove, md5, sha1, xhash, "move")
 }
 
 // baseMove chains to the wrapped Move or simulates it by Copy+Delete
@@ -1706,14 +1767,16 @@ func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryT
 
 // Object represents a composite file wrapping one or more data chunks
 type Object struct {
-	remote string
-	main   fs.Object   // meta object if file is composite, or wrapped non-chunked file, nil if meta format is 'none'
-	chunks []fs.Object // active data chunks if file is composite, or wrapped file as a single chunk if meta format is 'none'
-	size   int64       // cached total size of chunks in a composite file or -1 for non-chunked files
-	isFull bool        // true if metadata has been read
-	md5    string
-	sha1   string
-	f      *Fs
+	remote    string
+	main      fs.Object   // meta object if file is composite, or wrapped non-chunked file, nil if meta format is 'none'
+	chunks    []fs.Object // active data chunks if file is composite, or wrapped file as a single chunk if meta format is 'none'
+	size      int64       // cached total size of chunks in a composite file or -1 for non-chunked files
+	isFull    bool        // true if metadata has been read
+	md5       string
+	sha1      string
+	xhashType hash.Type // type of xhash, eg BLAKE3
+	xhash     string
+	f         *Fs
 }
 
 func (o *Object) addChunk(chunk fs.Object, chunkNo int) error {
@@ -1871,19 +1934,26 @@ func (o *Object) Hash(ctx context.Context, hashType hash.Type) (string, error) {
 		return "", err // valid metadata is required to get hash, abort
 	}
 	// Try hash from metadata if the file is composite or if wrapped remote fails.
+	// With hash type "none" the hash in metadata is only used by verify.
 	switch hashType {
 	case hash.MD5:
-		if o.md5 == "" {
+		if o.md5 == "" || !o.f.usesHash() {
 			return "", nil
 		}
 		return o.md5, nil
 	case hash.SHA1:
-		if o.sha1 == "" {
+		if o.sha1 == "" || !o.f.usesHash() {
 			return "", nil
 		}
 		return o.sha1, nil
 	default:
-		return "", hash.ErrUnsupported
+		if hashType == hash.None || (hashType != o.f.xhashType && hashType != o.xhashType) {
+			return "", hash.ErrUnsupported
+		}
+		if o.xhash == "" || hashType != o.xhashType || !o.f.usesHash() {
+			return "", nil
+		}
+		return o.xhash, nil
 	}
 }
 
@@ -1928,14 +1998,20 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 }
 
 // linearReader opens and reads file chunks sequentially, without read-ahead
+//
+// Only the chunks overlapping the range being read are opened, each
+// with a range of just the part needed, and the reader can be moved
+// with RangeSeek without reading the chunks in between.
 type linearReader struct {
 	ctx     context.Context
 	chunks  []fs.Object
+	starts  []int64 // offset in the file of the start of each chunk
+	size    int64   // size of the file
 	options []fs.OpenOption
-	limit   int64
-	count   int64
-	pos     int
-	reader  io.ReadCloser
+	offset  int64         // offset in the file of the next byte to read
+	limit   int64         // number of bytes left to read
+	reader  io.ReadCloser // reader of the current chunk or nil
+	count   int64         // number of bytes left to read from reader
 	err     error
 }
 
@@ -1943,55 +2019,51 @@ func (o *Object) newLinearReader(ctx context.Context, offset, limit int64, optio
 	r := &linearReader{
 		ctx:     ctx,
 		chunks:  o.chunks,
+		starts:  make([]int64, len(o.chunks)),
 		options: options,
+		offset:  offset,
 		limit:   limit,
 	}
-
-	// skip to chunk for given offset
-	err := io.EOF
-	for offset >= 0 && err != nil {
-		offset, err = r.nextChunk(offset)
-	}
-	if err == nil || err == io.EOF {
-		r.err = err
-		return r, nil
+	for i, chunk := range o.chunks {
+		r.starts[i] = r.size
+		r.size += chunk.Size()
 	}
-	return nil, err
-}
-
-func (r *linearReader) nextChunk(offset int64) (int64, error) {
-	if r.err != nil {
-		return -1, r.err
+	if r.offset+r.limit > r.size {
+		r.limit = r.size - r.offset
 	}
-	if r.pos >= len(r.chunks) || r.limit <= 0 || offset < 0 {
-		return -1, io.EOF
+	// open the first chunk now to return any errors from Open
+	if r.limit > 0 {
+		if err := r.openChunk(); err != nil {
+			return nil, err
+		}
 	}
+	return r, nil
+}
 
-	chunk := r.chunks[r.pos]
-	count := chunk.Size()
-	r.pos++
-
-	if offset >= count {
-		return offset - count, io.EOF
+// openChunk opens the chunk containing the current offset to read up
+// to the limit from it
+func (r *linearReader) openChunk() error {
+	// find the first chunk ending after offset, skipping empty chunks
+	pos := sort.Search(len(r.chunks), func(i int) bool {
+		return r.starts[i]+r.chunks[i].Size() > r.offset
+	})
+	if pos >= len(r.chunks) {
+		return io.EOF
 	}
-	count -= offset
+	chunk := r.chunks[pos]
+	start := r.offset - r.starts[pos]
+	count := chunk.Size() - start
 	if r.limit < count {
 		count = r.limit
 	}
-	options := append(r.options, &fs.RangeOption{Start: offset, End: offset + count - 1})
-
-	if err := r.Close(); err != nil {
-		return -1, err
-	}
-
+	options := append(append([]fs.OpenOption{}, r.options...), &fs.RangeOption{Start: start, End: start + count - 1})
 	reader, err := chunk.Open(r.ctx, options...)
 	if err != nil {
-		return -1, err
+		return err
 	}
-
 	r.reader = reader
 	r.count = count
-	return offset, nil
+	return nil
 }
 
 func (r *linearReader) Read(p []byte) (n int, err error) {
@@ -1999,29 +2071,66 @@ func (r *linearReader) Read(p []byte) (n int, err error) {
 		return 0, r.err
 	}
 	if r.limit <= 0 {
-		r.err = io.EOF
 		return 0, io.EOF
 	}
-
-	for r.count <= 0 {
-		// current chunk has been read completely or its size is zero
-		off, err := r.nextChunk(0)
-		if off < 0 {
+	if r.reader == nil {
+		if err = r.openChunk(); err != nil {
 			r.err = err
 			return 0, err
 		}
 	}
-
+	if int64(len(p)) > r.count {
+		p = p[:r.count]
+	}
 	n, err = r.reader.Read(p)
-	if err == nil || err == io.EOF {
-		r.count -= int64(n)
-		r.limit -= int64(n)
-		if r.limit > 0 {
-			err = nil // more data to read
-		}
+	r.offset += int64(n)
+	r.count -= int64(n)
+	r.limit -= int64(n)
+	if r.count <= 0 {
+		// current chunk has been read completely so the next
+		// Read opens the next one
+		if err == nil || err == io.EOF {
+			err = r.Close()
+		}
+	} else if err == io.EOF {
+		err = io.ErrUnexpectedEOF
 	}
 	r.err = err
-	return
+	return n, err
+}
+
+// RangeSeek moves the reader to offset relative to whence and limits
+// the data read from there to length bytes, or to the end of the file
+// if length is -1.
+func (r *linearReader) RangeSeek(ctx context.Context, offset int64, whence int, length int64) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("invalid offset")
+	}
+	if err := r.Close(); err != nil {
+		return 0, err
+	}
+	r.ctx = ctx
+	r.offset = offset
+	r.limit = r.size - offset
+	if length >= 0 && length < r.limit {
+		r.limit = length
+	}
+	r.err = nil
+	return offset, nil
+}
+
+// Seek moves the reader to offset relative to whence - see io.Seeker
+func (r *linearReader) Seek(offset int64, whence int) (int64, error) {
+	return r.RangeSeek(r.ctx, offset, whence, -1)
 }
 
 func (r *linearReader) Close() (err error) {
@@ -2034,13 +2143,15 @@ func (r *linearReader) Close() (err error) {
 
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
 type ObjectInfo struct {
-	src     fs.ObjectInfo
-	fs      *Fs
-	nChunks int    // number of data chunks
-	size    int64  // overrides source size by the total size of data chunks
-	remote  string // overrides remote name
-	md5     string // overrides MD5 checksum
-	sha1    string // overrides SHA1 checksum
+	src       fs.ObjectInfo
+	fs        *Fs
+	nChunks   int       // number of data chunks
+	size      int64     // overrides source size by the total size of data chunks
+	remote    string    // overrides remote name
+	md5       string    // overrides MD5 checksum
+	sha1      string    // overrides SHA1 checksum
+	xhashType hash.Type // type of xhash, eg BLAKE3
+	xhash     string    // overrides checksum of type xhashType
 }
 
 func (f *Fs) wrapInfo(src fs.ObjectInfo, newRemote string, totalSize int64) *ObjectInfo {
@@ -2106,6 +2217,9 @@ func (oi *ObjectInfo) Hash(ctx context.Context, hashType hash.Type) (string, err
 			return oi.sha1, nil
 		}
 	default:
+		if oi.xhash != "" && hashType == oi.xhashType {
+			return oi.xhash, nil
+		}
 		errUnsupported = hash.ErrUnsupported
 	}
 	if oi.Size() != oi.src.Size() {
@@ -2135,8 +2249,10 @@ type metaSimpleJSON struct {
 	Size     *int64 `json:"size"`    // total size of data chunks
 	ChunkNum *int   `json:"nchunks"` // number of data chunks
 	// optional extra fields
-	MD5  string `json:"md5,omitempty"`
-	SHA1 string `json:"sha1,omitempty"`
+	MD5      string `json:"md5,omitempty"`
+	SHA1     string `json:"sha1,omitempty"`
+	HashType string `json:"htype,omitempty"` // type of Hash, eg blake3
+	Hash     string `json:"hash,omitempty"`  // hash other than MD5 and SHA1
 }
 
 // marshalSimpleJSON
@@ -2146,7 +2262,7 @@ type metaSimpleJSON struct {
 // - if file contents can be mistaken as meta object
 // - if consistent hashing is On but wrapped remote can't provide given hash
 //
-func marshalSimpleJSON(ctx context.Context, size int64, nChunks int, md5, sha1 string) ([]byte, error) {
+func marshalSimpleJSON(ctx context.Context, size int64, nChunks int, md5, sha1 string, xhashType hash.Type, xhash string) ([]byte, error) {
 	version := metadataVersion
 	metadata := metaSimpleJSON{
 		// required core fields
@@ -2157,6 +2273,10 @@ func marshalSimpleJSON(ctx context.Context, size int64, nChunks int, md5, sha1 s
 		MD5:  md5,
 		SHA1: sha1,
 	}
+	if xhash != "" {
+		metadata.HashType = xhashName(xhashType)
+		metadata.Hash = xhash
+	}
 	data, err := json.Marshal(&metadata)
 	if err == nil && data != nil && len(data) >= maxMetadataSize {
 		// be a nitpicker, never produce something you can't consume
@@ -2217,6 +2337,14 @@ func unmarshalSimpleJSON(ctx context.Context, metaObject fs.Object, data []byte,
 			return nil, errors.New("wrong sha1 hash")
 		}
 	}
+	// Hashes unknown to this release are ignored
+	xhashType := xhashTypes[metadata.HashType]
+	if metadata.Hash != "" && xhashType != hash.None {
+		_, err = hex.DecodeString(metadata.Hash)
+		if len(metadata.Hash) != hash.Width(xhashType) || err != nil {
+			return nil, errors.Errorf("wrong %s hash", metadata.HashType)
+		}
+	}
 	// ChunkNum is allowed to be 0 in future versions
 	if *metadata.ChunkNum < 1 && *metadata.Version <= metadataVersion {
 		return nil, errors.New("wrong number of chunks")
@@ -2231,6 +2359,10 @@ func unmarshalSimpleJSON(ctx context.Context, metaObject fs.Object, data []byte,
 	info.nChunks = *metadata.ChunkNum
 	info.md5 = metadata.MD5
 	info.sha1 = metadata.SHA1
+	if xhashType != hash.None {
+		info.xhashType = xhashType
+		info.xhash = metadata.Hash
+	}
 	return info, nil
 }
 
@@ -2282,4 +2414,5 @@ var (
 	_ fs.Object          = (*Object)(nil)
 	_ fs.ObjectUnWrapper = (*Object)(nil)
 	_ fs.IDer            = (*Object)(nil)
+	_ fs.RangeSeeker     = (*linearReader)(nil)
 )
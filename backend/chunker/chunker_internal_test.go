@@ -5,6 +5,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"path"
 	"regexp"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/fstests"
@@ -401,6 +403,8 @@ func testSmallFileInternals(t *testing.T, f *Fs) {
 			ht = hash.MD5
 		case f.useSHA1:
 			ht = hash.SHA1
+		case f.xhashType != hash.None:
+			ht = f.xhashType
 		default:
 			return
 		}
@@ -649,7 +653,7 @@ func testMetadataInput(t *testing.T, f *Fs) {
 		}
 	}
 
-	metaData, err := marshalSimpleJSON(ctx, 3, 1, "", "")
+	metaData, err := marshalSimpleJSON(ctx, 3, 1, "", "", hash.None, "")
 	require.NoError(t, err)
 	todaysMeta := string(metaData)
 	runSubtest(todaysMeta, "today")
@@ -663,6 +667,234 @@ func testMetadataInput(t *testing.T, f *Fs) {
 	runSubtest(futureMeta, "future")
 }
 
+// test that ranged reads and seeks only read the chunks needed
+func testRangedRead(t *testing.T, f *Fs) {
+	const dir = "ranged"
+	const chunkSize = 100
+	ctx := context.Background()
+	saveOpt := f.opt
+	defer func() {
+		_ = operations.Purge(ctx, f.base, dir)
+		f.opt = saveOpt
+	}()
+	f.opt.ChunkSize = chunkSize
+
+	contents := random.String(chunkSize*3 + chunkSize/2)
+	item := fstest.Item{Path: path.Join(dir, "file.txt"), ModTime: fstest.Time("2001-02-03T04:05:06.499999999Z")}
+	_, obj := fstests.PutTestContents(ctx, t, f, &item, contents, true)
+	o := obj.(*Object)
+	require.True(t, o.isComposite())
+	require.Len(t, o.chunks, 4)
+
+	read := func(options ...fs.OpenOption) string {
+		r, err := o.Open(ctx, options...)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		return string(data)
+	}
+	for _, test := range []struct{ start, end int }{
+		{0, 9},
+		{chunkSize - 5, chunkSize + 4},
+		{chunkSize, 2*chunkSize - 1},
+		{chunkSize / 2, 3*chunkSize + 10},
+		{3 * chunkSize, len(contents) - 1},
+		{len(contents) - 1, len(contents) - 1},
+	} {
+		got := read(&fs.RangeOption{Start: int64(test.start), End: int64(test.end)})
+		assert.Equal(t, contents[test.start:test.end+1], got, "range %d-%d", test.start, test.end)
+	}
+	assert.Equal(t, contents[chunkSize+1:], read(&fs.SeekOption{Offset: int64(chunkSize + 1)}))
+	assert.Equal(t, contents[len(contents)-6:], read(&fs.RangeOption{Start: -1, End: 6}))
+	assert.Equal(t, "", read(&fs.SeekOption{Offset: int64(len(contents))}))
+
+	// seek backwards and forwards across chunk boundaries
+	r, err := o.Open(ctx, &fs.RangeOption{Start: 0, End: 9})
+	require.NoError(t, err)
+	rs := r.(fs.RangeSeeker)
+	buf := make([]byte, 10)
+	for _, offset := range []int{2*chunkSize - 3, 5, 3*chunkSize + 1, chunkSize - 10} {
+		pos, err := rs.RangeSeek(ctx, int64(offset), io.SeekStart, int64(len(buf)))
+		require.NoError(t, err)
+		assert.Equal(t, int64(offset), pos)
+		_, err = io.ReadFull(r, buf)
+		require.NoError(t, err)
+		assert.Equal(t, contents[offset:offset+len(buf)], string(buf))
+		n, err := r.Read(buf)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, io.EOF, err)
+	}
+	_, err = r.(io.Seeker).Seek(-5, io.SeekEnd)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, contents[len(contents)-5:], string(data))
+	require.NoError(t, r.Close())
+}
+
+// test that verify finds missing and corrupted chunks
+func testVerify(t *testing.T, f *Fs) {
+	if !f.useMeta {
+		t.Skip("verify requires metadata")
+	}
+	const dir = "verify"
+	const chunkSize = 100
+	ctx := context.Background()
+	saveOpt := f.opt
+	defer func() {
+		_ = operations.Purge(ctx, f.base, dir)
+		f.opt = saveOpt
+	}()
+	f.opt.ChunkSize = chunkSize
+
+	modTime := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	contents := random.String(chunkSize*3 + chunkSize/2)
+	item := fstest.Item{Path: path.Join(dir, "file.txt"), ModTime: modTime}
+	_, obj := fstests.PutTestContents(ctx, t, f, &item, contents, true)
+	o := obj.(*Object)
+	require.Len(t, o.chunks, 4)
+	small := fstest.Item{Path: path.Join(dir, "sub", "small.txt"), ModTime: modTime}
+	_, _ = fstests.PutTestContents(ctx, t, f, &small, "small", true)
+
+	verify := func(quick bool, paths ...string) (string, error) {
+		opt := map[string]string{}
+		if quick {
+			opt["quick"] = ""
+		}
+		out, err := f.Command(ctx, "verify", paths, opt)
+		if err != nil {
+			return "", err
+		}
+		return out.(string), nil
+	}
+
+	out, err := verify(false)
+	require.NoError(t, err)
+	assert.Equal(t, "1 composite files verified", out)
+
+	// the hash is recorded with hash type "none" too but not returned
+	require.NoError(t, f.setHashType("none"))
+	noHash := fstest.Item{Path: path.Join(dir, "sub", "nohash.txt"), ModTime: modTime}
+	_, obj = fstests.PutTestContents(ctx, t, f, &noHash, contents, false)
+	require.NoError(t, f.setHashType(saveOpt.HashType))
+	require.NoError(t, obj.(*Object).readMetadata(ctx))
+	assert.True(t, obj.(*Object).md5 != "" || obj.(*Object).sha1 != "")
+	require.NoError(t, f.setHashType("none"))
+	sum, err := obj.Hash(ctx, hash.MD5)
+	require.NoError(t, f.setHashType(saveOpt.HashType))
+	require.NoError(t, err)
+	assert.Equal(t, "", sum)
+	out, err = verify(false, noHash.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "1 composite files verified", out)
+	require.NoError(t, obj.Remove(ctx))
+
+	out, err = verify(false, dir+"/sub")
+	require.NoError(t, err)
+	assert.Equal(t, "0 composite files verified", out)
+	out, err = verify(false, item.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "1 composite files verified", out)
+	_, err = verify(false, dir+"/missing.txt")
+	assert.Error(t, err)
+
+	// corrupt a chunk keeping its size
+	chunk := o.chunks[1]
+	corrupt := random.String(int(chunk.Size()))
+	src := object.NewStaticObjectInfo(chunk.Remote(), modTime, chunk.Size(), true, nil, nil)
+	require.NoError(t, chunk.Update(ctx, bytes.NewBufferString(corrupt), src))
+	_, err = verify(false, item.Path)
+	assert.Error(t, err)
+	out, err = verify(true, item.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "1 composite files verified", out)
+
+	// remove a chunk in the middle and the last chunk
+	for _, chunkNo := range []int{2, 3} {
+		require.NoError(t, o.chunks[chunkNo].Remove(ctx))
+		_, err = verify(true)
+		assert.Error(t, err)
+	}
+}
+
+func testXHash(t *testing.T, f *Fs) {
+	if !f.useMeta {
+		t.Skip("test requires metadata")
+	}
+	const dir = "xhash"
+	const chunkSize = 100
+	ctx := context.Background()
+	saveOpt := f.opt
+	defer func() {
+		_ = operations.Purge(ctx, f.base, dir)
+		f.opt = saveOpt
+		_ = f.setHashType(saveOpt.HashType)
+	}()
+	f.opt.ChunkSize = chunkSize
+
+	modTime := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	contents := random.String(chunkSize*2 + chunkSize/2)
+	want, err := hash.StreamTypes(bytes.NewBufferString(contents), hash.NewHashSet(hash.BLAKE3, hash.XXH3))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		hashType string
+		ht       hash.Type
+	}{
+		{"blake3", hash.BLAKE3},
+		{"xxh3all", hash.XXH3},
+	} {
+		require.NoError(t, f.setHashType(test.hashType))
+		assert.Equal(t, test.ht, f.xhashType)
+		item := fstest.Item{Path: path.Join(dir, test.hashType+".txt"), ModTime: modTime}
+		_, obj := fstests.PutTestContents(ctx, t, f, &item, contents, true)
+		require.Len(t, obj.(*Object).chunks, 3)
+
+		// the hash is read back from the metadata with its type
+		obj, err = f.NewObject(ctx, item.Path)
+		require.NoError(t, err)
+		o := obj.(*Object)
+		require.NoError(t, o.readMetadata(ctx))
+		assert.Equal(t, "", o.md5)
+		assert.Equal(t, "", o.sha1)
+		assert.Equal(t, test.ht, o.xhashType)
+		assert.Equal(t, want[test.ht], o.xhash)
+		sum, err := o.Hash(ctx, test.ht)
+		require.NoError(t, err)
+		assert.Equal(t, want[test.ht], sum)
+		_, err = o.Hash(ctx, hash.SHA256)
+		assert.Equal(t, hash.ErrUnsupported, err)
+
+		hashed, err := o.verify(ctx, false)
+		require.NoError(t, err)
+		assert.True(t, hashed)
+
+		// the hash survives a server side move
+		moved, err := f.Move(ctx, o, path.Join(dir, "moved", test.hashType+".txt"))
+		require.NoError(t, err)
+		sum, err = moved.Hash(ctx, test.ht)
+		require.NoError(t, err)
+		assert.Equal(t, want[test.ht], sum)
+	}
+
+	metaData, err := marshalSimpleJSON(ctx, 3, 1, "", "", hash.BLAKE3, want[hash.BLAKE3])
+	require.NoError(t, err)
+	assert.Contains(t, string(metaData), `"htype":"blake3"`)
+	info, err := unmarshalSimpleJSON(ctx, nil, metaData, true)
+	require.NoError(t, err)
+	assert.Equal(t, hash.BLAKE3, info.xhashType)
+	assert.Equal(t, want[hash.BLAKE3], info.xhash)
+
+	// a wrong hash is rejected and an unknown hash type is ignored
+	_, err = unmarshalSimpleJSON(ctx, nil, []byte(`{"ver":1,"size":3,"nchunks":1,"htype":"blake3","hash":"00"}`), true)
+	assert.Error(t, err)
+	info, err = unmarshalSimpleJSON(ctx, nil, []byte(`{"ver":1,"size":3,"nchunks":1,"htype":"future","hash":"00"}`), true)
+	require.NoError(t, err)
+	assert.Equal(t, hash.None, info.xhashType)
+	assert.Equal(t, "", info.xhash)
+}
+
 // InternalTest dispatches all internal tests
 func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("PutLarge", func(t *testing.T) {
@@ -686,6 +918,15 @@ func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("MetadataInput", func(t *testing.T) {
 		testMetadataInput(t, f)
 	})
+	t.Run("RangedRead", func(t *testing.T) {
+		testRangedRead(t, f)
+	})
+	t.Run("Verify", func(t *testing.T) {
+		testVerify(t, f)
+	})
+	t.Run("XHash", func(t *testing.T) {
+		testXHash(t, f)
+	})
 }
 
 var _ fstests.InternalTester = (*Fs)(nil)
@@ -39,10 +39,13 @@ func TestIntegration(t *testing.T) {
 		UnimplementableFsMethods: []string{
 			"PublicLink",
 			"OpenWriterAt",
+			"OpenChunkWriter",
 			"MergeDirs",
 			"DirCacheFlush",
 			"UserInfo",
 			"Disconnect",
+			"DeleteBatch",
+			"HardLink",
 		},
 	}
 	if *fstest.RemoteName == "" {
@@ -0,0 +1,246 @@
+// Verifying composite files against the hash in their metadata
+
+package chunker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "verify",
+	Short: "Verify composite files chunk by chunk",
+	Long: `This checks that every chunk of the composite files is present and
+of the expected size, then reads the chunks in order and compares the
+hash of the data with the whole-file hash recorded in the metadata.
+
+Usage Examples:
+
+    rclone backend verify chunker:
+    rclone backend verify chunker: path/to/dir path/to/file
+    rclone backend verify chunker: -o quick
+
+The paths are files or directories relative to the remote, the whole
+remote if none are given. Directories are checked recursively.
+
+Missing or corrupted chunks are logged as errors and the command fails
+if any are found. Files uploaded by older versions of rclone with
+hash type "none" have no hash recorded so only their chunks are
+checked.
+`,
+	Opts: map[string]string{
+		"quick": "only check the chunks are present and of the right size, don't read them",
+	},
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "verify":
+		_, quick := opt["quick"]
+		if len(arg) == 0 {
+			arg = []string{""}
+		}
+		return f.verify(ctx, arg, quick)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// verifyStats counts the composite files verified
+type verifyStats struct {
+	checked    int // composite files checked
+	failed     int // composite files with missing or corrupted chunks
+	unverified int // composite files without a hash in their metadata
+}
+
+// verify checks the composite files at the paths, which may be files
+// or directories
+func (f *Fs) verify(ctx context.Context, paths []string, quick bool) (string, error) {
+	if !f.useMeta {
+		return "", errors.New("can't verify composite files without metadata")
+	}
+	var stats verifyStats
+	for _, p := range paths {
+		isFile := false
+		if p != "" {
+			_, err := f.base.NewObject(ctx, p)
+			isFile = err == nil
+		}
+		var err error
+		if isFile {
+			// Check just the file with this main object
+			dir := path.Dir(p)
+			if dir == "." {
+				dir = ""
+			}
+			err = f.verifyDir(ctx, dir, 1, p, quick, &stats)
+		} else {
+			err = f.verifyDir(ctx, p, -1, "", quick, &stats)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to verify %q", p)
+		}
+	}
+	if stats.failed > 0 {
+		return "", errors.Errorf("%d of %d composite files have missing or corrupted chunks", stats.failed, stats.checked)
+	}
+	out := fmt.Sprintf("%d composite files verified", stats.checked)
+	if stats.unverified > 0 {
+		out += fmt.Sprintf(", %d without a hash only had their chunks checked", stats.unverified)
+	}
+	return out, nil
+}
+
+// verifyDir checks the composite files in dir and its subdirectories
+// down to maxLevel. If only is set just the composite file with that
+// remote is checked.
+//
+// The wrapped remote is listed directly rather than through chunker,
+// which skips the composite files with missing chunks.
+func (f *Fs) verifyDir(ctx context.Context, dir string, maxLevel int, only string, quick bool, stats *verifyStats) error {
+	return walk.Walk(ctx, f.base, dir, true, maxLevel, func(dirPath string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			return err
+		}
+		byRemote := make(map[string]*Object)
+		badChunks := make(map[string]bool)
+		var objects []*Object
+		for _, entry := range entries {
+			entry, ok := entry.(fs.Object)
+			if !ok {
+				continue
+			}
+			remote := entry.Remote()
+			mainRemote, chunkNo, ctrlType, xactID := f.parseChunkName(remote)
+			if ctrlType != "" || xactID != "" {
+				continue
+			}
+			if mainRemote != "" {
+				remote = mainRemote
+			}
+			if only != "" && remote != only {
+				continue
+			}
+			o := byRemote[remote]
+			if o == nil {
+				o = f.newObject(remote, nil, nil)
+				byRemote[remote] = o
+				objects = append(objects, o)
+			}
+			if mainRemote == "" {
+				o.main = entry
+				continue
+			}
+			if err := o.addChunk(entry, chunkNo); err != nil {
+				badChunks[remote] = true
+				fs.Errorf(remote, "Bad chunk %q: %v", entry.Remote(), err)
+			}
+		}
+		for _, o := range objects {
+			if !o.isComposite() && !badChunks[o.remote] {
+				continue // non-chunked file
+			}
+			stats.checked++
+			if o.main == nil || badChunks[o.remote] {
+				stats.failed++
+				if o.main == nil {
+					fs.Errorf(o.remote, "Chunks found without a metadata object")
+				}
+				continue
+			}
+			hashed, err := o.verify(ctx, quick)
+			if err != nil {
+				stats.failed++
+				fs.Errorf(o, "Verify failed: %v", err)
+				continue
+			}
+			if !hashed && !quick {
+				stats.unverified++
+				fs.Logf(o, "No hash in metadata - only the chunks were checked")
+				continue
+			}
+			fs.Debugf(o, "Verified OK")
+		}
+		return nil
+	})
+}
+
+// verify checks the chunks of the composite file are all present and
+// of the size recorded in its metadata then, unless quick is set,
+// reads them and compares the hash of the data with the one in its
+// metadata.
+//
+// It returns whether the hash was checked.
+func (o *Object) verify(ctx context.Context, quick bool) (hashed bool, err error) {
+	if err = o.validate(); err != nil {
+		return false, err
+	}
+	if err = o.readMetadata(ctx); err != nil {
+		return false, err
+	}
+	var hashType hash.Type
+	var want string
+	switch {
+	case o.md5 != "":
+		hashType, want = hash.MD5, o.md5
+	case o.sha1 != "":
+		hashType, want = hash.SHA1, o.sha1
+	case o.xhash != "":
+		hashType, want = o.xhashType, o.xhash
+	}
+	if quick || hashType == hash.None {
+		return false, nil
+	}
+	hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(hashType))
+	if err != nil {
+		return false, err
+	}
+	for chunkNo, chunk := range o.chunks {
+		err = verifyChunk(ctx, chunk, hasher)
+		if err != nil {
+			return false, errors.Wrapf(err, "chunk %d", chunkNo+o.f.opt.StartFrom)
+		}
+	}
+	if got := hasher.Sums()[hashType]; got != want {
+		return false, errors.Errorf("%v differ - recorded %q but chunks have %q", hashType, want, got)
+	}
+	return true, nil
+}
+
+// verifyChunk reads the chunk into hasher checking all of it is read
+func verifyChunk(ctx context.Context, chunk fs.Object, hasher io.Writer) (err error) {
+	in, err := chunk.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(in, &err)
+	n, err := io.Copy(hasher, in)
+	if err != nil {
+		return err
+	}
+	if n != chunk.Size() {
+		return errors.Errorf("read %d bytes but expected %d", n, chunk.Size())
+	}
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Commander = (*Fs)(nil)
+)
@@ -5,10 +5,13 @@ import (
 	"context"
 	"crypto/aes"
 	gocipher "crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,6 +37,8 @@ const (
 	blockDataSize       = 64 * 1024
 	blockSize           = blockHeaderSize + blockDataSize
 	encryptedSuffix     = ".bin" // when file name encryption is off we add this suffix to make sure the cloud provider doesn't process the file
+	extensionTagSize    = 8      // size of the HMAC authenticating the extension in extension mode
+	maxExtensionLength  = 16     // longest extension kept in clear in extension mode, not including the "."
 )
 
 // Errors returned by cipher
@@ -51,6 +56,7 @@ var (
 	ErrorFileClosed              = errors.New("file already closed")
 	ErrorNotAnEncryptedFile      = errors.New("not an encrypted file - no \"" + encryptedSuffix + "\" suffix")
 	ErrorBadSeek                 = errors.New("Seek beyond end of file")
+	ErrorBadExtension            = errors.New("bad decryption - file extension has been changed")
 	defaultSalt                  = []byte{0xA8, 0x0D, 0xF4, 0x3A, 0x8F, 0xBD, 0x03, 0x08, 0xA7, 0xCA, 0xB8, 0x3E, 0x58, 0x1F, 0x86, 0xB1}
 	obfuscQuoteRune              = '!'
 )
@@ -79,6 +85,7 @@ const (
 	NameEncryptionOff NameEncryptionMode = iota
 	NameEncryptionStandard
 	NameEncryptionObfuscated
+	NameEncryptionExtension
 )
 
 // NewNameEncryptionMode turns a string into a NameEncryptionMode
@@ -91,6 +98,8 @@ func NewNameEncryptionMode(s string) (mode NameEncryptionMode, err error) {
 		mode = NameEncryptionStandard
 	case "obfuscate":
 		mode = NameEncryptionObfuscated
+	case "extension":
+		mode = NameEncryptionExtension
 	default:
 		err = errors.Errorf("Unknown file name encryption mode %q", s)
 	}
@@ -106,6 +115,8 @@ func (mode NameEncryptionMode) String() (out string) {
 		out = "standard"
 	case NameEncryptionObfuscated:
 		out = "obfuscate"
+	case NameEncryptionExtension:
+		out = "extension"
 	default:
 		out = fmt.Sprintf("Unknown mode #%d", mode)
 	}
@@ -117,11 +128,21 @@ type Cipher struct {
 	dataKey        [32]byte                  // Key for secretbox
 	nameKey        [32]byte                  // 16,24 or 32 bytes
 	nameTweak      [nameCipherBlockSize]byte // used to tweak the name crypto
+	extensionKey   [32]byte                  // HMAC key authenticating extensions in extension mode
 	block          gocipher.Block
 	mode           NameEncryptionMode
 	buffers        sync.Pool // encrypt/decrypt buffers
 	cryptoRand     io.Reader // read crypto random numbers from here
 	dirNameEncrypt bool
+	dirKeys        []dirKey // directories encrypted with their own keys, longest first
+}
+
+// dirKey is a directory whose contents are encrypted with a different
+// key to the rest of the remote
+type dirKey struct {
+	dir       string  // directory in clear
+	encrypted string  // directory encrypted
+	cipher    *Cipher // cipher for the contents of the directory
 }
 
 // newCipher initialises the cipher.  If salt is "" then it uses a built in salt val
@@ -167,6 +188,11 @@ func (c *Cipher) Key(password, salt string) (err error) {
 	copy(c.dataKey[:], key)
 	copy(c.nameKey[:], key[len(c.dataKey):])
 	copy(c.nameTweak[:], key[len(c.dataKey)+len(c.nameKey):])
+	// Derive the extension key from the name key rather than
+	// from more scrypt output so the other keys stay the same
+	mac := hmac.New(sha256.New, c.nameKey[:])
+	_, _ = mac.Write([]byte("rclone crypt extension"))
+	copy(c.extensionKey[:], mac.Sum(nil))
 	// Key the name cipher
 	c.block, err = aes.NewCipher(c.nameKey[:])
 	return err
@@ -241,6 +267,11 @@ func (c *Cipher) decryptSegment(ciphertext string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return c.decryptRawSegment(rawCiphertext)
+}
+
+// decryptRawSegment decrypts a path segment after base32 decoding
+func (c *Cipher) decryptRawSegment(rawCiphertext []byte) (string, error) {
 	if len(rawCiphertext)%nameCipherBlockSize != 0 {
 		return "", ErrorNotAMultipleOfBlocksize
 	}
@@ -259,6 +290,81 @@ func (c *Cipher) decryptSegment(ciphertext string) (string, error) {
 	return string(plaintext), err
 }
 
+// splitExtension splits a file name into the base name and the
+// extension to be kept in clear in extension mode.
+//
+// The extension is the part from the last "." if that is made of up
+// to maxExtensionLength ASCII letters, digits, "-" and "_" and isn't
+// the whole name, otherwise it is "".
+func splitExtension(name string) (base, ext string) {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 || len(name)-i-1 > maxExtensionLength {
+		return name, ""
+	}
+	for _, r := range name[i+1:] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return name, ""
+		}
+	}
+	return name[:i], name[i:]
+}
+
+// extensionTag returns the HMAC authenticating the extension ext of
+// the file whose encrypted base name is ciphertext
+func (c *Cipher) extensionTag(ciphertext []byte, ext string) []byte {
+	mac := hmac.New(sha256.New, c.extensionKey[:])
+	_, _ = mac.Write(ciphertext)
+	_, _ = mac.Write([]byte(ext))
+	return mac.Sum(nil)[:extensionTagSize]
+}
+
+// encryptSegmentExtension encrypts a file name segment keeping its
+// extension in clear
+//
+// The base name is encrypted as in encryptSegment then an HMAC of the
+// encrypted base name and the extension is added before base32
+// encoding it so the extension can't be changed without detection.
+func (c *Cipher) encryptSegmentExtension(plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+	base, ext := splitExtension(plaintext)
+	paddedPlaintext := pkcs7.Pad(nameCipherBlockSize, []byte(base))
+	ciphertext := eme.Transform(c.block, c.nameTweak[:], paddedPlaintext, eme.DirectionEncrypt)
+	ciphertext = append(ciphertext, c.extensionTag(ciphertext, ext)...)
+	return encodeFileName(ciphertext) + ext
+}
+
+// decryptSegmentExtension decrypts a file name segment encrypted by
+// encryptSegmentExtension
+func (c *Cipher) decryptSegmentExtension(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	// The base32 encoding never contains a "."
+	encoded, ext := ciphertext, ""
+	if i := strings.LastIndexByte(ciphertext, '.'); i >= 0 {
+		encoded, ext = ciphertext[:i], ciphertext[i:]
+	}
+	rawCiphertext, err := decodeFileName(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(rawCiphertext) < extensionTagSize {
+		return "", ErrorTooShortAfterDecode
+	}
+	tagStart := len(rawCiphertext) - extensionTagSize
+	rawCiphertext, tag := rawCiphertext[:tagStart], rawCiphertext[tagStart:]
+	if !hmac.Equal(tag, c.extensionTag(rawCiphertext, ext)) {
+		return "", ErrorBadExtension
+	}
+	base, err := c.decryptRawSegment(rawCiphertext)
+	if err != nil {
+		return "", err
+	}
+	return base + ext, nil
+}
+
 // Simple obfuscation routines
 func (c *Cipher) obfuscateSegment(plaintext string) string {
 	if plaintext == "" {
@@ -434,7 +540,10 @@ func (c *Cipher) deobfuscateSegment(ciphertext string) (string, error) {
 }
 
 // encryptFileName encrypts a file path
-func (c *Cipher) encryptFileName(in string) string {
+//
+// If isFile is set then the last segment is the name of a file so
+// keeps its extension in extension mode.
+func (c *Cipher) encryptFileName(in string, isFile bool) string {
 	segments := strings.Split(in, "/")
 	for i := range segments {
 		// Skip directory name encryption if the user chose to
@@ -442,9 +551,12 @@ func (c *Cipher) encryptFileName(in string) string {
 		if !c.dirNameEncrypt && i != (len(segments)-1) {
 			continue
 		}
-		if c.mode == NameEncryptionStandard {
+		switch {
+		case c.mode == NameEncryptionExtension && isFile && i == len(segments)-1:
+			segments[i] = c.encryptSegmentExtension(segments[i])
+		case c.mode == NameEncryptionStandard || c.mode == NameEncryptionExtension:
 			segments[i] = c.encryptSegment(segments[i])
-		} else {
+		default:
 			segments[i] = c.obfuscateSegment(segments[i])
 		}
 	}
@@ -453,22 +565,31 @@ func (c *Cipher) encryptFileName(in string) string {
 
 // EncryptFileName encrypts a file path
 func (c *Cipher) EncryptFileName(in string) string {
+	if k := c.dirKeyFor(in); k != nil {
+		return k.encrypted + "/" + k.cipher.EncryptFileName(in[len(k.dir)+1:])
+	}
 	if c.mode == NameEncryptionOff {
 		return in + encryptedSuffix
 	}
-	return c.encryptFileName(in)
+	return c.encryptFileName(in, true)
 }
 
 // EncryptDirName encrypts a directory path
 func (c *Cipher) EncryptDirName(in string) string {
+	if k := c.dirKeyFor(in); k != nil {
+		return k.encrypted + "/" + k.cipher.EncryptDirName(in[len(k.dir)+1:])
+	}
 	if c.mode == NameEncryptionOff || !c.dirNameEncrypt {
 		return in
 	}
-	return c.encryptFileName(in)
+	return c.encryptFileName(in, false)
 }
 
 // decryptFileName decrypts a file path
-func (c *Cipher) decryptFileName(in string) (string, error) {
+//
+// If isFile is set then the last segment is the name of a file so
+// has its extension in clear in extension mode.
+func (c *Cipher) decryptFileName(in string, isFile bool) (string, error) {
 	segments := strings.Split(in, "/")
 	for i := range segments {
 		var err error
@@ -477,9 +598,12 @@ func (c *Cipher) decryptFileName(in string) (string, error) {
 		if !c.dirNameEncrypt && i != (len(segments)-1) {
 			continue
 		}
-		if c.mode == NameEncryptionStandard {
+		switch {
+		case c.mode == NameEncryptionExtension && isFile && i == len(segments)-1:
+			segments[i], err = c.decryptSegmentExtension(segments[i])
+		case c.mode == NameEncryptionStandard || c.mode == NameEncryptionExtension:
 			segments[i], err = c.decryptSegment(segments[i])
-		} else {
+		default:
 			segments[i], err = c.deobfuscateSegment(segments[i])
 		}
 
@@ -492,6 +616,13 @@ func (c *Cipher) decryptFileName(in string) (string, error) {
 
 // DecryptFileName decrypts a file path
 func (c *Cipher) DecryptFileName(in string) (string, error) {
+	if k := c.dirKeyForEncrypted(in); k != nil {
+		out, err := k.cipher.DecryptFileName(in[len(k.encrypted)+1:])
+		if err != nil {
+			return "", err
+		}
+		return k.dir + "/" + out, nil
+	}
 	if c.mode == NameEncryptionOff {
 		remainingLength := len(in) - len(encryptedSuffix)
 		if remainingLength > 0 && strings.HasSuffix(in, encryptedSuffix) {
@@ -499,15 +630,22 @@ func (c *Cipher) DecryptFileName(in string) (string, error) {
 		}
 		return "", ErrorNotAnEncryptedFile
 	}
-	return c.decryptFileName(in)
+	return c.decryptFileName(in, true)
 }
 
 // DecryptDirName decrypts a directory path
 func (c *Cipher) DecryptDirName(in string) (string, error) {
+	if k := c.dirKeyForEncrypted(in); k != nil {
+		out, err := k.cipher.DecryptDirName(in[len(k.encrypted)+1:])
+		if err != nil {
+			return "", err
+		}
+		return k.dir + "/" + out, nil
+	}
 	if c.mode == NameEncryptionOff || !c.dirNameEncrypt {
 		return in, nil
 	}
-	return c.decryptFileName(in)
+	return c.decryptFileName(in, false)
 }
 
 // NameEncryptionMode returns the encryption mode in use for names
@@ -515,6 +653,107 @@ func (c *Cipher) NameEncryptionMode() NameEncryptionMode {
 	return c.mode
 }
 
+// setDirKeys sets the directories whose contents are encrypted with
+// their own ciphers.
+//
+// The directory names themselves are encrypted with the cipher of the
+// directory they are in so they can be listed.
+func (c *Cipher) setDirKeys(dirCiphers map[string]*Cipher) {
+	c.dirKeys = c.dirKeys[:0]
+	for dir, dirCipher := range dirCiphers {
+		c.dirKeys = append(c.dirKeys, dirKey{dir: dir, cipher: dirCipher})
+	}
+	sort.Slice(c.dirKeys, func(i, j int) bool {
+		return len(c.dirKeys[i].dir) > len(c.dirKeys[j].dir)
+	})
+	// Encrypt the shortest first so the enclosing directories are
+	// ready to be used by EncryptDirName
+	for i := len(c.dirKeys) - 1; i >= 0; i-- {
+		c.dirKeys[i].encrypted = c.EncryptDirName(c.dirKeys[i].dir)
+	}
+}
+
+// dirKeyFor returns the innermost directory with its own key which
+// contains the path in, or nil if there isn't one
+func (c *Cipher) dirKeyFor(in string) *dirKey {
+	for i := range c.dirKeys {
+		if strings.HasPrefix(in, c.dirKeys[i].dir+"/") {
+			return &c.dirKeys[i]
+		}
+	}
+	return nil
+}
+
+// dirKeyForEncrypted returns the innermost directory with its own key
+// which contains the encrypted path in, or nil if there isn't one
+func (c *Cipher) dirKeyForEncrypted(in string) *dirKey {
+	for i := range c.dirKeys {
+		if strings.HasPrefix(in, c.dirKeys[i].encrypted+"/") {
+			return &c.dirKeys[i]
+		}
+	}
+	return nil
+}
+
+// forFile returns the cipher for the data of the file at remote
+func (c *Cipher) forFile(remote string) *Cipher {
+	if k := c.dirKeyFor(remote); k != nil {
+		return k.cipher
+	}
+	return c
+}
+
+// forDir returns the cipher for the contents of the directory dir
+func (c *Cipher) forDir(dir string) *Cipher {
+	return c.forFile(dir + "/")
+}
+
+// hasDirKeysIn returns true if any directory inside dir has its own key
+func (c *Cipher) hasDirKeysIn(dir string) bool {
+	for _, k := range c.dirKeys {
+		if dir == "" || strings.HasPrefix(k.dir, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDataKey returns true if data encrypted by c can be decrypted by
+// other
+func (c *Cipher) sameDataKey(other *Cipher) bool {
+	return c.dataKey == other.dataKey
+}
+
+// withRoot returns the cipher to use for paths relative to root, a
+// directory relative to the root of c.
+func (c *Cipher) withRoot(root string) *Cipher {
+	if root == "" || len(c.dirKeys) == 0 {
+		return c
+	}
+	base := c.forDir(root)
+	out := &Cipher{
+		dataKey:        base.dataKey,
+		nameKey:        base.nameKey,
+		nameTweak:      base.nameTweak,
+		extensionKey:   base.extensionKey,
+		block:          base.block,
+		mode:           base.mode,
+		cryptoRand:     base.cryptoRand,
+		dirNameEncrypt: base.dirNameEncrypt,
+	}
+	out.buffers.New = func() interface{} {
+		return make([]byte, blockSize)
+	}
+	dirCiphers := map[string]*Cipher{}
+	for _, k := range c.dirKeys {
+		if strings.HasPrefix(k.dir, root+"/") {
+			dirCiphers[k.dir[len(root)+1:]] = k.cipher
+		}
+	}
+	out.setDirKeys(dirCiphers)
+	return out
+}
+
 // nonce is an NACL secretbox nonce
 type nonce [fileNonceSize]byte
 
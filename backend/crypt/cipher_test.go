@@ -26,6 +26,7 @@ func TestNewNameEncryptionMode(t *testing.T) {
 		{"off", NameEncryptionOff, ""},
 		{"standard", NameEncryptionStandard, ""},
 		{"obfuscate", NameEncryptionObfuscated, ""},
+		{"extension", NameEncryptionExtension, ""},
 		{"potato", NameEncryptionOff, "Unknown file name encryption mode \"potato\""},
 	} {
 		actual, actualErr := NewNameEncryptionMode(test.in)
@@ -42,7 +43,8 @@ func TestNewNameEncryptionModeString(t *testing.T) {
 	assert.Equal(t, NameEncryptionOff.String(), "off")
 	assert.Equal(t, NameEncryptionStandard.String(), "standard")
 	assert.Equal(t, NameEncryptionObfuscated.String(), "obfuscate")
-	assert.Equal(t, NameEncryptionMode(3).String(), "Unknown mode #3")
+	assert.Equal(t, NameEncryptionExtension.String(), "extension")
+	assert.Equal(t, NameEncryptionMode(4).String(), "Unknown mode #4")
 }
 
 func TestEncodeFileName(t *testing.T) {
@@ -202,6 +204,14 @@ func TestDecryptFileName(t *testing.T) {
 		{NameEncryptionObfuscated, true, "161.\u00e4", "\u00a1", nil},
 		{NameEncryptionObfuscated, true, "160.\u03c2", "\u03a0", nil},
 		{NameEncryptionObfuscated, false, "1/12/123/53.!!lipps", "1/12/123/!hello", nil},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72tkrocjhoof3ha0", "1", nil},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771hnv3an9cgc8cr2n1ni90l65qmd4cg08.jpg", "1/12.jpg", nil},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771hnv3an9cgc8cr2n1ni90l65qmd4cg08.exe", "", ErrorBadExtension},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771hnv3an9cgc8cr2n1ni90l65qmd4cg08", "", ErrorBadExtension},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771hnv3an9cgc8cr2n1ng.jpg", "", ErrorBadExtension},
+		{NameEncryptionExtension, true, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771.jpg", "", ErrorTooShortAfterDecode},
+		{NameEncryptionExtension, true, "9h9r8teics69dh31ku9pffjptr9vgvp5vqlod7g.JPG", "photo.JPG", nil},
+		{NameEncryptionExtension, false, "1/12/l70m3qoe1loo3iovgmcmq9u168p4psdukstil98.gz", "1/12/123.tar.gz", nil},
 	} {
 		c, _ := newCipher(test.mode, "", "", test.dirNameEncrypt)
 		actual, actualErr := c.DecryptFileName(test.in)
@@ -220,6 +230,11 @@ func TestEncDecMatches(t *testing.T) {
 		{NameEncryptionOff, "1/2/3/4"},
 		{NameEncryptionObfuscated, "1/2/3/4/!hello\u03a0"},
 		{NameEncryptionObfuscated, "Avatar The Last Airbender"},
+		{NameEncryptionExtension, "1/2/3/4.mkv"},
+		{NameEncryptionExtension, "1/2/.bashrc"},
+		{NameEncryptionExtension, "1/2/file."},
+		{NameEncryptionExtension, "1/2/a.b c"},
+		{NameEncryptionExtension, "1/2/archive.tar.gz"},
 	} {
 		c, _ := newCipher(test.mode, "", "", true)
 		out, err := c.DecryptFileName(c.EncryptFileName(test.in))
@@ -242,6 +257,32 @@ func TestEncryptDirName(t *testing.T) {
 	// Now off mode
 	c, _ = newCipher(NameEncryptionOff, "", "", true)
 	assert.Equal(t, "1/12/123", c.EncryptDirName("1/12/123"))
+	// Extension mode encrypts directories like standard mode
+	c, _ = newCipher(NameEncryptionExtension, "", "", true)
+	assert.Equal(t, "p0e52nreeaj0a5ea7s64m4j72s/l42g6771hnv3an9cgc8cr2n1ng", c.EncryptDirName("1/12"))
+}
+
+func TestSplitExtension(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		base string
+		ext  string
+	}{
+		{"file.jpg", "file", ".jpg"},
+		{"archive.tar.gz", "archive.tar", ".gz"},
+		{"Photo.JPEG", "Photo", ".JPEG"},
+		{"file", "file", ""},
+		{".bashrc", ".bashrc", ""},
+		{"file.", "file.", ""},
+		{"file.two words", "file.two words", ""},
+		{"file.0123456789abcdef", "file", ".0123456789abcdef"},
+		{"file.0123456789abcdefg", "file.0123456789abcdefg", ""},
+		{"file.été", "file.été", ""},
+	} {
+		base, ext := splitExtension(test.in)
+		assert.Equal(t, test.base, base, test.in)
+		assert.Equal(t, test.ext, ext, test.in)
+	}
 }
 
 func TestDecryptDirName(t *testing.T) {
@@ -271,6 +312,60 @@ func TestDecryptDirName(t *testing.T) {
 	}
 }
 
+func TestDirKeys(t *testing.T) {
+	c, _ := newCipher(NameEncryptionStandard, "", "", true)
+	ca, _ := newCipher(NameEncryptionStandard, "a", "", true)
+	cb, _ := newCipher(NameEncryptionStandard, "b", "", true)
+	plain, _ := newCipher(NameEncryptionStandard, "", "", true)
+	c.setDirKeys(map[string]*Cipher{"a": ca, "a/b": cb})
+
+	// Directory names are encrypted with the key of their parent
+	assert.Equal(t, plain.EncryptDirName("a"), c.EncryptDirName("a"))
+	assert.Equal(t, plain.EncryptFileName("x/y"), c.EncryptFileName("x/y"))
+	assert.Equal(t, plain.EncryptDirName("a")+"/"+ca.EncryptFileName("x"), c.EncryptFileName("a/x"))
+	assert.Equal(t, plain.EncryptDirName("a")+"/"+ca.EncryptDirName("b")+"/"+cb.EncryptFileName("c"), c.EncryptFileName("a/b/c"))
+	assert.Equal(t, plain.EncryptDirName("a")+"/"+ca.EncryptDirName("b")+"/"+cb.EncryptDirName("c"), c.EncryptDirName("a/b/c"))
+
+	for _, in := range []string{"x", "a", "a/x", "a/b", "a/b/c", "a/b/c/d", "ab/c"} {
+		out, err := c.DecryptFileName(c.EncryptFileName(in))
+		require.NoError(t, err)
+		assert.Equal(t, in, out)
+		out, err = c.DecryptDirName(c.EncryptDirName(in))
+		require.NoError(t, err)
+		assert.Equal(t, in, out)
+	}
+
+	// Names encrypted with the wrong key don't decrypt
+	_, err := c.DecryptFileName(plain.EncryptFileName("a/x"))
+	assert.Error(t, err)
+
+	assert.True(t, c.forFile("x").sameDataKey(plain))
+	assert.True(t, c.forFile("a").sameDataKey(plain))
+	assert.True(t, c.forFile("a/x").sameDataKey(ca))
+	assert.True(t, c.forFile("a/b/c/d").sameDataKey(cb))
+	assert.True(t, c.forDir("a").sameDataKey(ca))
+	assert.False(t, c.forDir("ab").sameDataKey(ca))
+
+	assert.True(t, c.hasDirKeysIn(""))
+	assert.True(t, c.hasDirKeysIn("a"))
+	assert.False(t, c.hasDirKeysIn("a/b"))
+	assert.False(t, c.hasDirKeysIn("x"))
+
+	// Rooted inside a directory with its own key
+	r := c.withRoot("a")
+	assert.True(t, r.forFile("x").sameDataKey(ca))
+	assert.True(t, r.forFile("b/x").sameDataKey(cb))
+	assert.Equal(t, ca.EncryptDirName("b")+"/"+cb.EncryptFileName("c"), r.EncryptFileName("b/c"))
+	out, err := r.DecryptFileName(r.EncryptFileName("b/c"))
+	require.NoError(t, err)
+	assert.Equal(t, "b/c", out)
+
+	// Rooted outside the directories with their own keys
+	r = c.withRoot("x")
+	assert.True(t, r.forFile("a/x").sameDataKey(plain))
+	assert.False(t, r.hasDirKeysIn(""))
+}
+
 func TestEncryptedSize(t *testing.T) {
 	c, _ := newCipher(NameEncryptionStandard, "", "", true)
 	for _, test := range []struct {
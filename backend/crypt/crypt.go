@@ -43,6 +43,9 @@ func init() {
 				}, {
 					Value: "obfuscate",
 					Help:  "Very simple filename obfuscation.",
+				}, {
+					Value: "extension",
+					Help:  "Encrypt the filenames but leave the file extension in clear.",
 				}, {
 					Value: "off",
 					Help:  "Don't encrypt the file names.  Adds a \".bin\" extension only.",
@@ -66,13 +69,112 @@ NB If filename_encryption is "off" then this option will do nothing.`,
 			},
 		}, {
 			Name:       "password",
-			Help:       "Password or pass phrase for encryption.",
+			Help:       "Password or pass phrase for encryption.\n\nLeave blank if it is fetched from a key provider.",
 			IsPassword: true,
-			Required:   true,
 		}, {
 			Name:       "password2",
 			Help:       "Password or pass phrase for salt. Optional but recommended.\nShould be different to the previous password.",
 			IsPassword: true,
+		}, {
+			Name: "directory_passwords",
+			Help: `Directories encrypted with their own passwords.
+
+This is a comma separated list of "dir=password" entries. The contents
+of each directory are encrypted with its password instead of the main
+one, so different teams can share a remote while only being able to
+read their own directories. The password is obscured as made by
+"rclone obscure" and password2 is used as the salt for all of them.
+
+The dir is relative to the root of the crypt remote, eg
+"finance=XXXX,teams/hr=YYYY". The name of each directory is
+encrypted with the password of the directory it is in so it can be
+listed.
+
+Files can't be moved or copied server side between directories with
+different passwords - they are downloaded and uploaded instead.`,
+			Default:  fs.CommaSepList{},
+			Advanced: true,
+		}, {
+			Name: "key_provider",
+			Help: `Where to fetch the password from instead of the config file.
+
+If this is set then the password is fetched when the remote is used
+and cached in memory for a few minutes. password2 is only fetched if
+key_password2 is set too.`,
+			Default:  "",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "Use the password in the config file.",
+			}, {
+				Value: keyProviderCommand,
+				Help:  "Run key_command to print the password.",
+			}, {
+				Value: keyProviderKeychain,
+				Help:  "Read the password from the OS keychain (macOS Keychain or the Secret Service on Linux).",
+			}, {
+				Value: keyProviderVault,
+				Help:  "Read the password from a Hashicorp Vault KV secret.",
+			}, {
+				Value: keyProviderAWSKMS,
+				Help:  "Decrypt kms_password with AWS KMS.",
+			}},
+		}, {
+			Name:     "key_password2",
+			Help:     "Fetch password2 from the key provider as well as the password.",
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "key_name",
+			Help: `Name of the secret in the key provider.
+
+For "keychain" this is the account name under the service "rclone",
+with ":password" or ":password2" added, eg "myremote" reads the
+account "myremote:password".
+
+For "vault" this is the path of the secret, eg
+"secret/data/rclone/myremote", which has "password" and optionally
+"password2" fields.`,
+			Advanced: true,
+		}, {
+			Name: "key_command",
+			Help: `Command to print the password for the "command" key provider.
+
+The command is run with "password" or "password2" added as the last
+argument and should print the secret on standard output.`,
+			Default:  fs.SpaceSepList{},
+			Advanced: true,
+		}, {
+			Name: "vault_address",
+			Help: `Address of the Hashicorp Vault server, eg "https://vault.example.com:8200".
+
+Leave blank to use the VAULT_ADDR environment variable. The token is
+read from the VAULT_TOKEN environment variable or the ~/.vault-token
+file written by "vault login".`,
+			Advanced: true,
+		}, {
+			Name: "kms_password",
+			Help: `The password encrypted with AWS KMS, base64 encoded.
+
+This can be made with
+
+    aws kms encrypt --key-id alias/mykey --plaintext fileb://<(printf %s PASSWORD) --query CiphertextBlob --output text
+
+The AWS credentials are read from the environment, the shared
+credentials file or the instance role.`,
+			Advanced: true,
+		}, {
+			Name:     "kms_password2",
+			Help:     "The password2 encrypted with AWS KMS, base64 encoded.",
+			Advanced: true,
+		}, {
+			Name:     "kms_region",
+			Help:     "Region of the AWS KMS key.\n\nLeave blank to use the region from the environment or the shared config.",
+			Advanced: true,
+		}, {
+			Name:     "kms_endpoint",
+			Help:     "Endpoint for AWS KMS.\n\nLeave blank to use the default endpoint for the region.",
+			Advanced: true,
 		}, {
 			Name:    "server_side_across_configs",
 			Default: false,
@@ -87,6 +189,21 @@ pointing to two different directories with the single changed
 parameter and use rclone move to move the files between the crypt
 remotes.`,
 			Advanced: true,
+		}, {
+			Name: "store_hashes",
+			Help: `Store the hashes of the unencrypted files in their metadata.
+
+If this is set then the MD5 and SHA-1 hashes of each file are
+calculated as it is uploaded and stored, encrypted, in the metadata
+of the underlying object along with the hash of the encrypted data.
+
+This lets rclone check and sync with hashes and "rclone cryptcheck"
+verify files without downloading and encrypting them again.
+
+This only works if the underlying remote can store metadata, eg s3,
+azureblob or local with extended attributes.`,
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name: "show_mapping",
 			Help: `For all files listed show how the names encrypt.
@@ -105,30 +222,76 @@ names, or for debugging purposes.`,
 	})
 }
 
+// getPasswords returns the password and salt from the config or the
+// key provider if one is set
+func getPasswords(opt *Options) (password, salt string, err error) {
+	ctx := context.Background()
+	if opt.KeyProvider != "" {
+		password, err = fetchKey(ctx, opt, "password")
+	} else if opt.Password == "" {
+		return "", "", errors.New("password not set in config file")
+	} else {
+		password, err = obscure.Reveal(opt.Password)
+		if err != nil {
+			err = errors.Wrap(err, "failed to decrypt password")
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if opt.KeyProvider != "" && opt.KeyPassword2 {
+		salt, err = fetchKey(ctx, opt, "password2")
+	} else if opt.Password2 != "" {
+		salt, err = obscure.Reveal(opt.Password2)
+		if err != nil {
+			err = errors.Wrap(err, "failed to decrypt password2")
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return password, salt, nil
+}
+
 // newCipherForConfig constructs a Cipher for the given config name
 func newCipherForConfig(opt *Options) (*Cipher, error) {
 	mode, err := NewNameEncryptionMode(opt.FilenameEncryption)
 	if err != nil {
 		return nil, err
 	}
-	if opt.Password == "" {
-		return nil, errors.New("password not set in config file")
-	}
-	password, err := obscure.Reveal(opt.Password)
+	password, salt, err := getPasswords(opt)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decrypt password")
-	}
-	var salt string
-	if opt.Password2 != "" {
-		salt, err = obscure.Reveal(opt.Password2)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to decrypt password2")
-		}
+		return nil, err
 	}
 	cipher, err := newCipher(mode, password, salt, opt.DirectoryNameEncryption)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make cipher")
 	}
+	if len(opt.DirectoryPasswords) > 0 {
+		dirCiphers := map[string]*Cipher{}
+		for _, item := range opt.DirectoryPasswords {
+			i := strings.IndexRune(item, '=')
+			if i < 0 {
+				return nil, errors.Errorf("bad directory password %q - should be dir=password", item)
+			}
+			dir := strings.Trim(path.Clean(item[:i]), "/")
+			if dir == "" || dir == "." {
+				return nil, errors.Errorf("bad directory password %q - dir must not be the root", item)
+			}
+			if _, found := dirCiphers[dir]; found {
+				return nil, errors.Errorf("directory %q has more than one password", dir)
+			}
+			dirPassword, err := obscure.Reveal(item[i+1:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decrypt password for directory %q", dir)
+			}
+			dirCiphers[dir], err = newCipher(mode, dirPassword, salt, opt.DirectoryNameEncryption)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to make cipher for directory %q", dir)
+			}
+		}
+		cipher.setDirKeys(dirCiphers)
+	}
 	return cipher, nil
 }
 
@@ -179,12 +342,20 @@ func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
 	if err != fs.ErrorIsFile && err != nil {
 		return nil, errors.Wrapf(err, "failed to make remote %q to wrap", remote)
 	}
+	// Names are relative to the directory the wrapped remote points to
+	cipherRoot := rpath
+	if err == fs.ErrorIsFile {
+		cipherRoot = path.Dir(rpath)
+		if cipherRoot == "." {
+			cipherRoot = ""
+		}
+	}
 	f := &Fs{
 		Fs:     wrappedFs,
 		name:   name,
 		root:   rpath,
 		opt:    *opt,
-		cipher: cipher,
+		cipher: cipher.withRoot(cipherRoot),
 	}
 	cache.PinUntilFinalized(f.Fs, f)
 	// the features here are ones we could support, and they are
@@ -206,13 +377,24 @@ func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
 
 // Options defines the configuration for this backend
 type Options struct {
-	Remote                  string `config:"remote"`
-	FilenameEncryption      string `config:"filename_encryption"`
-	DirectoryNameEncryption bool   `config:"directory_name_encryption"`
-	Password                string `config:"password"`
-	Password2               string `config:"password2"`
-	ServerSideAcrossConfigs bool   `config:"server_side_across_configs"`
-	ShowMapping             bool   `config:"show_mapping"`
+	Remote                  string          `config:"remote"`
+	FilenameEncryption      string          `config:"filename_encryption"`
+	DirectoryNameEncryption bool            `config:"directory_name_encryption"`
+	Password                string          `config:"password"`
+	Password2               string          `config:"password2"`
+	DirectoryPasswords      fs.CommaSepList `config:"directory_passwords"`
+	KeyProvider             string          `config:"key_provider"`
+	KeyPassword2            bool            `config:"key_password2"`
+	KeyName                 string          `config:"key_name"`
+	KeyCommand              fs.SpaceSepList `config:"key_command"`
+	VaultAddress            string          `config:"vault_address"`
+	KMSPassword             string          `config:"kms_password"`
+	KMSPassword2            string          `config:"kms_password2"`
+	KMSRegion               string          `config:"kms_region"`
+	KMSEndpoint             string          `config:"kms_endpoint"`
+	ServerSideAcrossConfigs bool            `config:"server_side_across_configs"`
+	StoreHashes             bool            `config:"store_hashes"`
+	ShowMapping             bool            `config:"show_mapping"`
 }
 
 // Fs represents a wrapped fs.Fs
@@ -346,8 +528,21 @@ type putFn func(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ..
 
 // put implements Put or PutStream
 func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options []fs.OpenOption, put putFn) (fs.Object, error) {
+	// Hash the unencrypted data if storing the hashes
+	var clearHasher *hash.MultiHasher
+	if f.opt.StoreHashes {
+		var err error
+		clearHasher, err = hash.NewMultiHasherTypes(storedHashTypes)
+		if err != nil {
+			return nil, err
+		}
+		var wrap accounting.WrapFn
+		in, wrap = accounting.UnWrap(in)
+		in = wrap(io.TeeReader(in, clearHasher))
+	}
+
 	// Encrypt the data into wrappedIn
-	wrappedIn, encrypter, err := f.cipher.encryptData(in)
+	wrappedIn, encrypter, err := f.cipher.forFile(src.Remote()).encryptData(in)
 	if err != nil {
 		return nil, err
 	}
@@ -377,8 +572,9 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options [
 	}
 
 	// Check the hashes of the encrypted data if we were comparing them
+	var srcHash string
 	if ht != hash.None && hasher != nil {
-		srcHash := hasher.Sums()[ht]
+		srcHash = hasher.Sums()[ht]
 		var dstHash string
 		dstHash, err = o.Hash(ctx, ht)
 		if err != nil {
@@ -394,6 +590,13 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options [
 		}
 	}
 
+	if clearHasher != nil {
+		err = f.storeHashes(ctx, o, src.Remote(), clearHasher.Sums(), ht, srcHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return f.newObject(o), nil
 }
 
@@ -413,6 +616,9 @@ func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, opt
 
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
+	if f.opt.StoreHashes {
+		return storedHashTypes
+	}
 	return hash.Set(hash.None)
 }
 
@@ -462,6 +668,10 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	if !ok {
 		return nil, fs.ErrorCantCopy
 	}
+	if !o.f.cipher.forFile(o.Remote()).sameDataKey(f.cipher.forFile(remote)) {
+		fs.Debugf(src, "Can't copy - encrypted with a different key")
+		return nil, fs.ErrorCantCopy
+	}
 	oResult, err := do(ctx, o.Object, f.cipher.EncryptFileName(remote))
 	if err != nil {
 		return nil, err
@@ -487,6 +697,10 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	if !ok {
 		return nil, fs.ErrorCantMove
 	}
+	if !o.f.cipher.forFile(o.Remote()).sameDataKey(f.cipher.forFile(remote)) {
+		fs.Debugf(src, "Can't move - encrypted with a different key")
+		return nil, fs.ErrorCantMove
+	}
 	oResult, err := do(ctx, o.Object, f.cipher.EncryptFileName(remote))
 	if err != nil {
 		return nil, err
@@ -512,7 +726,11 @@ func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string
 		fs.Debugf(srcFs, "Can't move directory - not same remote type")
 		return fs.ErrorCantDirMove
 	}
-	return do(ctx, srcFs.Fs, f.cipher.EncryptDirName(srcRemote), f.cipher.EncryptDirName(dstRemote))
+	if !srcFs.cipher.forDir(srcRemote).sameDataKey(f.cipher.forDir(dstRemote)) || srcFs.cipher.hasDirKeysIn(srcRemote) || f.cipher.hasDirKeysIn(dstRemote) {
+		fs.Debugf(srcFs, "Can't move directory - contents encrypted with different keys")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcFs.Fs, srcFs.cipher.EncryptDirName(srcRemote), f.cipher.EncryptDirName(dstRemote))
 }
 
 // PutUnchecked uploads the object
@@ -524,7 +742,7 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 	if do == nil {
 		return nil, errors.New("can't PutUnchecked")
 	}
-	wrappedIn, encrypter, err := f.cipher.encryptData(in)
+	wrappedIn, encrypter, err := f.cipher.forFile(src.Remote()).encryptData(in)
 	if err != nil {
 		return nil, err
 	}
@@ -582,10 +800,10 @@ func (f *Fs) DecryptFileName(encryptedFileName string) (string, error) {
 }
 
 // computeHashWithNonce takes the nonce and encrypts the contents of
-// src with it, and calculates the hash given by HashType on the fly
+// src with it using c, and calculates the hash given by HashType on the fly
 //
 // Note that we break lots of encapsulation in this function.
-func (f *Fs) computeHashWithNonce(ctx context.Context, nonce nonce, src fs.Object, hashType hash.Type) (hashStr string, err error) {
+func (f *Fs) computeHashWithNonce(ctx context.Context, c *Cipher, nonce nonce, src fs.Object, hashType hash.Type) (hashStr string, err error) {
 	// Open the src for input
 	in, err := src.Open(ctx)
 	if err != nil {
@@ -594,7 +812,7 @@ func (f *Fs) computeHashWithNonce(ctx context.Context, nonce nonce, src fs.Objec
 	defer fs.CheckClose(in, &err)
 
 	// Now encrypt the src with the nonce
-	out, err := f.cipher.newEncrypter(in, &nonce)
+	out, err := c.newEncrypter(in, &nonce)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to make encrypter")
 	}
@@ -623,7 +841,8 @@ func (f *Fs) ComputeHash(ctx context.Context, o *Object, src fs.Object, hashType
 	if err != nil {
 		return "", errors.Wrap(err, "failed to open object to read nonce")
 	}
-	d, err := f.cipher.newDecrypter(in)
+	c := f.cipher.forFile(o.Remote())
+	d, err := c.newDecrypter(in)
 	if err != nil {
 		_ = in.Close()
 		return "", errors.Wrap(err, "failed to open object to read nonce")
@@ -648,7 +867,7 @@ func (f *Fs) ComputeHash(ctx context.Context, o *Object, src fs.Object, hashType
 		return "", errors.Wrap(err, "failed to close nonce read")
 	}
 
-	return f.computeHashWithNonce(ctx, nonce, src, hashType)
+	return f.computeHashWithNonce(ctx, c, nonce, src, hashType)
 }
 
 // MergeDirs merges the contents of all the directories passed
@@ -832,7 +1051,14 @@ func (o *Object) Size() int64 {
 // Hash returns the selected checksum of the file
 // If no checksum is available it returns ""
 func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
-	return "", hash.ErrUnsupported
+	if !o.f.opt.StoreHashes || !storedHashTypes.Contains(ht) {
+		return "", hash.ErrUnsupported
+	}
+	hashes, err := o.readHashes(ctx)
+	if err != nil || hashes == nil {
+		return "", err
+	}
+	return hashes.Hashes[ht.String()], nil
 }
 
 // UnWrap returns the wrapped Object
@@ -855,7 +1081,7 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 			openOptions = append(openOptions, option)
 		}
 	}
-	rc, err = o.f.cipher.DecryptDataSeek(ctx, func(ctx context.Context, underlyingOffset, underlyingLimit int64) (io.ReadCloser, error) {
+	rc, err = o.f.cipher.forFile(o.Remote()).DecryptDataSeek(ctx, func(ctx context.Context, underlyingOffset, underlyingLimit int64) (io.ReadCloser, error) {
 		if underlyingOffset == 0 && underlyingLimit < 0 {
 			// Open with no seek
 			return o.Object.Open(ctx, openOptions...)
@@ -917,6 +1143,48 @@ func (f *Fs) Disconnect(ctx context.Context) error {
 	return do(ctx)
 }
 
+// DeleteBatch deletes the objects passed in with as few calls as
+// possible, returning an error for each object in order
+func (f *Fs) DeleteBatch(ctx context.Context, objs []fs.Object) []error {
+	do := f.Fs.Features().DeleteBatch
+	if do == nil {
+		errs := make([]error, len(objs))
+		for i := range errs {
+			errs[i] = fs.ErrorNotImplemented
+		}
+		return errs
+	}
+	wrappedObjs := make([]fs.Object, len(objs))
+	for i, obj := range objs {
+		if o, ok := obj.(*Object); ok {
+			wrappedObjs[i] = o.Object
+		} else {
+			wrappedObjs[i] = obj
+		}
+	}
+	return do(ctx, wrappedObjs)
+}
+
+// HardLink makes remote a hard link to src, replacing any existing
+// object at remote
+//
+// If it isn't possible then return fs.ErrorCantHardLink
+func (f *Fs) HardLink(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().HardLink
+	if do == nil {
+		return nil, fs.ErrorCantHardLink
+	}
+	o, ok := src.(*Object)
+	if !ok || !o.f.cipher.forFile(o.Remote()).sameDataKey(f.cipher.forFile(remote)) {
+		return nil, fs.ErrorCantHardLink
+	}
+	oResult, err := do(ctx, o.Object, f.cipher.EncryptFileName(remote))
+	if err != nil {
+		return nil, err
+	}
+	return f.newObject(oResult), nil
+}
+
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
 //
 // This encrypts the remote name and adjusts the size
@@ -971,7 +1239,7 @@ func (o *ObjectInfo) Hash(ctx context.Context, hash hash.Type) (string, error) {
 	if srcObj.Fs().Features().IsLocal {
 		// Read the data and encrypt it to calculate the hash
 		fs.Debugf(o, "Computing %v hash of encrypted source", hash)
-		return o.f.computeHashWithNonce(ctx, o.nonce, srcObj, hash)
+		return o.f.computeHashWithNonce(ctx, o.f.cipher.forFile(o.ObjectInfo.Remote()), o.nonce, srcObj, hash)
 	}
 	return "", nil
 }
@@ -1025,6 +1293,8 @@ var (
 	_ fs.PublicLinker    = (*Fs)(nil)
 	_ fs.UserInfoer      = (*Fs)(nil)
 	_ fs.Disconnecter    = (*Fs)(nil)
+	_ fs.BatchDeleter    = (*Fs)(nil)
+	_ fs.HardLinker      = (*Fs)(nil)
 	_ fs.ObjectInfo      = (*ObjectInfo)(nil)
 	_ fs.Object          = (*Object)(nil)
 	_ fs.ObjectUnWrapper = (*Object)(nil)
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
 	"github.com/rclone/rclone/lib/random"
@@ -141,3 +143,31 @@ func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("ObjectInfoWrap", func(t *testing.T) { testObjectInfo(t, f, true) })
 	t.Run("ComputeHash", func(t *testing.T) { testComputeHash(t, f) })
 }
+
+func TestNewCipherDirectoryPasswords(t *testing.T) {
+	password := obscure.MustObscure("potato")
+	newCipher := func(dirPasswords string) (*Cipher, error) {
+		return NewCipher(configmap.Simple{
+			"filename_encryption": "standard",
+			"password":            password,
+			"directory_passwords": dirPasswords,
+		})
+	}
+	c, err := newCipher("finance/=" + obscure.MustObscure("a") + ",teams/hr=" + obscure.MustObscure("b"))
+	require.NoError(t, err)
+	require.Len(t, c.dirKeys, 2)
+	assert.Equal(t, "teams/hr", c.dirKeys[0].dir)
+	assert.Equal(t, "finance", c.dirKeys[1].dir)
+	assert.False(t, c.forFile("finance/x").sameDataKey(c))
+	assert.False(t, c.forFile("teams/hr/x").sameDataKey(c.forFile("finance/x")))
+
+	for _, bad := range []string{
+		"finance",
+		"/=" + password,
+		"finance=" + password + ",finance=" + password,
+		"finance=notobscured",
+	} {
+		_, err = newCipher(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,146 @@
+// Storing the hashes of the cleartext of files in their metadata
+
+package crypt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// hashesMetadataName is the metadata key the encrypted hashes are
+// stored under. It is only letters so it is a valid key on all the
+// backends which can store metadata.
+const hashesMetadataName = "rclonecrypthashes"
+
+// storedHashTypes are the hashes of the cleartext stored with
+// store_hashes
+var storedHashTypes = hash.NewHashSet(hash.MD5, hash.SHA1)
+
+// fileHashes are the hashes stored for a file, encrypted, in the
+// metadata of the underlying object
+type fileHashes struct {
+	Hashes    map[string]string `json:"hashes"`              // hashes of the cleartext by hash name
+	Encrypted map[string]string `json:"encrypted,omitempty"` // hash of the encrypted data when uploaded by hash name
+}
+
+// sealHashes encrypts the hashes with the data key of c
+func (c *Cipher) sealHashes(hashes *fileHashes) (string, error) {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return "", err
+	}
+	var nonce nonce
+	err = nonce.fromReader(c.cryptoRand)
+	if err != nil {
+		return "", err
+	}
+	sealed := secretbox.Seal(nonce[:], data, nonce.pointer(), &c.dataKey)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openHashes decrypts hashes encrypted by sealHashes
+func (c *Cipher) openHashes(in string) (*fileHashes, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < fileNonceSize+secretbox.Overhead {
+		return nil, ErrorEncryptedFileTooShort
+	}
+	var nonce nonce
+	nonce.fromBuf(sealed)
+	data, ok := secretbox.Open(nil, sealed[fileNonceSize:], nonce.pointer(), &c.dataKey)
+	if !ok {
+		return nil, ErrorEncryptedBadBlock
+	}
+	hashes := new(fileHashes)
+	err = json.Unmarshal(data, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// hashesMetadataKey returns the metadata key of the underlying remote
+// to store the hashes under
+func (f *Fs) hashesMetadataKey() string {
+	// The local backend stores metadata as extended attributes
+	if f.Fs.Features().IsLocal {
+		return fs.MetadataXattrPrefix + "user." + hashesMetadataName
+	}
+	return hashesMetadataName
+}
+
+// storeHashes stores the hashes of the cleartext of the file at
+// remote in the metadata of its underlying object o along with the
+// hash of its encrypted data if known.
+func (f *Fs) storeHashes(ctx context.Context, o fs.Object, remote string, sums map[hash.Type]string, encryptedType hash.Type, encryptedHash string) error {
+	do, ok := o.(fs.SetMetadataer)
+	if !ok {
+		fs.Debugf(o, "Can't store hashes as the remote doesn't support metadata")
+		return nil
+	}
+	hashes := &fileHashes{
+		Hashes: make(map[string]string, len(sums)),
+	}
+	for ht, sum := range sums {
+		hashes.Hashes[ht.String()] = sum
+	}
+	if encryptedType != hash.None && encryptedHash != "" {
+		hashes.Encrypted = map[string]string{
+			encryptedType.String(): encryptedHash,
+		}
+	}
+	sealed, err := f.cipher.forFile(remote).sealHashes(hashes)
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt hashes")
+	}
+	err = do.SetMetadata(ctx, fs.Metadata{f.hashesMetadataKey(): sealed})
+	if err != nil {
+		return errors.Wrap(err, "failed to store hashes")
+	}
+	return nil
+}
+
+// readHashes reads the hashes stored for the object.
+//
+// It returns nil if there aren't any or if the encrypted data has
+// changed since they were stored.
+func (o *Object) readHashes(ctx context.Context) (*fileHashes, error) {
+	metadata, err := fs.GetMetadata(ctx, o.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read metadata")
+	}
+	sealed, ok := metadata[o.f.hashesMetadataKey()]
+	if !ok {
+		return nil, nil
+	}
+	hashes, err := o.f.cipher.forFile(o.Remote()).openHashes(sealed)
+	if err != nil {
+		fs.Debugf(o, "Ignoring undecryptable stored hashes: %v", err)
+		return nil, nil
+	}
+	// Check the encrypted data is still the data the hashes were
+	// stored for
+	for name, want := range hashes.Encrypted {
+		var ht hash.Type
+		if ht.Set(name) != nil || !o.Object.Fs().Hashes().Contains(ht) {
+			continue
+		}
+		got, err := o.Object.Hash(ctx, ht)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read hash of encrypted data")
+		}
+		if got != "" && got != want {
+			fs.Debugf(o, "Ignoring stored hashes as the encrypted data has changed")
+			return nil, nil
+		}
+	}
+	return hashes, nil
+}
@@ -0,0 +1,81 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealHashes(t *testing.T) {
+	c, err := newCipher(NameEncryptionStandard, "potato", "", true)
+	require.NoError(t, err)
+	in := &fileHashes{
+		Hashes:    map[string]string{"MD5": "0123456789abcdef0123456789abcdef"},
+		Encrypted: map[string]string{"SHA-1": "0123456789abcdef0123456789abcdef01234567"},
+	}
+	sealed, err := c.sealHashes(in)
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "0123456789")
+	out, err := c.openHashes(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	other, err := newCipher(NameEncryptionStandard, "other", "", true)
+	require.NoError(t, err)
+	_, err = other.openHashes(sealed)
+	assert.Equal(t, ErrorEncryptedBadBlock, err)
+	_, err = c.openHashes("AAAA")
+	assert.Equal(t, ErrorEncryptedFileTooShort, err)
+}
+
+func TestStoredHashes(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-crypt-hashes")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	f, err := NewFs("TestCryptHashes", "", configmap.Simple{
+		"remote":              dir,
+		"filename_encryption": "off",
+		"password":            obscure.MustObscure("potato"),
+		"store_hashes":        "true",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, storedHashTypes, f.Hashes())
+
+	contents := []byte("hello world")
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), int64(len(contents)), true, nil, nil)
+	o, err := f.Put(ctx, bytes.NewReader(contents), src)
+	require.NoError(t, err)
+	gotMD5, err := o.Hash(ctx, hash.MD5)
+	require.NoError(t, err)
+	if gotMD5 == "" {
+		t.Skip("Extended attributes not supported")
+	}
+	sum := md5.Sum(contents)
+	assert.Equal(t, hex.EncodeToString(sum[:]), gotMD5)
+	_, err = o.Hash(ctx, hash.Whirlpool)
+	assert.Equal(t, hash.ErrUnsupported, err)
+
+	// The stored hashes are ignored if the encrypted data changes
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt.bin"), []byte("changed"), 0600))
+	o, err = f.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+	gotMD5, err = o.Hash(ctx, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, "", gotMD5)
+}
@@ -0,0 +1,245 @@
+// Fetching the passwords from key providers outside the config file
+
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/lib/cache"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+// Names of the key providers
+const (
+	keyProviderCommand  = "command"
+	keyProviderKeychain = "keychain"
+	keyProviderVault    = "vault"
+	keyProviderAWSKMS   = "awskms"
+)
+
+// keychainService is the service the passwords are stored under in
+// the OS keychain
+const keychainService = "rclone"
+
+// keyProvider fetches the secret called what, "password" or
+// "password2", for the config in opt
+type keyProvider func(ctx context.Context, opt *Options, what string) (string, error)
+
+var keyProviders = map[string]keyProvider{
+	keyProviderCommand:  commandKey,
+	keyProviderKeychain: keychainKey,
+	keyProviderVault:    vaultKey,
+	keyProviderAWSKMS:   awsKMSKey,
+}
+
+// keyCache caches the secrets fetched from the key providers so they
+// aren't fetched for every crypt remote made. Errors aren't cached so
+// a failed fetch, eg because of expired credentials, is tried again
+// the next time.
+var keyCache = cache.New()
+
+// fetchKey fetches the secret called what from the key provider in
+// opt, using the cached value if there is one
+func fetchKey(ctx context.Context, opt *Options, what string) (string, error) {
+	provider, ok := keyProviders[opt.KeyProvider]
+	if !ok {
+		return "", errors.Errorf("unknown key provider %q", opt.KeyProvider)
+	}
+	cacheKey := fmt.Sprintf("%q", []string{
+		opt.KeyProvider, what, opt.KeyName, opt.KeyCommand.String(),
+		opt.VaultAddress, opt.KMSPassword, opt.KMSPassword2, opt.KMSRegion, opt.KMSEndpoint,
+	})
+	value, err := keyCache.Get(cacheKey, func(string) (interface{}, bool, error) {
+		secret, err := provider(ctx, opt, what)
+		if err == nil && secret == "" {
+			err = errors.New("empty secret returned")
+		}
+		return secret, false, err
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch %s from key provider %q", what, opt.KeyProvider)
+	}
+	return value.(string), nil
+}
+
+// runKeyCommand runs the command in args and returns its output
+// without the trailing new line
+func runKeyCommand(ctx context.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("no command set")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Stdin = os.Stdin
+	err := cmd.Run()
+	if err != nil {
+		if ers := strings.TrimSpace(stderr.String()); ers != "" {
+			return "", errors.Wrapf(err, "command failed: %s", ers)
+		}
+		return "", errors.Wrap(err, "command failed")
+	}
+	return strings.Trim(stdout.String(), "\r\n"), nil
+}
+
+// commandKey runs key_command with what as the last argument
+func commandKey(ctx context.Context, opt *Options, what string) (string, error) {
+	if len(opt.KeyCommand) == 0 {
+		return "", errors.New("key_command not set")
+	}
+	args := append(append([]string{}, opt.KeyCommand...), what)
+	return runKeyCommand(ctx, args)
+}
+
+// keychainArgs returns the command to read the secret for account
+// from the OS keychain
+func keychainArgs(goos, account string) ([]string, error) {
+	switch goos {
+	case "darwin":
+		return []string{"security", "find-generic-password", "-w", "-s", keychainService, "-a", account}, nil
+	case "windows", "plan9", "js":
+		return nil, errors.Errorf("the keychain isn't supported on %s - use the command key provider", goos)
+	}
+	// The freedesktop.org secret service, eg GNOME Keyring or KWallet
+	return []string{"secret-tool", "lookup", "service", keychainService, "account", account}, nil
+}
+
+// keychainKey reads the secret "key_name:what" from the OS keychain
+func keychainKey(ctx context.Context, opt *Options, what string) (string, error) {
+	if opt.KeyName == "" {
+		return "", errors.New("key_name not set")
+	}
+	args, err := keychainArgs(runtime.GOOS, opt.KeyName+":"+what)
+	if err != nil {
+		return "", err
+	}
+	return runKeyCommand(ctx, args)
+}
+
+// vaultToken reads the Vault token from the environment or the file
+// "vault login" writes it to
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "no VAULT_TOKEN")
+	}
+	token, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", errors.Wrap(err, "no VAULT_TOKEN")
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// vaultKey reads the field what of the Vault secret at key_name
+//
+// Both version 1 and version 2 of the KV secrets engine are supported.
+func vaultKey(ctx context.Context, opt *Options, what string) (string, error) {
+	if opt.KeyName == "" {
+		return "", errors.New("key_name not set")
+	}
+	address := opt.VaultAddress
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return "", errors.New("vault_address not set")
+	}
+	srv := rest.NewClient(fshttp.NewClient(fs.Config)).SetRoot(strings.TrimRight(address, "/") + "/v1/")
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	// The token is read again if it is refused in case it was
+	// renewed, eg by the Vault agent, since it was read
+	var resp *http.Response
+	var lastToken string
+	for {
+		token, err := vaultToken()
+		if err != nil {
+			return "", err
+		}
+		opts := rest.Opts{
+			Method:       "GET",
+			Path:         strings.Trim(opt.KeyName, "/"),
+			ExtraHeaders: map[string]string{"X-Vault-Token": token},
+		}
+		resp, err = srv.CallJSON(ctx, &opts, nil, &result)
+		if err == nil {
+			break
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			return "", err
+		}
+		if token == lastToken {
+			return "", errors.Wrap(err, "permission denied - check the token or log in to Vault again")
+		}
+		lastToken = token
+	}
+	data := result.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = inner
+	}
+	secret, ok := data[what].(string)
+	if !ok {
+		return "", errors.Errorf("secret %q has no %q field", opt.KeyName, what)
+	}
+	return secret, nil
+}
+
+// awsKMSKey decrypts kms_password or kms_password2 with AWS KMS
+//
+// The credentials are read from the environment, the shared AWS
+// config or the instance role and are refreshed by the SDK.
+func awsKMSKey(ctx context.Context, opt *Options, what string) (string, error) {
+	encrypted := opt.KMSPassword
+	if what == "password2" {
+		encrypted = opt.KMSPassword2
+	}
+	if encrypted == "" {
+		return "", errors.Errorf("kms_%s not set", what)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", errors.Wrapf(err, "kms_%s isn't base64", what)
+	}
+	awsConfig := aws.NewConfig().WithHTTPClient(fshttp.NewClient(fs.Config))
+	if opt.KMSRegion != "" {
+		awsConfig.WithRegion(opt.KMSRegion)
+	}
+	if opt.KMSEndpoint != "" {
+		awsConfig.WithEndpoint(opt.KMSEndpoint)
+	}
+	ses, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return "", err
+	}
+	out, err := kms.New(ses).DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob: ciphertext,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out.Plaintext), nil
+}
@@ -0,0 +1,171 @@
+package crypt
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv sets the environment variable key to value returning a
+// function to restore it
+func setEnv(key, value string) func() {
+	old, found := os.LookupEnv(key)
+	_ = os.Setenv(key, value)
+	return func() {
+		if found {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	}
+}
+
+func TestKeychainArgs(t *testing.T) {
+	args, err := keychainArgs("darwin", "myremote:password")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"security", "find-generic-password", "-w", "-s", "rclone", "-a", "myremote:password"}, args)
+	args, err = keychainArgs("linux", "myremote:password2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret-tool", "lookup", "service", "rclone", "account", "myremote:password2"}, args)
+	_, err = keychainArgs("windows", "myremote:password")
+	assert.Error(t, err)
+}
+
+func TestCommandKey(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-crypt-keys")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	secretFile := filepath.Join(dir, "secret")
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("potato\n"), 0600))
+
+	opt := &Options{
+		KeyProvider: keyProviderCommand,
+		KeyCommand:  fs.SpaceSepList{"sh", "-c", "cat " + secretFile},
+	}
+	secret, err := fetchKey(ctx, opt, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", secret)
+
+	// The secret is cached
+	require.NoError(t, ioutil.WriteFile(secretFile, []byte("changed\n"), 0600))
+	secret, err = fetchKey(ctx, opt, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", secret)
+
+	// The argument says which secret is wanted
+	secret, err = commandKey(ctx, &Options{KeyCommand: fs.SpaceSepList{"echo"}}, "password2")
+	require.NoError(t, err)
+	assert.Equal(t, "password2", secret)
+
+	// Errors aren't cached
+	opt.KeyCommand = fs.SpaceSepList{"sh", "-c", "exit 1"}
+	_, err = fetchKey(ctx, opt, "password2")
+	assert.Error(t, err)
+	opt.KeyCommand = fs.SpaceSepList{"sh", "-c", "cat " + secretFile}
+	secret, err = fetchKey(ctx, opt, "password2")
+	require.NoError(t, err)
+	assert.Equal(t, "changed", secret)
+
+	_, err = fetchKey(ctx, &Options{KeyProvider: "potato"}, "password")
+	assert.Error(t, err)
+}
+
+func TestNewCipherKeyProvider(t *testing.T) {
+	c, err := NewCipher(configmap.Simple{
+		"filename_encryption": "standard",
+		"key_provider":        "command",
+		"key_command":         "echo",
+		"key_password2":       "true",
+	})
+	require.NoError(t, err)
+	want, err := newCipher(NameEncryptionStandard, "password", "password2", true)
+	require.NoError(t, err)
+	assert.True(t, c.sameDataKey(want))
+
+	_, err = NewCipher(configmap.Simple{
+		"filename_encryption": "standard",
+	})
+	assert.EqualError(t, err, "password not set in config file")
+}
+
+func TestVaultKey(t *testing.T) {
+	ctx := context.Background()
+	defer setEnv("VAULT_ADDR", "")()
+	defer setEnv("VAULT_TOKEN", "token")()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/secret/data/rclone":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"potato","password2":"sausage"},"metadata":{"version":1}}}`))
+		case "/v1/kv/rclone":
+			_, _ = w.Write([]byte(`{"data":{"password":"potato"}}`))
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opt := &Options{KeyName: "secret/data/rclone", VaultAddress: server.URL}
+	secret, err := vaultKey(ctx, opt, "password2")
+	require.NoError(t, err)
+	assert.Equal(t, "sausage", secret)
+
+	// Version 1 of the KV engine with the address from the environment
+	defer setEnv("VAULT_ADDR", server.URL)()
+	opt = &Options{KeyName: "/kv/rclone/"}
+	secret, err = vaultKey(ctx, opt, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", secret)
+	_, err = vaultKey(ctx, opt, "password2")
+	assert.Error(t, err)
+
+	defer setEnv("VAULT_TOKEN", "bad")()
+	_, err = vaultKey(ctx, opt, "password")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+func TestAWSKMSKey(t *testing.T) {
+	ctx := context.Background()
+	defer setEnv("AWS_ACCESS_KEY_ID", "id")()
+	defer setEnv("AWS_SECRET_ACCESS_KEY", "secret")()
+	defer setEnv("AWS_CA_BUNDLE", "")()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "TrentService.Decrypt", r.Header.Get("X-Amz-Target"))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_, _ = w.Write([]byte(`{"KeyId":"key","Plaintext":"` + base64.StdEncoding.EncodeToString([]byte("potato")) + `"}`))
+	}))
+	defer server.Close()
+
+	opt := &Options{
+		KMSPassword: base64.StdEncoding.EncodeToString([]byte("encrypted")),
+		KMSRegion:   "us-east-1",
+		KMSEndpoint: server.URL,
+	}
+	secret, err := awsKMSKey(ctx, opt, "password")
+	require.NoError(t, err)
+	assert.Equal(t, "potato", secret)
+
+	_, err = awsKMSKey(ctx, opt, "password2")
+	assert.Error(t, err)
+	opt.KMSPassword = "not base64!"
+	_, err = awsKMSKey(ctx, opt, "password")
+	assert.Error(t, err)
+}
@@ -38,6 +38,7 @@ import (
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
@@ -74,7 +75,7 @@ const (
 	// 1<<18 is the minimum size supported by the Google uploader, and there is no maximum.
 	minChunkSize     = 256 * fs.KibiByte
 	defaultChunkSize = 8 * fs.MebiByte
-	partialFields    = "id,name,size,md5Checksum,trashed,explicitlyTrashed,modifiedTime,createdTime,mimeType,parents,webViewLink,shortcutDetails,exportLinks"
+	partialFields    = "id,resourceKey,name,size,md5Checksum,trashed,explicitlyTrashed,modifiedTime,createdTime,mimeType,parents,webViewLink,shortcutDetails,exportLinks"
 	listRGrouping    = 50   // number of IDs to search at once when using ListR
 	listRInputBuffer = 1000 // size of input buffer when using ListR
 
@@ -333,6 +334,40 @@ commands (copy, sync, etc), and with all other commands too.`,
 			Default:  "",
 			Help:     "Comma separated list of preferred formats for uploading Google docs.",
 			Advanced: true,
+		}, {
+			Name:    "import_mapping",
+			Default: "",
+			Help: `Comma separated list of extension:type choosing the document type to import files as.
+
+Drive can import some file types as more than one type of Google
+document, eg csv files as a document or a spreadsheet. Normally rclone
+uses the first type Drive offers. Use this to choose the type instead,
+eg "csv:spreadsheet,txt:document".
+
+The type can be document, spreadsheet, presentation or drawing.
+Extensions listed here are imported even if they aren't in
+import_formats.`,
+			Advanced: true,
+		}, {
+			Name:    "import_collision",
+			Default: "duplicate",
+			Help: `What to do if importing a file would duplicate an existing document.
+
+When a file is imported its extension is removed, so importing
+"report.odt" creates a document called "report". If there is a
+document of the same type called "report" already, perhaps imported
+from "report.docx", Drive will have two documents with the same name.`,
+			Examples: []fs.OptionExample{{
+				Value: "duplicate",
+				Help:  "Create another document with the same name",
+			}, {
+				Value: "update",
+				Help:  "Update the existing document with the file",
+			}, {
+				Value: "error",
+				Help:  "Don't upload the file and return an error",
+			}},
+			Advanced: true,
 		}, {
 			Name:     "allow_import_name_change",
 			Default:  false,
@@ -500,6 +535,59 @@ See: https://github.com/rclone/rclone/issues/3857
 Normally rclone dereferences shortcut files making them appear as if
 they are the original file (see [the shortcuts section](#shortcuts)).
 If this flag is set then rclone will ignore shortcut files completely.
+`,
+			Advanced: true,
+			Default:  false,
+		}, {
+			Name: "skip_dangling_shortcuts",
+			Help: `If set skip dangling shortcut files
+
+Shortcuts whose target has been deleted, or which point to files you
+no longer have access to, normally appear as empty files which can't
+be read. If this flag is set then rclone will leave them out of the
+listings instead.
+`,
+			Advanced: true,
+			Default:  false,
+		}, {
+			Name: "list_cache",
+			Help: `Keep a listing of the drive between runs, updated with the changes API.
+
+If this is set then the first recursive listing (eg with --fast-list)
+lists the whole drive and keeps the listing in rclone's cache
+directory. Later recursive listings read the changes made since from
+the changes API and update the cached listing instead of listing every
+directory again, which uses much less API quota on large drives.
+
+This isn't used with shared_with_me, starred_only, trashed_only or the
+appDataFolder.`,
+			Advanced: true,
+			Default:  false,
+		}, {
+			Name: "resource_keys",
+			Help: `Comma separated list of resource keys for items shared by link.
+
+Some files and folders shared by link need a resource key as well as
+the ID to access them, otherwise Drive says they can't be found. Give
+each as fileID/resourceKey or paste the shared link containing
+"resourcekey=", eg
+
+    https://drive.google.com/drive/folders/ID?resourcekey=KEY
+
+The keys are sent with every request so are used for the items inside
+a shared folder too. The resource keys of the items listed are
+recorded and sent too, so only the key of the top shared item is
+needed.`,
+			Advanced: true,
+			Default:  "",
+		}, {
+			Name: "copy_shortcut_content",
+			Help: `Server side copy contents of shortcuts instead of the shortcut.
+
+When doing server side copies, normally rclone will copy shortcuts as
+shortcuts. If this flag is used then rclone will copy the contents of
+the shortcuts rather than shortcuts themselves, so the copy doesn't
+depend on the original file staying where it is.
 `,
 			Advanced: true,
 			Default:  false,
@@ -548,6 +636,8 @@ type Options struct {
 	Extensions                string               `config:"formats"`
 	ExportExtensions          string               `config:"export_formats"`
 	ImportExtensions          string               `config:"import_formats"`
+	ImportMapping             string               `config:"import_mapping"`
+	ImportCollision           string               `config:"import_collision"`
 	AllowImportNameChange     bool                 `config:"allow_import_name_change"`
 	UseCreatedDate            bool                 `config:"use_created_date"`
 	UseSharedDate             bool                 `config:"use_shared_date"`
@@ -565,6 +655,10 @@ type Options struct {
 	DisableHTTP2              bool                 `config:"disable_http2"`
 	StopOnUploadLimit         bool                 `config:"stop_on_upload_limit"`
 	SkipShortcuts             bool                 `config:"skip_shortcuts"`
+	SkipDanglingShortcuts     bool                 `config:"skip_dangling_shortcuts"`
+	CopyShortcutContent       bool                 `config:"copy_shortcut_content"`
+	ListCache                 bool                 `config:"list_cache"`
+	ResourceKeys              string               `config:"resource_keys"`
 	Enc                       encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -582,12 +676,16 @@ type Fs struct {
 	pacer               *fs.Pacer          // To pace the API calls
 	exportExtensions    []string           // preferred extensions to download docs
 	importMimeTypes     []string           // MIME types to convert to docs
+	importMapping       map[string]string  // MIME type to the document MIME type to import it as
 	isTeamDrive         bool               // true if this is a team drive
 	fileFields          googleapi.Field    // fields to fetch file info with
+	resourceKeys        *resourceKeys      // resource keys configured and seen
 	m                   configmap.Mapper
 	grouping            int32               // number of IDs to search at once in ListR - read with atomic
 	listRmu             *sync.Mutex         // protects listRempties
 	listRempties        map[string]struct{} // IDs of supposedly empty directories which triggered grouping disable
+	listCacheMu         sync.Mutex          // protects listCache
+	listCache           *listCache          // listing kept up to date with the changes API if in use
 	ServiceAccountFiles map[string]int
 	serviceAccountMutex sync.Mutex
 	serviceAccountPool  *ServiceAccountPool
@@ -605,6 +703,7 @@ type baseObject struct {
 	mimeType     string // The object MIME type
 	bytes        int64  // size of the object
 	parents      int    // number of parents
+	owner        string // email address of the owner if read
 }
 type documentObject struct {
 	baseObject
@@ -756,7 +855,7 @@ func createDriveService(opt *Options, file string) (svc *drive.Service, err erro
 	if err != nil {
 		return nil, errors.Wrap(err, "error opening service account credentials file")
 	}
-	oAuthClient, err := getServiceAccountClient(opt, loadedCreds)
+	oAuthClient, err := getServiceAccountClient(opt, loadedCreds, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create oauth client from service account")
 	}
@@ -1006,6 +1105,11 @@ OUTER:
 				if err != nil {
 					return false, errors.Wrap(err, "list")
 				}
+				// ignore dangling shortcuts if directed
+				if f.opt.SkipDanglingShortcuts && item.MimeType == shortcutMimeTypeDangling {
+					fs.Debugf(item.Name, "Skipping dangling shortcut")
+					continue
+				}
 			}
 			// Check the case of items is correct since
 			// the `=` operator is case insensitive.
@@ -1111,6 +1215,40 @@ func parseExtensions(extensionsIn ...string) (extensions, mimeTypes []string, er
 	return
 }
 
+// parseImportMapping parses a comma separated list of extension:type
+// into a map of MIME type to the document MIME type to import it as
+func parseImportMapping(text string) (mapping map[string]string, err error) {
+	mapping = map[string]string{}
+	for _, rule := range strings.Split(text, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		i := strings.IndexRune(rule, ':')
+		if i < 0 {
+			return nil, errors.Errorf("bad import_mapping %q: expecting extension:type", rule)
+		}
+		_, mimeTypes, err := parseExtensions(rule[:i])
+		if err != nil {
+			return nil, errors.Wrap(err, "bad import_mapping")
+		}
+		if len(mimeTypes) != 1 {
+			return nil, errors.Errorf("bad import_mapping %q: expecting extension:type", rule)
+		}
+		documentType := strings.ToLower(strings.TrimSpace(rule[i+1:]))
+		switch documentType {
+		case "document", "spreadsheet", "presentation", "drawing":
+			documentType = "application/vnd.google-apps." + documentType
+		default:
+			if !isInternalMimeType(documentType) {
+				return nil, errors.Errorf("bad import_mapping %q: unknown document type %q", rule, documentType)
+			}
+		}
+		mapping[mimeTypes[0]] = documentType
+	}
+	return mapping, nil
+}
+
 // Figure out if the user wants to use a team drive
 func configTeamDrive(ctx context.Context, opt *Options, m configmap.Mapper, name string) error {
 	// Stop if we are running non-interactive config
@@ -1152,19 +1290,25 @@ func configTeamDrive(ctx context.Context, opt *Options, m configmap.Mapper, name
 }
 
 // getClient makes an http client according to the options
-func getClient(opt *Options) *http.Client {
+//
+// If keys is set then the client sends and records resource keys
+func getClient(opt *Options, keys *resourceKeys) *http.Client {
 
 	t := fshttp.NewTransportCustom(fs.Config, func(t *http.Transport) {
 		if opt.DisableHTTP2 {
 			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
 		}
 	})
+	var transport http.RoundTripper = t
+	if keys != nil {
+		transport = &resourceKeyTransport{wrapped: t, keys: keys}
+	}
 	return &http.Client{
-		Transport: t,
+		Transport: transport,
 	}
 }
 
-func getServiceAccountClient(opt *Options, credentialsData []byte) (*http.Client, error) {
+func getServiceAccountClient(opt *Options, credentialsData []byte, keys *resourceKeys) (*http.Client, error) {
 	scopes := driveScopes(opt.Scope)
 	conf, err := google.JWTConfigFromJSON(credentialsData, scopes...)
 	if err != nil {
@@ -1173,11 +1317,11 @@ func getServiceAccountClient(opt *Options, credentialsData []byte) (*http.Client
 	if opt.Impersonate != "" {
 		conf.Subject = opt.Impersonate
 	}
-	ctxWithSpecialClient := oauthutil.Context(getClient(opt))
+	ctxWithSpecialClient := oauthutil.Context(getClient(opt, keys))
 	return oauth2.NewClient(ctxWithSpecialClient, conf.TokenSource(ctxWithSpecialClient)), nil
 }
 
-func createOAuthClient(opt *Options, name string, m configmap.Mapper) (*http.Client, error) {
+func createOAuthClient(opt *Options, name string, m configmap.Mapper, keys *resourceKeys) (*http.Client, error) {
 	var oAuthClient *http.Client
 	var err error
 
@@ -1191,12 +1335,12 @@ func createOAuthClient(opt *Options, name string, m configmap.Mapper) (*http.Cli
 	}
 	if opt.ServiceAccountCredentials != "" {
 		// fmt.Printf("Initial SA Client %s \n", opt.ServiceAccountCredentials[0:250])
-		oAuthClient, err = getServiceAccountClient(opt, []byte(opt.ServiceAccountCredentials))
+		oAuthClient, err = getServiceAccountClient(opt, []byte(opt.ServiceAccountCredentials), keys)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create oauth client from service account")
 		}
 	} else {
-		oAuthClient, _, err = oauthutil.NewClientWithBaseClient(name, m, driveConfig, getClient(opt))
+		oAuthClient, _, err = oauthutil.NewClientWithBaseClient(name, m, driveConfig, getClient(opt, keys))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create oauth client")
 		}
@@ -1290,6 +1434,10 @@ func newFs(name, path string, m configmap.Mapper) (*Fs, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "drive: chunk size")
 	}
+	keys, err := newResourceKeys(opt.ResourceKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "drive: resource keys")
+	}
 
 	// Mod: create service account pool
 	pool := newServiceAccountPool(opt.ServicesMax)
@@ -1301,7 +1449,7 @@ func newFs(name, path string, m configmap.Mapper) (*Fs, error) {
 		}
 	}
 
-	oAuthClient, err := createOAuthClient(opt, name, m)
+	oAuthClient, err := createOAuthClient(opt, name, m, keys)
 	if err != nil {
 		return nil, errors.Wrap(err, "drive: failed when making oauth client")
 	}
@@ -1321,6 +1469,7 @@ func newFs(name, path string, m configmap.Mapper) (*Fs, error) {
 		listRmu:            new(sync.Mutex),
 		listRempties:       make(map[string]struct{}),
 		serviceAccountPool: pool,
+		resourceKeys:       keys,
 	}
 	f.isTeamDrive = opt.TeamDriveID != ""
 	f.fileFields = f.getFileFields()
@@ -1441,6 +1590,20 @@ func NewFs(name, path string, m configmap.Mapper) (fs.Fs, error) {
 	if err != nil {
 		return nil, err
 	}
+	f.importMapping, err = parseImportMapping(f.opt.ImportMapping)
+	if err != nil {
+		return nil, err
+	}
+	for mimeType := range f.importMapping {
+		if !containsString(f.importMimeTypes, mimeType) {
+			f.importMimeTypes = append(f.importMimeTypes, mimeType)
+		}
+	}
+	switch f.opt.ImportCollision {
+	case "duplicate", "update", "error":
+	default:
+		return nil, errors.Errorf("unknown import_collision %q - expecting duplicate, update or error", f.opt.ImportCollision)
+	}
 
 	if srcFile != nil {
 		tempF := *f
@@ -1498,6 +1661,10 @@ func (f *Fs) newBaseObject(remote string, info *drive.File) baseObject {
 	if f.opt.SizeAsQuota {
 		size = info.QuotaBytesUsed
 	}
+	owner := ""
+	if len(info.Owners) > 0 {
+		owner = info.Owners[0].EmailAddress
+	}
 	return baseObject{
 		fs:           f,
 		remote:       remote,
@@ -1506,13 +1673,15 @@ func (f *Fs) newBaseObject(remote string, info *drive.File) baseObject {
 		mimeType:     info.MimeType,
 		bytes:        size,
 		parents:      len(info.Parents),
+		owner:        owner,
 	}
 }
 
 // getFileFields gets the fields for a normal file Get or List
 func (f *Fs) getFileFields() (fields googleapi.Field) {
 	fields = partialFields
-	if f.opt.AuthOwnerOnly {
+	// Only read the owners if needed as it makes listings bigger
+	if f.opt.AuthOwnerOnly || filter.Active.UsesOwner() {
 		fields += ",owners"
 	}
 	if f.opt.UseSharedDate {
@@ -2010,6 +2179,12 @@ func (f *Fs) findImportFormat(mimeType string) string {
 	for _, mt := range f.importMimeTypes {
 		if mt == mimeType {
 			importMimeTypes := ifs[mimeType]
+			if documentMimeType, ok := f.importMapping[mimeType]; ok {
+				if containsString(importMimeTypes, documentMimeType) {
+					return documentMimeType
+				}
+				fs.Errorf(f, "can't import %q as %q - Drive only allows %q", mimeType, documentMimeType, importMimeTypes)
+			}
 			if l := len(importMimeTypes); l > 0 {
 				if l > 1 {
 					fs.Infof(f, "found %d import formats for %q: %q", l, mimeType, importMimeTypes)
@@ -2021,6 +2196,34 @@ func (f *Fs) findImportFormat(mimeType string) string {
 	return ""
 }
 
+// canImportAs returns true if files of mimeType are being imported
+// and Drive can import them as documentMimeType
+func (f *Fs) canImportAs(mimeType, documentMimeType string) bool {
+	mimeType = fixMimeType(mimeType)
+	return containsString(f.importMimeTypes, mimeType) && containsString(f.importFormats()[mimeType], documentMimeType)
+}
+
+// findDocument finds an existing document of documentMimeType at
+// remote, which doesn't have the export extension on. It returns nil
+// if there isn't one.
+func (f *Fs) findDocument(ctx context.Context, remote, documentMimeType string) (info *drive.File, err error) {
+	leaf, directoryID, err := f.dirCache.FindPath(ctx, remote, false)
+	if err == fs.ErrorDirNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	directoryID = actualID(directoryID)
+	_, err = f.list(ctx, []string{directoryID}, leaf, false, true, false, func(item *drive.File) bool {
+		if item.Name == leaf && item.MimeType == documentMimeType {
+			info = item
+			return true
+		}
+		return false
+	})
+	return info, err
+}
+
 // List the objects and directories in dir into entries.  The
 // entries can be returned in any order but should be for a
 // complete directory.
@@ -2251,6 +2454,10 @@ func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (
 	}
 	directoryID = actualID(directoryID)
 
+	if f.useListCache() {
+		return f.listRFromCache(ctx, dir, directoryID, callback)
+	}
+
 	mu := sync.Mutex{} // protects in and overflow
 	wg := sync.WaitGroup{}
 	in := make(chan listREntry, listRInputBuffer)
@@ -2537,6 +2744,23 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 			if exportExt != srcExt && !f.opt.AllowImportNameChange {
 				return nil, errors.Errorf("Can't convert %q to a document with a different export filetype (%q)", srcExt, exportExt)
 			}
+			if f.opt.ImportCollision != "duplicate" {
+				info, err := f.findDocument(ctx, remote, importMimeType)
+				if err != nil {
+					return nil, err
+				}
+				if info != nil {
+					if f.opt.ImportCollision == "error" {
+						return nil, errors.Errorf("can't import %q as document %q already exists", src.Remote(), remote+exportExt)
+					}
+					fs.Infof(src, "Updating existing document %q instead of making a duplicate", remote+exportExt)
+					o, err := f.newObjectWithInfo(remote, info)
+					if err != nil {
+						return nil, err
+					}
+					return o, o.Update(ctx, in, src, options...)
+				}
+			}
 		}
 	}
 
@@ -2568,7 +2792,7 @@ func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo,
 		}
 	} else {
 		// Upload the file in chunks
-		info, err = f.Upload(ctx, in, size, srcMimeType, "", remote, createInfo)
+		info, err = f.Upload(ctx, in, size, srcMimeType, "", remote, createInfo, src)
 		if err != nil {
 			return nil, err
 		}
@@ -2780,8 +3004,12 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 		createInfo.Description = ""
 	}
 
-	// get the ID of the thing to copy - this is the shortcut if available
+	// get the ID of the thing to copy - this is the shortcut if
+	// available unless we are copying the contents of shortcuts
 	id := shortcutID(srcObj.id)
+	if f.opt.CopyShortcutContent {
+		id = actualID(srcObj.id)
+	}
 
 	var info *drive.File
 	err = f.pacer.Call(func() (bool, error) {
@@ -3299,7 +3527,7 @@ func (f *Fs) changeServiceAccountFile(file string) (err error) {
 	}()
 	f.opt.ServiceAccountFile = file
 	f.opt.ServiceAccountCredentials = ""
-	oAuthClient, err := createOAuthClient(&f.opt, f.name, f.m)
+	oAuthClient, err := createOAuthClient(&f.opt, f.name, f.m, f.resourceKeys)
 	if err != nil {
 		return errors.Wrap(err, "drive: failed when making oauth client")
 	}
@@ -4317,7 +4545,7 @@ func (o *baseObject) update(ctx context.Context, updateInfo *drive.File, uploadM
 		return
 	}
 	// Upload the file in chunks
-	return o.fs.Upload(ctx, in, size, uploadMimeType, o.id, o.remote, updateInfo)
+	return o.fs.Upload(ctx, in, size, uploadMimeType, o.id, o.remote, updateInfo, src)
 }
 
 // Update the already existing object
@@ -4383,6 +4611,10 @@ func (o *documentObject) Update(ctx context.Context, in io.Reader, src fs.Object
 	if importMimeType == "" {
 		return errors.Errorf("no import format found for %q", srcMimeType)
 	}
+	// Keep the type of the document if it can be imported as it
+	if importMimeType != o.documentMimeType && o.fs.canImportAs(srcMimeType, o.documentMimeType) {
+		importMimeType = o.documentMimeType
+	}
 	if importMimeType != o.documentMimeType {
 		return errors.Errorf("can't change google document type (o: %q, src: %q, import: %q)", o.documentMimeType, srcMimeType, importMimeType)
 	}
@@ -4393,7 +4625,9 @@ func (o *documentObject) Update(ctx context.Context, in io.Reader, src fs.Object
 		return err
 	}
 
-	remote := src.Remote()
+	// Use the remote of the document as the src may have a different
+	// extension if it was imported with a name change
+	remote := o.remote
 	remote = remote[:len(remote)-o.extLen]
 
 	newO, err := o.fs.newObjectWithInfo(remote, info)
@@ -4427,6 +4661,13 @@ func (o *baseObject) MimeType(ctx context.Context) string {
 	return o.mimeType
 }
 
+// Owner returns the email address of the owner of the Object if
+// known, or "" if not. This is only read if there are filters on the
+// owner.
+func (o *baseObject) Owner(ctx context.Context) string {
+	return o.owner
+}
+
 // ID returns the ID of the Object if known, or "" if not
 func (o *baseObject) ID() string {
 	return o.id
@@ -4492,6 +4733,7 @@ var (
 	_ fs.Object          = (*Object)(nil)
 	_ fs.MimeTyper       = (*Object)(nil)
 	_ fs.IDer            = (*Object)(nil)
+	_ fs.Ownerer         = (*Object)(nil)
 	_ fs.Object          = (*documentObject)(nil)
 	_ fs.MimeTyper       = (*documentObject)(nil)
 	_ fs.IDer            = (*documentObject)(nil)
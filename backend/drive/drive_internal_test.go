@@ -108,6 +108,42 @@ func TestInternalParseExtensions(t *testing.T) {
 	assert.Equal(t, []string{".docx", ".svg", ".xlsx"}, extensions)
 }
 
+func TestInternalParseImportMapping(t *testing.T) {
+	mapping, err := parseImportMapping(" csv:Spreadsheet, .txt:document,odt:application/vnd.google-apps.document")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"text/csv; charset=utf-8":                 "application/vnd.google-apps.spreadsheet",
+		"text/plain; charset=utf-8":               "application/vnd.google-apps.document",
+		"application/vnd.oasis.opendocument.text": "application/vnd.google-apps.document",
+	}, mapping)
+
+	mapping, err = parseImportMapping("")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, mapping)
+
+	for _, in := range []string{"csv", "potato:document", "csv:potato", "csv,txt:document"} {
+		_, err = parseImportMapping(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestInternalFindImportFormat(t *testing.T) {
+	f := new(Fs)
+	_, f.importMimeTypes, _ = parseExtensions("csv,txt")
+	f.importMapping = map[string]string{}
+	assert.Equal(t, "application/vnd.google-apps.spreadsheet", f.findImportFormat("text/csv"))
+	assert.Equal(t, "", f.findImportFormat("application/pdf"))
+
+	// A mapping Drive doesn't allow falls back to the default
+	f.importMapping, _ = parseImportMapping("csv:document,txt:document")
+	assert.Equal(t, "application/vnd.google-apps.spreadsheet", f.findImportFormat("text/csv"))
+	assert.Equal(t, "application/vnd.google-apps.document", f.findImportFormat("text/plain"))
+
+	assert.True(t, f.canImportAs("text/csv", "application/vnd.google-apps.spreadsheet"))
+	assert.False(t, f.canImportAs("text/csv", "application/vnd.google-apps.document"))
+	assert.False(t, f.canImportAs("application/pdf", "application/vnd.google-apps.document"))
+}
+
 func TestInternalFindExportFormat(t *testing.T) {
 	item := &drive.File{
 		Name:     "file",
@@ -0,0 +1,331 @@
+// Listing from a cache kept up to date with the changes API
+
+package drive
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/walk"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// listCache is a listing of every file in the drive which is kept
+// between runs and brought up to date with the changes API
+type listCache struct {
+	Token string                 `json:"token"` // start page token for the changes since the listing
+	Files map[string]*drive.File `json:"files"` // files and folders as returned by Drive indexed by ID
+}
+
+// readListCache reads the list cache in path returning nil if there
+// isn't one
+func readListCache(path string) (*listCache, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var c listCache
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse list cache %q", path)
+	}
+	if c.Token == "" {
+		return nil, errors.Errorf("no token in list cache %q", path)
+	}
+	if c.Files == nil {
+		c.Files = map[string]*drive.File{}
+	}
+	return &c, nil
+}
+
+// write saves the list cache to path
+func (c *listCache) write(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make list cache directory")
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write list cache")
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// listCachePath returns the file the list cache is kept in
+func (f *Fs) listCachePath() string {
+	sum := md5.Sum([]byte(f.name + ":" + f.rootFolderID))
+	return filepath.Join(config.CacheDir, "drive-list", hex.EncodeToString(sum[:])+".json")
+}
+
+// useListCache returns true if recursive listings should come from
+// the list cache
+func (f *Fs) useListCache() bool {
+	return f.opt.ListCache &&
+		!f.opt.SharedWithMe &&
+		!f.opt.StarredOnly &&
+		!f.opt.TrashedOnly &&
+		f.rootFolderID != "root" && // the parents of the files won't match this alias
+		f.rootFolderID != "appDataFolder"
+}
+
+// listAllFiles calls fn for every file in the drive which isn't
+// trashed
+func (f *Fs) listAllFiles(ctx context.Context, fn func(item *drive.File)) error {
+	list := f.svc.Files.List().
+		Q("trashed=false").
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true)
+	if f.opt.ListChunk > 0 {
+		list.PageSize(f.opt.ListChunk)
+	}
+	if f.isTeamDrive {
+		list.DriveId(f.opt.TeamDriveID)
+		list.Corpora("drive")
+	}
+	fields := googleapi.Field(fmt.Sprintf("files(%s),nextPageToken", f.fileFields))
+	for {
+		var files *drive.FileList
+		err := f.pacer.Call(func() (bool, error) {
+			var err error
+			files, err = list.Fields(fields).Context(ctx).Do()
+			return f.shouldRetry(err)
+		})
+		if err != nil {
+			return errors.Wrap(err, "couldn't list drive")
+		}
+		for _, item := range files.Files {
+			fn(item)
+		}
+		if files.NextPageToken == "" {
+			return nil
+		}
+		list.PageToken(files.NextPageToken)
+	}
+}
+
+// applyChanges brings the list cache up to date with the changes made
+// since its token. It returns the number of changes applied.
+//
+// Changes carry the current state of the file so if this fails part
+// way through the changes can be applied again.
+func (f *Fs) applyChanges(ctx context.Context, c *listCache) (changed int, err error) {
+	pageToken := c.Token
+	fields := googleapi.Field(fmt.Sprintf("nextPageToken,newStartPageToken,changes(fileId,removed,file(%s))", f.fileFields))
+	for {
+		var changeList *drive.ChangeList
+		err = f.pacer.Call(func() (bool, error) {
+			changesCall := f.svc.Changes.List(pageToken).
+				Fields(fields).
+				SupportsAllDrives(true).
+				IncludeItemsFromAllDrives(true)
+			if f.opt.ListChunk > 0 {
+				changesCall.PageSize(f.opt.ListChunk)
+			}
+			if f.isTeamDrive {
+				changesCall.DriveId(f.opt.TeamDriveID)
+			}
+			changeList, err = changesCall.Context(ctx).Do()
+			return f.shouldRetry(err)
+		})
+		if err != nil {
+			return changed, err
+		}
+		for _, change := range changeList.Changes {
+			changed++
+			if change.Removed || change.File == nil || change.File.Trashed {
+				delete(c.Files, change.FileId)
+			} else {
+				c.Files[change.FileId] = change.File
+			}
+		}
+		switch {
+		case changeList.NewStartPageToken != "":
+			c.Token = changeList.NewStartPageToken
+			return changed, nil
+		case changeList.NextPageToken != "":
+			pageToken = changeList.NextPageToken
+		default:
+			return changed, errors.New("no page token returned when reading changes")
+		}
+	}
+}
+
+// isBadTokenError returns true if err means the changes token can't
+// be used any more
+func isBadTokenError(err error) bool {
+	if gerr, ok := errors.Cause(err).(*googleapi.Error); ok {
+		return gerr.Code == 400 || gerr.Code == 404 || gerr.Code == 410
+	}
+	return false
+}
+
+// updateListCache reads the list cache if necessary and brings it up
+// to date, listing the whole drive if there isn't one.
+//
+// Call with listCacheMu held.
+func (f *Fs) updateListCache(ctx context.Context) (err error) {
+	cachePath := f.listCachePath()
+	if f.listCache == nil {
+		f.listCache, err = readListCache(cachePath)
+		if err != nil {
+			fs.Errorf(f, "Ignoring list cache: %v", err)
+		}
+	}
+	if c := f.listCache; c != nil {
+		changed, err := f.applyChanges(ctx, c)
+		if err == nil {
+			fs.Debugf(f, "Applied %d changes to list cache", changed)
+			if changed == 0 {
+				return nil
+			}
+			return c.write(cachePath)
+		}
+		if !isBadTokenError(err) {
+			return errors.Wrap(err, "failed to read changes")
+		}
+		fs.Logf(f, "Rebuilding list cache as its changes token is no longer valid: %v", err)
+		f.listCache = nil
+	}
+
+	// Read the token before listing so no changes made during the
+	// listing are missed
+	token, err := f.changeNotifyStartPageToken()
+	if err != nil {
+		return errors.Wrap(err, "failed to read changes token")
+	}
+	c := &listCache{
+		Token: token,
+		Files: map[string]*drive.File{},
+	}
+	fs.Infof(f, "Building list cache - this lists the whole drive so may take some time")
+	err = f.listAllFiles(ctx, func(item *drive.File) {
+		c.Files[item.Id] = item
+	})
+	if err != nil {
+		return err
+	}
+	fs.Debugf(f, "Listed %d files into list cache", len(c.Files))
+	f.listCache = c
+	return c.write(cachePath)
+}
+
+// listCacheItem returns a copy of item from the list cache ready to
+// be made into a DirEntry or nil if it should be skipped. Shortcuts are
+// resolved from the cache if the target is in it.
+func (f *Fs) listCacheItem(c *listCache, item *drive.File) (*drive.File, error) {
+	newItem := *item
+	item = &newItem
+	item.Name = f.opt.Enc.ToStandardName(item.Name)
+	if !isShortcut(item) {
+		return item, nil
+	}
+	if f.opt.SkipShortcuts {
+		return nil, nil
+	}
+	if target, ok := c.Files[item.ShortcutDetails.TargetId]; ok {
+		newItem := *target
+		// make sure we use the Name, Parents and Trashed from the shortcut
+		newItem.Name = item.Name
+		newItem.Parents = item.Parents
+		newItem.Trashed = item.Trashed
+		newItem.Id = joinID(target.Id, item.Id)
+		return &newItem, nil
+	}
+	item, err := f.resolveShortcut(item)
+	if err != nil {
+		return nil, errors.Wrap(err, "list cache")
+	}
+	if f.opt.SkipDanglingShortcuts && item.MimeType == shortcutMimeTypeDangling {
+		fs.Debugf(item.Name, "Skipping dangling shortcut")
+		return nil, nil
+	}
+	return item, nil
+}
+
+// listRFromCache lists the directory dir with ID directoryID
+// recursively from the list cache
+func (f *Fs) listRFromCache(ctx context.Context, dir, directoryID string, callback fs.ListRCallback) error {
+	f.listCacheMu.Lock()
+	defer f.listCacheMu.Unlock()
+	err := f.updateListCache(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to update list cache")
+	}
+	c := f.listCache
+
+	children := make(map[string][]*drive.File)
+	for _, item := range c.Files {
+		for _, parent := range item.Parents {
+			children[parent] = append(children[parent], item)
+		}
+	}
+
+	type listJob struct {
+		id   string
+		path string
+	}
+	jobs := []listJob{{id: directoryID, path: dir}}
+	list := walk.NewListRHelper(callback)
+	for len(jobs) > 0 {
+		job := jobs[0]
+		jobs = jobs[1:]
+		var items []*drive.File
+		if _, found := c.Files[job.id]; found || job.id == f.rootFolderID {
+			for _, item := range children[job.id] {
+				item, err := f.listCacheItem(c, item)
+				if err != nil {
+					return err
+				}
+				if item != nil {
+					items = append(items, item)
+				}
+			}
+		} else {
+			// The folder isn't in the cache, eg the target of a
+			// shortcut in another drive, so list it directly
+			_, err := f.list(ctx, []string{job.id}, "", false, false, false, func(item *drive.File) bool {
+				items = append(items, item)
+				return false
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range items {
+			remote := path.Join(job.path, item.Name)
+			entry, err := f.itemToDirEntry(remote, item)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				continue
+			}
+			if d, isDir := entry.(*fs.Dir); isDir {
+				jobs = append(jobs, listJob{id: actualID(d.ID()), path: remote})
+			}
+			err = list.Add(entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return list.Flush()
+}
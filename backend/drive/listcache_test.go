@@ -0,0 +1,73 @@
+package drive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+)
+
+func TestListCacheReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-drive-list-cache")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	cachePath := filepath.Join(dir, "sub", "cache.json")
+
+	c, err := readListCache(cachePath)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c = &listCache{
+		Token: "123",
+		Files: map[string]*drive.File{
+			"id1": {Id: "id1", Name: "file", Size: 42, Parents: []string{"root"}},
+		},
+	}
+	require.NoError(t, c.write(cachePath))
+
+	got, err := readListCache(cachePath)
+	require.NoError(t, err)
+	assert.Equal(t, c, got)
+
+	require.NoError(t, ioutil.WriteFile(cachePath, []byte(`{"files":{}}`), 0600))
+	_, err = readListCache(cachePath)
+	assert.Error(t, err)
+}
+
+func TestListCacheItem(t *testing.T) {
+	f := new(Fs)
+	c := &listCache{
+		Files: map[string]*drive.File{
+			"target": {Id: "target", Name: "target", Size: 42, MimeType: "text/plain", Parents: []string{"a"}},
+		},
+	}
+	shortcut := &drive.File{
+		Id:              "shortcut",
+		Name:            "shortcut",
+		MimeType:        shortcutMimeType,
+		Parents:         []string{"b"},
+		ShortcutDetails: &drive.FileShortcutDetails{TargetId: "target"},
+	}
+	item, err := f.listCacheItem(c, shortcut)
+	require.NoError(t, err)
+	assert.Equal(t, joinID("target", "shortcut"), item.Id)
+	assert.Equal(t, "shortcut", item.Name)
+	assert.Equal(t, []string{"b"}, item.Parents)
+	assert.Equal(t, int64(42), item.Size)
+	assert.Equal(t, "text/plain", item.MimeType)
+
+	// The cached items must not be changed
+	assert.Equal(t, "target", c.Files["target"].Name)
+	assert.Equal(t, shortcutMimeType, shortcut.MimeType)
+
+	f.opt.SkipShortcuts = true
+	item, err = f.listCacheItem(c, shortcut)
+	require.NoError(t, err)
+	assert.Nil(t, item)
+}
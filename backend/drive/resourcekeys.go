@@ -0,0 +1,197 @@
+// Resource keys for items shared by link
+
+package drive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// resourceKeysHeader is the header resource keys are sent in
+const resourceKeysHeader = "X-Goog-Drive-Resource-Keys"
+
+// resourceKeys holds the resource keys configured and the ones seen
+// in the responses from Drive
+type resourceKeys struct {
+	mu     sync.Mutex
+	keys   map[string]string // file ID to resource key
+	header string            // the keys as the resource keys header
+}
+
+// newResourceKeys parses a comma separated list of resource keys,
+// each either fileID/resourceKey or a shared link with the resourcekey
+// parameter
+func newResourceKeys(text string) (r *resourceKeys, err error) {
+	r = &resourceKeys{
+		keys: map[string]string{},
+	}
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var id, key string
+		if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+			id, key, err = parseResourceKeyLink(item)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			i := strings.IndexRune(item, '/')
+			if i < 0 {
+				return nil, errors.Errorf("bad resource key %q: expecting fileID/resourceKey or a link", item)
+			}
+			id, key = item[:i], item[i+1:]
+		}
+		if id == "" || key == "" {
+			return nil, errors.Errorf("bad resource key %q: expecting fileID/resourceKey or a link", item)
+		}
+		r.keys[id] = key
+	}
+	r.makeHeader()
+	return r, nil
+}
+
+// parseResourceKeys parses a comma separated list of resource keys as
+// newResourceKeys does into the value of the resource keys header
+func parseResourceKeys(text string) (header string, err error) {
+	r, err := newResourceKeys(text)
+	if err != nil {
+		return "", err
+	}
+	return r.get(), nil
+}
+
+// makeHeader makes the header from the keys - call with mu held
+func (r *resourceKeys) makeHeader() {
+	var pairs []string
+	for id, key := range r.keys {
+		pairs = append(pairs, id+"/"+key)
+	}
+	sort.Strings(pairs)
+	r.header = strings.Join(pairs, ",")
+}
+
+// add records the resource key for the file ID
+func (r *resourceKeys) add(id, key string) {
+	if id == "" || key == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys[id] == key {
+		return
+	}
+	r.keys[id] = key
+	r.makeHeader()
+}
+
+// get returns the value of the resource keys header
+func (r *resourceKeys) get() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.header
+}
+
+// resourceKeyItem is a file in a response with its resource key
+type resourceKeyItem struct {
+	ID          string `json:"id"`
+	ResourceKey string `json:"resourceKey"`
+}
+
+// record adds the resource keys of the file or the list of files in
+// the JSON response from Drive in data
+func (r *resourceKeys) record(data []byte) {
+	var response struct {
+		resourceKeyItem
+		Files []resourceKeyItem `json:"files"`
+	}
+	if json.Unmarshal(data, &response) != nil {
+		return
+	}
+	r.add(response.ID, response.ResourceKey)
+	for _, item := range response.Files {
+		r.add(item.ID, item.ResourceKey)
+	}
+}
+
+// parseResourceKeyLink finds the file ID and resource key in a shared
+// link, eg
+//
+//     https://drive.google.com/drive/folders/ID?resourcekey=KEY
+//     https://drive.google.com/file/d/ID/view?resourcekey=KEY
+//     https://drive.google.com/open?id=ID&resourcekey=KEY
+func parseResourceKeyLink(link string) (id, key string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "bad resource key link %q", link)
+	}
+	query := u.Query()
+	key = query.Get("resourcekey")
+	if key == "" {
+		return "", "", errors.Errorf("no resourcekey in link %q", link)
+	}
+	id = query.Get("id")
+	if id == "" {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		for i, part := range parts {
+			if (part == "folders" || part == "d") && i+1 < len(parts) {
+				id = parts[i+1]
+				break
+			}
+		}
+	}
+	if id == "" {
+		return "", "", errors.Errorf("no file ID in link %q", link)
+	}
+	return id, key, nil
+}
+
+// resourceKeyTransport adds the resource keys to the requests to
+// Google and records the resource keys of the items in the responses
+// which asked for them
+type resourceKeyTransport struct {
+	wrapped http.RoundTripper
+	keys    *resourceKeys
+}
+
+// isGoogle returns true if the request is for Google
+func isGoogle(req *http.Request) bool {
+	host := req.URL.Hostname()
+	return host == "googleapis.com" || strings.HasSuffix(host, ".googleapis.com") ||
+		host == "google.com" || strings.HasSuffix(host, ".google.com")
+}
+
+// RoundTrip adds the resource keys header to req if it is for Google
+func (t *resourceKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isGoogle(req) {
+		return t.wrapped.RoundTrip(req)
+	}
+	if header := t.keys.get(); header != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(resourceKeysHeader, header)
+	}
+	resp, err := t.wrapped.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || !strings.Contains(req.URL.Query().Get("fields"), "resourceKey") {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	closeErr := resp.Body.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+	t.keys.record(data)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
@@ -0,0 +1,109 @@
+package drive
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResourceKeys(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"id1/key1", "id1/key1", false},
+		{" id2/key2 , id1/key1,", "id1/key1,id2/key2", false},
+		{"https://drive.google.com/drive/folders/id1?resourcekey=key1", "id1/key1", false},
+		{"https://drive.google.com/file/d/id2/view?usp=sharing&resourcekey=key2", "id2/key2", false},
+		{"https://drive.google.com/open?id=id3&resourcekey=key3", "id3/key3", false},
+		{"id1/key1,id1/key2", "id1/key2", false},
+		{"id1", "", true},
+		{"/key1", "", true},
+		{"id1/", "", true},
+		{"https://drive.google.com/drive/folders/id1", "", true},
+		{"https://drive.google.com/?resourcekey=key1", "", true},
+	} {
+		got, err := parseResourceKeys(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+			assert.Equal(t, test.want, got, test.in)
+		}
+	}
+}
+
+type recordRoundTripper struct {
+	req  *http.Request
+	body string
+}
+
+func (r *recordRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.req = req
+	w := httptest.NewRecorder()
+	_, _ = w.WriteString(r.body)
+	return w.Result(), nil
+}
+
+func TestResourceKeyTransport(t *testing.T) {
+	record := &recordRoundTripper{}
+	keys, err := newResourceKeys("id1/key1")
+	require.NoError(t, err)
+	transport := &resourceKeyTransport{wrapped: record, keys: keys}
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{"https://www.googleapis.com/drive/v3/files/id1", "id1/key1"},
+		{"https://docs.google.com/export", "id1/key1"},
+		{"https://example.com/", ""},
+		{"https://notgoogle.com/", ""},
+	} {
+		req, err := http.NewRequest("GET", test.url, nil)
+		require.NoError(t, err)
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, record.req.Header.Get(resourceKeysHeader), test.url)
+		assert.Equal(t, "", req.Header.Get(resourceKeysHeader), "original request modified")
+	}
+}
+
+func TestResourceKeyTransportRecord(t *testing.T) {
+	const body = `{"files":[{"id":"id2","resourceKey":"key2","name":"a"},{"id":"id3","name":"b"}]}`
+	record := &recordRoundTripper{body: body}
+	keys, err := newResourceKeys("")
+	require.NoError(t, err)
+	transport := &resourceKeyTransport{wrapped: record, keys: keys}
+
+	// Not recorded unless the fields ask for the resource keys
+	req, err := http.NewRequest("GET", "https://www.googleapis.com/drive/v3/files?fields=files(id)", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "", keys.get())
+	assert.Equal(t, "", record.req.Header.Get(resourceKeysHeader))
+
+	req, err = http.NewRequest("GET", "https://www.googleapis.com/drive/v3/files?fields=files(id,resourceKey)", nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+	assert.Equal(t, "id2/key2", keys.get())
+
+	// A single file is recorded too and the keys are sent
+	record.body = `{"id":"id1","resourceKey":"key1"}`
+	req, err = http.NewRequest("GET", "https://www.googleapis.com/drive/v3/files/id1?fields=id,resourceKey", nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "id2/key2", record.req.Header.Get(resourceKeysHeader))
+	assert.Equal(t, "id1/key1,id2/key2", keys.get())
+}
@@ -20,9 +20,11 @@ import (
 	"net/url"
 	"strconv"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/lib/readers"
+	"github.com/rclone/rclone/lib/resume"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 )
@@ -45,12 +47,49 @@ type resumableUpload struct {
 	MediaType string
 	// ContentLength is the full size of the object being uploaded.
 	ContentLength int64
+	// start is the offset to start uploading from
+	start int64
+	// resumer records the chunks sent, may be nil
+	resumer *resume.Upload
 	// Return value
 	ret *drive.File
 }
 
 // Upload the io.Reader in of size bytes with contentType and info
-func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType, fileID, remote string, info *drive.File) (*drive.File, error) {
+//
+// If src is set and --resume-uploads is in use then the upload
+// session is recorded so it can be resumed if rclone is interrupted.
+func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType, fileID, remote string, info *drive.File, src fs.ObjectInfo) (*drive.File, error) {
+	var resumer *resume.Upload
+	if src != nil {
+		resumer = resume.New(ctx, f, remote, src)
+	}
+	if loc, ok := resumer.Resume(int64(f.opt.ChunkSize)); ok {
+		rx := &resumableUpload{
+			f:             f,
+			remote:        remote,
+			URI:           loc,
+			Media:         in,
+			MediaType:     contentType,
+			ContentLength: size,
+			resumer:       resumer,
+		}
+		pos, err := rx.queryPosition(ctx)
+		if err == nil {
+			err = resumer.Skip(in, pos, make([]byte, int(f.opt.ChunkSize)))
+			if err != nil {
+				// the source has been read so we must retry from the start
+				resumer.Done()
+				return nil, fserrors.RetryError(err)
+			}
+			fs.Debugf(remote, "Resuming upload at offset %d", pos)
+			rx.start = pos
+			return rx.Upload(ctx)
+		}
+		fs.Debugf(remote, "Can't resume upload - starting again: %v", err)
+		resumer.Done()
+	}
+
 	params := url.Values{
 		"alt":        {"json"},
 		"uploadType": {"resumable"},
@@ -101,6 +140,7 @@ func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType,
 		return nil, err
 	}
 	loc := res.Header.Get("Location")
+	resumer.Start(loc, int64(f.opt.ChunkSize))
 	rx := &resumableUpload{
 		f:             f,
 		remote:        remote,
@@ -108,10 +148,43 @@ func (f *Fs) Upload(ctx context.Context, in io.Reader, size int64, contentType,
 		Media:         in,
 		MediaType:     contentType,
 		ContentLength: size,
+		resumer:       resumer,
 	}
 	return rx.Upload(ctx)
 }
 
+// queryPosition asks the server how many bytes of the upload it has
+// received so far
+func (rx *resumableUpload) queryPosition(ctx context.Context) (pos int64, err error) {
+	err = rx.f.pacer.Call(func() (bool, error) {
+		req := rx.makeRequest(ctx, 0, nil, 0)
+		res, err := rx.f.client.Do(req)
+		if err != nil {
+			return rx.f.shouldRetry(err)
+		}
+		defer googleapi.CloseBody(res)
+		if res.StatusCode != statusResumeIncomplete {
+			err = googleapi.CheckResponse(res)
+			if err != nil {
+				return rx.f.shouldRetry(err)
+			}
+			return false, errors.Errorf("upload already finished (status %d)", res.StatusCode)
+		}
+		// The Range header is missing if nothing has been received
+		pos = 0
+		if byteRange := res.Header.Get("Range"); byteRange != "" {
+			var end int64
+			_, err = fmt.Sscanf(byteRange, "bytes=0-%d", &end)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to parse Range %q", byteRange)
+			}
+			pos = end + 1
+		}
+		return false, nil
+	})
+	return pos, err
+}
+
 // Make an http.Request for the range passed in
 func (rx *resumableUpload) makeRequest(ctx context.Context, start int64, body io.ReadSeeker, reqSize int64) *http.Request {
 	req, _ := http.NewRequest("POST", rx.URI, body)
@@ -167,7 +240,7 @@ func (rx *resumableUpload) transferChunk(ctx context.Context, start int64, chunk
 // Upload uploads the chunks from the input
 // It retries each chunk using the pacer and --low-level-retries
 func (rx *resumableUpload) Upload(ctx context.Context) (*drive.File, error) {
-	start := int64(0)
+	start := rx.start
 	var StatusCode int
 	var err error
 	buf := make([]byte, int(rx.f.opt.ChunkSize))
@@ -214,6 +287,9 @@ func (rx *resumableUpload) Upload(ctx context.Context) (*drive.File, error) {
 		if err != nil {
 			return nil, err
 		}
+		if rx.ret == nil {
+			rx.resumer.AddPart(start/int64(rx.f.opt.ChunkSize)+1, start, buf[:reqSize], "")
+		}
 
 		start += reqSize
 	}
@@ -240,5 +316,6 @@ func (rx *resumableUpload) Upload(ctx context.Context) (*drive.File, error) {
 	if rx.ret == nil {
 		return nil, fserrors.RetryErrorf("Incomplete upload - retry, last error %d", StatusCode)
 	}
+	rx.resumer.Done()
 	return rx.ret, nil
 }
@@ -0,0 +1,292 @@
+// Committing uploads in batches with upload_session/finish_batch
+
+package dropbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/async"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+const (
+	maxBatchSize          = 1000                   // the most entries upload_session/finish_batch accepts
+	defaultTimeoutSync    = 500 * time.Millisecond // kick off the batch this long after the last upload in sync mode
+	defaultTimeoutAsync   = 10 * time.Second       // kick off the batch this long after the last upload in async mode
+	defaultBatchSizeAsync = 100                    // default batch size in async mode
+	minCheckSleep         = 100 * time.Millisecond // first wait before checking an async batch
+	maxCheckSleep         = 10 * time.Second       // longest wait between checks of an async batch
+)
+
+// batcherRequest is an upload waiting to be committed
+type batcherRequest struct {
+	commitInfo *files.UploadSessionFinishArg
+	result     chan<- batcherResponse // nil in async mode
+}
+
+// batcherResponse is the result of committing an upload
+type batcherResponse struct {
+	entry *files.FileMetadata
+	err   error
+}
+
+// batcher commits finished upload sessions in batches
+type batcher struct {
+	f        *Fs                 // Fs this batch is part of
+	mode     string              // configured batch mode
+	size     int                 // maximum size for batch
+	timeout  time.Duration       // idle timeout for batch
+	async    bool                // whether we are using async batching
+	in       chan batcherRequest // incoming items to batch
+	quit     chan struct{}       // close to shut down the batcher
+	closed   chan struct{}       // closed when the batcher has shut down
+	shutOnce sync.Once           // make sure we shutdown once only
+}
+
+// newBatcher creates a new batcher structure
+func newBatcher(ctx context.Context, f *Fs, mode string, size int, timeout time.Duration) (*batcher, error) {
+	if size < 0 {
+		return nil, errors.New("dropbox: batch size must be >= 0")
+	}
+	if size > maxBatchSize {
+		return nil, errors.Errorf("dropbox: batch size must be <= %d", maxBatchSize)
+	}
+	async := false
+
+	switch mode {
+	case "sync":
+		if size <= 0 {
+			size = fs.Config.Transfers
+		}
+		if timeout <= 0 {
+			timeout = defaultTimeoutSync
+		}
+	case "async":
+		if size <= 0 {
+			size = defaultBatchSizeAsync
+		}
+		if timeout <= 0 {
+			timeout = defaultTimeoutAsync
+		}
+		async = true
+	case "off":
+		size = 0
+	default:
+		return nil, errors.Errorf("dropbox: batch mode must be sync|async|off not %q", mode)
+	}
+
+	b := &batcher{
+		f:       f,
+		mode:    mode,
+		size:    size,
+		timeout: timeout,
+		async:   async,
+		in:      make(chan batcherRequest),
+		quit:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	if b.Batching() {
+		atexit.Register(b.Shutdown)
+		go b.commitLoop(ctx)
+	}
+	return b, nil
+}
+
+// Batching returns true if batching is active
+func (b *batcher) Batching() bool {
+	return b.size > 0
+}
+
+// finishErrorString describes why an upload in a batch failed
+func finishErrorString(e *files.UploadSessionFinishError) string {
+	switch {
+	case e.LookupFailed != nil:
+		return e.Tag + "/" + e.LookupFailed.Tag
+	case e.Path != nil:
+		return e.Tag + "/" + e.Path.Tag
+	}
+	return e.Tag
+}
+
+// finishBatch commits the batch, waiting for the job to finish if it
+// runs asynchronously
+func (b *batcher) finishBatch(ctx context.Context, items []*files.UploadSessionFinishArg) (result *files.UploadSessionFinishBatchResult, err error) {
+	arg := files.NewUploadSessionFinishBatchArg(items)
+	var launch *files.UploadSessionFinishBatchLaunch
+	err = b.f.pacer.Call(func() (bool, error) {
+		launch, err = b.f.srv.UploadSessionFinishBatch(arg)
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finish_batch failed")
+	}
+	switch launch.Tag {
+	case files.UploadSessionFinishBatchLaunchComplete:
+		return launch.Complete, nil
+	case files.UploadSessionFinishBatchLaunchAsyncJobId:
+	default:
+		return nil, errors.Errorf("finish_batch returned unknown status %q", launch.Tag)
+	}
+	poll := async.PollArg{AsyncJobId: launch.AsyncJobId}
+	sleepTime := minCheckSleep
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sleepTime):
+		}
+		var status *files.UploadSessionFinishBatchJobStatus
+		err = b.f.pacer.Call(func() (bool, error) {
+			status, err = b.f.srv.UploadSessionFinishBatchCheck(&poll)
+			return shouldRetry(err)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "finish_batch check failed")
+		}
+		switch status.Tag {
+		case files.UploadSessionFinishBatchJobStatusComplete:
+			return status.Complete, nil
+		case files.UploadSessionFinishBatchJobStatusInProgress:
+		default:
+			return nil, errors.Errorf("finish_batch returned unknown status %q", status.Tag)
+		}
+		fs.Debugf(b.f, "Waiting for finish_batch of %d files to finish", len(items))
+		sleepTime *= 2
+		if sleepTime > maxCheckSleep {
+			sleepTime = maxCheckSleep
+		}
+	}
+}
+
+// commitBatch commits a batch and sends the results to the waiting
+// uploads
+func (b *batcher) commitBatch(ctx context.Context, reqs []batcherRequest) {
+	items := make([]*files.UploadSessionFinishArg, len(reqs))
+	for i := range reqs {
+		items[i] = reqs[i].commitInfo
+	}
+	fs.Debugf(b.f, "Committing %s batch of %d files", b.mode, len(items))
+	result, err := b.finishBatch(ctx, items)
+	if err == nil && len(result.Entries) != len(items) {
+		err = errors.Errorf("finish_batch returned %d entries for %d files", len(result.Entries), len(items))
+	}
+	failed := 0
+	for i, req := range reqs {
+		var resp batcherResponse
+		if err != nil {
+			resp.err = err
+		} else if entry := result.Entries[i]; entry.Failure != nil {
+			resp.err = errors.Errorf("upload failed: %s", finishErrorString(entry.Failure))
+		} else {
+			resp.entry = entry.Success
+		}
+		if resp.err != nil {
+			failed++
+			if req.result == nil {
+				fs.Errorf(req.commitInfo.Commit.Path, "Failed to commit upload: %v", resp.err)
+			}
+		}
+		if req.result != nil {
+			req.result <- resp
+		}
+	}
+	if failed > 0 {
+		fs.Errorf(b.f, "Committed %s batch of %d files with %d failures", b.mode, len(items), failed)
+	} else {
+		fs.Debugf(b.f, "Committed %s batch of %d files", b.mode, len(items))
+	}
+}
+
+// commitLoop runs the commit engine in the background
+func (b *batcher) commitLoop(ctx context.Context) {
+	var (
+		reqs      []batcherRequest
+		idleTimer = time.NewTimer(b.timeout)
+	)
+	defer close(b.closed)
+	defer idleTimer.Stop()
+	idleTimer.Stop()
+
+	commit := func() {
+		b.commitBatch(ctx, reqs)
+		reqs = nil
+	}
+
+	for {
+		select {
+		case req := <-b.in:
+			reqs = append(reqs, req)
+			if !idleTimer.Stop() {
+				select {
+				case <-idleTimer.C:
+				default:
+				}
+			}
+			if len(reqs) >= b.size {
+				commit()
+			} else {
+				idleTimer.Reset(b.timeout)
+			}
+		case <-idleTimer.C:
+			if len(reqs) > 0 {
+				commit()
+			}
+		case <-b.quit:
+			if len(reqs) > 0 {
+				commit()
+			}
+			return
+		}
+	}
+}
+
+// Shutdown finishes any pending batches then shuts everything down
+//
+// Can be called from atexit handler
+func (b *batcher) Shutdown() {
+	if !b.Batching() {
+		return
+	}
+	b.shutOnce.Do(func() {
+		fs.Infof(b.f, "Committing uploads - please wait...")
+		close(b.quit)
+		<-b.closed
+	})
+}
+
+// Commit adds the finished upload session to the batch and, in sync
+// mode, waits for the batch to be committed.
+//
+// In async mode it returns a nil entry as soon as the upload has been
+// queued.
+func (b *batcher) Commit(ctx context.Context, commitInfo *files.UploadSessionFinishArg) (entry *files.FileMetadata, err error) {
+	var result chan batcherResponse
+	req := batcherRequest{commitInfo: commitInfo}
+	if !b.async {
+		result = make(chan batcherResponse, 1)
+		req.result = result
+	}
+	fs.Debugf(b.f, "Adding %q to %s batch", commitInfo.Commit.Path, b.mode)
+	select {
+	case b.in <- req:
+	case <-b.quit:
+		return nil, fserrors.FatalError(errors.New("can't upload as batcher is shutting down"))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if b.async {
+		return nil, nil
+	}
+	select {
+	case resp := <-result:
+		return resp.entry, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package dropbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBatcher(t *testing.T) {
+	ctx := context.Background()
+	f := &Fs{}
+	for _, test := range []struct {
+		mode        string
+		size        int
+		timeout     time.Duration
+		wantSize    int
+		wantTimeout time.Duration
+		wantAsync   bool
+		wantErr     bool
+	}{
+		{mode: "off", size: 10, wantSize: 0},
+		{mode: "sync", wantSize: fs.Config.Transfers, wantTimeout: defaultTimeoutSync},
+		{mode: "sync", size: 50, timeout: time.Second, wantSize: 50, wantTimeout: time.Second},
+		{mode: "async", wantSize: defaultBatchSizeAsync, wantTimeout: defaultTimeoutAsync, wantAsync: true},
+		{mode: "potato", wantErr: true},
+		{mode: "sync", size: -1, wantErr: true},
+		{mode: "sync", size: maxBatchSize + 1, wantErr: true},
+	} {
+		what := test.mode
+		b, err := newBatcher(ctx, f, test.mode, test.size, test.timeout)
+		if test.wantErr {
+			assert.Error(t, err, what)
+			continue
+		}
+		require.NoError(t, err, what)
+		assert.Equal(t, test.wantSize, b.size, what)
+		assert.Equal(t, test.wantSize > 0, b.Batching(), what)
+		if b.Batching() {
+			assert.Equal(t, test.wantTimeout, b.timeout, what)
+			assert.Equal(t, test.wantAsync, b.async, what)
+		}
+		b.Shutdown()
+	}
+}
+
+func TestFinishErrorString(t *testing.T) {
+	e := &files.UploadSessionFinishError{Tagged: dropbox.Tagged{Tag: "too_many_write_operations"}}
+	assert.Equal(t, "too_many_write_operations", finishErrorString(e))
+	e = &files.UploadSessionFinishError{
+		Tagged: dropbox.Tagged{Tag: "path"},
+		Path:   &files.WriteError{Tagged: dropbox.Tagged{Tag: "insufficient_space"}},
+	}
+	assert.Equal(t, "path/insufficient_space", finishErrorString(e))
+	e = &files.UploadSessionFinishError{
+		Tagged:       dropbox.Tagged{Tag: "lookup_failed"},
+		LookupFailed: &files.UploadSessionLookupError{Tagged: dropbox.Tagged{Tag: "not_found"}},
+	}
+	assert.Equal(t, "lookup_failed/not_found", finishErrorString(e))
+}
@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/async"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/auth"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/common"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
@@ -51,6 +52,7 @@ import (
 	"github.com/rclone/rclone/lib/oauthutil"
 	"github.com/rclone/rclone/lib/pacer"
 	"github.com/rclone/rclone/lib/readers"
+	"github.com/rclone/rclone/lib/rest"
 	"golang.org/x/oauth2"
 )
 
@@ -142,6 +144,89 @@ memory.  It can be set smaller if you are tight on memory.`, maxChunkSize),
 			Help:     "Impersonate this user when using a business account.",
 			Default:  "",
 			Advanced: true,
+		}, {
+			Name: "export_formats",
+			Help: `Comma separated list of preferred formats for exporting documents.
+
+Paper docs and other cloud documents can't be downloaded directly so
+rclone exports them in the first format in this list they support.
+Paper docs support all the formats, other documents only the format
+Dropbox says they export as.
+
+The formats are
+
+- html - HTML
+- md - Markdown
+- txt - Plain text
+
+Exported documents are listed with the extension of the format
+replacing their own and with an unknown size, and can't be uploaded
+to.
+`,
+			Default:  fs.CommaSepList{"html", "md"},
+			Advanced: true,
+		}, {
+			Name:     "skip_exports",
+			Help:     "Skip exportable files in all listings.\n\nIf given, Paper docs and other documents which can only be exported\nwill not be listed at all.",
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "batch_mode",
+			Help: `Upload file batching sync|async|off.
+
+This sets the batch mode used by rclone.
+
+For full info see [the main docs](https://rclone.org/dropbox/#batch-mode)
+
+This has 3 possible values
+
+- off - no batching
+- sync - batch uploads and check completion (default)
+- async - batch upload and don't check completion
+
+Rclone will close any outstanding batches when it exits which may make
+a delay on quit.
+`,
+			Default:  "sync",
+			Advanced: true,
+		}, {
+			Name: "batch_size",
+			Help: `Max number of files in upload batch.
+
+This sets the batch size of files to upload. It can be at most 1000.
+
+By default this is 0 which means rclone will calculate the batch size
+depending on the setting of batch_mode.
+
+- batch_mode: async - default batch_size is 100
+- batch_mode: sync - default batch_size is the same as --transfers
+- batch_mode: off - not in use
+
+Rclone will close any outstanding batches when it exits which may make
+a delay on quit.
+
+Setting this is a great idea if you are uploading lots of small files
+as it will make them a lot quicker. You can use --transfers 32 to
+maximise throughput.
+`,
+			Default:  0,
+			Advanced: true,
+		}, {
+			Name: "batch_timeout",
+			Help: `Max time to allow an idle upload batch before uploading.
+
+If an upload batch is idle for more than this long then it will be
+uploaded.
+
+The default for this is 0 which means rclone will choose a sensible
+default based on the batch_mode in use.
+
+- batch_mode: async - default batch_timeout is 10s
+- batch_mode: sync - default batch_timeout is 500ms
+- batch_mode: off - not in use
+`,
+			Default:  fs.Duration(0),
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -161,9 +246,14 @@ memory.  It can be set smaller if you are tight on memory.`, maxChunkSize),
 
 // Options defines the configuration for this backend
 type Options struct {
-	ChunkSize   fs.SizeSuffix        `config:"chunk_size"`
-	Impersonate string               `config:"impersonate"`
-	Enc         encoder.MultiEncoder `config:"encoding"`
+	ChunkSize     fs.SizeSuffix        `config:"chunk_size"`
+	Impersonate   string               `config:"impersonate"`
+	ExportFormats fs.CommaSepList      `config:"export_formats"`
+	SkipExports   bool                 `config:"skip_exports"`
+	BatchMode     string               `config:"batch_mode"`
+	BatchSize     int                  `config:"batch_size"`
+	BatchTimeout  fs.Duration          `config:"batch_timeout"`
+	Enc           encoder.MultiEncoder `config:"encoding"`
 }
 
 // Fs represents a remote dropbox server
@@ -180,17 +270,22 @@ type Fs struct {
 	slashRootSlash string         // root with "/" prefix and postfix, lowercase
 	pacer          *fs.Pacer      // To pace the API calls
 	ns             string         // The namespace we are using or "" for none
+	memberID       string         // The team member we are impersonating or "" for none
+	exportSrv      *rest.Client   // for exporting documents which the SDK can't do
+	batcher        *batcher       // batch builder
 }
 
 // Object describes a dropbox object
 //
 // Dropbox Objects always have full metadata
 type Object struct {
-	fs      *Fs       // what this object is part of
-	remote  string    // The remote path
-	bytes   int64     // size of the object
-	modTime time.Time // time it was last modified
-	hash    string    // content_hash of the object
+	fs              *Fs       // what this object is part of
+	remote          string    // The remote path
+	bytes           int64     // size of the object
+	modTime         time.Time // time it was last modified
+	hash            string    // content_hash of the object
+	exportPath      string    // path of the document if this is an export
+	exportAPIFormat string    // format to export the document as or "" if not an export
 }
 
 // ------------------------------------------------------------
@@ -273,6 +368,10 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "dropbox: chunk size")
 	}
+	err = checkExportFormats(opt.ExportFormats)
+	if err != nil {
+		return nil, errors.Wrap(err, "dropbox: export formats")
+	}
 
 	// Convert the old token if it exists.  The old token was just
 	// just a string, the new one is a JSON blob
@@ -293,9 +392,14 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	}
 
 	f := &Fs{
-		name:  name,
-		opt:   *opt,
-		pacer: fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+		name:      name,
+		opt:       *opt,
+		exportSrv: rest.NewClient(oAuthClient).SetErrorHandler(exportErrorHandler),
+		pacer:     fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+	}
+	f.batcher, err = newBatcher(context.Background(), f, f.opt.BatchMode, f.opt.BatchSize, time.Duration(f.opt.BatchTimeout))
+	if err != nil {
+		return nil, err
 	}
 	config := dropbox.Config{
 		LogLevel:        dropbox.LogOff, // logging in the SDK: LogOff, LogDebug, LogInfo
@@ -323,6 +427,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		}
 
 		config.AsMemberID = memberIds[0].MemberInfo.Profile.MemberProfile.TeamMemberId
+		f.memberID = config.AsMemberID
 	}
 
 	f.srv = files.New(config)
@@ -451,11 +556,18 @@ func (f *Fs) newObjectWithInfo(remote string, info *files.FileMetadata) (fs.Obje
 		remote: remote,
 	}
 	var err error
-	if info != nil {
-		err = o.setMetadataFromEntry(info)
-	} else {
-		err = o.readEntryAndSetMetadata()
+	if info == nil {
+		info, err = o.readEntry()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Documents which can only be exported are found by their
+	// export name
+	if isExportable(info) {
+		return nil, fs.ErrorObjectNotFound
 	}
+	err = o.setMetadataFromEntry(info)
 	if err != nil {
 		return nil, err
 	}
@@ -465,7 +577,11 @@ func (f *Fs) newObjectWithInfo(remote string, info *files.FileMetadata) (fs.Obje
 // NewObject finds the Object at remote.  If it can't be found
 // it returns the error fs.ErrorObjectNotFound.
 func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
-	return f.newObjectWithInfo(remote, nil)
+	o, err := f.newObjectWithInfo(remote, nil)
+	if err == fs.ErrorObjectNotFound {
+		return f.findExportObject(ctx, remote)
+	}
+	return o, err
 }
 
 // List the objects and directories in dir into entries.  The
@@ -543,6 +659,10 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 			if folderInfo != nil {
 				d := fs.NewDir(remote, time.Now())
 				entries = append(entries, d)
+			} else if fileInfo != nil && isExportable(fileInfo) {
+				if o := f.newExportObject(dir, leaf, fileInfo); o != nil {
+					entries = append(entries, o)
+				}
 			} else if fileInfo != nil {
 				o, err := f.newObjectWithInfo(remote, fileInfo)
 				if err != nil {
@@ -679,6 +799,10 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 		fs.Debugf(src, "Can't copy - not same remote type")
 		return nil, fs.ErrorCantCopy
 	}
+	if srcObj.exportAPIFormat != "" {
+		fs.Debugf(src, "Can't copy - exported document")
+		return nil, fs.ErrorCantCopy
+	}
 
 	// Temporary Object under construction
 	dstObj := &Object{
@@ -740,6 +864,10 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 		fs.Debugf(src, "Can't move - not same remote type")
 		return nil, fs.ErrorCantMove
 	}
+	if srcObj.exportAPIFormat != "" {
+		fs.Debugf(src, "Can't move - exported document")
+		return nil, fs.ErrorCantMove
+	}
 
 	// Temporary Object under construction
 	dstObj := &Object{
@@ -974,6 +1102,9 @@ func (o *Object) readEntryAndSetMetadata() error {
 
 // Returns the remote path for the object
 func (o *Object) remotePath() string {
+	if o.exportPath != "" {
+		return o.exportPath
+	}
 	return o.fs.slashRootSlash + o.remote
 }
 
@@ -1016,6 +1147,9 @@ func (o *Object) Storable() bool {
 
 // Open an object for read
 func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	if o.exportAPIFormat != "" {
+		return o.openExport(ctx, options...)
+	}
 	fs.FixRangeOption(options, o.bytes)
 	headers := fs.OpenOptionHeaders(options)
 	arg := files.DownloadArg{
@@ -1043,12 +1177,20 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 // Will work optimally if size is >= uploadChunkSize. If the size is either
 // unknown (i.e. -1) or smaller than uploadChunkSize, the method incurs an
 // avoidable request to the Dropbox API that does not carry payload.
-func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size int64) (entry *files.FileMetadata, err error) {
+//
+// If batching is in use the session is closed and committed by the
+// batcher instead of with UploadSessionFinish. In async mode the entry
+// returned is made up from what was uploaded.
+func (o *Object) uploadChunked(ctx context.Context, in0 io.Reader, commitInfo *files.CommitInfo, size int64) (entry *files.FileMetadata, err error) {
 	chunkSize := int64(o.fs.opt.ChunkSize)
 	chunks := 0
 	if size != -1 {
 		chunks = int(size/chunkSize) + 1
 	}
+	batching := o.fs.batcher.Batching()
+	// If the whole file fits in the first chunk the session can be
+	// closed straight away ready for the batch
+	closeFirst := batching && size >= 0 && size <= chunkSize
 	in := readers.NewCountingReader(in0)
 	buf := make([]byte, int(chunkSize))
 
@@ -1071,7 +1213,7 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 		if _, err = chunk.Seek(0, io.SeekStart); err != nil {
 			return false, nil
 		}
-		res, err = o.fs.srv.UploadSessionStart(&files.UploadSessionStartArg{}, chunk)
+		res, err = o.fs.srv.UploadSessionStart(&files.UploadSessionStartArg{Close: closeFirst}, chunk)
 		return shouldRetry(err)
 	})
 	if err != nil {
@@ -1089,7 +1231,7 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 
 	// write more whole chunks (if any)
 	currentChunk := 2
-	for {
+	for !closeFirst {
 		if chunks > 0 && currentChunk >= chunks {
 			// if the size is known, only upload full chunks. Remaining bytes are uploaded with
 			// the UploadSessionFinish request.
@@ -1117,6 +1259,44 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 		currentChunk++
 	}
 
+	if batching {
+		// write the remains closing the session
+		if !closeFirst {
+			cursor.Offset = in.BytesRead()
+			appendArg.Close = true
+			fmtChunk(currentChunk, true)
+			chunk = readers.NewRepeatableReaderBuffer(in, buf)
+			err = o.fs.pacer.Call(func() (bool, error) {
+				// seek to the start in case this is a retry
+				if _, err = chunk.Seek(0, io.SeekStart); err != nil {
+					return false, nil
+				}
+				err = o.fs.srv.UploadSessionAppendV2(&appendArg, chunk)
+				// after the first chunk is uploaded, we retry everything
+				return err != nil, err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		cursor.Offset = in.BytesRead()
+		entry, err = o.fs.batcher.Commit(ctx, &files.UploadSessionFinishArg{
+			Cursor: &cursor,
+			Commit: commitInfo,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			// async mode so the upload hasn't been committed yet
+			entry = &files.FileMetadata{
+				Size:           in.BytesRead(),
+				ClientModified: commitInfo.ClientModified,
+			}
+		}
+		return entry, nil
+	}
+
 	// write the remains
 	cursor.Offset = in.BytesRead()
 	args := &files.UploadSessionFinishArg{
@@ -1153,6 +1333,9 @@ func (o *Object) uploadChunked(in0 io.Reader, commitInfo *files.CommitInfo, size
 //
 // The new object may have been created if an error is returned
 func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	if o.exportAPIFormat != "" {
+		return fserrors.NoRetryError(errors.Errorf("can't upload to exported document %q", o.remote))
+	}
 	remote := o.remotePath()
 	if ignoredFiles.MatchString(remote) {
 		return fserrors.NoRetryError(errors.Errorf("file name %q is disallowed - not uploading", path.Base(remote)))
@@ -1165,8 +1348,8 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	size := src.Size()
 	var err error
 	var entry *files.FileMetadata
-	if size > int64(o.fs.opt.ChunkSize) || size == -1 {
-		entry, err = o.uploadChunked(in, commitInfo, size)
+	if size > int64(o.fs.opt.ChunkSize) || size == -1 || o.fs.batcher.Batching() {
+		entry, err = o.uploadChunked(ctx, in, commitInfo, size)
 	} else {
 		err = o.fs.pacer.CallNoRetry(func() (bool, error) {
 			entry, err = o.fs.srv.Upload(commitInfo, in)
@@ -1190,6 +1373,105 @@ func (o *Object) Remove(ctx context.Context) (err error) {
 	return err
 }
 
+// maxDeleteBatch is the most entries delete_batch accepts at once
+const maxDeleteBatch = 1000
+
+// DeleteBatch deletes the objects passed in using delete_batch with up
+// to 1000 objects in each call
+func (f *Fs) DeleteBatch(ctx context.Context, objs []fs.Object) []error {
+	errs := make([]error, len(objs))
+	for start := 0; start < len(objs); start += maxDeleteBatch {
+		end := start + maxDeleteBatch
+		if end > len(objs) {
+			end = len(objs)
+		}
+		result, err := f.deleteBatch(ctx, objs[start:end])
+		for i := start; i < end; i++ {
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			if i-start >= len(result.Entries) {
+				errs[i] = errors.New("delete_batch returned too few entries")
+				continue
+			}
+			if failure := result.Entries[i-start].Failure; failure != nil {
+				errs[i] = errors.Errorf("delete failed: %s", deleteErrorString(failure))
+			}
+		}
+	}
+	return errs
+}
+
+// deleteBatch deletes objs in a single delete_batch call waiting for
+// the job to finish if it runs asynchronously
+func (f *Fs) deleteBatch(ctx context.Context, objs []fs.Object) (result *files.DeleteBatchResult, err error) {
+	arg := files.DeleteBatchArg{}
+	for _, obj := range objs {
+		o, ok := obj.(*Object)
+		if !ok {
+			return nil, errors.Errorf("can't batch delete %T", obj)
+		}
+		arg.Entries = append(arg.Entries, files.NewDeleteArg(f.opt.Enc.FromStandardPath(o.remotePath())))
+	}
+	var launch *files.DeleteBatchLaunch
+	err = f.pacer.Call(func() (bool, error) {
+		launch, err = f.srv.DeleteBatch(&arg)
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "delete_batch failed")
+	}
+	switch launch.Tag {
+	case files.DeleteBatchLaunchComplete:
+		return launch.Complete, nil
+	case files.DeleteBatchLaunchAsyncJobId:
+	default:
+		return nil, errors.Errorf("delete_batch returned unknown status %q", launch.Tag)
+	}
+	poll := async.PollArg{AsyncJobId: launch.AsyncJobId}
+	for {
+		var status *files.DeleteBatchJobStatus
+		err = f.pacer.Call(func() (bool, error) {
+			status, err = f.srv.DeleteBatchCheck(&poll)
+			return shouldRetry(err)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "delete_batch check failed")
+		}
+		switch status.Tag {
+		case files.DeleteBatchJobStatusComplete:
+			return status.Complete, nil
+		case files.DeleteBatchJobStatusFailed:
+			reason := "unknown reason"
+			if status.Failed != nil {
+				reason = status.Failed.Tag
+			}
+			return nil, errors.Errorf("delete_batch failed: %s", reason)
+		case files.DeleteBatchJobStatusInProgress:
+		default:
+			return nil, errors.Errorf("delete_batch returned unknown status %q", status.Tag)
+		}
+		fs.Debugf(f, "Waiting for delete_batch of %d files to finish", len(objs))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// deleteErrorString describes why a delete in a batch failed
+func deleteErrorString(e *files.DeleteError) string {
+	switch {
+	case e.PathLookup != nil:
+		return e.Tag + "/" + e.PathLookup.Tag
+	case e.PathWrite != nil:
+		return e.Tag + "/" + e.PathWrite.Tag
+	}
+	return e.Tag
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs           = (*Fs)(nil)
@@ -1200,5 +1482,6 @@ var (
 	_ fs.PublicLinker = (*Fs)(nil)
 	_ fs.DirMover     = (*Fs)(nil)
 	_ fs.Abouter      = (*Fs)(nil)
+	_ fs.BatchDeleter = (*Fs)(nil)
 	_ fs.Object       = (*Object)(nil)
 )
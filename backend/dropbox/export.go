@@ -0,0 +1,194 @@
+// Exporting Paper and other cloud documents
+
+package dropbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+// exportURL is the endpoint for files/export
+//
+// This is called directly as the SDK doesn't support choosing the
+// export format.
+const exportURL = "https://content.dropboxapi.com/2/files/export"
+
+// exportAPIFormats maps the extensions which can be used in
+// export_formats to the format names the Dropbox API uses
+var exportAPIFormats = map[string]string{
+	"html": "html",
+	"md":   "markdown",
+	"txt":  "plain_text",
+}
+
+// paperExtension is the extension Dropbox gives Paper docs
+const paperExtension = ".paper"
+
+// exportArg is the argument to files/export
+type exportArg struct {
+	Path         string `json:"path"`
+	ExportFormat string `json:"export_format,omitempty"`
+}
+
+// exportErrorResponse is returned by files/export on error
+type exportErrorResponse struct {
+	ErrorSummary string `json:"error_summary"`
+}
+
+// checkExportFormats checks the extensions in export_formats are
+// known
+func checkExportFormats(exts fs.CommaSepList) error {
+	for _, ext := range exts {
+		if _, ok := exportAPIFormats[strings.ToLower(ext)]; !ok {
+			return errors.Errorf("unknown export format %q - expecting one of html, md or txt", ext)
+		}
+	}
+	return nil
+}
+
+// isExportable returns true if info is a document which can't be
+// downloaded but can be exported
+func isExportable(info *files.FileMetadata) bool {
+	return !info.IsDownloadable && info.ExportInfo != nil
+}
+
+// findExportFormat returns the extension and API format from
+// export_formats to export the document called leaf as, or ok false
+// if it can't be exported as any of them.
+//
+// Paper docs can be exported as any of the formats, other documents
+// only as the format Dropbox says they export as.
+func (f *Fs) findExportFormat(leaf string, info *files.FileMetadata) (ext, apiFormat string, ok bool) {
+	isPaper := strings.EqualFold(path.Ext(leaf), paperExtension)
+	for _, ext := range f.opt.ExportFormats {
+		ext = strings.ToLower(ext)
+		apiFormat := exportAPIFormats[ext]
+		if isPaper || apiFormat == info.ExportInfo.ExportAs || ext == info.ExportInfo.ExportAs {
+			return ext, apiFormat, true
+		}
+	}
+	return "", "", false
+}
+
+// exportName returns the name leaf is listed as when exported as ext
+func exportName(leaf, ext string) string {
+	return strings.TrimSuffix(leaf, path.Ext(leaf)) + "." + ext
+}
+
+// newExportObject makes an Object for the document info called leaf
+// in dir which will be exported. It returns nil if the document
+// should be skipped.
+func (f *Fs) newExportObject(dir, leaf string, info *files.FileMetadata) *Object {
+	if f.opt.SkipExports {
+		return nil
+	}
+	ext, apiFormat, ok := f.findExportFormat(leaf, info)
+	if !ok {
+		fs.Debugf(path.Join(dir, leaf), "Skipping document as it can't be exported as any of %v", f.opt.ExportFormats)
+		return nil
+	}
+	return &Object{
+		fs:              f,
+		remote:          path.Join(dir, exportName(leaf, ext)),
+		bytes:           -1,
+		modTime:         info.ClientModified,
+		exportPath:      f.slashRootSlash + path.Join(dir, leaf),
+		exportAPIFormat: apiFormat,
+	}
+}
+
+// findExportObject finds the document which is exported as remote
+// by listing its directory
+func (f *Fs) findExportObject(ctx context.Context, remote string) (fs.Object, error) {
+	if f.opt.SkipExports {
+		return nil, fs.ErrorObjectNotFound
+	}
+	ext := strings.TrimPrefix(path.Ext(remote), ".")
+	if _, ok := exportAPIFormats[strings.ToLower(ext)]; !ok {
+		return nil, fs.ErrorObjectNotFound
+	}
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	entries, err := f.List(ctx, dir)
+	if err == fs.ErrorDirNotFound {
+		return nil, fs.ErrorObjectNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if o, ok := entry.(*Object); ok && o.exportAPIFormat != "" && o.remote == remote {
+			return o, nil
+		}
+	}
+	return nil, fs.ErrorObjectNotFound
+}
+
+// exportErrorHandler parses a non 2xx response from files/export
+func exportErrorHandler(resp *http.Response) error {
+	body, err := rest.ReadBody(resp)
+	if err != nil {
+		return errors.Wrap(err, "error reading error out of body")
+	}
+	var errResponse exportErrorResponse
+	if json.Unmarshal(body, &errResponse) == nil && errResponse.ErrorSummary != "" {
+		return errors.Errorf("export failed: %s (HTTP %d)", errResponse.ErrorSummary, resp.StatusCode)
+	}
+	return errors.Errorf("export failed: HTTP error %v (%v) returned body: %q", resp.StatusCode, resp.Status, body)
+}
+
+// export opens the document at objPath exported as apiFormat
+func (f *Fs) export(ctx context.Context, objPath, apiFormat string) (in io.ReadCloser, err error) {
+	arg, err := json.Marshal(exportArg{
+		Path:         f.opt.Enc.FromStandardPath(objPath),
+		ExportFormat: apiFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+	headers := f.headerGenerator("content", "download", "files", "export")
+	headers["Dropbox-API-Arg"] = dropbox.HTTPHeaderSafeJSON(arg)
+	if f.memberID != "" {
+		headers["Dropbox-API-Select-User"] = f.memberID
+	}
+	opts := rest.Opts{
+		Method:       "POST",
+		RootURL:      exportURL,
+		ExtraHeaders: headers,
+	}
+	var resp *http.Response
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.exportSrv.Call(ctx, &opts)
+		if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+			return true, err
+		}
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// openExport opens the exported document
+func (o *Object) openExport(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	for _, option := range options {
+		switch option.(type) {
+		case *fs.RangeOption, *fs.SeekOption:
+			return nil, fserrors.NoRetryError(errors.New("can't read part of an exported document"))
+		}
+	}
+	return o.fs.export(ctx, o.exportPath, o.exportAPIFormat)
+}
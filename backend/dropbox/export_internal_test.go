@@ -0,0 +1,57 @@
+package dropbox
+
+import (
+	"testing"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/dropbox/files"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckExportFormats(t *testing.T) {
+	assert.NoError(t, checkExportFormats(fs.CommaSepList{"html", "MD", "txt"}))
+	assert.NoError(t, checkExportFormats(nil))
+	assert.Error(t, checkExportFormats(fs.CommaSepList{"html", "docx"}))
+}
+
+func TestExportName(t *testing.T) {
+	assert.Equal(t, "notes.md", exportName("notes.paper", "md"))
+	assert.Equal(t, "my.notes.html", exportName("my.notes.paper", "html"))
+}
+
+func TestFindExportFormat(t *testing.T) {
+	f := &Fs{opt: Options{ExportFormats: fs.CommaSepList{"html", "md"}}}
+	paper := &files.FileMetadata{ExportInfo: &files.ExportInfo{ExportAs: "markdown"}}
+	other := &files.FileMetadata{ExportInfo: &files.ExportInfo{ExportAs: "markdown"}}
+	unknown := &files.FileMetadata{ExportInfo: &files.ExportInfo{ExportAs: "docx"}}
+
+	ext, apiFormat, ok := f.findExportFormat("notes.paper", paper)
+	assert.True(t, ok)
+	assert.Equal(t, "html", ext)
+	assert.Equal(t, "html", apiFormat)
+
+	ext, apiFormat, ok = f.findExportFormat("notes.gdoc", other)
+	assert.True(t, ok)
+	assert.Equal(t, "md", ext)
+	assert.Equal(t, "markdown", apiFormat)
+
+	_, _, ok = f.findExportFormat("notes.gdoc", unknown)
+	assert.False(t, ok)
+}
+
+func TestNewExportObject(t *testing.T) {
+	f := &Fs{opt: Options{ExportFormats: fs.CommaSepList{"md"}}}
+	f.setRoot("root")
+	info := &files.FileMetadata{ExportInfo: &files.ExportInfo{ExportAs: "markdown"}}
+
+	o := f.newExportObject("dir", "notes.paper", info)
+	if assert.NotNil(t, o) {
+		assert.Equal(t, "dir/notes.md", o.Remote())
+		assert.Equal(t, "/root/dir/notes.paper", o.remotePath())
+		assert.Equal(t, "markdown", o.exportAPIFormat)
+		assert.Equal(t, int64(-1), o.Size())
+	}
+
+	f.opt.SkipExports = true
+	assert.Nil(t, f.newExportObject("dir", "notes.paper", info))
+}
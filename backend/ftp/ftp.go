@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"io"
+	"net"
 	"net/textproto"
 	"os"
 	"path"
@@ -20,6 +21,7 @@ import (
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/pacer"
@@ -81,6 +83,23 @@ to an encrypted one. Cannot be used in combination with implicit FTP.`,
 			Help:     "Disable using EPSV even if server advertises support",
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name:    "tls_cache_size",
+			Default: 32,
+			Help: `Size of the TLS session cache of each control connection.
+
+The data connections resume the TLS session of their control
+connection using this cache, which many servers, eg vsftpd with
+require_ssl_reuse and ProFTPD without NoSessionReuseRequired,
+insist on. Each control connection has its own cache so its data
+connections can't resume the session of another. Set to 0 to
+disable it.`,
+			Advanced: true,
+		}, {
+			Name:     "disable_tls13",
+			Help:     "Disable TLS 1.3 (workaround for FTP servers with buggy TLS)",
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -107,22 +126,32 @@ type Options struct {
 	Concurrency       int                  `config:"concurrency"`
 	SkipVerifyTLSCert bool                 `config:"no_check_certificate"`
 	DisableEPSV       bool                 `config:"disable_epsv"`
+	TLSCacheSize      int                  `config:"tls_cache_size"`
+	DisableTLS13      bool                 `config:"disable_tls13"`
 	Enc               encoder.MultiEncoder `config:"encoding"`
 }
 
 // Fs represents a remote FTP server
 type Fs struct {
-	name     string       // name of this remote
-	root     string       // the path we are working on if any
-	opt      Options      // parsed options
-	features *fs.Features // optional features
-	url      string
-	user     string
-	pass     string
-	dialAddr string
-	poolMu   sync.Mutex
-	pool     []*ftp.ServerConn
-	tokens   *pacer.TokenDispenser
+	name      string       // name of this remote
+	root      string       // the path we are working on if any
+	opt       Options      // parsed options
+	features  *fs.Features // optional features
+	url       string
+	user      string
+	pass      string
+	dialAddr  string
+	tlsConfig *tls.Config // cloned for each control connection, nil if not using TLS
+	poolMu    sync.Mutex
+	pool      []*ftpConn
+	tokens    *pacer.TokenDispenser
+	setTime   bool // set if the server lists precise times and can set them
+}
+
+// ftpConn is a connection to the FTP server
+type ftpConn struct {
+	*ftp.ServerConn
+	data *dataDialer // makes the data connections
 }
 
 // Object describes an FTP file
@@ -138,6 +167,7 @@ type FileInfo struct {
 	Size    uint64
 	ModTime time.Time
 	IsDir   bool
+	Facts   mlsdFacts // facts from the MLSD listing, nil if not known
 }
 
 // ------------------------------------------------------------
@@ -200,23 +230,42 @@ func (dl *debugLog) Write(p []byte) (n int, err error) {
 }
 
 // Open a new connection to the FTP server.
-func (f *Fs) ftpConnection() (*ftp.ServerConn, error) {
+func (f *Fs) ftpConnection() (*ftpConn, error) {
 	fs.Debugf(f, "Connecting to FTP server")
-	ftpConfig := []ftp.DialOption{ftp.DialWithTimeout(fs.Config.ConnectTimeout)}
 	if f.opt.TLS && f.opt.ExplicitTLS {
 		fs.Errorf(f, "Implicit TLS and explicit TLS are mutually incompatible. Please revise your config")
 		return nil, errors.New("Implicit TLS and explicit TLS are mutually incompatible. Please revise your config")
-	} else if f.opt.TLS {
-		tlsConfig := &tls.Config{
-			ServerName:         f.opt.Host,
-			InsecureSkipVerify: f.opt.SkipVerifyTLSCert,
+	}
+	// Each control connection has its own TLS session cache so
+	// its data connections can only resume its own session
+	var tlsConfig *tls.Config
+	if f.tlsConfig != nil {
+		tlsConfig = f.tlsConfig.Clone()
+		if f.opt.TLSCacheSize > 0 {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(f.opt.TLSCacheSize)
 		}
+	}
+	// Dial the control connection here so the data connections
+	// can be made by the dataDialer
+	dialer := fshttp.NewDialer(fs.Config)
+	var conn net.Conn
+	var err error
+	if f.opt.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", f.dialAddr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", f.dialAddr)
+	}
+	if err != nil {
+		fs.Errorf(f, "Error while Dialing %s: %s", f.dialAddr, err)
+		return nil, errors.Wrap(err, "ftpConnection Dial")
+	}
+	data := &dataDialer{control: conn, tlsConfig: tlsConfig}
+	ftpConfig := []ftp.DialOption{ftp.DialWithDialFunc(data.dial)}
+	if f.opt.TLS {
+		// Already connected with TLS but this makes the login
+		// protect the data connections
 		ftpConfig = append(ftpConfig, ftp.DialWithTLS(tlsConfig))
 	} else if f.opt.ExplicitTLS {
-		tlsConfig := &tls.Config{
-			ServerName:         f.opt.Host,
-			InsecureSkipVerify: f.opt.SkipVerifyTLSCert,
-		}
 		ftpConfig = append(ftpConfig, ftp.DialWithExplicitTLS(tlsConfig))
 	}
 	if f.opt.DisableEPSV {
@@ -236,11 +285,11 @@ func (f *Fs) ftpConnection() (*ftp.ServerConn, error) {
 		fs.Errorf(f, "Error while Logging in into %s: %s", f.dialAddr, err)
 		return nil, errors.Wrap(err, "ftpConnection Login")
 	}
-	return c, nil
+	return &ftpConn{ServerConn: c, data: data}, nil
 }
 
 // Get an FTP connection from the pool, or open a new one
-func (f *Fs) getFtpConnection() (c *ftp.ServerConn, err error) {
+func (f *Fs) getFtpConnection() (c *ftpConn, err error) {
 	if f.opt.Concurrency > 0 {
 		f.tokens.Get()
 	}
@@ -266,7 +315,7 @@ func (f *Fs) getFtpConnection() (c *ftp.ServerConn, err error) {
 //
 // if err is not nil then it checks the connection is alive using a
 // NOOP request
-func (f *Fs) putFtpConnection(pc **ftp.ServerConn, err error) {
+func (f *Fs) putFtpConnection(pc **ftpConn, err error) {
 	if f.opt.Concurrency > 0 {
 		defer f.tokens.Put()
 	}
@@ -334,6 +383,15 @@ func NewFs(name, root string, m configmap.Mapper) (ff fs.Fs, err error) {
 		dialAddr: dialAddr,
 		tokens:   pacer.NewTokenDispenser(opt.Concurrency),
 	}
+	if opt.TLS || opt.ExplicitTLS {
+		f.tlsConfig = &tls.Config{
+			ServerName:         opt.Host,
+			InsecureSkipVerify: opt.SkipVerifyTLSCert,
+		}
+		if opt.DisableTLS13 {
+			f.tlsConfig.MaxVersion = tls.VersionTLS12
+		}
+	}
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
 	}).Fill(f)
@@ -342,6 +400,7 @@ func NewFs(name, root string, m configmap.Mapper) (ff fs.Fs, err error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "NewFs")
 	}
+	f.setTime = c.IsTimePreciseInList() && c.IsSetTimeSupported()
 	f.putFtpConnection(&c, nil)
 	if root != "" {
 		// Check to see if the root actually an existing file
@@ -408,8 +467,9 @@ func (f *Fs) dirFromStandardPath(dir string) string {
 	return f.opt.Enc.FromStandardPath(dir)
 }
 
-// findItem finds a directory entry for the name in its parent directory
-func (f *Fs) findItem(remote string) (entry *ftp.Entry, err error) {
+// findItem finds a directory entry for the name in its parent
+// directory along with its MLSD facts if known
+func (f *Fs) findItem(remote string) (entry *ftp.Entry, facts mlsdFacts, err error) {
 	// defer fs.Trace(remote, "")("o=%v, err=%v", &o, &err)
 	fullPath := path.Join(f.root, remote)
 	if fullPath == "" || fullPath == "." || fullPath == "/" {
@@ -418,34 +478,35 @@ func (f *Fs) findItem(remote string) (entry *ftp.Entry, err error) {
 			Name: "",
 			Type: ftp.EntryTypeFolder,
 			Time: time.Now(),
-		}, nil
+		}, nil, nil
 	}
 	dir := path.Dir(fullPath)
 	base := path.Base(fullPath)
 
 	c, err := f.getFtpConnection()
 	if err != nil {
-		return nil, errors.Wrap(err, "findItem")
+		return nil, nil, errors.Wrap(err, "findItem")
 	}
-	files, err := c.List(f.dirFromStandardPath(dir))
+	files, dirFacts, err := c.listFacts(f.dirFromStandardPath(dir))
 	f.putFtpConnection(&c, err)
 	if err != nil {
-		return nil, translateErrorFile(err)
+		return nil, nil, translateErrorFile(err)
 	}
 	for _, file := range files {
+		name := file.Name
 		f.entryToStandard(file)
 		if file.Name == base {
-			return file, nil
+			return file, dirFacts[name], nil
 		}
 	}
-	return nil, nil
+	return nil, nil, nil
 }
 
 // NewObject finds the Object at remote.  If it can't be found
 // it returns the error fs.ErrorObjectNotFound.
 func (f *Fs) NewObject(ctx context.Context, remote string) (o fs.Object, err error) {
 	// defer fs.Trace(remote, "")("o=%v, err=%v", &o, &err)
-	entry, err := f.findItem(remote)
+	entry, facts, err := f.findItem(remote)
 	if err != nil {
 		return nil, err
 	}
@@ -458,6 +519,7 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (o fs.Object, err err
 			Name:    remote,
 			Size:    entry.Size,
 			ModTime: entry.Time,
+			Facts:   facts,
 		}
 		o.info = info
 
@@ -468,7 +530,7 @@ func (f *Fs) NewObject(ctx context.Context, remote string) (o fs.Object, err err
 
 // dirExists checks the directory pointed to by remote exists or not
 func (f *Fs) dirExists(remote string) (exists bool, err error) {
-	entry, err := f.findItem(remote)
+	entry, _, err := f.findItem(remote)
 	if err != nil {
 		return false, errors.Wrap(err, "dirExists")
 	}
@@ -496,16 +558,18 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 
 	var listErr error
 	var files []*ftp.Entry
+	var facts map[string]mlsdFacts
 
 	resultchan := make(chan []*ftp.Entry, 1)
 	errchan := make(chan error, 1)
 	go func() {
-		result, err := c.List(f.dirFromStandardPath(path.Join(f.root, dir)))
+		result, resultFacts, err := c.listFacts(f.dirFromStandardPath(path.Join(f.root, dir)))
 		f.putFtpConnection(&c, err)
 		if err != nil {
 			errchan <- err
 			return
 		}
+		facts = resultFacts
 		resultchan <- result
 	}()
 
@@ -537,6 +601,7 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	}
 	for i := range files {
 		object := files[i]
+		name := object.Name
 		f.entryToStandard(object)
 		newremote := path.Join(dir, object.Name)
 		switch object.Type {
@@ -555,6 +620,7 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 				Name:    newremote,
 				Size:    object.Size,
 				ModTime: object.Time,
+				Facts:   facts[name],
 			}
 			o.info = info
 			entries = append(entries, o)
@@ -568,8 +634,14 @@ func (f *Fs) Hashes() hash.Set {
 	return 0
 }
 
-// Precision shows Modified Time not supported
+// Precision of the modification times
+//
+// These are only supported if the server lists them precisely with
+// MLSD and can set them with MFMT.
 func (f *Fs) Precision() time.Duration {
+	if f.setTime {
+		return time.Second
+	}
 	return fs.ModTimeNotSupported
 }
 
@@ -607,7 +679,7 @@ func (f *Fs) getInfo(remote string) (fi *FileInfo, err error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "getInfo")
 	}
-	files, err := c.List(f.dirFromStandardPath(dir))
+	files, facts, err := c.listFacts(f.dirFromStandardPath(dir))
 	f.putFtpConnection(&c, err)
 	if err != nil {
 		return nil, translateErrorFile(err)
@@ -615,6 +687,7 @@ func (f *Fs) getInfo(remote string) (fi *FileInfo, err error) {
 
 	for i := range files {
 		file := files[i]
+		name := file.Name
 		f.entryToStandard(file)
 		if file.Name == base {
 			info := &FileInfo{
@@ -622,6 +695,7 @@ func (f *Fs) getInfo(remote string) (fi *FileInfo, err error) {
 				Size:    file.Size,
 				ModTime: file.Time,
 				IsDir:   file.Type == ftp.EntryTypeFolder,
+				Facts:   facts[name],
 			}
 			return info, nil
 		}
@@ -810,7 +884,22 @@ func (o *Object) ModTime(ctx context.Context) time.Time {
 }
 
 // SetModTime sets the modification time of the object
+//
+// This does nothing unless the server supports setting it with MFMT.
 func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	if !o.fs.setTime {
+		return nil
+	}
+	c, err := o.fs.getFtpConnection()
+	if err != nil {
+		return errors.Wrap(err, "SetModTime")
+	}
+	err = c.SetTime(o.fs.opt.Enc.FromStandardPath(path.Join(o.fs.root, o.remote)), modTime)
+	o.fs.putFtpConnection(&c, err)
+	if err != nil {
+		return errors.Wrap(err, "SetModTime")
+	}
+	o.info.ModTime = modTime
 	return nil
 }
 
@@ -822,7 +911,7 @@ func (o *Object) Storable() bool {
 // ftpReadCloser implements io.ReadCloser for FTP objects.
 type ftpReadCloser struct {
 	rc  io.ReadCloser
-	c   *ftp.ServerConn
+	c   *ftpConn
 	f   *Fs
 	err error // errors found during read
 }
@@ -937,6 +1026,13 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		o.fs.putFtpConnection(nil, err)
 		return errors.Wrap(err, "update stor")
 	}
+	if o.fs.setTime {
+		err = c.SetTime(o.fs.opt.Enc.FromStandardPath(path), src.ModTime(ctx))
+		if err != nil {
+			o.fs.putFtpConnection(&c, err)
+			return errors.Wrap(err, "update set modification time")
+		}
+	}
 	o.fs.putFtpConnection(&c, nil)
 	o.info, err = o.fs.getInfo(path)
 	if err != nil {
@@ -0,0 +1,120 @@
+package ftp
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMLSDLine(t *testing.T) {
+	for _, test := range []struct {
+		line  string
+		name  string
+		facts mlsdFacts
+		ok    bool
+	}{
+		{
+			line:  "type=file;size=42;modify=20200812163218.123;UNIX.mode=0644; file name.txt\r\n",
+			name:  "file name.txt",
+			facts: mlsdFacts{"type": "file", "size": "42", "modify": "20200812163218.123", "unix.mode": "0644"},
+			ok:    true,
+		}, {
+			line:  "type=dir;modify=20200812163218; dir",
+			name:  "dir",
+			facts: mlsdFacts{"type": "dir", "modify": "20200812163218"},
+			ok:    true,
+		},
+		{line: "drwxr-xr-x    2 ftp      ftp          4096 Aug 12 16:32 dir"},
+		{line: "total 12"},
+		{line: "08-12-20  04:32PM       <DIR>          dir"},
+		{line: "nospace"},
+		{line: ""},
+	} {
+		name, facts, ok := parseMLSDLine(test.line)
+		assert.Equal(t, test.ok, ok, test.line)
+		assert.Equal(t, test.name, name, test.line)
+		assert.Equal(t, test.facts, facts, test.line)
+	}
+}
+
+func TestMLSDFactsMetadata(t *testing.T) {
+	facts := mlsdFacts{
+		"type":           "file",
+		"modify":         "20200812163218.123",
+		"create":         "20200101000000",
+		"unix.mode":      "644",
+		"unix.owner":     "1000",
+		"unix.group":     "100",
+		"unix.ownername": "alice",
+		"unix.groupname": "users",
+		"perm":           "adfrw",
+	}
+	assert.Equal(t, fs.Metadata{
+		fs.MetadataMtime: "2020-08-12T16:32:18.123Z",
+		fs.MetadataBtime: "2020-01-01T00:00:00Z",
+		fs.MetadataMode:  "0644",
+		fs.MetadataUID:   "1000",
+		fs.MetadataGID:   "100",
+		"owner":          "alice",
+		"group":          "users",
+		"perm":           "adfrw",
+	}, facts.metadata())
+
+	facts = mlsdFacts{"unix.uid": "0", "unix.gid": "0", "modify": "bad", "unix.mode": "bad"}
+	assert.Equal(t, fs.Metadata{
+		fs.MetadataUID: "0",
+		fs.MetadataGID: "0",
+	}, facts.metadata())
+}
+
+func TestMLSDConn(t *testing.T) {
+	listing := "type=file;size=1;modify=20200812163218.5; a\r\n" +
+		"type=file;size=2;unix.mode=0600; b c\r\n" +
+		"type=dir; d"
+	client, server := net.Pipe()
+	go func() {
+		// write in small pieces to split the lines
+		for i := 0; i < len(listing); i += 7 {
+			end := i + 7
+			if end > len(listing) {
+				end = len(listing)
+			}
+			_, _ = server.Write([]byte(listing[i:end]))
+		}
+		_ = server.Close()
+	}()
+	facts := map[string]mlsdFacts{}
+	data, err := ioutil.ReadAll(&mlsdConn{Conn: client, facts: facts})
+	require.NoError(t, err)
+	assert.Equal(t, listing, string(data))
+	assert.Equal(t, map[string]mlsdFacts{
+		"a":   {"type": "file", "size": "1", "modify": "20200812163218.5"},
+		"b c": {"type": "file", "size": "2", "unix.mode": "0600"},
+		"d":   {"type": "dir"},
+	}, facts)
+}
+
+func TestDataDialerControl(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+	control, _ := net.Pipe()
+	d := &dataDialer{control: control}
+
+	// The first dial returns the control connection
+	conn, err := d.dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, control, conn)
+
+	// Then data connections are dialled
+	conn, err = d.dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	assert.NotEqual(t, control, conn)
+	_ = conn.Close()
+}
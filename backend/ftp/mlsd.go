@@ -0,0 +1,203 @@
+// Data connections and the facts of MLSD listings
+
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// mlsdTimeFormat is the format of times in MLSD listings. Fractions
+// of a second after it are parsed too.
+const mlsdTimeFormat = "20060102150405"
+
+// mlsdFacts are the facts about a file in an MLSD listing keyed by the
+// lower case fact name, eg "unix.mode"
+type mlsdFacts map[string]string
+
+// parseMLSDLine parses a line of an MLSD listing, as described in RFC
+// 3659, returning ok false if it isn't one
+func parseMLSDLine(line string) (name string, facts mlsdFacts, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return "", nil, false
+	}
+	facts = mlsdFacts{}
+	for _, fact := range strings.Split(line[:i], ";") {
+		if fact == "" {
+			continue
+		}
+		j := strings.IndexByte(fact, '=')
+		if j < 1 {
+			return "", nil, false
+		}
+		facts[strings.ToLower(fact[:j])] = fact[j+1:]
+	}
+	if len(facts) == 0 {
+		return "", nil, false
+	}
+	return line[i+1:], facts, true
+}
+
+// isNumeric returns true if s is a decimal number
+func isNumeric(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// metadata returns the facts as metadata
+//
+// The times, mode, uid and gid are translated to the standard keys
+// and the owner and group names and the RFC 3659 permissions are
+// returned as "owner", "group" and "perm".
+func (facts mlsdFacts) metadata() fs.Metadata {
+	metadata := make(fs.Metadata, len(facts))
+	setTime := func(key, value string) {
+		t, err := time.ParseInLocation(mlsdTimeFormat, value, time.UTC)
+		if err == nil {
+			metadata.SetTime(key, t)
+		}
+	}
+	for fact, value := range facts {
+		switch fact {
+		case "modify":
+			setTime(fs.MetadataMtime, value)
+		case "create":
+			setTime(fs.MetadataBtime, value)
+		case "unix.mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err == nil {
+				metadata[fs.MetadataMode] = fmt.Sprintf("%04o", mode)
+			}
+		case "unix.uid":
+			metadata[fs.MetadataUID] = value
+		case "unix.gid":
+			metadata[fs.MetadataGID] = value
+		case "unix.owner", "unix.ownername":
+			// Some servers send the uid as unix.owner
+			if isNumeric(value) {
+				metadata[fs.MetadataUID] = value
+			} else {
+				metadata["owner"] = value
+			}
+		case "unix.group", "unix.groupname":
+			if isNumeric(value) {
+				metadata[fs.MetadataGID] = value
+			} else {
+				metadata["group"] = value
+			}
+		case "perm":
+			metadata["perm"] = value
+		}
+	}
+	return metadata
+}
+
+// dataDialer makes the data connections for a control connection
+type dataDialer struct {
+	control   net.Conn             // the control connection, returned by the first dial
+	tlsConfig *tls.Config          // TLS config of the control connection, if any
+	facts     map[string]mlsdFacts // if set the facts of listings are recorded in here
+}
+
+// dial makes a data connection, resuming the TLS session of the
+// control connection if using TLS
+//
+// The first call returns the control connection which has already
+// been dialled.
+func (d *dataDialer) dial(network, address string) (net.Conn, error) {
+	if d.control != nil {
+		conn := d.control
+		d.control = nil
+		return conn, nil
+	}
+	conn, err := fshttp.NewDialer(fs.Config).Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if d.tlsConfig != nil {
+		conn = tls.Client(conn, d.tlsConfig)
+	}
+	if d.facts != nil {
+		conn = &mlsdConn{Conn: conn, facts: d.facts}
+	}
+	return conn, nil
+}
+
+// mlsdConn is a data connection carrying a listing which records the
+// facts of the MLSD lines read through it
+type mlsdConn struct {
+	net.Conn
+	facts map[string]mlsdFacts
+	line  []byte // incomplete line read so far
+}
+
+// Read reads from the connection recording the facts of any
+// complete lines
+func (c *mlsdConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	data := p[:n]
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		c.line = append(c.line, data[:i]...)
+		c.record()
+		data = data[i+1:]
+	}
+	c.line = append(c.line, data...)
+	if err == io.EOF {
+		c.record()
+	}
+	return n, err
+}
+
+// record records the facts of the line read so far and resets it
+func (c *mlsdConn) record() {
+	if name, facts, ok := parseMLSDLine(string(c.line)); ok {
+		c.facts[name] = facts
+	}
+	c.line = c.line[:0]
+}
+
+// listFacts lists dir returning the entries and, if the server
+// supports MLSD, their facts by the name as listed
+func (c *ftpConn) listFacts(dir string) (entries []*ftp.Entry, facts map[string]mlsdFacts, err error) {
+	facts = map[string]mlsdFacts{}
+	c.data.facts = facts
+	defer func() {
+		c.data.facts = nil
+	}()
+	entries, err = c.List(dir)
+	return entries, facts, err
+}
+
+// Metadata returns the modification time of the object and, if the
+// server supports MLSD, the other facts about it in the listing it
+// was read from.
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	metadata := fs.Metadata{}
+	metadata.SetTime(fs.MetadataMtime, o.info.ModTime)
+	for key, value := range o.info.Facts.metadata() {
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Metadataer = &Object{}
+)
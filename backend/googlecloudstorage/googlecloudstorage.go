@@ -1070,6 +1070,10 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	}
 	// Apply upload options
 	for _, option := range options {
+		if tierOption, ok := option.(*fs.TierOption); ok {
+			object.StorageClass = strings.ToUpper(tierOption.Tier)
+			continue
+		}
 		key, value := option.Header()
 		lowerKey := strings.ToLower(key)
 		switch lowerKey {
@@ -4,6 +4,7 @@ package googlephotos
 
 import (
 	"path"
+	"sort"
 	"strings"
 	"sync"
 
@@ -146,3 +147,16 @@ func (as *albums) getDirs(albumPath string) (dirs []string, ok bool) {
 	dirs, ok = as.path[albumPath]
 	return dirs, ok
 }
+
+// list returns all the albums sorted by title
+func (as *albums) list() (all []*api.Album) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	for _, album := range as.byTitle {
+		all = append(all, album)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Title < all[j].Title
+	})
+	return all
+}
@@ -309,3 +309,21 @@ func TestAlbumsGetDirs(t *testing.T) {
 	assert.Equal(t, false, ok)
 	assert.Nil(t, dirs)
 }
+
+func TestAlbumsList(t *testing.T) {
+	albums := newAlbums()
+	assert.Nil(t, albums.list())
+
+	a1 := &api.Album{
+		Title: "two",
+		ID:    "1",
+	}
+	albums.add(a1)
+	a2 := &api.Album{
+		Title: "one",
+		ID:    "2",
+	}
+	albums.add(a2)
+
+	assert.Equal(t, []*api.Album{a2, a1}, albums.list())
+}
@@ -188,3 +188,8 @@ type BatchCreateResponse struct {
 type BatchRemoveItems struct {
 	MediaItemIds []string `json:"mediaItemIds"`
 }
+
+// BatchAddItems is for adding items to an album
+type BatchAddItems struct {
+	MediaItemIds []string `json:"mediaItemIds"`
+}
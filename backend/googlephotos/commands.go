@@ -0,0 +1,268 @@
+// Backend commands to manage albums
+
+package googlephotos
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/googlephotos/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+// batchChunks is the maximum number of media items which can be added
+// to or removed from an album in one call
+const batchChunks = 50
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "albums",
+	Short: "List the albums",
+	Long: `This lists the albums with their IDs, number of items and whether
+rclone can add items to them.
+
+Usage Examples:
+
+    rclone backend albums gphotos:
+    rclone backend albums gphotos: -o shared
+
+The titles are as shown under "album" (or "shared-album" with the
+"shared" option) so may have the ID added if they are duplicated.
+`,
+	Opts: map[string]string{
+		"shared": "list the shared albums instead",
+	},
+}, {
+	Name:  "create-album",
+	Short: "Create albums",
+	Long: `This creates each album given unless it exists already.
+
+Usage Example:
+
+    rclone backend create-album gphotos: "Holidays/2020" "Family"
+
+This is the same as making the directory under "album" with "rclone
+mkdir".
+`,
+}, {
+	Name:  "rename-album",
+	Short: "Rename an album",
+	Long: `This changes the title of an album.
+
+Usage Example:
+
+    rclone backend rename-album gphotos: "Holidays/2020" "Holidays/Spain 2020"
+
+The Google Photos API only allows albums created by rclone to be renamed.
+`,
+}, {
+	Name:  "add-to-album",
+	Short: "Add media items to an album",
+	Long: `This adds media items to an album, creating it if necessary, without
+uploading them again.
+
+Usage Example:
+
+    rclone backend add-to-album gphotos: "Holidays/2020" upload/IMG_0001.jpg media/by-month/2020/2020-07/IMG_0002.jpg
+
+The media items are paths relative to the remote. The Google Photos
+API only allows media items uploaded by rclone to be added to albums
+created by rclone.
+`,
+}, {
+	Name:  "remove-from-album",
+	Short: "Remove media items from an album",
+	Long: `This removes media items from an album. The media items stay in
+the library.
+
+Usage Example:
+
+    rclone backend remove-from-album gphotos: "Holidays/2020" upload/IMG_0001.jpg
+
+The media items are paths relative to the remote. The Google Photos
+API only allows media items to be removed from albums created by
+rclone.
+`,
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "albums":
+		_, shared := opt["shared"]
+		all, err := f.listAlbums(ctx, shared)
+		if err != nil {
+			return nil, err
+		}
+		return all.list(), nil
+	case "create-album":
+		var albums []*api.Album
+		for _, albumTitle := range arg {
+			album, err := f.getOrCreateAlbum(ctx, albumTitle)
+			if err != nil {
+				return nil, err
+			}
+			albums = append(albums, album)
+		}
+		return albums, nil
+	case "rename-album":
+		if len(arg) != 2 {
+			return nil, errors.New("need exactly 2 arguments")
+		}
+		return f.renameAlbum(ctx, arg[0], arg[1])
+	case "add-to-album", "remove-from-album":
+		if len(arg) < 2 {
+			return nil, errors.New("need an album and at least one media item")
+		}
+		var album *api.Album
+		if name == "add-to-album" {
+			album, err = f.getOrCreateAlbum(ctx, arg[0])
+		} else {
+			album, err = f.getAlbum(ctx, arg[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !album.IsWriteable {
+			return nil, errOwnAlbums
+		}
+		ids, err := f.mediaItemIDs(ctx, arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		if name == "add-to-album" {
+			return nil, f.addToAlbum(ctx, album, ids)
+		}
+		return nil, f.removeFromAlbum(ctx, album, ids)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// getAlbum gets an existing album by title
+func (f *Fs) getAlbum(ctx context.Context, albumTitle string) (album *api.Album, err error) {
+	albums, err := f.listAlbums(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	album, ok := albums.get(albumTitle)
+	if !ok {
+		return nil, errors.Errorf("album %q not found", albumTitle)
+	}
+	return album, nil
+}
+
+// mediaItemIDs returns the IDs of the media items at the remote paths
+func (f *Fs) mediaItemIDs(ctx context.Context, remotes []string) (ids []string, err error) {
+	for _, remote := range remotes {
+		o, err := f.NewObject(ctx, remote)
+		if err != nil {
+			return nil, errors.Wrapf(err, "couldn't find media item %q", remote)
+		}
+		ids = append(ids, o.(*Object).id)
+	}
+	return ids, nil
+}
+
+// renameAlbum changes the title of the album called oldTitle to newTitle
+func (f *Fs) renameAlbum(ctx context.Context, oldTitle, newTitle string) (album *api.Album, err error) {
+	f.createMu.Lock()
+	defer f.createMu.Unlock()
+	albums, err := f.listAlbums(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	old, ok := albums.get(oldTitle)
+	if !ok {
+		return nil, errors.Errorf("album %q not found", oldTitle)
+	}
+	if _, ok := albums.get(newTitle); ok {
+		return nil, errors.Errorf("album %q already exists", newTitle)
+	}
+	if !old.IsWriteable {
+		return nil, errOwnAlbums
+	}
+	opts := rest.Opts{
+		Method:     "PATCH",
+		Path:       "/albums/" + old.ID,
+		Parameters: url.Values{},
+	}
+	opts.Parameters.Set("updateMask", "title")
+	var request = api.Album{
+		Title: newTitle,
+	}
+	var result api.Album
+	var resp *http.Response
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.srv.CallJSON(ctx, &opts, &request, &result)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't rename album")
+	}
+	albums.del(old)
+	albums.add(&result)
+	return &result, nil
+}
+
+// batchAlbumItems calls the album method, eg "batchAddMediaItems", on
+// the media items with ids in batches
+func (f *Fs) batchAlbumItems(ctx context.Context, album *api.Album, method string, ids []string) (err error) {
+	opts := rest.Opts{
+		Method:     "POST",
+		Path:       "/albums/" + album.ID + ":" + method,
+		NoResponse: true,
+	}
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > batchChunks {
+			n = batchChunks
+		}
+		var request = api.BatchAddItems{
+			MediaItemIds: ids[:n],
+		}
+		var resp *http.Response
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err = f.srv.CallJSON(ctx, &opts, &request, nil)
+			return shouldRetry(resp, err)
+		})
+		if err != nil {
+			return err
+		}
+		ids = ids[n:]
+	}
+	return nil
+}
+
+// addToAlbum adds the media items with ids to the album
+func (f *Fs) addToAlbum(ctx context.Context, album *api.Album, ids []string) error {
+	err := f.batchAlbumItems(ctx, album, "batchAddMediaItems", ids)
+	if err != nil {
+		return errors.Wrap(err, "couldn't add items to album")
+	}
+	return nil
+}
+
+// removeFromAlbum removes the media items with ids from the album
+func (f *Fs) removeFromAlbum(ctx context.Context, album *api.Album, ids []string) error {
+	err := f.batchAlbumItems(ctx, album, "batchRemoveMediaItems", ids)
+	if err != nil {
+		return errors.Wrap(err, "couldn't remove items from album")
+	}
+	return nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Commander = &Fs{}
+)
@@ -78,6 +78,7 @@ func init() {
 		Prefix:      "gphotos",
 		Description: "Google Photos",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Config: func(name string, m configmap.Mapper) {
 			// Parse config into Options struct
 			opt := new(Options)
@@ -132,33 +133,50 @@ you want to read the media.`,
 			Default:  2000,
 			Help:     `Year limits the photos to be downloaded to those which are uploaded after the given year`,
 			Advanced: true,
+		}, {
+			Name:    "skip_uploaded",
+			Default: false,
+			Help: `Skip uploading media which rclone has uploaded before.
+
+The Google Photos API doesn't return hashes of media items, so if this
+is set rclone records the MD5 of each media item it uploads in the
+cache directory. Media with the same MD5 is not uploaded again, but
+is added to the album it is being uploaded to instead.
+
+This saves uploading media which Google Photos would deduplicate
+anyway, for example when the same photo is in several local
+directories which are uploaded to different albums.`,
+			Advanced: true,
 		}}...),
 	})
 }
 
 // Options defines the configuration for this backend
 type Options struct {
-	ReadOnly  bool `config:"read_only"`
-	ReadSize  bool `config:"read_size"`
-	StartYear int  `config:"start_year"`
+	ReadOnly     bool `config:"read_only"`
+	ReadSize     bool `config:"read_size"`
+	StartYear    int  `config:"start_year"`
+	SkipUploaded bool `config:"skip_uploaded"`
 }
 
 // Fs represents a remote storage server
 type Fs struct {
-	name       string                 // name of this remote
-	root       string                 // the path we are working on if any
-	opt        Options                // parsed options
-	features   *fs.Features           // optional features
-	unAuth     *rest.Client           // unauthenticated http client
-	srv        *rest.Client           // the connection to the one drive server
-	ts         *oauthutil.TokenSource // token source for oauth2
-	pacer      *fs.Pacer              // To pace the API calls
-	startTime  time.Time              // time Fs was started - used for datestamps
-	albumsMu   sync.Mutex             // protect albums (but not contents)
-	albums     map[bool]*albums       // albums, shared or not
-	uploadedMu sync.Mutex             // to protect the below
-	uploaded   dirtree.DirTree        // record of uploaded items
-	createMu   sync.Mutex             // held when creating albums to prevent dupes
+	name           string                 // name of this remote
+	root           string                 // the path we are working on if any
+	opt            Options                // parsed options
+	features       *fs.Features           // optional features
+	unAuth         *rest.Client           // unauthenticated http client
+	srv            *rest.Client           // the connection to the one drive server
+	ts             *oauthutil.TokenSource // token source for oauth2
+	pacer          *fs.Pacer              // To pace the API calls
+	startTime      time.Time              // time Fs was started - used for datestamps
+	albumsMu       sync.Mutex             // protect albums (but not contents)
+	albums         map[bool]*albums       // albums, shared or not
+	uploadedMu     sync.Mutex             // to protect the below
+	uploaded       dirtree.DirTree        // record of uploaded items
+	createMu       sync.Mutex             // held when creating albums to prevent dupes
+	uploadHashes   *uploadHashes          // hashes of uploaded media if skip_uploaded is set
+	downloadNotice sync.Once              // to warn once that downloads aren't the originals
 }
 
 // Object describes a storage object
@@ -277,6 +295,9 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		albums:    map[bool]*albums{},
 		uploaded:  dirtree.New(),
 	}
+	if opt.SkipUploaded {
+		f.uploadHashes = newUploadHashes(name)
+	}
 	f.features = (&fs.Features{
 		ReadMimeType: true,
 	}).Fill(f)
@@ -807,6 +828,24 @@ func (o *Object) setMetaData(info *api.MediaItem) {
 	o.modTime = info.MediaMetadata.CreationTime
 }
 
+// getMediaItem gets the media item with the ID passed in
+func (f *Fs) getMediaItem(ctx context.Context, id string) (item *api.MediaItem, err error) {
+	opts := rest.Opts{
+		Method: "GET",
+		Path:   "/mediaItems/" + id,
+	}
+	var result api.MediaItem
+	var resp *http.Response
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.srv.CallJSON(ctx, &opts, nil, &result)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get media item")
+	}
+	return &result, nil
+}
+
 // readMetaData gets the metadata if it hasn't already been fetched
 //
 // it also sets the info
@@ -825,20 +864,11 @@ func (o *Object) readMetaData(ctx context.Context) (err error) {
 	}
 	// If have ID fetch it directly
 	if id := findID(fileName); id != "" {
-		opts := rest.Opts{
-			Method: "GET",
-			Path:   "/mediaItems/" + id,
-		}
-		var item api.MediaItem
-		var resp *http.Response
-		err = o.fs.pacer.Call(func() (bool, error) {
-			resp, err = o.fs.srv.CallJSON(ctx, &opts, nil, &item)
-			return shouldRetry(resp, err)
-		})
+		item, err := o.fs.getMediaItem(ctx, id)
 		if err != nil {
-			return errors.Wrap(err, "couldn't get media item")
+			return err
 		}
-		o.setMetaData(&item)
+		o.setMetaData(item)
 		return nil
 	}
 	// Otherwise list the directory the file is in
@@ -902,6 +932,9 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 		fs.Debugf(o, "Open: Failed to read metadata: %v", err)
 		return nil, err
 	}
+	o.fs.downloadNotice.Do(func() {
+		fs.Logf(o.fs, "The Google Photos API doesn't download the original media - photos have their location data removed and videos are re-encoded")
+	})
 	var resp *http.Response
 	opts := rest.Opts{
 		Method:  "GET",
@@ -949,6 +982,36 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		albumID = album.ID
 	}
 
+	// Skip the upload if the media has been uploaded before,
+	// otherwise find its MD5 to record it
+	var (
+		md5    string
+		hasher *hash.MultiHasher
+	)
+	if o.fs.uploadHashes != nil {
+		md5, _ = src.Hash(ctx, hash.MD5)
+		if md5 != "" {
+			skipped, err := o.skipUpload(ctx, md5, albumID)
+			if err != nil {
+				return err
+			}
+			if skipped {
+				if pattern.isUpload {
+					o.fs.uploadedMu.Lock()
+					o.fs.uploaded.AddEntry(o)
+					o.fs.uploadedMu.Unlock()
+				}
+				return nil
+			}
+		} else {
+			hasher, err = hash.NewMultiHasherTypes(hash.NewHashSet(hash.MD5))
+			if err != nil {
+				return err
+			}
+			in = io.TeeReader(in, hasher)
+		}
+	}
+
 	// Upload the media item in exchange for an UploadToken
 	opts := rest.Opts{
 		Method:  "POST",
@@ -1009,6 +1072,12 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return errors.Errorf("upload failed: %s (%d)", mediaItemResult.Status.Message, mediaItemResult.Status.Code)
 	}
 	o.setMetaData(&mediaItemResult.MediaItem)
+	if hasher != nil {
+		md5 = hasher.Sums()[hash.MD5]
+	}
+	if md5 != "" {
+		o.fs.uploadHashes.set(md5, o.id)
+	}
 
 	// Add upload to internal storage
 	if pattern.isUpload {
@@ -1019,6 +1088,31 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return nil
 }
 
+// skipUpload checks whether media with md5 was uploaded before and
+// still exists, returning true if so after adding it to the album
+// with albumID if set.
+func (o *Object) skipUpload(ctx context.Context, md5, albumID string) (skipped bool, err error) {
+	id, ok := o.fs.uploadHashes.get(md5)
+	if !ok {
+		return false, nil
+	}
+	item, err := o.fs.getMediaItem(ctx, id)
+	if err != nil {
+		fs.Debugf(o, "Uploading again as media uploaded before not found: %v", err)
+		o.fs.uploadHashes.set(md5, "")
+		return false, nil
+	}
+	if albumID != "" {
+		err = o.fs.addToAlbum(ctx, &api.Album{ID: albumID}, []string{id})
+		if err != nil {
+			return false, err
+		}
+	}
+	fs.Debugf(o, "Skipping upload as media was uploaded before as %q", item.Filename)
+	o.setMetaData(item)
+	return true, nil
+}
+
 // Remove an object
 func (o *Object) Remove(ctx context.Context) (err error) {
 	match, _, pattern := patterns.match(o.fs.root, o.remote, true)
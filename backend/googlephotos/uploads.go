@@ -0,0 +1,99 @@
+// Bookkeeping of the hashes of uploaded media items
+
+package googlephotos
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+)
+
+// uploadHashes records the MD5 of each media item uploaded so the
+// same media can be skipped when uploaded again, since the Google
+// Photos API doesn't return the hashes of media items.
+//
+// It is persisted as a JSON object of MD5 to media item ID.
+type uploadHashes struct {
+	mu     sync.Mutex
+	path   string            // file it is stored in
+	byHash map[string]string // media item ID by MD5
+	loaded bool              // set if the file has been read
+}
+
+// newUploadHashes makes the upload hashes for the remote called name
+// which are stored in the cache directory
+func newUploadHashes(name string) *uploadHashes {
+	return &uploadHashes{
+		path:   filepath.Join(config.CacheDir, "googlephotos", name+".json"),
+		byHash: map[string]string{},
+	}
+}
+
+// _load reads the file if it hasn't been read - call with lock held
+func (u *uploadHashes) _load() {
+	if u.loaded {
+		return
+	}
+	u.loaded = true
+	data, err := ioutil.ReadFile(u.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &u.byHash)
+	}
+	if err != nil {
+		fs.Errorf(nil, "Ignoring uploaded media hashes in %q: %v", u.path, err)
+		u.byHash = map[string]string{}
+	}
+}
+
+// _save writes the file - call with lock held
+func (u *uploadHashes) _save() error {
+	data, err := json.Marshal(u.byHash)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(u.path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := u.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.path)
+}
+
+// get returns the ID of the media item uploaded with md5 if any
+func (u *uploadHashes) get(md5 string) (id string, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u._load()
+	id, ok = u.byHash[md5]
+	return id, ok
+}
+
+// set records that the media item with id was uploaded with md5, or
+// forgets md5 if id is ""
+func (u *uploadHashes) set(md5, id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u._load()
+	if id == "" {
+		delete(u.byHash, md5)
+	} else {
+		u.byHash[md5] = id
+	}
+	err := u._save()
+	if err != nil {
+		fs.Errorf(nil, "Failed to save uploaded media hashes: %v", errors.Wrap(err, u.path))
+	}
+}
@@ -0,0 +1,54 @@
+package googlephotos
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fs/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-gphotos-uploads")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	oldCacheDir := config.CacheDir
+	config.CacheDir = dir
+	defer func() {
+		config.CacheDir = oldCacheDir
+	}()
+
+	u := newUploadHashes("remote")
+	_, ok := u.get("md5")
+	assert.False(t, ok)
+
+	u.set("md5", "id")
+	id, ok := u.get("md5")
+	assert.True(t, ok)
+	assert.Equal(t, "id", id)
+
+	// Read back from the file
+	u = newUploadHashes("remote")
+	id, ok = u.get("md5")
+	assert.True(t, ok)
+	assert.Equal(t, "id", id)
+
+	// Forget it
+	u.set("md5", "")
+	_, ok = newUploadHashes("remote").get("md5")
+	assert.False(t, ok)
+
+	// Other remotes are separate
+	u.set("md5", "id")
+	_, ok = newUploadHashes("other").get("md5")
+	assert.False(t, ok)
+
+	// A corrupt file is ignored
+	require.NoError(t, ioutil.WriteFile(u.path, []byte("{"), 0600))
+	_, ok = newUploadHashes("remote").get("md5")
+	assert.False(t, ok)
+}
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+)
+
+// authTransport adds the credentials to the requests to the host of
+// the remote, answering digest challenges when the server sends them
+type authTransport struct {
+	wrapped http.RoundTripper
+	host    string // only requests to this host get the credentials
+	user    string
+	pass    string
+	bearer  string // bearer token which is used instead of user and pass
+
+	mu     sync.Mutex
+	digest *digestChallenge // the last digest challenge if any
+	nc     int              // number of requests made with its nonce
+}
+
+// newAuthTransport wraps transport to add the credentials to the
+// requests to host
+func newAuthTransport(transport http.RoundTripper, host, user, pass, bearer string) *authTransport {
+	return &authTransport{
+		wrapped: transport,
+		host:    host,
+		user:    user,
+		pass:    pass,
+		bearer:  bearer,
+	}
+}
+
+// RoundTrip makes the request with the credentials, retrying it with
+// a digest response if the server asks for one
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host {
+		// Don't send the credentials to other hosts we are
+		// redirected to
+		return t.wrapped.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	usedDigest := t.authorize(req)
+	res, err := t.wrapped.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || t.user == "" || req.Body != nil {
+		return res, err
+	}
+	challenge := findDigestChallenge(res.Header)
+	if challenge == nil || (usedDigest && !challenge.stale) {
+		return res, nil
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	_ = res.Body.Close()
+	t.mu.Lock()
+	t.digest, t.nc = challenge, 0
+	t.mu.Unlock()
+	req = req.Clone(req.Context())
+	t.authorize(req)
+	return t.wrapped.RoundTrip(req)
+}
+
+// authorize sets the Authorization header of req, returning whether
+// it is a digest response
+func (t *authTransport) authorize(req *http.Request) bool {
+	switch {
+	case t.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+t.bearer)
+	case t.user != "":
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.digest != nil {
+			t.nc++
+			req.Header.Set("Authorization", t.digest.response(req.Method, req.URL.RequestURI(), t.user, t.pass, t.nc))
+			return true
+		}
+		req.SetBasicAuth(t.user, t.pass)
+	}
+	return false
+}
+
+// digestChallenge is a WWW-Authenticate Digest challenge as in RFC 7616
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // "auth" if the server supports it, otherwise ""
+	stale     bool
+}
+
+// findDigestChallenge returns the first digest challenge in the
+// headers which uses an algorithm we support, or nil if there isn't one
+func findDigestChallenge(header http.Header) *digestChallenge {
+	for _, value := range header["Www-Authenticate"] {
+		if len(value) < 7 || !strings.EqualFold(value[:7], "Digest ") {
+			continue
+		}
+		params := parseAuthParams(value[7:])
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		for _, qop := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(qop) == "auth" {
+				c.qop = "auth"
+			}
+		}
+		if c.nonce != "" && c.hash() != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// parseAuthParams parses the comma separated key=value parameters of
+// an authentication challenge where the values may be quoted
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[i:]
+			if s != "" {
+				s = s[1:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[key] = value.String()
+	}
+}
+
+// hash returns a new hash for the algorithm of the challenge or nil if
+// it isn't supported
+func (c *digestChallenge) hash() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+	case "MD5":
+		return md5.New()
+	case "SHA-256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// h returns the hex encoded hash of the parts joined by colons
+func (c *digestChallenge) h(parts ...string) string {
+	h := c.hash()
+	_, _ = io.WriteString(h, strings.Join(parts, ":"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// response returns the Authorization header answering the challenge
+// for the nc-th request with the nonce
+func (c *digestChallenge) response(method, uri, user, pass string, nc int) string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	cnonce := hex.EncodeToString(b[:])
+	ncValue := fmt.Sprintf("%08x", nc)
+	ha1 := c.h(user, c.realm, pass)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = c.h(ha1, c.nonce, cnonce)
+	}
+	ha2 := c.h(method, uri)
+	var response string
+	if c.qop != "" {
+		response = c.h(ha1, c.nonce, ncValue, cnonce, c.qop, ha2)
+	} else {
+		response = c.h(ha1, c.nonce, ha2)
+	}
+	header := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		user, c.realm, c.nonce, uri, c.algorithm, response)
+	if c.opaque != "" {
+		header += fmt.Sprintf(`, opaque=%q`, c.opaque)
+	}
+	if c.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce=%q`, c.qop, ncValue, cnonce)
+	}
+	return header
+}
+
+// newCookieJar makes a cookie jar for the remote, loading the cookies
+// in cookieFile into it if set
+func newCookieJar(cookieFile string) (http.CookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	if cookieFile == "" {
+		return jar, nil
+	}
+	in, err := os.Open(cookieFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open cookie file")
+	}
+	defer func() { _ = in.Close() }()
+	err = loadCookies(jar, in)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read cookie file %q", cookieFile)
+	}
+	return jar, nil
+}
+
+// loadCookies reads cookies in the Netscape cookies.txt format which
+// browsers and curl export into jar
+//
+// Each line is the tab separated domain, whether subdomains are
+// included, path, whether the cookie is secure, expiry time in Unix
+// seconds, name and value.
+func loadCookies(jar http.CookieJar, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return errors.Errorf("line %d: expecting 7 tab separated fields but got %d", n, len(fields))
+		}
+		domain := strings.TrimPrefix(fields[0], ".")
+		secure := strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = domain
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return errors.Errorf("line %d: bad expiry time %q", n, fields[4])
+		}
+		if expires != 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: domain, Path: fields[2]}, []*http.Cookie{cookie})
+	}
+	return scanner.Err()
+}
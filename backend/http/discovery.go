@@ -0,0 +1,288 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+// maxSitemaps is the most sitemaps read from a sitemap index
+const maxSitemaps = 1000
+
+// entry is a name found in a listing with anything the listing said
+// about it
+type entry struct {
+	name    string    // name in the directory, ending in / for directories
+	size    int64     // size of the file or -1 if not known
+	modTime time.Time // modification time or timeUnset if not known
+	stat    bool      // set if the file needs a HEAD request to find its size
+}
+
+// namesToEntries makes entries for names found in an HTML page
+func namesToEntries(names []string) []entry {
+	entries := make([]entry, len(names))
+	for i, name := range names {
+		entries[i] = entry{name: name, size: -1, modTime: timeUnset, stat: true}
+	}
+	return entries
+}
+
+// jsonEntry is an entry in a JSON directory listing
+//
+// This understands the listings from nginx with autoindex_format json
+// and from Caddy when asked for application/json.
+type jsonEntry struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`     // nginx: file, directory or other
+	MTime   string `json:"mtime"`    // nginx: RFC 1123 time
+	IsDir   bool   `json:"is_dir"`   // Caddy
+	ModTime string `json:"mod_time"` // Caddy: RFC 3339 time
+	Size    *int64 `json:"size"`
+}
+
+// parseJSON turns a JSON directory listing into entries
+func parseJSON(in io.Reader) ([]entry, error) {
+	var items []jsonEntry
+	err := json.NewDecoder(in).Decode(&items)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]entry, 0, len(items))
+	for _, item := range items {
+		name := strings.TrimSuffix(item.Name, "/")
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") || item.Type == "other" {
+			continue
+		}
+		e := entry{name: name, size: -1, modTime: timeUnset}
+		if item.MTime != "" {
+			if t, err := http.ParseTime(item.MTime); err == nil {
+				e.modTime = t
+			}
+		} else if item.ModTime != "" {
+			if t, err := time.Parse(time.RFC3339Nano, item.ModTime); err == nil {
+				e.modTime = t
+			}
+		}
+		if item.IsDir || item.Type == "directory" {
+			e.name += "/"
+		} else if item.Size != nil {
+			e.size = *item.Size
+		} else {
+			e.stat = true
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// sitemapXML is a sitemap or a sitemap index as described at
+// https://www.sitemaps.org/protocol.html
+type sitemapXML struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// parseLastMod parses the W3C datetime of a sitemap
+func parseLastMod(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err == nil {
+			return t
+		}
+	}
+	return timeUnset
+}
+
+// sitemapTree is the tree of directories made from the URLs in the
+// sitemaps
+//
+// It is keyed by directory, "" for the root and ending in / otherwise,
+// the way List looks things up.
+type sitemapTree map[string][]entry
+
+// add adds the file or directory at remote, ending in / for
+// directories, and the directories above it
+func (tree sitemapTree) add(remote string, modTime time.Time) {
+	isDir := strings.HasSuffix(remote, "/")
+	remote = strings.Trim(remote, "/")
+	if remote == "" {
+		return
+	}
+	dir, leaf := path.Split(remote)
+	if isDir {
+		leaf += "/"
+		if _, ok := tree[remote+"/"]; !ok {
+			tree[remote+"/"] = nil
+		}
+	}
+	for _, e := range tree[dir] {
+		if e.name == leaf {
+			return
+		}
+	}
+	tree[dir] = append(tree[dir], entry{name: leaf, size: -1, modTime: modTime, stat: !isDir})
+	if dir != "" {
+		tree.add(dir, timeUnset)
+	}
+}
+
+// readSitemaps reads the sitemap at URL and any sitemaps it refers to
+// into a tree of what is under the root of the Fs
+func (f *Fs) readSitemaps(ctx context.Context, URL string) (sitemapTree, error) {
+	tree := sitemapTree{"": nil}
+	seen := map[string]bool{URL: true}
+	for queue := []string{URL}; len(queue) > 0; queue = queue[1:] {
+		sitemap, err := f.readSitemap(ctx, queue[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range sitemap.URLs {
+			remote, err := f.remoteFromURL(strings.TrimSpace(u.Loc))
+			if err != nil {
+				continue
+			}
+			tree.add(remote, parseLastMod(u.LastMod))
+		}
+		for _, s := range sitemap.Sitemaps {
+			loc := strings.TrimSpace(s.Loc)
+			if seen[loc] {
+				continue
+			}
+			if len(seen) >= maxSitemaps {
+				return nil, errors.Errorf("more than %d sitemaps", maxSitemaps)
+			}
+			seen[loc] = true
+			queue = append(queue, loc)
+		}
+	}
+	return tree, nil
+}
+
+// readSitemap reads the sitemap at URL, which may be gzipped
+func (f *Fs) readSitemap(ctx context.Context, URL string) (sitemap *sitemapXML, err error) {
+	req, err := http.NewRequest("GET", URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sitemap")
+	}
+	req = req.WithContext(ctx) // go1.13 can use NewRequestWithContext
+	f.addHeaders(req)
+	res, err := f.httpClient.Do(req)
+	err = statusError(res, err)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read sitemap %q", URL)
+	}
+	defer fs.CheckClose(res.Body, &err)
+	in := bufio.NewReader(res.Body)
+	var r io.Reader = in
+	if magic, _ := in.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read sitemap %q", URL)
+		}
+		defer fs.CheckClose(gz, &err)
+		r = gz
+	}
+	sitemap = new(sitemapXML)
+	err = xml.NewDecoder(r).Decode(sitemap)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse sitemap %q", URL)
+	}
+	return sitemap, nil
+}
+
+// remoteFromURL returns the path of URL relative to the root of the
+// Fs, ending in / if URL does, or an error if it isn't under the root
+func (f *Fs) remoteFromURL(URL string) (string, error) {
+	u, err := url.Parse(URL)
+	if err != nil {
+		return "", errURLJoinFailed
+	}
+	switch {
+	case u.RawQuery != "":
+		return "", errFoundQuestionMark
+	case u.Host != f.endpoint.Host:
+		return "", errHostMismatch
+	case u.Scheme != f.endpoint.Scheme:
+		return "", errSchemeMismatch
+	case !strings.HasPrefix(u.Path, f.endpoint.Path):
+		return "", errNotUnderRoot
+	}
+	return u.Path[len(f.endpoint.Path):], nil
+}
+
+// sitemap returns the tree read from the sitemap, reading it the
+// first time it is needed
+func (f *Fs) sitemap(ctx context.Context) (sitemapTree, error) {
+	f.sitemapMu.Lock()
+	defer f.sitemapMu.Unlock()
+	if f.sitemapTree != nil {
+		return f.sitemapTree, nil
+	}
+	base, err := url.Parse(f.opt.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u, err := rest.URLJoin(base, f.opt.Sitemap)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad sitemap URL")
+	}
+	tree, err := f.readSitemaps(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+	f.sitemapTree = tree
+	return tree, nil
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// This is only used with a sitemap, which lists everything at once.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) error {
+	tree, err := f.sitemap(ctx)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		dir += "/"
+	}
+	if _, ok := tree[dir]; !ok {
+		return fs.ErrorDirNotFound
+	}
+	var dirs []string
+	for d := range tree {
+		if strings.HasPrefix(d, dir) {
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	for _, d := range dirs {
+		entries := f.newEntries(ctx, d, tree[d])
+		if len(entries) == 0 {
+			continue
+		}
+		err = callback(entries)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
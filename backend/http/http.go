@@ -20,6 +20,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/rest"
@@ -98,6 +99,50 @@ If you set this option, rclone will not do the HEAD request.  This will mean
 `,
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "user",
+			Help: `User name for HTTP authentication
+
+This is used for basic authentication, or digest authentication if
+the server asks for it. It may also be given in the URL.`,
+			Advanced: true,
+		}, {
+			Name:       "pass",
+			Help:       "Password for HTTP authentication",
+			IsPassword: true,
+			Advanced:   true,
+		}, {
+			Name:     "bearer_token",
+			Help:     "Bearer token to send instead of the user and password",
+			Advanced: true,
+		}, {
+			Name: "use_cookies",
+			Help: `Keep the cookies the server sets and send them back
+
+This is like the global --use-cookies flag but the cookies are only
+used for this remote.`,
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "cookie_file",
+			Help: `Path to a cookies.txt file with cookies to send
+
+This should be in the Netscape format which browsers and curl export,
+eg to use the cookies from logging in to the site in a browser. The
+cookies the server sets are kept too.`,
+			Advanced: true,
+		}, {
+			Name: "sitemap",
+			Help: `URL of a sitemap to list the remote from
+
+If this is set then rclone finds the files and directories from the
+sitemap, or the sitemaps in a sitemap index, rather than from the HTML
+pages for the directories. It may be relative to the URL of the
+remote, eg "sitemap.xml", and may be gzipped.
+
+This finds files on sites without directory listings, and everything
+is listed at once which makes recursive listings quick.`,
+			Advanced: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -105,10 +150,16 @@ If you set this option, rclone will not do the HEAD request.  This will mean
 
 // Options defines the configuration for this backend
 type Options struct {
-	Endpoint string          `config:"url"`
-	NoSlash  bool            `config:"no_slash"`
-	NoHead   bool            `config:"no_head"`
-	Headers  fs.CommaSepList `config:"headers"`
+	Endpoint    string          `config:"url"`
+	NoSlash     bool            `config:"no_slash"`
+	NoHead      bool            `config:"no_head"`
+	Headers     fs.CommaSepList `config:"headers"`
+	User        string          `config:"user"`
+	Pass        string          `config:"pass"`
+	BearerToken string          `config:"bearer_token"`
+	UseCookies  bool            `config:"use_cookies"`
+	CookieFile  string          `config:"cookie_file"`
+	Sitemap     string          `config:"sitemap"`
 }
 
 // Fs stores the interface to the remote HTTP files
@@ -120,6 +171,9 @@ type Fs struct {
 	endpoint    *url.URL
 	endpointURL string // endpoint as a string
 	httpClient  *http.Client
+
+	sitemapMu   sync.Mutex  // protects sitemapTree
+	sitemapTree sitemapTree // files found in the sitemap once read
 }
 
 // Object is a remote object that has been stat'd (so it exists, but is not necessarily open for reading)
@@ -173,6 +227,26 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	}
 
 	client := fshttp.NewClient(fs.Config)
+	user, pass := opt.User, ""
+	if opt.Pass != "" {
+		pass, err = obscure.Reveal(opt.Pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't decrypt password")
+		}
+	}
+	if user == "" && base.User != nil {
+		user = base.User.Username()
+		pass, _ = base.User.Password()
+	}
+	if user != "" || opt.BearerToken != "" {
+		client.Transport = newAuthTransport(client.Transport, base.Host, user, pass, opt.BearerToken)
+	}
+	if opt.UseCookies || opt.CookieFile != "" {
+		client.Jar, err = newCookieJar(opt.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var isFile = false
 	if !strings.HasSuffix(u.String(), "/") {
@@ -221,6 +295,9 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	f.features = (&fs.Features{
 		CanHaveEmptyDirectories: true,
 	}).Fill(f)
+	if opt.Sitemap == "" {
+		f.features.ListR = nil
+	}
 	if isFile {
 		return f, fs.ErrorIsFile
 	}
@@ -379,7 +456,7 @@ func (f *Fs) addHeaders(req *http.Request) {
 }
 
 // Read the directory passed in
-func (f *Fs) readDir(ctx context.Context, dir string) (names []string, err error) {
+func (f *Fs) readDir(ctx context.Context, dir string) (entries []entry, err error) {
 	URL := f.url(dir)
 	u, err := url.Parse(URL)
 	if err != nil {
@@ -410,14 +487,20 @@ func (f *Fs) readDir(ctx context.Context, dir string) (names []string, err error
 	contentType := strings.SplitN(res.Header.Get("Content-Type"), ";", 2)[0]
 	switch contentType {
 	case "text/html":
-		names, err = parse(u, res.Body)
+		names, err := parse(u, res.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "readDir")
+		}
+		entries = namesToEntries(names)
+	case "application/json":
+		entries, err = parseJSON(res.Body)
 		if err != nil {
 			return nil, errors.Wrap(err, "readDir")
 		}
 	default:
 		return nil, errors.Errorf("Can't parse content type %q", contentType)
 	}
-	return names, nil
+	return entries, nil
 }
 
 // List the objects and directories in dir into entries.  The
@@ -433,14 +516,33 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	if !strings.HasSuffix(dir, "/") && dir != "" {
 		dir += "/"
 	}
-	names, err := f.readDir(ctx, dir)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error listing %q", dir)
+	var found []entry
+	if f.opt.Sitemap != "" {
+		tree, err := f.sitemap(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing %q", dir)
+		}
+		var ok bool
+		found, ok = tree[dir]
+		if !ok {
+			return nil, fs.ErrorDirNotFound
+		}
+	} else {
+		found, err = f.readDir(ctx, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error listing %q", dir)
+		}
 	}
+	return f.newEntries(ctx, dir, found), nil
+}
+
+// newEntries makes the directory entries for what was found in dir,
+// doing HEAD requests for the files that need them in parallel
+func (f *Fs) newEntries(ctx context.Context, dir string, found []entry) (entries fs.DirEntries) {
 	var (
 		entriesMu sync.Mutex // to protect entries
 		wg        sync.WaitGroup
-		in        = make(chan string, fs.Config.Checkers)
+		in        = make(chan entry, fs.Config.Checkers)
 	)
 	add := func(entry fs.DirEntry) {
 		entriesMu.Lock()
@@ -451,12 +553,17 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for remote := range in {
+			for e := range in {
+				remote := path.Join(dir, e.name)
 				file := &Object{
 					fs:     f,
 					remote: remote,
 				}
-				switch err := file.stat(ctx); err {
+				err := file.stat(ctx)
+				if err == nil && file.modTime == timeUnset {
+					file.modTime = e.modTime
+				}
+				switch err {
 				case nil:
 					add(file)
 				case fs.ErrorNotAFile:
@@ -468,19 +575,27 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 			}
 		}()
 	}
-	for _, name := range names {
-		isDir := name[len(name)-1] == '/'
-		name = strings.TrimRight(name, "/")
-		remote := path.Join(dir, name)
-		if isDir {
-			add(fs.NewDir(remote, timeUnset))
-		} else {
-			in <- remote
+	for _, e := range found {
+		isDir := e.name[len(e.name)-1] == '/'
+		remote := path.Join(dir, strings.TrimRight(e.name, "/"))
+		switch {
+		case isDir:
+			add(fs.NewDir(remote, e.modTime))
+		case e.stat:
+			in <- e
+		default:
+			add(&Object{
+				fs:          f,
+				remote:      remote,
+				size:        e.size,
+				modTime:     e.modTime,
+				contentType: fs.MimeTypeFromName(remote),
+			})
 		}
 	}
 	close(in)
 	wg.Wait()
-	return entries, nil
+	return entries
 }
 
 // Put in to the remote path with the modTime given of the given size
@@ -1,8 +1,11 @@
 package http
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/md5"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -17,6 +20,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/lib/rest"
 	"github.com/stretchr/testify/assert"
@@ -353,3 +357,222 @@ func TestParseCaddy(t *testing.T) {
 		"v1.36-22-g06ea13a-ssh-agentβ/",
 	})
 }
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="a \"b\", c", nonce=123, qop="auth,auth-int"`)
+	assert.Equal(t, map[string]string{
+		"realm": `a "b", c`,
+		"nonce": "123",
+		"qop":   "auth,auth-int",
+	}, params)
+}
+
+func TestDigestAuth(t *testing.T) {
+	const user, pass, realm, nonce = "user", "secret", "test realm", "abc123"
+	md5hex := func(s string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+	}
+	fileServer := http.FileServer(http.Dir(filesPath))
+	challenges := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Digest ") {
+			challenges++
+			w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", nonce="`+nonce+`", qop="auth", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := parseAuthParams(auth[7:])
+		ha1 := md5hex(user + ":" + realm + ":" + pass)
+		ha2 := md5hex(r.Method + ":" + r.URL.RequestURI())
+		want := md5hex(ha1 + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+		if p["username"] != user || p["opaque"] != "xyz" || p["response"] != want {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	f, err := NewFs(remoteName, "", configmap.Simple{
+		"url":  ts.URL,
+		"user": user,
+		"pass": obscure.MustObscure(pass),
+	})
+	require.NoError(t, err)
+	entries, err := f.List(context.Background(), "three")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	assert.Equal(t, int64(9), entries[0].Size())
+	// Only the first request should have needed a challenge
+	assert.Equal(t, 1, challenges)
+
+	f, err = NewFs(remoteName, "", configmap.Simple{
+		"url":  ts.URL,
+		"user": user,
+		"pass": obscure.MustObscure("wrong"),
+	})
+	require.NoError(t, err)
+	_, err = f.List(context.Background(), "three")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestBearerTokenAndCookies(t *testing.T) {
+	fileServer := http.FileServer(http.Dir(filesPath))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if r.Header.Get("Authorization") != "Bearer token" || err != nil || cookie.Value != "potato" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	cookieFile, err := ioutil.TempFile("", "rclone-cookies")
+	require.NoError(t, err)
+	defer func() { _ = os.Remove(cookieFile.Name()) }()
+	_, err = fmt.Fprintf(cookieFile, "# Netscape HTTP Cookie File\n%s\tFALSE\t/\tFALSE\t0\tsession\tpotato\n", u.Hostname())
+	require.NoError(t, err)
+	require.NoError(t, cookieFile.Close())
+
+	f, err := NewFs(remoteName, "", configmap.Simple{
+		"url":          ts.URL,
+		"bearer_token": "token",
+		"cookie_file":  cookieFile.Name(),
+	})
+	require.NoError(t, err)
+	o, err := f.NewObject(context.Background(), "three/underthree.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), o.Size())
+}
+
+func TestLoadCookiesBad(t *testing.T) {
+	jar, err := newCookieJar("")
+	require.NoError(t, err)
+	err = loadCookies(jar, strings.NewReader("example.com\tFALSE\t/\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1")
+}
+
+func TestParseJSON(t *testing.T) {
+	// nginx with autoindex_format json
+	entries, err := parseJSON(strings.NewReader(`[
+{ "name":"dir", "type":"directory", "mtime":"Mon, 06 Jul 2020 10:00:00 GMT" },
+{ "name":"file.txt", "type":"file", "mtime":"Mon, 06 Jul 2020 10:00:00 GMT", "size":123 },
+{ "name":"link", "type":"other", "mtime":"Mon, 06 Jul 2020 10:00:00 GMT" }
+]`))
+	require.NoError(t, err)
+	modTime := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, []entry{
+		{name: "dir/", size: -1, modTime: modTime},
+		{name: "file.txt", size: 123, modTime: modTime},
+	}, entries)
+
+	// Caddy
+	entries, err = parseJSON(strings.NewReader(`[
+{"name":"dir/","size":4096,"url":"./dir/","mod_time":"2020-07-06T10:00:00Z","mode":2147484141,"is_dir":true,"is_symlink":false},
+{"name":"file.txt","size":123,"url":"./file.txt","mod_time":"2020-07-06T10:00:00Z","mode":420,"is_dir":false,"is_symlink":false},
+{"name":"../escape","size":1,"is_dir":false}
+]`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	assert.Equal(t, "dir/", entries[0].name)
+	assert.Equal(t, entry{name: "file.txt", size: 123, modTime: modTime}, entries[1])
+
+	_, err = parseJSON(strings.NewReader(`{"not":"a list"}`))
+	assert.Error(t, err)
+}
+
+func TestListJSON(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			// Shouldn't be needed as the listing has the sizes
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `[{"name":"file.txt","type":"file","mtime":"Mon, 06 Jul 2020 10:00:00 GMT","size":5}]`)
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	f, err := NewFs(remoteName, "", configmap.Simple{"url": ts.URL})
+	require.NoError(t, err)
+	entries, err := f.List(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	o, ok := entries[0].(*Object)
+	require.True(t, ok)
+	assert.Equal(t, int64(5), o.Size())
+	assert.Equal(t, time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC), o.ModTime(context.Background()))
+	assert.Equal(t, "text/plain; charset=utf-8", o.MimeType(context.Background()))
+}
+
+func TestSitemap(t *testing.T) {
+	fileServer := http.FileServer(http.Dir(filesPath))
+	var ts *httptest.Server
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%s/sitemap1.xml.gz</loc></sitemap>
+  <sitemap><loc>%s/sitemap.xml</loc></sitemap>
+</sitemapindex>`, ts.URL, ts.URL)
+		case "/sitemap1.xml.gz":
+			gz := gzip.NewWriter(w)
+			_, _ = fmt.Fprintf(gz, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>%s/one%%25.txt</loc><lastmod>2020-07-06</lastmod></url>
+  <url><loc>%s/three/underthree.txt</loc></url>
+  <url><loc>%s/four/</loc></url>
+  <url><loc>%s/four/under%%20four.txt</loc></url>
+  <url><loc>%s/page?query=1</loc></url>
+  <url><loc>https://elsewhere.example.com/file.txt</loc></url>
+</urlset>`, ts.URL, ts.URL, ts.URL, ts.URL, ts.URL)
+			_ = gz.Close()
+		default:
+			fileServer.ServeHTTP(w, r)
+		}
+	})
+	ts = httptest.NewServer(handler)
+	defer ts.Close()
+
+	f, err := NewFs(remoteName, "", configmap.Simple{"url": ts.URL, "sitemap": "sitemap.xml"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	sort.Sort(entries)
+	require.Equal(t, 3, len(entries))
+	assert.Equal(t, "four", entries[0].Remote())
+	assert.Equal(t, "one%.txt", entries[1].Remote())
+	assert.Equal(t, int64(6), entries[1].Size())
+	assert.Equal(t, "three", entries[2].Remote())
+
+	_, err = f.List(ctx, "five")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+
+	var remotes []string
+	err = f.Features().ListR(ctx, "", func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			remotes = append(remotes, entry.Remote())
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(remotes)
+	assert.Equal(t, []string{"four", "four/under four.txt", "one%.txt", "three", "three/underthree.txt"}, remotes)
+
+	// Without a sitemap there is no ListR
+	f, err = NewFs(remoteName, "", configmap.Simple{"url": ts.URL})
+	require.NoError(t, err)
+	assert.Nil(t, f.Features().ListR)
+}
@@ -0,0 +1,252 @@
+// Package api has type definitions for iCloud Drive
+//
+// Converted from the API calls made by the iCloud web app as there
+// is no public documentation of the API.
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// Types of Item
+const (
+	ItemTypeFile       = "FILE"
+	ItemTypeFolder     = "FOLDER"
+	ItemTypeAppLibrary = "APP_LIBRARY"
+)
+
+// Error is returned from the iCloud services when things go wrong
+//
+// The sign in service returns serviceErrors, the others one of the
+// other fields.
+type Error struct {
+	Status        string         `json:"-"` // HTTP status of the response
+	ServiceErrors []ServiceError `json:"serviceErrors"`
+	Reason        string         `json:"reason"`
+	ErrorCode     string         `json:"errorCode"`
+	ErrorMessage  string         `json:"errorMessage"`
+}
+
+// ServiceError describes a single error from the sign in service
+type ServiceError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error satisfies the error interface
+func (e *Error) Error() string {
+	out := e.Status
+	switch {
+	case len(e.ServiceErrors) > 0:
+		out += fmt.Sprintf(": %s (%s)", e.ServiceErrors[0].Message, e.ServiceErrors[0].Code)
+	case e.ErrorMessage != "":
+		out += fmt.Sprintf(": %s (%s)", e.ErrorMessage, e.ErrorCode)
+	case e.Reason != "":
+		out += ": " + e.Reason
+	}
+	return out
+}
+
+// SigninRequest is sent to sign in with the Apple ID and password
+type SigninRequest struct {
+	AccountName string   `json:"accountName"`
+	Password    string   `json:"password"`
+	RememberMe  bool     `json:"rememberMe"`
+	TrustTokens []string `json:"trustTokens"`
+}
+
+// SecurityCodeRequest is sent to verify a two-factor authentication
+// code from a trusted device
+type SecurityCodeRequest struct {
+	SecurityCode struct {
+		Code string `json:"code"`
+	} `json:"securityCode"`
+}
+
+// AccountLoginRequest is sent to log in to iCloud with the session
+// token from signing in
+type AccountLoginRequest struct {
+	AccountCountryCode string `json:"accountCountryCode"`
+	DsWebAuthToken     string `json:"dsWebAuthToken"`
+	ExtendedLogin      bool   `json:"extended_login"`
+	TrustToken         string `json:"trustToken"`
+}
+
+// AccountLoginResponse describes the account logged in to and the
+// URLs of its web services
+type AccountLoginResponse struct {
+	DsInfo struct {
+		Dsid string `json:"dsid"`
+	} `json:"dsInfo"`
+	Webservices map[string]Webservice `json:"webservices"`
+}
+
+// Webservice is a web service of the account
+type Webservice struct {
+	URL    string `json:"url"`
+	Status string `json:"status"`
+}
+
+// StorageUsageResponse is returned from storageUsageInfo
+type StorageUsageResponse struct {
+	StorageUsageInfo struct {
+		TotalStorageInBytes int64 `json:"totalStorageInBytes"`
+		UsedStorageInBytes  int64 `json:"usedStorageInBytes"`
+	} `json:"storageUsageInfo"`
+}
+
+// Item describes a file or a folder in iCloud Drive
+type Item struct {
+	DrivewsID    string    `json:"drivewsid"` // TYPE::zone::docwsid
+	DocwsID      string    `json:"docwsid"`
+	Zone         string    `json:"zone"`
+	Name         string    `json:"name"`      // without the extension
+	Extension    string    `json:"extension"` // of files only
+	Etag         string    `json:"etag"`
+	Type         string    `json:"type"`
+	Size         int64     `json:"size"`
+	DateCreated  time.Time `json:"dateCreated"`
+	DateModified time.Time `json:"dateModified"`
+	Items        []Item    `json:"items"` // contents of folders
+}
+
+// FullName returns the name of the item with its extension
+func (i *Item) FullName() string {
+	if i.Extension == "" {
+		return i.Name
+	}
+	return i.Name + "." + i.Extension
+}
+
+// ModTime returns the modification time of the item
+func (i *Item) ModTime() time.Time {
+	if i.DateModified.IsZero() {
+		return i.DateCreated
+	}
+	return i.DateModified
+}
+
+// FolderRequest asks for the details of a folder in
+// retrieveItemDetailsInFolders
+type FolderRequest struct {
+	DrivewsID   string `json:"drivewsid"`
+	PartialData bool   `json:"partialData"`
+}
+
+// ItemRef refers to an item in moveItemsToTrash and renameItems
+type ItemRef struct {
+	DrivewsID string `json:"drivewsid"`
+	Etag      string `json:"etag"`
+	ClientID  string `json:"clientId,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// ItemsRequest is sent to moveItemsToTrash and renameItems
+type ItemsRequest struct {
+	Items []ItemRef `json:"items"`
+}
+
+// ItemsResponse is returned from moveItemsToTrash and renameItems
+type ItemsResponse struct {
+	Items []Item `json:"items"`
+}
+
+// NewFolder is a folder to create in createFolders
+type NewFolder struct {
+	ClientID string `json:"clientId"`
+	Name     string `json:"name"`
+}
+
+// CreateFoldersRequest is sent to createFolders
+type CreateFoldersRequest struct {
+	DestinationDrivewsID string      `json:"destinationDrivewsId"`
+	Folders              []NewFolder `json:"folders"`
+}
+
+// CreateFoldersResponse is returned from createFolders
+type CreateFoldersResponse struct {
+	Folders []Item `json:"folders"`
+}
+
+// DownloadResponse is returned from download/by_id
+type DownloadResponse struct {
+	DataToken struct {
+		URL string `json:"url"`
+	} `json:"data_token"`
+	PackageToken struct {
+		URL string `json:"url"`
+	} `json:"package_token"`
+}
+
+// UploadRequest is sent to upload/web to get a URL to upload to
+type UploadRequest struct {
+	Filename    string `json:"filename"`
+	Type        string `json:"type"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// UploadURL is returned from upload/web
+type UploadURL struct {
+	DocumentID string `json:"document_id"`
+	URL        string `json:"url"`
+}
+
+// UploadResponse is returned from uploading the file contents
+type UploadResponse struct {
+	SingleFile UploadedFile `json:"singleFile"`
+}
+
+// UploadedFile describes the file contents uploaded
+type UploadedFile struct {
+	FileChecksum      string `json:"fileChecksum"`
+	WrappingKey       string `json:"wrappingKey"`
+	ReferenceChecksum string `json:"referenceChecksum"`
+	Receipt           string `json:"receipt"`
+	Size              int64  `json:"size"`
+}
+
+// UpdateDocumentsRequest is sent to update/documents to add the
+// uploaded file contents to a folder
+type UpdateDocumentsRequest struct {
+	Data            UpdateData `json:"data"`
+	Command         string     `json:"command"`
+	CreateShortGUID bool       `json:"create_short_guid"`
+	DocumentID      string     `json:"document_id"`
+	Path            struct {
+		StartingDocumentID string `json:"starting_document_id"`
+		Path               string `json:"path"`
+	} `json:"path"`
+	AllowConflict bool `json:"allow_conflict"`
+	FileFlags     struct {
+		IsWritable   bool `json:"is_writable"`
+		IsExecutable bool `json:"is_executable"`
+		IsHidden     bool `json:"is_hidden"`
+	} `json:"file_flags"`
+	Mtime int64 `json:"mtime"` // milliseconds since the epoch
+	Btime int64 `json:"btime"` // milliseconds since the epoch
+}
+
+// UpdateData refers to the uploaded file contents
+type UpdateData struct {
+	Signature          string `json:"signature,omitempty"`
+	WrappingKey        string `json:"wrapping_key,omitempty"`
+	ReferenceSignature string `json:"reference_signature,omitempty"`
+	Receipt            string `json:"receipt,omitempty"`
+	Size               int64  `json:"size"`
+}
+
+// UpdateDocumentsResponse is returned from update/documents
+type UpdateDocumentsResponse struct {
+	Results []struct {
+		Document struct {
+			DocumentID string `json:"document_id"`
+			Etag       string `json:"etag"`
+		} `json:"document"`
+		Status struct {
+			StatusCode   int    `json:"status_code"`
+			ErrorMessage string `json:"error_message"`
+		} `json:"status"`
+	} `json:"results"`
+}
@@ -0,0 +1,976 @@
+// Package iclouddrive provides an interface to iCloud Drive
+package iclouddrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/iclouddrive/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/dircache"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+const (
+	minSleep      = 10 * time.Millisecond
+	maxSleep      = 2 * time.Second
+	decayConstant = 2 // bigger for slower decay, exponential
+	driveZone     = "com.apple.CloudDocs"
+	rootID        = "FOLDER::" + driveZone + "::root" // ID of root folder is always this
+	// the client ID of the iCloud web app
+	defaultClientID  = "d39ba9916b7251055b22c7f910e2ea796ee65e98b2ddecea8f5dde8d9d1a815d"
+	configCookies    = "cookies"
+	configTrustToken = "trust_token"
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "iclouddrive",
+		Description: "iCloud Drive",
+		NewFs:       NewFs,
+		Config:      Config,
+		Options: []fs.Option{{
+			Name:     "apple_id",
+			Help:     "Apple ID.",
+			Required: true,
+		}, {
+			Name:       "password",
+			Help:       "Password.",
+			Required:   true,
+			IsPassword: true,
+		}, {
+			Name:    configTrustToken,
+			Help:    "Trust token to sign in without two-factor authentication (internal use only)",
+			Hide:    fs.OptionHideBoth,
+			Default: "",
+		}, {
+			Name:    configCookies,
+			Help:    "Cookies of the session (internal use only)",
+			Hide:    fs.OptionHideBoth,
+			Default: "",
+		}, {
+			Name:     "client_id",
+			Help:     "Client ID of the iCloud web app to sign in as.",
+			Default:  defaultClientID,
+			Advanced: true,
+		}, {
+			Name:     config.ConfigEncoding,
+			Help:     config.ConfigEncodingHelp,
+			Advanced: true,
+			Default: (encoder.Display |
+				encoder.EncodeBackSlash |
+				encoder.EncodeInvalidUtf8),
+		}},
+	})
+}
+
+// Config signs in to iCloud, asking for the two-factor authentication
+// code if needed, and saves the session.
+func Config(name string, m configmap.Mapper) {
+	// Stop if we are running non-interactive config
+	if fs.Config.AutoConfirm {
+		return
+	}
+	ctx := context.TODO()
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		log.Fatalf("Failed to read options: %v", err)
+	}
+	s, err := newSession(name, m, opt)
+	if err != nil {
+		log.Fatalf("Failed to configure iCloud Drive: %v", err)
+	}
+	need2FA, err := s.signin(ctx)
+	if err != nil {
+		log.Fatalf("Failed to sign in: %v", err)
+	}
+	for need2FA {
+		code := ""
+		for code == "" {
+			fmt.Print("Two-factor authentication: enter the code shown on your trusted device\n2fa code> ")
+			code = strings.TrimSpace(config.ReadLine())
+		}
+		err = s.verifyCode(ctx, code)
+		if err == nil {
+			break
+		}
+		fmt.Printf("Verification failed: %v\nTry again?\n", err)
+		if !config.Confirm(true) {
+			log.Fatalf("Failed to sign in: %v", err)
+		}
+	}
+	err = s.accountLogin(ctx)
+	if err != nil {
+		log.Fatalf("Failed to log in: %v", err)
+	}
+	s.save()
+	fmt.Println("Signed in to iCloud")
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	AppleID    string               `config:"apple_id"`
+	Password   string               `config:"password"`
+	TrustToken string               `config:"trust_token"`
+	Cookies    string               `config:"cookies"`
+	ClientID   string               `config:"client_id"`
+	Enc        encoder.MultiEncoder `config:"encoding"`
+}
+
+// Fs represents a remote iCloud Drive
+type Fs struct {
+	name     string             // name of this remote
+	root     string             // the path we are working on
+	opt      Options            // parsed options
+	features *fs.Features       // optional features
+	session  *session           // the signed in session
+	srv      *rest.Client       // the connection to the server
+	dirCache *dircache.DirCache // Map of directory path to directory id
+	pacer    *fs.Pacer          // pacer for API calls
+}
+
+// Object describes a file
+type Object struct {
+	fs          *Fs       // what this object is part of
+	remote      string    // The remote path
+	hasMetaData bool      // metadata is present and correct
+	size        int64     // size of the object
+	modTime     time.Time // modification time of the object
+	id          string    // drivewsid of the object
+	docID       string    // docwsid of the object
+	zone        string    // zone the object is in
+	etag        string    // etag of the object
+}
+
+// ------------------------------------------------------------
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("iCloud Drive root '%s'", f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// parsePath parses an iCloud Drive 'url'
+func parsePath(path string) (root string) {
+	root = strings.Trim(path, "/")
+	return
+}
+
+// splitID splits a drivewsid of the form TYPE::zone::docwsid into its
+// zone and docwsid
+func splitID(id string) (zone, docID string) {
+	parts := strings.SplitN(id, "::", 3)
+	if len(parts) != 3 {
+		return driveZone, id
+	}
+	return parts[1], parts[2]
+}
+
+// retryErrorCodes is a slice of error codes that we will retry
+var retryErrorCodes = []int{
+	429, // Too Many Requests.
+	500, // Internal Server Error
+	502, // Bad Gateway
+	503, // Service Unavailable
+	504, // Gateway Timeout
+	509, // Bandwidth Limit Exceeded
+}
+
+// shouldRetry returns a boolean as to whether this resp and err
+// deserve to be retried.  It returns the err as a convenience
+//
+// If the session has expired it logs in again first.
+func (f *Fs) shouldRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == 421) {
+		fs.Debugf(f, "Session expired - logging in again")
+		loginErr := f.session.login(ctx, true)
+		if loginErr != nil {
+			return false, errors.Wrap(loginErr, "failed to log in again")
+		}
+		return true, err
+	}
+	return fserrors.ShouldRetry(err) || fserrors.ShouldRetryHTTP(resp, retryErrorCodes), err
+}
+
+// errorHandler parses a non 2xx error response into an error
+func errorHandler(resp *http.Response) error {
+	body, err := rest.ReadBody(resp)
+	if err != nil {
+		body = nil
+	}
+	var e = api.Error{
+		Status: fmt.Sprintf("%s (%d)", resp.Status, resp.StatusCode),
+	}
+	if body != nil {
+		_ = json.Unmarshal(body, &e)
+	}
+	return &e
+}
+
+// callJSON calls a web service of the account with the account ID
+// added to the parameters
+func (f *Fs) callJSON(ctx context.Context, opts *rest.Opts, request interface{}, response interface{}) (err error) {
+	dsid, _, _ := f.session.account()
+	if opts.Parameters == nil {
+		opts.Parameters = url.Values{}
+	}
+	opts.Parameters.Set("dsid", dsid)
+	var resp *http.Response
+	return f.pacer.Call(func() (bool, error) {
+		resp, err = f.srv.CallJSON(ctx, opts, request, response)
+		return f.shouldRetry(ctx, resp, err)
+	})
+}
+
+// drivewsOpts returns the options to call method of the drive web service
+func (f *Fs) drivewsOpts(method string) rest.Opts {
+	_, drivews, _ := f.session.account()
+	return rest.Opts{
+		Method:  "POST",
+		RootURL: drivews,
+		Path:    "/" + method,
+	}
+}
+
+// docwsOpts returns the options to call method of the documents web
+// service for zone
+func (f *Fs) docwsOpts(httpMethod, zone, method string) rest.Opts {
+	_, _, docws := f.session.account()
+	return rest.Opts{
+		Method:  httpMethod,
+		RootURL: docws,
+		Path:    "/ws/" + zone + "/" + method,
+	}
+}
+
+// readMetaDataForPath reads the metadata from the path
+func (f *Fs) readMetaDataForPath(ctx context.Context, path string) (info *api.Item, err error) {
+	leaf, directoryID, err := f.dirCache.FindPath(ctx, path, false)
+	if err != nil {
+		if err == fs.ErrorDirNotFound {
+			return nil, fs.ErrorObjectNotFound
+		}
+		return nil, err
+	}
+
+	lcLeaf := strings.ToLower(leaf)
+	found, err := f.listAll(ctx, directoryID, false, true, func(item *api.Item) bool {
+		if strings.ToLower(item.Name) == lcLeaf {
+			info = item
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return info, nil
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
+	ctx := context.Background()
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	root = parsePath(root)
+
+	s, err := newSession(name, m, opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure iCloud Drive")
+	}
+	err = s.login(ctx, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to log in to iCloud")
+	}
+
+	f := &Fs{
+		name:    name,
+		root:    root,
+		opt:     *opt,
+		session: s,
+		srv:     s.srv,
+		pacer:   fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+	}
+	f.features = (&fs.Features{
+		CaseInsensitive:         true,
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+
+	// Get rootID
+	f.dirCache = dircache.New(root, rootID, f)
+
+	// Find the current root
+	err = f.dirCache.FindRoot(ctx, false)
+	if err != nil {
+		// Assume it is a file
+		newRoot, remote := dircache.SplitPath(root)
+		tempF := *f
+		tempF.dirCache = dircache.New(newRoot, rootID, &tempF)
+		tempF.root = newRoot
+		// Make new Fs which is the parent
+		err = tempF.dirCache.FindRoot(ctx, false)
+		if err != nil {
+			// No root so return old f
+			return f, nil
+		}
+		_, err := tempF.newObjectWithInfo(ctx, remote, nil)
+		if err != nil {
+			if err == fs.ErrorObjectNotFound {
+				// File doesn't exist so return old f
+				return f, nil
+			}
+			return nil, err
+		}
+		f.features.Fill(&tempF)
+		// XXX: update the old f here instead of returning tempF, since
+		// `features` were already filled with functions having *f as a receiver.
+		// See https://github.com/rclone/rclone/issues/2182
+		f.dirCache = tempF.dirCache
+		f.root = tempF.root
+		// return an error with an fs which points to the parent
+		return f, fs.ErrorIsFile
+	}
+	return f, nil
+}
+
+// Return an Object from a path
+//
+// If it can't be found it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) newObjectWithInfo(ctx context.Context, remote string, info *api.Item) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	var err error
+	if info != nil {
+		// Set info
+		err = o.setMetaData(info)
+	} else {
+		err = o.readMetaData(ctx) // reads info and meta, returning an error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	return f.newObjectWithInfo(ctx, remote, nil)
+}
+
+// FindLeaf finds a directory of name leaf in the folder with ID pathID
+func (f *Fs) FindLeaf(ctx context.Context, pathID, leaf string) (pathIDOut string, found bool, err error) {
+	// Find the leaf in pathID
+	found, err = f.listAll(ctx, pathID, true, false, func(item *api.Item) bool {
+		if item.Name == leaf {
+			pathIDOut = item.DrivewsID
+			return true
+		}
+		return false
+	})
+	return pathIDOut, found, err
+}
+
+// CreateDir makes a directory with pathID as parent and name leaf
+func (f *Fs) CreateDir(ctx context.Context, pathID, leaf string) (newID string, err error) {
+	opts := f.drivewsOpts("createFolders")
+	request := api.CreateFoldersRequest{
+		DestinationDrivewsID: pathID,
+		Folders: []api.NewFolder{{
+			ClientID: "FOLDER::UNKNOWN_ZONE::TempId-" + uuid.New().String(),
+			Name:     f.opt.Enc.FromStandardName(leaf),
+		}},
+	}
+	var result api.CreateFoldersResponse
+	err = f.callJSON(ctx, &opts, &request, &result)
+	if err != nil {
+		return "", errors.Wrap(err, "CreateDir")
+	}
+	if len(result.Folders) == 0 || result.Folders[0].DrivewsID == "" {
+		return "", errors.New("CreateDir: no folder returned")
+	}
+	return result.Folders[0].DrivewsID, nil
+}
+
+// getFolder reads the folder with ID dirID and its contents
+func (f *Fs) getFolder(ctx context.Context, dirID string) (*api.Item, error) {
+	opts := f.drivewsOpts("retrieveItemDetailsInFolders")
+	request := []api.FolderRequest{{
+		DrivewsID:   dirID,
+		PartialData: false,
+	}}
+	var result []api.Item
+	err := f.callJSON(ctx, &opts, &request, &result)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't list files")
+	}
+	if len(result) == 0 || result[0].Type == "" {
+		return nil, fs.ErrorDirNotFound
+	}
+	return &result[0], nil
+}
+
+// list the objects into the function supplied
+//
+// If directories is set it only sends directories
+// User function to process a File item from listAll
+//
+// Should return true to finish processing
+type listAllFn func(*api.Item) bool
+
+// Lists the directory required calling the user function on each item found
+//
+// If the user fn ever returns true then it early exits with found = true
+func (f *Fs) listAll(ctx context.Context, dirID string, directoriesOnly bool, filesOnly bool, fn listAllFn) (found bool, err error) {
+	folder, err := f.getFolder(ctx, dirID)
+	if err != nil {
+		return found, err
+	}
+	for i := range folder.Items {
+		item := &folder.Items[i]
+		if item.Type == api.ItemTypeFolder {
+			if filesOnly {
+				continue
+			}
+		} else if item.Type == api.ItemTypeFile {
+			if directoriesOnly {
+				continue
+			}
+		} else {
+			fs.Debugf(f, "Ignoring %q - unknown type %q", item.Name, item.Type)
+			continue
+		}
+		item.Name = f.opt.Enc.ToStandardName(item.FullName())
+		item.Extension = ""
+		if fn(item) {
+			found = true
+			break
+		}
+	}
+	return
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	directoryID, err := f.dirCache.FindDir(ctx, dir, false)
+	if err != nil {
+		return nil, err
+	}
+	var iErr error
+	_, err = f.listAll(ctx, directoryID, false, false, func(info *api.Item) bool {
+		remote := path.Join(dir, info.Name)
+		if info.Type == api.ItemTypeFolder {
+			// cache the directory ID for later lookups
+			f.dirCache.Put(remote, info.DrivewsID)
+			d := fs.NewDir(remote, info.ModTime()).SetID(info.DrivewsID)
+			entries = append(entries, d)
+		} else {
+			o, err := f.newObjectWithInfo(ctx, remote, info)
+			if err != nil {
+				iErr = err
+				return true
+			}
+			entries = append(entries, o)
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if iErr != nil {
+		return nil, iErr
+	}
+	return entries, nil
+}
+
+// Put the object
+//
+// Copy the reader in to the new object which is returned
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	existingObj, err := f.newObjectWithInfo(ctx, src.Remote(), nil)
+	switch err {
+	case nil:
+		return existingObj, existingObj.Update(ctx, in, src, options...)
+	case fs.ErrorObjectNotFound:
+		// Not found so create it
+		o := &Object{
+			fs:     f,
+			remote: src.Remote(),
+		}
+		return o, o.Update(ctx, in, src, options...)
+	default:
+		return nil, err
+	}
+}
+
+// Mkdir creates the container if it doesn't exist
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	_, err := f.dirCache.FindDir(ctx, dir, true)
+	return err
+}
+
+// trash moves the item with id and etag to the trash
+func (f *Fs) trash(ctx context.Context, id, etag string) error {
+	opts := f.drivewsOpts("moveItemsToTrash")
+	request := api.ItemsRequest{
+		Items: []api.ItemRef{{
+			DrivewsID: id,
+			Etag:      etag,
+			ClientID:  id,
+		}},
+	}
+	var result api.ItemsResponse
+	return f.callJSON(ctx, &opts, &request, &result)
+}
+
+// purgeCheck removes the root directory, if check is set then it
+// refuses to do so if it has anything in
+func (f *Fs) purgeCheck(ctx context.Context, dir string, check bool) error {
+	root := path.Join(f.root, dir)
+	if root == "" {
+		return errors.New("can't purge root directory")
+	}
+	dc := f.dirCache
+	rootID, err := dc.FindDir(ctx, dir, false)
+	if err != nil {
+		return err
+	}
+
+	folder, err := f.getFolder(ctx, rootID)
+	if err != nil {
+		return errors.Wrap(err, "purgeCheck")
+	}
+	if check && len(folder.Items) > 0 {
+		return fs.ErrorDirectoryNotEmpty
+	}
+
+	err = f.trash(ctx, rootID, folder.Etag)
+	if err != nil {
+		return errors.Wrap(err, "rmdir failed")
+	}
+	f.dirCache.FlushDir(dir)
+	return nil
+}
+
+// Rmdir deletes the root folder
+//
+// Returns an error if it isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	return f.purgeCheck(ctx, dir, true)
+}
+
+// Precision return the precision of this Fs
+func (f *Fs) Precision() time.Duration {
+	return time.Second
+}
+
+// Purge deletes all the files in the directory
+//
+// Optional interface: Only implement this if you have a way of
+// deleting all the files quicker than just running Remove() on the
+// result of List()
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	return f.purgeCheck(ctx, dir, false)
+}
+
+// About gets quota information
+func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
+	opts := rest.Opts{
+		Method:  "POST",
+		RootURL: setupURL,
+		Path:    "/storageUsageInfo",
+	}
+	var info api.StorageUsageResponse
+	err = f.callJSON(ctx, &opts, nil, &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "about failed")
+	}
+	total := info.StorageUsageInfo.TotalStorageInBytes
+	used := info.StorageUsageInfo.UsedStorageInBytes
+	usage = &fs.Usage{
+		Total: fs.NewUsageValue(total),
+		Used:  fs.NewUsageValue(used),
+		Free:  fs.NewUsageValue(total - used),
+	}
+	return usage, nil
+}
+
+// DirCacheFlush resets the directory cache - used in testing as an
+// optional interface
+func (f *Fs) DirCacheFlush() {
+	f.dirCache.ResetRoot()
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the hash of an object - iCloud Drive doesn't have any
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	err := o.readMetaData(context.TODO())
+	if err != nil {
+		fs.Logf(o, "Failed to read metadata: %v", err)
+		return 0
+	}
+	return o.size
+}
+
+// setMetaData sets the metadata from info
+func (o *Object) setMetaData(info *api.Item) (err error) {
+	if info.Type != api.ItemTypeFile {
+		return errors.Wrapf(fs.ErrorNotAFile, "%q is %q", o.remote, info.Type)
+	}
+	o.hasMetaData = true
+	o.size = info.Size
+	o.modTime = info.ModTime()
+	o.id = info.DrivewsID
+	o.docID = info.DocwsID
+	o.zone = info.Zone
+	o.etag = info.Etag
+	if o.zone == "" || o.docID == "" {
+		o.zone, o.docID = splitID(o.id)
+	}
+	return nil
+}
+
+// readMetaData gets the metadata if it hasn't already been fetched
+//
+// it also sets the info
+func (o *Object) readMetaData(ctx context.Context) (err error) {
+	if o.hasMetaData {
+		return nil
+	}
+	info, err := o.fs.readMetaDataForPath(ctx, o.remote)
+	if err != nil {
+		return err
+	}
+	return o.setMetaData(info)
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		fs.Logf(o, "Failed to read metadata: %v", err)
+		return time.Now()
+	}
+	return o.modTime
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	return fs.ErrorCantSetModTime
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	err = o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := o.fs.docwsOpts("GET", o.zone, "download/by_id")
+	opts.Parameters = url.Values{"document_id": {o.docID}}
+	var info api.DownloadResponse
+	err = o.fs.callJSON(ctx, &opts, nil, &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get download URL")
+	}
+	downloadURL := info.DataToken.URL
+	if downloadURL == "" {
+		downloadURL = info.PackageToken.URL
+	}
+	if downloadURL == "" {
+		if o.size == 0 {
+			// empty files have no contents to download
+			return ioutil.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return nil, errors.New("can't download - no URL")
+	}
+	fs.FixRangeOption(options, o.size)
+	var resp *http.Response
+	opts = rest.Opts{
+		Method:  "GET",
+		RootURL: downloadURL,
+		Options: options,
+	}
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.srv.Call(ctx, &opts)
+		return o.fs.shouldRetry(ctx, resp, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, err
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// If existing is set then it updates the object rather than creating a new one
+//
+// The new object may have been created if an error is returned
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	size := src.Size()
+	if size < 0 {
+		return errors.New("can't upload files of unknown size")
+	}
+
+	// Create the directory for the object if it doesn't exist
+	leaf, directoryID, err := o.fs.dirCache.FindPath(ctx, o.remote, true)
+	if err != nil {
+		return err
+	}
+	leaf = o.fs.opt.Enc.FromStandardName(leaf)
+
+	// if file exists then rename it out the way otherwise the
+	// upload will be saved under a different name
+	uploaded := false
+	if o.hasMetaData {
+		newLeaf := leaf + "." + random.String(8)
+		fs.Debugf(o, "Moving old file out the way to %q", newLeaf)
+		err = o.renameLeaf(ctx, newLeaf)
+		if err != nil {
+			return errors.Wrap(err, "upload rename old file")
+		}
+		defer func() {
+			// on failed upload rename old file back
+			if !uploaded {
+				fs.Debugf(o, "Renaming old file back (from %q to %q) since upload failed", newLeaf, leaf)
+				newErr := o.renameLeaf(ctx, leaf)
+				if newErr != nil && err == nil {
+					err = errors.Wrap(newErr, "upload renaming old file back")
+				}
+			}
+		}()
+	}
+
+	err = o.fs.upload(ctx, in, leaf, directoryID, src, options...)
+	if err != nil {
+		return err
+	}
+
+	// on successful upload, remove old file if it exists
+	uploaded = true
+	if o.hasMetaData {
+		fs.Debugf(o, "Removing old file")
+		err := o.fs.trash(ctx, o.id, o.etag)
+		if err != nil {
+			return errors.Wrap(err, "upload remove old file")
+		}
+	}
+
+	o.hasMetaData = false
+	return o.readMetaData(ctx)
+}
+
+// upload the contents of in as leaf in the folder with ID directoryID
+//
+// This gets a URL to upload to, uploads the contents then adds them to
+// the folder.
+func (f *Fs) upload(ctx context.Context, in io.Reader, leaf, directoryID string, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	size := src.Size()
+	zone, folderDocID := splitID(directoryID)
+
+	opts := f.docwsOpts("POST", zone, "upload/web")
+	request := api.UploadRequest{
+		Filename:    leaf,
+		Type:        api.ItemTypeFile,
+		ContentType: fs.MimeType(ctx, src),
+		Size:        size,
+	}
+	var uploadURLs []api.UploadURL
+	err := f.callJSON(ctx, &opts, &request, &uploadURLs)
+	if err != nil {
+		return errors.Wrap(err, "upload get URL")
+	}
+	if len(uploadURLs) == 0 || uploadURLs[0].URL == "" {
+		return errors.New("upload get URL: no URL returned")
+	}
+
+	var result api.UploadResponse
+	if size > 0 {
+		opts = rest.Opts{
+			Method:               "POST",
+			RootURL:              uploadURLs[0].URL,
+			Body:                 in,
+			Options:              options,
+			MultipartContentName: "files", // ..name of the parameter which is the attached file
+			MultipartFileName:    leaf,    // ..name of the file for the attached file
+			ContentLength:        &size,
+		}
+		var resp *http.Response
+		err = f.pacer.CallNoRetry(func() (bool, error) {
+			resp, err = f.srv.CallJSON(ctx, &opts, nil, &result)
+			return f.shouldRetry(ctx, resp, err)
+		})
+		if err != nil {
+			return errors.Wrap(err, "upload file")
+		}
+	}
+
+	modTime := src.ModTime(ctx).UnixNano() / int64(time.Millisecond)
+	opts = f.docwsOpts("POST", zone, "update/documents")
+	update := api.UpdateDocumentsRequest{
+		Data: api.UpdateData{
+			Signature:          result.SingleFile.FileChecksum,
+			WrappingKey:        result.SingleFile.WrappingKey,
+			ReferenceSignature: result.SingleFile.ReferenceChecksum,
+			Receipt:            result.SingleFile.Receipt,
+			Size:               result.SingleFile.Size,
+		},
+		Command:         "add_file",
+		CreateShortGUID: true,
+		DocumentID:      uploadURLs[0].DocumentID,
+		AllowConflict:   true,
+		Mtime:           modTime,
+		Btime:           modTime,
+	}
+	update.Path.StartingDocumentID = folderDocID
+	update.Path.Path = leaf
+	update.FileFlags.IsWritable = true
+	var updated api.UpdateDocumentsResponse
+	err = f.callJSON(ctx, &opts, &update, &updated)
+	if err != nil {
+		return errors.Wrap(err, "upload add file")
+	}
+	for _, r := range updated.Results {
+		if r.Status.StatusCode != 0 && r.Status.StatusCode != http.StatusOK {
+			return errors.Errorf("upload add file: %s (%d)", r.Status.ErrorMessage, r.Status.StatusCode)
+		}
+	}
+	return nil
+}
+
+// renameLeaf renames the object to newLeaf in its directory
+func (o *Object) renameLeaf(ctx context.Context, newLeaf string) error {
+	opts := o.fs.drivewsOpts("renameItems")
+	request := api.ItemsRequest{
+		Items: []api.ItemRef{{
+			DrivewsID: o.id,
+			Etag:      o.etag,
+			Name:      newLeaf,
+		}},
+	}
+	var result api.ItemsResponse
+	err := o.fs.callJSON(ctx, &opts, &request, &result)
+	if err != nil {
+		return errors.Wrap(err, "rename")
+	}
+	// the etag changes on rename
+	for _, item := range result.Items {
+		if item.DrivewsID == o.id && item.Etag != "" {
+			o.etag = item.Etag
+		}
+	}
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Remove: Failed to read metadata")
+	}
+	return o.fs.trash(ctx, o.id, o.etag)
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	return o.id
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.DirCacheFlusher = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.IDer            = (*Object)(nil)
+)
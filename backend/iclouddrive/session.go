@@ -0,0 +1,368 @@
+// Signing in to iCloud and keeping the session
+
+package iclouddrive
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/iclouddrive/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/lib/rest"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	homeURL = "https://www.icloud.com"
+	// don't sign in again for an expired session if it was signed
+	// in this recently, it is another request that was refused
+	reloginInterval = 10 * time.Second
+)
+
+// The endpoints used to sign in - variables so the tests can change them
+var (
+	authURL  = "https://idmsa.apple.com/appleauth/auth"
+	setupURL = "https://setup.icloud.com/setup/ws/1"
+)
+
+// session is a session with the iCloud web services
+//
+// The session is kept in the cookies set when logging in to the
+// account, which are saved in the config along with the trust token
+// which lets the Apple ID sign in again without two-factor
+// authentication.
+type session struct {
+	name     string           // name of the remote
+	m        configmap.Mapper // config to save the session in
+	opt      *Options         // parsed options
+	password string           // revealed password
+	srv      *rest.Client     // client keeping the cookies
+	jar      *cookiejar.Jar   // the cookies
+	mu       sync.Mutex       // protects the fields below
+
+	// set while signing in
+	sessionID    string // X-Apple-ID-Session-Id
+	scnt         string // scnt
+	sessionToken string // X-Apple-Session-Token
+	country      string // X-Apple-ID-Account-Country
+	trustToken   string // X-Apple-TwoSV-Trust-Token
+
+	// set by logging in to the account
+	lastLogin time.Time
+	dsid      string // account ID
+	drivews   string // URL of the drive web service
+	docws     string // URL of the documents web service
+}
+
+// newSession makes a session for the options, restoring the cookies
+// saved in the config
+func newSession(name string, m configmap.Mapper, opt *Options) (*session, error) {
+	if opt.AppleID == "" {
+		return nil, errors.New("apple_id not set")
+	}
+	password, err := obscure.Reveal(opt.Password)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't decrypt password")
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	client := fshttp.NewClient(fs.Config)
+	client.Jar = jar
+	s := &session{
+		name:       name,
+		m:          m,
+		opt:        opt,
+		password:   password,
+		srv:        rest.NewClient(client).SetErrorHandler(errorHandler),
+		jar:        jar,
+		trustToken: opt.TrustToken,
+	}
+	s.srv.SetHeader("Origin", homeURL)
+	s.srv.SetHeader("Referer", homeURL+"/")
+	err = s.loadCookies(opt.Cookies)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// cookieURL returns the URL the cookies of the iCloud web services
+// are set for
+func cookieURL() (*url.URL, error) {
+	u, err := url.Parse(setupURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad setup URL")
+	}
+	return u, nil
+}
+
+// cookieDomain returns the domain to restore the cookies set for host
+// to so they are sent to all the iCloud web services
+func cookieDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	i := strings.Index(host, ".")
+	if i < 0 || !strings.Contains(host[i+1:], ".") {
+		return ""
+	}
+	return host[i+1:]
+}
+
+// loadCookies restores the cookies saved in the config
+func (s *session) loadCookies(saved string) error {
+	if saved == "" {
+		return nil
+	}
+	u, err := cookieURL()
+	if err != nil {
+		return err
+	}
+	cookies := (&http.Request{Header: http.Header{"Cookie": {saved}}}).Cookies()
+	domain := cookieDomain(u.Hostname())
+	for _, cookie := range cookies {
+		cookie.Domain = domain
+		cookie.Path = "/"
+	}
+	s.jar.SetCookies(u, cookies)
+	return nil
+}
+
+// savedCookies returns the cookies to save in the config
+func (s *session) savedCookies() string {
+	u, err := cookieURL()
+	if err != nil {
+		return ""
+	}
+	req := &http.Request{Header: http.Header{}}
+	for _, cookie := range s.jar.Cookies(u) {
+		req.AddCookie(cookie)
+	}
+	return req.Header.Get("Cookie")
+}
+
+// save saves the session in the config if it has changed
+func (s *session) save() {
+	for key, value := range map[string]string{
+		configCookies:    s.savedCookies(),
+		configTrustToken: s.trustToken,
+	} {
+		if old, _ := s.m.Get(key); old != value {
+			s.m.Set(key, value)
+		}
+	}
+}
+
+// authHeaders returns the headers needed by the sign in service
+func (s *session) authHeaders() map[string]string {
+	headers := map[string]string{
+		"Accept":                           "application/json",
+		"X-Apple-OAuth-Client-Id":          s.opt.ClientID,
+		"X-Apple-OAuth-Client-Type":        "firstPartyAuth",
+		"X-Apple-OAuth-Redirect-URI":       homeURL,
+		"X-Apple-OAuth-Require-Grant-Code": "true",
+		"X-Apple-OAuth-Response-Mode":      "web_message",
+		"X-Apple-OAuth-Response-Type":      "code",
+		"X-Apple-OAuth-State":              s.opt.ClientID,
+		"X-Apple-Widget-Key":               s.opt.ClientID,
+	}
+	if s.sessionID != "" {
+		headers["X-Apple-ID-Session-Id"] = s.sessionID
+	}
+	if s.scnt != "" {
+		headers["scnt"] = s.scnt
+	}
+	return headers
+}
+
+// readAuthHeaders reads the state returned by the sign in service
+func (s *session) readAuthHeaders(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	for header, value := range map[string]*string{
+		"X-Apple-ID-Session-Id":      &s.sessionID,
+		"scnt":                       &s.scnt,
+		"X-Apple-Session-Token":      &s.sessionToken,
+		"X-Apple-ID-Account-Country": &s.country,
+		"X-Apple-TwoSV-Trust-Token":  &s.trustToken,
+	} {
+		if v := resp.Header.Get(header); v != "" {
+			*value = v
+		}
+	}
+}
+
+// signin signs in to the Apple ID with the password
+//
+// It returns whether two-factor authentication is needed to finish
+// signing in, which it isn't if the trust token is still valid.
+func (s *session) signin(ctx context.Context) (need2FA bool, err error) {
+	opts := rest.Opts{
+		Method:       "POST",
+		RootURL:      authURL,
+		Path:         "/signin",
+		Parameters:   url.Values{"isRememberMeEnabled": {"true"}},
+		ExtraHeaders: s.authHeaders(),
+	}
+	request := api.SigninRequest{
+		AccountName: s.opt.AppleID,
+		Password:    s.password,
+		RememberMe:  true,
+		TrustTokens: []string{},
+	}
+	if s.trustToken != "" {
+		request.TrustTokens = append(request.TrustTokens, s.trustToken)
+	}
+	resp, err := s.srv.CallJSON(ctx, &opts, &request, nil)
+	s.readAuthHeaders(resp)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to sign in")
+	}
+	return false, nil
+}
+
+// verifyCode finishes signing in with the two-factor authentication
+// code shown on a trusted device then gets a trust token so it isn't
+// needed the next time.
+func (s *session) verifyCode(ctx context.Context, code string) error {
+	opts := rest.Opts{
+		Method:       "POST",
+		RootURL:      authURL,
+		Path:         "/verify/trusteddevice/securitycode",
+		ExtraHeaders: s.authHeaders(),
+		NoResponse:   true,
+	}
+	var request api.SecurityCodeRequest
+	request.SecurityCode.Code = strings.Replace(code, " ", "", -1)
+	resp, err := s.srv.CallJSON(ctx, &opts, &request, nil)
+	s.readAuthHeaders(resp)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify code")
+	}
+	opts = rest.Opts{
+		Method:       "GET",
+		RootURL:      authURL,
+		Path:         "/2sv/trust",
+		ExtraHeaders: s.authHeaders(),
+		NoResponse:   true,
+	}
+	resp, err = s.srv.Call(ctx, &opts)
+	s.readAuthHeaders(resp)
+	if err != nil {
+		return errors.Wrap(err, "failed to trust session")
+	}
+	return nil
+}
+
+// accountLogin logs in to the account with the session token from
+// signing in
+func (s *session) accountLogin(ctx context.Context) error {
+	if s.sessionToken == "" {
+		return errors.New("not signed in")
+	}
+	opts := rest.Opts{
+		Method:  "POST",
+		RootURL: setupURL,
+		Path:    "/accountLogin",
+	}
+	request := api.AccountLoginRequest{
+		AccountCountryCode: s.country,
+		DsWebAuthToken:     s.sessionToken,
+		ExtendedLogin:      true,
+		TrustToken:         s.trustToken,
+	}
+	var result api.AccountLoginResponse
+	_, err := s.srv.CallJSON(ctx, &opts, &request, &result)
+	if err != nil {
+		return errors.Wrap(err, "failed to log in to account")
+	}
+	return s.setAccount(&result)
+}
+
+// validate checks the saved cookies are still logged in to the account
+func (s *session) validate(ctx context.Context) error {
+	opts := rest.Opts{
+		Method:  "POST",
+		RootURL: setupURL,
+		Path:    "/validate",
+	}
+	var result api.AccountLoginResponse
+	_, err := s.srv.CallJSON(ctx, &opts, nil, &result)
+	if err != nil {
+		return err
+	}
+	return s.setAccount(&result)
+}
+
+// setAccount reads the account logged in to
+func (s *session) setAccount(result *api.AccountLoginResponse) error {
+	s.dsid = result.DsInfo.Dsid
+	s.drivews = result.Webservices["drivews"].URL
+	s.docws = result.Webservices["docws"].URL
+	if s.dsid == "" {
+		return errors.New("no account ID returned")
+	}
+	if s.drivews == "" || s.docws == "" {
+		return errors.New("iCloud Drive isn't available for this account")
+	}
+	s.lastLogin = time.Now()
+	return nil
+}
+
+// login logs in to the account with the saved cookies if they are
+// still valid or by signing in again with the password and trust token.
+//
+// If force is set the saved cookies aren't tried as a web service
+// refused them.
+func (s *session) login(ctx context.Context, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if force && time.Since(s.lastLogin) < reloginInterval {
+		return nil
+	}
+	if !force && s.savedCookies() != "" {
+		err := s.validate(ctx)
+		if err == nil {
+			s.save()
+			return nil
+		}
+		fs.Debugf(s.name, "Saved session is no longer valid: %v", err)
+	}
+	need2FA, err := s.signin(ctx)
+	if err != nil {
+		return err
+	}
+	if need2FA {
+		return errors.Errorf("two-factor authentication needed - run \"rclone config reconnect %s:\"", s.name)
+	}
+	err = s.accountLogin(ctx)
+	if err != nil {
+		return err
+	}
+	s.save()
+	return nil
+}
+
+// account returns the account ID and the URLs of the drive and
+// documents web services
+func (s *session) account() (dsid, drivews, docws string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dsid, s.drivews, s.docws
+}
@@ -0,0 +1,199 @@
+package iclouddrive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rclone/rclone/backend/iclouddrive/api"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a fake of the iCloud sign in and setup services
+type testServer struct {
+	*httptest.Server
+	signins     int    // number of times signed in
+	oldAuthURL  string // endpoints to restore on close
+	oldSetupURL string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ts := &testServer{}
+	account := func(w http.ResponseWriter) {
+		result := api.AccountLoginResponse{
+			Webservices: map[string]api.Webservice{
+				"drivews": {URL: ts.URL + "/drivews"},
+				"docws":   {URL: ts.URL + "/docws"},
+			},
+		}
+		result.DsInfo.Dsid = "1234"
+		_ = json.NewEncoder(w).Encode(&result)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/appleauth/auth/signin", func(w http.ResponseWriter, r *http.Request) {
+		ts.signins++
+		var request api.SigninRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.AccountName != "user@example.com" || request.Password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"serviceErrors":[{"code":"-20101","message":"Your Apple ID or password was incorrect."}]}`))
+			return
+		}
+		w.Header().Set("X-Apple-ID-Session-Id", "session-id")
+		w.Header().Set("scnt", "scnt-value")
+		w.Header().Set("X-Apple-Session-Token", "session-token")
+		w.Header().Set("X-Apple-ID-Account-Country", "GBR")
+		if len(request.TrustTokens) == 1 && request.TrustTokens[0] == "trusted" {
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"authType":"hsa2"}`))
+	})
+	mux.HandleFunc("/appleauth/auth/verify/trusteddevice/securitycode", func(w http.ResponseWriter, r *http.Request) {
+		var request api.SecurityCodeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "scnt-value", r.Header.Get("scnt"))
+		assert.Equal(t, "session-id", r.Header.Get("X-Apple-ID-Session-Id"))
+		if request.SecurityCode.Code != "123456" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"serviceErrors":[{"code":"-21669","message":"Incorrect verification code."}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/appleauth/auth/2sv/trust", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Apple-TwoSV-Trust-Token", "trusted")
+		w.Header().Set("X-Apple-Session-Token", "trusted-session-token")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/setup/ws/1/accountLogin", func(w http.ResponseWriter, r *http.Request) {
+		var request api.AccountLoginRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		if request.DsWebAuthToken == "" || request.AccountCountryCode != "GBR" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "X-APPLE-WEBAUTH-TOKEN", Value: "webauth", Path: "/"})
+		account(w)
+	})
+	mux.HandleFunc("/setup/ws/1/validate", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("X-APPLE-WEBAUTH-TOKEN")
+		if err != nil || cookie.Value != "webauth" {
+			w.WriteHeader(421)
+			_, _ = w.Write([]byte(`{"success":false,"error":"Missing X-APPLE-WEBAUTH-TOKEN cookie"}`))
+			return
+		}
+		account(w)
+	})
+	ts.Server = httptest.NewServer(mux)
+	ts.oldAuthURL, ts.oldSetupURL = authURL, setupURL
+	authURL, setupURL = ts.URL+"/appleauth/auth", ts.URL+"/setup/ws/1"
+	return ts
+}
+
+// close the server and restore the endpoints
+func (ts *testServer) close() {
+	authURL, setupURL = ts.oldAuthURL, ts.oldSetupURL
+	ts.Close()
+}
+
+func newTestSession(t *testing.T, m configmap.Simple) *session {
+	m.Set("apple_id", "user@example.com")
+	m.Set("password", obscure.MustObscure("pass"))
+	m.Set("client_id", defaultClientID)
+	opt := new(Options)
+	opt.AppleID, _ = m.Get("apple_id")
+	opt.Password, _ = m.Get("password")
+	opt.ClientID, _ = m.Get("client_id")
+	opt.TrustToken, _ = m.Get(configTrustToken)
+	opt.Cookies, _ = m.Get(configCookies)
+	s, err := newSession("remote", m, opt)
+	require.NoError(t, err)
+	return s
+}
+
+func TestSessionSignin(t *testing.T) {
+	ctx := context.Background()
+	ts := newTestServer(t)
+	defer ts.close()
+	m := configmap.Simple{}
+
+	// Sign in with two-factor authentication as config does
+	s := newTestSession(t, m)
+	need2FA, err := s.signin(ctx)
+	require.NoError(t, err)
+	assert.True(t, need2FA)
+	err = s.verifyCode(ctx, "000000")
+	assert.EqualError(t, err, "failed to verify code: 400 Bad Request (400): Incorrect verification code. (-21669)")
+	require.NoError(t, s.verifyCode(ctx, "123 456"))
+	require.NoError(t, s.accountLogin(ctx))
+	s.save()
+	assert.Equal(t, "1234", s.dsid)
+	assert.Equal(t, ts.URL+"/drivews", s.drivews)
+	assert.Equal(t, ts.URL+"/docws", s.docws)
+	assert.Equal(t, "trusted", m[configTrustToken])
+	assert.Equal(t, "X-APPLE-WEBAUTH-TOKEN=webauth", m[configCookies])
+	assert.Equal(t, 1, ts.signins)
+
+	// The saved cookies are used without signing in
+	s = newTestSession(t, m)
+	require.NoError(t, s.login(ctx, false))
+	assert.Equal(t, "1234", s.dsid)
+	assert.Equal(t, 1, ts.signins)
+
+	// The trust token signs in without two-factor authentication
+	delete(m, configCookies)
+	s = newTestSession(t, m)
+	require.NoError(t, s.login(ctx, false))
+	assert.Equal(t, "X-APPLE-WEBAUTH-TOKEN=webauth", m[configCookies])
+	assert.Equal(t, 2, ts.signins)
+
+	// Expired sessions sign in again, but not more than once
+	s.lastLogin = s.lastLogin.Add(-reloginInterval)
+	require.NoError(t, s.login(ctx, true))
+	require.NoError(t, s.login(ctx, true))
+	assert.Equal(t, 3, ts.signins)
+
+	// Without the trust token two-factor authentication is needed
+	s = newTestSession(t, configmap.Simple{})
+	err = s.login(ctx, false)
+	assert.EqualError(t, err, `two-factor authentication needed - run "rclone config reconnect remote:"`)
+}
+
+func TestSessionBadPassword(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.close()
+	m := configmap.Simple{}
+	s := newTestSession(t, m)
+	s.password = "wrong"
+	_, err := s.signin(context.Background())
+	assert.EqualError(t, err, "failed to sign in: 401 Unauthorized (401): Your Apple ID or password was incorrect. (-20101)")
+}
+
+func TestCookieDomain(t *testing.T) {
+	for _, test := range []struct {
+		host string
+		want string
+	}{
+		{"setup.icloud.com", "icloud.com"},
+		{"icloud.com", ""},
+		{"localhost", ""},
+		{"127.0.0.1", ""},
+	} {
+		assert.Equal(t, test.want, cookieDomain(test.host), test.host)
+	}
+}
+
+func TestSplitID(t *testing.T) {
+	zone, docID := splitID(rootID)
+	assert.Equal(t, driveZone, zone)
+	assert.Equal(t, "root", docID)
+	zone, docID = splitID("FOLDER::com.apple.CloudDocs::8A7D1B4C-7F3E")
+	assert.Equal(t, "com.apple.CloudDocs", zone)
+	assert.Equal(t, "8A7D1B4C-7F3E", docID)
+}
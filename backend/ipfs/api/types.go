@@ -0,0 +1,71 @@
+// Package api has type definitions for the IPFS HTTP RPC API
+//
+// See https://docs.ipfs.tech/reference/kubo/rpc/
+package api
+
+import "fmt"
+
+// Types of Entry
+const (
+	EntryTypeFile      = 0
+	EntryTypeDirectory = 1
+)
+
+// Types of Stat
+const (
+	StatTypeFile      = "file"
+	StatTypeDirectory = "directory"
+)
+
+// Error is returned from the API when things go wrong
+type Error struct {
+	Message string `json:"Message"`
+	Code    int    `json:"Code"`
+	Type    string `json:"Type"`
+}
+
+// Error satisfies the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("ipfs error: %s", e.Message)
+}
+
+// Entry is a file or directory listed by files/ls
+type Entry struct {
+	Name string `json:"Name"`
+	Type int    `json:"Type"`
+	Size int64  `json:"Size"`
+	Hash string `json:"Hash"` // CID of the entry
+}
+
+// ListResponse is returned from files/ls
+type ListResponse struct {
+	Entries []Entry `json:"Entries"`
+}
+
+// Stat is returned from files/stat
+type Stat struct {
+	Hash           string `json:"Hash"` // CID of the file or directory
+	Size           int64  `json:"Size"`
+	CumulativeSize int64  `json:"CumulativeSize"`
+	Type           string `json:"Type"`
+	Mtime          int64  `json:"Mtime,omitempty"` // seconds since the epoch if stored
+}
+
+// PinResponse is returned from pin/add
+type PinResponse struct {
+	Pins []string `json:"Pins"`
+}
+
+// RemotePin is returned from pin/remote/add
+type RemotePin struct {
+	Cid    string `json:"Cid"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"`
+}
+
+// RepoStat is returned from repo/stat
+type RepoStat struct {
+	RepoSize   int64 `json:"RepoSize"`
+	StorageMax int64 `json:"StorageMax"`
+	NumObjects int64 `json:"NumObjects"`
+}
@@ -0,0 +1,98 @@
+// Backend commands to pin and import content by CID
+
+package ipfs
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "pin",
+	Short: "Pin files or directories by their CID",
+	Long: `This pins the current CID of each path given, or the root if none
+are, to the pinning_service if set, otherwise to the IPFS node.
+
+Usage Examples:
+
+    rclone backend pin ipfs:
+    rclone backend pin ipfs: path/to/dir path/to/file
+
+Pinning a directory pins everything in it as it is now. The pin isn't
+updated when the directory changes, run the command again for that.
+
+It returns the CID and status of each path pinned.
+`,
+}, {
+	Name:  "import",
+	Short: "Import content by its CID",
+	Long: `This adds the content with the CID given to the path given without
+downloading it, replacing any file there.
+
+Usage Examples:
+
+    rclone backend import ipfs: QmPChd2hVbrJ6bfo3WBcTW4iZnpHm8TEzWkLHmLpXhF68A path/to/dir
+    rclone backend import ipfs: /ipfs/QmPChd2hVbrJ6bfo3WBcTW4iZnpHm8TEzWkLHmLpXhF68A/file path/to/file
+
+The content is fetched from the IPFS network as it is read, so it
+should be pinned somewhere to stay available.
+`,
+}}
+
+// pinResult is the result of pinning a path
+type pinResult struct {
+	Path   string `json:"path"`
+	CID    string `json:"cid"`
+	Status string `json:"status"`
+}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "pin":
+		if len(arg) == 0 {
+			arg = []string{""}
+		}
+		var results []pinResult
+		for _, remote := range arg {
+			info, err := f.stat(ctx, remote)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read %q", remote)
+			}
+			status, err := f.pin(ctx, info.Hash, path.Join(f.root, remote))
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, pinResult{Path: remote, CID: info.Hash, Status: status})
+		}
+		return results, nil
+	case "import":
+		if len(arg) != 2 {
+			return nil, errors.New("need a CID and a path to import it to")
+		}
+		from := arg[0]
+		if !strings.HasPrefix(from, "/") {
+			from = "/ipfs/" + from
+		}
+		return nil, f.replace(ctx, "files/cp", from, arg[1])
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Commander = (*Fs)(nil)
+)
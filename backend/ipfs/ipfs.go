@@ -0,0 +1,827 @@
+// Package ipfs provides an interface to the Mutable File System of an
+// IPFS node
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/ipfs/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+const (
+	minSleep      = 10 * time.Millisecond
+	maxSleep      = 2 * time.Second
+	decayConstant = 2 // bigger for slower decay, exponential
+	apiPath       = "/api/v0/"
+)
+
+var (
+	timeUnset = time.Unix(0, 0)
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "ipfs",
+		Description: "IPFS",
+		NewFs:       NewFs,
+		CommandHelp: commandHelp,
+		Options: []fs.Option{{
+			Name:    "api_url",
+			Help:    "URL of the HTTP RPC API of the IPFS node.",
+			Default: "http://127.0.0.1:5001",
+		}, {
+			Name: "user",
+			Help: "User name if the API needs basic authentication.",
+		}, {
+			Name:       "pass",
+			Help:       "Password if the API needs basic authentication.",
+			IsPassword: true,
+		}, {
+			Name: "gateway",
+			Help: `URL of an HTTP gateway to download files from.
+
+If set files are downloaded from this gateway by their CID rather than
+read through the API, and links made with "rclone link" point to it.`,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "Read files through the API",
+			}, {
+				Value: "http://127.0.0.1:8080",
+				Help:  "The gateway of the local IPFS node",
+			}, {
+				Value: "https://ipfs.io",
+				Help:  "The public gateway run by Protocol Labs",
+			}},
+		}, {
+			Name: "pinning_service",
+			Help: `Remote pinning service to pin uploaded files to.
+
+This is the name the service was added to the IPFS node with
+"ipfs pin remote service add". If it is empty uploaded files are only
+stored in the node.`,
+			Advanced: true,
+		}, {
+			Name:     config.ConfigEncoding,
+			Help:     config.ConfigEncodingHelp,
+			Advanced: true,
+			// Encode invalid UTF-8 bytes as json doesn't handle them properly.
+			Default: (encoder.Display |
+				encoder.EncodeInvalidUtf8),
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	APIURL         string               `config:"api_url"`
+	User           string               `config:"user"`
+	Pass           string               `config:"pass"`
+	Gateway        string               `config:"gateway"`
+	PinningService string               `config:"pinning_service"`
+	Enc            encoder.MultiEncoder `config:"encoding"`
+}
+
+// Fs represents a directory in the Mutable File System of an IPFS node
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path we are working on
+	opt      Options      // parsed options
+	features *fs.Features // optional features
+	srv      *rest.Client // the connection to the API
+	pacer    *fs.Pacer    // pacer for API calls
+}
+
+// Object describes a file
+type Object struct {
+	fs      *Fs       // what this object is part of
+	remote  string    // The remote path
+	size    int64     // size of the object
+	cid     string    // CID of the object
+	modTime time.Time // modification time of the object if stored
+}
+
+// ------------------------------------------------------------
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("IPFS root '%s'", f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// retryErrorCodes is a slice of error codes that we will retry
+//
+// The API returns 500 for all errors so it isn't retried.
+var retryErrorCodes = []int{
+	429, // Too Many Requests.
+	502, // Bad Gateway
+	503, // Service Unavailable
+	504, // Gateway Timeout
+}
+
+// shouldRetry returns a boolean as to whether this resp and err
+// deserve to be retried.  It returns the err as a convenience
+func shouldRetry(resp *http.Response, err error) (bool, error) {
+	return fserrors.ShouldRetry(err) || fserrors.ShouldRetryHTTP(resp, retryErrorCodes), err
+}
+
+// errorHandler parses a non 2xx error response into an error
+func errorHandler(resp *http.Response) error {
+	body, err := rest.ReadBody(resp)
+	if err != nil {
+		body = nil
+	}
+	var e = api.Error{
+		Message: fmt.Sprintf("%s (%d)", resp.Status, resp.StatusCode),
+	}
+	if body != nil {
+		_ = json.Unmarshal(body, &e)
+	}
+	return &e
+}
+
+// isNotFound returns true if err is from a path which doesn't exist
+func isNotFound(err error) bool {
+	apiErr, ok := errors.Cause(err).(*api.Error)
+	if !ok {
+		return false
+	}
+	return strings.Contains(apiErr.Message, "does not exist") || strings.Contains(apiErr.Message, "no link named")
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
+	ctx := context.Background()
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.APIURL == "" {
+		return nil, errors.New("api_url not set")
+	}
+	opt.Gateway = strings.TrimRight(opt.Gateway, "/")
+
+	root = strings.Trim(root, "/")
+
+	f := &Fs{
+		name:  name,
+		root:  root,
+		opt:   *opt,
+		srv:   rest.NewClient(fshttp.NewClient(fs.Config)).SetRoot(strings.TrimRight(opt.APIURL, "/") + apiPath),
+		pacer: fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+	}
+	f.srv.SetErrorHandler(errorHandler)
+	if opt.User != "" || opt.Pass != "" {
+		pass, err := obscure.Reveal(opt.Pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't decrypt password")
+		}
+		f.srv.SetUserPass(opt.User, pass)
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+
+	if root != "" {
+		// Check to see if the root is a file
+		info, err := f.stat(ctx, "")
+		if err == nil && info.Type == api.StatTypeFile {
+			f.root = path.Dir(root)
+			if f.root == "." {
+				f.root = ""
+			}
+			// return an error with an fs which points to the parent
+			return f, fs.ErrorIsFile
+		}
+	}
+	return f, nil
+}
+
+// mfsPath returns the path of remote in the Mutable File System
+func (f *Fs) mfsPath(remote string) string {
+	return f.opt.Enc.FromStandardPath(path.Join("/", f.root, remote))
+}
+
+// call runs the API command with the args, decoding the response into
+// response if it isn't nil
+func (f *Fs) call(ctx context.Context, command string, args []string, params url.Values, response interface{}) error {
+	opts := rest.Opts{
+		Method:     "POST",
+		Path:       command,
+		Parameters: url.Values{},
+	}
+	for key, values := range params {
+		opts.Parameters[key] = values
+	}
+	for _, arg := range args {
+		opts.Parameters.Add("arg", arg)
+	}
+	var resp *http.Response
+	return f.pacer.Call(func() (bool, error) {
+		var err error
+		if response == nil {
+			opts.NoResponse = true
+			resp, err = f.srv.Call(ctx, &opts)
+		} else {
+			resp, err = f.srv.CallJSON(ctx, &opts, nil, response)
+		}
+		return shouldRetry(resp, err)
+	})
+}
+
+// stat returns the information about remote
+func (f *Fs) stat(ctx context.Context, remote string) (*api.Stat, error) {
+	var info api.Stat
+	err := f.call(ctx, "files/stat", []string{f.mfsPath(remote)}, nil, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	info, err := f.stat(ctx, dir)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fs.ErrorDirNotFound
+		}
+		return nil, err
+	}
+	if info.Type != api.StatTypeDirectory {
+		return nil, fs.ErrorDirNotFound
+	}
+	var result api.ListResponse
+	err = f.call(ctx, "files/ls", []string{f.mfsPath(dir)}, url.Values{"long": {"true"}}, &result)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fs.ErrorDirNotFound
+		}
+		return nil, errors.Wrap(err, "couldn't list files")
+	}
+	for _, entry := range result.Entries {
+		remote := path.Join(dir, f.opt.Enc.ToStandardName(entry.Name))
+		switch entry.Type {
+		case api.EntryTypeDirectory:
+			entries = append(entries, fs.NewDir(remote, timeUnset).SetID(entry.Hash))
+		case api.EntryTypeFile:
+			entries = append(entries, &Object{
+				fs:      f,
+				remote:  remote,
+				size:    entry.Size,
+				cid:     entry.Hash,
+				modTime: timeUnset,
+			})
+		default:
+			fs.Debugf(f, "Ignoring %q - unknown type %d", entry.Name, entry.Type)
+		}
+	}
+	return entries, nil
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Put the object
+//
+// Copy the reader in to the new object which is returned
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(ctx, in, src, options...)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// mkParentDir makes the parent directory of remote, which may be
+// outside the root
+func (f *Fs) mkParentDir(ctx context.Context, remote string) error {
+	return f.mkdir(ctx, path.Dir(f.mfsPath(remote)))
+}
+
+// Mkdir creates the directory if it doesn't exist
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	return f.mkdir(ctx, f.mfsPath(dir))
+}
+
+// mkdir creates the directory p in the Mutable File System
+func (f *Fs) mkdir(ctx context.Context, p string) error {
+	if p == "/" {
+		return nil
+	}
+	err := f.call(ctx, "files/mkdir", []string{p}, url.Values{"parents": {"true"}}, nil)
+	if err != nil {
+		return errors.Wrap(err, "mkdir failed")
+	}
+	return nil
+}
+
+// Rmdir deletes the directory
+//
+// Returns an error if it isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	entries, err := f.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fs.ErrorDirectoryNotEmpty
+	}
+	return f.remove(ctx, dir, true)
+}
+
+// Purge deletes all the files in the directory
+//
+// Optional interface: Only implement this if you have a way of
+// deleting all the files quicker than just running Remove() on the
+// result of List()
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	if f.mfsPath(dir) == "/" {
+		return errors.New("can't purge root directory")
+	}
+	return f.remove(ctx, dir, true)
+}
+
+// remove removes remote, recursively if it is a directory
+func (f *Fs) remove(ctx context.Context, remote string, recursive bool) error {
+	params := url.Values{}
+	if recursive {
+		params.Set("recursive", "true")
+	}
+	err := f.call(ctx, "files/rm", []string{f.mfsPath(remote)}, params, nil)
+	if err != nil {
+		if isNotFound(err) {
+			if recursive {
+				return fs.ErrorDirNotFound
+			}
+			return fs.ErrorObjectNotFound
+		}
+		return errors.Wrap(err, "remove failed")
+	}
+	return nil
+}
+
+// Precision return the precision of this Fs
+func (f *Fs) Precision() time.Duration {
+	return fs.ModTimeNotSupported
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// Files are copied by their CID so no data is copied.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok || srcObj.cid == "" {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	err := f.replace(ctx, "files/cp", "/ipfs/"+srcObj.cid, remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "copy failed")
+	}
+	return f.NewObject(ctx, remote)
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't move - not same remote type")
+		return nil, fs.ErrorCantMove
+	}
+	err := f.replace(ctx, "files/mv", srcObj.fs.mfsPath(srcObj.remote), remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "move failed")
+	}
+	return f.NewObject(ctx, remote)
+}
+
+// tempRemote returns a hidden name next to remote to write it to
+// before moving it into place
+func tempRemote(remote string) string {
+	return path.Join(path.Dir(remote), "."+path.Base(remote)+"."+random.String(8)+".partial")
+}
+
+// replace runs the files/cp or files/mv command to the file remote,
+// replacing it if it exists as the commands won't overwrite files.
+//
+// It copies or moves from to a temporary name first so the existing
+// file is only removed once that has worked.
+func (f *Fs) replace(ctx context.Context, command, from, remote string) error {
+	err := f.mkParentDir(ctx, remote)
+	if err != nil {
+		return err
+	}
+	temp := tempRemote(remote)
+	err = f.call(ctx, command, []string{from, f.mfsPath(temp)}, nil, nil)
+	if err != nil {
+		return err
+	}
+	return f.moveInto(ctx, temp, remote)
+}
+
+// moveInto moves the temporary file temp over remote removing
+// remote first if it exists
+func (f *Fs) moveInto(ctx context.Context, temp, remote string) error {
+	err := f.remove(ctx, remote, false)
+	if err != nil && err != fs.ErrorObjectNotFound {
+		_ = f.remove(ctx, temp, false)
+		return err
+	}
+	err = f.call(ctx, "files/mv", []string{f.mfsPath(temp), f.mfsPath(remote)}, nil, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move %q into place", temp)
+	}
+	return nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	_, err := f.stat(ctx, dstRemote)
+	if err == nil {
+		return fs.ErrorDirExists
+	} else if !isNotFound(err) {
+		return err
+	}
+	err = f.mkParentDir(ctx, dstRemote)
+	if err != nil {
+		return err
+	}
+	err = f.call(ctx, "files/mv", []string{srcFs.mfsPath(srcRemote), f.mfsPath(dstRemote)}, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "move directory failed")
+	}
+	return nil
+}
+
+// PublicLink returns a link to the file or directory on the gateway
+//
+// As the link is to the CID it doesn't change if remote does.
+func (f *Fs) PublicLink(ctx context.Context, remote string, expire fs.Duration, unlink bool) (string, error) {
+	if unlink {
+		return "", errors.New("links to IPFS content can't be removed")
+	}
+	info, err := f.stat(ctx, remote)
+	if err != nil {
+		if isNotFound(err) {
+			return "", fs.ErrorObjectNotFound
+		}
+		return "", err
+	}
+	gateway := f.opt.Gateway
+	if gateway == "" {
+		gateway = "https://ipfs.io"
+	}
+	return gateway + "/ipfs/" + info.Hash, nil
+}
+
+// About gets quota information from the node's repository
+func (f *Fs) About(ctx context.Context) (usage *fs.Usage, err error) {
+	var info api.RepoStat
+	err = f.call(ctx, "repo/stat", nil, url.Values{"size-only": {"true"}}, &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "about failed")
+	}
+	usage = &fs.Usage{
+		Used: fs.NewUsageValue(info.RepoSize),
+	}
+	if info.StorageMax > 0 {
+		usage.Total = fs.NewUsageValue(info.StorageMax)
+		usage.Free = fs.NewUsageValue(info.StorageMax - info.RepoSize)
+	}
+	return usage, nil
+}
+
+// pin pins cid to the pinning service, or the node if there isn't
+// one, with name
+func (f *Fs) pin(ctx context.Context, cid, name string) (status string, err error) {
+	if f.opt.PinningService == "" {
+		var result api.PinResponse
+		err = f.call(ctx, "pin/add", []string{cid}, nil, &result)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to pin %s", cid)
+		}
+		return "pinned", nil
+	}
+	var result api.RemotePin
+	params := url.Values{
+		"service":    {f.opt.PinningService},
+		"name":       {name},
+		"background": {"true"},
+	}
+	err = f.call(ctx, "pin/remote/add", []string{"/ipfs/" + cid}, params, &result)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to pin %s to %q", cid, f.opt.PinningService)
+	}
+	return result.Status, nil
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the hash of an object - IPFS only has CIDs, see ID
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// setMetaData sets the metadata from info
+func (o *Object) setMetaData(info *api.Stat) error {
+	if info.Type != api.StatTypeFile {
+		return fs.ErrorNotAFile
+	}
+	o.size = info.Size
+	o.cid = info.Hash
+	o.modTime = timeUnset
+	if info.Mtime != 0 {
+		o.modTime = time.Unix(info.Mtime, 0)
+	}
+	return nil
+}
+
+// readMetaData gets the metadata of the object
+func (o *Object) readMetaData(ctx context.Context) error {
+	info, err := o.fs.stat(ctx, o.remote)
+	if err != nil {
+		if isNotFound(err) {
+			return fs.ErrorObjectNotFound
+		}
+		return err
+	}
+	return o.setMetaData(info)
+}
+
+// ModTime returns the modification time of the object
+//
+// This is only known if it was stored in the UnixFS metadata.
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	return fs.ErrorCantSetModTime
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	if o.fs.opt.Gateway != "" {
+		return o.openGateway(ctx, options...)
+	}
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.size)
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "files/read",
+		Parameters: url.Values{
+			"arg":    {o.fs.mfsPath(o.remote)},
+			"offset": {fmt.Sprint(offset)},
+		},
+	}
+	if limit >= 0 {
+		opts.Parameters.Set("count", fmt.Sprint(limit))
+	}
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.srv.Call(ctx, &opts)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fs.ErrorObjectNotFound
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// openGateway opens the object by its CID on the gateway
+func (o *Object) openGateway(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	fs.FixRangeOption(options, o.size)
+	opts := rest.Opts{
+		Method:  "GET",
+		RootURL: o.fs.opt.Gateway,
+		Path:    "/ipfs/" + o.cid,
+		Options: options,
+	}
+	var resp *http.Response
+	err = o.fs.pacer.Call(func() (bool, error) {
+		resp, err = o.fs.srv.Call(ctx, &opts)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// If existing is set then it updates the object rather than creating a new one
+//
+// The file is written to a temporary name which is moved over the
+// existing file once the upload has finished so a failed upload leaves
+// the existing file alone.
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	temp := tempRemote(o.remote)
+	opts := rest.Opts{
+		Method: "POST",
+		Path:   "files/write",
+		Body:   in,
+		Parameters: url.Values{
+			"arg":      {o.fs.mfsPath(temp)},
+			"create":   {"true"},
+			"truncate": {"true"},
+			"parents":  {"true"},
+		},
+		Options:              options,
+		NoResponse:           true,
+		MultipartContentName: "file",
+		MultipartFileName:    path.Base(o.remote),
+	}
+	if size := src.Size(); size >= 0 {
+		opts.ContentLength = &size
+	}
+	var resp *http.Response
+	err = o.fs.pacer.CallNoRetry(func() (bool, error) {
+		resp, err = o.fs.srv.CallJSON(ctx, &opts, nil, nil)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		_ = o.fs.remove(ctx, temp, false)
+		return errors.Wrap(err, "upload failed")
+	}
+	err = o.fs.moveInto(ctx, temp, o.remote)
+	if err != nil {
+		return errors.Wrap(err, "upload failed")
+	}
+	err = o.readMetaData(ctx)
+	if err != nil {
+		return err
+	}
+	if o.fs.opt.PinningService != "" {
+		status, err := o.fs.pin(ctx, o.cid, path.Join(o.fs.root, o.remote))
+		if err != nil {
+			return err
+		}
+		fs.Debugf(o, "Pinned %s to %q: %s", o.cid, o.fs.opt.PinningService, status)
+	}
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	return o.fs.remove(ctx, o.remote, false)
+}
+
+// ID returns the CID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	return o.cid
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs           = (*Fs)(nil)
+	_ fs.Purger       = (*Fs)(nil)
+	_ fs.PutStreamer  = (*Fs)(nil)
+	_ fs.Copier       = (*Fs)(nil)
+	_ fs.Mover        = (*Fs)(nil)
+	_ fs.DirMover     = (*Fs)(nil)
+	_ fs.PublicLinker = (*Fs)(nil)
+	_ fs.Abouter      = (*Fs)(nil)
+	_ fs.Object       = (*Object)(nil)
+	_ fs.IDer         = (*Object)(nil)
+)
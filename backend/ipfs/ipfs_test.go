@@ -0,0 +1,406 @@
+// Test IPFS filesystem interface
+package ipfs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/backend/ipfs"
+	"github.com/rclone/rclone/backend/ipfs/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: "TestIPFS:",
+		NilObject:  (*ipfs.Object)(nil),
+	})
+}
+
+// fakeNode is an IPFS node with just enough of the HTTP RPC API and
+// the gateway for the backend
+type fakeNode struct {
+	mu       sync.Mutex
+	files    map[string][]byte // contents of the files by path
+	dirs     map[string]bool   // the directories
+	blocks   map[string][]byte // contents of the files by CID
+	pins     []string          // CIDs pinned to the node
+	remotes  []api.RemotePin   // CIDs pinned to the remote service
+	requests int               // number of API requests
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{
+		files:  map[string][]byte{},
+		dirs:   map[string]bool{"/": true},
+		blocks: map[string][]byte{},
+	}
+}
+
+// cid makes a CID for data
+func cid(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "bafk" + hex.EncodeToString(sum[:16])
+}
+
+// dirCID makes a CID for the directory at p from its contents
+func (n *fakeNode) dirCID(p string) string {
+	var listing []string
+	for name, data := range n.files {
+		if strings.HasPrefix(name, p) {
+			listing = append(listing, name+cid(data))
+		}
+	}
+	sort.Strings(listing)
+	return cid([]byte("dir" + p + strings.Join(listing, "\n")))
+}
+
+// under returns whether p is dir or inside it
+func under(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimRight(dir, "/")+"/")
+}
+
+func (n *fakeNode) error(w http.ResponseWriter, format string, a ...interface{}) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(&api.Error{Message: fmt.Sprintf(format, a...), Type: "error"})
+}
+
+func (n *fakeNode) mkdirAll(p string) {
+	for ; p != "/"; p = path.Dir(p) {
+		n.dirs[p] = true
+	}
+}
+
+// move moves or copies everything at src to dst
+func (n *fakeNode) move(src, dst string, keep bool) {
+	for p, data := range n.files {
+		if under(p, src) {
+			n.files[dst+strings.TrimPrefix(p, src)] = data
+			if !keep {
+				delete(n.files, p)
+			}
+		}
+	}
+	for p := range n.dirs {
+		if under(p, src) {
+			n.dirs[dst+strings.TrimPrefix(p, src)] = true
+			if !keep {
+				delete(n.dirs, p)
+			}
+		}
+	}
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if strings.HasPrefix(r.URL.Path, "/ipfs/") {
+		// The gateway
+		data, ok := n.blocks[strings.TrimPrefix(r.URL.Path, "/ipfs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		return
+	}
+	n.requests++
+	if r.Method != "POST" {
+		n.error(w, "method %s not allowed", r.Method)
+		return
+	}
+	args := r.URL.Query()["arg"]
+	p := ""
+	if len(args) > 0 {
+		p = args[0]
+	}
+	query := r.URL.Query()
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0/") {
+	case "files/stat":
+		if n.dirs[p] {
+			_ = json.NewEncoder(w).Encode(&api.Stat{Hash: n.dirCID(p), Type: api.StatTypeDirectory})
+		} else if data, ok := n.files[p]; ok {
+			_ = json.NewEncoder(w).Encode(&api.Stat{Hash: cid(data), Size: int64(len(data)), Type: api.StatTypeFile})
+		} else {
+			n.error(w, "%s: file does not exist", p)
+		}
+	case "files/ls":
+		if !n.dirs[p] {
+			n.error(w, "file does not exist")
+			return
+		}
+		var result api.ListResponse
+		for name, data := range n.files {
+			if path.Dir(name) == p {
+				result.Entries = append(result.Entries, api.Entry{Name: path.Base(name), Type: api.EntryTypeFile, Size: int64(len(data)), Hash: cid(data)})
+			}
+		}
+		for name := range n.dirs {
+			if name != "/" && path.Dir(name) == p {
+				result.Entries = append(result.Entries, api.Entry{Name: path.Base(name), Type: api.EntryTypeDirectory, Hash: n.dirCID(name)})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(&result)
+	case "files/read":
+		data, ok := n.files[p]
+		if !ok {
+			n.error(w, "file does not exist")
+			return
+		}
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		if offset > len(data) {
+			offset = len(data)
+		}
+		data = data[offset:]
+		if count := query.Get("count"); count != "" {
+			c, _ := strconv.Atoi(count)
+			if c < len(data) {
+				data = data[:c]
+			}
+		}
+		_, _ = w.Write(data)
+	case "files/write":
+		if n.dirs[p] {
+			n.error(w, "%s is a directory", p)
+			return
+		}
+		if !n.dirs[path.Dir(p)] && query.Get("parents") != "true" {
+			n.error(w, "file does not exist")
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			n.error(w, "bad upload: %v", err)
+			return
+		}
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			n.error(w, "bad upload: %v", err)
+			return
+		}
+		n.mkdirAll(path.Dir(p))
+		n.files[p] = data
+		n.blocks[cid(data)] = data
+	case "files/mkdir":
+		if n.dirs[p] {
+			if query.Get("parents") != "true" {
+				n.error(w, "file already exists")
+			}
+			return
+		}
+		if _, ok := n.files[p]; ok {
+			n.error(w, "file already exists")
+			return
+		}
+		n.mkdirAll(p)
+	case "files/rm":
+		if n.dirs[p] {
+			if query.Get("recursive") != "true" {
+				n.error(w, "%s is a directory, use -r to remove directories", p)
+				return
+			}
+			for name := range n.files {
+				if under(name, p) {
+					delete(n.files, name)
+				}
+			}
+			for name := range n.dirs {
+				if under(name, p) {
+					delete(n.dirs, name)
+				}
+			}
+		} else if _, ok := n.files[p]; ok {
+			delete(n.files, p)
+		} else {
+			n.error(w, "file does not exist")
+		}
+	case "files/mv", "files/cp":
+		dst := args[1]
+		if _, ok := n.files[dst]; ok || n.dirs[dst] {
+			n.error(w, "directory already has entry by that name")
+			return
+		}
+		if !n.dirs[path.Dir(dst)] {
+			n.error(w, "file does not exist")
+			return
+		}
+		if strings.HasPrefix(p, "/ipfs/") {
+			data, ok := n.blocks[strings.TrimPrefix(p, "/ipfs/")]
+			if !ok {
+				n.error(w, "no link named %q", p)
+				return
+			}
+			n.files[dst] = data
+			return
+		}
+		if _, ok := n.files[p]; !ok && !n.dirs[p] {
+			n.error(w, "file does not exist")
+			return
+		}
+		n.move(p, dst, strings.HasSuffix(r.URL.Path, "cp"))
+	case "pin/add":
+		n.pins = append(n.pins, p)
+		_ = json.NewEncoder(w).Encode(&api.PinResponse{Pins: []string{p}})
+	case "pin/remote/add":
+		pin := api.RemotePin{Cid: strings.TrimPrefix(p, "/ipfs/"), Name: query.Get("name"), Status: "queued"}
+		n.remotes = append(n.remotes, pin)
+		_ = json.NewEncoder(w).Encode(&pin)
+	case "repo/stat":
+		var size int64
+		for _, data := range n.blocks {
+			size += int64(len(data))
+		}
+		_ = json.NewEncoder(w).Encode(&api.RepoStat{RepoSize: size, StorageMax: 10e9})
+	default:
+		n.error(w, "unknown command %q", r.URL.Path)
+	}
+}
+
+// TestStandard runs the integration tests against a fake node
+func TestStandard(t *testing.T) {
+	srv := httptest.NewServer(newFakeNode())
+	defer srv.Close()
+	name := "TestIPFSFake"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*ipfs.Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "ipfs"},
+			{Name: name, Key: "api_url", Value: srv.URL},
+		},
+	})
+}
+
+// newTestFs makes an Fs for the fake node with the options in m
+func newTestFs(t *testing.T, node *fakeNode, m configmap.Simple) (fs.Fs, func()) {
+	srv := httptest.NewServer(node)
+	m.Set("api_url", srv.URL)
+	if m["gateway"] == "gateway" {
+		m.Set("gateway", srv.URL)
+	}
+	f, err := ipfs.NewFs("ipfs", "", m)
+	require.NoError(t, err)
+	return f, srv.Close
+}
+
+func upload(ctx context.Context, t *testing.T, f fs.Fs, remote, contents string) fs.Object {
+	src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(contents)), true, nil, nil)
+	o, err := f.Put(ctx, strings.NewReader(contents), src)
+	require.NoError(t, err)
+	return o
+}
+
+func TestGateway(t *testing.T) {
+	ctx := context.Background()
+	f, done := newTestFs(t, newFakeNode(), configmap.Simple{"gateway": "gateway"})
+	defer done()
+	o := upload(ctx, t, f, "dir/file.txt", "hello gateway")
+	in, err := o.Open(ctx, &fs.RangeOption{Start: 6, End: -1})
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, "gateway", string(data))
+
+	link, err := f.Features().PublicLink(ctx, "dir/file.txt", 0, false)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(link, "/ipfs/"+cid([]byte("hello gateway"))), link)
+}
+
+func TestPinAndImport(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeNode()
+	f, done := newTestFs(t, node, configmap.Simple{})
+	defer done()
+	o := upload(ctx, t, f, "file.txt", "pin me")
+	want := cid([]byte("pin me"))
+	assert.Equal(t, want, o.(fs.IDer).ID())
+	assert.Equal(t, 0, len(node.pins))
+
+	// Pin to the node
+	out, err := f.Features().Command(ctx, "pin", []string{"file.txt"}, nil)
+	require.NoError(t, err)
+	result, err := json.Marshal(out)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"path":"file.txt","cid":"`+want+`","status":"pinned"}]`, string(result))
+	assert.Equal(t, []string{want}, node.pins)
+
+	// Import the CID to another path
+	_, err = f.Features().Command(ctx, "import", []string{want, "copies/copy.txt"}, nil)
+	require.NoError(t, err)
+	o, err = f.NewObject(ctx, "copies/copy.txt")
+	require.NoError(t, err)
+	assert.Equal(t, want, o.(fs.IDer).ID())
+
+	// Uploads are pinned to the pinning service
+	f, done = newTestFs(t, node, configmap.Simple{"pinning_service": "pinata"})
+	defer done()
+	upload(ctx, t, f, "pinned/file.txt", "pin me remotely")
+	require.Equal(t, 1, len(node.remotes))
+	assert.Equal(t, api.RemotePin{Cid: cid([]byte("pin me remotely")), Name: "pinned/file.txt", Status: "queued"}, node.remotes[0])
+}
+
+// errorReader returns some data then an error
+type errorReader struct {
+	data []byte
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("read failed")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUpdateFailed(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeNode()
+	f, done := newTestFs(t, node, configmap.Simple{})
+	defer done()
+	o := upload(ctx, t, f, "dir/file.txt", "original")
+
+	// A failed upload leaves the existing file alone
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), -1, true, nil, nil)
+	err := o.Update(ctx, &errorReader{data: []byte("partial")}, src)
+	require.Error(t, err)
+	node.mu.Lock()
+	assert.Equal(t, map[string][]byte{"/dir/file.txt": []byte("original")}, node.files)
+	node.mu.Unlock()
+
+	// A successful one replaces it without leaving anything behind
+	o = upload(ctx, t, f, "dir/file.txt", "replaced")
+	node.mu.Lock()
+	assert.Equal(t, map[string][]byte{"/dir/file.txt": []byte("replaced")}, node.files)
+	node.mu.Unlock()
+
+	// Copies replace the destination too
+	_, err = f.Features().Copy(ctx, upload(ctx, t, f, "other.txt", "other"), "dir/file.txt")
+	require.NoError(t, err)
+	node.mu.Lock()
+	assert.Equal(t, []byte("other"), node.files["/dir/file.txt"])
+	assert.Equal(t, 2, len(node.files))
+	node.mu.Unlock()
+}
@@ -0,0 +1,243 @@
+package local
+
+import (
+	"encoding/binary"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The tags of the entries of a POSIX ACL as the kernel stores them in
+// the system.posix_acl_access and system.posix_acl_default extended
+// attributes
+const (
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+const (
+	aclVersion     = 2          // version of the extended attribute format
+	aclEntrySize   = 8          // size of each entry after the version
+	aclUndefinedID = 0xFFFFFFFF // ID of the entries without a qualifier
+	aclDefault     = "default:" // prefix of the entries of a default ACL
+)
+
+// errACLUnknownQualifier is the cause of the error parsing an ACL entry
+// for a user or group which doesn't exist here, eg one from another
+// system identified by a GUID
+var errACLUnknownQualifier = errors.New("unknown user or group")
+
+// aclEntry is an entry of a POSIX ACL
+type aclEntry struct {
+	tag  uint16
+	perm uint16
+	id   uint32
+}
+
+// aclTagName returns the name of tag in the text form of an ACL
+func aclTagName(tag uint16) string {
+	switch tag {
+	case aclUserObj, aclUser:
+		return "user"
+	case aclGroupObj, aclGroup:
+		return "group"
+	case aclMask:
+		return "mask"
+	case aclOther:
+		return "other"
+	}
+	return ""
+}
+
+// decodePosixACL decodes the ACL in data, which is in the format of
+// the extended attributes, into the entries of its short text form,
+// eg "user::rwx", each with prefix added.
+func decodePosixACL(data []byte, prefix string) ([]string, error) {
+	if len(data) < 4 || (len(data)-4)%aclEntrySize != 0 || binary.LittleEndian.Uint32(data) != aclVersion {
+		return nil, errors.New("invalid POSIX ACL")
+	}
+	var entries []string
+	for p := data[4:]; len(p) > 0; p = p[aclEntrySize:] {
+		e := aclEntry{
+			tag:  binary.LittleEndian.Uint16(p),
+			perm: binary.LittleEndian.Uint16(p[2:]),
+			id:   binary.LittleEndian.Uint32(p[4:]),
+		}
+		name := aclTagName(e.tag)
+		if name == "" {
+			return nil, errors.Errorf("invalid POSIX ACL tag %#x", e.tag)
+		}
+		qualifier := ""
+		if e.tag == aclUser || e.tag == aclGroup {
+			qualifier = strconv.FormatUint(uint64(e.id), 10)
+		}
+		perms := []byte("---")
+		for i, c := range "rwx" {
+			if e.perm&(4>>uint(i)) != 0 {
+				perms[i] = byte(c)
+			}
+		}
+		entries = append(entries, prefix+name+":"+qualifier+":"+string(perms))
+	}
+	return entries, nil
+}
+
+// parseACLEntry parses an entry of the text form of an ACL without
+// any default prefix
+//
+// Users and groups may be given by name or ID.
+func parseACLEntry(text string) (e aclEntry, err error) {
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 {
+		return e, errors.Errorf("invalid ACL entry %q", text)
+	}
+	name, qualifier, perms := parts[0], parts[1], parts[2]
+	e.id = aclUndefinedID
+	switch name {
+	case "user", "u":
+		e.tag = aclUserObj
+		if qualifier != "" {
+			e.tag = aclUser
+			e.id, err = lookupID(qualifier, func(name string) (string, error) {
+				u, err := user.Lookup(name)
+				if err != nil {
+					return "", err
+				}
+				return u.Uid, nil
+			})
+		}
+	case "group", "g":
+		e.tag = aclGroupObj
+		if qualifier != "" {
+			e.tag = aclGroup
+			e.id, err = lookupID(qualifier, func(name string) (string, error) {
+				g, err := user.LookupGroup(name)
+				if err != nil {
+					return "", err
+				}
+				return g.Gid, nil
+			})
+		}
+	case "mask", "m":
+		e.tag = aclMask
+	case "other", "o":
+		e.tag = aclOther
+	default:
+		return e, errors.Errorf("invalid ACL entry %q", text)
+	}
+	if err != nil {
+		return e, errors.Wrapf(err, "invalid ACL entry %q", text)
+	}
+	if (e.tag == aclMask || e.tag == aclOther) && qualifier != "" {
+		return e, errors.Errorf("invalid ACL entry %q", text)
+	}
+	for _, c := range perms {
+		switch c {
+		case 'r':
+			e.perm |= 4
+		case 'w':
+			e.perm |= 2
+		case 'x':
+			e.perm |= 1
+		case '-':
+		default:
+			return e, errors.Errorf("invalid permissions in ACL entry %q", text)
+		}
+	}
+	return e, nil
+}
+
+// lookupID returns qualifier as an ID, looking it up by name with
+// lookup if it isn't numeric
+//
+// It returns errACLUnknownQualifier if the lookup fails.
+func lookupID(qualifier string, lookup func(string) (string, error)) (uint32, error) {
+	id, err := strconv.ParseUint(qualifier, 10, 32)
+	if err == nil {
+		return uint32(id), nil
+	}
+	numeric, err := lookup(qualifier)
+	if err != nil {
+		return 0, errACLUnknownQualifier
+	}
+	id, err = strconv.ParseUint(numeric, 10, 32)
+	return uint32(id), err
+}
+
+// encodePosixACL encodes entries of the text form of an ACL in the
+// format of the extended attributes, sorted into the order the
+// kernel needs
+//
+// The entries for users and groups which don't exist here are left
+// out and returned in skipped. If there are entries for named users
+// or groups and no mask entry the mask is computed from them as
+// setfacl does, as the kernel won't accept the ACL without one.
+func encodePosixACL(entries []string) (data []byte, skipped []string, err error) {
+	acl := make([]aclEntry, 0, len(entries)+1)
+	hasMask, hasNamed := false, false
+	var mask uint16
+	for _, text := range entries {
+		e, err := parseACLEntry(text)
+		if errors.Cause(err) == errACLUnknownQualifier {
+			skipped = append(skipped, text)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		switch e.tag {
+		case aclMask:
+			hasMask = true
+		case aclUser, aclGroup:
+			hasNamed = true
+			mask |= e.perm
+		case aclGroupObj:
+			mask |= e.perm
+		}
+		acl = append(acl, e)
+	}
+	if hasNamed && !hasMask {
+		acl = append(acl, aclEntry{tag: aclMask, perm: mask, id: aclUndefinedID})
+	}
+	sort.Slice(acl, func(i, j int) bool {
+		if acl[i].tag != acl[j].tag {
+			return acl[i].tag < acl[j].tag
+		}
+		return acl[i].id < acl[j].id
+	})
+	data = make([]byte, 4, 4+len(acl)*aclEntrySize)
+	binary.LittleEndian.PutUint32(data, aclVersion)
+	for _, e := range acl {
+		var b [aclEntrySize]byte
+		binary.LittleEndian.PutUint16(b[:], e.tag)
+		binary.LittleEndian.PutUint16(b[2:], e.perm)
+		binary.LittleEndian.PutUint32(b[4:], e.id)
+		data = append(data, b[:]...)
+	}
+	return data, skipped, nil
+}
+
+// splitPosixACL splits the text form of an ACL, as in the acl
+// metadata, into the entries of the access ACL and of the default
+// ACL, with the default prefix removed
+func splitPosixACL(text string) (access, defaults []string) {
+	for _, entry := range strings.Split(text, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, aclDefault):
+			defaults = append(defaults, entry[len(aclDefault):])
+		case strings.HasPrefix(entry, "d:"):
+			defaults = append(defaults, entry[2:])
+		default:
+			access = append(access, entry)
+		}
+	}
+	return access, defaults
+}
@@ -0,0 +1,73 @@
+//+build linux
+
+package local
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// canCopyFile is set if copyFile can copy files in the kernel
+const canCopyFile = true
+
+// ficlone is the FICLONE ioctl from linux/fs.h which makes a file
+// share the data of another
+const ficlone = 0x40049409
+
+// maxCopyFileRange is the most copy_file_range is asked to copy at once
+const maxCopyFileRange = 1 << 30
+
+// copyFile copies size bytes from in to out, which is empty, without
+// reading the data into rclone
+//
+// It makes out a reflink of in on file systems which can share data,
+// like btrfs and XFS, and otherwise has the kernel copy the data with
+// copy_file_range. If neither can be used, for instance because in
+// and out are on different file systems, it returns errCopyUnsupported.
+//
+// copy_file_range may fill in the holes of sparse files so if sparse
+// is set errCopyUnsupported is returned for them instead, leaving
+// them to the copy which keeps the holes.
+func copyFile(out, in *os.File, size int64, sparse bool) error {
+	err := unix.IoctlSetInt(int(out.Fd()), ficlone, int(in.Fd()))
+	if err == nil {
+		return nil
+	}
+	if sparse {
+		ranges, err := dataRanges(in, size)
+		if err != nil {
+			return err
+		}
+		var data int64
+		for _, r := range ranges {
+			data += r.End - r.Start + 1
+		}
+		if data < size {
+			return errCopyUnsupported
+		}
+	}
+	var copied int64
+	for copied < size {
+		n := size - copied
+		if n > maxCopyFileRange {
+			n = maxCopyFileRange
+		}
+		roff, woff := copied, copied
+		got, err := unix.CopyFileRange(int(in.Fd()), &roff, int(out.Fd()), &woff, int(n), 0)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			if copied == 0 && (err == unix.EXDEV || err == unix.ENOSYS || err == unix.EOPNOTSUPP || err == unix.EINVAL) {
+				return errCopyUnsupported
+			}
+			return errors.Wrap(err, "copy_file_range failed")
+		}
+		if got == 0 {
+			return errors.New("source file shrank while copying")
+		}
+		copied += int64(got)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//+build !linux
+
+package local
+
+import "os"
+
+// canCopyFile is set if copyFile can copy files in the kernel
+const canCopyFile = false
+
+// copyFile can't copy files in the kernel on this platform
+func copyFile(out, in *os.File, size int64, sparse bool) error {
+	return errCopyUnsupported
+}
@@ -21,6 +21,10 @@ import "github.com/rclone/rclone/lib/encoder"
 //   . (period)        -> '．' // FULLWIDTH FULL STOP
 //     (space)         -> '␠' // SYMBOL FOR SPACE
 //
+// Names can't be any of the device names Windows reserves either,
+// whatever their extension, so the last character before any extension
+// is replaced with its FULLWIDTH variant, eg CON.txt -> COＮ.txt.
+//
 // Also encode invalid UTF-8 bytes as Go can't convert them to UTF-16.
 //
 // https://docs.microsoft.com/de-de/windows/desktop/FileIO/naming-a-file#naming-conventions
@@ -30,4 +34,5 @@ const defaultEnc = (encoder.Base |
 	encoder.EncodeCtl |
 	encoder.EncodeRightSpace |
 	encoder.EncodeRightPeriod |
+	encoder.EncodeWinReserved |
 	encoder.EncodeInvalidUtf8)
@@ -136,12 +136,17 @@ to override the default choice.`,
 			Advanced: true,
 		}, {
 			Name: "no_sparse",
-			Help: `Disable sparse files for multi-thread downloads
+			Help: `Disable sparse files for multi-thread downloads and sparse copies
 
 On Windows platforms rclone will make sparse files when doing
 multi-thread downloads. This avoids long pauses on large files where
 the OS zeros the file. However sparse files may be undesirable as they
-cause disk fragmentation and can be slow to work with.`,
+cause disk fragmentation and can be slow to work with.
+
+With --sparse, on Linux rclone finds the holes in sparse source files
+and only copies the data, keeping the holes in destinations which
+support random access writes, such as the local backend. This flag
+disables that too.`,
 			Default:  false,
 			Advanced: true,
 		}, {
@@ -155,6 +160,33 @@ when copying to a CIFS mount owned by another user. If this option is
 enabled, rclone will no longer update the modtime after copying a file.`,
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "server_side_copy",
+			Help: `Copy files in the kernel when copying between local paths
+
+On Linux this makes copies between local paths server side copies.
+On file systems which support it, like btrfs and XFS, the copy is a
+reflink which shares the data with the original until either is
+changed, so it is made instantly and takes no space. Otherwise the
+kernel copies the data with copy_file_range without passing it
+through rclone, falling back to a normal copy if it can't.
+
+Server side copies aren't limited by --bwlimit or --max-transfer.`,
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "vss",
+			Help: `Read from a Volume Shadow Copy snapshot of the volume (Windows only)
+
+This makes a VSS snapshot of the volume the remote is on and reads the
+files from that, so files which are open or locked by other programs,
+such as Outlook PST files and databases, can be read in a consistent
+state. The snapshot is removed when rclone exits.
+
+Making snapshots needs rclone to be run as an administrator. The
+snapshot can't be written to so this should only be used for sources.`,
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -178,6 +210,8 @@ type Options struct {
 	CaseInsensitive   bool                 `config:"case_insensitive"`
 	NoSparse          bool                 `config:"no_sparse"`
 	NoSetModTime      bool                 `config:"no_set_modtime"`
+	ServerSideCopy    bool                 `config:"server_side_copy"`
+	VSS               bool                 `config:"vss"`
 	Enc               encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -216,6 +250,9 @@ type Object struct {
 
 var errLinksAndCopyLinks = errors.New("can't use -l/--links with -L/--copy-links")
 
+// errCopyUnsupported is returned by copyFile if it can't copy the file
+var errCopyUnsupported = errors.New("can't copy file in the kernel")
+
 // NewFs constructs an Fs from the path
 func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	// Parse config into Options struct
@@ -240,12 +277,24 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		lstat:  os.Lstat,
 	}
 	f.root = cleanRootPath(root, f.opt.NoUNC, f.opt.Enc)
+	if opt.VSS {
+		if runtime.GOOS != "windows" {
+			return nil, errors.New("vss is only supported on Windows")
+		}
+		f.root, err = snapshotRoot(f.root)
+		if err != nil {
+			return nil, err
+		}
+	}
 	f.features = (&fs.Features{
 		CaseInsensitive:         f.caseInsensitive(),
 		CanHaveEmptyDirectories: true,
 		IsLocal:                 true,
 		SlowHash:                true,
 	}).Fill(f)
+	if !canCopyFile || !opt.ServerSideCopy {
+		f.features.Copy = nil
+	}
 	if opt.FollowSymlinks {
 		f.lstat = os.Stat
 	}
@@ -700,6 +749,166 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	return dstObj, nil
 }
 
+// Copy src to this remote using server side copy operations.
+//
+// The data is copied in the kernel, sharing it with a reflink on
+// file systems which support that. This is only enabled with the
+// server_side_copy option.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (dst fs.Object, err error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	if srcObj.translatedLink {
+		fs.Debugf(src, "Can't copy - translated link")
+		return nil, fs.ErrorCantCopy
+	}
+
+	// Temporary Object under construction
+	dstObj := f.newObject(remote)
+	dstObj.fs.objectMetaMu.RLock()
+	dstObjMode := dstObj.mode
+	dstObj.fs.objectMetaMu.RUnlock()
+
+	// Check it is a file if it exists
+	err = dstObj.lstat()
+	if os.IsNotExist(err) {
+		// OK
+	} else if err != nil {
+		return nil, err
+	} else if !dstObj.fs.isRegular(dstObjMode) {
+		// It isn't a file
+		return nil, errors.New("can't copy file onto non-file")
+	}
+
+	// Create destination
+	err = dstObj.mkdirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := os.Open(srcObj.path)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy to a temporary name then rename it over the destination
+	// so the destination is never partly written
+	tmpPath := dstObj.path + ".rclone-copy"
+	out, err := file.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+	err = copyFile(out, in, info.Size(), !f.opt.NoSparse)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dstObj.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		if err == errCopyUnsupported {
+			fs.Debugf(src, "Can't copy: %v", err)
+			return nil, fs.ErrorCantCopy
+		}
+		return nil, err
+	}
+
+	// Set the mtime
+	err = dstObj.SetModTime(ctx, srcObj.ModTime(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// Update the info
+	err = dstObj.lstat()
+	if err != nil {
+		return nil, err
+	}
+
+	return dstObj, nil
+}
+
+// HardLink makes remote a hard link to src, replacing any existing
+// file at remote.
+//
+// If it isn't possible then return fs.ErrorCantHardLink
+func (f *Fs) HardLink(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't hard link - not same remote type")
+		return nil, fs.ErrorCantHardLink
+	}
+
+	// Temporary Object under construction
+	dstObj := f.newObject(remote)
+	dstObj.fs.objectMetaMu.RLock()
+	dstObjMode := dstObj.mode
+	dstObj.fs.objectMetaMu.RUnlock()
+
+	// Check it is a file if it exists
+	err := dstObj.lstat()
+	if os.IsNotExist(err) {
+		// OK
+	} else if err != nil {
+		return nil, err
+	} else if !dstObj.fs.isRegular(dstObjMode) {
+		// It isn't a file
+		return nil, errors.New("can't hard link onto non-file")
+	} else if srcInfo, err := os.Stat(srcObj.path); err == nil {
+		if dstInfo, err := os.Stat(dstObj.path); err == nil && os.SameFile(srcInfo, dstInfo) {
+			fs.Debugf(dstObj, "Already hard linked")
+			return dstObj, nil
+		}
+	}
+
+	// Create destination
+	err = dstObj.mkdirAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Link to a temporary name then rename it over the destination
+	// so the destination is never missing
+	tmpPath := dstObj.path + ".rclone-link"
+	err = os.Link(srcObj.path, tmpPath)
+	if err != nil {
+		// probably trying to link across file system boundaries
+		// or on a file system without hard links
+		fs.Debugf(src, "Can't hard link: %v", err)
+		return nil, fs.ErrorCantHardLink
+	}
+	err = os.Rename(tmpPath, dstObj.path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, err
+	}
+
+	// Update the info
+	err = dstObj.lstat()
+	if err != nil {
+		return nil, err
+	}
+
+	return dstObj, nil
+}
+
 // DirMove moves src, srcRemote to this remote at dstRemote
 // using server side move operations.
 //
@@ -1220,6 +1429,70 @@ func (f *Fs) OpenWriterAt(ctx context.Context, remote string, size int64) (fs.Wr
 	return out, nil
 }
 
+// BlockHashes returns the MD5 hashes of each blockSize block of the
+// object for use in delta transfers
+func (o *Object) BlockHashes(ctx context.Context, blockSize int64) (sums []string, err error) {
+	if o.translatedLink {
+		return nil, errors.New("can't read block hashes of a symlink")
+	}
+	fd, err := file.Open(o.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "block hashes: failed to open")
+	}
+	in := newFadviseReadCloser(o, fd, 0, 0)
+	defer fs.CheckClose(in, &err)
+	sums, err = hash.StreamBlocks(in, blockSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "block hashes: failed to read")
+	}
+	return sums, nil
+}
+
+// partialUpdate is a handle for writing blocks into an existing file
+type partialUpdate struct {
+	*os.File
+	o    *Object
+	size int64
+}
+
+// Close truncates the file to the final size, closes it and updates
+// the metadata of the object
+func (pu *partialUpdate) Close() (err error) {
+	err = pu.File.Truncate(pu.size)
+	closeErr := pu.File.Close()
+	if err != nil {
+		return errors.Wrap(err, "partial update: failed to truncate")
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	pu.o.fs.objectMetaMu.Lock()
+	pu.o.hashes = nil
+	pu.o.fs.objectMetaMu.Unlock()
+	return pu.o.lstat()
+}
+
+// OpenPartialUpdate opens the existing object for random access
+// writes without truncating it. It is truncated to size on Close.
+func (o *Object) OpenPartialUpdate(ctx context.Context, size int64) (fs.WriterAtCloser, error) {
+	if o.translatedLink {
+		return nil, errors.New("can't open a symlink for random writing")
+	}
+	out, err := file.OpenFile(o.path, os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &partialUpdate{File: out, o: o, size: size}, nil
+}
+
+// OpenOSFile opens the file the object is stored in for reading
+func (o *Object) OpenOSFile(ctx context.Context) (*os.File, error) {
+	if o.translatedLink {
+		return nil, errors.New("can't open a symlink as a file")
+	}
+	return file.Open(o.path)
+}
+
 // setMetadata sets the file info from the os.FileInfo passed in
 func (o *Object) setMetadata(info os.FileInfo) {
 	// if not checking updated then don't update the stat
@@ -1281,9 +1554,14 @@ var (
 	_ fs.Fs             = &Fs{}
 	_ fs.Purger         = &Fs{}
 	_ fs.PutStreamer    = &Fs{}
+	_ fs.Copier         = &Fs{}
 	_ fs.Mover          = &Fs{}
 	_ fs.DirMover       = &Fs{}
+	_ fs.HardLinker     = &Fs{}
 	_ fs.Commander      = &Fs{}
 	_ fs.OpenWriterAter = &Fs{}
 	_ fs.Object         = &Object{}
+	_ fs.BlockHasher    = &Object{}
+	_ fs.PartialUpdater = &Object{}
+	_ fs.OSFileOpener   = &Object{}
 )
@@ -175,3 +175,249 @@ func TestSymlinkError(t *testing.T) {
 	_, err := NewFs("local", "/", m)
 	assert.Equal(t, errLinksAndCopyLinks, err)
 }
+
+func TestMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test as file modes aren't supported on Windows")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	modTime1 := fstest.Time("2001-02-03T04:05:10.123123123Z")
+	file1 := r.WriteFile("file.txt", "hello", modTime1)
+	require.NoError(t, os.Chmod(filepath.Join(f.root, file1.Path), 0600))
+	o, err := f.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+
+	metadata, err := o.(*Object).Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "0600", metadata[fs.MetadataMode])
+	mtime, ok := metadata.Time(fs.MetadataMtime)
+	assert.True(t, ok)
+	assert.True(t, modTime1.Equal(mtime), "want %v got %v", modTime1, mtime)
+
+	// Set it on another file
+	modTime2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	file2 := r.WriteFile("file2.txt", "hello", modTime2)
+	o2, err := f.NewObject(ctx, file2.Path)
+	require.NoError(t, err)
+	require.NoError(t, o2.(*Object).SetMetadata(ctx, metadata))
+
+	info, err := os.Stat(filepath.Join(f.root, file2.Path))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.True(t, modTime1.Equal(o2.ModTime(ctx)), "want %v got %v", modTime1, o2.ModTime(ctx))
+}
+
+func TestDataRanges(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	// Write a file with data in the middle of a hole
+	const size = 4 << 20
+	require.NoError(t, os.MkdirAll(f.root, 0777))
+	fd, err := os.Create(filepath.Join(f.root, "sparse"))
+	require.NoError(t, err)
+	require.NoError(t, fd.Truncate(size))
+	_, err = fd.WriteAt([]byte("hello"), 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+
+	o, err := f.NewObject(ctx, "sparse")
+	require.NoError(t, err)
+	ranges, err := o.(*Object).DataRanges(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, 0, len(ranges))
+	if len(ranges) == 1 && ranges[0].Start == 0 && ranges[0].End == size-1 {
+		t.Skip("Skipping test as sparse files aren't supported here")
+	}
+	var data int64
+	found := false
+	for _, r := range ranges {
+		data += r.End - r.Start + 1
+		if r.Start <= 1<<20 && r.End >= 1<<20+4 {
+			found = true
+		}
+	}
+	assert.True(t, found, "data not in %v", ranges)
+	assert.True(t, data < size, "no holes in %v", ranges)
+}
+
+func TestHardLink(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	modTime1 := fstest.Time("2001-02-03T04:05:10.123123123Z")
+	modTime2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	file1 := r.WriteFile("file.txt", "hello", modTime1)
+	file2 := r.WriteFile("dir/file2.txt", "hello", modTime2)
+	o, err := f.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		o2, err := f.HardLink(ctx, o, file2.Path)
+		require.NoError(t, err)
+		assert.True(t, modTime1.Equal(o2.ModTime(ctx)))
+	}
+
+	info1, err := os.Stat(filepath.Join(f.root, file1.Path))
+	require.NoError(t, err)
+	info2, err := os.Stat(filepath.Join(f.root, file2.Path))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(info1, info2))
+	file2.ModTime = modTime1
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+}
+
+func TestCopy(t *testing.T) {
+	if !canCopyFile {
+		t.Skip("Skipping test as files can't be copied in the kernel on this OS")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	modTime1 := fstest.Time("2001-02-03T04:05:10.123123123Z")
+	file1 := r.WriteFile("file.txt", "hello copy", modTime1)
+	o, err := f.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+
+	o2, err := f.Copy(ctx, o, "dir/file2.txt")
+	if err == fs.ErrorCantCopy {
+		t.Skip("Skipping test as the file system can't copy files in the kernel")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file2.txt", o2.Remote())
+	assert.True(t, modTime1.Equal(o2.ModTime(ctx)))
+
+	info1, err := os.Stat(filepath.Join(f.root, file1.Path))
+	require.NoError(t, err)
+	info2, err := os.Stat(filepath.Join(f.root, "dir/file2.txt"))
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(info1, info2))
+	file2 := fstest.NewItem("dir/file2.txt", "hello copy", modTime1)
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+}
+
+func TestPosixACL(t *testing.T) {
+	access, defaults := splitPosixACL("user::rw-,user:1000:r--, group::r--,mask::r--,other::---,default:user::rwx,d:other::r-x")
+	assert.Equal(t, []string{"user::rw-", "user:1000:r--", "group::r--", "mask::r--", "other::---"}, access)
+	assert.Equal(t, []string{"user::rwx", "other::r-x"}, defaults)
+
+	// Entries are sorted into the order the kernel wants
+	data, skipped, err := encodePosixACL([]string{"other::---", "mask::r--", "group:50:rwx", "user:1000:r--", "group::r--", "user::rw-"})
+	require.NoError(t, err)
+	assert.Nil(t, skipped)
+	assert.Equal(t, 4+6*aclEntrySize, len(data))
+	entries, err := decodePosixACL(data, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user::rw-", "user:1000:r--", "group::r--", "group:50:rwx", "mask::r--", "other::---"}, entries)
+	entries, err = decodePosixACL(data, aclDefault)
+	require.NoError(t, err)
+	assert.Equal(t, "default:user::rw-", entries[0])
+
+	for _, bad := range []string{"user:rw-", "bob::rwx", "user::rwz", "other:1:r--"} {
+		_, _, err = encodePosixACL([]string{bad})
+		assert.Error(t, err, bad)
+	}
+
+	// Unknown users and groups are skipped and the mask is computed
+	// if there are named entries without one
+	guid := "b6ef3a1c-0c34-4b6e-a3a4-2f1c2a9d8e01"
+	data, skipped, err = encodePosixACL([]string{"user::rw-", "user:" + guid + ":rwx", "group::r--", "group:50:-w-", "other::---"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:" + guid + ":rwx"}, skipped)
+	entries, err = decodePosixACL(data, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user::rw-", "group::r--", "group:50:-w-", "mask::rw-", "other::---"}, entries)
+
+	// The mask isn't needed without named entries
+	data, skipped, err = encodePosixACL([]string{"user::rw-", "group:" + guid + ":r--", "group::r--", "other::---"})
+	require.NoError(t, err)
+	assert.Len(t, skipped, 1)
+	entries, err = decodePosixACL(data, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user::rw-", "group::r--", "other::---"}, entries)
+	_, err = decodePosixACL([]byte{1, 0, 0, 0}, "")
+	assert.Error(t, err)
+}
+
+func TestMetadataACL(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping test as ACLs are only supported on Linux")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	f := r.Flocal.(*Fs)
+
+	file1 := r.WriteFile("file.txt", "hello", time.Now())
+	o, err := f.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	acl := "user::rw-,user:1000:r--,group::r--,mask::r--,other::---"
+	require.NoError(t, o.(*Object).SetMetadata(ctx, fs.Metadata{fs.MetadataACL: acl}))
+
+	metadata, err := o.(*Object).Metadata(ctx)
+	require.NoError(t, err)
+	if _, ok := metadata[fs.MetadataACL]; !ok {
+		t.Skip("Skipping test as the file system doesn't support ACLs")
+	}
+	assert.Equal(t, acl, metadata[fs.MetadataACL])
+	assert.Equal(t, "0640", metadata[fs.MetadataMode])
+	for key := range metadata {
+		assert.NotContains(t, key, "posix_acl")
+	}
+
+	// Unknown users are skipped and the mask added
+	guid := "b6ef3a1c-0c34-4b6e-a3a4-2f1c2a9d8e01"
+	require.NoError(t, o.(*Object).SetMetadata(ctx, fs.Metadata{fs.MetadataACL: "user::rw-,user:" + guid + ":rwx,user:1000:r--,group::r--,other::---"}))
+	metadata, err = o.(*Object).Metadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, acl, metadata[fs.MetadataACL])
+}
+
+func TestVSSHelpers(t *testing.T) {
+	for _, test := range []struct {
+		root   string
+		volume string
+		path   string
+	}{
+		{`\\?\C:\Users\me\Documents`, `C:\`, `\Users\me\Documents`},
+		{`d:\data`, `D:\`, `\data`},
+		{`\\?\E:\`, `E:\`, `\`},
+		{`E:`, `E:\`, `\`},
+	} {
+		volume, p, err := vssVolume(test.root)
+		require.NoError(t, err, test.root)
+		assert.Equal(t, test.volume, volume, test.root)
+		assert.Equal(t, test.path, p, test.root)
+	}
+	for _, root := range []string{`\\?\UNC\server\share\dir`, `/home/me`, ``} {
+		_, _, err := vssVolume(root)
+		assert.Error(t, err, root)
+	}
+
+	out := "\r\n{2B7A5C1B-0000-4E4E-9D6B-1234567890AB}\r\n\\\\?\\GLOBALROOT\\Device\\HarddiskVolumeShadowCopy7\r\n"
+	snapshot, err := parseVSSOutput(out)
+	require.NoError(t, err)
+	assert.Equal(t, "{2B7A5C1B-0000-4E4E-9D6B-1234567890AB}", snapshot.id)
+	assert.Equal(t, `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy7\Users\me`, snapshot.path(`\Users\me`))
+	_, err = parseVSSOutput("something else\n")
+	assert.Error(t, err)
+
+	assert.Equal(t, "access denied - rclone must be run as an administrator", vssError("error 1\r\n"))
+	assert.Equal(t, "other problem", vssError("other problem\n"))
+	assert.Contains(t, vssCreateScript(`C:\`), `Volume='C:\'`)
+
+	if runtime.GOOS != "windows" {
+		_, err = NewFs("local", "/tmp", configmap.Simple{"vss": "true"})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,78 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// Metadata returns the permissions, times, owner and extended
+// attributes of the file
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	info, err := o.fs.lstat(o.path)
+	if err != nil {
+		return nil, err
+	}
+	metadata := fs.Metadata{
+		fs.MetadataMode: fmt.Sprintf("%04o", info.Mode().Perm()),
+	}
+	metadata.SetTime(fs.MetadataMtime, info.ModTime())
+	err = readMetadataSys(o.path, info, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// SetMetadata sets the permissions, times, owner and extended
+// attributes of the file from metadata
+//
+// Setting the owner needs privileges so failures to do that are only
+// logged.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	if o.translatedLink {
+		fs.Debugf(o, "Can't set metadata on a translated link")
+		return nil
+	}
+	if mode, ok := metadata[fs.MetadataMode]; ok {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid mode %q", mode)
+		}
+		err = os.Chmod(o.path, os.FileMode(perm)&os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+	err := setMetadataSys(o, metadata)
+	if err != nil {
+		return err
+	}
+	// Set the times last as setting the other metadata may change them
+	mtime, haveMtime := metadata.Time(fs.MetadataMtime)
+	atime, haveAtime := metadata.Time(fs.MetadataAtime)
+	if (haveMtime || haveAtime) && !o.fs.opt.NoSetModTime {
+		if !haveMtime {
+			mtime = o.ModTime(ctx)
+		}
+		if !haveAtime {
+			atime = mtime
+		}
+		err = os.Chtimes(o.path, atime, mtime)
+		if err != nil {
+			return err
+		}
+	}
+	// Re-read metadata
+	return o.lstat()
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Metadataer    = &Object{}
+	_ fs.SetMetadataer = &Object{}
+)
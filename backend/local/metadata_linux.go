@@ -0,0 +1,166 @@
+//+build linux
+
+package local
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+// Names of the extended attributes the kernel keeps POSIX ACLs in
+const (
+	xattrACLAccess  = "system.posix_acl_access"
+	xattrACLDefault = "system.posix_acl_default"
+)
+
+// readMetadataSys adds the owner, access and change times, the POSIX
+// ACL and the extended attributes of the file at path to metadata
+//
+// The ACL is only there if the file has entries beyond those its
+// mode gives it.
+func readMetadataSys(path string, info os.FileInfo, metadata fs.Metadata) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		metadata[fs.MetadataUID] = strconv.FormatUint(uint64(stat.Uid), 10)
+		metadata[fs.MetadataGID] = strconv.FormatUint(uint64(stat.Gid), 10)
+		metadata.SetTime(fs.MetadataAtime, time.Unix(stat.Atim.Unix()))
+		metadata.SetTime(fs.MetadataCtime, time.Unix(stat.Ctim.Unix()))
+	}
+	names, err := listXattrs(path)
+	if err != nil {
+		return err
+	}
+	var acl []string
+	for _, name := range names {
+		value, err := getXattr(path, name)
+		if err == unix.ENODATA {
+			continue // removed since listing
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read xattr %q", name)
+		}
+		switch name {
+		case xattrACLAccess, xattrACLDefault:
+			prefix := ""
+			if name == xattrACLDefault {
+				prefix = aclDefault
+			}
+			entries, err := decodePosixACL(value, prefix)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read xattr %q", name)
+			}
+			acl = append(acl, entries...)
+		default:
+			metadata[fs.MetadataXattrPrefix+name] = string(value)
+		}
+	}
+	if len(acl) > 0 {
+		metadata[fs.MetadataACL] = strings.Join(acl, ",")
+	}
+	return nil
+}
+
+// setMetadataSys sets the owner, extended attributes and POSIX ACL
+// of the file from metadata
+func setMetadataSys(o *Object, metadata fs.Metadata) error {
+	uid, gid := -1, -1
+	if value, ok := metadata[fs.MetadataUID]; ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			uid = n
+		}
+	}
+	if value, ok := metadata[fs.MetadataGID]; ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			gid = n
+		}
+	}
+	if uid >= 0 || gid >= 0 {
+		err := os.Lchown(o.path, uid, gid)
+		if err != nil {
+			fs.Debugf(o, "Failed to set owner: %v", err)
+		}
+	}
+	for name, value := range metadata.Xattrs() {
+		err := unix.Lsetxattr(o.path, name, []byte(value), 0)
+		if err == unix.ENOTSUP || err == unix.EPERM || err == unix.EACCES {
+			fs.Debugf(o, "Failed to set xattr %q: %v", name, err)
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to set xattr %q", name)
+		}
+	}
+	if value, ok := metadata[fs.MetadataACL]; ok {
+		return setPosixACL(o, value)
+	}
+	return nil
+}
+
+// setPosixACL sets the POSIX ACL of the file from its text form
+//
+// Default ACLs only apply to directories so they are ignored here.
+// File systems without ACLs are only logged like other xattrs.
+func setPosixACL(o *Object, text string) error {
+	access, _ := splitPosixACL(text)
+	if len(access) == 0 {
+		return nil
+	}
+	data, skipped, err := encodePosixACL(access)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		fs.Logf(o, "Ignoring ACL entries for users and groups which don't exist here: %s", strings.Join(skipped, ","))
+	}
+	err = unix.Lsetxattr(o.path, xattrACLAccess, data, 0)
+	if err == unix.ENOTSUP || err == unix.EPERM || err == unix.EACCES {
+		fs.Debugf(o, "Failed to set ACL: %v", err)
+	} else if err != nil {
+		return errors.Wrap(err, "failed to set ACL")
+	}
+	return nil
+}
+
+// listXattrs returns the names of the extended attributes of path
+func listXattrs(path string) ([]string, error) {
+	for {
+		size, err := unix.Llistxattr(path, nil)
+		if err == unix.ENOTSUP {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to list xattrs")
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := unix.Llistxattr(path, buf)
+		if err == unix.ERANGE {
+			continue // grown since we read the size
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to list xattrs")
+		}
+		return strings.Split(strings.TrimRight(string(buf[:n]), "\x00"), "\x00"), nil
+	}
+}
+
+// getXattr returns the value of the extended attribute name of path
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := unix.Lgetxattr(path, name, buf)
+		if err == unix.ERANGE {
+			continue // grown since we read the size
+		} else if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,21 @@
+//+build !linux
+
+package local
+
+import (
+	"os"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// readMetadataSys adds the metadata only available on some platforms
+// to metadata - there is none on this platform
+func readMetadataSys(path string, info os.FileInfo, metadata fs.Metadata) error {
+	return nil
+}
+
+// setMetadataSys sets the metadata only available on some platforms -
+// there is none on this platform
+func setMetadataSys(o *Object, metadata fs.Metadata) error {
+	return nil
+}
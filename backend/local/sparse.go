@@ -0,0 +1,30 @@
+package local
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// DataRanges returns the parts of the file which contain data, leaving
+// out the holes if it is a sparse file
+func (o *Object) DataRanges(ctx context.Context) (ranges []fs.RangeOption, err error) {
+	if o.translatedLink {
+		return nil, errors.New("can't read the data ranges of a symlink")
+	}
+	size := o.Size()
+	if o.fs.opt.NoSparse || size <= 0 {
+		return []fs.RangeOption{{Start: 0, End: size - 1}}, nil
+	}
+	in, err := os.Open(o.path)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	return dataRanges(in, size)
+}
+
+// Check the interfaces are satisfied
+var _ fs.DataRanger = &Object{}
@@ -0,0 +1,45 @@
+//+build linux
+
+package local
+
+import (
+	"os"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+// whence values for lseek from linux/fs.h
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
+
+// dataRanges finds the data in the file of size using SEEK_DATA and
+// SEEK_HOLE
+func dataRanges(in *os.File, size int64) (ranges []fs.RangeOption, err error) {
+	fd := int(in.Fd())
+	for offset := int64(0); offset < size; {
+		start, err := unix.Seek(fd, offset, seekData)
+		if err == unix.ENXIO {
+			break // the rest of the file is a hole
+		} else if err == unix.EINVAL {
+			// SEEK_DATA isn't supported so treat it all as data
+			return []fs.RangeOption{{Start: 0, End: size - 1}}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		end, err := unix.Seek(fd, start, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if end > size {
+			end = size // the file has grown
+		}
+		if end > start {
+			ranges = append(ranges, fs.RangeOption{Start: start, End: end - 1})
+		}
+		offset = end
+	}
+	return ranges, nil
+}
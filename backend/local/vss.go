@@ -0,0 +1,136 @@
+// Reading from Volume Shadow Copy snapshots on Windows
+
+package local
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// vssSnapshot is a Volume Shadow Copy snapshot of a volume
+type vssSnapshot struct {
+	id     string // ID of the shadow copy, eg "{2B7A5C1B-...}"
+	device string // device object to read it from, eg `\\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1`
+}
+
+var (
+	vssMu        sync.Mutex
+	vssSnapshots = map[string]*vssSnapshot{} // snapshots made by volume
+)
+
+// vssVolume splits root, a Windows path with or without the `\\?\`
+// prefix, into the volume, eg `C:\`, and the path on it
+//
+// Only paths on drives with letters can be snapshotted.
+func vssVolume(root string) (volume, p string, err error) {
+	s := strings.TrimPrefix(root, `\\?\`)
+	if len(s) < 2 || s[1] != ':' || !(('a' <= s[0] && s[0] <= 'z') || ('A' <= s[0] && s[0] <= 'Z')) {
+		return "", "", errors.Errorf("can't snapshot %q: only paths on lettered drives can be snapshotted", root)
+	}
+	p = strings.TrimLeft(s[2:], `\`)
+	return strings.ToUpper(s[:1]) + `:\`, `\` + p, nil
+}
+
+// path returns the path of p, a path on the volume, in the snapshot
+func (s *vssSnapshot) path(p string) string {
+	return strings.TrimRight(s.device, `\`) + p
+}
+
+// parseVSSOutput reads the ID and device object of a snapshot from
+// the output of vssCreateScript
+func parseVSSOutput(out string) (*vssSnapshot, error) {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "{") || !strings.HasPrefix(lines[1], `\\?\`) {
+		return nil, errors.Errorf("unexpected output %q", out)
+	}
+	return &vssSnapshot{id: lines[0], device: lines[1]}, nil
+}
+
+// vssCreateScript returns the PowerShell to make a snapshot of volume
+// and print its ID and device object
+func vssCreateScript(volume string) string {
+	return `$ErrorActionPreference = 'Stop'
+$r = Invoke-CimMethod -ClassName Win32_ShadowCopy -MethodName Create -Arguments @{Volume='` + volume + `'; Context='ClientAccessible'}
+if ($r.ReturnValue -ne 0) { [Console]::Error.WriteLine('error ' + $r.ReturnValue); exit 1 }
+$s = Get-CimInstance -ClassName Win32_ShadowCopy -Filter ("ID='" + $r.ShadowID + "'")
+$s.ID
+$s.DeviceObject
+`
+}
+
+// vssRemoveScript returns the PowerShell to remove the snapshot with id
+func vssRemoveScript(id string) string {
+	return `$ErrorActionPreference = 'Stop'
+Get-CimInstance -ClassName Win32_ShadowCopy -Filter "ID='` + id + `'" | Remove-CimInstance
+`
+}
+
+// vssErrors are the meanings of the return values of
+// Win32_ShadowCopy.Create
+var vssErrors = map[string]string{
+	"1":  "access denied - rclone must be run as an administrator",
+	"2":  "invalid argument",
+	"3":  "the volume wasn't found",
+	"4":  "the volume doesn't support shadow copies",
+	"5":  "the shadow copy context isn't supported",
+	"6":  "not enough free space on the volume",
+	"7":  "the maximum number of shadow copies has been reached",
+	"8":  "another shadow copy is being made",
+	"9":  "the shadow copy provider vetoed the operation",
+	"10": "the shadow copy provider isn't registered",
+	"11": "the shadow copy provider failed",
+	"12": "unknown error",
+}
+
+// vssError translates the error output of vssCreateScript
+func vssError(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if code := strings.TrimPrefix(stderr, "error "); code != stderr {
+		if message, ok := vssErrors[code]; ok {
+			return message
+		}
+	}
+	return stderr
+}
+
+// snapshotRoot returns the path of root in a snapshot of its volume,
+// making the snapshot if needed
+//
+// The snapshots are shared by all the remotes on the volume and
+// removed when rclone exits.
+func snapshotRoot(root string) (string, error) {
+	volume, p, err := vssVolume(root)
+	if err != nil {
+		return "", err
+	}
+	vssMu.Lock()
+	defer vssMu.Unlock()
+	snapshot, ok := vssSnapshots[volume]
+	if !ok {
+		fs.Infof(nil, "Making VSS snapshot of %s", volume)
+		snapshot, err = createVSSSnapshot(volume)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to make VSS snapshot of %s", volume)
+		}
+		fs.Debugf(nil, "Made VSS snapshot %s of %s at %s", snapshot.id, volume, snapshot.device)
+		vssSnapshots[volume] = snapshot
+		atexit.Register(func() {
+			fs.Infof(nil, "Removing VSS snapshot of %s", volume)
+			err := removeVSSSnapshot(snapshot)
+			if err != nil {
+				fs.Errorf(nil, "Failed to remove VSS snapshot %s of %s: %v", snapshot.id, volume, err)
+			}
+		})
+	}
+	return snapshot.path(p), nil
+}
@@ -0,0 +1,18 @@
+//+build !windows
+
+package local
+
+import "github.com/pkg/errors"
+
+// errVSSNotSupported is returned when making snapshots on this platform
+var errVSSNotSupported = errors.New("VSS snapshots are only supported on Windows")
+
+// createVSSSnapshot isn't supported on this platform
+func createVSSSnapshot(volume string) (*vssSnapshot, error) {
+	return nil, errVSSNotSupported
+}
+
+// removeVSSSnapshot isn't supported on this platform
+func removeVSSSnapshot(snapshot *vssSnapshot) error {
+	return errVSSNotSupported
+}
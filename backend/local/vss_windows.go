@@ -0,0 +1,42 @@
+//+build windows
+
+package local
+
+import (
+	"bytes"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// runPowerShell runs script with PowerShell returning its output
+func runPowerShell(script string) (string, error) {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "-")
+	cmd.Stdin = bytes.NewBufferString(script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		if stderr.Len() > 0 {
+			return "", errors.New(vssError(stderr.String()))
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// createVSSSnapshot makes a snapshot of volume, eg `C:\`
+func createVSSSnapshot(volume string) (*vssSnapshot, error) {
+	out, err := runPowerShell(vssCreateScript(volume))
+	if err != nil {
+		return nil, err
+	}
+	return parseVSSOutput(out)
+}
+
+// removeVSSSnapshot removes the snapshot
+func removeVSSSnapshot(snapshot *vssSnapshot) error {
+	_, err := runPowerShell(vssRemoveScript(snapshot.id))
+	return err
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/bucket"
@@ -35,12 +36,33 @@ func init() {
 		Name:        "memory",
 		Description: "In memory object storage system.",
 		NewFs:       NewFs,
-		Options:     []fs.Option{},
+		Options: []fs.Option{{
+			Name: "snapshot",
+			Help: `Path of a file to keep the contents in between runs
+
+If this is set then the contents are loaded from this file when the
+remote is first used and saved to it when rclone exits, so the memory
+backend can be used as a fast staging area which isn't lost.
+
+All memory remotes share the same storage so everything in them is
+saved.`,
+			Advanced: true,
+		}, {
+			Name: "max_size",
+			Help: `Maximum size of all the objects stored
+
+Uploads which would make the objects stored by all the memory remotes
+bigger than this fail.`,
+			Default:  fs.SizeSuffix(-1),
+			Advanced: true,
+		}},
 	})
 }
 
 // Options defines the configuration for this backend
 type Options struct {
+	Snapshot string        `config:"snapshot"`
+	MaxSize  fs.SizeSuffix `config:"max_size"`
 }
 
 // Fs represents a remote memory server
@@ -57,6 +79,9 @@ type Fs struct {
 type bucketsInfo struct {
 	mu      sync.RWMutex
 	buckets map[string]*bucketInfo
+
+	sizeMu sync.Mutex
+	size   int64 // total size of the objects stored
 }
 
 func newBucketsInfo() *bucketsInfo {
@@ -110,12 +135,41 @@ func (bi *bucketsInfo) getObjectData(bucketName, bucketPath string) (od *objectD
 	return b.getObjectData(bucketPath)
 }
 
+// addSize changes the total size by the difference between the
+// object old being replaced and the size of the new one, returning an
+// error if this would take it over maxSize unless that is negative
+func (bi *bucketsInfo) addSize(old *objectData, size int64, maxSize fs.SizeSuffix) error {
+	if old != nil {
+		size -= int64(len(old.data))
+	}
+	bi.sizeMu.Lock()
+	defer bi.sizeMu.Unlock()
+	if maxSize >= 0 && size > 0 && bi.size+size > int64(maxSize) {
+		return fserrors.NoRetryError(errors.Errorf("memory backend full - max_size %v reached", maxSize))
+	}
+	bi.size += size
+	return nil
+}
+
+// usedSize returns the total size of the objects stored
+func (bi *bucketsInfo) usedSize() int64 {
+	bi.sizeMu.Lock()
+	defer bi.sizeMu.Unlock()
+	return bi.size
+}
+
 // updateObjectData updates an object from (bucketName, bucketPath)
-func (bi *bucketsInfo) updateObjectData(bucketName, bucketPath string, od *objectData) {
+// returning an error if there isn't room for it under maxSize
+func (bi *bucketsInfo) updateObjectData(bucketName, bucketPath string, od *objectData, maxSize fs.SizeSuffix) error {
 	b := bi.makeBucket(bucketName)
 	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := bi.addSize(b.objects[bucketPath], int64(len(od.data)), maxSize)
+	if err != nil {
+		return err
+	}
 	b.objects[bucketPath] = od
-	b.mu.Unlock()
+	return nil
 }
 
 // removeObjectData removes an object from (bucketName, bucketPath) returning true if removed
@@ -126,6 +180,7 @@ func (bi *bucketsInfo) removeObjectData(bucketName, bucketPath string) (removed
 		od := b.objects[bucketPath]
 		if od != nil {
 			delete(b.objects, bucketPath)
+			_ = bi.addSize(od, 0, -1)
 			removed = true
 		}
 		b.mu.Unlock()
@@ -236,6 +291,12 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		opt:  *opt,
 	}
 	f.setRoot(root)
+	if opt.Snapshot != "" {
+		err = useSnapshot(opt.Snapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
 	f.features = (&fs.Features{
 		ReadMimeType:      true,
 		WriteMimeType:     true,
@@ -484,10 +545,26 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	if od == nil {
 		return nil, fs.ErrorObjectNotFound
 	}
-	buckets.updateObjectData(dstBucket, dstPath, od)
+	err := buckets.updateObjectData(dstBucket, dstPath, od, f.opt.MaxSize)
+	if err != nil {
+		return nil, err
+	}
 	return f.NewObject(ctx, remote)
 }
 
+// About gets quota information
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	used := buckets.usedSize()
+	usage := &fs.Usage{
+		Used: fs.NewUsageValue(used),
+	}
+	if f.opt.MaxSize >= 0 {
+		usage.Total = fs.NewUsageValue(int64(f.opt.MaxSize))
+		usage.Free = fs.NewUsageValue(int64(f.opt.MaxSize) - used)
+	}
+	return usage, nil
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hashType)
@@ -588,13 +665,17 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if err != nil {
 		return errors.Wrap(err, "failed to update memory object")
 	}
-	o.od = &objectData{
+	od := &objectData{
 		data:     data,
 		hash:     "",
 		modTime:  src.ModTime(ctx),
 		mimeType: fs.MimeType(ctx, o),
 	}
-	buckets.updateObjectData(bucket, bucketPath, o.od)
+	err = buckets.updateObjectData(bucket, bucketPath, od, o.fs.opt.MaxSize)
+	if err != nil {
+		return err
+	}
+	o.od = od
 	return nil
 }
 
@@ -619,6 +700,7 @@ var (
 	_ fs.Copier      = &Fs{}
 	_ fs.PutStreamer = &Fs{}
 	_ fs.ListRer     = &Fs{}
+	_ fs.Abouter     = &Fs{}
 	_ fs.Object      = &Object{}
 	_ fs.MimeTyper   = &Object{}
 )
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func put(ctx context.Context, t *testing.T, f fs.Fs, remote string, data []byte) (fs.Object, error) {
+	src := object.NewStaticObjectInfo(remote, time.Unix(1600000000, 0), int64(len(data)), true, nil, nil)
+	return f.Put(ctx, bytes.NewReader(data), src)
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-memory")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "snapshot")
+
+	f, err := NewFs("memory", "snapshot-test", configmap.Simple{"snapshot": path, "max_size": "off"})
+	require.NoError(t, err)
+	_, err = put(ctx, t, f, "dir/file.txt", []byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, buckets.saveSnapshot(path))
+
+	// Lose everything then load it back
+	o, err := f.NewObject(ctx, "dir/file.txt")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+	require.NoError(t, f.Rmdir(ctx, ""))
+	_, err = f.NewObject(ctx, "dir/file.txt")
+	require.Equal(t, fs.ErrorObjectNotFound, err)
+
+	require.NoError(t, buckets.loadSnapshot(path))
+	o, err = f.NewObject(ctx, "dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), o.Size())
+	assert.True(t, o.ModTime(ctx).Equal(time.Unix(1600000000, 0)))
+	assert.Equal(t, "text/plain; charset=utf-8", o.(*Object).MimeType(ctx))
+	require.NoError(t, o.Remove(ctx))
+	require.NoError(t, f.Rmdir(ctx, ""))
+
+	// A bad snapshot is an error
+	require.NoError(t, ioutil.WriteFile(path, []byte("potato"), 0666))
+	err = buckets.loadSnapshot(path)
+	assert.Error(t, err)
+	// A missing one isn't
+	require.NoError(t, useSnapshot(filepath.Join(dir, "missing")))
+}
+
+func TestMaxSize(t *testing.T) {
+	ctx := context.Background()
+	used := buckets.usedSize()
+	f, err := NewFs("memory", "max-size-test", configmap.Simple{})
+	require.NoError(t, err)
+	f.(*Fs).opt.MaxSize = fs.SizeSuffix(used + 10)
+
+	_, err = put(ctx, t, f, "file1", make([]byte, 6))
+	require.NoError(t, err)
+	_, err = put(ctx, t, f, "file2", make([]byte, 6))
+	require.Error(t, err)
+	assert.True(t, fserrors.IsNoRetryError(err))
+	assert.Contains(t, err.Error(), "memory backend full")
+	_, err = f.NewObject(ctx, "file2")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	// Replacing an object only needs room for the difference
+	_, err = put(ctx, t, f, "file1", make([]byte, 10))
+	require.NoError(t, err)
+
+	usage, err := f.Features().About(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, used+10, *usage.Used)
+	assert.Equal(t, int64(0), *usage.Free)
+
+	o, err := f.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+	assert.Equal(t, used, buckets.usedSize())
+	require.NoError(t, f.Rmdir(ctx, ""))
+}
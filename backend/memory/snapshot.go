@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// snapshotObject is an object as it is saved in a snapshot
+type snapshotObject struct {
+	ModTime  time.Time
+	Hash     string
+	MimeType string
+	Data     []byte
+}
+
+// snapshot is the contents of the memory backend as saved to a file
+type snapshot struct {
+	Buckets map[string]map[string]snapshotObject
+}
+
+var (
+	snapshotsMu sync.Mutex
+	snapshots   = map[string]bool{} // snapshot files loaded and saved at exit
+)
+
+// useSnapshot loads the snapshot at path, if it exists, and saves the
+// contents to it at exit
+//
+// This only happens once for each path however many remotes use it.
+func useSnapshot(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	if snapshots[path] {
+		return nil
+	}
+	err = buckets.loadSnapshot(path)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+	snapshots[path] = true
+	atexit.Register(func() {
+		err := buckets.saveSnapshot(path)
+		if err != nil {
+			fs.Errorf(nil, "memory: %v", err)
+		}
+	})
+	return nil
+}
+
+// loadSnapshot adds the objects in the snapshot at path
+func (bi *bucketsInfo) loadSnapshot(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to load snapshot")
+	}
+	defer fs.CheckClose(in, &err)
+	var s snapshot
+	err = gob.NewDecoder(bufio.NewReader(in)).Decode(&s)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load snapshot %q", path)
+	}
+	for bucketName, objects := range s.Buckets {
+		b := bi.makeBucket(bucketName)
+		for bucketPath, o := range objects {
+			b.mu.Lock()
+			_ = bi.addSize(b.objects[bucketPath], int64(len(o.Data)), -1)
+			b.objects[bucketPath] = &objectData{
+				modTime:  o.ModTime,
+				hash:     o.Hash,
+				mimeType: o.MimeType,
+				data:     o.Data,
+			}
+			b.mu.Unlock()
+		}
+	}
+	fs.Debugf(nil, "memory: loaded snapshot %q", path)
+	return nil
+}
+
+// saveSnapshot saves all the objects to the snapshot at path
+//
+// It is written to a temporary file first so a snapshot is never left
+// half written.
+func (bi *bucketsInfo) saveSnapshot(path string) (err error) {
+	s := snapshot{Buckets: map[string]map[string]snapshotObject{}}
+	bi.mu.RLock()
+	for bucketName, b := range bi.buckets {
+		objects := map[string]snapshotObject{}
+		b.mu.RLock()
+		for bucketPath, od := range b.objects {
+			objects[bucketPath] = snapshotObject{
+				ModTime:  od.modTime,
+				Hash:     od.hash,
+				MimeType: od.mimeType,
+				Data:     od.data,
+			}
+		}
+		b.mu.RUnlock()
+		s.Buckets[bucketName] = objects
+	}
+	bi.mu.RUnlock()
+	out, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to save snapshot")
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(out.Name())
+		}
+	}()
+	w := bufio.NewWriter(out)
+	err = gob.NewEncoder(w).Encode(&s)
+	if err == nil {
+		err = w.Flush()
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), path)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save snapshot %q", path)
+	}
+	fs.Debugf(nil, "memory: saved snapshot %q", path)
+	return nil
+}
@@ -43,6 +43,7 @@ var _ error = (*Error)(nil)
 type Identity struct {
 	DisplayName string `json:"displayName"`
 	ID          string `json:"id"`
+	Email       string `json:"email,omitempty"` // Only returned for some identities, eg in permissions
 }
 
 // IdentitySet is a keyed collection of Identity objects. It is used
@@ -272,6 +273,50 @@ type CreateShareLinkResponse struct {
 	} `json:"link"`
 }
 
+// SharingLink is the sharing link of a Permission
+type SharingLink struct {
+	Type   string `json:"type"`   // The type of the link: view, edit or embed
+	Scope  string `json:"scope"`  // Who the link is for: anonymous, organization or users
+	WebURL string `json:"webUrl"` // A URL that opens the item in the browser
+}
+
+// SharingInvitation is the invitation of a Permission
+type SharingInvitation struct {
+	Email          string `json:"email"`          // The email address of the recipient of the invitation
+	SignInRequired bool   `json:"signInRequired"` // If true the recipient needs to sign in to access the item
+}
+
+// Permission is a sharing permission granted on an item
+type Permission struct {
+	ID                    string             `json:"id"`                    // The unique identifier of the permission
+	Roles                 []string           `json:"roles"`                 // The roles granted: read, write or owner
+	Link                  *SharingLink       `json:"link"`                  // Set if the permission is a sharing link
+	GrantedTo             *IdentitySet       `json:"grantedTo"`             // The user the permission was granted to
+	GrantedToV2           *IdentitySet       `json:"grantedToV2"`           // The user the permission was granted to - newer version
+	GrantedToIdentities   []IdentitySet      `json:"grantedToIdentities"`   // The users a sharing link was granted to
+	GrantedToIdentitiesV2 []IdentitySet      `json:"grantedToIdentitiesV2"` // The users a sharing link was granted to - newer version
+	Invitation            *SharingInvitation `json:"invitation"`            // Set if the permission is an invitation
+	InheritedFrom         *ItemReference     `json:"inheritedFrom"`         // Set if the permission is inherited from an ancestor
+}
+
+// PermissionsResponse is the response to the list permissions method
+type PermissionsResponse struct {
+	Value []Permission `json:"value"` // An array of Permission objects
+}
+
+// DriveRecipient is a recipient of an InviteRequest
+type DriveRecipient struct {
+	Email string `json:"email"` // The email address of the recipient
+}
+
+// InviteRequest is the request to grant users access to an item
+type InviteRequest struct {
+	Recipients     []DriveRecipient `json:"recipients"`     // The users to grant access to
+	Roles          []string         `json:"roles"`          // The roles to grant: read or write
+	RequireSignIn  bool             `json:"requireSignIn"`  // Whether the recipients must sign in to access the item
+	SendInvitation bool             `json:"sendInvitation"` // Whether to email the recipients
+}
+
 // AsyncOperationStatus provides information on the status of an asynchronous job progress.
 //
 // The following API calls return AsyncOperationStatus resources:
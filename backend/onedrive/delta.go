@@ -0,0 +1,391 @@
+// Listing and change detection with the delta API
+
+package onedrive
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/onedrive/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/rest"
+)
+
+// deltaCache is a listing of every item in the drive which is kept
+// between runs and brought up to date with the delta API
+type deltaCache struct {
+	DeltaLink string               `json:"deltaLink"` // link to read the changes since the listing
+	Items     map[string]*api.Item `json:"items"`     // items as returned by OneDrive indexed by ID
+}
+
+// errResyncRequired is returned by readDelta if the delta link can't
+// be used any more
+var errResyncRequired = errors.New("delta link expired - resync required")
+
+// parentID returns the normalized ID of the parent of item or "" if
+// it doesn't have one
+func parentID(item *api.Item) string {
+	parent := item.GetParentReference()
+	if parent == nil || parent.ID == "" {
+		return ""
+	}
+	return parent.DriveID + "#" + parent.ID
+}
+
+// readDelta reads the changes from link, calling fn for each, and
+// returns the delta link to read the changes after these. If link is
+// "" it reads every item in the drive.
+func (f *Fs) readDelta(ctx context.Context, link string, fn func(item *api.Item)) (deltaLink string, err error) {
+	opts := rest.Opts{
+		Method: "GET",
+		Path:   "/root/delta",
+	}
+	if link != "" {
+		opts.Path = ""
+		opts.RootURL = link
+	}
+	for {
+		var result api.ViewDeltaResponse
+		var resp *http.Response
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err = f.srv.CallJSON(ctx, &opts, nil, &result)
+			return shouldRetry(resp, err)
+		})
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusGone {
+				return "", errResyncRequired
+			}
+			return "", errors.Wrap(err, "couldn't read delta")
+		}
+		for i := range result.Value {
+			fn(&result.Value[i])
+		}
+		switch {
+		case result.NextLink != "":
+			opts.Path = ""
+			opts.RootURL = result.NextLink
+		case result.DeltaLink != "":
+			return result.DeltaLink, nil
+		default:
+			return "", errors.New("no link returned when reading delta")
+		}
+	}
+}
+
+// latestDeltaLink returns a delta link for the changes made from now
+// on without reading the items in the drive
+func (f *Fs) latestDeltaLink(ctx context.Context) (deltaLink string, err error) {
+	opts := rest.Opts{
+		Method:     "GET",
+		Path:       "/root/delta",
+		Parameters: map[string][]string{"token": {"latest"}},
+	}
+	var result api.ViewDeltaResponse
+	var resp *http.Response
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.srv.CallJSON(ctx, &opts, nil, &result)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't read latest delta link")
+	}
+	if result.DeltaLink == "" {
+		return "", errors.New("no delta link returned")
+	}
+	return result.DeltaLink, nil
+}
+
+// deltaCachePath returns the file the delta cache is kept in
+func (f *Fs) deltaCachePath() string {
+	sum := md5.Sum([]byte(f.name + ":" + f.driveID))
+	return filepath.Join(config.CacheDir, "onedrive-delta", hex.EncodeToString(sum[:])+".json")
+}
+
+// readDeltaCache reads the delta cache in path returning nil if there
+// isn't one
+func readDeltaCache(path string) (*deltaCache, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var c deltaCache
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse delta cache %q", path)
+	}
+	if c.DeltaLink == "" {
+		return nil, errors.Errorf("no delta link in delta cache %q", path)
+	}
+	if c.Items == nil {
+		c.Items = map[string]*api.Item{}
+	}
+	return &c, nil
+}
+
+// write saves the delta cache to path
+func (c *deltaCache) write(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make delta cache directory")
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write delta cache")
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// apply updates the cache with item read from the delta API
+func (c *deltaCache) apply(item *api.Item) {
+	if item.Deleted != nil {
+		delete(c.Items, item.GetID())
+		return
+	}
+	c.Items[item.GetID()] = item
+}
+
+// updateDeltaCache reads the delta cache if necessary and brings it
+// up to date, reading every item in the drive if there isn't one.
+//
+// Call with deltaMu held.
+func (f *Fs) updateDeltaCache(ctx context.Context) (err error) {
+	cachePath := f.deltaCachePath()
+	if f.delta == nil {
+		f.delta, err = readDeltaCache(cachePath)
+		if err != nil {
+			fs.Errorf(f, "Ignoring delta cache: %v", err)
+		}
+	}
+	if c := f.delta; c != nil {
+		changed := 0
+		deltaLink, err := f.readDelta(ctx, c.DeltaLink, func(item *api.Item) {
+			changed++
+			c.apply(item)
+		})
+		if err == nil {
+			fs.Debugf(f, "Applied %d changes to delta cache", changed)
+			if deltaLink == c.DeltaLink {
+				return nil
+			}
+			c.DeltaLink = deltaLink
+			return c.write(cachePath)
+		}
+		if err != errResyncRequired {
+			return err
+		}
+		fs.Logf(f, "Reading every item in the drive again as %v", err)
+		f.delta = nil
+	}
+
+	c := &deltaCache{
+		Items: map[string]*api.Item{},
+	}
+	fs.Infof(f, "Building delta cache - this reads every item in the drive so may take some time")
+	c.DeltaLink, err = f.readDelta(ctx, "", c.apply)
+	if err != nil {
+		return err
+	}
+	fs.Debugf(f, "Read %d items into delta cache", len(c.Items))
+	f.delta = c
+	return c.write(cachePath)
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// This is only used if the delta option is set.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	directoryID, err := f.dirCache.FindDir(ctx, dir, false)
+	if err != nil {
+		return err
+	}
+	f.deltaMu.Lock()
+	defer f.deltaMu.Unlock()
+	err = f.updateDeltaCache(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to update delta cache")
+	}
+	c := f.delta
+
+	children := make(map[string][]*api.Item)
+	for _, item := range c.Items {
+		if id := parentID(item); id != "" {
+			children[id] = append(children[id], item)
+		}
+	}
+
+	type listJob struct {
+		id   string
+		path string
+	}
+	jobs := []listJob{{id: directoryID, path: dir}}
+	list := walk.NewListRHelper(callback)
+	for len(jobs) > 0 {
+		job := jobs[0]
+		jobs = jobs[1:]
+		var items []*api.Item
+		if item, found := c.Items[job.id]; found && !item.IsRemote() {
+			for _, item := range children[job.id] {
+				newItem := *item
+				newItem.Name = f.opt.Enc.ToStandardName(item.GetName())
+				items = append(items, &newItem)
+			}
+		} else {
+			// The folder isn't in the delta listing, eg a folder
+			// shared with us, so list it directly
+			_, err := f.listAll(ctx, job.id, false, false, func(item *api.Item) bool {
+				items = append(items, item)
+				return false
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range items {
+			remote := path.Join(job.path, item.GetName())
+			entry, err := f.itemToDirEntry(ctx, remote, item)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				continue
+			}
+			if d, isDir := entry.(fs.Directory); isDir {
+				jobs = append(jobs, listJob{id: d.ID(), path: remote})
+			}
+			err = list.Add(entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return list.Flush()
+}
+
+// ChangeNotify calls the passed function with a path that has had changes.
+// If the implementation uses polling, it should adhere to the given interval.
+//
+// Automatically restarts itself in case of unexpected behavior of the remote.
+//
+// Close the returned channel to stop being notified.
+func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration) {
+	go func() {
+		// get the delta link early so all changes from now on get processed
+		deltaLink, err := f.latestDeltaLink(ctx)
+		if err != nil {
+			fs.Infof(f, "Failed to get delta link: %s", err)
+		}
+		var ticker *time.Ticker
+		var tickerC <-chan time.Time
+		for {
+			select {
+			case pollInterval, ok := <-pollIntervalChan:
+				if !ok {
+					if ticker != nil {
+						ticker.Stop()
+					}
+					return
+				}
+				if ticker != nil {
+					ticker.Stop()
+					ticker, tickerC = nil, nil
+				}
+				if pollInterval != 0 {
+					ticker = time.NewTicker(pollInterval)
+					tickerC = ticker.C
+				}
+			case <-tickerC:
+				if deltaLink == "" {
+					deltaLink, err = f.latestDeltaLink(ctx)
+					if err != nil {
+						fs.Infof(f, "Failed to get delta link: %s", err)
+						continue
+					}
+				}
+				fs.Debugf(f, "Checking for changes on remote")
+				deltaLink, err = f.changeNotifyRunner(ctx, notifyFunc, deltaLink)
+				if err != nil {
+					fs.Infof(f, "Change notify listener failure: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// changeNotifyRunner reads the changes from deltaLink and calls
+// notifyFunc with the paths of the ones it can find. It returns the
+// delta link to use next time, or "" if the delta link needs fetching
+// again.
+func (f *Fs) changeNotifyRunner(ctx context.Context, notifyFunc func(string, fs.EntryType), deltaLink string) (newDeltaLink string, err error) {
+	type entryType struct {
+		path      string
+		entryType fs.EntryType
+	}
+	var pathsToClear []entryType
+	newDeltaLink, err = f.readDelta(ctx, deltaLink, func(item *api.Item) {
+		changeType := fs.EntryObject
+		if item.GetFolder() != nil {
+			changeType = fs.EntryDirectory
+		}
+		// find the previous path if it is a known directory
+		if oldPath, ok := f.dirCache.GetInv(item.GetID()); ok {
+			pathsToClear = append(pathsToClear, entryType{path: oldPath, entryType: fs.EntryDirectory})
+		}
+		// find the new path from the parent
+		name := item.GetName()
+		if name == "" {
+			return
+		}
+		if parentPath, ok := f.dirCache.GetInv(parentID(item)); ok {
+			newPath := path.Join(parentPath, f.opt.Enc.ToStandardName(name))
+			pathsToClear = append(pathsToClear, entryType{path: newPath, entryType: changeType})
+		}
+	})
+	if err == errResyncRequired {
+		// Everything could have changed so clear the root
+		notifyFunc("", fs.EntryDirectory)
+		return "", err
+	}
+	if err != nil {
+		return deltaLink, err
+	}
+
+	visitedPaths := make(map[string]struct{})
+	for _, entry := range pathsToClear {
+		if _, ok := visitedPaths[entry.path]; ok {
+			continue
+		}
+		visitedPaths[entry.path] = struct{}{}
+		notifyFunc(entry.path, entry.entryType)
+	}
+	return newDeltaLink, nil
+}
@@ -0,0 +1,51 @@
+package onedrive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/backend/onedrive/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParentID(t *testing.T) {
+	assert.Equal(t, "", parentID(&api.Item{}))
+	assert.Equal(t, "", parentID(&api.Item{ParentReference: &api.ItemReference{DriveID: "drive"}}))
+	assert.Equal(t, "drive#parent", parentID(&api.Item{ParentReference: &api.ItemReference{DriveID: "drive", ID: "parent"}}))
+}
+
+func TestDeltaCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-onedrive-delta")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	cachePath := filepath.Join(dir, "sub", "delta.json")
+
+	c, err := readDeltaCache(cachePath)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c = &deltaCache{
+		DeltaLink: "https://example.com/delta?token=1",
+		Items:     map[string]*api.Item{},
+	}
+	parent := &api.ItemReference{DriveID: "drive", ID: "root"}
+	c.apply(&api.Item{ID: "file1", Name: "file1", Size: 1, ParentReference: parent})
+	c.apply(&api.Item{ID: "file2", Name: "file2", Size: 2, ParentReference: parent})
+	c.apply(&api.Item{ID: "file1", Name: "renamed", Size: 1, ParentReference: parent})
+	c.apply(&api.Item{ID: "file2", ParentReference: parent, Deleted: &api.DeletedFacet{}})
+	require.Len(t, c.Items, 1)
+	assert.Equal(t, "renamed", c.Items["drive#file1"].Name)
+
+	require.NoError(t, c.write(cachePath))
+	got, err := readDeltaCache(cachePath)
+	require.NoError(t, err)
+	assert.Equal(t, c.DeltaLink, got.DeltaLink)
+	require.Len(t, got.Items, 1)
+	assert.Equal(t, "renamed", got.Items["drive#file1"].Name)
+	assert.Equal(t, "drive#root", parentID(got.Items["drive#file1"]))
+}
@@ -0,0 +1,239 @@
+// Metadata and sharing permissions for OneDrive objects
+
+package onedrive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/onedrive/api"
+	"github.com/rclone/rclone/fs"
+)
+
+// metadataPermissions is the metadata key the sharing permissions are
+// stored in as JSON
+const metadataPermissions = "permissions"
+
+// permission is a sharing permission as stored in the metadata
+type permission struct {
+	Roles []string `json:"roles"`           // the roles granted, eg read or write
+	Link  string   `json:"link,omitempty"`  // type:scope of a sharing link, eg "view:anonymous"
+	URL   string   `json:"url,omitempty"`   // URL of a sharing link - ignored when writing
+	Users []string `json:"users,omitempty"` // email addresses, or IDs if not known, of the users granted access
+}
+
+// key returns a string identifying what p grants
+func (p *permission) key() string {
+	roles := append([]string(nil), p.Roles...)
+	sort.Strings(roles)
+	users := append([]string(nil), p.Users...)
+	sort.Strings(users)
+	return strings.Join(roles, ",") + "|" + p.Link + "|" + strings.ToLower(strings.Join(users, ","))
+}
+
+// parseMetadataPermissions checks the metadata_permissions option
+// returning whether permissions should be read and written
+func parseMetadataPermissions(text string) (read, write bool, err error) {
+	for _, mode := range strings.Split(text, ",") {
+		switch strings.ToLower(strings.TrimSpace(mode)) {
+		case "", "off":
+		case "read":
+			read = true
+		case "write":
+			write = true
+		default:
+			return false, false, errors.Errorf("unknown metadata_permissions %q - expecting off, read, write or read,write", mode)
+		}
+	}
+	return read, write, nil
+}
+
+// newPermission converts a permission returned by OneDrive, returning
+// nil for those which shouldn't be in the metadata
+func newPermission(in *api.Permission) *permission {
+	// Inherited permissions belong to the parent and the owner
+	// can't be changed
+	if in.InheritedFrom != nil {
+		return nil
+	}
+	for _, role := range in.Roles {
+		if role == "owner" {
+			return nil
+		}
+	}
+	p := &permission{
+		Roles: in.Roles,
+	}
+	if in.Link != nil {
+		p.Link = in.Link.Type + ":" + in.Link.Scope
+		p.URL = in.Link.WebURL
+	}
+	seen := map[string]bool{}
+	addUser := func(identity api.Identity) {
+		user := identity.Email
+		if user == "" {
+			user = identity.ID
+		}
+		if user != "" && !seen[strings.ToLower(user)] {
+			seen[strings.ToLower(user)] = true
+			p.Users = append(p.Users, user)
+		}
+	}
+	for _, identities := range [][]api.IdentitySet{in.GrantedToIdentitiesV2, in.GrantedToIdentities} {
+		for _, identity := range identities {
+			addUser(identity.User)
+		}
+	}
+	for _, identity := range []*api.IdentitySet{in.GrantedToV2, in.GrantedTo} {
+		if identity != nil {
+			addUser(identity.User)
+		}
+	}
+	if in.Invitation != nil && in.Invitation.Email != "" {
+		addUser(api.Identity{Email: in.Invitation.Email})
+	}
+	return p
+}
+
+// readPermissions reads the sharing permissions of the item with ID id
+func (f *Fs) readPermissions(ctx context.Context, id string) (permissions []*permission, err error) {
+	opts := newOptsCall(id, "GET", "/permissions")
+	var result api.PermissionsResponse
+	var resp *http.Response
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.srv.CallJSON(ctx, &opts, nil, &result)
+		return shouldRetry(resp, err)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read permissions")
+	}
+	for i := range result.Value {
+		if p := newPermission(&result.Value[i]); p != nil {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions, nil
+}
+
+// writePermissions grants the sharing permissions on the item with ID
+// id which it doesn't have already. Existing permissions aren't
+// removed.
+func (f *Fs) writePermissions(ctx context.Context, id string, permissions []*permission) error {
+	existing, err := f.readPermissions(ctx, id)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, p := range existing {
+		have[p.key()] = true
+	}
+	for _, p := range permissions {
+		if have[p.key()] {
+			continue
+		}
+		linkType, linkScope := p.Link, ""
+		if i := strings.IndexRune(p.Link, ':'); i >= 0 {
+			linkType, linkScope = p.Link[:i], p.Link[i+1:]
+		}
+		// Links for specific users are made by inviting them
+		if p.Link != "" && linkScope != "users" {
+			opts := newOptsCall(id, "POST", "/createLink")
+			share := api.CreateShareLinkRequest{
+				Type:  linkType,
+				Scope: linkScope,
+			}
+			var result api.CreateShareLinkResponse
+			err = f.pacer.Call(func() (bool, error) {
+				resp, err := f.srv.CallJSON(ctx, &opts, &share, &result)
+				return shouldRetry(resp, err)
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to create %s link", p.Link)
+			}
+			continue
+		}
+		invite := api.InviteRequest{
+			Roles:         p.Roles,
+			RequireSignIn: true,
+		}
+		for _, user := range p.Users {
+			if !strings.Contains(user, "@") {
+				fs.Debugf(f, "Can't grant permission to user %q without an email address", user)
+				continue
+			}
+			invite.Recipients = append(invite.Recipients, api.DriveRecipient{Email: user})
+		}
+		if len(invite.Recipients) == 0 {
+			continue
+		}
+		opts := newOptsCall(id, "POST", "/invite")
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.CallJSON(ctx, &opts, &invite, nil)
+			return shouldRetry(resp, err)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to grant %v to %v", p.Roles, p.Users)
+		}
+	}
+	return nil
+}
+
+// Metadata returns the metadata of the object, including the sharing
+// permissions if metadata_permissions includes read
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(fs.Metadata, 3)
+	metadata.SetTime(fs.MetadataMtime, o.modTime)
+	if o.mimeType != "" {
+		metadata[fs.MetadataContentType] = o.mimeType
+	}
+	if o.fs.readPerms {
+		permissions, err := o.fs.readPermissions(ctx, o.id)
+		if err != nil {
+			return nil, err
+		}
+		if len(permissions) > 0 {
+			data, err := json.Marshal(permissions)
+			if err != nil {
+				return nil, err
+			}
+			metadata[metadataPermissions] = string(data)
+		}
+	}
+	return metadata, nil
+}
+
+// SetMetadata sets the modification time and, if metadata_permissions
+// includes write, grants the sharing permissions in the metadata. The
+// other keys are ignored.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return err
+	}
+	if modTime, ok := metadata.Time(fs.MetadataMtime); ok && !modTime.Equal(o.modTime) {
+		err = o.SetModTime(ctx, modTime)
+		if err != nil {
+			return err
+		}
+	}
+	if value, ok := metadata[metadataPermissions]; ok && o.fs.writePerms {
+		var permissions []*permission
+		err = json.Unmarshal([]byte(value), &permissions)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse permissions metadata")
+		}
+		err = o.fs.writePermissions(ctx, o.id, permissions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
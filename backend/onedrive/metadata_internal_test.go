@@ -0,0 +1,66 @@
+package onedrive
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rclone/rclone/backend/onedrive/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadataPermissions(t *testing.T) {
+	for _, test := range []struct {
+		in        string
+		wantRead  bool
+		wantWrite bool
+		wantErr   bool
+	}{
+		{"", false, false, false},
+		{"off", false, false, false},
+		{"read", true, false, false},
+		{"write", false, true, false},
+		{"Read, Write", true, true, false},
+		{"potato", false, false, true},
+	} {
+		read, write, err := parseMetadataPermissions(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.wantRead, read, test.in)
+		assert.Equal(t, test.wantWrite, write, test.in)
+	}
+}
+
+func TestNewPermission(t *testing.T) {
+	const permissionsJSON = `[
+	{"id": "1", "roles": ["owner"], "grantedTo": {"user": {"displayName": "Owner", "id": "owner-id"}}},
+	{"id": "2", "roles": ["read"], "link": {"type": "view", "scope": "anonymous", "webUrl": "https://1drv.ms/x"}},
+	{"id": "3", "roles": ["write"], "grantedToV2": {"user": {"displayName": "A", "id": "a-id", "email": "a@example.com"}}, "grantedTo": {"user": {"displayName": "A", "id": "a-id", "email": "A@example.com"}}},
+	{"id": "4", "roles": ["read"], "invitation": {"email": "b@example.com"}},
+	{"id": "5", "roles": ["read"], "inheritedFrom": {"id": "parent"}},
+	{"id": "6", "roles": ["write"], "link": {"type": "edit", "scope": "users"}, "grantedToIdentitiesV2": [{"user": {"id": "c-id"}}, {"user": {"email": "d@example.com"}}]}
+]`
+	var in []api.Permission
+	require.NoError(t, json.Unmarshal([]byte(permissionsJSON), &in))
+	var got []*permission
+	for i := range in {
+		if p := newPermission(&in[i]); p != nil {
+			got = append(got, p)
+		}
+	}
+	assert.Equal(t, []*permission{
+		{Roles: []string{"read"}, Link: "view:anonymous", URL: "https://1drv.ms/x"},
+		{Roles: []string{"write"}, Users: []string{"a@example.com"}},
+		{Roles: []string{"read"}, Users: []string{"b@example.com"}},
+		{Roles: []string{"write"}, Link: "edit:users", Users: []string{"c-id", "d@example.com"}},
+	}, got)
+
+	// The key ignores the order, case and URL
+	a := &permission{Roles: []string{"read", "write"}, Users: []string{"b@example.com", "A@example.com"}, URL: "x"}
+	b := &permission{Roles: []string{"write", "read"}, Users: []string{"a@example.com", "b@example.com"}}
+	assert.Equal(t, a.key(), b.key())
+	assert.NotEqual(t, a.key(), (&permission{Roles: []string{"read"}}).key())
+}
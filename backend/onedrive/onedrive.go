@@ -3,6 +3,7 @@
 package onedrive
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/hex"
@@ -34,8 +35,8 @@ import (
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/oauthutil"
 	"github.com/rclone/rclone/lib/pacer"
-	"github.com/rclone/rclone/lib/readers"
 	"github.com/rclone/rclone/lib/rest"
+	"github.com/rclone/rclone/lib/resume"
 	"golang.org/x/oauth2"
 )
 
@@ -298,6 +299,46 @@ modification time and removes all but the last version.
 this flag there.
 `,
 			Advanced: true,
+		}, {
+			Name:    "delta",
+			Default: false,
+			Help: `Use the delta API for recursive listings, keeping the listing between runs
+
+If this is set then the first recursive listing (eg with --fast-list)
+reads every item in the drive with the delta API and keeps the listing
+in rclone's cache directory. Later recursive listings only read the
+changes made since, which is much quicker and less likely to be
+throttled on large drives.
+
+Items shared with you and added to your drive aren't in the delta
+listing so are listed directory by directory as normal.`,
+			Advanced: true,
+		}, {
+			Name:    "metadata_permissions",
+			Default: "off",
+			Help: `Read and write the sharing permissions of files as metadata
+
+If this includes "read" then the sharing links and the users files
+are shared with are read into the "permissions" metadata key as JSON.
+This needs an extra API call per file so is off by default.
+
+If this includes "write" then when the metadata is copied with
+--metadata the permissions in it are granted on the destination.
+Permissions the destination has already aren't removed.`,
+			Examples: []fs.OptionExample{{
+				Value: "off",
+				Help:  "Don't read or write permissions",
+			}, {
+				Value: "read",
+				Help:  "Read permissions into the metadata",
+			}, {
+				Value: "write",
+				Help:  "Write permissions from the metadata",
+			}, {
+				Value: "read,write",
+				Help:  "Read and write permissions",
+			}},
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -356,6 +397,8 @@ type Options struct {
 	ExposeOneNoteFiles      bool                 `config:"expose_onenote_files"`
 	ServerSideAcrossConfigs bool                 `config:"server_side_across_configs"`
 	NoVersions              bool                 `config:"no_versions"`
+	Delta                   bool                 `config:"delta"`
+	MetadataPermissions     string               `config:"metadata_permissions"`
 	Enc                     encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -371,6 +414,10 @@ type Fs struct {
 	tokenRenewer *oauthutil.Renew   // renew the token on expiry
 	driveID      string             // ID to use for querying Microsoft Graph
 	driveType    string             // https://developer.microsoft.com/en-us/graph/docs/api-reference/v1.0/resources/drive
+	deltaMu      *sync.Mutex        // protects delta
+	delta        *deltaCache        // listing kept up to date with the delta API if in use
+	readPerms    bool               // read sharing permissions into the metadata
+	writePerms   bool               // write sharing permissions from the metadata
 }
 
 // Object describes a one drive object
@@ -604,6 +651,11 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		return nil, errors.Wrap(err, "onedrive: chunk size")
 	}
 
+	readPerms, writePerms, err := parseMetadataPermissions(opt.MetadataPermissions)
+	if err != nil {
+		return nil, errors.Wrap(err, "onedrive")
+	}
+
 	if opt.DriveID == "" || opt.DriveType == "" {
 		return nil, errors.New("unable to get drive_id and drive_type - if you are upgrading from older versions of rclone, please run `rclone config` and re-configure this backend")
 	}
@@ -615,13 +667,16 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	}
 
 	f := &Fs{
-		name:      name,
-		root:      root,
-		opt:       *opt,
-		driveID:   opt.DriveID,
-		driveType: opt.DriveType,
-		srv:       rest.NewClient(oAuthClient).SetRoot(graphURL + "/drives/" + opt.DriveID),
-		pacer:     fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+		name:       name,
+		root:       root,
+		opt:        *opt,
+		driveID:    opt.DriveID,
+		driveType:  opt.DriveType,
+		srv:        rest.NewClient(oAuthClient).SetRoot(graphURL + "/drives/" + opt.DriveID),
+		pacer:      fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+		deltaMu:    new(sync.Mutex),
+		readPerms:  readPerms,
+		writePerms: writePerms,
 	}
 	f.features = (&fs.Features{
 		CaseInsensitive:         true,
@@ -629,6 +684,10 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		CanHaveEmptyDirectories: true,
 		ServerSideAcrossConfigs: opt.ServerSideAcrossConfigs,
 	}).Fill(f)
+	if !opt.Delta {
+		// Only use ListR with the delta API
+		f.features.ListR = nil
+	}
 	f.srv.SetErrorHandler(errorHandler)
 
 	// Renew the token in the background
@@ -834,27 +893,13 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	}
 	var iErr error
 	_, err = f.listAll(ctx, directoryID, false, false, func(info *api.Item) bool {
-		if !f.opt.ExposeOneNoteFiles && info.GetPackageType() == api.PackageTypeOneNote {
-			fs.Debugf(info.Name, "OneNote file not shown in directory listing")
-			return false
+		entry, err := f.itemToDirEntry(ctx, path.Join(dir, info.GetName()), info)
+		if err != nil {
+			iErr = err
+			return true
 		}
-
-		remote := path.Join(dir, info.GetName())
-		folder := info.GetFolder()
-		if folder != nil {
-			// cache the directory ID for later lookups
-			id := info.GetID()
-			f.dirCache.Put(remote, id)
-			d := fs.NewDir(remote, time.Time(info.GetLastModifiedDateTime())).SetID(id)
-			d.SetItems(folder.ChildCount)
-			entries = append(entries, d)
-		} else {
-			o, err := f.newObjectWithInfo(ctx, remote, info)
-			if err != nil {
-				iErr = err
-				return true
-			}
-			entries = append(entries, o)
+		if entry != nil {
+			entries = append(entries, entry)
 		}
 		return false
 	})
@@ -867,6 +912,25 @@ func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err e
 	return entries, nil
 }
 
+// itemToDirEntry converts an api.Item to an fs.DirEntry. It returns
+// (nil, nil) if the item shouldn't be shown.
+func (f *Fs) itemToDirEntry(ctx context.Context, remote string, info *api.Item) (fs.DirEntry, error) {
+	if !f.opt.ExposeOneNoteFiles && info.GetPackageType() == api.PackageTypeOneNote {
+		fs.Debugf(info.Name, "OneNote file not shown in directory listing")
+		return nil, nil
+	}
+	folder := info.GetFolder()
+	if folder != nil {
+		// cache the directory ID for later lookups
+		id := info.GetID()
+		f.dirCache.Put(remote, id)
+		d := fs.NewDir(remote, time.Time(info.GetLastModifiedDateTime())).SetID(id)
+		d.SetItems(folder.ChildCount)
+		return d, nil
+	}
+	return f.newObjectWithInfo(ctx, remote, info)
+}
+
 // Creates from the parameters passed in a half finished Object which
 // must have setMetaData called on it
 //
@@ -1711,21 +1775,57 @@ func (o *Object) cancelUploadSession(ctx context.Context, url string) (err error
 }
 
 // uploadMultipart uploads a file using multipart upload
-func (o *Object) uploadMultipart(ctx context.Context, in io.Reader, size int64, modTime time.Time, options ...fs.OpenOption) (info *api.Item, err error) {
+func (o *Object) uploadMultipart(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (info *api.Item, err error) {
+	size := src.Size()
+	modTime := src.ModTime(ctx)
 	if size <= 0 {
 		return nil, errors.New("unknown-sized upload not supported")
 	}
+	chunkSize := int64(o.fs.opt.ChunkSize)
+	buf := make([]byte, chunkSize)
+
+	// See if we can resume a previous upload
+	var (
+		uploadURL string
+		position  int64
+		resumer   = resume.New(ctx, o.fs, o.remote, src)
+	)
+	if resumeURL, ok := resumer.Resume(chunkSize); ok {
+		position, err = o.getPosition(ctx, resumeURL)
+		if err == nil {
+			err = resumer.Skip(in, position, buf)
+			if err != nil {
+				// the source has been read so we must retry from the start
+				resumer.Done()
+				return nil, fserrors.RetryError(err)
+			}
+			fs.Debugf(o, "Resuming multipart upload at offset %d", position)
+			uploadURL = resumeURL
+		} else {
+			fs.Debugf(o, "Can't resume multipart upload - starting again: %v", err)
+			resumer.Done()
+			position = 0
+		}
+	}
 
-	// Create upload session
-	fs.Debugf(o, "Starting multipart upload")
-	session, err := o.createUploadSession(ctx, modTime)
-	if err != nil {
-		return nil, err
+	if uploadURL == "" {
+		// Create upload session
+		fs.Debugf(o, "Starting multipart upload")
+		var session *api.CreateUploadResponse
+		session, err = o.createUploadSession(ctx, modTime)
+		if err != nil {
+			return nil, err
+		}
+		uploadURL = session.UploadURL
+		resumer.Start(uploadURL, chunkSize)
 	}
-	uploadURL := session.UploadURL
 
 	// Cancel the session if something went wrong
 	defer atexit.OnError(&err, func() {
+		// Leave the session for the next attempt if resuming
+		if resumer.Keep(err, false) {
+			return
+		}
 		fs.Debugf(o, "Cancelling multipart upload: %v", err)
 		cancelErr := o.cancelUploadSession(ctx, uploadURL)
 		if cancelErr != nil {
@@ -1734,23 +1834,28 @@ func (o *Object) uploadMultipart(ctx context.Context, in io.Reader, size int64,
 	})()
 
 	// Upload the chunks
-	remaining := size
-	position := int64(0)
+	remaining := size - position
 	for remaining > 0 {
-		n := int64(o.fs.opt.ChunkSize)
+		n := chunkSize
 		if remaining < n {
 			n = remaining
 		}
-		seg := readers.NewRepeatableReader(io.LimitReader(in, n))
+		_, err = io.ReadFull(in, buf[:n])
+		if err != nil {
+			return nil, errors.Wrap(err, "multipart upload failed to read source")
+		}
+		seg := bytes.NewReader(buf[:n])
 		fs.Debugf(o, "Uploading segment %d/%d size %d", position, size, n)
 		info, err = o.uploadFragment(ctx, uploadURL, position, size, seg, n, options...)
 		if err != nil {
 			return nil, err
 		}
+		resumer.AddPart(position/chunkSize+1, position, buf[:n], "")
 		remaining -= n
 		position += n
 	}
 
+	resumer.Done()
 	return info, nil
 }
 
@@ -1823,7 +1928,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 
 	var info *api.Item
 	if size > 0 {
-		info, err = o.uploadMultipart(ctx, in, size, modTime, options...)
+		info, err = o.uploadMultipart(ctx, in, src, options...)
 	} else if size == 0 {
 		info, err = o.uploadSinglepart(ctx, in, size, modTime, options...)
 	} else {
@@ -1938,7 +2043,11 @@ var (
 	_ fs.Abouter         = (*Fs)(nil)
 	_ fs.PublicLinker    = (*Fs)(nil)
 	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.ChangeNotifier  = (*Fs)(nil)
 	_ fs.Object          = (*Object)(nil)
 	_ fs.MimeTyper       = &Object{}
 	_ fs.IDer            = &Object{}
+	_ fs.Metadataer      = &Object{}
+	_ fs.SetMetadataer   = &Object{}
 )
@@ -0,0 +1,330 @@
+// Checksums and the delta encoding of files sent to the daemon
+
+package rsyncd
+
+import (
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/md4"
+)
+
+// readSize is how much data is read from files at once
+const readSize = 64 * 1024
+
+// sumHead describes the block checksums of the basis file the daemon
+// has for a file
+type sumHead struct {
+	count       int32 // number of blocks
+	blockLength int32 // length of the blocks
+	sum2Length  int32 // number of bytes of the strong checksums sent
+	remainder   int32 // length of the last block if shorter than blockLength
+}
+
+// blockLen returns the length of block i
+func (head *sumHead) blockLen(i int32) int {
+	if i == head.count-1 && head.remainder != 0 {
+		return int(head.remainder)
+	}
+	return int(head.blockLength)
+}
+
+// blockSum is the checksums of a block of the basis file
+type blockSum struct {
+	weak   uint32
+	strong []byte
+}
+
+// weakSum returns the two halves of rsync's rolling checksum of data
+//
+// rsync sums the bytes as signed chars so they are sign extended.
+func weakSum(data []byte) (s1, s2 uint32) {
+	n := uint32(len(data))
+	for i, b := range data {
+		x := uint32(int8(b))
+		s1 += x
+		s2 += (n - uint32(i)) * x
+	}
+	return s1, s2
+}
+
+// packWeakSum makes the weak checksum sent from its halves
+func packWeakSum(s1, s2 uint32) uint32 {
+	return s1&0xFFFF | s2<<16
+}
+
+// strongSum returns the MD4 of a block with the seed appended if it
+// isn't 0
+func strongSum(data []byte, seed int32) []byte {
+	h := md4.New()
+	_, _ = h.Write(data)
+	if seed != 0 {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(seed))
+		_, _ = h.Write(b[:])
+	}
+	return h.Sum(nil)
+}
+
+// newFileSum returns the hash for the checksum of a whole file which
+// is the MD4 of the seed followed by the data
+func newFileSum(seed int32) hash.Hash {
+	h := md4.New()
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(seed))
+	_, _ = h.Write(b[:])
+	return h
+}
+
+// readBlockSums reads the checksums of the blocks described by head
+func readBlockSums(r io.Reader, head *sumHead) ([]blockSum, error) {
+	sums := make([]blockSum, head.count)
+	for i := range sums {
+		weak, err := readInt(r)
+		if err != nil {
+			return nil, err
+		}
+		sums[i].weak = uint32(weak)
+		sums[i].strong = make([]byte, head.sum2Length)
+		_, err = io.ReadFull(r, sums[i].strong)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sums, nil
+}
+
+// sendLiteral sends data as literal tokens
+func sendLiteral(w io.Writer, data []byte) error {
+	for len(data) > 0 {
+		n := len(data)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		err := writeInt(w, int32(n))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data[:n])
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// sendFile sends the data read from in as literal data and matches
+// of the blocks of the basis file, followed by the file checksum
+//
+// This works like rsync, rolling a window the length of a block over
+// the data, looking up the weak checksum of the window in the blocks
+// and checking the strong checksum of any found. The window shrinks at
+// the end of the data so the shorter last block can match too. Only
+// a couple of blocks of data are kept in memory so in can be a stream.
+func sendFile(w io.Writer, in io.Reader, head *sumHead, sums []blockSum, seed int32) error {
+	fileSum := newFileSum(seed)
+	blockLength := int(head.blockLength)
+	blocks := make(map[uint32][]int32, len(sums))
+	for i := range sums {
+		blocks[sums[i].weak] = append(blocks[sums[i].weak], int32(i))
+	}
+	var (
+		buf      []byte // data read which hasn't been sent
+		pos      int    // start of the window in buf
+		literal  int    // start of the literal data not yet sent in buf
+		eof      bool   // set if all of in has been read
+		window   int    // length of the window
+		s1, s2   uint32 // weak checksum of the window
+		sumValid bool   // set if s1, s2 are for the window
+	)
+	// fill reads data until there are n bytes after pos or in is
+	// exhausted, dropping the data sent already from buf
+	fill := func(n int) error {
+		for !eof && len(buf)-pos < n {
+			if literal > 0 {
+				copy(buf, buf[literal:])
+				buf = buf[:len(buf)-literal]
+				pos -= literal
+				literal = 0
+			}
+			if cap(buf)-len(buf) < readSize {
+				newBuf := make([]byte, len(buf), 2*cap(buf)+readSize)
+				copy(newBuf, buf)
+				buf = newBuf
+			}
+			got, err := in.Read(buf[len(buf):cap(buf)])
+			_, _ = fileSum.Write(buf[len(buf) : len(buf)+got])
+			buf = buf[:len(buf)+got]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		// Read enough for the window and the byte after it
+		err := fill(blockLength + 1)
+		if err != nil {
+			return err
+		}
+		if pos-literal >= chunkSize {
+			err = sendLiteral(w, buf[literal:pos])
+			if err != nil {
+				return err
+			}
+			literal = pos
+		}
+		if len(sums) == 0 {
+			// Without a basis all the data is literal
+			if eof && pos == len(buf) {
+				break
+			}
+			pos = len(buf)
+			continue
+		}
+		if !sumValid {
+			window = blockLength
+			if window > len(buf)-pos {
+				window = len(buf) - pos
+			}
+			s1, s2 = weakSum(buf[pos : pos+window])
+			sumValid = true
+		}
+		if window == 0 {
+			break
+		}
+		if match := findBlock(head, sums, blocks[packWeakSum(s1, s2)], buf[pos:pos+window], seed); match >= 0 {
+			err = sendLiteral(w, buf[literal:pos])
+			if err == nil {
+				err = writeInt(w, -(match + 1))
+			}
+			if err != nil {
+				return err
+			}
+			pos += window
+			literal = pos
+			sumValid = false
+			continue
+		}
+		// Roll the window on a byte, shrinking it at the end
+		out := uint32(int8(buf[pos]))
+		s1 -= out
+		s2 -= uint32(window) * out
+		if pos+window < len(buf) {
+			s1 += uint32(int8(buf[pos+window]))
+			s2 += s1
+		} else {
+			window--
+		}
+		pos++
+	}
+	err := sendLiteral(w, buf[literal:])
+	if err != nil {
+		return err
+	}
+	err = writeInt(w, 0)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(fileSum.Sum(nil))
+	return err
+}
+
+// findBlock returns the index of the block in candidates which data
+// matches, or -1 if none do
+func findBlock(head *sumHead, sums []blockSum, candidates []int32, data []byte, seed int32) int32 {
+	var strong []byte
+	for _, i := range candidates {
+		if head.blockLen(i) != len(data) {
+			continue
+		}
+		if strong == nil {
+			strong = strongSum(data, seed)
+		}
+		if string(strong[:head.sum2Length]) == string(sums[i].strong) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sendFileData answers the daemon's requests for the files in a
+// transfer of n files to it, sending the data from in for the file at
+// index ndx, then ends the transfer
+//
+// If the daemon wants any other file, or ndx again as it was
+// corrupted, an error is returned, so in may be nil if no file data
+// is expected.
+func (c *conn) sendFileData(n int, ndx int32, in io.Reader) error {
+	phase := 0
+	sent := false
+	for {
+		got, err := c.readNdx(n)
+		if err != nil {
+			return err
+		}
+		if got == ndxDone {
+			phase++
+			if phase > 1 {
+				break
+			}
+			err = writeInt(c.out, ndxDone)
+			if err == nil {
+				err = c.out.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if got != ndx || in == nil {
+			return errors.Errorf("daemon asked for unexpected file %d", got)
+		}
+		if sent {
+			return errors.New("corrupted on transfer: daemon asked for the file again")
+		}
+		head, err := readSumHead(c)
+		if err != nil {
+			return err
+		}
+		sums, err := readBlockSums(c, head)
+		if err != nil {
+			return err
+		}
+		err = writeInt(c.out, ndx)
+		if err == nil {
+			err = writeSumHead(c.out, head)
+		}
+		if err == nil {
+			err = sendFile(c.out, in, head, sums, c.seed)
+		}
+		if err == nil {
+			err = c.out.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		sent = true
+	}
+	// End the transfer and the daemon says goodbye
+	err := writeInt(c.out, ndxDone)
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	got, err := readInt(c)
+	if err != nil {
+		return err
+	}
+	if got != ndxDone {
+		return errors.Errorf("protocol error: expecting goodbye but got %d", got)
+	}
+	return c.err()
+}
@@ -0,0 +1,796 @@
+// Client side of the rsync daemon protocol
+//
+// The protocol is documented only by the rsync source, so this
+// follows what rsync does when speaking protocol version 27, which is
+// the oldest version rsync 3.x daemons speak without complaint and
+// doesn't need incremental recursion or the varint encodings.
+
+package rsyncd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+	"golang.org/x/crypto/md4"
+)
+
+const (
+	protocolVersion = 27                 // protocol version we speak
+	greeting        = "@RSYNCD: "        // prefix of the daemon's control lines
+	mplexBase       = 7                  // added to message codes in the multiplexed stream
+	chunkSize       = 32 * 1024          // maximum literal data sent in one token
+	sumLength       = md4.Size           // length of the file checksums
+	maxNameLength   = 4096               // longest file name accepted in a file list
+	maxBlocks       = 1 << 27            // most block checksums accepted for a file
+	maxBlockLength  = 1 << 29            // longest block rsync uses before protocol 30
+	dirMode         = modeDir | 0755     // mode of directories we send
+	fileMode        = modeRegular | 0644 // mode of files we send
+)
+
+// Codes of the messages in the multiplexed stream from the daemon
+const (
+	msgData      = 0
+	msgErrorXfer = 1
+	msgInfo      = 2
+	msgError     = 3
+	msgWarning   = 4
+)
+
+// Flags of the entries in a file list
+const (
+	xmitTopDir   = 1 << 0
+	xmitSameMode = 1 << 1
+	xmitSameName = 1 << 5
+	xmitLongName = 1 << 6
+	xmitSameTime = 1 << 7
+)
+
+// File types in modes
+const (
+	modeTypeMask = 0170000
+	modeDir      = 0040000
+	modeRegular  = 0100000
+)
+
+// ndxDone marks the end of a phase of the transfer
+const ndxDone = -1
+
+// fileInfo is an entry in a file list
+type fileInfo struct {
+	name    string // path relative to the transfer root
+	size    int64
+	modTime int32 // seconds since the epoch
+	mode    uint32
+}
+
+// isDir returns whether the entry is a directory
+func (fi *fileInfo) isDir() bool {
+	return fi.mode&modeTypeMask == modeDir
+}
+
+// isRegular returns whether the entry is a regular file
+func (fi *fileInfo) isRegular() bool {
+	return fi.mode&modeTypeMask == modeRegular
+}
+
+// sortFileList sorts files the way rsync does for protocols before
+// 29, which the indexes of the files in the transfer refer to
+func sortFileList(files []*fileInfo) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+}
+
+// readInt reads a little endian 32 bit integer
+func readInt(r io.Reader) (int32, error) {
+	var b [4]byte
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b[:])), nil
+}
+
+// readLongint reads a 32 bit integer, or a 64 bit one if the 32 bit
+// one is -1
+func readLongint(r io.Reader) (int64, error) {
+	x, err := readInt(r)
+	if err != nil || x != -1 {
+		return int64(x), err
+	}
+	var b [8]byte
+	_, err = io.ReadFull(r, b[:])
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b[:])), nil
+}
+
+// readByte reads a single byte
+func readByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+// writeInt writes a little endian 32 bit integer
+func writeInt(w io.Writer, x int32) error {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(x))
+	_, err := w.Write(b[:])
+	return err
+}
+
+// writeLongint writes x as a 32 bit integer if it fits, otherwise as
+// -1 followed by a 64 bit integer
+func writeLongint(w io.Writer, x int64) error {
+	if x >= 0 && x <= 0x7FFFFFFF {
+		return writeInt(w, int32(x))
+	}
+	var b [12]byte
+	binary.LittleEndian.PutUint32(b[:4], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint64(b[4:], uint64(x))
+	_, err := w.Write(b[:])
+	return err
+}
+
+// readFileList reads a file list and the I/O error flag which
+// follows it, returning the files in the order the transfer refers
+// to them
+func readFileList(r io.Reader) (files []*fileInfo, ioError int32, err error) {
+	var (
+		lastName string
+		modTime  int32
+		mode     uint32
+	)
+	for {
+		flags, err := readByte(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if flags == 0 {
+			break
+		}
+		prefix := 0
+		if flags&xmitSameName != 0 {
+			b, err := readByte(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			prefix = int(b)
+		}
+		var length int
+		if flags&xmitLongName != 0 {
+			x, err := readInt(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			length = int(x)
+		} else {
+			b, err := readByte(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			length = int(b)
+		}
+		if prefix > len(lastName) || length < 0 || prefix+length > maxNameLength {
+			return nil, 0, errors.Errorf("protocol error: bad name length %d+%d in file list", prefix, length)
+		}
+		name := make([]byte, length)
+		_, err = io.ReadFull(r, name)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi := &fileInfo{name: lastName[:prefix] + string(name)}
+		lastName = fi.name
+		fi.size, err = readLongint(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if flags&xmitSameTime == 0 {
+			modTime, err = readInt(r)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		if flags&xmitSameMode == 0 {
+			x, err := readInt(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			mode = uint32(x)
+		}
+		fi.modTime, fi.mode = modTime, mode
+		// We don't ask for owners, devices, links or checksums
+		// so none of those follow
+		files = append(files, fi)
+	}
+	ioError, err = readInt(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	sortFileList(files)
+	return files, ioError, nil
+}
+
+// writeFileList writes files as a file list followed by a clear I/O
+// error flag
+//
+// The directories without a "/" in their names are marked as the top
+// directories of the transfer, which the daemon deletes files in when
+// asked to.
+func writeFileList(w io.Writer, files []*fileInfo) error {
+	for _, fi := range files {
+		// Long names are always used so the flags are never 0
+		flags := byte(xmitLongName)
+		if fi.isDir() && !strings.Contains(fi.name, "/") {
+			flags |= xmitTopDir
+		}
+		_, err := w.Write([]byte{flags})
+		if err != nil {
+			return err
+		}
+		err = writeInt(w, int32(len(fi.name)))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, fi.name)
+		if err != nil {
+			return err
+		}
+		err = writeLongint(w, fi.size)
+		if err != nil {
+			return err
+		}
+		err = writeInt(w, fi.modTime)
+		if err != nil {
+			return err
+		}
+		err = writeInt(w, int32(fi.mode))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0})
+	if err != nil {
+		return err
+	}
+	return writeInt(w, 0)
+}
+
+// writeFilterList writes the filter rules and the empty rule which
+// ends them
+func writeFilterList(w io.Writer, rules []string) error {
+	for _, rule := range rules {
+		err := writeInt(w, int32(len(rule)))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, rule)
+		if err != nil {
+			return err
+		}
+	}
+	return writeInt(w, 0)
+}
+
+// escapeWildcards escapes p if it contains any of the wildcards which
+// the daemon expands in paths and filter rules
+//
+// rsync only treats backslashes as escapes in patterns with
+// wildcards in so anything else is left alone.
+func escapeWildcards(p string) string {
+	if !strings.ContainsAny(p, "*?[") {
+		return p
+	}
+	var out strings.Builder
+	for _, c := range p {
+		if strings.ContainsRune(`*?[]\`, c) {
+			out.WriteByte('\\')
+		}
+		out.WriteRune(c)
+	}
+	return out.String()
+}
+
+// serverError is an error reported by the daemon
+type serverError struct {
+	messages []string
+}
+
+// Error satisfies the error interface
+func (e *serverError) Error() string {
+	return "rsync daemon: " + strings.Join(e.messages, ": ")
+}
+
+// contains returns whether any of the messages contain s
+func (e *serverError) contains(s string) bool {
+	for _, message := range e.messages {
+		if strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound returns whether err says a path doesn't exist
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(*serverError)
+	return ok && (e.contains("No such file or directory") || e.contains("Not a directory") || e.contains("Unknown module"))
+}
+
+// conn is a connection to a module of the daemon
+//
+// Once the daemon has accepted the arguments everything read from it
+// is multiplexed, so conn reads the data out of the data messages and
+// logs the others, keeping any errors to return later. What is
+// written isn't multiplexed in protocols before 30.
+type conn struct {
+	f         *Fs
+	c         net.Conn
+	in        *bufio.Reader
+	out       *bufio.Writer
+	seed      int32    // checksum seed for the transfer
+	remaining int      // data left in the current message
+	errors    []string // errors reported by the daemon
+}
+
+// dial connects to the daemon and says hello, returning the
+// connection ready for a module name
+func (f *Fs) dial(ctx context.Context) (*conn, error) {
+	nc, err := fshttp.NewDialer(fs.Config).DialContext(ctx, "tcp", f.dialAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect")
+	}
+	c := &conn{
+		f:   f,
+		c:   nc,
+		in:  bufio.NewReader(nc),
+		out: bufio.NewWriter(nc),
+	}
+	line, err := c.readLine()
+	if err != nil {
+		_ = c.Close()
+		return nil, errors.Wrap(err, "failed to read greeting")
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, greeting))
+	if !strings.HasPrefix(line, greeting) || len(fields) == 0 {
+		_ = c.Close()
+		return nil, errors.Errorf("not an rsync daemon: %q", line)
+	}
+	version, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || version < protocolVersion {
+		_ = c.Close()
+		return nil, errors.Errorf("unsupported rsync protocol version %q", fields[0])
+	}
+	_, err = fmt.Fprintf(c.out, "%s%d.0\n", greeting, protocolVersion)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// open connects to module and starts a transfer with the arguments
+// args given to the daemon
+func (f *Fs) open(ctx context.Context, module string, args []string) (c *conn, err error) {
+	err = f.pacer.Call(func() (bool, error) {
+		c, err = f.dial(ctx)
+		if err == nil {
+			err = c.start(module, args)
+			if err != nil {
+				_ = c.Close()
+			}
+		}
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// start selects module, logging in if needed, and starts a transfer
+// with args
+func (c *conn) start(module string, args []string) error {
+	_, err := fmt.Fprintf(c.out, "%s\n", module)
+	if err != nil {
+		return err
+	}
+	err = c.out.Flush()
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return errors.Wrap(err, "failed to read reply")
+		}
+		switch {
+		case line == greeting+"OK":
+			for _, arg := range args {
+				_, err = fmt.Fprintf(c.out, "%s\n", arg)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = c.out.WriteString("\n")
+			if err != nil {
+				return err
+			}
+			err = c.out.Flush()
+			if err != nil {
+				return err
+			}
+			// Only the checksum seed comes before the
+			// multiplexing starts
+			c.seed, err = readInt(c.in)
+			if err != nil {
+				return errors.Wrap(err, "failed to read checksum seed")
+			}
+			return nil
+		case strings.HasPrefix(line, greeting+"AUTHREQD "):
+			err = c.authenticate(strings.TrimPrefix(line, greeting+"AUTHREQD "))
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "@ERROR"):
+			return &serverError{messages: []string{strings.TrimSpace(strings.TrimPrefix(line, "@ERROR:"))}}
+		case line == greeting+"EXIT":
+			return errors.Errorf("daemon closed the connection to module %q", module)
+		default:
+			fs.Debugf(c.f, "motd: %s", line)
+		}
+	}
+}
+
+// authenticate answers the challenge from the daemon with the user
+// and password
+//
+// Before protocol 30 the response is the MD4 of the zero checksum
+// seed, the password and the challenge.
+func (c *conn) authenticate(challenge string) error {
+	if c.f.opt.User == "" {
+		return errors.New("module needs a user and password")
+	}
+	h := md4.New()
+	_, _ = h.Write([]byte{0, 0, 0, 0})
+	_, _ = io.WriteString(h, c.f.pass)
+	_, _ = io.WriteString(h, challenge)
+	_, err := fmt.Fprintf(c.out, "%s %s\n", c.f.opt.User, base64.RawStdEncoding.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return c.out.Flush()
+}
+
+// listModules returns the names of the modules the daemon lists
+func (f *Fs) listModules(ctx context.Context) (modules []string, err error) {
+	var c *conn
+	err = f.pacer.Call(func() (bool, error) {
+		c, err = f.dial(ctx)
+		return shouldRetry(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(c, &err)
+	_, err = c.out.WriteString("\n")
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list modules")
+		}
+		switch {
+		case line == greeting+"EXIT":
+			return modules, nil
+		case strings.HasPrefix(line, "@ERROR"):
+			return nil, &serverError{messages: []string{strings.TrimSpace(strings.TrimPrefix(line, "@ERROR:"))}}
+		case strings.HasPrefix(line, greeting):
+		default:
+			// Each line is the name padded with spaces, a tab
+			// and the comment
+			name := strings.TrimSpace(strings.SplitN(line, "\t", 2)[0])
+			if name != "" {
+				modules = append(modules, name)
+			}
+		}
+	}
+}
+
+// readLine reads a line of the text part of the protocol
+func (c *conn) readLine() (string, error) {
+	line, err := c.in.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Read reads the data from the multiplexed stream
+func (c *conn) Read(p []byte) (n int, err error) {
+	for c.remaining == 0 {
+		err = c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(p) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err = c.in.Read(p)
+	c.remaining -= n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// readMessage reads the header of the next message, reading the
+// whole message unless it is data
+func (c *conn) readMessage() error {
+	header, err := readInt(c.in)
+	if err != nil {
+		if len(c.errors) > 0 {
+			return c.err()
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return errors.Wrap(err, "failed to read from daemon")
+	}
+	code := int(uint32(header)>>24) - mplexBase
+	length := int(header & 0xFFFFFF)
+	if code == msgData {
+		c.remaining = length
+		return nil
+	}
+	if code < 0 {
+		return errors.Errorf("protocol error: bad message header %08x", uint32(header))
+	}
+	message := make([]byte, length)
+	_, err = io.ReadFull(c.in, message)
+	if err != nil {
+		return errors.Wrap(err, "failed to read message from daemon")
+	}
+	text := strings.TrimSpace(string(message))
+	switch code {
+	case msgErrorXfer, msgError:
+		fs.Debugf(c.f, "daemon error: %s", text)
+		c.errors = append(c.errors, text)
+	case msgWarning:
+		fs.Logf(c.f, "daemon warning: %s", text)
+	default:
+		fs.Debugf(c.f, "daemon: %s", text)
+	}
+	return nil
+}
+
+// err returns the errors reported by the daemon as an error, or nil
+// if there weren't any
+func (c *conn) err() error {
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return &serverError{messages: c.errors}
+}
+
+// readNdx reads the index of a file or ndxDone, checking it is one
+// of these
+func (c *conn) readNdx(n int) (int32, error) {
+	ndx, err := readInt(c)
+	if err != nil {
+		return 0, err
+	}
+	if ndx != ndxDone && (ndx < 0 || int(ndx) >= n) {
+		return 0, errors.Errorf("protocol error: bad file index %d", ndx)
+	}
+	return ndx, nil
+}
+
+// finishReceiving ends a transfer from the daemon once the files
+// wanted have been requested and received, checking for errors
+func (c *conn) finishReceiving(n int) error {
+	// The daemon echoes the end of the first phase then sends the
+	// end of the transfer when we end the second phase, which
+	// has already been sent
+	for i := 0; i < 2; i++ {
+		ndx, err := c.readNdx(n)
+		if err != nil {
+			return err
+		}
+		if ndx != ndxDone {
+			return errors.Errorf("protocol error: unexpected file index %d", ndx)
+		}
+	}
+	// Then the statistics which we don't need
+	for i := 0; i < 3; i++ {
+		_, err := readLongint(c)
+		if err != nil {
+			return err
+		}
+	}
+	err := c.goodbye()
+	if err != nil {
+		return err
+	}
+	return c.err()
+}
+
+// endPhases ends both phases of a transfer from the daemon once the
+// files wanted have been requested
+func (c *conn) endPhases() error {
+	err := writeInt(c.out, ndxDone)
+	if err == nil {
+		err = writeInt(c.out, ndxDone)
+	}
+	if err == nil {
+		err = c.out.Flush()
+	}
+	return err
+}
+
+// goodbye says goodbye to the daemon
+func (c *conn) goodbye() error {
+	err := writeInt(c.out, ndxDone)
+	if err != nil {
+		return err
+	}
+	return c.out.Flush()
+}
+
+// Close the connection
+func (c *conn) Close() error {
+	return c.c.Close()
+}
+
+// writeSumHead writes the header of the block checksums
+func writeSumHead(w io.Writer, head *sumHead) error {
+	for _, x := range []int32{head.count, head.blockLength, head.sum2Length, head.remainder} {
+		err := writeInt(w, x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSumHead reads the header of the block checksums checking it
+// is sensible
+func readSumHead(r io.Reader) (*sumHead, error) {
+	var x [4]int32
+	for i := range x {
+		var err error
+		x[i], err = readInt(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	head := &sumHead{count: x[0], blockLength: x[1], sum2Length: x[2], remainder: x[3]}
+	if head.count < 0 || head.count > maxBlocks ||
+		head.blockLength < 0 || head.blockLength > maxBlockLength ||
+		head.sum2Length < 0 || head.sum2Length > sumLength ||
+		head.remainder < 0 || head.remainder > head.blockLength ||
+		(head.count > 0 && head.blockLength == 0) {
+		return nil, errors.Errorf("protocol error: bad checksum header %+v", *head)
+	}
+	return head, nil
+}
+
+// fileReader reads the data of a file from the daemon sent without a
+// basis file, checking the file checksum at the end
+type fileReader struct {
+	c    *conn
+	n    int // number of files in the transfer
+	left int // data left in the current token
+	sum  hashWriter
+	done bool
+	err  error
+}
+
+// hashWriter is the part of hash.Hash we need
+type hashWriter interface {
+	io.Writer
+	Sum(b []byte) []byte
+}
+
+// newFileReader reads the file at index ndx, whose checksums have
+// been requested, of the n files in the transfer
+func (c *conn) newFileReader(ndx int32, n int) (*fileReader, error) {
+	got, err := c.readNdx(n)
+	if err != nil {
+		return nil, err
+	}
+	if got != ndx {
+		if got == ndxDone {
+			// The daemon couldn't send it
+			err = c.finishReceiving(n)
+			if err == nil {
+				err = errors.New("daemon didn't send the file")
+			}
+			return nil, err
+		}
+		return nil, errors.Errorf("protocol error: expecting file index %d but got %d", ndx, got)
+	}
+	_, err = readSumHead(c)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReader{
+		c:   c,
+		n:   n,
+		sum: newFileSum(c.seed),
+	}, nil
+}
+
+// Read the file data
+func (r *fileReader) Read(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for r.left == 0 {
+		token, err := readInt(r.c)
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if token == 0 {
+			r.err = r.finish()
+			if r.err == nil {
+				r.err = io.EOF
+			}
+			return 0, r.err
+		}
+		if token < 0 || token > chunkSize {
+			r.err = errors.Errorf("protocol error: unexpected token %d", token)
+			return 0, r.err
+		}
+		r.left = int(token)
+	}
+	if len(p) > r.left {
+		p = p[:r.left]
+	}
+	n, err = r.c.Read(p)
+	r.left -= n
+	_, _ = r.sum.Write(p[:n])
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// finish checks the file checksum and ends the transfer
+func (r *fileReader) finish() error {
+	var sum [sumLength]byte
+	_, err := io.ReadFull(r.c, sum[:])
+	if err != nil {
+		return err
+	}
+	if string(sum[:]) != string(r.sum.Sum(nil)) {
+		return errors.New("corrupted on transfer: file checksums differ")
+	}
+	r.done = true
+	return r.c.finishReceiving(r.n)
+}
+
+// Close the connection, abandoning the transfer if it isn't done
+func (r *fileReader) Close() error {
+	return r.c.Close()
+}
@@ -0,0 +1,698 @@
+// Package rsyncd provides an interface to the modules of rsync
+// daemons
+package rsyncd
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/bucket"
+	"github.com/rclone/rclone/lib/encoder"
+	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/readers"
+)
+
+const (
+	minSleep      = 10 * time.Millisecond
+	maxSleep      = 2 * time.Second
+	decayConstant = 2 // bigger for slower decay, exponential
+	defaultPort   = "873"
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "rsyncd",
+		Description: "rsync daemon",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "host",
+			Help:     "rsync daemon host to connect to",
+			Required: true,
+			Examples: []fs.OptionExample{{
+				Value: "rsync.example.com",
+				Help:  "Connect to rsync.example.com",
+			}},
+		}, {
+			Name: "port",
+			Help: "rsync daemon port, leave blank to use default (" + defaultPort + ")",
+		}, {
+			Name: "user",
+			Help: "User name for modules which need authentication",
+		}, {
+			Name:       "pass",
+			Help:       "Password for modules which need authentication",
+			IsPassword: true,
+		}, {
+			Name:     config.ConfigEncoding,
+			Help:     config.ConfigEncodingHelp,
+			Advanced: true,
+			// rsync sends the bytes of the names so only the
+			// characters which can't be in names on the daemon's
+			// file system need encoding, and line endings as
+			// paths are sent to the daemon a line at a time
+			Default: encoder.Base | encoder.EncodeCrLf,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Host string               `config:"host"`
+	Port string               `config:"port"`
+	User string               `config:"user"`
+	Pass string               `config:"pass"`
+	Enc  encoder.MultiEncoder `config:"encoding"`
+}
+
+// Fs represents the modules of an rsync daemon
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path we are working on if any
+	opt      Options      // parsed options
+	features *fs.Features // optional features
+	dialAddr string       // address to connect to
+	pass     string       // revealed password
+	pacer    *fs.Pacer    // pacer for connections
+}
+
+// Object describes a file in a module
+type Object struct {
+	fs      *Fs       // what this object is part of
+	remote  string    // the remote path
+	size    int64     // size of the object
+	modTime time.Time // modification time of the object
+}
+
+// ------------------------------------------------------------
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return "rsync://" + f.dialAddr + "/" + f.root
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// shouldRetry returns a boolean as to whether this err deserves to be
+// retried.  It returns the err as a convenience
+func shouldRetry(err error) (bool, error) {
+	if e, ok := errors.Cause(err).(*serverError); ok {
+		return e.contains("max connections"), err
+	}
+	return fserrors.ShouldRetry(err), err
+}
+
+// NewFs constructs an Fs from the path, module/path
+func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
+	ctx := context.Background()
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	pass := ""
+	if opt.Pass != "" {
+		pass, err = obscure.Reveal(opt.Pass)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't decrypt password")
+		}
+	}
+	port := opt.Port
+	if port == "" {
+		port = defaultPort
+	}
+	root = strings.Trim(root, "/")
+	f := &Fs{
+		name:     name,
+		root:     root,
+		opt:      *opt,
+		dialAddr: net.JoinHostPort(opt.Host, port),
+		pass:     pass,
+		pacer:    fs.NewPacer(pacer.NewDefault(pacer.MinSleep(minSleep), pacer.MaxSleep(maxSleep), pacer.DecayConstant(decayConstant))),
+	}
+	f.features = (&fs.Features{
+		CanHaveEmptyDirectories: true,
+	}).Fill(f)
+	module, p := f.split("")
+	if p != "" {
+		// Check to see if the root is a file
+		fi, err := f.stat(ctx, module, p)
+		if err == nil && fi.isRegular() {
+			f.root = path.Dir(root)
+			// return an error with an fs which points to the parent
+			return f, fs.ErrorIsFile
+		}
+	}
+	return f, nil
+}
+
+// split returns the module and the path in the module of remote
+func (f *Fs) split(remote string) (module, p string) {
+	module, p = bucket.Split(path.Join(f.root, remote))
+	return module, f.opt.Enc.FromStandardPath(p)
+}
+
+// modulePath returns the argument for p in module which the daemon
+// expands wildcards in
+func modulePath(module, p string) string {
+	if p == "" {
+		return module + "/"
+	}
+	return module + "/" + escapeWildcards(p)
+}
+
+// receiveFileList returns the file list the daemon sends for arg,
+// only one level of it unless recurse is set
+//
+// If arg ends in "/" it is the contents of the directory, including
+// the directory itself as ".", otherwise the entry for arg.
+func (f *Fs) receiveFileList(ctx context.Context, module, arg string, recurse bool) (files []*fileInfo, err error) {
+	c, err := f.open(ctx, module, []string{"--server", "--sender", "-r", ".", arg})
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(c, &err)
+	var rules []string
+	if !recurse {
+		rules = []string{"- /*/*"}
+	}
+	err = writeFilterList(c.out, rules)
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	files, _, err = readFileList(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file list")
+	}
+	if len(files) == 0 {
+		// The daemon ends the transfer when it has nothing to send
+		return nil, c.err()
+	}
+	err = c.endPhases()
+	if err == nil {
+		err = c.finishReceiving(len(files))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// stat returns the entry for p in module
+func (f *Fs) stat(ctx context.Context, module, p string) (*fileInfo, error) {
+	files, err := f.receiveFileList(ctx, module, modulePath(module, p), false)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	name := path.Base(p)
+	for _, fi := range files {
+		if fi.name == name {
+			return fi, nil
+		}
+	}
+	return nil, fs.ErrorObjectNotFound
+}
+
+// newEntry makes a directory entry in dir from fi, returning nil for
+// anything other than files and directories
+func (f *Fs) newEntry(dir string, fi *fileInfo) fs.DirEntry {
+	remote := path.Join(dir, f.opt.Enc.ToStandardPath(fi.name))
+	modTime := time.Unix(int64(fi.modTime), 0)
+	switch {
+	case fi.isDir():
+		return fs.NewDir(remote, modTime)
+	case fi.isRegular():
+		return &Object{
+			fs:      f,
+			remote:  remote,
+			size:    fi.size,
+			modTime: modTime,
+		}
+	}
+	fs.Debugf(f, "Ignoring %q - not a file or directory", remote)
+	return nil
+}
+
+// listModuleDirs lists the modules as directories
+func (f *Fs) listModuleDirs(ctx context.Context) (entries fs.DirEntries, err error) {
+	modules, err := f.listModules(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't list modules")
+	}
+	for _, module := range modules {
+		entries = append(entries, fs.NewDir(module, time.Time{}))
+	}
+	return entries, nil
+}
+
+// listDir lists dir, everything under it if recurse is set, calling
+// fn with each entry
+func (f *Fs) listDir(ctx context.Context, dir string, recurse bool, fn func(fs.DirEntry) error) error {
+	module, p := f.split(dir)
+	arg := module + "/"
+	if p != "" {
+		arg += escapeWildcards(p) + "/"
+	}
+	files, err := f.receiveFileList(ctx, module, arg, recurse)
+	if err != nil {
+		if isNotFound(err) {
+			return fs.ErrorDirNotFound
+		}
+		return err
+	}
+	if len(files) == 0 {
+		return fs.ErrorDirNotFound
+	}
+	for _, fi := range files {
+		if fi.name == "." {
+			continue
+		}
+		entry := f.newEntry(dir, fi)
+		if entry == nil {
+			continue
+		}
+		err = fn(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	module, _ := f.split(dir)
+	if module == "" {
+		return f.listModuleDirs(ctx)
+	}
+	err = f.listDir(ctx, dir, false, func(entry fs.DirEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// The daemon sends everything under dir in one file list so this is
+// no more work for it than listing dir.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	list := walk.NewListRHelper(callback)
+	module, _ := f.split(dir)
+	if module == "" {
+		modules, err := f.listModuleDirs(ctx)
+		if err != nil {
+			return err
+		}
+		for _, module := range modules {
+			err = list.Add(module)
+			if err != nil {
+				return err
+			}
+			err = f.listDir(ctx, module.Remote(), true, list.Add)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		err = f.listDir(ctx, dir, true, list.Add)
+		if err != nil {
+			return err
+		}
+	}
+	return list.Flush()
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	module, p := f.split(remote)
+	if module == "" || p == "" {
+		return nil, fs.ErrorObjectNotFound
+	}
+	fi, err := f.stat(ctx, module, p)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.isRegular() {
+		return nil, fs.ErrorNotAFile
+	}
+	return f.newEntry(path.Dir(remote), fi).(*Object), nil
+}
+
+// push sends files to module for the daemon running with args,
+// sending the data from in for the file at index ndx in the files
+//
+// If rules is not nil it is sent as the filter list which the daemon
+// wants for deleting files.
+func (f *Fs) push(ctx context.Context, module string, args []string, rules []string, files []*fileInfo, ndx int32, in io.Reader) (err error) {
+	c, err := f.open(ctx, module, args)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(c, &err)
+	if rules != nil {
+		err = writeFilterList(c.out, rules)
+		if err != nil {
+			return err
+		}
+	}
+	err = writeFileList(c.out, files)
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	return c.sendFileData(len(files), ndx, in)
+}
+
+// withParents returns the directories above p then fi in the order
+// of a file list
+func withParents(p string, fi *fileInfo) []*fileInfo {
+	files := []*fileInfo{fi}
+	now := int32(time.Now().Unix())
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		files = append(files, &fileInfo{name: dir, modTime: now, mode: dirMode})
+	}
+	sortFileList(files)
+	return files
+}
+
+// Put the object
+//
+// Copy the reader in to the new object which is returned
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(ctx, in, src, options...)
+}
+
+// Mkdir creates the directory if it doesn't exist
+//
+// Modules can't be created so it is an error to make a module which
+// doesn't exist.
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	module, p := f.split(dir)
+	if module == "" {
+		return nil
+	}
+	if p == "" {
+		_, err := f.receiveFileList(ctx, module, module+"/", false)
+		if isNotFound(err) {
+			return errors.Errorf("module %q not found - modules can't be created", module)
+		}
+		return err
+	}
+	files := withParents(p, &fileInfo{name: p, modTime: int32(time.Now().Unix()), mode: dirMode})
+	err := f.push(ctx, module, []string{"--server", "-r", ".", module + "/"}, nil, files, -1, nil)
+	if err != nil {
+		return errors.Wrap(err, "mkdir failed")
+	}
+	return nil
+}
+
+// remove removes p in module, and everything in it if it is a
+// directory
+//
+// This is done by sending the daemon just the directory p is in,
+// asking it to delete what isn't in the transfer with filter rules
+// which protect everything apart from p.
+func (f *Fs) remove(ctx context.Context, module, p string) error {
+	dest := module + "/"
+	if dir := path.Dir(p); dir != "." {
+		dest += escapeWildcards(dir) + "/"
+	}
+	rules := []string{"+ /" + escapeWildcards(path.Base(p)), "- /*"}
+	files := []*fileInfo{{name: ".", modTime: int32(time.Now().Unix()), mode: dirMode}}
+	err := f.push(ctx, module, []string{"--server", "-r", "--delete", ".", dest}, rules, files, -1, nil)
+	if err != nil {
+		return errors.Wrap(err, "delete failed")
+	}
+	return nil
+}
+
+// Rmdir deletes the directory
+//
+// Returns an error if it isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	module, p := f.split(dir)
+	if p == "" {
+		return errors.New("can't remove modules")
+	}
+	files, err := f.receiveFileList(ctx, module, modulePath(module, p)+"/", false)
+	if isNotFound(err) || (err == nil && len(files) == 0) {
+		return fs.ErrorDirNotFound
+	} else if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		return fs.ErrorDirectoryNotEmpty
+	}
+	return f.remove(ctx, module, p)
+}
+
+// Purge deletes all the files in the directory
+//
+// Optional interface: Only implement this if you have a way of
+// deleting all the files quicker than just running Remove() on the
+// result of List()
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	module, p := f.split(dir)
+	if p == "" {
+		return fs.ErrorCantPurge
+	}
+	fi, err := f.stat(ctx, module, p)
+	if err == fs.ErrorObjectNotFound || (err == nil && !fi.isDir()) {
+		return fs.ErrorDirNotFound
+	} else if err != nil {
+		return err
+	}
+	return f.remove(ctx, module, p)
+}
+
+// Precision return the precision of this Fs
+func (f *Fs) Precision() time.Duration {
+	return time.Second
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// ------------------------------------------------------------
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the hash of an object - the daemon doesn't send any
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.modTime
+}
+
+// SetModTime sets the modification time of the object
+//
+// This sends the file with the new time asking the daemon to skip
+// files which are the same size, which it sets the time of instead.
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	module, p := o.fs.split(o.remote)
+	files := []*fileInfo{{name: p, size: o.size, modTime: int32(modTime.Unix()), mode: fileMode}}
+	err := o.fs.push(ctx, module, []string{"--server", "-rt", "--size-only", ".", module + "/"}, nil, files, -1, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to set modification time")
+	}
+	o.modTime = time.Unix(modTime.Unix(), 0)
+	return nil
+}
+
+// Storable returns a boolean showing whether this object storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+//
+// The whole file is sent so seeking reads and discards the start of
+// it.
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.size)
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	module, p := o.fs.split(o.remote)
+	c, err := o.fs.open(ctx, module, []string{"--server", "--sender", ".", modulePath(module, p)})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	err = writeFilterList(c.out, nil)
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	files, _, err := readFileList(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file list")
+	}
+	if len(files) != 1 || !files[0].isRegular() {
+		if err = c.err(); err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		return nil, fs.ErrorObjectNotFound
+	}
+	// Ask for the file with no basis
+	err = writeInt(c.out, 0)
+	if err == nil {
+		err = writeSumHead(c.out, &sumHead{})
+	}
+	if err == nil {
+		err = c.endPhases()
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.newFileReader(0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		_, err = io.CopyN(ioutil.Discard, r, offset)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to seek")
+		}
+	}
+	if limit >= 0 {
+		return readers.NewLimitedReadCloser(r, limit), nil
+	}
+	return r, nil
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// The daemon sends the checksums of the blocks of the existing file so
+// only the data which has changed is sent.
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	size := src.Size()
+	if size < 0 {
+		return errors.New("can't upload files of unknown size")
+	}
+	module, p := o.fs.split(o.remote)
+	if module == "" || p == "" {
+		return errors.New("can't upload files outside a module")
+	}
+	modTime := src.ModTime(ctx)
+	fi := &fileInfo{name: p, size: size, modTime: int32(modTime.Unix()), mode: fileMode}
+	files := withParents(p, fi)
+	var ndx int32
+	for i := range files {
+		if files[i] == fi {
+			ndx = int32(i)
+		}
+	}
+	err := o.fs.push(ctx, module, []string{"--server", "-rtIO", ".", module + "/"}, nil, files, ndx, in)
+	if err != nil {
+		return errors.Wrap(err, "upload failed")
+	}
+	o.size = size
+	o.modTime = time.Unix(modTime.Unix(), 0)
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	module, p := o.fs.split(o.remote)
+	return o.fs.remove(ctx, module, p)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs      = (*Fs)(nil)
+	_ fs.Purger  = (*Fs)(nil)
+	_ fs.ListRer = (*Fs)(nil)
+	_ fs.Object  = (*Object)(nil)
+)
@@ -0,0 +1,825 @@
+package rsyncd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/md4"
+)
+
+// fakeDaemon is an rsync daemon with just enough of the protocol for
+// the backend, serving modules from local directories
+type fakeDaemon struct {
+	t           *testing.T
+	l           net.Listener
+	modules     map[string]string // directories of the modules by name
+	user        string            // user needed if set
+	pass        string            // password for user
+	seed        int32             // checksum seed sent
+	blockLength int32             // length of the blocks of basis files
+
+	wg      sync.WaitGroup // for the goroutines serving
+	mu      sync.Mutex
+	literal int64                 // literal file data received
+	conns   map[net.Conn]struct{} // open connections
+	closed  bool                  // set when the daemon is closed
+}
+
+// newFakeDaemon starts a daemon serving modules which needs user and
+// pass to log in if user is set
+func newFakeDaemon(t *testing.T, modules map[string]string, user, pass string) *fakeDaemon {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	d := &fakeDaemon{
+		t:           t,
+		l:           l,
+		modules:     modules,
+		user:        user,
+		pass:        pass,
+		seed:        0x1234567,
+		blockLength: 64,
+		conns:       map[net.Conn]struct{}{},
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			d.mu.Lock()
+			if d.closed {
+				d.mu.Unlock()
+				_ = nc.Close()
+				return
+			}
+			d.conns[nc] = struct{}{}
+			d.wg.Add(1)
+			d.mu.Unlock()
+			go func() {
+				defer d.wg.Done()
+				err := d.serve(nc)
+				_ = nc.Close()
+				d.mu.Lock()
+				delete(d.conns, nc)
+				closed := d.closed
+				d.mu.Unlock()
+				// Errors from connections closed by close are expected
+				if err != nil && err != io.EOF && !closed {
+					t.Logf("fake daemon: %v", err)
+				}
+			}()
+		}
+	}()
+	return d
+}
+
+// literalBytes returns the literal file data received since the
+// last call
+func (d *fakeDaemon) literalBytes() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	literal := d.literal
+	d.literal = 0
+	return literal
+}
+
+// config returns the config for the daemon
+func (d *fakeDaemon) config() configmap.Simple {
+	host, port, _ := net.SplitHostPort(d.l.Addr().String())
+	return configmap.Simple{"host": host, "port": port}
+}
+
+// close stops the daemon, closing its connections and waiting for
+// them to finish
+func (d *fakeDaemon) close() {
+	d.mu.Lock()
+	d.closed = true
+	_ = d.l.Close()
+	for nc := range d.conns {
+		_ = nc.Close()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// daemonConn is a connection from the point of view of the daemon
+type daemonConn struct {
+	in  *bufio.Reader
+	out *bufio.Writer
+	buf bytes.Buffer // data not yet sent in a message
+}
+
+// Write buffers data to send multiplexed
+func (c *daemonConn) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
+
+// message sends a message with code and data
+func (c *daemonConn) message(code int, data []byte) error {
+	err := writeInt(c.out, int32((code+mplexBase)<<24|len(data)))
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(data)
+	return err
+}
+
+// flush sends the buffered data
+func (c *daemonConn) flush() error {
+	if c.buf.Len() > 0 {
+		err := c.message(msgData, c.buf.Bytes())
+		if err != nil {
+			return err
+		}
+		c.buf.Reset()
+	}
+	return c.out.Flush()
+}
+
+// errorf sends an error message
+func (c *daemonConn) errorf(format string, a ...interface{}) error {
+	err := c.flush()
+	if err != nil {
+		return err
+	}
+	err = c.message(msgError, []byte(fmt.Sprintf(format, a...)+"\n"))
+	if err != nil {
+		return err
+	}
+	return c.out.Flush()
+}
+
+func (c *daemonConn) readLine() (string, error) {
+	line, err := c.in.ReadString('\n')
+	return strings.TrimRight(line, "\n"), err
+}
+
+// request is a transfer asked for by the client
+type request struct {
+	sender   bool   // client is receiving from us
+	recurse  bool   // -r
+	times    bool   // -t
+	delete   bool   // --delete
+	sizeOnly bool   // --size-only
+	dir      string // local directory of the module
+	rest     string // path in the module
+	trailing bool   // set if the path is for the contents of a directory
+}
+
+// local returns the local path of name under p
+func (r *request) local(p, name string) string {
+	return filepath.Join(r.dir, filepath.FromSlash(p), filepath.FromSlash(name))
+}
+
+func (d *fakeDaemon) serve(nc net.Conn) error {
+	c := &daemonConn{in: bufio.NewReader(nc), out: bufio.NewWriter(nc)}
+	_, _ = c.out.WriteString("@RSYNCD: 31.0\n")
+	_ = c.out.Flush()
+	version, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if version != "@RSYNCD: 27.0" {
+		return fmt.Errorf("unexpected version %q", version)
+	}
+	module, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if module == "" {
+		var names []string
+		for name := range d.modules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, _ = fmt.Fprintf(c.out, "%-15s\tmodule %s\n", name, name)
+		}
+		_, _ = c.out.WriteString("@RSYNCD: EXIT\n")
+		return c.out.Flush()
+	}
+	dir, ok := d.modules[module]
+	if !ok {
+		_, _ = fmt.Fprintf(c.out, "@ERROR: Unknown module '%s'\n", module)
+		return c.out.Flush()
+	}
+	_, _ = c.out.WriteString("Welcome to the fake daemon\n")
+	if d.user != "" {
+		challenge := "c2VjcmV0Y2hhbGxlbmdl"
+		_, _ = fmt.Fprintf(c.out, "@RSYNCD: AUTHREQD %s\n", challenge)
+		_ = c.out.Flush()
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		h := md4.New()
+		_, _ = h.Write([]byte{0, 0, 0, 0})
+		_, _ = io.WriteString(h, d.pass+challenge)
+		if line != d.user+" "+base64.RawStdEncoding.EncodeToString(h.Sum(nil)) {
+			_, _ = fmt.Fprintf(c.out, "@ERROR: auth failed on module %s\n", module)
+			return c.out.Flush()
+		}
+	}
+	_, _ = c.out.WriteString("@RSYNCD: OK\n")
+	_ = c.out.Flush()
+	var args []string
+	for {
+		arg, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if arg == "" {
+			break
+		}
+		args = append(args, arg)
+	}
+	r := &request{dir: dir}
+	for _, arg := range args[:len(args)-1] {
+		switch {
+		case arg == "--sender":
+			r.sender = true
+		case arg == "--delete":
+			r.delete = true
+		case arg == "--size-only":
+			r.sizeOnly = true
+		case strings.HasPrefix(arg, "--"), arg == ".":
+		case strings.HasPrefix(arg, "-"):
+			r.recurse = r.recurse || strings.Contains(arg, "r")
+			r.times = r.times || strings.Contains(arg, "t")
+		}
+	}
+	p := args[len(args)-1]
+	if !strings.HasPrefix(p, module+"/") {
+		return fmt.Errorf("bad path %q", p)
+	}
+	r.rest = strings.TrimPrefix(p, module+"/")
+	r.trailing = r.rest == "" || strings.HasSuffix(r.rest, "/")
+	if strings.ContainsAny(r.rest, "*?[") {
+		r.rest = unescape(r.rest)
+	}
+	r.rest = strings.TrimSuffix(r.rest, "/")
+	err = writeInt(c.out, d.seed)
+	if err == nil {
+		err = c.out.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if r.sender {
+		return d.send(c, r)
+	}
+	return d.receive(c, r)
+}
+
+// unescape removes the backslash escapes from p
+func unescape(p string) string {
+	var out strings.Builder
+	escaped := false
+	for _, c := range p {
+		if c == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		out.WriteRune(c)
+	}
+	return out.String()
+}
+
+// readFilterList reads the filter rules
+func readFilterList(r io.Reader) (rules []string, err error) {
+	for {
+		n, err := readInt(r)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return rules, nil
+		}
+		rule := make([]byte, n)
+		_, err = io.ReadFull(r, rule)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, string(rule))
+	}
+}
+
+// newFileInfo makes a file list entry from a local file
+func newFileInfo(name string, info os.FileInfo) *fileInfo {
+	fi := &fileInfo{name: name, modTime: int32(info.ModTime().Unix())}
+	switch {
+	case info.IsDir():
+		fi.mode = modeDir | 0755
+	case info.Mode().IsRegular():
+		fi.mode = modeRegular | 0644
+		fi.size = info.Size()
+	default:
+		fi.mode = 0120777 // a symlink
+	}
+	return fi
+}
+
+// writeCompressedFileList writes a file list the way rsync does,
+// leaving out what is the same as the previous entry
+func writeCompressedFileList(w io.Writer, files []*fileInfo, ioError int32) error {
+	var last fileInfo
+	for i, fi := range files {
+		var flags byte
+		prefix := 0
+		for prefix < len(fi.name) && prefix < len(last.name) && prefix < 255 && fi.name[prefix] == last.name[prefix] {
+			prefix++
+		}
+		if prefix > 0 {
+			flags |= xmitSameName
+		}
+		name := fi.name[prefix:]
+		if len(name) > 255 {
+			flags |= xmitLongName
+		}
+		if i > 0 && fi.modTime == last.modTime {
+			flags |= xmitSameTime
+		}
+		if i > 0 && fi.mode == last.mode {
+			flags |= xmitSameMode
+		}
+		if fi.isDir() && !strings.Contains(fi.name, "/") {
+			flags |= xmitTopDir
+		}
+		if flags == 0 {
+			flags = xmitLongName
+		}
+		buf := []byte{flags}
+		if prefix > 0 {
+			buf = append(buf, byte(prefix))
+		}
+		if flags&xmitLongName == 0 {
+			buf = append(buf, byte(len(name)))
+		}
+		_, _ = w.Write(buf)
+		if flags&xmitLongName != 0 {
+			_ = writeInt(w, int32(len(name)))
+		}
+		_, _ = io.WriteString(w, name)
+		_ = writeLongint(w, fi.size)
+		if flags&xmitSameTime == 0 {
+			_ = writeInt(w, fi.modTime)
+		}
+		if flags&xmitSameMode == 0 {
+			_ = writeInt(w, int32(fi.mode))
+		}
+		last = *fi
+	}
+	_, _ = w.Write([]byte{0})
+	return writeInt(w, ioError)
+}
+
+// send sends the files asked for to the client
+func (d *fakeDaemon) send(c *daemonConn, r *request) error {
+	rules, err := readFilterList(c.in)
+	if err != nil {
+		return err
+	}
+	oneLevel := len(rules) == 1 && rules[0] == "- /*/*"
+	var files []*fileInfo
+	root := r.local(r.rest, "")
+	info, err := os.Lstat(root)
+	if err == nil && info.IsDir() && !r.trailing && !r.recurse {
+		err = c.errorf("skipping directory %s", r.rest)
+	} else if err != nil {
+		err = c.errorf("rsync: link_stat %q (in %s) failed: No such file or directory (2)", r.rest, "module")
+	} else {
+		prefix := "."
+		if !r.trailing {
+			prefix = path.Base(r.rest)
+		}
+		files = append(files, newFileInfo(prefix, info))
+		if info.IsDir() && r.recurse {
+			err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+				if err != nil || p == root {
+					return err
+				}
+				name := filepath.ToSlash(p[len(root)+1:])
+				if prefix != "." {
+					name = prefix + "/" + name
+				}
+				if oneLevel && strings.Contains(name, "/") {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				files = append(files, newFileInfo(name, info))
+				return nil
+			})
+		}
+	}
+	if err != nil {
+		return err
+	}
+	sortFileList(files)
+	var ioError int32
+	if len(files) == 0 {
+		ioError = 1
+	}
+	err = writeCompressedFileList(c, files, ioError)
+	if err == nil {
+		err = c.flush()
+	}
+	if err != nil || len(files) == 0 {
+		// rsync gives up here when it has nothing to send
+		return err
+	}
+	phase := 0
+	for {
+		ndx, err := readInt(c.in)
+		if err != nil {
+			return err
+		}
+		if ndx == ndxDone {
+			phase++
+			_ = writeInt(c, ndxDone)
+			if phase == 2 {
+				break
+			}
+			_ = c.flush()
+			continue
+		}
+		head, err := readSumHead(c.in)
+		if err != nil {
+			return err
+		}
+		sums, err := readBlockSums(c.in, head)
+		if err != nil {
+			return err
+		}
+		local := r.local(r.rest, files[ndx].name)
+		if !r.trailing {
+			local = r.local(path.Dir(r.rest), files[ndx].name)
+		}
+		in, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		_ = writeInt(c, ndx)
+		_ = writeSumHead(c, head)
+		err = sendFile(c, in, head, sums, d.seed)
+		_ = in.Close()
+		if err != nil {
+			return err
+		}
+		err = c.flush()
+		if err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 3; i++ {
+		_ = writeLongint(c, 0)
+	}
+	err = c.flush()
+	if err != nil {
+		return err
+	}
+	goodbye, err := readInt(c.in)
+	if err != nil {
+		return err
+	}
+	if goodbye != ndxDone {
+		return fmt.Errorf("bad goodbye %d", goodbye)
+	}
+	return nil
+}
+
+// matchRule returns whether the filter pattern for the top of the
+// transfer matches name
+func matchRule(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	return pattern == name
+}
+
+// receive receives the files from the client
+func (d *fakeDaemon) receive(c *daemonConn, r *request) error {
+	var rules []string
+	var err error
+	if r.delete {
+		rules, err = readFilterList(c.in)
+		if err != nil {
+			return err
+		}
+	}
+	files, _, err := readFileList(c.in)
+	if err != nil {
+		return err
+	}
+	for ndx, fi := range files {
+		local := r.local(r.rest, fi.name)
+		switch {
+		case fi.name == ".":
+			if !r.delete {
+				continue
+			}
+			entries, err := ioutil.ReadDir(local)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				for _, rule := range rules {
+					if matchRule(rule[2:], entry.Name()) {
+						if rule[0] == '+' {
+							err = os.RemoveAll(filepath.Join(local, entry.Name()))
+							if err != nil {
+								return err
+							}
+						}
+						break
+					}
+				}
+			}
+		case fi.isDir():
+			err = os.MkdirAll(local, 0777)
+			if err != nil {
+				return err
+			}
+		case fi.isRegular():
+			err = d.receiveFile(c, r, int32(ndx), fi, local)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// End the phases and say goodbye
+	for i := 0; i < 2; i++ {
+		_ = writeInt(c, ndxDone)
+		err = c.flush()
+		if err != nil {
+			return err
+		}
+		ndx, err := readInt(c.in)
+		if err != nil {
+			return err
+		}
+		if ndx != ndxDone {
+			return fmt.Errorf("expecting end of phase but got %d", ndx)
+		}
+	}
+	_ = writeInt(c, ndxDone)
+	return c.flush()
+}
+
+// receiveFile asks for the file at ndx and writes it to local
+func (d *fakeDaemon) receiveFile(c *daemonConn, r *request, ndx int32, fi *fileInfo, local string) error {
+	modTime := time.Unix(int64(fi.modTime), 0)
+	basis, err := ioutil.ReadFile(local)
+	if err == nil && r.sizeOnly && int64(len(basis)) == fi.size {
+		if r.times {
+			return os.Chtimes(local, modTime, modTime)
+		}
+		return nil
+	}
+	head := &sumHead{blockLength: d.blockLength, sum2Length: 4}
+	head.count = (int32(len(basis)) + head.blockLength - 1) / head.blockLength
+	head.remainder = int32(len(basis)) % head.blockLength
+	_ = writeInt(c, ndx)
+	_ = writeSumHead(c, head)
+	for i := int32(0); i < head.count; i++ {
+		block := basis[i*head.blockLength:]
+		block = block[:head.blockLen(i)]
+		_ = writeInt(c, int32(packWeakSum(weakSum(block))))
+		_, _ = c.Write(strongSum(block, d.seed)[:head.sum2Length])
+	}
+	err = c.flush()
+	if err != nil {
+		return err
+	}
+	got, err := readInt(c.in)
+	if err != nil {
+		return err
+	}
+	if got != ndx {
+		return fmt.Errorf("expecting file %d but got %d", ndx, got)
+	}
+	_, err = readSumHead(c.in)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for {
+		token, err := readInt(c.in)
+		if err != nil {
+			return err
+		}
+		if token == 0 {
+			break
+		} else if token > 0 {
+			literal := make([]byte, token)
+			_, err = io.ReadFull(c.in, literal)
+			if err != nil {
+				return err
+			}
+			data = append(data, literal...)
+			d.mu.Lock()
+			d.literal += int64(token)
+			d.mu.Unlock()
+		} else {
+			i := -(token + 1)
+			start := i * head.blockLength
+			data = append(data, basis[start:int(start)+head.blockLen(i)]...)
+		}
+	}
+	sum := make([]byte, sumLength)
+	_, err = io.ReadFull(c.in, sum)
+	if err != nil {
+		return err
+	}
+	fileSum := newFileSum(d.seed)
+	_, _ = fileSum.Write(data)
+	if !bytes.Equal(sum, fileSum.Sum(nil)) {
+		return c.errorf("file checksums differ for %s", fi.name)
+	}
+	tmp := local + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0666)
+	if err != nil {
+		return err
+	}
+	if r.times {
+		err = os.Chtimes(tmp, modTime, modTime)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, local)
+}
+
+// TestStandard runs the integration tests against the fake daemon
+func TestStandard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-rsyncd")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	d := newFakeDaemon(t, map[string]string{"test": dir}, "", "")
+	defer d.close()
+	m := d.config()
+	name := "TestRsyncdFake"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":test",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "rsyncd"},
+			{Name: name, Key: "host", Value: m["host"]},
+			{Name: name, Key: "port", Value: m["port"]},
+		},
+	})
+}
+
+// newTestFs makes an Fs for the daemon at root
+func newTestFs(t *testing.T, d *fakeDaemon, root string, m configmap.Simple) fs.Fs {
+	for k, v := range d.config() {
+		m.Set(k, v)
+	}
+	f, err := NewFs("rsyncd", root, m)
+	require.NoError(t, err)
+	return f
+}
+
+func upload(ctx context.Context, t *testing.T, f fs.Fs, remote string, data []byte) fs.Object {
+	src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(data)), true, nil, nil)
+	o, err := f.Put(ctx, bytes.NewReader(data), src)
+	require.NoError(t, err)
+	return o
+}
+
+func TestModulesAndAuth(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-rsyncd")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	d := newFakeDaemon(t, map[string]string{"one": dir, "two": dir}, "user", "secret")
+	defer d.close()
+
+	f := newTestFs(t, d, "", configmap.Simple{})
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	assert.Equal(t, "one", entries[0].Remote())
+	assert.Equal(t, "two", entries[1].Remote())
+
+	_, err = f.List(ctx, "one")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs a user and password")
+
+	f = newTestFs(t, d, "", configmap.Simple{"user": "user", "pass": obscure.MustObscure("secret")})
+	upload(ctx, t, f, "one/dir/file.txt", []byte("hello"))
+	entries, err = f.List(ctx, "two/dir")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	assert.Equal(t, "two/dir/file.txt", entries[0].Remote())
+
+	_, err = f.List(ctx, "three")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+	err = f.Mkdir(ctx, "three")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "modules can't be created")
+}
+
+func TestDeltaUpload(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-rsyncd")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	d := newFakeDaemon(t, map[string]string{"test": dir}, "", "")
+	defer d.close()
+	f := newTestFs(t, d, "test", configmap.Simple{})
+
+	data := make([]byte, 100*1024)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	upload(ctx, t, f, "file.bin", data)
+	assert.Equal(t, int64(len(data)), d.literalBytes())
+
+	// Change some bytes in the middle and add some at the start
+	changed := append([]byte("new start"), data...)
+	copy(changed[50000:], "changed")
+	o := upload(ctx, t, f, "file.bin", changed)
+	literal := d.literalBytes()
+	assert.True(t, literal < 1000, "sent %d bytes", literal)
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "file.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, changed, got)
+
+	in, err := o.Open(ctx, &fs.RangeOption{Start: 9, End: 18})
+	require.NoError(t, err)
+	part, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, data[:10], part)
+}
+
+func TestWeakSum(t *testing.T) {
+	s1, s2 := weakSum([]byte("abc"))
+	assert.Equal(t, uint32(294), s1)
+	assert.Equal(t, uint32(586), s2)
+	// Bytes are signed
+	assert.Equal(t, uint32(0xffffffff), packWeakSum(weakSum([]byte{0xff})))
+}
+
+func TestEscapeWildcards(t *testing.T) {
+	assert.Equal(t, `dir/file\name`, escapeWildcards(`dir/file\name`))
+	assert.Equal(t, `\[a\]\*\\\?`, escapeWildcards(`[a]*\?`))
+}
+
+func TestSendFile(t *testing.T) {
+	basis := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	head := &sumHead{count: 4, blockLength: 10, sum2Length: sumLength, remainder: 6}
+	var sums []blockSum
+	for i := int32(0); i < head.count; i++ {
+		block := basis[i*10:]
+		block = block[:head.blockLen(i)]
+		sums = append(sums, blockSum{weak: packWeakSum(weakSum(block)), strong: strongSum(block, 1)})
+	}
+	var out bytes.Buffer
+	require.NoError(t, sendFile(&out, strings.NewReader("xx0123456789abcdefghijyyuvwxyz"), head, sums, 1))
+	var tokens []int32
+	for {
+		token, err := readInt(&out)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+		if token == 0 {
+			break
+		}
+		if token > 0 {
+			_, err = io.CopyN(ioutil.Discard, &out, int64(token))
+			require.NoError(t, err)
+		}
+	}
+	// literal "xx", block 0, block 1, literal "yy", block 3, end
+	assert.Equal(t, []int32{2, -1, -2, 2, -4, 0}, tokens)
+	assert.Equal(t, sumLength, out.Len())
+}
@@ -0,0 +1,249 @@
+// Listing objects from S3 Inventory reports
+
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/list"
+)
+
+// inventoryManifest is the manifest.json of an S3 Inventory report
+type inventoryManifest struct {
+	SourceBucket      string `json:"sourceBucket"`
+	DestinationBucket string `json:"destinationBucket"`
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"`
+	Files             []struct {
+		Key  string `json:"key"`
+		Size int64  `json:"size"`
+	} `json:"files"`
+	columns map[string]int // index of each column in FileSchema
+}
+
+// inventoryRow is an object read from an S3 Inventory report
+type inventoryRow struct {
+	key          string
+	size         int64
+	lastModified time.Time
+	etag         string
+	storageClass string
+}
+
+// parseInventoryManifest reads and checks an S3 Inventory manifest
+func parseInventoryManifest(in io.Reader) (*inventoryManifest, error) {
+	var m inventoryManifest
+	err := json.NewDecoder(in).Decode(&m)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse manifest")
+	}
+	if !strings.EqualFold(m.FileFormat, "CSV") {
+		return nil, errors.Errorf("inventory is in %s format which isn't supported - make a report in CSV format to use it", m.FileFormat)
+	}
+	m.columns = make(map[string]int)
+	for i, column := range strings.Split(m.FileSchema, ",") {
+		m.columns[strings.TrimSpace(column)] = i
+	}
+	for _, column := range []string{"Bucket", "Key"} {
+		if _, ok := m.columns[column]; !ok {
+			return nil, errors.Errorf("inventory doesn't have the %s column", column)
+		}
+	}
+	return &m, nil
+}
+
+// destinationBucket returns the name of the bucket the report files
+// are in
+func (m *inventoryManifest) destinationBucket() string {
+	// This is an ARN, eg arn:aws:s3:::bucket
+	if i := strings.LastIndex(m.DestinationBucket, ":"); i >= 0 {
+		return m.DestinationBucket[i+1:]
+	}
+	return m.DestinationBucket
+}
+
+// parseRow parses the fields of a line of a CSV report returning
+// false if it isn't the current version of an object
+func (m *inventoryManifest) parseRow(fields []string) (row inventoryRow, ok bool, err error) {
+	get := func(column string) string {
+		if i, found := m.columns[column]; found && i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	// Skip old versions and delete markers in reports with versions
+	if get("IsLatest") == "false" || get("IsDeleteMarker") == "true" {
+		return row, false, nil
+	}
+	// The keys are URL encoded
+	row.key, err = url.QueryUnescape(get("Key"))
+	if err != nil {
+		return row, false, errors.Wrapf(err, "bad key %q", get("Key"))
+	}
+	if size := get("Size"); size != "" {
+		row.size, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return row, false, errors.Wrapf(err, "bad size for %q", row.key)
+		}
+	}
+	if lastModified := get("LastModifiedDate"); lastModified != "" {
+		row.lastModified, err = time.Parse(time.RFC3339, lastModified)
+		if err != nil {
+			return row, false, errors.Wrapf(err, "bad last modified date for %q", row.key)
+		}
+	}
+	row.etag = get("ETag")
+	row.storageClass = get("StorageClass")
+	return row, true, nil
+}
+
+// readInventoryFile calls fn for each object in bucket in the CSV
+// report in
+func (m *inventoryManifest) readInventoryFile(in io.Reader, bucket string, fn func(row inventoryRow) error) error {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if i := m.columns["Bucket"]; i >= len(fields) || fields[i] != bucket {
+			continue
+		}
+		row, ok, err := m.parseRow(fields)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = fn(row)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// getObject opens the object at key in bucket for reading
+func (f *Fs) getObject(ctx context.Context, bucket, key string) (in io.ReadCloser, err error) {
+	req := s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	var resp *s3.GetObjectOutput
+	err = f.pacer.Call(func() (bool, error) {
+		resp, err = f.c.GetObjectWithContext(ctx, &req)
+		return f.shouldRetry(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// readInventoryManifest reads the manifest from the inventory option
+// the first time it is called
+func (f *Fs) readInventoryManifest(ctx context.Context) (*inventoryManifest, error) {
+	f.inventoryOnce.Do(func() {
+		bucket, key := splitBucket(strings.TrimPrefix(f.opt.Inventory, "s3://"))
+		var in io.ReadCloser
+		in, f.inventoryErr = f.getObject(ctx, bucket, key)
+		if f.inventoryErr != nil {
+			f.inventoryErr = errors.Wrap(f.inventoryErr, "failed to read inventory manifest")
+			return
+		}
+		defer fs.CheckClose(in, &f.inventoryErr)
+		f.inventory, f.inventoryErr = parseInventoryManifest(in)
+	})
+	return f.inventory, f.inventoryErr
+}
+
+// listInventory lists the objects in bucket under directory
+// recursively from the S3 Inventory report for list. It returns false
+// if the report isn't for bucket or the listing isn't of a source.
+//
+// Only the listings of sources use the report as it is out of date,
+// so syncing to the bucket from it would overwrite or delete the
+// wrong objects.
+func (f *Fs) listInventory(ctx context.Context, bucket, directory, prefix string, addBucket bool, fn listFn) (ok bool, err error) {
+	if !list.IsSource(ctx) {
+		return false, nil
+	}
+	m, err := f.readInventoryManifest(ctx)
+	if err != nil {
+		return false, err
+	}
+	if m.SourceBucket != bucket {
+		fs.Debugf(f, "Not using inventory as it is for bucket %q", m.SourceBucket)
+		return false, nil
+	}
+	fs.Debugf(f, "Listing %q from inventory in %d files", path.Join(bucket, directory), len(m.Files))
+	for _, file := range m.Files {
+		in, err := f.getObject(ctx, m.destinationBucket(), file.Key)
+		if err != nil {
+			return true, errors.Wrap(err, "failed to read inventory")
+		}
+		var report io.ReadCloser = in
+		if strings.HasSuffix(file.Key, ".gz") {
+			report, err = gzip.NewReader(in)
+			if err != nil {
+				_ = in.Close()
+				return true, errors.Wrapf(err, "failed to read inventory %q", file.Key)
+			}
+		}
+		err = m.readInventoryFile(report, bucket, func(row inventoryRow) error {
+			remote := f.opt.Enc.ToStandardPath(row.key)
+			if !strings.HasPrefix(row.key, directory) || !strings.HasPrefix(remote, prefix) {
+				return nil
+			}
+			remote = remote[len(prefix):]
+			isDirectory := remote == "" || strings.HasSuffix(remote, "/")
+			if addBucket {
+				remote = path.Join(bucket, remote)
+			}
+			if isDirectory && row.size == 0 {
+				return nil // skip directory marker
+			}
+			object := &s3.Object{
+				Key:          aws.String(row.key),
+				Size:         aws.Int64(row.size),
+				ETag:         aws.String(row.etag),
+				StorageClass: aws.String(row.storageClass),
+			}
+			if !row.lastModified.IsZero() {
+				object.LastModified = aws.Time(row.lastModified)
+			}
+			return fn(remote, object, nil, false)
+		})
+		closeErr := report.Close()
+		if report != in {
+			inCloseErr := in.Close()
+			if closeErr == nil {
+				closeErr = inCloseErr
+			}
+		}
+		if err != nil {
+			return true, errors.Wrapf(err, "failed to read inventory %q", file.Key)
+		}
+		if closeErr != nil {
+			return true, closeErr
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `{
+  "sourceBucket" : "source",
+  "destinationBucket" : "arn:aws:s3:::reports",
+  "version" : "2016-11-30",
+  "fileFormat" : "CSV",
+  "fileSchema" : "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate, ETag, StorageClass",
+  "files" : [ {
+    "key" : "source/config/data/1.csv.gz",
+    "size" : 100,
+    "MD5checksum" : "d41d8cd98f00b204e9800998ecf8427e"
+  } ]
+}`
+
+func TestParseInventoryManifest(t *testing.T) {
+	m, err := parseInventoryManifest(strings.NewReader(testManifest))
+	require.NoError(t, err)
+	assert.Equal(t, "source", m.SourceBucket)
+	assert.Equal(t, "reports", m.destinationBucket())
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "source/config/data/1.csv.gz", m.Files[0].Key)
+	assert.Equal(t, 5, m.columns["Size"])
+
+	_, err = parseInventoryManifest(strings.NewReader(strings.Replace(testManifest, `"CSV"`, `"ORC"`, 1)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ORC format")
+	_, err = parseInventoryManifest(strings.NewReader(strings.Replace(testManifest, "Bucket, Key,", "Bucket,", 1)))
+	assert.Error(t, err)
+	_, err = parseInventoryManifest(strings.NewReader("potato"))
+	assert.Error(t, err)
+}
+
+func TestReadInventoryFile(t *testing.T) {
+	m, err := parseInventoryManifest(strings.NewReader(testManifest))
+	require.NoError(t, err)
+	report := `"source","dir/file+one.txt","v2","true","false","5","2020-06-30T12:00:00.000Z","d41d8cd98f00b204e9800998ecf8427e","STANDARD"
+"source","dir/file+one.txt","v1","false","false","6","2020-06-29T12:00:00.000Z","0cc175b9c0f1b6a831c399e269772661","STANDARD"
+"source","deleted.txt","v3","true","true","","2020-06-30T12:00:00.000Z","",""
+"other","file.txt","v4","true","false","7","2020-06-30T12:00:00.000Z","0cc175b9c0f1b6a831c399e269772661","GLACIER"
+"source","100%25.txt","v5","true","false","8","2020-06-30T12:00:00.000Z","0cc175b9c0f1b6a831c399e269772661","GLACIER"
+`
+	var rows []inventoryRow
+	err = m.readInventoryFile(strings.NewReader(report), "source", func(row inventoryRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, inventoryRow{
+		key:          "dir/file one.txt",
+		size:         5,
+		lastModified: time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC),
+		etag:         "d41d8cd98f00b204e9800998ecf8427e",
+		storageClass: "STANDARD",
+	}, rows[0])
+	assert.Equal(t, "100%.txt", rows[1].key)
+	assert.Equal(t, "GLACIER", rows[1].storageClass)
+
+	err = m.readInventoryFile(strings.NewReader(`"source","file.txt","v1","true","false","potato","","",""`), "source", func(row inventoryRow) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
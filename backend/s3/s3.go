@@ -2,6 +2,7 @@
 package s3
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/md5"
@@ -11,7 +12,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"os"
+	"os/exec"
 	"path"
 	"regexp"
 	"sort"
@@ -21,6 +25,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/corehandlers"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -50,6 +55,7 @@ import (
 	"github.com/rclone/rclone/lib/pool"
 	"github.com/rclone/rclone/lib/readers"
 	"github.com/rclone/rclone/lib/rest"
+	"github.com/rclone/rclone/lib/resume"
 	"github.com/rclone/rclone/lib/structs"
 	"golang.org/x/sync/errgroup"
 )
@@ -795,6 +801,30 @@ isn't set then "acl" is used instead.`,
 				Value: "",
 				Help:  "None",
 			}},
+		}, {
+			Name: "sse_customer_key_file",
+			Help: `Path to a file of SSE-C keys to use for objects under each prefix.
+
+Each line of the file is a prefix, starting with the bucket name, then
+the base64 encoded 256 bit key to use for the objects under it, eg
+
+    bucket/private/ vtyNBmTaVEEz/4kXSnYLfVbV4hmkT25VtkSL1XWTSfQ=
+
+The key for the longest matching prefix is used. Objects not under any
+prefix use sse_customer_key if set. Blank lines and lines starting with
+"#" are ignored.`,
+			Provider: "AWS,Ceph,Minio",
+			Advanced: true,
+		}, {
+			Name: "sse_customer_key_command",
+			Help: `Command to run to get SSE-C keys to use for objects under each prefix.
+
+This is run once when the remote is created and should print the keys
+in the same format as sse_customer_key_file, eg to read them from a
+secrets manager.`,
+			Default:  fs.SpaceSepList{},
+			Provider: "AWS,Ceph,Minio",
+			Advanced: true,
 		}, {
 			Name:     "storage_class",
 			Help:     "The storage class to use when storing new objects in S3.",
@@ -857,6 +887,71 @@ isn't set then "acl" is used instead.`,
 				Value: "GLACIER",
 				Help:  "Archived storage; prices are lower, but it needs to be restored first to be accessed.",
 			}},
+		}, {
+			Name: "object_lock_mode",
+			Help: `Object Lock retention mode to set on uploaded objects.
+
+The bucket must have Object Lock enabled. This must be used with
+object_lock_retain_until.`,
+			Provider: "AWS,Ceph,Minio",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "",
+				Help:  "None",
+			}, {
+				Value: s3.ObjectLockModeGovernance,
+				Help:  "Governance mode - users with special permissions can remove the lock",
+			}, {
+				Value: s3.ObjectLockModeCompliance,
+				Help:  "Compliance mode - nobody can remove the lock until it expires",
+			}},
+		}, {
+			Name: "object_lock_retain_until",
+			Help: `Date or time from upload until which uploaded objects are retained.
+
+This can be a date, eg "2030-01-01" or "2030-01-01T12:00:00Z", or a
+duration from the time of upload, eg "30d" or "1y".
+
+This must be used with object_lock_mode.`,
+			Provider: "AWS,Ceph,Minio",
+			Advanced: true,
+		}, {
+			Name: "object_lock_legal_hold",
+			Help: `Set a legal hold on uploaded objects.
+
+The bucket must have Object Lock enabled. Objects with a legal hold
+can't be deleted until it is removed, whatever their retention.`,
+			Provider: "AWS,Ceph,Minio",
+			Default:  false,
+			Advanced: true,
+		}, {
+			Name: "version_at",
+			Help: `Show the bucket as it was at the time given.
+
+This uses the object versions in a versioned bucket to list and read
+the objects as they were at the time given, so they can be copied
+elsewhere to restore them. The time can be a date, eg "2020-06-30" or
+"2020-06-30T12:00:00Z", or a duration before now, eg "3d" for three
+days ago.
+
+The remote is read only when this is set.`,
+			Default:  "",
+			Advanced: true,
+		}, {
+			Name: "inventory",
+			Help: `Path to the manifest.json of an S3 Inventory report to list from.
+
+If set, recursive listings of the bucket the report is for, as used
+by --fast-list, read the objects from the report instead of listing
+the bucket when it is the source of a sync, copy, move or check,
+which is much quicker for buckets with millions of objects. Listings
+of the bucket as a destination always list the bucket. The path should include the bucket the report is in, eg
+"reports/source-bucket/config-id/2020-06-30T00-00Z/manifest.json".
+
+Only reports in CSV format are supported, not Parquet or ORC. The report is only as up to
+date as when it was made, so objects changed since won't be seen as
+changed.`,
+			Advanced: true,
 		}, {
 			Name: "upload_cutoff",
 			Help: `Cutoff for switching to chunked upload
@@ -915,6 +1010,27 @@ copied in chunks of this size.
 The minimum is 0 and the maximum is 5GB.`,
 			Default:  fs.SizeSuffix(maxSizeForCopy),
 			Advanced: true,
+		}, {
+			Name: "copy_chunk_size",
+			Help: `Chunk size to use for multipart copy.
+
+Files larger than copy_cutoff are server side copied in chunks of this
+size, several at once. If not set copy_cutoff is used. Making this
+smaller means more chunks can be copied at once, which makes copying
+very large files quicker.
+
+The chunk size is increased if needed to keep the number of chunks
+under max_upload_parts. The minimum is 5MB and the maximum is 5GB.`,
+			Default:  fs.SizeSuffix(0),
+			Advanced: true,
+		}, {
+			Name: "copy_concurrency",
+			Help: `Concurrency for multipart copy.
+
+This is the number of chunks of the same file that are server side
+copied concurrently.`,
+			Default:  4,
+			Advanced: true,
 		}, {
 			Name: "disable_checksum",
 			Help: `Don't store MD5 checksum with object metadata
@@ -1096,9 +1212,13 @@ type Options struct {
 	SSECustomerAlgorithm  string               `config:"sse_customer_algorithm"`
 	SSECustomerKey        string               `config:"sse_customer_key"`
 	SSECustomerKeyMD5     string               `config:"sse_customer_key_md5"`
+	SSECustomerKeyFile    string               `config:"sse_customer_key_file"`
+	SSECustomerKeyCommand fs.SpaceSepList      `config:"sse_customer_key_command"`
 	StorageClass          string               `config:"storage_class"`
 	UploadCutoff          fs.SizeSuffix        `config:"upload_cutoff"`
 	CopyCutoff            fs.SizeSuffix        `config:"copy_cutoff"`
+	CopyChunkSize         fs.SizeSuffix        `config:"copy_chunk_size"`
+	CopyConcurrency       int                  `config:"copy_concurrency"`
 	ChunkSize             fs.SizeSuffix        `config:"chunk_size"`
 	MaxUploadParts        int64                `config:"max_upload_parts"`
 	DisableChecksum       bool                 `config:"disable_checksum"`
@@ -1115,22 +1235,32 @@ type Options struct {
 	Enc                   encoder.MultiEncoder `config:"encoding"`
 	MemoryPoolFlushTime   fs.Duration          `config:"memory_pool_flush_time"`
 	MemoryPoolUseMmap     bool                 `config:"memory_pool_use_mmap"`
+	ObjectLockMode        string               `config:"object_lock_mode"`
+	ObjectLockRetainUntil string               `config:"object_lock_retain_until"`
+	ObjectLockLegalHold   bool                 `config:"object_lock_legal_hold"`
+	VersionAt             string               `config:"version_at"`
+	Inventory             string               `config:"inventory"`
 }
 
 // Fs represents a remote s3 server
 type Fs struct {
-	name          string           // the name of the remote
-	root          string           // root of the bucket - ignore all objects above this
-	opt           Options          // parsed options
-	features      *fs.Features     // optional features
-	c             *s3.S3           // the connection to the s3 server
-	ses           *session.Session // the s3 session
-	rootBucket    string           // bucket part of root (if any)
-	rootDirectory string           // directory part of root (if any)
-	cache         *bucket.Cache    // cache for bucket creation status
-	pacer         *fs.Pacer        // To pace the API calls
-	srv           *http.Client     // a plain http client
-	pool          *pool.Pool       // memory pool
+	name          string             // the name of the remote
+	root          string             // root of the bucket - ignore all objects above this
+	opt           Options            // parsed options
+	features      *fs.Features       // optional features
+	c             *s3.S3             // the connection to the s3 server
+	ses           *session.Session   // the s3 session
+	rootBucket    string             // bucket part of root (if any)
+	rootDirectory string             // directory part of root (if any)
+	cache         *bucket.Cache      // cache for bucket creation status
+	pacer         *fs.Pacer          // To pace the API calls
+	srv           *http.Client       // a plain http client
+	pool          *pool.Pool         // memory pool
+	sseKeys       []sseCustomerKey   // SSE-C keys for each prefix, longest first
+	versionAt     time.Time          // show the objects as they were at this time if set
+	inventoryOnce sync.Once          // read the inventory manifest once
+	inventory     *inventoryManifest // the inventory manifest if read
+	inventoryErr  error              // error reading the inventory manifest
 }
 
 // Object describes a s3 object
@@ -1147,6 +1277,10 @@ type Object struct {
 	meta         map[string]*string // The object metadata if known - may be nil
 	mimeType     string             // MimeType of object - may be ""
 	storageClass string             // eg GLACIER
+	versionID    *string            // version of the object to read if set
+	lockMode     string             // Object Lock retention mode if read
+	lockUntil    time.Time          // Object Lock retain until date if read
+	lockHold     bool               // set if the object has a legal hold
 }
 
 // ------------------------------------------------------------
@@ -1224,11 +1358,55 @@ func parsePath(path string) (root string) {
 	return
 }
 
+// isAccessPointARN returns true if bucketName is an access point ARN
+// rather than the name of a bucket
+func isAccessPointARN(bucketName string) bool {
+	return strings.HasPrefix(bucketName, "arn:") && strings.Contains(bucketName, ":accesspoint/")
+}
+
+// splitBucket splits absPath into a bucket and a path in that bucket
+// like bucket.Split except that the bucket may be an access point ARN,
+// eg arn:aws:s3:us-west-2:123456789012:accesspoint/name, which has a
+// "/" in it.
+func splitBucket(absPath string) (bucketName, bucketPath string) {
+	bucketName, bucketPath = bucket.Split(absPath)
+	if strings.HasPrefix(bucketName, "arn:") && strings.HasSuffix(bucketName, ":accesspoint") && bucketPath != "" {
+		name, rest := bucket.Split(bucketPath)
+		return bucketName + "/" + name, rest
+	}
+	return bucketName, bucketPath
+}
+
+// checkAccessPoint checks an access point ARN used as the bucket can
+// be used with the options
+func checkAccessPoint(opt *Options, bucketName string) error {
+	if !isAccessPointARN(bucketName) {
+		return nil
+	}
+	a, err := awsarn.Parse(bucketName)
+	if err != nil {
+		return err
+	}
+	if a.Region == "" || strings.HasSuffix(a.Resource, ".mrap") {
+		return errors.Errorf("multi-region access point %q needs SigV4A signing which isn't supported", bucketName)
+	}
+	if opt.Endpoint != "" {
+		return errors.Errorf("can't use endpoint with access point %q", bucketName)
+	}
+	if opt.UseAccelerateEndpoint {
+		return errors.Errorf("can't use accelerated endpoint with access point %q", bucketName)
+	}
+	return nil
+}
+
 // split returns bucket and bucketPath from the rootRelativePath
 // relative to f.root
 func (f *Fs) split(rootRelativePath string) (bucketName, bucketPath string) {
-	bucketName, bucketPath = bucket.Split(path.Join(f.root, rootRelativePath))
-	return f.opt.Enc.FromStandardName(bucketName), f.opt.Enc.FromStandardPath(bucketPath)
+	bucketName, bucketPath = splitBucket(path.Join(f.root, rootRelativePath))
+	if !isAccessPointARN(bucketName) {
+		bucketName = f.opt.Enc.FromStandardName(bucketName)
+	}
+	return bucketName, f.opt.Enc.FromStandardPath(bucketPath)
 }
 
 // split returns bucket and bucketPath from the object
@@ -1317,6 +1495,7 @@ func s3Connection(opt *Options) (*s3.S3, *session.Session, error) {
 		WithHTTPClient(fshttp.NewClient(fs.Config)).
 		WithS3ForcePathStyle(opt.ForcePathStyle).
 		WithS3UseAccelerate(opt.UseAccelerateEndpoint).
+		WithS3UseARNRegion(true).
 		WithS3UsEast1RegionalEndpoint(endpoints.RegionalS3UsEast1Endpoint)
 
 	if opt.Region != "" {
@@ -1365,6 +1544,21 @@ func checkUploadChunkSize(cs fs.SizeSuffix) error {
 	return nil
 }
 
+// checkCopyChunkSize checks copy_chunk_size is 0 or a size S3 accepts
+// for a part of a multipart copy
+func checkCopyChunkSize(cs fs.SizeSuffix) error {
+	if cs == 0 {
+		return nil
+	}
+	if cs < minChunkSize {
+		return errors.Errorf("%s is less than %s", cs, minChunkSize)
+	}
+	if cs > maxSizeForCopy {
+		return errors.Errorf("%s is greater than %s", cs, fs.SizeSuffix(maxSizeForCopy))
+	}
+	return nil
+}
+
 func (f *Fs) setUploadChunkSize(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
 	err = checkUploadChunkSize(cs)
 	if err == nil {
@@ -1380,6 +1574,102 @@ func checkUploadCutoff(cs fs.SizeSuffix) error {
 	return nil
 }
 
+// retainUntilFormats are the date formats object_lock_retain_until can be in
+var retainUntilFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseRetainUntil parses object_lock_retain_until returning the time
+// to retain an object uploaded at now until
+func parseRetainUntil(retainUntil string, now time.Time) (time.Time, error) {
+	for _, format := range retainUntilFormats {
+		if t, err := time.Parse(format, retainUntil); err == nil {
+			return t, nil
+		}
+	}
+	d, err := fs.ParseDuration(retainUntil)
+	if err != nil {
+		return time.Time{}, errors.Errorf("can't parse %q as a date or a duration", retainUntil)
+	}
+	return now.Add(d), nil
+}
+
+// parseVersionAt parses version_at returning the time to show the
+// bucket at
+func parseVersionAt(versionAt string, now time.Time) (time.Time, error) {
+	for _, format := range retainUntilFormats {
+		if t, err := time.Parse(format, versionAt); err == nil {
+			return t, nil
+		}
+	}
+	d, err := fs.ParseDuration(versionAt)
+	if err != nil {
+		return time.Time{}, errors.Errorf("can't parse %q as a date or a duration", versionAt)
+	}
+	return now.Add(-d), nil
+}
+
+// errNotWithVersionAt is returned when trying to change a remote with
+// version_at set
+var errNotWithVersionAt = errors.New("can't modify or delete files with version_at set")
+
+// checkObjectLock checks the object lock options make sense
+func checkObjectLock(opt *Options) error {
+	switch opt.ObjectLockMode {
+	case "":
+		if opt.ObjectLockRetainUntil != "" {
+			return errors.New("object_lock_retain_until needs object_lock_mode")
+		}
+		return nil
+	case s3.ObjectLockModeGovernance, s3.ObjectLockModeCompliance:
+	default:
+		return errors.Errorf("unknown object_lock_mode %q", opt.ObjectLockMode)
+	}
+	if opt.ObjectLockRetainUntil == "" {
+		return errors.New("object_lock_mode needs object_lock_retain_until")
+	}
+	now := time.Now()
+	retainUntil, err := parseRetainUntil(opt.ObjectLockRetainUntil, now)
+	if err != nil {
+		return err
+	}
+	if !retainUntil.After(now) {
+		return errors.Errorf("object_lock_retain_until %q must be in the future", opt.ObjectLockRetainUntil)
+	}
+	return nil
+}
+
+// usesObjectLock returns true if uploads set an object lock
+func (f *Fs) usesObjectLock() bool {
+	return f.opt.ObjectLockMode != "" || f.opt.ObjectLockLegalHold
+}
+
+// objectLock returns the object lock parameters to set on an object
+// uploaded now. They are nil if not in use.
+func (f *Fs) objectLock() (mode *string, retainUntil *time.Time, legalHold *string) {
+	if f.opt.ObjectLockMode != "" {
+		// Checked in NewFs so can't fail
+		t, _ := parseRetainUntil(f.opt.ObjectLockRetainUntil, time.Now())
+		mode, retainUntil = aws.String(f.opt.ObjectLockMode), &t
+	}
+	if f.opt.ObjectLockLegalHold {
+		legalHold = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+	return mode, retainUntil, legalHold
+}
+
+// objectLockError makes the error returned when S3 rejects an object
+// lock clearer
+func (f *Fs) objectLockError(bucket string, err error) error {
+	if err != nil && f.usesObjectLock() && strings.Contains(err.Error(), "Object Lock") {
+		return errors.Wrapf(err, "can't set object lock - check bucket %q has Object Lock enabled", bucket)
+	}
+	return err
+}
+
 func (f *Fs) setUploadCutoff(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
 	err = checkUploadCutoff(cs)
 	if err == nil {
@@ -1391,7 +1681,104 @@ func (f *Fs) setUploadCutoff(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
 // setRoot changes the root of the Fs
 func (f *Fs) setRoot(root string) {
 	f.root = parsePath(root)
-	f.rootBucket, f.rootDirectory = bucket.Split(f.root)
+	f.rootBucket, f.rootDirectory = splitBucket(f.root)
+}
+
+// sseCustomerKey is the SSE-C key for the objects under a prefix
+type sseCustomerKey struct {
+	prefix string // bucket and path the key is for
+	key    string // the raw key
+}
+
+// parseSSECustomerKeys parses lines of "prefix base64key"
+func parseSSECustomerKeys(in io.Reader) (keys []sseCustomerKey, err error) {
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("line %d: expecting prefix and key", lineNumber)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d: bad key", lineNumber)
+		}
+		if len(key) != 32 {
+			return nil, errors.Errorf("line %d: key must be 256 bits but is %d", lineNumber, 8*len(key))
+		}
+		keys = append(keys, sseCustomerKey{
+			prefix: strings.TrimLeft(fields[0], "/"),
+			key:    string(key),
+		})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	// Longest first so the first match is the best
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(keys[i].prefix) > len(keys[j].prefix)
+	})
+	return keys, nil
+}
+
+// readSSECustomerKeys reads the SSE-C keys from the key file and the
+// output of the key command
+func readSSECustomerKeys(opt *Options) (keys []sseCustomerKey, err error) {
+	var in []io.Reader
+	if opt.SSECustomerKeyFile != "" {
+		fh, err := os.Open(opt.SSECustomerKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open key file")
+		}
+		defer fs.CheckClose(fh, &err)
+		in = append(in, fh)
+	}
+	if len(opt.SSECustomerKeyCommand) != 0 {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(opt.SSECustomerKeyCommand[0], opt.SSECustomerKeyCommand[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, errors.Wrapf(err, "key command failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		// Make sure the output starts on a new line
+		in = append(in, strings.NewReader("\n"), &stdout)
+	}
+	if len(in) == 0 {
+		return nil, nil
+	}
+	return parseSSECustomerKeys(io.MultiReader(in...))
+}
+
+// sseCustomer returns the SSE-C parameters for the object at
+// bucketPath in bucket. They are nil if SSE-C isn't in use.
+//
+// The key MD5 is left for the SDK to work out when the key comes from
+// the key file or command.
+func (f *Fs) sseCustomer(bucket, bucketPath string) (algorithm, key, keyMD5 *string) {
+	fullPath := path.Join(bucket, bucketPath)
+	for _, sseKey := range f.sseKeys {
+		if strings.HasPrefix(fullPath, sseKey.prefix) {
+			algorithm = aws.String(f.opt.SSECustomerAlgorithm)
+			if *algorithm == "" {
+				algorithm = aws.String(s3.ServerSideEncryptionAes256)
+			}
+			return algorithm, aws.String(sseKey.key), nil
+		}
+	}
+	if f.opt.SSECustomerAlgorithm != "" {
+		algorithm = &f.opt.SSECustomerAlgorithm
+	}
+	if f.opt.SSECustomerKey != "" {
+		key = &f.opt.SSECustomerKey
+	}
+	if f.opt.SSECustomerKeyMD5 != "" {
+		keyMD5 = &f.opt.SSECustomerKeyMD5
+	}
+	return algorithm, key, keyMD5
 }
 
 // NewFs constructs an Fs from the path, bucket:path
@@ -1410,6 +1797,25 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "s3: upload cutoff")
 	}
+	err = checkCopyChunkSize(opt.CopyChunkSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3: copy chunk size")
+	}
+	err = checkObjectLock(opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3: object lock")
+	}
+	sseKeys, err := readSSECustomerKeys(opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3: SSE-C keys")
+	}
+	var versionAt time.Time
+	if opt.VersionAt != "" {
+		versionAt, err = parseVersionAt(opt.VersionAt, time.Now())
+		if err != nil {
+			return nil, errors.Wrap(err, "s3: version_at")
+		}
+	}
 	if opt.ACL == "" {
 		opt.ACL = "private"
 	}
@@ -1435,9 +1841,15 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 			opt.UploadConcurrency*fs.Config.Transfers,
 			opt.MemoryPoolUseMmap,
 		),
+		sseKeys:   sseKeys,
+		versionAt: versionAt,
 	}
 
 	f.setRoot(root)
+	err = checkAccessPoint(opt, f.rootBucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3")
+	}
 	f.features = (&fs.Features{
 		ReadMimeType:      true,
 		WriteMimeType:     true,
@@ -1454,6 +1866,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 			Bucket: &f.rootBucket,
 			Key:    &encodedDirectory,
 		}
+		req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = f.sseCustomer(f.rootBucket, encodedDirectory)
 		err = f.pacer.Call(func() (bool, error) {
 			_, err = f.c.HeadObject(&req)
 			return f.shouldRetry(err)
@@ -1475,10 +1888,11 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 // Return an Object from a path
 //
 //If it can't be found it returns the error ErrorObjectNotFound.
-func (f *Fs) newObjectWithInfo(ctx context.Context, remote string, info *s3.Object) (fs.Object, error) {
+func (f *Fs) newObjectWithInfo(ctx context.Context, remote string, info *s3.Object, versionID *string) (fs.Object, error) {
 	o := &Object{
-		fs:     f,
-		remote: remote,
+		fs:        f,
+		remote:    remote,
+		versionID: versionID,
 	}
 	if info != nil {
 		// Set info but not meta
@@ -1503,7 +1917,7 @@ func (f *Fs) newObjectWithInfo(ctx context.Context, remote string, info *s3.Obje
 // NewObject finds the Object at remote.  If it can't be found
 // it returns the error fs.ErrorObjectNotFound.
 func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
-	return f.newObjectWithInfo(ctx, remote, nil)
+	return f.newObjectWithInfo(ctx, remote, nil, nil)
 }
 
 // Gets the bucket location
@@ -1551,8 +1965,9 @@ func (f *Fs) updateRegionForBucket(bucket string) error {
 	return nil
 }
 
-// listFn is called from list to handle an object.
-type listFn func(remote string, object *s3.Object, isDirectory bool) error
+// listFn is called from list to handle an object. versionID is the
+// version of the object to read if set.
+type listFn func(remote string, object *s3.Object, versionID *string, isDirectory bool) error
 
 // list lists the objects into the function supplied from
 // the bucket and directory supplied.  The remote has prefix
@@ -1571,6 +1986,15 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 	if !recurse {
 		delimiter = "/"
 	}
+	if !f.versionAt.IsZero() {
+		return f.listVersionsAt(ctx, bucket, directory, prefix, delimiter, addBucket, fn)
+	}
+	if recurse && f.opt.Inventory != "" {
+		ok, err := f.listInventory(ctx, bucket, directory, prefix, addBucket, fn)
+		if ok || err != nil {
+			return err
+		}
+	}
 	var marker *string
 	// URL encode the listings so we can use control characters in object names
 	// See: https://github.com/aws/aws-sdk-go/issues/1914
@@ -1664,7 +2088,7 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 				if strings.HasSuffix(remote, "/") {
 					remote = remote[:len(remote)-1]
 				}
-				err = fn(remote, &s3.Object{Key: &remote}, true)
+				err = fn(remote, &s3.Object{Key: &remote}, nil, true)
 				if err != nil {
 					return err
 				}
@@ -1693,7 +2117,7 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 			if isDirectory && object.Size != nil && *object.Size == 0 {
 				continue // skip directory marker
 			}
-			err = fn(remote, object, false)
+			err = fn(remote, object, nil, false)
 			if err != nil {
 				return err
 			}
@@ -1720,8 +2144,190 @@ func (f *Fs) list(ctx context.Context, bucket, directory, prefix string, addBuck
 	return nil
 }
 
+// objectVersion is an object version or delete marker from a
+// ListObjectVersions listing
+type objectVersion struct {
+	key          string
+	lastModified time.Time
+	versionID    *string
+	object       *s3.Object // nil for a delete marker
+}
+
+// sortVersions sorts versions by key then newest first
+func sortVersions(versions []objectVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.lastModified.After(b.lastModified)
+	})
+}
+
+// listVersionsAt lists the objects as they were at f.versionAt for
+// list. directory and prefix should end in "/" if set.
+//
+// The object versions are listed and the newest version of each
+// object from before f.versionAt used, unless that is a delete
+// marker. Directories are shown if they contain any versions.
+func (f *Fs) listVersionsAt(ctx context.Context, bucket, directory, prefix, delimiter string, addBucket bool, fn listFn) error {
+	var urlEncodeListings = (f.opt.Provider == "AWS" || f.opt.Provider == "Wasabi" || f.opt.Provider == "Alibaba" || f.opt.Provider == "Minio")
+	// toRemote converts a key in the listing into a remote
+	toRemote := func(key string) (remote string, ok bool) {
+		remote = key
+		if urlEncodeListings {
+			var err error
+			remote, err = url.QueryUnescape(remote)
+			if err != nil {
+				fs.Logf(f, "failed to URL decode %q in listing: %v", key, err)
+				return "", false
+			}
+		}
+		remote = f.opt.Enc.ToStandardPath(remote)
+		if !strings.HasPrefix(remote, prefix) {
+			fs.Logf(f, "Odd name received %q", remote)
+			return "", false
+		}
+		remote = remote[len(prefix):]
+		if addBucket {
+			remote = path.Join(bucket, remote)
+		}
+		return remote, true
+	}
+	var (
+		keyMarker       *string
+		versionIDMarker *string
+		decidedKey      *string // the key whose version has been found
+	)
+	for {
+		req := s3.ListObjectVersionsInput{
+			Bucket:          &bucket,
+			Delimiter:       &delimiter,
+			Prefix:          &directory,
+			MaxKeys:         &f.opt.ListChunk,
+			KeyMarker:       keyMarker,
+			VersionIdMarker: versionIDMarker,
+		}
+		if urlEncodeListings {
+			req.EncodingType = aws.String(s3.EncodingTypeUrl)
+		}
+		var resp *s3.ListObjectVersionsOutput
+		err := f.pacer.Call(func() (bool, error) {
+			var err error
+			resp, err = f.c.ListObjectVersionsWithContext(ctx, &req)
+			return f.shouldRetry(err)
+		})
+		if err != nil {
+			if awsErr, ok := err.(awserr.RequestFailure); ok {
+				if awsErr.StatusCode() == http.StatusNotFound {
+					err = fs.ErrorDirNotFound
+				}
+			}
+			return err
+		}
+		if delimiter != "" {
+			for _, commonPrefix := range resp.CommonPrefixes {
+				remote, ok := toRemote(aws.StringValue(commonPrefix.Prefix))
+				if !ok {
+					continue
+				}
+				remote = strings.TrimSuffix(remote, "/")
+				err = fn(remote, &s3.Object{Key: &remote}, nil, true)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		versions := make([]objectVersion, 0, len(resp.Versions)+len(resp.DeleteMarkers))
+		for _, version := range resp.Versions {
+			object := &s3.Object{
+				Key:          version.Key,
+				LastModified: version.LastModified,
+				ETag:         version.ETag,
+				Size:         version.Size,
+				StorageClass: version.StorageClass,
+				Owner:        version.Owner,
+			}
+			versions = append(versions, objectVersion{
+				key:          aws.StringValue(version.Key),
+				lastModified: aws.TimeValue(version.LastModified),
+				versionID:    version.VersionId,
+				object:       object,
+			})
+		}
+		for _, marker := range resp.DeleteMarkers {
+			versions = append(versions, objectVersion{
+				key:          aws.StringValue(marker.Key),
+				lastModified: aws.TimeValue(marker.LastModified),
+				versionID:    marker.VersionId,
+			})
+		}
+		sortVersions(versions)
+		for _, version := range versions {
+			if decidedKey != nil && *decidedKey == version.key {
+				continue
+			}
+			if version.lastModified.After(f.versionAt) {
+				continue
+			}
+			key := version.key
+			decidedKey = &key
+			if version.object == nil {
+				continue // deleted at versionAt
+			}
+			remote, ok := toRemote(key)
+			if !ok {
+				continue
+			}
+			isDirectory := remote == "" || strings.HasSuffix(remote, "/")
+			if isDirectory && aws.Int64Value(version.object.Size) == 0 {
+				continue // skip directory marker
+			}
+			err = fn(remote, version.object, version.versionID, false)
+			if err != nil {
+				return err
+			}
+		}
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+		keyMarker, versionIDMarker = resp.NextKeyMarker, resp.NextVersionIdMarker
+		if urlEncodeListings && keyMarker != nil {
+			marker, err := url.QueryUnescape(*keyMarker)
+			if err != nil {
+				return errors.Wrapf(err, "failed to URL decode NextKeyMarker %q", *keyMarker)
+			}
+			keyMarker = &marker
+		}
+	}
+	return nil
+}
+
+// findVersionAt finds the version of the object at bucketPath in
+// bucket at f.versionAt
+func (f *Fs) findVersionAt(ctx context.Context, bucket, bucketPath string) (versionID *string, err error) {
+	remote := f.opt.Enc.ToStandardPath(bucketPath)
+	found := false
+	// Objects are listed in key order so the object will be first
+	// if it exists
+	errStop := errors.New("stop listing")
+	err = f.listVersionsAt(ctx, bucket, bucketPath, "", "", false, func(entryRemote string, object *s3.Object, entryVersionID *string, isDirectory bool) error {
+		if entryRemote == remote {
+			versionID, found = entryVersionID, true
+		}
+		return errStop
+	})
+	if err != nil && err != errStop && err != fs.ErrorDirNotFound {
+		return nil, err
+	}
+	if !found {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return versionID, nil
+}
+
 // Convert a list item into a DirEntry
-func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *s3.Object, isDirectory bool) (fs.DirEntry, error) {
+func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *s3.Object, versionID *string, isDirectory bool) (fs.DirEntry, error) {
 	if isDirectory {
 		size := int64(0)
 		if object.Size != nil {
@@ -1730,7 +2336,7 @@ func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *s3.Objec
 		d := fs.NewDir(remote, time.Time{}).SetSize(size)
 		return d, nil
 	}
-	o, err := f.newObjectWithInfo(ctx, remote, object)
+	o, err := f.newObjectWithInfo(ctx, remote, object, versionID)
 	if err != nil {
 		return nil, err
 	}
@@ -1740,8 +2346,8 @@ func (f *Fs) itemToDirEntry(ctx context.Context, remote string, object *s3.Objec
 // listDir lists files and directories to out
 func (f *Fs) listDir(ctx context.Context, bucket, directory, prefix string, addBucket bool) (entries fs.DirEntries, err error) {
 	// List the objects and directories
-	err = f.list(ctx, bucket, directory, prefix, addBucket, false, func(remote string, object *s3.Object, isDirectory bool) error {
-		entry, err := f.itemToDirEntry(ctx, remote, object, isDirectory)
+	err = f.list(ctx, bucket, directory, prefix, addBucket, false, func(remote string, object *s3.Object, versionID *string, isDirectory bool) error {
+		entry, err := f.itemToDirEntry(ctx, remote, object, versionID, isDirectory)
 		if err != nil {
 			return err
 		}
@@ -1818,8 +2424,8 @@ func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (
 	bucket, directory := f.split(dir)
 	list := walk.NewListRHelper(callback)
 	listR := func(bucket, directory, prefix string, addBucket bool) error {
-		return f.list(ctx, bucket, directory, prefix, addBucket, true, func(remote string, object *s3.Object, isDirectory bool) error {
-			entry, err := f.itemToDirEntry(ctx, remote, object, isDirectory)
+		return f.list(ctx, bucket, directory, prefix, addBucket, true, func(remote string, object *s3.Object, versionID *string, isDirectory bool) error {
+			entry, err := f.itemToDirEntry(ctx, remote, object, versionID, isDirectory)
 			if err != nil {
 				return err
 			}
@@ -1894,13 +2500,16 @@ func (f *Fs) bucketExists(ctx context.Context, bucket string) (bool, error) {
 
 // Mkdir creates the bucket if it doesn't exist
 func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	if !f.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
 	bucket, _ := f.split(dir)
 	return f.makeBucket(ctx, bucket)
 }
 
 // makeBucket creates the bucket if it doesn't exist
 func (f *Fs) makeBucket(ctx context.Context, bucket string) error {
-	if f.opt.NoCheckBucket {
+	if f.opt.NoCheckBucket || isAccessPointARN(bucket) {
 		return nil
 	}
 	return f.cache.Create(bucket, func() error {
@@ -1935,8 +2544,11 @@ func (f *Fs) makeBucket(ctx context.Context, bucket string) error {
 //
 // Returns an error if it isn't empty
 func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	if !f.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
 	bucket, directory := f.split(dir)
-	if bucket == "" || directory != "" {
+	if bucket == "" || directory != "" || isAccessPointARN(bucket) {
 		return nil
 	}
 	return f.cache.Remove(bucket, func() error {
@@ -1965,6 +2577,21 @@ func pathEscape(s string) string {
 	return strings.Replace(rest.URLPathEscape(s), "+", "%2B", -1)
 }
 
+// copySource returns the CopySource for a server side copy of
+// srcPath in srcBucket, of the version with versionID if set
+func copySource(srcBucket, srcPath string, versionID *string) string {
+	var source string
+	if isAccessPointARN(srcBucket) {
+		source = srcBucket + "/object/" + pathEscape(srcPath)
+	} else {
+		source = pathEscape(path.Join(srcBucket, srcPath))
+	}
+	if versionID != nil {
+		source += "?versionId=" + url.QueryEscape(*versionID)
+	}
+	return source
+}
+
 // copy does a server side copy
 //
 // It adds the boiler plate to the req passed in and calls the s3
@@ -1973,7 +2600,14 @@ func (f *Fs) copy(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPa
 	req.Bucket = &dstBucket
 	req.ACL = &f.opt.ACL
 	req.Key = &dstPath
-	source := pathEscape(path.Join(srcBucket, srcPath))
+	if !src.fs.versionAt.IsZero() && src.versionID == nil {
+		versionID, err := src.fs.findVersionAt(ctx, srcBucket, srcPath)
+		if err != nil {
+			return err
+		}
+		src.versionID = versionID
+	}
+	source := copySource(srcBucket, srcPath, src.versionID)
 	req.CopySource = &source
 	if f.opt.ServerSideEncryption != "" {
 		req.ServerSideEncryption = &f.opt.ServerSideEncryption
@@ -1984,14 +2618,19 @@ func (f *Fs) copy(ctx context.Context, req *s3.CopyObjectInput, dstBucket, dstPa
 	if req.StorageClass == nil && f.opt.StorageClass != "" {
 		req.StorageClass = &f.opt.StorageClass
 	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = f.sseCustomer(dstBucket, dstPath)
+	req.CopySourceSSECustomerAlgorithm, req.CopySourceSSECustomerKey, req.CopySourceSSECustomerKeyMD5 = src.fs.sseCustomer(srcBucket, srcPath)
+	if req.ObjectLockMode == nil && req.ObjectLockLegalHoldStatus == nil {
+		req.ObjectLockMode, req.ObjectLockRetainUntilDate, req.ObjectLockLegalHoldStatus = f.objectLock()
+	}
 
 	if src.bytes >= int64(f.opt.CopyCutoff) {
-		return f.copyMultipart(ctx, req, dstBucket, dstPath, srcBucket, srcPath, src)
+		return f.objectLockError(dstBucket, f.copyMultipart(ctx, req, dstBucket, dstPath, srcBucket, srcPath, src))
 	}
-	return f.pacer.Call(func() (bool, error) {
+	return f.objectLockError(dstBucket, f.pacer.Call(func() (bool, error) {
 		_, err := f.c.CopyObjectWithContext(ctx, req)
 		return f.shouldRetry(err)
-	})
+	}))
 }
 
 func calculateRange(partSize, partIndex, numParts, totalSize int64) string {
@@ -2007,6 +2646,27 @@ func calculateRange(partSize, partIndex, numParts, totalSize int64) string {
 	return fmt.Sprintf("bytes=%v-%v", start, ends)
 }
 
+// copyPartSize returns the size of the parts to copy an object of
+// size in
+func (f *Fs) copyPartSize(size int64) int64 {
+	partSize := int64(f.opt.CopyChunkSize)
+	if partSize <= 0 {
+		partSize = int64(f.opt.CopyCutoff)
+	}
+	uploadParts := f.opt.MaxUploadParts
+	if uploadParts < 1 {
+		uploadParts = 1
+	} else if uploadParts > maxUploadParts {
+		uploadParts = maxUploadParts
+	}
+	// Adjust partSize until the number of parts is small enough.
+	if (size-1)/partSize+1 > uploadParts {
+		// Calculate partition size rounded up to the nearest MB
+		partSize = (((size / uploadParts) >> 20) + 1) << 20
+	}
+	return partSize
+}
+
 func (f *Fs) copyMultipart(ctx context.Context, copyReq *s3.CopyObjectInput, dstBucket, dstPath, srcBucket, srcPath string, src *Object) (err error) {
 	info, err := src.headObject(ctx)
 	if err != nil {
@@ -2055,36 +2715,63 @@ func (f *Fs) copyMultipart(ctx context.Context, copyReq *s3.CopyObjectInput, dst
 	})()
 
 	srcSize := src.bytes
-	partSize := int64(f.opt.CopyCutoff)
+	partSize := f.copyPartSize(srcSize)
 	numParts := (srcSize-1)/partSize + 1
+	concurrency := f.opt.CopyConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	tokens := pacer.NewTokenDispenser(concurrency)
 
-	fs.Debugf(src, "Starting  multipart copy with %d parts", numParts)
+	fs.Debugf(src, "Starting  multipart copy with %d parts of size %v", numParts, fs.SizeSuffix(partSize))
 
-	var parts []*s3.CompletedPart
+	var (
+		g, gCtx = errgroup.WithContext(ctx)
+		partsMu sync.Mutex // to protect parts
+		parts   []*s3.CompletedPart
+	)
 	for partNum := int64(1); partNum <= numParts; partNum++ {
-		if err := f.pacer.Call(func() (bool, error) {
-			partNum := partNum
-			uploadPartReq := &s3.UploadPartCopyInput{}
-			structs.SetFrom(uploadPartReq, copyReq)
-			uploadPartReq.Bucket = &dstBucket
-			uploadPartReq.Key = &dstPath
-			uploadPartReq.PartNumber = &partNum
-			uploadPartReq.UploadId = uid
-			uploadPartReq.CopySourceRange = aws.String(calculateRange(partSize, partNum-1, numParts, srcSize))
-			uout, err := f.c.UploadPartCopyWithContext(ctx, uploadPartReq)
-			if err != nil {
-				return f.shouldRetry(err)
-			}
-			parts = append(parts, &s3.CompletedPart{
-				PartNumber: &partNum,
-				ETag:       uout.CopyPartResult.ETag,
-			})
-			return false, nil
-		}); err != nil {
-			return err
+		tokens.Get()
+		// Fail fast if a part has failed
+		if gCtx.Err() != nil {
+			tokens.Put()
+			break
 		}
+		partNum := partNum
+		g.Go(func() error {
+			defer tokens.Put()
+			return f.pacer.Call(func() (bool, error) {
+				uploadPartReq := &s3.UploadPartCopyInput{}
+				structs.SetFrom(uploadPartReq, copyReq)
+				uploadPartReq.Bucket = &dstBucket
+				uploadPartReq.Key = &dstPath
+				uploadPartReq.PartNumber = &partNum
+				uploadPartReq.UploadId = uid
+				uploadPartReq.CopySourceRange = aws.String(calculateRange(partSize, partNum-1, numParts, srcSize))
+				uout, err := f.c.UploadPartCopyWithContext(gCtx, uploadPartReq)
+				if err != nil {
+					return f.shouldRetry(err)
+				}
+				partsMu.Lock()
+				parts = append(parts, &s3.CompletedPart{
+					PartNumber: &partNum,
+					ETag:       uout.CopyPartResult.ETag,
+				})
+				partsMu.Unlock()
+				return false, nil
+			})
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		return err
 	}
 
+	// sort the completed parts by part number
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
 	return f.pacer.Call(func() (bool, error) {
 		_, err := f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
 			Bucket: &dstBucket,
@@ -2109,6 +2796,9 @@ func (f *Fs) copyMultipart(ctx context.Context, copyReq *s3.CopyObjectInput, dst
 //
 // If it isn't possible then return fs.ErrorCantCopy
 func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	if !f.versionAt.IsZero() {
+		return nil, errNotWithVersionAt
+	}
 	dstBucket, dstPath := f.split(remote)
 	err := f.makeBucket(ctx, dstBucket)
 	if err != nil {
@@ -2464,6 +3154,9 @@ func (f *Fs) cleanUp(ctx context.Context, maxAge time.Duration) (err error) {
 
 // CleanUp removes all pending multipart uploads older than 24 hours
 func (f *Fs) CleanUp(ctx context.Context) (err error) {
+	if !f.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
 	return f.cleanUp(ctx, 24*time.Hour)
 }
 
@@ -2526,6 +3219,14 @@ func (o *Object) headObject(ctx context.Context) (resp *s3.HeadObjectOutput, err
 		Bucket: &bucket,
 		Key:    &bucketPath,
 	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer(bucket, bucketPath)
+	if !o.fs.versionAt.IsZero() && o.versionID == nil {
+		o.versionID, err = o.fs.findVersionAt(ctx, bucket, bucketPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req.VersionId = o.versionID
 	err = o.fs.pacer.Call(func() (bool, error) {
 		var err error
 		resp, err = o.fs.c.HeadObjectWithContext(ctx, &req)
@@ -2574,9 +3275,44 @@ func (o *Object) readMetaData(ctx context.Context) (err error) {
 		o.lastModified = *resp.LastModified
 	}
 	o.mimeType = aws.StringValue(resp.ContentType)
+	o.lockMode = aws.StringValue(resp.ObjectLockMode)
+	o.lockUntil = aws.TimeValue(resp.ObjectLockRetainUntilDate)
+	o.lockHold = aws.StringValue(resp.ObjectLockLegalHoldStatus) == s3.ObjectLockLegalHoldStatusOn
+	return nil
+}
+
+// lockError returns a non retryable error if the Object Lock read with
+// the metadata would stop the object being overwritten or deleted at
+// now
+func (o *Object) lockError(now time.Time) error {
+	if o.lockHold {
+		return fserrors.NoRetryError(errors.New("can't overwrite or delete object with a legal hold"))
+	}
+	if o.lockMode != "" && o.lockUntil.After(now) {
+		return fserrors.NoRetryError(errors.Errorf("can't overwrite or delete object locked in %s mode until %v", o.lockMode, o.lockUntil))
+	}
 	return nil
 }
 
+// checkNotLocked returns a non retryable error if the object has a
+// legal hold or a retention period which hasn't expired.
+//
+// This is only checked if the remote uses Object Lock to save a HEAD
+// request for each object otherwise.
+func (o *Object) checkNotLocked(ctx context.Context) error {
+	if !o.fs.usesObjectLock() {
+		return nil
+	}
+	err := o.readMetaData(ctx)
+	if err == fs.ErrorObjectNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return o.lockError(time.Now())
+}
+
 // ModTime returns the modification time of the object
 //
 // It attempts to read the objects mtime and if that isn't present the
@@ -2606,6 +3342,9 @@ func (o *Object) ModTime(ctx context.Context) time.Time {
 
 // SetModTime sets the modification time of the local fs object
 func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	if !o.fs.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
 	err := o.readMetaData(ctx)
 	if err != nil {
 		return err
@@ -2627,6 +3366,79 @@ func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
 	return o.fs.copy(ctx, &req, bucket, bucketPath, bucket, bucketPath, o)
 }
 
+// Metadata returns the user metadata of the object with the
+// modification time and content type translated to the standard keys
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata := make(fs.Metadata, len(o.meta)+2)
+	for k, v := range o.meta {
+		if k == metaMtime || v == nil {
+			continue
+		}
+		metadata[strings.ToLower(k)] = *v
+	}
+	metadata.SetTime(fs.MetadataMtime, o.ModTime(ctx))
+	if o.mimeType != "" {
+		metadata[fs.MetadataContentType] = o.mimeType
+	}
+	return metadata, nil
+}
+
+// SetMetadata stores the metadata as the user metadata of the object,
+// translating the modification time and content type to their S3
+// equivalents
+//
+// Other keys are kept as they are so the total size of the metadata
+// must fit in the 2KB S3 allows.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	if !o.fs.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return err
+	}
+	// Can't update metadata here
+	if o.storageClass == "GLACIER" || o.storageClass == "DEEP_ARCHIVE" {
+		return errors.Errorf("can't set metadata on object in %s", o.storageClass)
+	}
+	meta := make(map[string]*string, len(o.meta)+len(metadata))
+	for k, v := range o.meta {
+		meta[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	contentType := fs.MimeType(ctx, o)
+	for k, v := range metadata {
+		switch k {
+		case fs.MetadataMtime:
+			if modTime, ok := metadata.Time(k); ok {
+				meta[metaMtime] = aws.String(swift.TimeToFloatString(modTime))
+			}
+		case fs.MetadataContentType:
+			contentType = v
+		default:
+			meta[textproto.CanonicalMIMEHeaderKey(k)] = aws.String(v)
+		}
+	}
+
+	// Copy the object to itself to update the metadata
+	bucket, bucketPath := o.split()
+	req := s3.CopyObjectInput{
+		ContentType:       aws.String(contentType),
+		Metadata:          meta,
+		MetadataDirective: aws.String(s3.MetadataDirectiveReplace), // replace metadata with that passed in
+	}
+	err = o.fs.copy(ctx, &req, bucket, bucketPath, bucket, bucketPath, o)
+	if err != nil {
+		return err
+	}
+	o.meta = meta
+	o.mimeType = contentType
+	return nil
+}
+
 // Storable raturns a boolean indicating if this object is storable
 func (o *Object) Storable() bool {
 	return true
@@ -2636,18 +3448,11 @@ func (o *Object) Storable() bool {
 func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
 	bucket, bucketPath := o.split()
 	req := s3.GetObjectInput{
-		Bucket: &bucket,
-		Key:    &bucketPath,
-	}
-	if o.fs.opt.SSECustomerAlgorithm != "" {
-		req.SSECustomerAlgorithm = &o.fs.opt.SSECustomerAlgorithm
-	}
-	if o.fs.opt.SSECustomerKey != "" {
-		req.SSECustomerKey = &o.fs.opt.SSECustomerKey
-	}
-	if o.fs.opt.SSECustomerKeyMD5 != "" {
-		req.SSECustomerKeyMD5 = &o.fs.opt.SSECustomerKeyMD5
+		Bucket:    &bucket,
+		Key:       &bucketPath,
+		VersionId: o.versionID,
 	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer(bucket, bucketPath)
 	httpReq, resp := o.fs.c.GetObjectRequest(&req)
 	fs.FixRangeOption(options, o.bytes)
 	for _, option := range options {
@@ -2683,25 +3488,72 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 
 var warnStreamUpload sync.Once
 
-func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader) (err error) {
-	f := o.fs
-
-	// make concurrency machinery
-	concurrency := f.opt.UploadConcurrency
-	if concurrency < 1 {
-		concurrency = 1
+// listParts returns the ETags of the parts already uploaded to the
+// multipart upload uid indexed by part number
+func (f *Fs) listParts(ctx context.Context, req *s3.PutObjectInput, uid *string) (etags map[int64]string, err error) {
+	etags = make(map[int64]string)
+	listReq := s3.ListPartsInput{
+		Bucket:       req.Bucket,
+		Key:          req.Key,
+		UploadId:     uid,
+		RequestPayer: req.RequestPayer,
 	}
-	tokens := pacer.NewTokenDispenser(concurrency)
+	for {
+		var resp *s3.ListPartsOutput
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err = f.c.ListPartsWithContext(ctx, &listReq)
+			return f.shouldRetry(err)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range resp.Parts {
+			etags[aws.Int64Value(part.PartNumber)] = aws.StringValue(part.ETag)
+		}
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+		listReq.PartNumberMarker = resp.NextPartNumberMarker
+	}
+	return etags, nil
+}
 
+// uploadParts returns the most parts a multipart upload may have
+func (f *Fs) uploadParts() int64 {
 	uploadParts := f.opt.MaxUploadParts
 	if uploadParts < 1 {
 		uploadParts = 1
 	} else if uploadParts > maxUploadParts {
 		uploadParts = maxUploadParts
 	}
+	return uploadParts
+}
 
-	// calculate size of parts
+// partSize returns the size of the parts for a multipart upload of
+// size bytes, which is --s3-chunk-size if size is -1
+func (f *Fs) partSize(size int64) int {
 	partSize := int(f.opt.ChunkSize)
+	uploadParts := f.uploadParts()
+	// Adjust partSize until the number of parts is small enough.
+	if size >= 0 && size/int64(partSize) >= uploadParts {
+		// Calculate partition size rounded up to the nearest MB
+		partSize = int((((size / uploadParts) >> 20) + 1) << 20)
+	}
+	return partSize
+}
+
+func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, size int64, in io.Reader, src fs.ObjectInfo) (err error) {
+	f := o.fs
+
+	// make concurrency machinery
+	concurrency := f.opt.UploadConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	tokens := pacer.NewTokenDispenser(concurrency)
+
+	// calculate size of parts
+	partSize := f.partSize(size)
 
 	// size can be -1 here meaning we don't know the size of the incoming file.  We use ChunkSize
 	// buffers here (default 5MB). With a maximum number of parts (10,000) this will be a file of
@@ -2709,33 +3561,48 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	if size == -1 {
 		warnStreamUpload.Do(func() {
 			fs.Logf(f, "Streaming uploads using chunk size %v will have maximum file size of %v",
-				f.opt.ChunkSize, fs.SizeSuffix(int64(partSize)*uploadParts))
+				f.opt.ChunkSize, fs.SizeSuffix(int64(partSize)*f.uploadParts()))
 		})
-	} else {
-		// Adjust partSize until the number of parts is small enough.
-		if size/int64(partSize) >= uploadParts {
-			// Calculate partition size rounded up to the nearest MB
-			partSize = int((((size / uploadParts) >> 20) + 1) << 20)
-		}
 	}
 
 	memPool := f.getMemoryPool(int64(partSize))
 
-	var mReq s3.CreateMultipartUploadInput
-	structs.SetFrom(&mReq, req)
-	var cout *s3.CreateMultipartUploadOutput
-	err = f.pacer.Call(func() (bool, error) {
-		var err error
-		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
-		return f.shouldRetry(err)
-	})
-	if err != nil {
-		return errors.Wrap(err, "multipart upload failed to initialise")
+	// See if we can resume a previous upload
+	var (
+		uid       *string
+		doneParts map[int64]string
+		resumer   = resume.New(ctx, f, o.remote, src)
+	)
+	if resumeID, ok := resumer.Resume(int64(partSize)); ok {
+		doneParts, err = f.listParts(ctx, req, &resumeID)
+		if err != nil {
+			fs.Debugf(o, "Can't resume multipart upload - starting again: %v", err)
+			resumer.Done()
+		} else {
+			uid = &resumeID
+		}
+	}
+
+	if uid == nil {
+		var mReq s3.CreateMultipartUploadInput
+		structs.SetFrom(&mReq, req)
+		var cout *s3.CreateMultipartUploadOutput
+		err = f.pacer.Call(func() (bool, error) {
+			var err error
+			cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
+			return f.shouldRetry(err)
+		})
+		if err != nil {
+			return errors.Wrap(err, "multipart upload failed to initialise")
+		}
+		uid = cout.UploadId
+		resumer.Start(aws.StringValue(uid), int64(partSize))
 	}
-	uid := cout.UploadId
 
 	defer atexit.OnError(&err, func() {
-		if o.fs.opt.LeavePartsOnError {
+		// Leave the parts for the next attempt if resuming
+		retry, _ := f.shouldRetry(errors.Cause(err))
+		if resumer.Keep(err, retry) || o.fs.opt.LeavePartsOnError {
 			return
 		}
 		fs.Debugf(o, "Cancelling multipart upload")
@@ -2764,7 +3631,12 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	for partNum := int64(1); !finished; partNum++ {
 		// Get a block of memory from the pool and token which limits concurrency.
 		tokens.Get()
-		buf := memPool.Get()
+		buf, getErr := memPool.GetContext(gCtx)
+		if getErr != nil {
+			// gCtx was cancelled while waiting for memory
+			tokens.Put()
+			break
+		}
 
 		free := func() {
 			// return the memory and token
@@ -2794,13 +3666,25 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 		}
 		buf = buf[:n]
 
-		partNum := partNum
+		partNum, partOff := partNum, off
 		fs.Debugf(o, "multipart upload starting chunk %d size %v offset %v/%v", partNum, fs.SizeSuffix(n), fs.SizeSuffix(off), fs.SizeSuffix(size))
 		off += int64(n)
 		g.Go(func() (err error) {
 			defer free()
 			partLength := int64(len(buf))
 
+			// skip the part if a previous attempt uploaded it
+			if etag, ok := resumer.Verify(partNum, buf); ok && doneParts[partNum] == etag {
+				fs.Debugf(o, "multipart upload skipping chunk %d already uploaded", partNum)
+				partsMu.Lock()
+				parts = append(parts, &s3.CompletedPart{
+					PartNumber: &partNum,
+					ETag:       aws.String(etag),
+				})
+				partsMu.Unlock()
+				return nil
+			}
+
 			// create checksum of buffer for integrity checking
 			md5sumBinary := md5.Sum(buf)
 			md5sum := base64.StdEncoding.EncodeToString(md5sumBinary[:])
@@ -2833,6 +3717,7 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 					ETag:       uout.ETag,
 				})
 				partsMu.Unlock()
+				resumer.AddPart(partNum, partOff, buf, aws.StringValue(uout.ETag))
 
 				return false, nil
 			})
@@ -2867,20 +3752,163 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	if err != nil {
 		return errors.Wrap(err, "multipart upload failed to finalise")
 	}
+	resumer.Done()
 	return nil
 }
 
-// Update the Object from in with modTime and size
-func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
-	bucket, bucketPath := o.split()
-	err := o.fs.makeBucket(ctx, bucket)
-	if err != nil {
-		return err
+// chunkWriter uploads the chunks written to it as the parts of a
+// multipart upload
+type chunkWriter struct {
+	f       *Fs
+	o       *Object
+	req     *s3.PutObjectInput
+	uid     *string
+	partsMu sync.Mutex // to protect parts
+	parts   []*s3.CompletedPart
+}
+
+// OpenChunkWriter returns a ChunkWriter which uploads the chunks of
+// the object at remote as the parts of a multipart upload
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (info fs.ChunkWriterInfo, writer fs.ChunkWriter, err error) {
+	if !f.versionAt.IsZero() {
+		return info, nil, errNotWithVersionAt
 	}
-	modTime := src.ModTime(ctx)
 	size := src.Size()
+	if size < 0 {
+		return info, nil, errors.New("can't write chunks of unknown sized object")
+	}
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	bucket, _ := o.split()
+	err = f.makeBucket(ctx, bucket)
+	if err != nil {
+		return info, nil, err
+	}
+	req, err := o.prepareUpload(ctx, src, options, true)
+	if err != nil {
+		return info, nil, err
+	}
+	var mReq s3.CreateMultipartUploadInput
+	structs.SetFrom(&mReq, req)
+	var cout *s3.CreateMultipartUploadOutput
+	err = f.pacer.Call(func() (bool, error) {
+		var err error
+		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
+		return f.shouldRetry(err)
+	})
+	if err != nil {
+		return info, nil, f.objectLockError(bucket, errors.Wrap(err, "multipart upload failed to initialise"))
+	}
+	info = fs.ChunkWriterInfo{
+		ChunkSize:   int64(f.partSize(size)),
+		Concurrency: f.opt.UploadConcurrency,
+	}
+	w := &chunkWriter{
+		f:   f,
+		o:   o,
+		req: req,
+		uid: cout.UploadId,
+	}
+	return info, w, nil
+}
 
-	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+// WriteChunk uploads chunk number chunkNumber as a part
+func (w *chunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error) {
+	partNum := int64(chunkNumber) + 1
+
+	// create checksum of the chunk for integrity checking
+	hasher := md5.New()
+	bytesWritten, err = io.Copy(hasher, reader)
+	if err != nil {
+		return 0, errors.Wrap(err, "multipart upload failed to read part")
+	}
+	md5sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+
+	var uout *s3.UploadPartOutput
+	err = w.f.pacer.Call(func() (bool, error) {
+		_, err := reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return false, err
+		}
+		uout, err = w.f.c.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Body:                 reader,
+			Bucket:               w.req.Bucket,
+			Key:                  w.req.Key,
+			PartNumber:           &partNum,
+			UploadId:             w.uid,
+			ContentMD5:           &md5sum,
+			ContentLength:        &bytesWritten,
+			RequestPayer:         w.req.RequestPayer,
+			SSECustomerAlgorithm: w.req.SSECustomerAlgorithm,
+			SSECustomerKey:       w.req.SSECustomerKey,
+			SSECustomerKeyMD5:    w.req.SSECustomerKeyMD5,
+		})
+		return w.f.shouldRetry(err)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "multipart upload failed to upload part")
+	}
+	w.partsMu.Lock()
+	w.parts = append(w.parts, &s3.CompletedPart{
+		PartNumber: &partNum,
+		ETag:       uout.ETag,
+	})
+	w.partsMu.Unlock()
+	return bytesWritten, nil
+}
+
+// Close completes the multipart upload
+func (w *chunkWriter) Close(ctx context.Context) error {
+	// sort the completed parts by part number
+	w.partsMu.Lock()
+	parts := w.parts
+	w.partsMu.Unlock()
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: w.req.Bucket,
+			Key:    w.req.Key,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: parts,
+			},
+			RequestPayer: w.req.RequestPayer,
+			UploadId:     w.uid,
+		})
+		return w.f.shouldRetry(err)
+	})
+	if err != nil {
+		return errors.Wrap(err, "multipart upload failed to finalise")
+	}
+	return nil
+}
+
+// Abort cancels the multipart upload, unless --s3-leave-parts-on-error
+// is set
+func (w *chunkWriter) Abort(ctx context.Context) error {
+	if w.f.opt.LeavePartsOnError {
+		return nil
+	}
+	fs.Debugf(w.o, "Cancelling multipart upload")
+	return w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:       w.req.Bucket,
+			Key:          w.req.Key,
+			UploadId:     w.uid,
+			RequestPayer: w.req.RequestPayer,
+		})
+		return w.f.shouldRetry(err)
+	})
+}
+
+// prepareUpload makes the request to upload src to o, applying the
+// upload options
+func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption, multipart bool) (*s3.PutObjectInput, error) {
+	bucket, bucketPath := o.split()
+	modTime := src.ModTime(ctx)
 
 	// Set the mtime in the meta data
 	metadata := map[string]*string{
@@ -2921,23 +3949,20 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if o.fs.opt.ServerSideEncryption != "" {
 		req.ServerSideEncryption = &o.fs.opt.ServerSideEncryption
 	}
-	if o.fs.opt.SSECustomerAlgorithm != "" {
-		req.SSECustomerAlgorithm = &o.fs.opt.SSECustomerAlgorithm
-	}
-	if o.fs.opt.SSECustomerKey != "" {
-		req.SSECustomerKey = &o.fs.opt.SSECustomerKey
-	}
-	if o.fs.opt.SSECustomerKeyMD5 != "" {
-		req.SSECustomerKeyMD5 = &o.fs.opt.SSECustomerKeyMD5
-	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey, req.SSECustomerKeyMD5 = o.fs.sseCustomer(bucket, bucketPath)
 	if o.fs.opt.SSEKMSKeyID != "" {
 		req.SSEKMSKeyId = &o.fs.opt.SSEKMSKeyID
 	}
 	if o.fs.opt.StorageClass != "" {
 		req.StorageClass = &o.fs.opt.StorageClass
 	}
+	req.ObjectLockMode, req.ObjectLockRetainUntilDate, req.ObjectLockLegalHoldStatus = o.fs.objectLock()
 	// Apply upload options
 	for _, option := range options {
+		if tierOption, ok := option.(*fs.TierOption); ok {
+			req.StorageClass = aws.String(strings.ToUpper(tierOption.Tier))
+			continue
+		}
 		key, value := option.Header()
 		lowerKey := strings.ToLower(key)
 		switch lowerKey {
@@ -2955,6 +3980,16 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			req.ContentType = aws.String(value)
 		case "x-amz-tagging":
 			req.Tagging = aws.String(value)
+		case "x-amz-object-lock-mode":
+			req.ObjectLockMode = aws.String(value)
+		case "x-amz-object-lock-retain-until-date":
+			retainUntil, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad %s", key)
+			}
+			req.ObjectLockRetainUntilDate = &retainUntil
+		case "x-amz-object-lock-legal-hold":
+			req.ObjectLockLegalHoldStatus = aws.String(value)
 		default:
 			const amzMetaPrefix = "x-amz-meta-"
 			if strings.HasPrefix(lowerKey, amzMetaPrefix) {
@@ -2965,16 +4000,55 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			}
 		}
 	}
+	return &req, nil
+}
 
-	if multipart {
-		err = o.uploadMultipart(ctx, &req, size, in)
+// Update the Object from in with modTime and size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	if !o.fs.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
+	// A new object from Put hasn't been read so doesn't exist yet
+	if !o.lastModified.IsZero() {
+		err := o.checkNotLocked(ctx)
 		if err != nil {
 			return err
 		}
+	}
+	bucket, _ := o.split()
+	err := o.fs.makeBucket(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	size := src.Size()
+
+	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+
+	// An upload setting an object lock must have a Content-MD5 so
+	// if the source doesn't have an MD5 use a multipart upload
+	// which sends one for each part.
+	if !multipart && o.fs.usesObjectLock() {
+		hash, err := src.Hash(ctx, hash.MD5)
+		if err != nil || !matchMd5.MatchString(hash) {
+			fs.Debugf(o, "Using multipart upload to set object lock as MD5 not known")
+			multipart = true
+		}
+	}
+
+	req, err := o.prepareUpload(ctx, src, options, multipart)
+	if err != nil {
+		return err
+	}
+
+	if multipart {
+		err = o.uploadMultipart(ctx, req, size, in, src)
+		if err != nil {
+			return o.fs.objectLockError(bucket, err)
+		}
 	} else {
 
 		// Create the request
-		putObj, _ := o.fs.c.PutObjectRequest(&req)
+		putObj, _ := o.fs.c.PutObjectRequest(req)
 
 		// Sign it so we can upload using a presigned request.
 		//
@@ -3021,7 +4095,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 			return fserrors.ShouldRetryHTTP(resp, retryErrorCodes), err
 		})
 		if err != nil {
-			return err
+			return o.fs.objectLockError(bucket, err)
 		}
 	}
 
@@ -3033,18 +4107,106 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 
 // Remove an object
 func (o *Object) Remove(ctx context.Context) error {
+	if !o.fs.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
+	err := o.checkNotLocked(ctx)
+	if err != nil {
+		return err
+	}
 	bucket, bucketPath := o.split()
 	req := s3.DeleteObjectInput{
 		Bucket: &bucket,
 		Key:    &bucketPath,
 	}
-	err := o.fs.pacer.Call(func() (bool, error) {
+	err = o.fs.pacer.Call(func() (bool, error) {
 		_, err := o.fs.c.DeleteObjectWithContext(ctx, &req)
 		return o.fs.shouldRetry(err)
 	})
 	return err
 }
 
+// maxDeleteObjects is the most keys DeleteObjects can delete at once
+const maxDeleteObjects = 1000
+
+// DeleteBatch deletes the objects passed in using DeleteObjects with
+// up to 1000 objects in each call
+func (f *Fs) DeleteBatch(ctx context.Context, objs []fs.Object) []error {
+	errs := make([]error, len(objs))
+	// Group the objects by bucket remembering where they came from
+	type key struct {
+		index int
+		path  string
+	}
+	var buckets []string
+	keys := make(map[string][]key)
+	for i, obj := range objs {
+		o, ok := obj.(*Object)
+		if !ok {
+			errs[i] = errors.Errorf("can't batch delete %T", obj)
+			continue
+		}
+		if !o.fs.versionAt.IsZero() {
+			errs[i] = errNotWithVersionAt
+			continue
+		}
+		if err := o.checkNotLocked(ctx); err != nil {
+			errs[i] = err
+			continue
+		}
+		bucket, bucketPath := o.split()
+		if _, found := keys[bucket]; !found {
+			buckets = append(buckets, bucket)
+		}
+		keys[bucket] = append(keys[bucket], key{index: i, path: bucketPath})
+	}
+	for _, bucket := range buckets {
+		bucket := bucket
+		bucketKeys := keys[bucket]
+		for len(bucketKeys) > 0 {
+			n := len(bucketKeys)
+			if n > maxDeleteObjects {
+				n = maxDeleteObjects
+			}
+			chunk := bucketKeys[:n]
+			bucketKeys = bucketKeys[n:]
+			req := s3.DeleteObjectsInput{
+				Bucket: &bucket,
+				Delete: &s3.Delete{
+					Quiet: aws.Bool(true),
+				},
+			}
+			index := make(map[string]int, len(chunk))
+			for _, k := range chunk {
+				req.Delete.Objects = append(req.Delete.Objects, &s3.ObjectIdentifier{Key: aws.String(k.path)})
+				index[k.path] = k.index
+			}
+			var resp *s3.DeleteObjectsOutput
+			err := f.pacer.Call(func() (bool, error) {
+				var err error
+				resp, err = f.c.DeleteObjectsWithContext(ctx, &req)
+				return f.shouldRetry(err)
+			})
+			if err != nil {
+				for _, k := range chunk {
+					errs[k.index] = err
+				}
+				continue
+			}
+			// In quiet mode only the failures are returned
+			for _, e := range resp.Errors {
+				i, found := index[aws.StringValue(e.Key)]
+				if !found {
+					fs.Debugf(f, "Unexpected key %q in DeleteObjects errors", aws.StringValue(e.Key))
+					continue
+				}
+				errs[i] = errors.Errorf("%s: %s", aws.StringValue(e.Code), aws.StringValue(e.Message))
+			}
+		}
+	}
+	return errs
+}
+
 // MimeType of an Object if known, "" otherwise
 func (o *Object) MimeType(ctx context.Context) string {
 	err := o.readMetaData(ctx)
@@ -3057,6 +4219,9 @@ func (o *Object) MimeType(ctx context.Context) string {
 
 // SetTier performs changing storage class
 func (o *Object) SetTier(tier string) (err error) {
+	if !o.fs.versionAt.IsZero() {
+		return errNotWithVersionAt
+	}
 	ctx := context.TODO()
 	tier = strings.ToUpper(tier)
 	bucket, bucketPath := o.split()
@@ -3082,14 +4247,18 @@ func (o *Object) GetTier() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Commander   = &Fs{}
-	_ fs.CleanUpper  = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs                = &Fs{}
+	_ fs.Copier            = &Fs{}
+	_ fs.PutStreamer       = &Fs{}
+	_ fs.ListRer           = &Fs{}
+	_ fs.Commander         = &Fs{}
+	_ fs.CleanUpper        = &Fs{}
+	_ fs.BatchDeleter      = &Fs{}
+	_ fs.OpenChunkWriterer = &Fs{}
+	_ fs.Object            = &Object{}
+	_ fs.MimeTyper         = &Object{}
+	_ fs.GetTierer         = &Object{}
+	_ fs.SetTierer         = &Object{}
+	_ fs.Metadataer        = &Object{}
+	_ fs.SetMetadataer     = &Object{}
 )
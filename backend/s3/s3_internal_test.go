@@ -0,0 +1,237 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetainUntil(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, test := range []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2030-01-01", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2030-01-01T12:00:00Z", time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"30d", now.Add(30 * 24 * time.Hour), false},
+		{"1h", now.Add(time.Hour), false},
+		{"potato", time.Time{}, true},
+	} {
+		got, err := parseRetainUntil(test.in, now)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.True(t, test.want.Equal(got), "%s: want %v got %v", test.in, test.want, got)
+	}
+}
+
+func TestCheckObjectLock(t *testing.T) {
+	for _, test := range []struct {
+		mode    string
+		until   string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"GOVERNANCE", "30d", false},
+		{"COMPLIANCE", "2999-01-01", false},
+		{"", "30d", true},
+		{"GOVERNANCE", "", true},
+		{"POTATO", "30d", true},
+		{"GOVERNANCE", "2000-01-01", true},
+		{"GOVERNANCE", "potato", true},
+	} {
+		err := checkObjectLock(&Options{ObjectLockMode: test.mode, ObjectLockRetainUntil: test.until})
+		assert.Equal(t, test.wantErr, err != nil, "%+v: %v", test, err)
+	}
+
+	f := &Fs{opt: Options{ObjectLockMode: "GOVERNANCE", ObjectLockRetainUntil: "1d", ObjectLockLegalHold: true}}
+	assert.True(t, f.usesObjectLock())
+	mode, retainUntil, legalHold := f.objectLock()
+	assert.Equal(t, "GOVERNANCE", *mode)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *retainUntil, time.Minute)
+	assert.Equal(t, "ON", *legalHold)
+
+	f = &Fs{}
+	assert.False(t, f.usesObjectLock())
+	mode, retainUntil, legalHold = f.objectLock()
+	assert.Nil(t, mode)
+	assert.Nil(t, retainUntil)
+	assert.Nil(t, legalHold)
+}
+
+func TestObjectLockError(t *testing.T) {
+	now := time.Now()
+	o := &Object{}
+	assert.NoError(t, o.lockError(now))
+
+	o.lockMode, o.lockUntil = "COMPLIANCE", now.Add(time.Hour)
+	err := o.lockError(now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "COMPLIANCE")
+	assert.True(t, fserrors.IsNoRetryError(err))
+
+	o.lockUntil = now.Add(-time.Hour)
+	assert.NoError(t, o.lockError(now))
+
+	o.lockHold = true
+	err = o.lockError(now)
+	require.Error(t, err)
+	assert.True(t, fserrors.IsNoRetryError(err))
+}
+
+func TestSSECustomerKeys(t *testing.T) {
+	key1 := strings.Repeat("1", 32)
+	key2 := strings.Repeat("2", 32)
+	in := "# keys\n\n" +
+		"bucket/ " + base64.StdEncoding.EncodeToString([]byte(key1)) + "\n" +
+		"bucket/private/ " + base64.StdEncoding.EncodeToString([]byte(key2)) + "\n"
+	keys, err := parseSSECustomerKeys(strings.NewReader(in))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "bucket/private/", keys[0].prefix)
+
+	f := &Fs{opt: Options{SSECustomerKey: "global"}, sseKeys: keys}
+	algorithm, key, keyMD5 := f.sseCustomer("bucket", "private/file.txt")
+	assert.Equal(t, "AES256", *algorithm)
+	assert.Equal(t, key2, *key)
+	assert.Nil(t, keyMD5)
+	_, key, _ = f.sseCustomer("bucket", "file.txt")
+	assert.Equal(t, key1, *key)
+	algorithm, key, _ = f.sseCustomer("other", "file.txt")
+	assert.Nil(t, algorithm)
+	assert.Equal(t, "global", *key)
+
+	for _, bad := range []string{
+		"bucket/",
+		"bucket/ potato!",
+		"bucket/ " + base64.StdEncoding.EncodeToString([]byte("short")),
+	} {
+		_, err := parseSSECustomerKeys(strings.NewReader(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSplitBucket(t *testing.T) {
+	const ap = "arn:aws:s3:us-west-2:123456789012:accesspoint/myap"
+	for _, test := range []struct {
+		in         string
+		bucket     string
+		bucketPath string
+	}{
+		{"", "", ""},
+		{"bucket", "bucket", ""},
+		{"bucket/path/file.txt", "bucket", "path/file.txt"},
+		{ap, ap, ""},
+		{ap + "/path/file.txt", ap, "path/file.txt"},
+	} {
+		bucket, bucketPath := splitBucket(test.in)
+		assert.Equal(t, test.bucket, bucket, test.in)
+		assert.Equal(t, test.bucketPath, bucketPath, test.in)
+	}
+	assert.True(t, isAccessPointARN(ap))
+	assert.False(t, isAccessPointARN("bucket"))
+
+	assert.Equal(t, "bucket/path/file.txt", copySource("bucket", "path/file.txt", nil))
+	assert.Equal(t, ap+"/object/path/file.txt", copySource(ap, "path/file.txt", nil))
+}
+
+func TestCopySourceVersion(t *testing.T) {
+	versionID := aws.String("3/L4kqtJlcpXroDTDmJ+rmSpXd3dIbrHY")
+	assert.Equal(t, "bucket/path/file.txt?versionId=3%2FL4kqtJlcpXroDTDmJ%2BrmSpXd3dIbrHY", copySource("bucket", "path/file.txt", versionID))
+	ap := "arn:aws:s3:us-west-2:123456789012:accesspoint/test"
+	assert.Equal(t, ap+"/object/path/file.txt?versionId=null", copySource(ap, "path/file.txt", aws.String("null")))
+}
+
+func TestCheckAccessPoint(t *testing.T) {
+	for _, test := range []struct {
+		opt     Options
+		bucket  string
+		wantErr bool
+	}{
+		{Options{}, "bucket", false},
+		{Options{Endpoint: "https://example.com"}, "bucket", false},
+		{Options{}, "arn:aws:s3:us-west-2:123456789012:accesspoint/myap", false},
+		{Options{Endpoint: "https://example.com"}, "arn:aws:s3:us-west-2:123456789012:accesspoint/myap", true},
+		{Options{UseAccelerateEndpoint: true}, "arn:aws:s3:us-west-2:123456789012:accesspoint/myap", true},
+		{Options{}, "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", true},
+	} {
+		err := checkAccessPoint(&test.opt, test.bucket)
+		assert.Equal(t, test.wantErr, err != nil, "%s: %v", test.bucket, err)
+	}
+}
+
+func TestParseVersionAt(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, test := range []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2019-12-25", time.Date(2019, 12, 25, 0, 0, 0, 0, time.UTC), false},
+		{"2019-12-25T12:00:00Z", time.Date(2019, 12, 25, 12, 0, 0, 0, time.UTC), false},
+		{"1d", now.Add(-24 * time.Hour), false},
+		{"potato", time.Time{}, true},
+	} {
+		got, err := parseVersionAt(test.in, now)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.True(t, test.want.Equal(got), "%s: want %v got %v", test.in, test.want, got)
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []objectVersion{
+		{key: "b", lastModified: t0},
+		{key: "a", lastModified: t0},
+		{key: "b", lastModified: t0.Add(time.Hour)},
+		{key: "a", lastModified: t0.Add(2 * time.Hour)},
+	}
+	sortVersions(versions)
+	var got []string
+	for _, version := range versions {
+		got = append(got, version.key+" "+version.lastModified.Format("15"))
+	}
+	assert.Equal(t, []string{"a 02", "a 00", "b 01", "b 00"}, got)
+}
+
+func TestVersionAtReadOnly(t *testing.T) {
+	f := &Fs{versionAt: time.Now()}
+	o := &Object{fs: f, remote: "file.txt"}
+	assert.Equal(t, errNotWithVersionAt, f.Mkdir(context.Background(), "dir"))
+	assert.Equal(t, errNotWithVersionAt, f.Rmdir(context.Background(), "dir"))
+	assert.Equal(t, errNotWithVersionAt, o.Remove(context.Background()))
+	assert.Equal(t, errNotWithVersionAt, o.SetModTime(context.Background(), time.Now()))
+}
+
+func TestCopyPartSize(t *testing.T) {
+	const MiB = 1024 * 1024
+	f := &Fs{opt: Options{CopyCutoff: 100 * MiB, MaxUploadParts: 10000}}
+	assert.Equal(t, int64(100*MiB), f.copyPartSize(1000*MiB))
+	f.opt.CopyChunkSize = 10 * MiB
+	assert.Equal(t, int64(10*MiB), f.copyPartSize(1000*MiB))
+	// Too many parts so the part size grows
+	f.opt.MaxUploadParts = 10
+	partSize := f.copyPartSize(1000 * MiB)
+	assert.Equal(t, int64(101*MiB), partSize)
+	assert.True(t, (1000*MiB-1)/partSize+1 <= 10)
+
+	assert.NoError(t, checkCopyChunkSize(0))
+	assert.NoError(t, checkCopyChunkSize(512*MiB))
+	assert.Error(t, checkCopyChunkSize(1*MiB))
+	assert.Error(t, checkCopyChunkSize(6*1024*MiB))
+}
@@ -0,0 +1,370 @@
+// Checking host keys against known_hosts
+
+// +build !plan9
+
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/env"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Values for host_key_checking
+const (
+	hostKeyCheckingStrict    = "strict"     // only connect to hosts in known_hosts
+	hostKeyCheckingAcceptNew = "accept-new" // add unknown hosts to known_hosts
+	hostKeyCheckingOff       = "off"        // don't check host keys
+)
+
+// defaultKnownHostsFile is used if known_hosts_file isn't set
+const defaultKnownHostsFile = "~/.ssh/known_hosts"
+
+// knownHostsMu stops more than one connection writing to a
+// known_hosts file at once
+var knownHostsMu sync.Mutex
+
+// hostKeyError is returned when the host key of the server can't be
+// trusted
+type hostKeyError struct {
+	msg string
+}
+
+// Error satisfies the error interface
+func (e *hostKeyError) Error() string {
+	return e.msg
+}
+
+// isHostKeyError returns true if err is caused by a host key which
+// can't be trusted
+func isHostKeyError(err error) bool {
+	_, ok := errors.Cause(err).(*hostKeyError)
+	return ok
+}
+
+// errHostKeyRead is returned by the callback used to read the host
+// key without connecting
+var errHostKeyRead = errors.New("host key read")
+
+// checkHostKeyChecking checks the host_key_checking option is valid
+func checkHostKeyChecking(mode string) error {
+	switch mode {
+	case hostKeyCheckingStrict, hostKeyCheckingAcceptNew, hostKeyCheckingOff:
+		return nil
+	}
+	return errors.Errorf("host_key_checking must be %s, %s or %s not %q", hostKeyCheckingStrict, hostKeyCheckingAcceptNew, hostKeyCheckingOff, mode)
+}
+
+// knownHostsFile returns the path of the known_hosts file
+func (f *Fs) knownHostsFile() string {
+	file := f.opt.KnownHostsFile
+	if file == "" {
+		file = defaultKnownHostsFile
+	}
+	return env.ShellExpand(file)
+}
+
+// hostAddress returns the address of the server as used in
+// known_hosts
+func (f *Fs) hostAddress() string {
+	return net.JoinHostPort(f.opt.Host, f.opt.Port)
+}
+
+// describeKey returns the type and fingerprint of key
+func describeKey(key ssh.PublicKey) string {
+	return key.Type() + " key " + ssh.FingerprintSHA256(key)
+}
+
+// plainKey returns the key inside key if it is a certificate or key
+// otherwise
+func plainKey(key ssh.PublicKey) ssh.PublicKey {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		return cert.Key
+	}
+	return key
+}
+
+// readKnownHosts makes a callback from the known_hosts file, treating
+// a missing file as empty
+//
+// The knownhosts package matches @cert-authority lines against plain
+// host keys too, which hides any host keys of the same type, so plain
+// keys are checked against a copy of the file without them.
+func (f *Fs) readKnownHosts() (ssh.HostKeyCallback, error) {
+	file := f.knownHostsFile()
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return func(string, net.Addr, ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read known_hosts")
+	}
+	certCallback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read known_hosts")
+	}
+	keyCallback, err := newHostKeyCallback(file, data)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if _, ok := key.(*ssh.Certificate); ok {
+			return certCallback(hostname, remote, key)
+		}
+		return keyCallback(hostname, remote, key)
+	}, nil
+}
+
+// newHostKeyCallback makes a callback which only checks the host keys,
+// not the certificate authorities, in data read from file
+func newHostKeyCallback(file string, data []byte) (ssh.HostKeyCallback, error) {
+	// Blank the @cert-authority lines keeping the line numbers
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		fields := bytes.Fields(line)
+		if len(fields) > 0 && string(fields[0]) == "@cert-authority" {
+			lines[i] = []byte("\n")
+		}
+	}
+	tmp, err := ioutil.TempFile("", "rclone-known-hosts")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy known_hosts")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(bytes.Join(lines, nil))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy known_hosts")
+	}
+	callback, err := knownhosts.New(tmp.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read known_hosts")
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		// Report the real file in errors
+		if keyErr, ok := err.(*knownhosts.KeyError); ok {
+			for i := range keyErr.Want {
+				keyErr.Want[i].Filename = file
+			}
+		} else if revokedErr, ok := err.(*knownhosts.RevokedError); ok {
+			revokedErr.Revoked.Filename = file
+		}
+		return err
+	}, nil
+}
+
+// knownHostKeyAlgorithms returns the types of the keys for the server
+// in known_hosts so the server is asked for one of those, or nil if
+// there aren't any.
+func (f *Fs) knownHostKeyAlgorithms() ([]string, error) {
+	callback, err := f.readKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+	// Check a key which can't be known to find the known ones
+	var keyErr *knownhosts.KeyError
+	err = callback(f.hostAddress(), &net.TCPAddr{}, dummyHostKey{})
+	if !errors.As(err, &keyErr) {
+		return nil, nil
+	}
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, known := range keyErr.Want {
+		algorithm := known.Key.Type()
+		if !seen[algorithm] {
+			seen[algorithm] = true
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms, nil
+}
+
+// dummyHostKey is a key which is never in known_hosts
+type dummyHostKey struct{}
+
+func (dummyHostKey) Type() string                        { return "rclone-dummy" }
+func (dummyHostKey) Marshal() []byte                     { return []byte("rclone-dummy") }
+func (dummyHostKey) Verify([]byte, *ssh.Signature) error { return errors.New("dummy key") }
+
+// addKnownHost adds key for the server to known_hosts, replacing any
+// keys for it already there if replace is set
+func (f *Fs) addKnownHost(key ssh.PublicKey, replace bool) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	file := f.knownHostsFile()
+	address := knownhosts.Normalize(f.hostAddress())
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read known_hosts")
+	}
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if replace && knownHostsLineIsFor(line, address) {
+			continue
+		}
+		out.Write(line)
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	out.WriteString(knownhosts.Line([]string{address}, plainKey(key)))
+	out.WriteByte('\n')
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make known_hosts directory")
+	}
+	err = ioutil.WriteFile(file, out.Bytes(), 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write known_hosts")
+	}
+	fs.Infof(f, "Added %s for %s to %s", describeKey(plainKey(key)), address, file)
+	return nil
+}
+
+// knownHostsLineIsFor returns true if line is a host key, not a CA or
+// revocation, for exactly address
+func knownHostsLineIsFor(line []byte, address string) bool {
+	fields := strings.Fields(string(line))
+	if len(fields) < 3 || strings.HasPrefix(fields[0], "@") || strings.HasPrefix(fields[0], "#") {
+		return false
+	}
+	for _, host := range strings.Split(fields[0], ",") {
+		if host == address {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostKey is the ssh.HostKeyCallback which checks the server's
+// key against known_hosts
+func (f *Fs) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if f.opt.HostKeyChecking == hostKeyCheckingOff {
+		return nil
+	}
+	callback, err := f.readKnownHosts()
+	if err != nil {
+		return err
+	}
+	err = callback(hostname, remote, key)
+	if cert, ok := key.(*ssh.Certificate); ok && err != nil && !isRevoked(err) {
+		// No CA is trusted for the certificate so check the
+		// key it certifies instead, as the server has proved
+		// it has that key
+		fs.Debugf(f, "Checking host key as certificate not trusted: %v", err)
+		err = callback(hostname, remote, cert.Key)
+	}
+	if err == nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case errors.As(err, &revokedErr):
+		return &hostKeyError{msg: fmt.Sprintf("host key for %s is revoked by %s", hostname, revokedErr.Revoked.String())}
+	case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+		var want []string
+		for _, known := range keyErr.Want {
+			want = append(want, fmt.Sprintf("%s at %s:%d", describeKey(known.Key), known.Filename, known.Line))
+		}
+		return &hostKeyError{msg: fmt.Sprintf(`HOST KEY MISMATCH for %s - this could be a man-in-the-middle attack!
+The server sent %s
+but known_hosts has %s
+If the host key has been changed, check the new fingerprint with the server administrator then accept it with
+    rclone backend accept-host-key %s: --sftp-host-key-checking off -o fingerprint=%s`,
+			hostname, describeKey(plainKey(key)), strings.Join(want, ", "), f.name, ssh.FingerprintSHA256(plainKey(key)))}
+	case errors.As(err, &keyErr):
+		if f.opt.HostKeyChecking == hostKeyCheckingAcceptNew {
+			return f.addKnownHost(key, false)
+		}
+		return &hostKeyError{msg: fmt.Sprintf(`unknown host key for %s - the server sent %s
+Check the fingerprint with the server administrator then accept it with
+    rclone backend accept-host-key %s: --sftp-host-key-checking off -o fingerprint=%s`,
+			hostname, describeKey(plainKey(key)), f.name, ssh.FingerprintSHA256(plainKey(key)))}
+	}
+	return err
+}
+
+// isRevoked returns true if err says the key is revoked
+func isRevoked(err error) bool {
+	var revokedErr *knownhosts.RevokedError
+	return errors.As(err, &revokedErr)
+}
+
+// readHostKey connects to the server and returns its host key
+// without checking it or logging in
+func (f *Fs) readHostKey() (key ssh.PublicKey, err error) {
+	config := *f.config
+	config.HostKeyAlgorithms = nil
+	config.HostKeyCallback = func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+		key = k
+		return errHostKeyRead
+	}
+	c, err := f.dial("tcp", f.hostAddress(), &config)
+	if err == nil {
+		_ = c.Close()
+	}
+	if key == nil {
+		return nil, errors.Wrap(err, "couldn't read host key")
+	}
+	return plainKey(key), nil
+}
+
+// hostKeyResult is returned by the accept-host-key command
+type hostKeyResult struct {
+	Host        string
+	Type        string
+	Fingerprint string
+	Known       bool
+	Accepted    bool
+}
+
+// acceptHostKeyCommand reads the host key of the server and adds it
+// to known_hosts if its fingerprint matches the one passed in
+func (f *Fs) acceptHostKeyCommand(ctx context.Context, opt map[string]string) (out *hostKeyResult, err error) {
+	key, err := f.readHostKey()
+	if err != nil {
+		return nil, err
+	}
+	out = &hostKeyResult{
+		Host:        knownhosts.Normalize(f.hostAddress()),
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+	}
+	callback, err := f.readKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+	out.Known = callback(f.hostAddress(), &net.TCPAddr{}, key) == nil
+	fingerprint, ok := opt["fingerprint"]
+	if !ok || out.Known {
+		return out, nil
+	}
+	if fingerprint != out.Fingerprint && fingerprint != ssh.FingerprintLegacyMD5(key) {
+		return nil, errors.Errorf("the server sent %s which doesn't match fingerprint %q", describeKey(key), fingerprint)
+	}
+	err = f.addKnownHost(key, true)
+	if err != nil {
+		return nil, err
+	}
+	out.Accepted = true
+	return out, nil
+}
@@ -0,0 +1,159 @@
+// +build !plan9
+
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestHostKey makes a new random host key
+func newTestHostKey(t *testing.T) ssh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return signer
+}
+
+// newTestKnownHostsFs makes an Fs using a known_hosts file in a temporary
+// directory
+func newTestKnownHostsFs(t *testing.T, mode string) (f *Fs, file string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "rclone-sftp-known-hosts")
+	require.NoError(t, err)
+	file = filepath.Join(dir, "ssh", "known_hosts")
+	f = &Fs{
+		name: "remote",
+		opt: Options{
+			Host:            "example.com",
+			Port:            "22",
+			KnownHostsFile:  file,
+			HostKeyChecking: mode,
+		},
+	}
+	return f, file, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestCheckHostKeyChecking(t *testing.T) {
+	for _, mode := range []string{hostKeyCheckingStrict, hostKeyCheckingAcceptNew, hostKeyCheckingOff} {
+		assert.NoError(t, checkHostKeyChecking(mode))
+	}
+	assert.Error(t, checkHostKeyChecking(""))
+	assert.Error(t, checkHostKeyChecking("yes"))
+}
+
+func TestKnownHostsLineIsFor(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		want bool
+	}{
+		{"example.com ssh-ed25519 AAAA\n", true},
+		{"other.com,example.com ssh-ed25519 AAAA\n", true},
+		{"[example.com]:2222 ssh-ed25519 AAAA\n", false},
+		{"example.org ssh-ed25519 AAAA\n", false},
+		{"@cert-authority example.com ssh-ed25519 AAAA\n", false},
+		{"@revoked example.com ssh-ed25519 AAAA\n", false},
+		{"# example.com ssh-ed25519 AAAA\n", false},
+		{"\n", false},
+	} {
+		assert.Equal(t, test.want, knownHostsLineIsFor([]byte(test.line), "example.com"), test.line)
+	}
+}
+
+func TestCheckHostKey(t *testing.T) {
+	hostKey := newTestHostKey(t).PublicKey()
+	otherKey := newTestHostKey(t).PublicKey()
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	t.Run("Strict", func(t *testing.T) {
+		f, file, cleanup := newTestKnownHostsFs(t, hostKeyCheckingStrict)
+		defer cleanup()
+		err := f.checkHostKey("example.com:22", remote, hostKey)
+		require.Error(t, err)
+		assert.True(t, isHostKeyError(err))
+		assert.Contains(t, err.Error(), "unknown host key")
+		assert.Contains(t, err.Error(), ssh.FingerprintSHA256(hostKey))
+		_, err = os.Stat(file)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("AcceptNew", func(t *testing.T) {
+		f, file, cleanup := newTestKnownHostsFs(t, hostKeyCheckingAcceptNew)
+		defer cleanup()
+		require.NoError(t, f.checkHostKey("example.com:22", remote, hostKey))
+		data, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, knownhosts.Line([]string{"example.com"}, hostKey)+"\n", string(data))
+		algorithms, err := f.knownHostKeyAlgorithms()
+		require.NoError(t, err)
+		assert.Equal(t, []string{ssh.KeyAlgoED25519}, algorithms)
+
+		// Same key is fine, changed key is refused
+		require.NoError(t, f.checkHostKey("example.com:22", remote, hostKey))
+		err = f.checkHostKey("example.com:22", remote, otherKey)
+		require.Error(t, err)
+		assert.True(t, isHostKeyError(err))
+		assert.Contains(t, err.Error(), "HOST KEY MISMATCH")
+		assert.Contains(t, err.Error(), ssh.FingerprintSHA256(otherKey))
+
+		// Replacing the key accepts the new one only
+		require.NoError(t, f.addKnownHost(otherKey, true))
+		require.NoError(t, f.checkHostKey("example.com:22", remote, otherKey))
+		assert.Error(t, f.checkHostKey("example.com:22", remote, hostKey))
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		f, file, cleanup := newTestKnownHostsFs(t, hostKeyCheckingOff)
+		defer cleanup()
+		require.NoError(t, f.checkHostKey("example.com:22", remote, hostKey))
+		_, err := os.Stat(file)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Certificate", func(t *testing.T) {
+		f, file, cleanup := newTestKnownHostsFs(t, hostKeyCheckingStrict)
+		defer cleanup()
+		ca := newTestHostKey(t)
+		cert := &ssh.Certificate{
+			Key:             hostKey,
+			CertType:        ssh.HostCert,
+			ValidPrincipals: []string{"example.com"},
+			ValidBefore:     ssh.CertTimeInfinity,
+		}
+		require.NoError(t, cert.SignCert(rand.Reader, ca))
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0700))
+		caLine := "@cert-authority *.com " + string(ssh.MarshalAuthorizedKey(ca.PublicKey()))
+		require.NoError(t, ioutil.WriteFile(file, []byte(caLine), 0600))
+
+		// Signed by a trusted CA
+		require.NoError(t, f.checkHostKey("example.com:22", remote, cert))
+
+		// Signed by an unknown CA and the key isn't known
+		other := *cert
+		require.NoError(t, other.SignCert(rand.Reader, newTestHostKey(t)))
+		err := f.checkHostKey("example.com:22", remote, &other)
+		require.Error(t, err)
+		assert.True(t, isHostKeyError(err))
+
+		// A plain key isn't checked against the CA
+		err = f.checkHostKey("example.com:22", remote, hostKey)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown host key")
+
+		// Signed by an unknown CA but the key is known
+		require.NoError(t, f.addKnownHost(hostKey, false))
+		require.NoError(t, f.checkHostKey("example.com:22", remote, &other))
+	})
+}
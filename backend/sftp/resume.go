@@ -0,0 +1,197 @@
+// Resuming interrupted uploads
+
+// +build !plan9
+
+package sftp
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// partialSuffix is added to the name of a file while it is being
+// uploaded with resume_uploads set
+const partialSuffix = ".rclone-partial"
+
+// resumeBlockSize is the size of the blocks the partial file is
+// hashed in, so uploads are resumed from a multiple of it
+const resumeBlockSize = 1024 * 1024
+
+// Values for resume_check
+const (
+	resumeCheckHash = "hash" // check the hash of the partial file
+	resumeCheckSize = "size" // only check the size of the partial file
+)
+
+// checkResumeCheck checks the resume_check option is valid
+func checkResumeCheck(check string) error {
+	switch check {
+	case resumeCheckHash, resumeCheckSize:
+		return nil
+	}
+	return errors.Errorf("resume_check must be %s or %s not %q", resumeCheckHash, resumeCheckSize, check)
+}
+
+// resumeUploads returns true if interrupted uploads should be resumed
+func (f *Fs) resumeUploads() bool {
+	return f.opt.ResumeUploads || fs.Config.ResumeUploads
+}
+
+// partialPath returns the path the object is uploaded to when
+// resuming uploads
+func (o *Object) partialPath() string {
+	return o.path() + partialSuffix
+}
+
+// resumeStart returns the offset to resume uploading size bytes from
+// given a partial file of partialSize bytes, or 0 to start again.
+//
+// The last window bytes of the partial file are uploaded again as
+// they may not all have been written when the upload was interrupted
+// since up to concurrency writes are outstanding at once. The offset
+// is rounded down to a multiple of blockSize.
+func resumeStart(partialSize, size, window, blockSize int64) int64 {
+	if partialSize > size {
+		return 0
+	}
+	start := partialSize - window
+	if start <= 0 {
+		return 0
+	}
+	return start - start%blockSize
+}
+
+// partialHash returns the hash of the first n bytes of the partial
+// file calculated on the remote, or hash.ErrUnsupported if the remote
+// can't run hash commands
+//
+// n must be a multiple of resumeBlockSize.
+func (o *Object) partialHash(n int64) (hashType hash.Type, sum string, err error) {
+	if o.fs.opt.DisableHashCheck {
+		return hash.None, "", hash.ErrUnsupported
+	}
+	hashes := o.fs.Hashes()
+	var hashCmd string
+	for _, hashType = range []hash.Type{hash.MD5, hash.SHA1, hash.SHA256} {
+		if hashes.Contains(hashType) {
+			hashCmd = o.fs.hashCommand(hashType)
+			break
+		}
+	}
+	if hashCmd == "" {
+		return hash.None, "", hash.ErrUnsupported
+	}
+	rangeCmd := o.fs.rangeCommand(o.fs.shellPath(o.remote+partialSuffix), resumeBlockSize, "0", n/resumeBlockSize)
+	out, err := o.fs.run(rangeCmd + " | " + hashCmd)
+	if err != nil {
+		return hash.None, "", errors.Wrap(err, "failed to hash partial upload")
+	}
+	return hashType, parseHash(out), nil
+}
+
+// resumeOffset looks for a partial file left by an interrupted upload
+// of src and returns how many bytes of it can be kept, reading those
+// bytes from in to check them if resume_check is "hash".
+//
+// If the partial file doesn't match the source it is removed and a
+// retriable error returned as the start of in has been read.
+func (o *Object) resumeOffset(ctx context.Context, in io.Reader, src fs.ObjectInfo) (offset int64, err error) {
+	info, err := o.fs.stat(o.remote + partialSuffix)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0, nil
+	}
+	window := int64(o.fs.opt.ChunkSize) * int64(o.fs.opt.Concurrency)
+	offset = resumeStart(info.Size(), src.Size(), window, resumeBlockSize)
+	if offset == 0 {
+		fs.Debugf(o, "Not resuming from partial upload of %d bytes", info.Size())
+		return 0, nil
+	}
+	if o.fs.opt.ResumeCheck == resumeCheckSize {
+		_, err = io.CopyN(ioutil.Discard, in, offset)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to skip source")
+		}
+		fs.Infof(o, "Resuming upload from %d bytes", offset)
+		return offset, nil
+	}
+	hashType, remoteSum, err := o.partialHash(offset)
+	if err != nil {
+		fs.Debugf(o, "Not resuming as can't check partial upload: %v", err)
+		return 0, nil
+	}
+	hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(hashType))
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.CopyN(hasher, in, offset)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read source")
+	}
+	localSum := hasher.Sums()[hashType]
+	if localSum != remoteSum {
+		o.removePartial()
+		return 0, fserrors.RetryErrorf("partial upload doesn't match source (%v %s != %s)", hashType, remoteSum, localSum)
+	}
+	fs.Infof(o, "Resuming upload from %d bytes", offset)
+	return offset, nil
+}
+
+// removePartial removes the partial file of the object
+func (o *Object) removePartial() {
+	c, err := o.fs.getSftpConnection()
+	if err != nil {
+		fs.Debugf(o, "Failed to open new SSH connection for delete: %v", err)
+		return
+	}
+	err = c.sftpClient.Remove(o.partialPath())
+	o.fs.putSftpConnection(&c, err)
+	if err != nil {
+		fs.Debugf(o, "Failed to remove partial upload: %v", err)
+	}
+}
+
+// updateResumable uploads to the partial file of the object, carrying
+// on from where a previous upload was interrupted if possible, then
+// renames it to the object
+func (o *Object) updateResumable(ctx context.Context, in io.Reader, src fs.ObjectInfo) error {
+	offset, err := o.resumeOffset(ctx, in, src)
+	if err != nil {
+		return errors.Wrap(err, "Update resume failed")
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY
+	}
+	c, err := o.fs.getSftpConnection()
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	file, err := c.sftpClient.OpenFile(o.partialPath(), flags)
+	o.fs.putSftpConnection(&c, err)
+	if err != nil {
+		return errors.Wrap(err, "Update Create failed")
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "Update Seek failed")
+	}
+	n, err := file.ReadFrom(in)
+	if err != nil {
+		_ = file.Close()
+		fs.Infof(o, "Keeping partial upload of about %d bytes to resume", offset+n)
+		return errors.Wrap(err, "Update ReadFrom failed")
+	}
+	err = file.Close()
+	if err != nil {
+		return errors.Wrap(err, "Update Close failed")
+	}
+	return o.replaceWith(o.partialPath())
+}
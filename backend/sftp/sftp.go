@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/user"
 	"path"
@@ -39,7 +40,11 @@ const (
 	hashCommandNotSupported = "none"
 	minSleep                = 100 * time.Millisecond
 	maxSleep                = 2 * time.Second
-	decayConstant           = 2 // bigger for slower decay, exponential
+	decayConstant           = 2                // bigger for slower decay, exponential
+	defaultChunkSize        = 32 * fs.KibiByte // the largest request all servers must accept
+	maxChunkSize            = 1 * fs.MebiByte  // well above what any server accepts
+	defaultConcurrency      = 64               // outstanding requests per file
+	defaultRangeCommand     = "dd if={path} bs={blocksize} skip={skip} count={count} 2>/dev/null"
 )
 
 var (
@@ -51,6 +56,7 @@ func init() {
 		Name:        "sftp",
 		Description: "SSH/SFTP Connection",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Options: []fs.Option{{
 			Name:     "host",
 			Help:     "SSH host to connect to",
@@ -150,14 +156,37 @@ Home directory can be found in a shared folder called "home"
 			Help:     "Set the modified time on the remote if set.",
 			Advanced: true,
 		}, {
-			Name:     "md5sum_command",
-			Default:  "",
-			Help:     "The command used to read md5 hashes. Leave blank for autodetect.",
+			Name:    "md5sum_command",
+			Default: "",
+			Help: `The command used to read md5 hashes. Leave blank for autodetect.
+
+The path of the file is added to the end of the command, and with
+no path it should hash its standard input, like md5sum. Set to
+"none" to disable md5 hashes.`,
 			Advanced: true,
 		}, {
-			Name:     "sha1sum_command",
-			Default:  "",
-			Help:     "The command used to read sha1 hashes. Leave blank for autodetect.",
+			Name:    "sha1sum_command",
+			Default: "",
+			Help: `The command used to read sha1 hashes. Leave blank for autodetect.
+
+This works like md5sum_command.`,
+			Advanced: true,
+		}, {
+			Name:    "sha256sum_command",
+			Default: "",
+			Help: `The command used to read sha256 hashes. Leave blank for autodetect.
+
+This works like md5sum_command.`,
+			Advanced: true,
+		}, {
+			Name:    "range_command",
+			Default: defaultRangeCommand,
+			Help: `The command used to read part of a file to hash it.
+
+Its output is piped into the hash command to hash blocks of a file,
+eg to check a partial upload before resuming it. {path} is replaced
+with the path of the file and the command should output {count}
+blocks of {blocksize} bytes starting {skip} blocks into the file.`,
 			Advanced: true,
 		}, {
 			Name:     "skip_links",
@@ -176,6 +205,103 @@ Home directory can be found in a shared folder called "home"
 
 The subsystem option is ignored when server_command is defined.`,
 			Advanced: true,
+		}, {
+			Name:    "known_hosts_file",
+			Default: "",
+			Help: `Optional path to known_hosts file.
+
+Host keys are checked against this file, which is in the same format
+as OpenSSH's, so it can contain host keys, @cert-authority lines to
+trust host certificates signed by a CA and @revoked lines.
+
+Leave blank to use ~/.ssh/known_hosts.`,
+			Examples: []fs.OptionExample{{
+				Value: "~/.ssh/known_hosts",
+				Help:  "Use OpenSSH's known_hosts file",
+			}},
+			Advanced: true,
+		}, {
+			Name:    "host_key_checking",
+			Default: hostKeyCheckingAcceptNew,
+			Help: `How to check the host key of the server.
+
+With "accept-new" the host key of a server which isn't in the
+known_hosts file is added to it, but a server whose host key doesn't
+match the one in known_hosts is refused. With "strict" servers not in
+known_hosts are refused too.
+
+Use the "accept-host-key" backend command to add or replace the host
+key of a server.
+
+"off" doesn't check the host key at all which is insecure.`,
+			Examples: []fs.OptionExample{{
+				Value: hostKeyCheckingAcceptNew,
+				Help:  "Add unknown host keys to known_hosts, refuse changed ones",
+			}, {
+				Value: hostKeyCheckingStrict,
+				Help:  "Only connect to servers in known_hosts",
+			}, {
+				Value: hostKeyCheckingOff,
+				Help:  "Don't check host keys - insecure",
+			}},
+			Advanced: true,
+		}, {
+			Name:    "chunk_size",
+			Default: defaultChunkSize,
+			Help: `Upload and download chunk size.
+
+This controls the maximum size of the data in each SSH_FXP_READ and
+SSH_FXP_WRITE request. The SFTP protocol says servers should accept
+at least 32k which is the default. Many servers, eg OpenSSH, accept
+bigger requests and raising this, eg to 255k, can make transfers
+quicker. If you get errors like "failed to send packet header: EOF"
+then the server doesn't support the size.`,
+			Advanced: true,
+		}, {
+			Name:    "concurrency",
+			Default: defaultConcurrency,
+			Help: `The maximum number of outstanding requests for one file.
+
+This controls how many SSH_FXP_READ or SSH_FXP_WRITE requests for a
+single file can be waiting for a reply at once, like the -R and -W
+flags of OpenSSH's sftp. Reads and writes need about
+concurrency * chunk_size bytes in flight to fill the link, so raise
+this on high latency links. Each transfer uses up to
+concurrency * chunk_size bytes of memory.`,
+			Advanced: true,
+		}, {
+			Name:    "resume_uploads",
+			Default: false,
+			Help: `Resume interrupted uploads.
+
+If set, files are uploaded to a partial file named with a
+".rclone-partial" suffix which is renamed when the upload is
+complete. If an upload fails the partial file is kept and the next
+upload of the file carries on from where it got to, after checking
+the partial file as set by resume_check.
+
+This is also enabled by the global --resume-uploads flag.`,
+			Advanced: true,
+		}, {
+			Name:    "resume_check",
+			Default: resumeCheckHash,
+			Help: `How to check a partial file before resuming an upload.
+
+With "hash" the hash of the partial file is calculated on the remote
+with the md5sum, sha1sum or sha256sum command and compared with the hash of the
+same part of the source. If the remote can't run these commands the
+upload starts again.
+
+With "size" only the size of the partial file is checked, which is
+quicker, but won't notice if the source has changed.`,
+			Examples: []fs.OptionExample{{
+				Value: resumeCheckHash,
+				Help:  "Check the hash of the partial file",
+			}, {
+				Value: resumeCheckSize,
+				Help:  "Only check the size of the partial file",
+			}},
+			Advanced: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -183,24 +309,32 @@ The subsystem option is ignored when server_command is defined.`,
 
 // Options defines the configuration for this backend
 type Options struct {
-	Host              string `config:"host"`
-	User              string `config:"user"`
-	Port              string `config:"port"`
-	Pass              string `config:"pass"`
-	KeyPem            string `config:"key_pem"`
-	KeyFile           string `config:"key_file"`
-	KeyFilePass       string `config:"key_file_pass"`
-	KeyUseAgent       bool   `config:"key_use_agent"`
-	UseInsecureCipher bool   `config:"use_insecure_cipher"`
-	DisableHashCheck  bool   `config:"disable_hashcheck"`
-	AskPassword       bool   `config:"ask_password"`
-	PathOverride      string `config:"path_override"`
-	SetModTime        bool   `config:"set_modtime"`
-	Md5sumCommand     string `config:"md5sum_command"`
-	Sha1sumCommand    string `config:"sha1sum_command"`
-	SkipLinks         bool   `config:"skip_links"`
-	Subsystem         string `config:"subsystem"`
-	ServerCommand     string `config:"server_command"`
+	Host              string        `config:"host"`
+	User              string        `config:"user"`
+	Port              string        `config:"port"`
+	Pass              string        `config:"pass"`
+	KeyPem            string        `config:"key_pem"`
+	KeyFile           string        `config:"key_file"`
+	KeyFilePass       string        `config:"key_file_pass"`
+	KeyUseAgent       bool          `config:"key_use_agent"`
+	UseInsecureCipher bool          `config:"use_insecure_cipher"`
+	DisableHashCheck  bool          `config:"disable_hashcheck"`
+	AskPassword       bool          `config:"ask_password"`
+	PathOverride      string        `config:"path_override"`
+	SetModTime        bool          `config:"set_modtime"`
+	Md5sumCommand     string        `config:"md5sum_command"`
+	Sha1sumCommand    string        `config:"sha1sum_command"`
+	Sha256sumCommand  string        `config:"sha256sum_command"`
+	RangeCommand      string        `config:"range_command"`
+	SkipLinks         bool          `config:"skip_links"`
+	Subsystem         string        `config:"subsystem"`
+	ServerCommand     string        `config:"server_command"`
+	ChunkSize         fs.SizeSuffix `config:"chunk_size"`
+	Concurrency       int           `config:"concurrency"`
+	KnownHostsFile    string        `config:"known_hosts_file"`
+	HostKeyChecking   string        `config:"host_key_checking"`
+	ResumeUploads     bool          `config:"resume_uploads"`
+	ResumeCheck       string        `config:"resume_check"`
 }
 
 // Fs stores the interface to the remote SFTP files
@@ -222,13 +356,14 @@ type Fs struct {
 
 // Object is a remote SFTP file that has been stat'd (so it exists, but is not necessarily open for reading)
 type Object struct {
-	fs      *Fs
-	remote  string
-	size    int64       // size of the object
-	modTime time.Time   // modification time of the object
-	mode    os.FileMode // mode bits from the file
-	md5sum  *string     // Cached MD5 checksum
-	sha1sum *string     // Cached SHA1 checksum
+	fs        *Fs
+	remote    string
+	size      int64       // size of the object
+	modTime   time.Time   // modification time of the object
+	mode      os.FileMode // mode bits from the file
+	md5sum    *string     // Cached MD5 checksum
+	sha1sum   *string     // Cached SHA1 checksum
+	sha256sum *string     // Cached SHA256 checksum
 }
 
 // readCurrentUser finds the current user name or "" if not found
@@ -254,8 +389,21 @@ func (f *Fs) dial(network, addr string, sshConfig *ssh.ClientConfig) (*ssh.Clien
 	if err != nil {
 		return nil, err
 	}
-	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConfig)
+	// Keep the host key error as the ssh library only returns its text
+	var hostKeyErr error
+	config := *sshConfig
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := sshConfig.HostKeyCallback(hostname, remote, key)
+		if isHostKeyError(err) {
+			hostKeyErr = err
+		}
+		return err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &config)
 	if err != nil {
+		if hostKeyErr != nil {
+			return nil, hostKeyErr
+		}
 		return nil, err
 	}
 	fs.Debugf(f, "New connection %s->%s to %q", c.LocalAddr(), c.RemoteAddr(), c.ServerVersion())
@@ -304,7 +452,10 @@ func (f *Fs) sftpConnection() (c *conn, err error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't connect SSH")
 	}
-	c.sftpClient, err = f.newSftpClient(c.sshClient)
+	c.sftpClient, err = f.newSftpClient(c.sshClient,
+		sftp.MaxPacketUnchecked(int(f.opt.ChunkSize)),
+		sftp.MaxConcurrentRequestsPerFile(f.opt.Concurrency),
+	)
 	if err != nil {
 		_ = c.sshClient.Close()
 		return nil, errors.Wrap(err, "couldn't initialise SFTP")
@@ -407,6 +558,17 @@ func (f *Fs) putSftpConnection(pc **conn, err error) {
 	f.poolMu.Unlock()
 }
 
+// checkChunkSize checks the chunk_size is in range
+func checkChunkSize(cs fs.SizeSuffix) error {
+	if cs < fs.KibiByte {
+		return errors.Errorf("%s is less than %s", cs, fs.KibiByte)
+	}
+	if cs > maxChunkSize {
+		return errors.Errorf("%s is greater than %s", cs, maxChunkSize)
+	}
+	return nil
+}
+
 // NewFs creates a new Fs object from the name and root. It connects to
 // the host specified in the config file.
 func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
@@ -423,12 +585,26 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if opt.Port == "" {
 		opt.Port = "22"
 	}
+	err = checkChunkSize(opt.ChunkSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "sftp: chunk size")
+	}
+	if opt.Concurrency < 1 {
+		return nil, errors.Errorf("sftp: concurrency must be at least 1 not %d", opt.Concurrency)
+	}
+	err = checkHostKeyChecking(opt.HostKeyChecking)
+	if err != nil {
+		return nil, errors.Wrap(err, "sftp")
+	}
+	err = checkResumeCheck(opt.ResumeCheck)
+	if err != nil {
+		return nil, errors.Wrap(err, "sftp")
+	}
 	sshConfig := &ssh.ClientConfig{
-		User:            opt.User,
-		Auth:            []ssh.AuthMethod{},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         fs.Config.ConnectTimeout,
-		ClientVersion:   "SSH-2.0-" + fs.Config.UserAgent,
+		User:          opt.User,
+		Auth:          []ssh.AuthMethod{},
+		Timeout:       fs.Config.ConnectTimeout,
+		ClientVersion: "SSH-2.0-" + fs.Config.UserAgent,
 	}
 
 	if opt.UseInsecureCipher {
@@ -547,9 +723,23 @@ func NewFsWithConnection(ctx context.Context, name string, root string, m config
 		CanHaveEmptyDirectories: true,
 		SlowHash:                true,
 	}).Fill(f)
+	// Check host keys against known_hosts unless the caller has
+	// set up its own checks
+	if sshConfig.HostKeyCallback == nil {
+		sshConfig.HostKeyCallback = f.checkHostKey
+		if opt.HostKeyChecking != hostKeyCheckingOff {
+			algorithms, err := f.knownHostKeyAlgorithms()
+			if err != nil {
+				return nil, errors.Wrap(err, "NewFs")
+			}
+			sshConfig.HostKeyAlgorithms = algorithms
+		}
+	}
 	// Make a connection and pool it to return errors early
 	c, err := f.getSftpConnection()
-	if err != nil {
+	if isHostKeyError(err) {
+		return nil, err
+	} else if err != nil {
 		return nil, errors.Wrap(err, "NewFs")
 	}
 	cwd, err := c.sftpClient.Getwd()
@@ -944,10 +1134,12 @@ func (f *Fs) Hashes() hash.Set {
 	changed := false
 	md5Works := checkHash([]string{"md5sum", "md5 -r"}, "d41d8cd98f00b204e9800998ecf8427e", &f.opt.Md5sumCommand, &changed)
 	sha1Works := checkHash([]string{"sha1sum", "sha1 -r"}, "da39a3ee5e6b4b0d3255bfef95601890afd80709", &f.opt.Sha1sumCommand, &changed)
+	sha256Works := checkHash([]string{"sha256sum", "sha256 -r", "shasum -a 256"}, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", &f.opt.Sha256sumCommand, &changed)
 
 	if changed {
 		f.m.Set("md5sum_command", f.opt.Md5sumCommand)
 		f.m.Set("sha1sum_command", f.opt.Sha1sumCommand)
+		f.m.Set("sha256sum_command", f.opt.Sha256sumCommand)
 	}
 
 	set := hash.NewHashSet()
@@ -957,6 +1149,9 @@ func (f *Fs) Hashes() hash.Set {
 	if md5Works {
 		set.Add(hash.MD5)
 	}
+	if sha256Works {
+		set.Add(hash.SHA256)
+	}
 
 	f.cachedHashes = &set
 	return set
@@ -1016,21 +1211,15 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 	}
 	_ = o.fs.Hashes()
 
-	var hashCmd string
-	if r == hash.MD5 {
-		if o.md5sum != nil {
-			return *o.md5sum, nil
-		}
-		hashCmd = o.fs.opt.Md5sumCommand
-	} else if r == hash.SHA1 {
-		if o.sha1sum != nil {
-			return *o.sha1sum, nil
-		}
-		hashCmd = o.fs.opt.Sha1sumCommand
-	} else {
-		return "", hash.ErrUnsupported
+	if r == hash.MD5 && o.md5sum != nil {
+		return *o.md5sum, nil
+	} else if r == hash.SHA1 && o.sha1sum != nil {
+		return *o.sha1sum, nil
+	} else if r == hash.SHA256 && o.sha256sum != nil {
+		return *o.sha256sum, nil
 	}
-	if hashCmd == "" || hashCmd == hashCommandNotSupported {
+	hashCmd := o.fs.hashCommand(r)
+	if hashCmd == "" {
 		return "", hash.ErrUnsupported
 	}
 
@@ -1047,10 +1236,7 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 	var stdout, stderr bytes.Buffer
 	session.Stdout = &stdout
 	session.Stderr = &stderr
-	escapedPath := shellEscape(o.path())
-	if o.fs.opt.PathOverride != "" {
-		escapedPath = shellEscape(path.Join(o.fs.opt.PathOverride, o.remote))
-	}
+	escapedPath := o.fs.shellPath(o.remote)
 	err = session.Run(hashCmd + " " + escapedPath)
 	fs.Debugf(nil, "sftp cmd = %s", escapedPath)
 	if err != nil {
@@ -1068,10 +1254,56 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 		o.md5sum = &str
 	} else if r == hash.SHA1 {
 		o.sha1sum = &str
+	} else if r == hash.SHA256 {
+		o.sha256sum = &str
 	}
 	return str, nil
 }
 
+// hashCommand returns the command to read hashes of type ht or "" if
+// the remote can't
+func (f *Fs) hashCommand(ht hash.Type) string {
+	var hashCmd string
+	switch ht {
+	case hash.MD5:
+		hashCmd = f.opt.Md5sumCommand
+	case hash.SHA1:
+		hashCmd = f.opt.Sha1sumCommand
+	case hash.SHA256:
+		hashCmd = f.opt.Sha256sumCommand
+	}
+	if hashCmd == hashCommandNotSupported {
+		return ""
+	}
+	return hashCmd
+}
+
+// rangeCommand returns the command which outputs count blocks of
+// blockSize bytes starting skip blocks into the file at escapedPath
+//
+// skip is a string so it can be a shell variable.
+func (f *Fs) rangeCommand(escapedPath string, blockSize int64, skip string, count int64) string {
+	rangeCmd := f.opt.RangeCommand
+	if rangeCmd == "" {
+		rangeCmd = defaultRangeCommand
+	}
+	return strings.NewReplacer(
+		"{path}", escapedPath,
+		"{blocksize}", strconv.FormatInt(blockSize, 10),
+		"{skip}", skip,
+		"{count}", strconv.FormatInt(count, 10),
+	).Replace(rangeCmd)
+}
+
+// shellPath returns the path of remote escaped for use in commands
+// run on the remote
+func (f *Fs) shellPath(remote string) string {
+	if f.opt.PathOverride != "" {
+		return shellEscape(path.Join(f.opt.PathOverride, remote))
+	}
+	return shellEscape(path.Join(f.absRoot, remote))
+}
+
 var shellEscapeRegex = regexp.MustCompile("[^A-Za-z0-9_.,:/\\@\u0080-\uFFFFFFFF\n-]")
 
 // Escape a string s.t. it cannot cause unintended behavior
@@ -1281,6 +1513,18 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// Clear the hash cache since we are about to update the object
 	o.md5sum = nil
 	o.sha1sum = nil
+	o.sha256sum = nil
+	if o.fs.resumeUploads() && src.Size() >= 0 {
+		err := o.updateResumable(ctx, in, src)
+		if err != nil {
+			return err
+		}
+		err = o.SetModTime(ctx, src.ModTime(ctx))
+		if err != nil {
+			return errors.Wrap(err, "Update SetModTime failed")
+		}
+		return nil
+	}
 	c, err := o.fs.getSftpConnection()
 	if err != nil {
 		return errors.Wrap(err, "Update")
@@ -1322,6 +1566,191 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return nil
 }
 
+// deltaSuffix is added to the name of the copy of a file which a delta
+// transfer writes to before renaming it over the file
+const deltaSuffix = ".rclone-delta"
+
+// blockHashesCommand returns the command which outputs the MD5 hashes
+// of each of the blocks of blockSize bytes of the file at escapedPath.
+//
+// This is one perl process reading the file once, Digest::MD5 being
+// part of perl.
+func blockHashesCommand(escapedPath string, blockSize int64) string {
+	return fmt.Sprintf(`perl -MDigest::MD5=md5_hex -e '$/ = \%d; binmode STDIN; while (defined($b = <STDIN>)) { print md5_hex($b), "\n" }' < %s`, blockSize, escapedPath)
+}
+
+// blockHashesLoopCommand returns the shell command which outputs the
+// hashes of each of the blocks of blockSize bytes of the file at
+// escapedPath using hashCmd, for remotes without perl.
+//
+// The file is opened once as the input of the loop and each dd reads
+// the next block from it, so the file is only read once.
+func blockHashesLoopCommand(escapedPath string, blockSize, blocks int64, hashCmd string) string {
+	return fmt.Sprintf("i=0; while [ $i -lt %d ]; do dd bs=%d count=1 2>/dev/null | %s; i=$((i+1)); done < %s", blocks, blockSize, hashCmd, escapedPath)
+}
+
+// canBlockHash returns true if the remote can calculate block hashes
+func (f *Fs) canBlockHash() bool {
+	if f.opt.DisableHashCheck {
+		return false
+	}
+	_ = f.Hashes()
+	return f.hashCommand(hash.MD5) != ""
+}
+
+// BlockHashes returns the MD5 hashes of each blockSize block of the
+// object for use in delta transfers.
+//
+// They are calculated on the remote end in one pass through the file
+// with one command so this only works if the remote has a shell.
+func (o *Object) BlockHashes(ctx context.Context, blockSize int64) ([]string, error) {
+	if !o.fs.canBlockHash() {
+		return nil, hash.ErrUnsupported
+	}
+	blocks := (o.size + blockSize - 1) / blockSize
+	escapedPath := o.fs.shellPath(o.remote)
+	out, err := o.fs.run(blockHashesCommand(escapedPath, blockSize))
+	if err != nil {
+		fs.Debugf(o, "Failed to read block hashes with perl - trying %s: %v", o.fs.hashCommand(hash.MD5), err)
+		out, err = o.fs.run(blockHashesLoopCommand(escapedPath, blockSize, blocks, o.fs.hashCommand(hash.MD5)))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "BlockHashes failed")
+	}
+	var sums []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+		sums = append(sums, parseHash([]byte(line)))
+	}
+	if int64(len(sums)) != blocks {
+		return nil, errors.Errorf("BlockHashes: expecting %d hashes but got %d", blocks, len(sums))
+	}
+	return sums, nil
+}
+
+// partialUpdate is a handle for writing blocks into a copy of an
+// existing remote file which replaces the file when closed
+type partialUpdate struct {
+	mu   sync.Mutex
+	o    *Object
+	file *sftp.File
+	size int64
+}
+
+// WriteAt writes len(p) bytes at offset off
+func (pu *partialUpdate) WriteAt(p []byte, off int64) (n int, err error) {
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+	_, err = pu.file.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return pu.file.Write(p)
+}
+
+// Close truncates the copy to the final size, closes it and renames
+// it over the file
+func (pu *partialUpdate) Close() error {
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+	err := pu.file.Truncate(pu.size)
+	closeErr := pu.file.Close()
+	if err != nil {
+		pu.o.removeDelta()
+		return errors.Wrap(err, "partial update: failed to truncate")
+	}
+	if closeErr != nil {
+		pu.o.removeDelta()
+		return errors.Wrap(closeErr, "partial update: failed to close")
+	}
+	return pu.o.replaceWith(pu.o.deltaPath())
+}
+
+// Abort closes and removes the copy leaving the file unchanged
+func (pu *partialUpdate) Abort() error {
+	pu.mu.Lock()
+	defer pu.mu.Unlock()
+	err := pu.file.Close()
+	pu.o.removeDelta()
+	return err
+}
+
+// deltaPath returns the path of the copy of the object a delta
+// transfer writes to
+func (o *Object) deltaPath() string {
+	return o.path() + deltaSuffix
+}
+
+// removeDelta removes the copy of the object made for a delta transfer
+func (o *Object) removeDelta() {
+	c, err := o.fs.getSftpConnection()
+	if err != nil {
+		fs.Debugf(o, "Failed to open new SSH connection for delete: %v", err)
+		return
+	}
+	err = c.sftpClient.Remove(o.deltaPath())
+	o.fs.putSftpConnection(&c, err)
+	if err != nil {
+		fs.Debugf(o, "Failed to remove delta copy: %v", err)
+	}
+}
+
+// replaceWith renames the file at from over the object
+func (o *Object) replaceWith(from string) error {
+	c, err := o.fs.getSftpConnection()
+	if err != nil {
+		return errors.Wrap(err, "Update")
+	}
+	err = c.sftpClient.PosixRename(from, o.path())
+	if err != nil {
+		// The server doesn't support posix-rename@openssh.com so
+		// remove the old file first
+		_ = c.sftpClient.Remove(o.path())
+		err = c.sftpClient.Rename(from, o.path())
+	}
+	o.fs.putSftpConnection(&c, err)
+	if err != nil {
+		return errors.Wrap(err, "Update Rename failed")
+	}
+	return nil
+}
+
+// OpenPartialUpdate copies the existing remote file on the remote and
+// opens the copy for random access writes. On Close the copy is
+// truncated to size and renamed over the file so it is never left half
+// written.
+//
+// If the remote can't run commands the copy starts empty, which is
+// fine as BlockHashes isn't supported then so all blocks are written.
+func (o *Object) OpenPartialUpdate(ctx context.Context, size int64) (fs.WriterAtCloser, error) {
+	// Clear the hash cache since we are about to update the object
+	o.md5sum = nil
+	o.sha1sum = nil
+	o.sha256sum = nil
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if o.fs.canBlockHash() {
+		_, err := o.fs.run(fmt.Sprintf("cp -p %s %s", o.fs.shellPath(o.remote), o.fs.shellPath(o.remote+deltaSuffix)))
+		if err != nil {
+			o.removeDelta()
+			return nil, errors.Wrap(err, "OpenPartialUpdate: failed to copy file")
+		}
+		flags = os.O_WRONLY
+	}
+	c, err := o.fs.getSftpConnection()
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenPartialUpdate")
+	}
+	file, err := c.sftpClient.OpenFile(o.deltaPath(), flags)
+	o.fs.putSftpConnection(&c, err)
+	if err != nil {
+		o.removeDelta()
+		return nil, errors.Wrap(err, "OpenPartialUpdate failed")
+	}
+	return &partialUpdate{o: o, file: file, size: size}, nil
+}
+
 // Remove a remote sftp file object
 func (o *Object) Remove(ctx context.Context) error {
 	c, err := o.fs.getSftpConnection()
@@ -1333,12 +1762,69 @@ func (o *Object) Remove(ctx context.Context) error {
 	return err
 }
 
+var commandHelp = []fs.CommandHelp{{
+	Name:  "accept-host-key",
+	Short: "Show or accept the host key of the server",
+	Long: `This command reads the host key of the server and, if the fingerprint
+option is given and matches it, adds it to the known_hosts file,
+replacing any other keys for the server there.
+
+Usage Examples:
+
+    rclone backend accept-host-key sftp:
+    rclone backend accept-host-key sftp: -o fingerprint=SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU
+
+Without the fingerprint option it shows the host key of the server
+and whether it is in known_hosts.
+
+As rclone won't connect to a server whose host key isn't accepted,
+add "--sftp-host-key-checking off" to run this for a new server or one
+whose host key has changed. Only this command should be run like that.
+
+    {
+        "Host": "example.com",
+        "Type": "ssh-ed25519",
+        "Fingerprint": "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU",
+        "Known": false,
+        "Accepted": false
+    }
+
+Check the fingerprint with the administrator of the server, eg with
+"ssh-keygen -lf /etc/ssh/ssh_host_ed25519_key.pub" run on the server,
+before accepting it.
+`,
+	Opts: map[string]string{
+		"fingerprint": "Accept the host key if it has this SHA256 or MD5 fingerprint",
+	},
+}}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "accept-host-key":
+		return f.acceptHostKeyCommand(ctx, opt)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.Mover       = &Fs{}
-	_ fs.DirMover    = &Fs{}
-	_ fs.Abouter     = &Fs{}
-	_ fs.Object      = &Object{}
+	_ fs.Fs             = &Fs{}
+	_ fs.Commander      = &Fs{}
+	_ fs.PutStreamer    = &Fs{}
+	_ fs.Mover          = &Fs{}
+	_ fs.DirMover       = &Fs{}
+	_ fs.Abouter        = &Fs{}
+	_ fs.Object         = &Object{}
+	_ fs.BlockHasher    = &Object{}
+	_ fs.PartialUpdater = &Object{}
 )
@@ -1,12 +1,23 @@
+//go:build !plan9
 // +build !plan9
 
 package sftp
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShellEscape(t *testing.T) {
@@ -49,3 +60,117 @@ func TestParseUsage(t *testing.T) {
 		assert.Equal(t, test.usage, [3]int64{gotSpaceTotal, gotSpaceUsed, gotSpaceAvail}, fmt.Sprintf("Test %d sshOutput = %q", i, test.sshOutput))
 	}
 }
+
+func TestCheckChunkSize(t *testing.T) {
+	for _, test := range []struct {
+		in      fs.SizeSuffix
+		wantErr bool
+	}{
+		{0, true},
+		{512, true},
+		{fs.KibiByte, false},
+		{defaultChunkSize, false},
+		{255 * fs.KibiByte, false},
+		{maxChunkSize, false},
+		{maxChunkSize + 1, true},
+	} {
+		err := checkChunkSize(test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in.String())
+	}
+}
+
+func TestResumeStart(t *testing.T) {
+	for _, test := range []struct {
+		partialSize, size, window, blockSize int64
+		want                                 int64
+	}{
+		{0, 100, 10, 1, 0},
+		{5, 100, 10, 1, 0},
+		{10, 100, 10, 1, 0},
+		{11, 100, 10, 1, 1},
+		{60, 100, 10, 1, 50},
+		{100, 100, 10, 1, 90},
+		{101, 100, 10, 1, 0},
+		{60, 100, 0, 1, 60},
+		{60, 100, 10, 16, 48},
+		{25, 100, 10, 16, 0},
+	} {
+		got := resumeStart(test.partialSize, test.size, test.window, test.blockSize)
+		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test))
+	}
+}
+
+func TestCheckResumeCheck(t *testing.T) {
+	assert.NoError(t, checkResumeCheck(resumeCheckHash))
+	assert.NoError(t, checkResumeCheck(resumeCheckSize))
+	assert.Error(t, checkResumeCheck(""))
+	assert.Error(t, checkResumeCheck("md5"))
+}
+
+func TestRangeCommand(t *testing.T) {
+	f := &Fs{}
+	assert.Equal(t, "dd if=/a\\ b bs=1024 skip=$i count=1 2>/dev/null", f.rangeCommand(shellEscape("/a b"), 1024, "$i", 1))
+	f.opt.RangeCommand = "busybox dd if={path} bs={blocksize} skip={skip} count={count}"
+	assert.Equal(t, "busybox dd if=/file bs=4 skip=2 count=3", f.rangeCommand("/file", 4, "2", 3))
+}
+
+func TestBlockHashesCommand(t *testing.T) {
+	assert.Equal(t, `perl -MDigest::MD5=md5_hex -e '$/ = \1024; binmode STDIN; while (defined($b = <STDIN>)) { print md5_hex($b), "\n" }' < /a\ b`, blockHashesCommand(shellEscape("/a b"), 1024))
+	assert.Equal(t, "i=0; while [ $i -lt 3 ]; do dd bs=1024 count=1 2>/dev/null | md5sum; i=$((i+1)); done < /a\\ b", blockHashesLoopCommand(shellEscape("/a b"), 1024, 3, "md5sum"))
+
+	// Run them locally if possible to check the hashes
+	dir, err := ioutil.TempDir("", "rclone-sftp-test")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "file name")
+	data := []byte(strings.Repeat("0123456789", 1000))
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	const blockSize = 4096
+	var want []string
+	for i := 0; i < len(data); i += blockSize {
+		end := i + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := md5.Sum(data[i:end])
+		want = append(want, hex.EncodeToString(sum[:]))
+	}
+	for _, test := range []struct {
+		command string
+		needs   []string
+	}{
+		{blockHashesCommand(shellEscape(path), blockSize), []string{"sh", "perl"}},
+		{blockHashesLoopCommand(shellEscape(path), blockSize, 3, "md5sum"), []string{"sh", "dd", "md5sum"}},
+	} {
+		missing := false
+		for _, command := range test.needs {
+			if _, err := exec.LookPath(command); err != nil {
+				t.Logf("%s not found", command)
+				missing = true
+			}
+		}
+		if missing {
+			continue
+		}
+		out, err := exec.Command("sh", "-c", test.command).Output()
+		require.NoError(t, err, test.command)
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			got = append(got, parseHash([]byte(line)))
+		}
+		assert.Equal(t, want, got, test.command)
+	}
+}
+
+func TestHashCommand(t *testing.T) {
+	f := &Fs{opt: Options{
+		Md5sumCommand:    "md5 -r",
+		Sha1sumCommand:   hashCommandNotSupported,
+		Sha256sumCommand: "sha256sum",
+	}}
+	assert.Equal(t, "md5 -r", f.hashCommand(hash.MD5))
+	assert.Equal(t, "", f.hashCommand(hash.SHA1))
+	assert.Equal(t, "sha256sum", f.hashCommand(hash.SHA256))
+	assert.Equal(t, "", f.hashCommand(hash.CRC32))
+}
@@ -11,6 +11,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ncw/swift"
@@ -27,7 +28,9 @@ import (
 	"github.com/rclone/rclone/lib/bucket"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/pool"
 	"github.com/rclone/rclone/lib/readers"
+	"golang.org/x/sync/errgroup"
 )
 
 // Constants
@@ -35,7 +38,9 @@ const (
 	directoryMarkerContentType = "application/directory" // content type of directory marker objects
 	listChunks                 = 1000                    // chunk size to read directory listings
 	defaultChunkSize           = 5 * fs.GibiByte
+	maxConcurrentChunkSize     = 256 * fs.MebiByte // largest chunk_size used with upload_concurrency > 1
 	minSleep                   = 10 * time.Millisecond // In case of error, start at 10ms sleep.
+	memoryPoolFlushTime        = time.Minute           // flush the pooled segments after this long
 )
 
 // SharedOptions are shared between swift and hubic
@@ -61,6 +66,21 @@ Rclone will still chunk files bigger than chunk_size when doing normal
 copy operations.`,
 	Default:  false,
 	Advanced: true,
+}, {
+	Name: "upload_concurrency",
+	Help: `Concurrency for chunked uploads.
+
+This is the number of segments of the same file which are uploaded at
+once. If it is more than 1 each segment is read into memory before it
+is uploaded, so up to chunk_size * upload_concurrency of memory may be
+used for each transfer. For this reason chunk_size is reduced to 256M
+if it is bigger when this is more than 1.
+
+Uploading segments at once can speed up streaming uploads (eg using
+rcat or mount) and big files over fast links. Reading the segments
+into memory also means they can be retried if the upload of one fails.`,
+	Default:  1,
+	Advanced: true,
 }, {
 	Name:     config.ConfigEncoding,
 	Help:     config.ConfigEncodingHelp,
@@ -212,6 +232,7 @@ type Options struct {
 	EndpointType                string               `config:"endpoint_type"`
 	ChunkSize                   fs.SizeSuffix        `config:"chunk_size"`
 	NoChunk                     bool                 `config:"no_chunk"`
+	UploadConcurrency           int                  `config:"upload_concurrency"`
 	Enc                         encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -227,6 +248,7 @@ type Fs struct {
 	cache            *bucket.Cache     // cache of container status
 	noCheckContainer bool              // don't check the container before creating it
 	pacer            *fs.Pacer         // To pace the API calls
+	pool             *pool.Pool        // memory pool for the segments with upload_concurrency
 }
 
 // Object describes a swift object
@@ -413,6 +435,34 @@ func checkUploadChunkSize(cs fs.SizeSuffix) error {
 	return nil
 }
 
+// capConcurrentChunkSize reduces chunk_size to maxConcurrentChunkSize
+// if upload_concurrency > 1 as each segment uploading is held in
+// memory
+func capConcurrentChunkSize(opt *Options) {
+	if opt.UploadConcurrency > 1 && opt.ChunkSize > maxConcurrentChunkSize {
+		fs.Logf(nil, "swift: reducing chunk_size from %v to %v as upload_concurrency is %d", opt.ChunkSize, maxConcurrentChunkSize, opt.UploadConcurrency)
+		opt.ChunkSize = maxConcurrentChunkSize
+	}
+}
+
+// newMemoryPool makes a memory pool for segments of size
+func (f *Fs) newMemoryPool(size int64) *pool.Pool {
+	return pool.New(
+		memoryPoolFlushTime,
+		int(size),
+		f.opt.UploadConcurrency*fs.Config.Transfers,
+		fs.Config.UseMmap,
+	)
+}
+
+// getMemoryPool returns the memory pool for segments of size
+func (f *Fs) getMemoryPool(size int64) *pool.Pool {
+	if size == int64(f.opt.ChunkSize) {
+		return f.pool
+	}
+	return f.newMemoryPool(size)
+}
+
 func (f *Fs) setUploadChunkSize(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
 	err = checkUploadChunkSize(cs)
 	if err == nil {
@@ -441,6 +491,8 @@ func NewFsWithConnection(opt *Options, name, root string, c *swift.Connection, n
 		pacer:            fs.NewPacer(pacer.NewS3(pacer.MinSleep(minSleep))),
 		cache:            bucket.NewCache(),
 	}
+	capConcurrentChunkSize(&f.opt)
+	f.pool = f.newMemoryPool(int64(f.opt.ChunkSize))
 	f.setRoot(root)
 	f.features = (&fs.Features{
 		ReadMimeType:      true,
@@ -1189,7 +1241,7 @@ func urlEncode(str string) string {
 
 // updateChunks updates the existing object using chunks to a separate
 // container.  It returns a string which prefixes current segments.
-func (o *Object) updateChunks(in0 io.Reader, headers swift.Headers, size int64, contentType string) (string, error) {
+func (o *Object) updateChunks(ctx context.Context, in0 io.Reader, headers swift.Headers, size int64, contentType string) (string, error) {
 	container, containerPath := o.split()
 	segmentsContainer := container + "_segments"
 	// Create the segmentsContainer if it doesn't exist
@@ -1213,17 +1265,47 @@ func (o *Object) updateChunks(in0 io.Reader, headers swift.Headers, size int64,
 		return "", err
 	}
 	// Upload the chunks
-	left := size
-	i := 0
 	uniquePrefix := fmt.Sprintf("%s/%d", swift.TimeToFloatString(time.Now()), size)
 	segmentsPath := path.Join(containerPath, uniquePrefix)
+	var segmentInfos []string
+	if o.fs.opt.UploadConcurrency > 1 {
+		segmentInfos, err = o.uploadSegmentsConcurrently(ctx, in0, segmentsContainer, segmentsPath, headers, size)
+	} else {
+		segmentInfos, err = o.uploadSegments(in0, segmentsContainer, segmentsPath, headers, size)
+	}
+	if err != nil {
+		return "", err
+	}
+	// Upload the manifest
+	headers["X-Object-Manifest"] = urlEncode(fmt.Sprintf("%s/%s", segmentsContainer, segmentsPath))
+	headers["Content-Length"] = "0" // set Content-Length as we know it
+	emptyReader := bytes.NewReader(nil)
+	err = o.fs.pacer.Call(func() (bool, error) {
+		var rxHeaders swift.Headers
+		rxHeaders, err = o.fs.c.ObjectPut(container, containerPath, emptyReader, true, "", contentType, headers)
+		return shouldRetryHeaders(rxHeaders, err)
+	})
+	if err != nil {
+		deleteChunks(o, segmentsContainer, segmentInfos)
+		segmentInfos = nil
+	}
+	return uniquePrefix + "/", err
+}
+
+// uploadSegments uploads in as segments of chunk_size into
+// segmentsContainer one at a time, streaming each from in, returning
+// the paths of the segments.
+func (o *Object) uploadSegments(in0 io.Reader, segmentsContainer, segmentsPath string, headers swift.Headers, size int64) ([]string, error) {
+	var err error
+	left := size
+	i := 0
 	in := bufio.NewReader(in0)
 	segmentInfos := make([]string, 0, ((size / int64(o.fs.opt.ChunkSize)) + 1))
 	for {
 		// can we read at least one byte?
 		if _, err := in.Peek(1); err != nil {
 			if left > 0 {
-				return "", err // read less than expected
+				return nil, err // read less than expected
 			}
 			fs.Debugf(o, "Uploading segments into %q seems done (%v)", segmentsContainer, err)
 			break
@@ -1247,25 +1329,107 @@ func (o *Object) updateChunks(in0 io.Reader, headers swift.Headers, size int64,
 		})
 		if err != nil {
 			deleteChunks(o, segmentsContainer, segmentInfos)
-			segmentInfos = nil
-			return "", err
+			return nil, err
 		}
 		i++
 	}
-	// Upload the manifest
-	headers["X-Object-Manifest"] = urlEncode(fmt.Sprintf("%s/%s", segmentsContainer, segmentsPath))
-	headers["Content-Length"] = "0" // set Content-Length as we know it
-	emptyReader := bytes.NewReader(nil)
-	err = o.fs.pacer.Call(func() (bool, error) {
-		var rxHeaders swift.Headers
-		rxHeaders, err = o.fs.c.ObjectPut(container, containerPath, emptyReader, true, "", contentType, headers)
-		return shouldRetryHeaders(rxHeaders, err)
-	})
+	return segmentInfos, nil
+}
+
+// uploadSegmentsConcurrently uploads in as segments of chunk_size into
+// segmentsContainer like uploadSegments, but reads each segment into
+// memory so upload_concurrency of them can be uploaded at once. This
+// also means the segments can be retried.
+func (o *Object) uploadSegmentsConcurrently(ctx context.Context, in io.Reader, segmentsContainer, segmentsPath string, headers swift.Headers, size int64) ([]string, error) {
+	var (
+		g, gCtx      = errgroup.WithContext(ctx)
+		tokens       = pacer.NewTokenDispenser(o.fs.opt.UploadConcurrency)
+		memPool      = o.fs.getMemoryPool(int64(o.fs.opt.ChunkSize))
+		segmentsMu   sync.Mutex // to protect segmentInfos
+		segmentInfos []string
+		left         = size
+		finished     = false
+		err          error
+	)
+	for i := 0; !finished; i++ {
+		// Get a block of memory from the pool and token which limits concurrency.
+		tokens.Get()
+		buf, poolErr := memPool.GetContext(gCtx)
+		if poolErr != nil {
+			tokens.Put()
+			break
+		}
+		free := func() {
+			// return the memory and token
+			memPool.Put(buf)
+			tokens.Put()
+		}
+
+		// Fail fast, in case an upload has failed
+		if gCtx.Err() != nil {
+			free()
+			break
+		}
+
+		// Read the segment
+		if size >= 0 && int64(len(buf)) > left {
+			buf = buf[:left]
+		}
+		var n int
+		n, err = readers.ReadFill(in, buf)
+		if err == io.EOF {
+			err = nil
+			finished = true
+			if size >= 0 && n < len(buf) {
+				err = io.ErrUnexpectedEOF // read less than expected
+			}
+		}
+		if err != nil || n == 0 {
+			free()
+			break
+		}
+		buf = buf[:n]
+		left -= int64(n)
+		if size >= 0 && left <= 0 {
+			finished = true
+		}
+
+		segmentPath := fmt.Sprintf("%s/%08d", segmentsPath, i)
+		segmentHeaders := swift.Headers{}
+		for k, v := range headers {
+			segmentHeaders[k] = v
+		}
+		segmentHeaders["Content-Length"] = strconv.Itoa(n)
+		g.Go(func() (err error) {
+			defer free()
+			fs.Debugf(o, "Uploading segment file %q into %q", segmentPath, segmentsContainer)
+			err = o.fs.pacer.Call(func() (bool, error) {
+				var rxHeaders swift.Headers
+				rxHeaders, err = o.fs.c.ObjectPut(segmentsContainer, segmentPath, bytes.NewReader(buf), true, "", "", segmentHeaders)
+				return shouldRetryHeaders(rxHeaders, err)
+			})
+			if err != nil {
+				return err
+			}
+			segmentsMu.Lock()
+			segmentInfos = append(segmentInfos, segmentPath)
+			segmentsMu.Unlock()
+			return nil
+		})
+	}
+	uploadErr := g.Wait()
+	if err == nil {
+		err = uploadErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
 	if err != nil {
 		deleteChunks(o, segmentsContainer, segmentInfos)
-		segmentInfos = nil
+		return nil, err
 	}
-	return uniquePrefix + "/", err
+	fs.Debugf(o, "Uploaded %d segments into %q", len(segmentInfos), segmentsContainer)
+	return segmentInfos, nil
 }
 
 func deleteChunks(o *Object, segmentsContainer string, segmentInfos []string) {
@@ -1309,7 +1473,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	fs.OpenOptionAddHeaders(options, headers)
 	uniquePrefix := ""
 	if size > int64(o.fs.opt.ChunkSize) || (size == -1 && !o.fs.opt.NoChunk) {
-		uniquePrefix, err = o.updateChunks(in, headers, size, contentType)
+		uniquePrefix, err = o.updateChunks(ctx, in, headers, size, contentType)
 		if err != nil {
 			return err
 		}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ncw/swift"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/stretchr/testify/assert"
 )
@@ -64,3 +65,19 @@ func TestInternalShouldRetryHeaders(t *testing.T) {
 	assert.True(t, dt >= time.Hour-time.Second && dt <= time.Hour+time.Second)
 
 }
+
+func TestInternalCapConcurrentChunkSize(t *testing.T) {
+	for _, test := range []struct {
+		concurrency int
+		in          fs.SizeSuffix
+		want        fs.SizeSuffix
+	}{
+		{1, defaultChunkSize, defaultChunkSize},
+		{2, defaultChunkSize, maxConcurrentChunkSize},
+		{2, 100 * fs.MebiByte, 100 * fs.MebiByte},
+	} {
+		opt := Options{UploadConcurrency: test.concurrency, ChunkSize: test.in}
+		capConcurrentChunkSize(&opt)
+		assert.Equal(t, test.want, opt.ChunkSize, test)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
 	"testing"
 
 	"github.com/rclone/rclone/fs"
@@ -71,9 +72,54 @@ func (f *Fs) testNoChunk(t *testing.T) {
 	assert.NoError(t, obj.Remove(ctx))
 }
 
+// Check that PutStream uploads the segments at once with
+// upload_concurrency, including a short last segment
+func (f *Fs) testUploadConcurrency(t *testing.T) {
+	ctx := context.Background()
+	f.opt.UploadConcurrency = 4
+	old, err := f.setUploadChunkSize(1024)
+	require.NoError(t, err)
+	defer func() {
+		f.opt.UploadConcurrency = 1
+		_, _ = f.setUploadChunkSize(old)
+	}()
+
+	file := fstest.Item{
+		ModTime: fstest.Time("2001-02-03T04:05:06.499999999Z"),
+		Path:    "piped data concurrent.txt",
+		Size:    -1, // use unknown size during upload
+	}
+
+	const contentSize = 10*1024 + 100
+
+	contents := random.String(contentSize)
+	buf := bytes.NewBufferString(contents)
+	uploadHash := hash.NewMultiHasher()
+	in := io.TeeReader(buf, uploadHash)
+
+	obji := object.NewStaticObjectInfo(file.Path, file.ModTime, file.Size, true, nil, nil)
+	obj, err := f.Features().PutStream(ctx, in, obji)
+	require.NoError(t, err)
+
+	// Re-read the object and check its contents
+	obj, err = f.NewObject(ctx, file.Path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(contentSize), obj.Size())
+	rc, err := obj.Open(ctx)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, contents, string(got))
+
+	// Delete the object
+	assert.NoError(t, obj.Remove(ctx))
+}
+
 // Additional tests that aren't in the framework
 func (f *Fs) InternalTest(t *testing.T) {
 	t.Run("NoChunk", f.testNoChunk)
+	t.Run("UploadConcurrency", f.testUploadConcurrency)
 }
 
 var _ fstests.InternalTester = (*Fs)(nil)
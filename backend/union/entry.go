@@ -81,6 +81,15 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	return errs.Err()
 }
 
+// Open opens the file for read, first copying it to the upstreams
+// missing a copy if the mirror create policy is used
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	if o.fs.mirror {
+		o.fs.repair(ctx, o)
+	}
+	return o.Object.Open(ctx, options...)
+}
+
 // Remove candidate objects selected by ACTION policy
 func (o *Object) Remove(ctx context.Context) error {
 	entries, err := o.fs.actionEntries(o.candidates()...)
@@ -0,0 +1,53 @@
+// Read repair of the copies written by the mirror create policy
+
+package union
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/backend/union/upstream"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// repair copies o to the upstreams the mirror create policy would
+// write it to if it has fewer copies than it should.
+//
+// Failures are logged rather than returned as the file can still be
+// read from the copies it has.
+func (f *Fs) repair(ctx context.Context, o *Object) {
+	// All the upstreams it could have a copy on, most free space first
+	upstreams, err := f.createPolicy.Create(ctx, f.upstreams, o.Remote())
+	if err != nil {
+		fs.Debugf(o, "Can't repair copies: %v", err)
+		return
+	}
+	want := len(upstreams)
+	if f.opt.MirrorCopies > 0 && f.opt.MirrorCopies < want {
+		want = f.opt.MirrorCopies
+	}
+	have := make(map[*upstream.Fs]bool, len(o.co))
+	for _, e := range o.co {
+		have[e.UpstreamFs()] = true
+	}
+	src := o.UnWrap().UnWrap()
+	for _, u := range upstreams {
+		if len(have) >= want {
+			break
+		}
+		if have[u] {
+			continue
+		}
+		have[u] = true
+		dst, err := operations.Copy(ctx, u, nil, o.Remote(), src)
+		if err != nil {
+			fs.Errorf(o, "Failed to repair missing copy on %v: %v", u, err)
+			continue
+		}
+		if dst == nil {
+			continue // dry run
+		}
+		fs.Infof(o, "Repaired missing copy on %v", u)
+		o.co = append(o.co, u.WrapObject(dst))
+	}
+}
@@ -0,0 +1,88 @@
+package union
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorRepair(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-union-mirror")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	var dirs []string
+	for _, name := range []string{"a", "b", "c"} {
+		d := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(d, 0777))
+		dirs = append(dirs, d)
+	}
+	f, err := NewFs("mirrortest", "", configmap.Simple{
+		"upstreams":     strings.Join(dirs, " "),
+		"action_policy": "mirror",
+		"create_policy": "mirror",
+		"search_policy": "mirror",
+		"mirror_copies": "2",
+	})
+	require.NoError(t, err)
+
+	copies := func() (paths []string) {
+		for _, d := range dirs {
+			p := filepath.Join(d, "sub", "file.txt")
+			if _, err := os.Stat(p); err == nil {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+
+	contents := "hello mirror"
+	src := object.NewStaticObjectInfo("sub/file.txt", time.Now(), int64(len(contents)), true, nil, nil)
+	_, err = f.Put(ctx, strings.NewReader(contents), src)
+	require.NoError(t, err)
+	paths := copies()
+	require.Len(t, paths, 2)
+
+	// lose a copy then read it
+	require.NoError(t, os.Remove(paths[0]))
+	require.Len(t, copies(), 1)
+	o, err := f.NewObject(ctx, "sub/file.txt")
+	require.NoError(t, err)
+	in, err := o.Open(ctx)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, contents, string(data))
+	paths = copies()
+	require.Len(t, paths, 2)
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(data))
+	}
+
+	// removing removes all the copies including the repaired one
+	require.NoError(t, o.Remove(ctx))
+	assert.Len(t, copies(), 0)
+
+	_, err = NewFs("mirrortest", "", configmap.Simple{
+		"upstreams":     strings.Join(dirs, " "),
+		"action_policy": "mirror",
+		"create_policy": "mirror",
+		"search_policy": "mirror",
+		"mirror_copies": "-1",
+	})
+	assert.Error(t, err)
+}
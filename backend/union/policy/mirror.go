@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rclone/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("mirror", &Mirror{})
+}
+
+// Mirror writes copies of each file to several upstreams
+// Action category: same as all.
+// Create category: same as all but with the upstreams with the most
+// free space first, so the ones with the most room are chosen when
+// the union limits the number of copies.
+// Search category: same as all.
+type Mirror struct {
+	All
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *Mirror) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	upstreams, err := p.All.Create(ctx, upstreams, path)
+	if err != nil {
+		return nil, err
+	}
+	free := make(map[*upstream.Fs]int64, len(upstreams))
+	for _, u := range upstreams {
+		free[u], _ = u.GetFreeSpace() // unknown is returned as infinite
+	}
+	upstreams = append([]*upstream.Fs(nil), upstreams...)
+	sort.SliceStable(upstreams, func(i, j int) bool {
+		return free[upstreams[i]] > free[upstreams[j]]
+	})
+	return upstreams, nil
+}
@@ -67,9 +67,11 @@ func filterROEntries(ue []upstream.Entry) (wue []upstream.Entry) {
 	return wue
 }
 
+// filterNC filters out the upstreams tagged no create and those
+// below their minimum free space
 func filterNC(ufs []*upstream.Fs) (wufs []*upstream.Fs) {
 	for _, u := range ufs {
-		if u.IsCreatable() {
+		if u.IsCreatable() && u.HasFreeSpace() {
 			wufs = append(wufs, u)
 		}
 	}
@@ -78,7 +80,7 @@ func filterNC(ufs []*upstream.Fs) (wufs []*upstream.Fs) {
 
 func filterNCEntries(ue []upstream.Entry) (wue []upstream.Entry) {
 	for _, e := range ue {
-		if e.UpstreamFs().IsCreatable() {
+		if e.UpstreamFs().IsCreatable() && e.UpstreamFs().HasFreeSpace() {
 			wue = append(wue, e)
 		}
 	}
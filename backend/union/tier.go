@@ -0,0 +1,218 @@
+// Tiering - migrating old files between upstreams
+
+package union
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+var commandHelp = []fs.CommandHelp{{
+	Name:  "tier",
+	Short: "Migrate files between upstreams with the tier rules",
+	Long: `This runs the tier_rules once, moving the files which have not been
+accessed for the age of a rule from its source upstream to its
+destination upstream. Upstreams which don't record when files were
+last accessed use when they were modified instead.
+
+Usage Examples:
+
+    rclone backend tier union:
+    rclone backend tier union: --dry-run
+    rclone rc backend/command command=tier fs=union: _async=true
+
+It returns the number of files and bytes moved. Files which fail to
+move are logged and left where they are.
+`,
+}}
+
+// tierRule moves the files not accessed for age from src to dst
+type tierRule struct {
+	rule string        // the rule as written in the config
+	src  int           // index of the upstream to move from
+	dst  int           // index of the upstream to move to
+	age  time.Duration // minimum time since the files were accessed
+}
+
+// String returns the rule as written in the config
+func (r tierRule) String() string {
+	return r.rule
+}
+
+// tierRuleRe matches a rule of the form "1>2:30d"
+var tierRuleRe = regexp.MustCompile(`^(\d+)>(\d+):(.+)$`)
+
+// parseTierRules parses the tier_rules for n upstreams
+func parseTierRules(rules []string, n int) (parsed []tierRule, err error) {
+	for _, rule := range rules {
+		match := tierRuleRe.FindStringSubmatch(rule)
+		if match == nil {
+			return nil, errors.Errorf("tier rule %q should be source>destination:age, eg 1>2:30d", rule)
+		}
+		r := tierRule{rule: rule}
+		r.src, _ = strconv.Atoi(match[1])
+		r.dst, _ = strconv.Atoi(match[2])
+		if r.src < 1 || r.src > n || r.dst < 1 || r.dst > n {
+			return nil, errors.Errorf("tier rule %q: upstreams are numbered from 1 to %d", rule, n)
+		}
+		if r.src == r.dst {
+			return nil, errors.Errorf("tier rule %q: source and destination are the same", rule)
+		}
+		r.src--
+		r.dst--
+		r.age, err = fs.ParseDuration(match[3])
+		if err != nil {
+			return nil, errors.Wrapf(err, "tier rule %q", rule)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// tierStats counts what a tier run did
+type tierStats struct {
+	mu     sync.Mutex
+	files  int64 // files moved
+	bytes  int64 // bytes moved
+	errors int   // files which failed to move
+}
+
+// tier runs the tier rules once
+func (f *Fs) tier(ctx context.Context) (string, error) {
+	if len(f.tierRules) == 0 {
+		return "", errors.New("no tier_rules set")
+	}
+	f.tierMu.Lock()
+	defer f.tierMu.Unlock()
+	var stats tierStats
+	for _, rule := range f.tierRules {
+		err := f.tierRule(ctx, rule, &stats)
+		if err != nil {
+			return "", errors.Wrapf(err, "tier rule %v", rule)
+		}
+	}
+	if stats.errors > 0 {
+		return "", errors.Errorf("failed to move %d files", stats.errors)
+	}
+	return fmt.Sprintf("moved %d files (%v)", stats.files, fs.SizeSuffix(stats.bytes)), nil
+}
+
+// tierRule moves the files matched by rule
+func (f *Fs) tierRule(ctx context.Context, rule tierRule, stats *tierStats) error {
+	src, dst := f.upstreams[rule.src], f.upstreams[rule.dst]
+	if !dst.IsWritable() {
+		return errors.Errorf("destination %v is read only", dst)
+	}
+	if !src.IsWritable() {
+		return errors.Errorf("source %v is read only", src)
+	}
+	cutoff := time.Now().Add(-rule.age)
+	return walk.ListR(ctx, src, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		var err error
+		entries.ForObject(func(o fs.Object) {
+			if err != nil || o.ModTime(ctx).After(cutoff) {
+				return
+			}
+			// A file isn't read before it is written so only
+			// the files old enough by modification time need
+			// their access time reading.
+			if atime, ok := accessTime(ctx, o); ok && atime.After(cutoff) {
+				fs.Debugf(o, "Not moving to %v as accessed at %v", dst, atime)
+				return
+			}
+			if !dst.HasFreeSpace() {
+				err = errors.Errorf("destination %v is below its minimum free space", dst)
+				return
+			}
+			size := o.Size()
+			_, moveErr := operations.Move(ctx, dst, nil, o.Remote(), o)
+			stats.mu.Lock()
+			defer stats.mu.Unlock()
+			if moveErr != nil {
+				fs.Errorf(o, "Failed to move to %v: %v", dst, moveErr)
+				stats.errors++
+				return
+			}
+			fs.Debugf(o, "Moved to %v", dst)
+			stats.files++
+			stats.bytes += size
+		})
+		return err
+	})
+}
+
+// accessTime returns the time o was last accessed if its upstream
+// records it in the metadata
+func accessTime(ctx context.Context, o fs.Object) (atime time.Time, ok bool) {
+	metadata, err := fs.GetMetadata(ctx, fs.UnWrapObject(o))
+	if err != nil {
+		fs.Debugf(o, "Failed to read access time: %v", err)
+		return atime, false
+	}
+	return metadata.Time(fs.MetadataAtime)
+}
+
+// startTiering runs the tier rules every interval in the background
+// until stopTiering is called, which it is when rclone exits.
+func (f *Fs) startTiering(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	f.stopTiering = func() {
+		cancel()
+		<-done
+	}
+	atexit.Register(f.stopTiering)
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			out, err := f.tier(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					fs.Errorf(f, "Tiering failed: %v", err)
+				}
+				continue
+			}
+			fs.Infof(f, "Tiering %s", out)
+		}
+	}()
+}
+
+// Command the backend to run a named command
+//
+// The command run is name
+// args may be used to read arguments from
+// opts may be used to read optional arguments from
+//
+// The result should be capable of being JSON encoded
+// If it is a string or a []string it will be shown to the user
+// otherwise it will be JSON encoded and shown to the user like that
+func (f *Fs) Command(ctx context.Context, name string, arg []string, opt map[string]string) (out interface{}, err error) {
+	switch name {
+	case "tier":
+		return f.tier(ctx)
+	default:
+		return nil, fs.ErrorCommandNotFound
+	}
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Commander = (*Fs)(nil)
+)
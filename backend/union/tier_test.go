@@ -0,0 +1,124 @@
+package union
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTierRules(t *testing.T) {
+	rules, err := parseTierRules([]string{"1>2:30d", "2>3:1h"}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []tierRule{
+		{rule: "1>2:30d", src: 0, dst: 1, age: 30 * 24 * time.Hour},
+		{rule: "2>3:1h", src: 1, dst: 2, age: time.Hour},
+	}, rules)
+	assert.Equal(t, "2>3:1h", rules[1].String())
+
+	rules, err = parseTierRules(nil, 3)
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+
+	for _, bad := range []string{"1>2", "1-2:30d", "0>1:1h", "1>4:1h", "2>2:1h", "1>2:potato"} {
+		_, err = parseTierRules([]string{bad}, 3)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTier(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-union-tier")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	hot, cold := filepath.Join(dir, "hot"), filepath.Join(dir, "cold")
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"old.txt", "sub/old.txt", "new.txt", "read.txt"} {
+		p := filepath.Join(hot, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0777))
+		require.NoError(t, ioutil.WriteFile(p, []byte(name), 0666))
+		switch name {
+		case "read.txt":
+			// modified long ago but read recently
+			require.NoError(t, os.Chtimes(p, time.Now(), old))
+		case "old.txt", "sub/old.txt":
+			require.NoError(t, os.Chtimes(p, old, old))
+		}
+	}
+	require.NoError(t, os.MkdirAll(cold, 0777))
+
+	f, err := NewFs("tiertest", "", configmap.Simple{
+		"upstreams":     hot + " " + cold,
+		"action_policy": "epall",
+		"create_policy": "epmfs",
+		"search_policy": "ff",
+		"tier_rules":    "1>2:1d",
+	})
+	require.NoError(t, err)
+
+	out, err := f.(fs.Commander).Command(ctx, "tier", nil, nil)
+	require.NoError(t, err)
+	// The local backend only reads access times on Linux
+	if runtime.GOOS == "linux" {
+		assert.Equal(t, "moved 2 files (18)", out)
+		assert.FileExists(t, filepath.Join(hot, "read.txt"))
+	} else {
+		assert.Equal(t, "moved 3 files (26)", out)
+	}
+	for _, name := range []string{"old.txt", "sub/old.txt"} {
+		assert.FileExists(t, filepath.Join(cold, filepath.FromSlash(name)))
+		_, err = os.Stat(filepath.Join(hot, filepath.FromSlash(name)))
+		assert.True(t, os.IsNotExist(err), name)
+
+		// still found through the union
+		o, err := f.NewObject(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(name)), o.Size())
+	}
+	assert.FileExists(t, filepath.Join(hot, "new.txt"))
+
+	f, err = NewFs("tiertest", "", configmap.Simple{
+		"upstreams":     hot + " " + cold,
+		"action_policy": "epall",
+		"create_policy": "epmfs",
+		"search_policy": "ff",
+	})
+	require.NoError(t, err)
+	_, err = f.(fs.Commander).Command(ctx, "tier", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestTierStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-union-tier")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	hot, cold := filepath.Join(dir, "hot"), filepath.Join(dir, "cold")
+	require.NoError(t, os.MkdirAll(hot, 0777))
+	require.NoError(t, os.MkdirAll(cold, 0777))
+	f, err := NewFs("tiertest", "", configmap.Simple{
+		"upstreams":     hot + " " + cold,
+		"action_policy": "epall",
+		"create_policy": "epmfs",
+		"search_policy": "ff",
+		"tier_rules":    "1>2:1d",
+		"tier_interval": "1ms",
+	})
+	require.NoError(t, err)
+	stop := f.(*Fs).stopTiering
+	require.NotNil(t, stop)
+	time.Sleep(10 * time.Millisecond)
+	stop() // returns once the background tiering has stopped
+	stop() // and can be called again
+}
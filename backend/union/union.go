@@ -28,6 +28,7 @@ func init() {
 		Name:        "union",
 		Description: "Union merges the contents of several upstream fs",
 		NewFs:       NewFs,
+		CommandHelp: commandHelp,
 		Options: []fs.Option{{
 			Name:     "upstreams",
 			Help:     "List of space separated upstreams.\nCan be 'upstreama:test/dir upstreamb:', '\"upstreama:test/space:ro dir\" upstreamb:', etc.\n",
@@ -52,6 +53,56 @@ func init() {
 			Help:     "Cache time of usage and free space (in seconds). This option is only useful when a path preserving policy is used.",
 			Required: true,
 			Default:  120,
+		}, {
+			Name: "min_free_space",
+			Help: `Minimum free space for new files to be created on an upstream.
+
+Create policies skip the upstreams with less free space, or unused
+quota if they only report that, so new files spill over to the
+upstreams with room. It can be set for a single upstream by adding
+":minfree=size" to the end of it, eg "remote:dir:minfree=10G".
+
+Free space is read with "about" and cached for cache_time.`,
+			Default:  fs.SizeSuffix(0),
+			Advanced: true,
+		}, {
+			Name: "min_free_space_hysteresis",
+			Help: `Free space above min_free_space needed to create files again.
+
+Once an upstream has dropped below min_free_space, new files are only
+created on it again when it has this much more free space, so they
+don't flip between upstreams as files are written and removed.`,
+			Default:  fs.SizeSuffix(0),
+			Advanced: true,
+		}, {
+			Name: "tier_rules",
+			Help: `Rules to migrate files between upstreams.
+
+A space separated list of rules like "1>2:30d" which moves the files
+not accessed for 30 days from the first upstream to the second.
+Upstreams are numbered from 1 in the order of upstreams. Upstreams
+which don't record when files were last accessed use when they were
+modified instead.
+
+The rules are run every tier_interval or with "rclone backend tier".`,
+			Advanced: true,
+		}, {
+			Name: "tier_interval",
+			Help: `How often to run the tier_rules in the background, 0 for never.
+
+This is only useful for long running commands like "rclone mount".`,
+			Default:  fs.Duration(0),
+			Advanced: true,
+		}, {
+			Name: "mirror_copies",
+			Help: `Number of upstreams the mirror create policy writes each file to.
+
+0 writes each file to all the upstreams new files can be created on,
+otherwise to that many of them, choosing the ones with the most free
+space. Reading a file with fewer copies than this copies it to the
+upstreams it is missing from.`,
+			Default:  0,
+			Advanced: true,
 		}},
 	}
 	fs.Register(fsi)
@@ -65,6 +116,11 @@ type Options struct {
 	CreatePolicy string          `config:"create_policy"`
 	SearchPolicy string          `config:"search_policy"`
 	CacheTime    int             `config:"cache_time"`
+	MinFree      fs.SizeSuffix   `config:"min_free_space"`
+	Hysteresis   fs.SizeSuffix   `config:"min_free_space_hysteresis"`
+	TierRules    fs.SpaceSepList `config:"tier_rules"`
+	TierInterval fs.Duration     `config:"tier_interval"`
+	MirrorCopies int             `config:"mirror_copies"`
 }
 
 // Fs represents a union of upstreams
@@ -78,6 +134,10 @@ type Fs struct {
 	actionPolicy policy.Policy  // policy for ACTION
 	createPolicy policy.Policy  // policy for CREATE
 	searchPolicy policy.Policy  // policy for SEARCH
+	tierRules    []tierRule     // rules to migrate files between upstreams
+	tierMu       sync.Mutex     // only one tier run at once
+	stopTiering  func()         // stop the background tiering if running
+	mirror       bool           // set if the create policy is mirror
 }
 
 // Wrap candidate objects in to a union Object
@@ -428,6 +488,9 @@ func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, stream bo
 	if err != nil {
 		return nil, err
 	}
+	if f.mirror && len(upstreams) < f.opt.MirrorCopies {
+		fs.Logf(src, "Only writing %d of %d copies as there aren't enough upstreams with room", len(upstreams), f.opt.MirrorCopies)
+	}
 	if len(upstreams) == 1 {
 		u := upstreams[0]
 		var o fs.Object
@@ -709,7 +772,11 @@ func (f *Fs) actionEntries(entries ...upstream.Entry) ([]upstream.Entry, error)
 }
 
 func (f *Fs) create(ctx context.Context, path string) ([]*upstream.Fs, error) {
-	return f.createPolicy.Create(ctx, f.upstreams, path)
+	upstreams, err := f.createPolicy.Create(ctx, f.upstreams, path)
+	if err == nil && f.mirror && f.opt.MirrorCopies > 0 && len(upstreams) > f.opt.MirrorCopies {
+		upstreams = upstreams[:f.opt.MirrorCopies]
+	}
+	return upstreams, err
 }
 
 func (f *Fs) createEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
@@ -782,7 +849,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	errs := Errors(make([]error, len(opt.Upstreams)))
 	multithread(len(opt.Upstreams), func(i int) {
 		u := opt.Upstreams[i]
-		upstreams[i], errs[i] = upstream.New(u, root, time.Duration(opt.CacheTime)*time.Second)
+		upstreams[i], errs[i] = upstream.New(u, root, time.Duration(opt.CacheTime)*time.Second, int64(opt.MinFree), int64(opt.Hysteresis))
 	})
 	var usedUpstreams []*upstream.Fs
 	var fserr error
@@ -818,6 +885,10 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if err != nil {
 		return nil, err
 	}
+	f.mirror = strings.EqualFold(opt.CreatePolicy, "mirror")
+	if opt.MirrorCopies < 0 {
+		return nil, errors.New("mirror_copies can't be negative")
+	}
 	var features = (&fs.Features{
 		CaseInsensitive:         true,
 		DuplicateFiles:          false,
@@ -854,6 +925,18 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	}
 	f.hashSet = hashSet
 
+	// Tiering moves files between all the upstreams so it isn't
+	// done when pointing at a file
+	if fserr == nil {
+		f.tierRules, err = parseTierRules(opt.TierRules, len(f.upstreams))
+		if err != nil {
+			return nil, err
+		}
+		if len(f.tierRules) > 0 && opt.TierInterval > 0 {
+			f.startTiering(time.Duration(opt.TierInterval))
+		}
+	}
+
 	return f, fserr
 }
 
@@ -19,7 +19,7 @@ func TestIntegration(t *testing.T) {
 	}
 	fstests.Run(t, &fstests.Opt{
 		RemoteName:                   *fstest.RemoteName,
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -45,7 +45,7 @@ func TestStandard(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "epmfs"},
 			{Name: name, Key: "search_policy", Value: "ff"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -71,7 +71,7 @@ func TestRO(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "epmfs"},
 			{Name: name, Key: "search_policy", Value: "ff"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -97,7 +97,7 @@ func TestNC(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "epmfs"},
 			{Name: name, Key: "search_policy", Value: "ff"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -123,7 +123,7 @@ func TestPolicy1(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "lus"},
 			{Name: name, Key: "search_policy", Value: "all"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -149,7 +149,7 @@ func TestPolicy2(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "rand"},
 			{Name: name, Key: "search_policy", Value: "ff"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
@@ -175,7 +175,34 @@ func TestPolicy3(t *testing.T) {
 			{Name: name, Key: "create_policy", Value: "all"},
 			{Name: name, Key: "search_policy", Value: "all"},
 		},
-		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
+		UnimplementableObjectMethods: []string{"MimeType"},
+	})
+}
+
+func TestMirror(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir1 := filepath.Join(os.TempDir(), "rclone-union-test-mirror1")
+	tempdir2 := filepath.Join(os.TempDir(), "rclone-union-test-mirror2")
+	tempdir3 := filepath.Join(os.TempDir(), "rclone-union-test-mirror3")
+	require.NoError(t, os.MkdirAll(tempdir1, 0744))
+	require.NoError(t, os.MkdirAll(tempdir2, 0744))
+	require.NoError(t, os.MkdirAll(tempdir3, 0744))
+	upstreams := tempdir1 + " " + tempdir2 + " " + tempdir3
+	name := "TestUnionMirror"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "union"},
+			{Name: name, Key: "upstreams", Value: upstreams},
+			{Name: name, Key: "action_policy", Value: "mirror"},
+			{Name: name, Key: "create_policy", Value: "mirror"},
+			{Name: name, Key: "search_policy", Value: "mirror"},
+			{Name: name, Key: "mirror_copies", Value: "2"},
+		},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "OpenChunkWriter", "DuplicateFiles"},
 		UnimplementableObjectMethods: []string{"MimeType"},
 	})
 }
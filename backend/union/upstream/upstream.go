@@ -33,7 +33,10 @@ type Fs struct {
 	cacheExpiry int64         // usage cache expiry time
 	cacheMutex  sync.RWMutex
 	cacheOnce   sync.Once
-	cacheUpdate bool // if the cache is updating
+	cacheUpdate bool  // if the cache is updating
+	minFree     int64 // minimum free space to create new files, 0 for none
+	hysteresis  int64 // free space above minFree needed before creating again
+	full        bool  // set if the free space dropped below minFree
 }
 
 // Directory describes a wrapped Directory
@@ -60,8 +63,12 @@ type Entry interface {
 }
 
 // New creates a new Fs based on the
-// string formatted `type:root_path(:ro/:nc)`
-func New(remote, root string, cacheTime time.Duration) (*Fs, error) {
+// string formatted `type:root_path(:ro/:nc)(:minfree=size)`
+//
+// minFree is the minimum free space for new files to be created on
+// it unless set with :minfree and hysteresis is the free space above
+// that needed before they are created on it again.
+func New(remote, root string, cacheTime time.Duration, minFree, hysteresis int64) (*Fs, error) {
 	_, configName, fsPath, err := fs.ParseRemote(remote)
 	if err != nil {
 		return nil, err
@@ -73,6 +80,17 @@ func New(remote, root string, cacheTime time.Duration) (*Fs, error) {
 		cacheExpiry: time.Now().Unix(),
 		cacheTime:   cacheTime,
 		usage:       &fs.Usage{},
+		minFree:     minFree,
+		hysteresis:  hysteresis,
+	}
+	if i := strings.LastIndex(fsPath, ":minfree="); i >= 0 {
+		var size fs.SizeSuffix
+		err = size.Set(fsPath[i+len(":minfree="):])
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad minfree in %q", remote)
+		}
+		f.minFree = int64(size)
+		fsPath = fsPath[:i]
 	}
 	if strings.HasSuffix(fsPath, ":ro") {
 		f.writable = false
@@ -258,6 +276,9 @@ func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
 }
 
 // GetFreeSpace get the free space of the fs
+//
+// If the fs only reports its quota the free space is the unused part
+// of it.
 func (f *Fs) GetFreeSpace() (int64, error) {
 	if atomic.LoadInt64(&f.cacheExpiry) <= time.Now().Unix() {
 		err := f.updateUsage()
@@ -267,10 +288,45 @@ func (f *Fs) GetFreeSpace() (int64, error) {
 	}
 	f.cacheMutex.RLock()
 	defer f.cacheMutex.RUnlock()
-	if f.usage.Free == nil {
-		return math.MaxInt64, ErrUsageFieldNotSupported
+	if f.usage.Free != nil {
+		return *f.usage.Free, nil
+	}
+	if f.usage.Total != nil && f.usage.Used != nil {
+		return *f.usage.Total - *f.usage.Used, nil
+	}
+	return math.MaxInt64, ErrUsageFieldNotSupported
+}
+
+// HasFreeSpace returns whether the fs has its minimum free space so
+// new files may be created on it.
+//
+// Once its free space has dropped below the minimum it needs the
+// minimum plus the hysteresis again before new files are created on
+// it, so they don't flip between upstreams as files come and go.
+func (f *Fs) HasFreeSpace() bool {
+	if f.minFree <= 0 {
+		return true
+	}
+	space, err := f.GetFreeSpace()
+	if err != nil {
+		return true // treat unknown free space as infinite like the policies
+	}
+	f.cacheMutex.Lock()
+	defer f.cacheMutex.Unlock()
+	threshold := f.minFree
+	if f.full {
+		threshold += f.hysteresis
+	}
+	full := space < threshold
+	if full != f.full {
+		if full {
+			fs.Logf(f, "Not creating new files as free space %v is below %v", fs.SizeSuffix(space), fs.SizeSuffix(f.minFree))
+		} else {
+			fs.Logf(f, "Creating new files again as free space is %v", fs.SizeSuffix(space))
+		}
+		f.full = full
 	}
-	return *f.usage.Free, nil
+	return !f.full
 }
 
 // GetUsedSpace get the used space of the fs
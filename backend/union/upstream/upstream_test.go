@@ -0,0 +1,91 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestFs makes an upstream with the usage given which won't be
+// read again
+func newTestFs(usage fs.Usage, minFree, hysteresis int64) *Fs {
+	return &Fs{
+		usage:       &usage,
+		cacheExpiry: math.MaxInt64,
+		minFree:     minFree,
+		hysteresis:  hysteresis,
+	}
+}
+
+func TestGetFreeSpace(t *testing.T) {
+	free, total, used := int64(10), int64(100), int64(30)
+
+	space, err := newTestFs(fs.Usage{Free: &free, Total: &total, Used: &used}, 0, 0).GetFreeSpace()
+	require.NoError(t, err)
+	assert.Equal(t, free, space)
+
+	space, err = newTestFs(fs.Usage{Total: &total, Used: &used}, 0, 0).GetFreeSpace()
+	require.NoError(t, err)
+	assert.Equal(t, total-used, space)
+
+	space, err = newTestFs(fs.Usage{Used: &used}, 0, 0).GetFreeSpace()
+	assert.Equal(t, ErrUsageFieldNotSupported, err)
+	assert.Equal(t, int64(math.MaxInt64), space)
+}
+
+func TestHasFreeSpace(t *testing.T) {
+	free := int64(100)
+	f := newTestFs(fs.Usage{Free: &free}, 50, 20)
+	for _, test := range []struct {
+		free int64
+		want bool
+	}{
+		{100, true},
+		{50, true},
+		{49, false},
+		{60, false}, // hysteresis
+		{69, false},
+		{70, true},
+		{55, true},
+		{10, false},
+	} {
+		free = test.free
+		assert.Equal(t, test.want, f.HasFreeSpace(), "free %d", test.free)
+	}
+
+	// unknown free space is treated as infinite
+	assert.True(t, newTestFs(fs.Usage{}, 50, 0).HasFreeSpace())
+
+	// no minimum
+	free = 0
+	assert.True(t, newTestFs(fs.Usage{Free: &free}, 0, 0).HasFreeSpace())
+}
+
+func TestNewMinFree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-union-upstream")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	f, err := New(dir, "", time.Minute, 1024, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024), f.minFree)
+	assert.True(t, f.IsCreatable())
+
+	f, err = New(dir+":nc:minfree=10M", "", time.Minute, 1024, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024), f.minFree)
+	assert.False(t, f.IsCreatable())
+	assert.True(t, f.IsWritable())
+
+	_, err = New(dir+":minfree=potato", "", time.Minute, 0, 0)
+	assert.Error(t, err)
+}
@@ -105,19 +105,26 @@ func (p *Prop) Hashes() (hashes map[hash.Type]string) {
 	}
 	hashes = make(map[hash.Type]string)
 	for _, checksums := range p.Checksums {
-		checksums = strings.ToLower(checksums)
-		for _, checksum := range strings.Split(checksums, " ") {
-			switch {
-			case strings.HasPrefix(checksum, "sha1:"):
-				hashes[hash.SHA1] = checksum[5:]
-			case strings.HasPrefix(checksum, "md5:"):
-				hashes[hash.MD5] = checksum[4:]
-			}
-		}
+		ParseChecksums(checksums, hashes)
 	}
 	return hashes
 }
 
+// ParseChecksums parses owncloud style checksums of the form
+// "SHA1:xxx MD5:yyy ADLER32:zzz", as found in the checksums prop and
+// the OC-Checksum header, into hashes
+func ParseChecksums(checksums string, hashes map[hash.Type]string) {
+	checksums = strings.ToLower(checksums)
+	for _, checksum := range strings.Split(checksums, " ") {
+		switch {
+		case strings.HasPrefix(checksum, "sha1:"):
+			hashes[hash.SHA1] = checksum[5:]
+		case strings.HasPrefix(checksum, "md5:"):
+			hashes[hash.MD5] = checksum[4:]
+		}
+	}
+}
+
 // PropValue is a tagged name and value
 type PropValue struct {
 	XMLName xml.Name `xml:""`
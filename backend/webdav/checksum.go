@@ -0,0 +1,125 @@
+// Owncloud style checksums of uploads and downloads
+
+package webdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/webdav/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// hash returns the hash of type t of the object if known
+func (o *Object) hash(t hash.Type) string {
+	switch t {
+	case hash.SHA1:
+		return o.sha1
+	case hash.MD5:
+		return o.md5
+	}
+	return ""
+}
+
+// readUploadedMetaData reads the metadata of the object after it was
+// uploaded with the OC-Checksum header set to checksum and checks the
+// server has the same checksum for it.
+//
+// If not the object is removed and an error returned.
+func (o *Object) readUploadedMetaData(ctx context.Context, checksum string) error {
+	o.hasMetaData = false
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return err
+	}
+	if checksum == "" {
+		return nil
+	}
+	sent := map[hash.Type]string{}
+	api.ParseChecksums(checksum, sent)
+	for hashType, want := range sent {
+		got := o.hash(hashType)
+		if got != "" && got != want {
+			_ = o.Remove(ctx)
+			return errors.Errorf("object corrupted on transfer - %v checksum mismatch (want %q got %q)", hashType, want, got)
+		}
+	}
+	return nil
+}
+
+// checksumReader reads the body of a GET checking its checksum
+type checksumReader struct {
+	o        *Object           // Object we are reading for
+	resp     *http.Response    // response of the GET
+	body     io.Reader         // reading from here
+	hashType hash.Type         // type of the checksum
+	want     string            // checksum expected
+	hasher   *hash.MultiHasher // accumulating the checksum
+	eof      bool              // whether we have read end of file
+}
+
+// newChecksumReader wraps the body of resp to check its checksum
+// against the OC-Checksum header or, if that isn't set, the checksum
+// read with the metadata.
+//
+// It returns the body unwrapped if there is no checksum to check.
+func newChecksumReader(o *Object, resp *http.Response) io.ReadCloser {
+	checksums := map[hash.Type]string{}
+	api.ParseChecksums(resp.Header.Get("OC-Checksum"), checksums)
+	for _, hashType := range []hash.Type{hash.SHA1, hash.MD5} {
+		if !o.fs.Hashes().Contains(hashType) {
+			continue
+		}
+		want := checksums[hashType]
+		if want == "" && o.hasMetaData {
+			want = o.hash(hashType)
+		}
+		if want == "" {
+			continue
+		}
+		hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(hashType))
+		if err != nil {
+			break
+		}
+		return &checksumReader{
+			o:        o,
+			resp:     resp,
+			body:     io.TeeReader(resp.Body, hasher),
+			hashType: hashType,
+			want:     want,
+			hasher:   hasher,
+		}
+	}
+	return resp.Body
+}
+
+// Read bytes from the object - see io.Reader
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.body.Read(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// Close the object and check the checksum if all the object was read
+func (r *checksumReader) Close() (err error) {
+	// Close the body at the end
+	defer fs.CheckClose(r.resp.Body, &err)
+
+	// If not end of file then can't check the checksum
+	if !r.eof {
+		return nil
+	}
+	got := r.hasher.Sums()[r.hashType]
+	if got != r.want {
+		return errors.Errorf("object corrupted on transfer - %v checksum mismatch (want %q got %q)", r.hashType, r.want, got)
+	}
+	return nil
+}
+
+// Check it satisfies the interfaces
+var _ io.ReadCloser = &checksumReader{}
@@ -0,0 +1,75 @@
+package webdav
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/backend/webdav/api"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+	hashes := map[hash.Type]string{}
+	api.ParseChecksums("SHA1:A94A8FE5CCB19BA61C4C0873D391E987982FBBD3 MD5:098F6BCD4621D373CADE4E832627B4F6 ADLER32:045d01c1", hashes)
+	assert.Equal(t, map[hash.Type]string{
+		hash.SHA1: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+		hash.MD5:  "098f6bcd4621d373cade4e832627b4f6",
+	}, hashes)
+}
+
+func TestChecksumReader(t *testing.T) {
+	const (
+		contents = "test"
+		sha1     = "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"
+	)
+	o := &Object{fs: &Fs{hasSHA1: true}}
+	newResp := func(checksum string) *http.Response {
+		resp := &http.Response{
+			Header: http.Header{},
+			Body:   ioutil.NopCloser(strings.NewReader(contents)),
+		}
+		if checksum != "" {
+			resp.Header.Set("OC-Checksum", checksum)
+		}
+		return resp
+	}
+
+	// No checksum so nothing to check
+	in := newChecksumReader(o, newResp(""))
+	_, ok := in.(*checksumReader)
+	assert.False(t, ok)
+
+	// Matching checksum
+	in = newChecksumReader(o, newResp("SHA1:"+sha1))
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	assert.Equal(t, contents, string(data))
+	assert.NoError(t, in.Close())
+
+	// Wrong checksum
+	in = newChecksumReader(o, newResp("SHA1:0000000000000000000000000000000000000000"))
+	_, err = ioutil.ReadAll(in)
+	require.NoError(t, err)
+	err = in.Close()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "corrupted on transfer")
+
+	// Not checked if not read to the end
+	in = newChecksumReader(o, newResp("SHA1:0000000000000000000000000000000000000000"))
+	_, err = in.Read(make([]byte, 1))
+	require.NoError(t, err)
+	assert.NoError(t, in.Close())
+
+	// Checksum from the metadata
+	o.hasMetaData, o.sha1 = true, sha1
+	in = newChecksumReader(o, newResp(""))
+	_, ok = in.(*checksumReader)
+	assert.True(t, ok)
+	_, err = ioutil.ReadAll(in)
+	require.NoError(t, err)
+	assert.NoError(t, in.Close())
+}
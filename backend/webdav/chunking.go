@@ -0,0 +1,237 @@
+// Chunked uploads to Nextcloud and ownCloud
+
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/backend/webdav/api"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/rclone/rclone/lib/rest"
+	"github.com/rclone/rclone/lib/resume"
+)
+
+// maxChunks is the maximum number of chunks Nextcloud accepts in an
+// upload
+const maxChunks = 10000
+
+// chunksUploadURL returns the URL of the directory uploads are
+// assembled in for a server whose files are at endpoint, or "" if
+// endpoint isn't of the form .../remote.php/dav/files/USER/
+func chunksUploadURL(endpoint *url.URL) string {
+	const filesPath = "/remote.php/dav/files/"
+	i := strings.Index(endpoint.Path, filesPath)
+	if i < 0 {
+		return ""
+	}
+	user := strings.SplitN(endpoint.Path[i+len(filesPath):], "/", 2)[0]
+	if user == "" {
+		return ""
+	}
+	u := *endpoint
+	u.Path = endpoint.Path[:i] + "/remote.php/dav/uploads/" + user + "/"
+	u.RawPath = ""
+	return u.String()
+}
+
+// uploadChunkSize returns the chunk size to upload size bytes with,
+// making it bigger than chunk_size if the upload would need too many
+// chunks
+func (f *Fs) uploadChunkSize(size int64) int64 {
+	chunkSize := int64(f.opt.ChunkSize)
+	if size > chunkSize*maxChunks {
+		chunkSize = (size + maxChunks - 1) / maxChunks
+	}
+	return chunkSize
+}
+
+// shouldUploadChunked returns true if an upload of size bytes should
+// be uploaded in chunks
+func (f *Fs) shouldUploadChunked(size int64) bool {
+	return f.chunksUploadURL != "" && f.opt.ChunkSize > 0 && size > int64(f.opt.ChunkSize)
+}
+
+// chunkedUploadExists returns true if the chunked upload directory
+// uploadDir still exists on the server
+func (f *Fs) chunkedUploadExists(ctx context.Context, uploadDir string) bool {
+	opts := rest.Opts{
+		Method:  "PROPFIND",
+		RootURL: f.chunksUploadURL,
+		Path:    uploadDir,
+		ExtraHeaders: map[string]string{
+			"Depth": "0",
+		},
+	}
+	var result api.Multistatus
+	err := f.pacer.Call(func() (bool, error) {
+		resp, err := f.srv.CallXML(ctx, &opts, nil, &result)
+		return f.shouldRetry(resp, err)
+	})
+	return err == nil
+}
+
+// updateChunked uploads the object in chunks to a temporary directory
+// on the server which is then moved into place to assemble them.
+//
+// checksum is sent as the OC-Checksum of the assembled file if set.
+//
+// This is the chunked upload protocol of Nextcloud and ownCloud. The
+// chunks are numbered from 1 and can be uploaded again if they fail,
+// so if --resume-uploads is set the upload directory is left on the
+// server on failure to carry on with next time.
+func (o *Object) updateChunked(ctx context.Context, in io.Reader, src fs.ObjectInfo, checksum string, options ...fs.OpenOption) (err error) {
+	f := o.fs
+	size := src.Size()
+	chunkSize := f.uploadChunkSize(size)
+	destinationURL, err := rest.URLJoin(f.endpoint, o.filePath())
+	if err != nil {
+		return errors.Wrap(err, "chunked upload couldn't join URL")
+	}
+	destination := destinationURL.String()
+
+	// See if we can resume a previous upload
+	var (
+		uploadDir string
+		resumer   = resume.New(ctx, f, o.remote, src)
+	)
+	if dir, ok := resumer.Resume(chunkSize); ok {
+		if f.chunkedUploadExists(ctx, dir) {
+			uploadDir = dir
+		} else {
+			fs.Debugf(o, "Can't resume chunked upload - starting again")
+			resumer.Done()
+		}
+	}
+
+	if uploadDir == "" {
+		uploadDir = "rclone-chunked-upload-" + random.String(16) + "/"
+		fs.Debugf(o, "Starting chunked upload to %q", uploadDir)
+		opts := rest.Opts{
+			Method:     "MKCOL",
+			RootURL:    f.chunksUploadURL,
+			Path:       uploadDir,
+			NoResponse: true,
+			ExtraHeaders: map[string]string{
+				"Destination": destination,
+			},
+		}
+		err = f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.Call(ctx, &opts)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			return errors.Wrap(err, "chunked upload failed to create upload directory")
+		}
+		resumer.Start(uploadDir, chunkSize)
+	}
+
+	// Remove the upload directory if something went wrong
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Leave the upload directory for the next attempt if resuming
+		retry := false
+		if apiErr, ok := errors.Cause(err).(*api.Error); ok {
+			for _, code := range retryErrorCodes {
+				retry = retry || apiErr.StatusCode == code
+			}
+		}
+		if resumer.Keep(err, retry) {
+			return
+		}
+		fs.Debugf(o, "Cancelling chunked upload: %v", err)
+		opts := rest.Opts{
+			Method:     "DELETE",
+			RootURL:    f.chunksUploadURL,
+			Path:       uploadDir,
+			NoResponse: true,
+		}
+		cancelErr := f.pacer.Call(func() (bool, error) {
+			resp, err := f.srv.Call(ctx, &opts)
+			return f.shouldRetry(resp, err)
+		})
+		if cancelErr != nil {
+			fs.Logf(o, "Failed to cancel chunked upload: %v", cancelErr)
+		}
+	}()
+
+	// Upload the chunks
+	totalLength := fmt.Sprintf("%d", size)
+	buf := make([]byte, chunkSize)
+	for position, number := int64(0), int64(1); position < size; position, number = position+chunkSize, number+1 {
+		n := chunkSize
+		if size-position < n {
+			n = size - position
+		}
+		_, err = io.ReadFull(in, buf[:n])
+		if err != nil {
+			return errors.Wrap(err, "chunked upload failed to read source")
+		}
+		if _, ok := resumer.Verify(number, buf[:n]); ok {
+			fs.Debugf(o, "Skipping chunk %d already uploaded", number)
+			continue
+		}
+		fs.Debugf(o, "Uploading chunk %d offset %d/%d size %d", number, position, size, n)
+		opts := rest.Opts{
+			Method:        "PUT",
+			RootURL:       f.chunksUploadURL,
+			Path:          fmt.Sprintf("%s%05d", uploadDir, number),
+			NoResponse:    true,
+			ContentLength: &n,
+			Options:       options,
+			ExtraHeaders: map[string]string{
+				"Destination":     destination,
+				"OC-Total-Length": totalLength,
+			},
+		}
+		err = f.pacer.Call(func() (bool, error) {
+			opts.Body = bytes.NewReader(buf[:n])
+			resp, err := f.srv.Call(ctx, &opts)
+			return f.shouldRetry(resp, err)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "chunked upload failed to upload chunk %d", number)
+		}
+		resumer.AddPart(number, position, buf[:n], "")
+	}
+
+	// Assemble the chunks into the destination
+	opts := rest.Opts{
+		Method:     "MOVE",
+		RootURL:    f.chunksUploadURL,
+		Path:       uploadDir + ".file",
+		NoResponse: true,
+		ExtraHeaders: map[string]string{
+			"Destination":     destination,
+			"OC-Total-Length": totalLength,
+		},
+	}
+	if f.useOCMtime {
+		opts.ExtraHeaders["X-OC-Mtime"] = fmt.Sprintf("%d", src.ModTime(ctx).Unix())
+	}
+	if checksum != "" {
+		opts.ExtraHeaders["OC-Checksum"] = checksum
+	}
+	var resp *http.Response
+	err = f.pacer.CallNoRetry(func() (bool, error) {
+		resp, err = f.srv.Call(ctx, &opts)
+		return f.shouldRetry(resp, err)
+	})
+	if err != nil {
+		// The chunks may be corrupt so don't resume from them
+		resumer.Done()
+		resumer = nil
+		return errors.Wrap(err, "chunked upload failed to assemble chunks")
+	}
+	resumer.Done()
+	return nil
+}
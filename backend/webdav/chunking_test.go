@@ -0,0 +1,213 @@
+package webdav
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunksUploadURL(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/remote.php/dav/files/user/", "https://example.com/remote.php/dav/uploads/user/"},
+		{"https://example.com/remote.php/dav/files/user/sub/dir/", "https://example.com/remote.php/dav/uploads/user/"},
+		{"https://example.com/nextcloud/remote.php/dav/files/user%40example.com/", "https://example.com/nextcloud/remote.php/dav/uploads/user@example.com/"},
+		{"https://example.com/remote.php/webdav/", ""},
+		{"https://example.com/remote.php/dav/files/", ""},
+		{"https://example.com/", ""},
+	} {
+		u, err := url.Parse(test.in)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, chunksUploadURL(u), test.in)
+	}
+}
+
+func TestUploadChunkSize(t *testing.T) {
+	f := &Fs{opt: Options{ChunkSize: 10}}
+	assert.Equal(t, int64(10), f.uploadChunkSize(100))
+	assert.Equal(t, int64(10), f.uploadChunkSize(10*maxChunks))
+	assert.Equal(t, int64(11), f.uploadChunkSize(10*maxChunks+1))
+}
+
+// chunkServer is a minimal server for the Nextcloud chunked upload
+// protocol
+type chunkServer struct {
+	mu       sync.Mutex
+	dirs     map[string]map[string]string // upload directories and their chunks
+	files    map[string]string            // assembled files
+	puts     int                          // number of chunks uploaded
+	failPuts int                          // fail chunk uploads after this many if > 0
+	failCode int                          // HTTP status of the failures, 403 if 0
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	const uploads = "/remote.php/dav/uploads/user/"
+	if !strings.HasPrefix(r.URL.Path, uploads) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, uploads), "/"), "/", 2)
+	dir, name := parts[0], ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if r.Method == "MKCOL" {
+		s.dirs[dir] = map[string]string{}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	chunks, ok := s.dirs[dir]
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "PROPFIND":
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = w.Write([]byte(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"></d:multistatus>`))
+	case "PUT":
+		if s.failPuts > 0 && s.puts >= s.failPuts {
+			code := s.failCode
+			if code == 0 {
+				code = http.StatusForbidden
+			}
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		chunks[name] = string(data)
+		s.puts++
+		w.WriteHeader(http.StatusCreated)
+	case "MOVE":
+		var names []string
+		for name := range chunks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var data string
+		for _, name := range names {
+			data += chunks[name]
+		}
+		if name != ".file" || r.Header.Get("OC-Total-Length") != strconv.Itoa(len(data)) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		destination, _ := url.Parse(r.Header.Get("Destination"))
+		s.files[destination.Path] = data
+		delete(s.dirs, dir)
+		w.WriteHeader(http.StatusCreated)
+	case "DELETE":
+		delete(s.dirs, dir)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "bad method", http.StatusMethodNotAllowed)
+	}
+}
+
+// newChunkTestFs makes an Fs for the Nextcloud server at URL using
+// chunks of chunkSize bytes
+func newChunkTestFs(t *testing.T, URL string, chunkSize string) *Fs {
+	f, err := NewFs("test", "", configmap.Simple{
+		"url":        URL + "/remote.php/dav/files/user/",
+		"vendor":     "nextcloud",
+		"chunk_size": chunkSize,
+	})
+	require.NoError(t, err)
+	return f.(*Fs)
+}
+
+func TestUpdateChunked(t *testing.T) {
+	ctx := context.Background()
+	s := &chunkServer{dirs: map[string]map[string]string{}, files: map[string]string{}}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	f := newChunkTestFs(t, server.URL, "4b")
+	assert.True(t, f.shouldUploadChunked(5))
+	assert.False(t, f.shouldUploadChunked(4))
+
+	const contents = "0123456789"
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), int64(len(contents)), true, nil, f)
+	o := &Object{fs: f, remote: "dir/file.txt"}
+	require.NoError(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Equal(t, map[string]string{"/remote.php/dav/files/user/dir/file.txt": contents}, s.files)
+	assert.Equal(t, 3, s.puts)
+	assert.Len(t, s.dirs, 0)
+
+	// A failed upload is cleaned up
+	s.puts, s.failPuts = 0, 1
+	err := o.updateChunked(ctx, strings.NewReader(contents), src, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "chunk 2")
+	assert.Len(t, s.dirs, 0)
+}
+
+func TestUpdateChunkedResume(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-webdav-resume")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	oldCacheDir, oldResume, oldRetries := config.CacheDir, fs.Config.ResumeUploads, fs.Config.LowLevelRetries
+	config.CacheDir, fs.Config.ResumeUploads, fs.Config.LowLevelRetries = dir, true, 1
+	defer func() {
+		config.CacheDir, fs.Config.ResumeUploads, fs.Config.LowLevelRetries = oldCacheDir, oldResume, oldRetries
+	}()
+
+	s := &chunkServer{dirs: map[string]map[string]string{}, files: map[string]string{}, failPuts: 2, failCode: http.StatusServiceUnavailable}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	f := newChunkTestFs(t, server.URL, "4b")
+
+	const contents = "0123456789"
+	src := object.NewStaticObjectInfo("file.txt", time.Unix(1600000000, 0), int64(len(contents)), true, nil, f)
+	o := &Object{fs: f, remote: "file.txt"}
+
+	// The upload directory is kept for the next attempt
+	require.Error(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Len(t, s.dirs, 1)
+	assert.Equal(t, 2, s.puts)
+
+	// Only the last chunk is uploaded when resuming
+	s.puts, s.failPuts = 0, 0
+	require.NoError(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Equal(t, 1, s.puts)
+	assert.Equal(t, contents, s.files["/remote.php/dav/files/user/file.txt"])
+	assert.Len(t, s.dirs, 0)
+
+	// Starts again if the upload directory has gone
+	s.puts, s.failPuts = 0, 2
+	require.Error(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	s.dirs = map[string]map[string]string{}
+	s.puts, s.failPuts = 0, 0
+	require.NoError(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Equal(t, 3, s.puts)
+
+	// A permanent error cancels the upload
+	s.puts, s.failPuts, s.failCode = 0, 2, http.StatusForbidden
+	require.Error(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Len(t, s.dirs, 0)
+	s.puts, s.failPuts = 0, 0
+	require.NoError(t, o.updateChunked(ctx, strings.NewReader(contents), src, ""))
+	assert.Equal(t, 3, s.puts)
+}
@@ -40,6 +40,8 @@ const (
 	maxSleep      = 2 * time.Second
 	decayConstant = 2   // bigger for slower decay, exponential
 	defaultDepth  = "1" // depth for PROPFIND
+
+	defaultChunkSize = 10 * fs.MebiByte
 )
 
 // Register with Fs
@@ -86,18 +88,36 @@ func init() {
 			Name:     "bearer_token_command",
 			Help:     "Command to run to get a bearer token",
 			Advanced: true,
+		}, {
+			Name: "chunk_size",
+			Help: `Upload chunk size for Nextcloud and ownCloud.
+
+Files bigger than this are uploaded in chunks of this size which
+are assembled on the server. This stops big uploads timing out
+through proxies which limit the size or duration of requests, and
+lets uploads be resumed with --resume-uploads.
+
+This needs the url to be of the form
+https://example.com/remote.php/dav/files/USER/ and the vendor set
+to nextcloud or owncloud. Nextcloud needs chunks of at least 5M.
+Note that the chunks will be buffered into memory.
+
+Set to 0 to disable chunked uploads.`,
+			Default:  defaultChunkSize,
+			Advanced: true,
 		}},
 	})
 }
 
 // Options defines the configuration for this backend
 type Options struct {
-	URL                string `config:"url"`
-	Vendor             string `config:"vendor"`
-	User               string `config:"user"`
-	Pass               string `config:"pass"`
-	BearerToken        string `config:"bearer_token"`
-	BearerTokenCommand string `config:"bearer_token_command"`
+	URL                string        `config:"url"`
+	Vendor             string        `config:"vendor"`
+	User               string        `config:"user"`
+	Pass               string        `config:"pass"`
+	BearerToken        string        `config:"bearer_token"`
+	BearerTokenCommand string        `config:"bearer_token_command"`
+	ChunkSize          fs.SizeSuffix `config:"chunk_size"`
 }
 
 // Fs represents a remote webdav
@@ -116,6 +136,7 @@ type Fs struct {
 	retryWithZeroDepth bool          // some vendors (sharepoint) won't list files when Depth is 1 (our default)
 	hasMD5             bool          // set if can use owncloud style checksums for MD5
 	hasSHA1            bool          // set if can use owncloud style checksums for SHA1
+	chunksUploadURL    string        // set if can use owncloud style chunked uploads
 }
 
 // Object describes a webdav object
@@ -435,10 +456,12 @@ func (f *Fs) setQuirks(ctx context.Context, vendor string) error {
 		f.useOCMtime = true
 		f.hasMD5 = true
 		f.hasSHA1 = true
+		f.chunksUploadURL = chunksUploadURL(f.endpoint)
 	case "nextcloud":
 		f.precision = time.Second
 		f.useOCMtime = true
 		f.hasSHA1 = true
+		f.chunksUploadURL = chunksUploadURL(f.endpoint)
 	case "sharepoint":
 		// To mount sharepoint, two Cookies are required
 		// They have to be set instead of BasicAuth
@@ -1141,9 +1164,32 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.Read
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode == http.StatusOK && (o.fs.hasMD5 || o.fs.hasSHA1) {
+		return newChecksumReader(o, resp), nil
+	}
 	return resp.Body, err
 }
 
+// ocChecksum returns the value of the OC-Checksum header to upload src
+// with or "" if there isn't one
+//
+// Owncloud uses one checksum only to check the upload and stores its
+// own SHA1 and MD5. Nextcloud stores the checksum you supply (SHA1 or
+// MD5) but only stores one.
+func (f *Fs) ocChecksum(ctx context.Context, src fs.ObjectInfo) string {
+	if f.hasSHA1 {
+		if sha1, _ := src.Hash(ctx, hash.SHA1); sha1 != "" {
+			return "SHA1:" + sha1
+		}
+	}
+	if f.hasMD5 {
+		if md5, _ := src.Hash(ctx, hash.MD5); md5 != "" {
+			return "MD5:" + md5
+		}
+	}
+	return ""
+}
+
 // Update the object with the contents of the io.Reader, modTime and size
 //
 // If existing is set then it updates the object rather than creating a new one
@@ -1156,6 +1202,14 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	}
 
 	size := src.Size()
+	checksum := o.fs.ocChecksum(ctx, src)
+	if size >= 0 && o.fs.shouldUploadChunked(size) {
+		err = o.updateChunked(ctx, in, src, checksum, options...)
+		if err != nil {
+			return err
+		}
+		return o.readUploadedMetaData(ctx, checksum)
+	}
 	var resp *http.Response
 	opts := rest.Opts{
 		Method:        "PUT",
@@ -1171,18 +1225,8 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		if o.fs.useOCMtime {
 			opts.ExtraHeaders["X-OC-Mtime"] = fmt.Sprintf("%d", src.ModTime(ctx).Unix())
 		}
-		// Set one upload checksum
-		// Owncloud uses one checksum only to check the upload and stores its own SHA1 and MD5
-		// Nextcloud stores the checksum you supply (SHA1 or MD5) but only stores one
-		if o.fs.hasSHA1 {
-			if sha1, _ := src.Hash(ctx, hash.SHA1); sha1 != "" {
-				opts.ExtraHeaders["OC-Checksum"] = "SHA1:" + sha1
-			}
-		}
-		if o.fs.hasMD5 && opts.ExtraHeaders["OC-Checksum"] == "" {
-			if md5, _ := src.Hash(ctx, hash.MD5); md5 != "" {
-				opts.ExtraHeaders["OC-Checksum"] = "MD5:" + md5
-			}
+		if checksum != "" {
+			opts.ExtraHeaders["OC-Checksum"] = checksum
 		}
 	}
 	err = o.fs.pacer.CallNoRetry(func() (bool, error) {
@@ -1200,9 +1244,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		_ = o.Remove(ctx)
 		return err
 	}
-	// read metadata from remote
-	o.hasMetaData = false
-	return o.readMetaData(ctx)
+	return o.readUploadedMetaData(ctx, checksum)
 }
 
 // Remove an object
@@ -42,6 +42,8 @@ docs = [
     "googlephotos.md",
     "http.md",
     "hubic.md",
+    "iclouddrive.md",
+    "ipfs.md",
     "jottacloud.md",
     "koofr.md",
     "mailru.md",
@@ -51,6 +53,7 @@ docs = [
     "onedrive.md",
     "opendrive.md",
     "qingstor.md",
+    "rsyncd.md",
     "swift.md",
     "pcloud.md",
     "premiumizeme.md",
@@ -0,0 +1,52 @@
+package apply
+
+import (
+	"context"
+	"log"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/sync"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "apply plan.json",
+	Short: `Carry out a plan made with rclone sync --plan.`,
+	Long: `
+Carry out the plan in plan.json made with ` + "`rclone sync --plan`" + `.
+
+This copies and deletes exactly the files listed in the plan between
+the source and destination the plan was made for, so the changes can
+be reviewed before they are made.
+
+Before doing anything rclone checks every file in the plan is still as
+it was when the plan was made - the source files to be copied and the
+destination files to be replaced or deleted must have the same size
+and modification time (and hash if the plan has one), and destination
+files which didn't exist mustn't have been created. If any have
+changed then nothing is done and rclone exits with an error. Make a
+new plan in that case.
+
+The files are copied first, then if there were no errors the files are
+deleted and then any empty directories are removed.
+
+    rclone sync --plan plan.json source:path dest:path
+    rclone apply plan.json
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		plan, err := sync.LoadPlan(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fsrc := cmd.NewFsDir([]string{plan.Source})
+		fdst := cmd.NewFsDir([]string{plan.Dest})
+		cmd.Run(false, true, command, func() error {
+			return sync.ApplyPlan(context.Background(), fdst, fsrc, plan)
+		})
+	},
+}
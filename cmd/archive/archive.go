@@ -0,0 +1,118 @@
+package archive
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/archive"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	format string
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(createCommand)
+	commandDefinition.AddCommand(extractCommand)
+	formatHelp := "Archive format, one of " + strings.Join(archive.Formats, ", ") + " (default from the file extension)"
+	flags.StringVarP(createCommand.Flags(), &format, "format", "", format, formatHelp)
+	flags.StringVarP(extractCommand.Flags(), &format, "format", "", format, formatHelp)
+}
+
+// getFormat returns the archive format from --format or the name of
+// the archive
+func getFormat(name string) (string, error) {
+	if format != "" {
+		return format, archive.CheckFormat(format)
+	}
+	return archive.FormatFromName(name)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "archive",
+	Short: `Create and extract tar and zip archives on remotes.`,
+	Long: `
+Pack a directory of files into a single tar or zip archive, or unpack
+one, with the archive on any remote. This is useful for putting lots
+of small files into cold storage as one object.
+
+See the subcommands for details.
+`,
+}
+
+var createCommand = &cobra.Command{
+	Use:   "create source:path dest:path/archive",
+	Short: `Pack the files in source:path into an archive.`,
+	Long: `
+Pack the files in source:path into an archive at dest:path/archive.
+
+The archive is streamed to the destination as it is made so no local
+temporary files are used if the destination supports streaming
+uploads.
+
+The format is worked out from the extension of the archive name -
+` + "`.tar`" + `, ` + "`.tar.gz`" + ` or ` + "`.tgz`" + `, or ` + "`.zip`" + ` - or can be set with ` + "`--format`" + `.
+
+The filtering flags (eg ` + "`--exclude`" + `) can be used to choose which files
+go in the archive.
+
+    rclone archive create remote:photos/2019 remote:cold/photos-2019.tar.gz
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc := cmd.NewFsDir(args[0:1])
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:2])
+		archiveFormat, err := getFormat(dstFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmd.Run(true, true, command, func() error {
+			return archive.Create(context.Background(), fsrc, fdst, dstFileName, archiveFormat)
+		})
+	},
+}
+
+var extractCommand = &cobra.Command{
+	Use:   "extract source:path/archive dest:path",
+	Short: `Unpack an archive into dest:path.`,
+	Long: `
+Unpack the archive at source:path/archive into dest:path.
+
+Tar archives are read as a stream. Zip archives are read with ranged
+reads as their directory is at the end.
+
+The format is worked out from the extension of the archive name or
+can be set with ` + "`--format`" + `.
+
+Names in the archive which would point outside dest:path are
+made relative to it. The filtering flags (eg ` + "`--include`" + `) can be
+used to extract only some of the files, eg
+
+    rclone archive extract remote:cold/photos-2019.tar.gz /tmp/photos --include "*.jpg"
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, srcFileName := cmd.NewFsFile(args[0])
+		if srcFileName == "" {
+			log.Fatalf("%q is not a file", args[0])
+		}
+		archiveFormat, err := getFormat(srcFileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fdst := cmd.NewFsDir(args[1:2])
+		cmd.Run(true, true, command, func() error {
+			ctx := context.Background()
+			o, err := fsrc.NewObject(ctx, srcFileName)
+			if err != nil {
+				return err
+			}
+			return archive.Extract(ctx, o, archiveFormat, fdst)
+		})
+	},
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/backup"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(restoreCommand)
+	commandDefinition.AddCommand(snapshotsCommand)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "backup source:path repo:path",
+	Short: `Make a deduplicated snapshot backup of source in a repository.`,
+	Long: `
+Make a snapshot of source:path in the backup repository at repo:path,
+creating the repository if it doesn't exist.
+
+Files are split into variable sized chunks (about 1MB on average)
+using content defined chunking and each chunk is stored in the
+repository named by its SHA-256 hash. Chunks which are already in the
+repository are never uploaded again, so each backup only uploads the
+data which has changed since the last one, even for files which were
+modified or renamed, and identical data in different files is only
+stored once.
+
+Files which have the same size and modification time as in the last
+snapshot of the same source aren't read again.
+
+If any files fail to be backed up then the snapshot isn't saved, so
+run the backup again once the errors have been fixed. The chunks
+which were uploaded are kept and won't be uploaded again.
+
+The repository can be on any remote. It isn't encrypted or compressed
+so use a crypt remote for the repository if required.
+
+    rclone backup /home/user remote:backups
+    rclone backup snapshots remote:backups
+    rclone backup restore remote:backups latest /tmp/restore
+
+The filtering flags (eg ` + "`--exclude`" + `) can be used to choose which files
+are backed up.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, frepo := cmd.NewFsSrcDst(args)
+		cmd.Run(true, true, command, func() error {
+			_, err := backup.Backup(context.Background(), fsrc, frepo)
+			return err
+		})
+	},
+}
+
+var restoreCommand = &cobra.Command{
+	Use:   "restore repo:path snapshot dest:path",
+	Short: `Restore a snapshot from a backup repository.`,
+	Long: `
+Restore the files in snapshot from the backup repository at repo:path
+to dest:path.
+
+The snapshot can be given as its ID, a unique prefix of its ID or
+` + "`latest`" + ` for the most recent snapshot. Use ` + "`rclone backup snapshots`" + `
+to list them.
+
+Files in dest:path with the same size and modification time as those
+in the snapshot are skipped. Other files in dest:path are left alone.
+
+The filtering flags (eg ` + "`--include`" + `) can be used to restore only
+some of the files, eg
+
+    rclone backup restore remote:backups latest /tmp/restore --include "/docs/**"
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(3, 3, command, args)
+		frepo := cmd.NewFsDir(args[0:1])
+		fdst := cmd.NewFsDir(args[2:3])
+		cmd.Run(true, true, command, func() error {
+			return backup.Restore(context.Background(), frepo, args[1], fdst)
+		})
+	},
+}
+
+var snapshotsCommand = &cobra.Command{
+	Use:   "snapshots repo:path",
+	Short: `List the snapshots in a backup repository.`,
+	Long: `
+List the snapshots in the backup repository at repo:path oldest first,
+showing the ID, time, number of files, total size and source of each.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		frepo := cmd.NewFsDir(args)
+		cmd.Run(false, false, command, func() error {
+			ctx := context.Background()
+			r, err := backup.OpenRepo(ctx, frepo, false)
+			if err != nil {
+				return err
+			}
+			snaps, err := r.Snapshots(ctx)
+			if err != nil {
+				return err
+			}
+			for _, snap := range snaps {
+				fmt.Printf("%s  %s  %8d  %9v  %s\n", snap.ID, snap.Time.Local().Format("2006-01-02 15:04:05"), len(snap.Files), fs.SizeSuffix(snap.Size()), snap.Source)
+			}
+			return nil
+		})
+	},
+}
@@ -0,0 +1,65 @@
+package backupprune
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	opt = operations.PruneOpt{
+		Template: "{date}",
+	}
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &opt.Template, "template", "", opt.Template, "Template the directory names were made with")
+	flags.IntVarP(cmdFlags, &opt.KeepDaily, "keep-daily", "", opt.KeepDaily, "Keep the newest directory for this many days")
+	flags.IntVarP(cmdFlags, &opt.KeepWeekly, "keep-weekly", "", opt.KeepWeekly, "Keep the newest directory for this many weeks")
+	flags.IntVarP(cmdFlags, &opt.KeepMonthly, "keep-monthly", "", opt.KeepMonthly, "Keep the newest directory for this many months")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "backup-prune remote:path",
+	Short: `Remove old dated backup directories made with --backup-dir.`,
+	Long: `
+Remove the dated directories in remote:path which aren't kept by the
+retention policy.
+
+This is for use with a dated ` + "`--backup-dir`" + `, for example
+
+    rclone sync /path/to/local remote:current --backup-dir "remote:old/{date}"
+    rclone backup-prune remote:old --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+
+The directory names are matched against ` + "`--template`" + ` (default
+` + "`{date}`" + `) to find when they were made and any directories whose
+names don't match are left alone. See ` + "`--backup-dir`" + ` for the
+templates which can be used.
+
+The template may contain ` + "`/`" + ` to prune nested directories, eg
+` + "`--template {YYYY}/{MM}/{DD}`" + ` for a backup dir of
+` + "`remote:old/{YYYY}/{MM}/{DD}`" + `, in which case the directories
+that deep are matched on their whole path under remote:path.
+
+For each of ` + "`--keep-daily`" + `, ` + "`--keep-weekly`" + ` and ` + "`--keep-monthly`" + `
+the newest directory in each of the last N days, weeks or months
+which have a directory is kept. A directory is kept if any of these
+keep it, and everything else is purged. Weeks are ISO weeks starting
+on Monday.
+
+**Important**: Since this can cause data loss, test first with the
+` + "`--dry-run` or the `--interactive`/`-i`" + ` flag.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(true, false, command, func() error {
+			return operations.PruneBackupDirs(context.Background(), f, opt)
+		})
+	},
+}
@@ -0,0 +1,85 @@
+package bisync
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/bisync"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	opt = bisync.DefaultOpt
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &opt.Resync, "resync", "", opt.Resync, "Copy both ways to make the initial listings")
+	flags.FVarP(cmdFlags, &opt.ConflictPolicy, "conflict-policy", "", "How to resolve files changed on both sides: newer|rename|path1|path2")
+	flags.IntVarP(cmdFlags, &opt.MaxDelete, "max-delete-percent", "", opt.MaxDelete, "Abort if more than this percentage of files would be deleted on either side")
+	flags.BoolVarP(cmdFlags, &opt.Force, "force", "", opt.Force, "Ignore --max-delete-percent")
+	flags.BoolVarP(cmdFlags, &opt.CheckSum, "check-sum", "", opt.CheckSum, "Use hashes rather than modification times to detect changes")
+	flags.StringVarP(cmdFlags, &opt.WorkDir, "workdir", "", opt.WorkDir, "Directory to keep the listings in (default: cache dir/bisync)")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "bisync remote1:path1 remote2:path2",
+	Short: `Bidirectional synchronization between two paths.`,
+	Long: `
+Bidirectional synchronization between two paths.
+
+Changes made on either side since the last run are propagated to the
+other side: new and modified files are copied across and deleted
+files are deleted from the other side. Only files are synced, empty
+directories are ignored.
+
+To do this rclone keeps a listing of each side from the end of the
+last successful run in the cache directory (or ` + "`--workdir`" + `). The
+first run must be made with ` + "`--resync`" + ` which copies path1 to
+path2 then path2 to path1 (so path1 wins where the two differ) and
+makes the listings.
+
+    rclone bisync --resync remote1:path1 remote2:path2
+    rclone bisync remote1:path1 remote2:path2
+
+When a file has been changed on both sides the ` + "`--conflict-policy`" + `
+flag decides what happens
+
+- ` + "`newer`" + ` - the most recently modified file wins (default). If the
+  modification times are the same or can't be read then ` + "`rename`" + ` is used.
+- ` + "`rename`" + ` - both files are kept, renamed with a ` + "`..path1`" + ` and
+  ` + "`..path2`" + ` suffix, and copied to the other side.
+- ` + "`path1`" + ` - the file on path1 wins.
+- ` + "`path2`" + ` - the file on path2 wins.
+
+A file modified on one side and deleted on the other is always kept.
+Files changed identically on both sides are left alone.
+
+As a safety check bisync will refuse to run if it would delete more
+than ` + "`--max-delete-percent`" + ` of the files on either side. Use
+` + "`--force`" + ` to override this.
+
+The listings are only updated at the end of a run with no errors. If
+a run is interrupted or fails the next run compares both sides
+against the last good listings again, so any changes which were
+already propagated are seen as identical on both sides and anything
+left over is retried.
+
+By default changes are detected with size and modification time. Use
+` + "`--check-sum`" + ` to detect them with hashes instead on remotes which
+don't support modification times.
+
+**Important**: Since this can cause data loss, test first with the
+` + "`--dry-run` or the `--interactive`/`-i`" + ` flag.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		f1 := cmd.NewFsDir(args[0:1])
+		f2 := cmd.NewFsDir(args[1:2])
+		cmd.Run(true, true, command, func() error {
+			return bisync.Bisync(context.Background(), f1, f2, &opt)
+		})
+	},
+}
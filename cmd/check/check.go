@@ -6,9 +6,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/hashdb"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -24,12 +26,18 @@ var (
 	match        = ""
 	differ       = ""
 	errFile      = ""
+	cacheDB      = false
+	cacheDBPath  = ""
+	quickCompare = fs.SizeSuffix(0)
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &download, "download", "", download, "Check by downloading rather than with hash.")
+	flags.BoolVarP(cmdFlags, &cacheDB, "cache-db", "", cacheDB, "Keep hashes in a database and only re-hash changed files.")
+	flags.StringVarP(cmdFlags, &cacheDBPath, "cache-db-path", "", cacheDBPath, "Path of the hash database to use with --cache-db (default in the cache dir).")
+	flags.FVarP(cmdFlags, &quickCompare, "quick-compare", "", "Check by downloading only this much of the start and end of each file.")
 	AddFlags(cmdFlags)
 }
 
@@ -114,6 +122,19 @@ func GetCheckOpt(fsrc, fdst fs.Fs) (opt *operations.CheckOpt, close func(), err
 		return nil, nil, err
 	}
 
+	if cacheDB {
+		path := cacheDBPath
+		if path == "" {
+			path = hashdb.DefaultPath()
+		}
+		db, err := hashdb.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		opt.HashDB = db
+		closers = append(closers, db)
+	}
+
 	close = func() {
 		for _, closer := range closers {
 			err := closer.Close()
@@ -142,16 +163,51 @@ If you supply the --download flag, it will download the data from
 both remotes and check them against each other on the fly.  This can
 be useful for remotes that don't support hashes or if you really want
 to check all the data.
+
+If you supply the --cache-db flag, the hash of each file checked is
+recorded in a database along with its size and modification time. On
+later checks the hash is only calculated again for files whose size or
+modification time has changed, which makes regular checks of large
+remotes much quicker, especially for remotes which calculate hashes by
+reading the data such as local disks or sftp. The database is kept in
+the cache directory (see --cache-dir) unless --cache-db-path is
+given. It can be shared between different checks.
+
+Note that with --cache-db a file which has been corrupted without its
+size or modification time changing won't be detected, so run a check
+without it occasionally to read all the data. It can't be used with
+--download or --quick-compare as they don't use the hashes.
+
+If you supply the --quick-compare flag with a size, eg
+` + "`--quick-compare 1M`" + `, it will download just that much data from the
+start and the end of each file from both remotes and compare hashes of
+those parts. This is a middle ground between --size-only and
+--download which is useful for checking large files such as media
+libraries on remotes which don't support hashes. It will find
+truncated files and transfers which went wrong at the start or the end
+but not corruption in the middle of files. Files no bigger than twice
+the size given are downloaded completely.
 ` + FlagsHelp,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, fdst := cmd.NewFsSrcDst(args)
 		cmd.Run(false, true, command, func() error {
+			switch {
+			case download && quickCompare > 0:
+				return errors.New("can't use --download and --quick-compare together")
+			case cacheDB && download:
+				return errors.New("can't use --cache-db with --download as it doesn't use hashes")
+			case cacheDB && quickCompare > 0:
+				return errors.New("can't use --cache-db with --quick-compare as it doesn't use hashes")
+			}
 			opt, close, err := GetCheckOpt(fsrc, fdst)
 			if err != nil {
 				return err
 			}
 			defer close()
+			if quickCompare > 0 {
+				return operations.CheckQuick(context.Background(), opt, int64(quickCompare))
+			}
 			if download {
 				return operations.CheckDownload(context.Background(), opt)
 			}
@@ -32,7 +32,9 @@ import (
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/fspath"
 	fslog "github.com/rclone/rclone/fs/log"
+	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/rc/rcflags"
+	"github.com/rclone/rclone/fs/rc/rcgrpc"
 	"github.com/rclone/rclone/fs/rc/rcserver"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/spf13/cobra"
@@ -236,13 +238,17 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 	if !showStats && ShowStats() {
 		showStats = true
 	}
-	if fs.Config.Progress {
+	if fs.Config.OutputFormat == fs.OutputFormatJSONL {
+		stopStats = startEventStats()
+	} else if fs.Config.Progress {
 		stopStats = startProgress()
 	} else if showStats {
 		stopStats = StartStats()
 	}
 	SigInfoHandler()
 	for try := 1; try <= *retries; try++ {
+		// Only report the failures of the last try
+		operations.ResetFailures()
 		cmdErr = f()
 		cmdErr = fs.CountError(cmdErr)
 		lastErr := accounting.GlobalStats().GetLastError()
@@ -283,6 +289,13 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 		}
 	}
 	stopStats()
+	accounting.GlobalStats().WriteStatsEvent(accounting.EventResult, cmdErr)
+	if fs.Config.FailureReport != "" {
+		err := operations.WriteFailureReport(fs.Config.FailureReport)
+		if err != nil {
+			fs.Errorf(nil, "%v", err)
+		}
+	}
 	if showStats && (accounting.GlobalStats().Errored() || *statsInterval > 0) {
 		accounting.GlobalStats().Log()
 	}
@@ -337,6 +350,25 @@ func CheckArgs(MinArgs, MaxArgs int, cmd *cobra.Command, args []string) {
 //
 // It returns a func which should be called to stop the stats.
 func StartStats() func() {
+	return startStatsTicker(func() {
+		accounting.GlobalStats().Log()
+	})
+}
+
+// startEventStats writes the stats as an event every statsInterval
+// for --output-format jsonl
+//
+// It returns a func which should be called to stop the stats.
+func startEventStats() func() {
+	return startStatsTicker(func() {
+		accounting.GlobalStats().WriteStatsEvent(accounting.EventStats, nil)
+	})
+}
+
+// startStatsTicker calls fn every statsInterval
+//
+// It returns a func which should be called to stop the stats.
+func startStatsTicker(fn func()) func() {
 	if *statsInterval <= 0 {
 		return func() {}
 	}
@@ -349,7 +381,7 @@ func StartStats() func() {
 		for {
 			select {
 			case <-ticker.C:
-				accounting.GlobalStats().Log()
+				fn()
 			case <-stopStats:
 				ticker.Stop()
 				return
@@ -376,6 +408,15 @@ func initConfig() {
 		log.Fatalf("Failed to load filters: %v", err)
 	}
 
+	// Write the --output-format events to a file if required
+	if fs.Config.OutputFile != "" {
+		out, err := os.OpenFile(fs.Config.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			log.Fatalf("Failed to open output file: %v", err)
+		}
+		accounting.SetEventOutput(out)
+	}
+
 	// Write the args for debug purposes
 	fs.Debugf("rclone", "Version %q starting with parameters %q", fs.Version, os.Args)
 
@@ -384,6 +425,16 @@ func initConfig() {
 	if err != nil {
 		log.Fatalf("Failed to start remote control: %v", err)
 	}
+	_, err = rcgrpc.Start(&rcflags.Opt)
+	if err != nil {
+		log.Fatalf("Failed to start gRPC remote control: %v", err)
+	}
+
+	// Start pushing the stats if configured
+	err = accounting.StartStatsPush()
+	if err != nil {
+		log.Fatalf("Failed to start pushing stats: %v", err)
+	}
 
 	// Setup CPU profiling if desired
 	if *cpuProfile != "" {
@@ -2,9 +2,12 @@ package copy
 
 import (
 	"context"
+	"log"
+	"os"
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/filesfrom"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
 	"github.com/spf13/cobra"
@@ -12,12 +15,17 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	filesFromStream    = ""
+	streamOpt          = filesfrom.DefaultOpt
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after copy")
+	flags.StringVarP(cmdFlags, &filesFromStream, "files-from-stream", "", filesFromStream, "Keep reading paths to copy from this file or - for stdin, copying them as they arrive")
+	flags.IntVarP(cmdFlags, &streamOpt.BatchSize, "stream-batch-size", "", streamOpt.BatchSize, "Most files to copy in a batch with --files-from-stream")
+	flags.DurationVarP(cmdFlags, &streamOpt.BatchWait, "stream-batch-wait", "", streamOpt.BatchWait, "Longest to wait for a batch to fill with --files-from-stream")
 }
 
 var commandDefinition = &cobra.Command{
@@ -71,6 +79,28 @@ recently very efficiently like this:
 
     rclone copy --max-age 24h --no-traverse /path/to/src remote:
 
+With ` + "`--files-from-stream`" + ` rclone reads the paths of the files to
+copy, one per line, from the file given (or stdin if it is ` + "`-`" + `) and
+copies them as they arrive rather than listing the source. This is for
+pipelines where another program produces the files to copy. Paths are
+relative to source:path like ` + "`--files-from`" + `, and blank lines and
+lines starting with ` + "`#`" + ` or ` + "`;`" + ` are ignored.
+
+Paths are gathered into batches of up to ` + "`--stream-batch-size`" + `
+files (default 100), waiting at most ` + "`--stream-batch-wait`" + ` (default
+1s) for a batch to fill up, and each batch is copied with
+` + "`--transfers`" + ` files at once. As each file finishes a line of JSON is
+written to stdout with the ` + "`path`" + ` and the ` + "`result`" + ` which is one of
+` + "`copied`, `unchanged`, `missing` or `error`" + ` (with the ` + "`error`" + `).
+
+rclone exits when stdin or the file ends. If the file is a named pipe
+it is opened again each time the program writing to it closes it, so
+rclone keeps running until it is stopped.
+
+    mkfifo /tmp/to-copy
+    rclone copy --files-from-stream /tmp/to-copy /data remote:data &
+    echo "dir/new-file.txt" > /tmp/to-copy
+
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics.
 
 **Note**: Use the ` + "`--dry-run` or the `--interactive`/`-i`" + ` flag to test without copying anything.
@@ -84,6 +114,16 @@ recently very efficiently like this:
 			srcFileName = fsrc.Root()[7:]
 		}
 
+		if filesFromStream != "" {
+			if srcFileName != "" {
+				log.Fatalf("Can't use --files-from-stream with a file as the source")
+			}
+			cmd.Run(false, true, command, func() error {
+				return filesfrom.Stream(context.Background(), fdst, fsrc, filesFromStream, os.Stdout, &streamOpt)
+			})
+			return
+		}
+
 		cmd.Run(true, true, command, func() error {
 			if srcFileName == "" {
 				return sync.CopyDir(context.Background(), fdst, fsrc, createEmptySrcDirs)
@@ -44,6 +44,13 @@ the files in remote:path.
 
     rclone cryptcheck remote:path encryptedremote:path
 
+If the crypted remote was written with the "store_hashes" option then
+the hashes of the unencrypted files stored in the metadata are
+compared with the hashes of the files on remote: instead, so the
+files don't need to be read and encrypted again if remote: can
+supply the hashes cheaply.  Files without stored hashes are checked
+as above.
+
 After it has run it will log the status of the encryptedremote:.
 ` + check.FlagsHelp,
 	Run: func(command *cobra.Command, args []string) {
@@ -70,6 +77,12 @@ func cryptCheck(ctx context.Context, fdst, fsrc fs.Fs) error {
 	}
 	fs.Infof(nil, "Using %v for hash comparisons", hashType)
 
+	// Find a hash to compare with hashes stored on the crypted remote
+	storedHashType := fcrypt.Hashes().Overlap(fsrc.Hashes()).GetOne()
+	if storedHashType != hash.None {
+		fs.Infof(nil, "Using %v for comparisons with stored hashes", storedHashType)
+	}
+
 	opt, close, err := check.GetCheckOpt(fsrc, fcrypt)
 	if err != nil {
 		return err
@@ -82,6 +95,12 @@ func cryptCheck(ctx context.Context, fdst, fsrc fs.Fs) error {
 	// it also returns whether it couldn't be hashed
 	opt.Check = func(ctx context.Context, dst, src fs.Object) (differ bool, noHash bool, err error) {
 		cryptDst := dst.(*crypt.Object)
+		if storedHashType != hash.None {
+			differ, found, err := checkStoredHash(ctx, cryptDst, src, storedHashType)
+			if err != nil || found {
+				return differ, false, err
+			}
+		}
 		underlyingDst := cryptDst.UnWrap()
 		underlyingHash, err := underlyingDst.Hash(ctx, hashType)
 		if err != nil {
@@ -107,3 +126,29 @@ func cryptCheck(ctx context.Context, fdst, fsrc fs.Fs) error {
 
 	return operations.CheckFn(ctx, opt)
 }
+
+// checkStoredHash compares the hash of src with the hash stored on
+// the crypted remote for dst.
+//
+// It returns found false if either hash isn't available.
+func checkStoredHash(ctx context.Context, dst *crypt.Object, src fs.Object, hashType hash.Type) (differ bool, found bool, err error) {
+	dstHash, err := dst.Hash(ctx, hashType)
+	if err != nil {
+		return true, false, errors.Wrap(err, "error reading stored hash")
+	}
+	if dstHash == "" {
+		return false, false, nil
+	}
+	srcHash, err := src.Hash(ctx, hashType)
+	if err != nil {
+		return true, false, errors.Wrap(err, "error reading hash")
+	}
+	if srcHash == "" {
+		return false, false, nil
+	}
+	if srcHash != dstHash {
+		fs.Errorf(src, "stored %v hashes differ %q vs %q", hashType, dstHash, srcHash)
+		return true, true, nil
+	}
+	return false, true, nil
+}
@@ -11,13 +11,17 @@ import (
 )
 
 var (
-	dedupeMode = operations.DeduplicateInteractive
+	dedupeMode   = operations.DeduplicateInteractive
+	byHash       = false
+	byHashAction = operations.DedupeHashReport
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlag := commandDefinition.Flags()
 	flags.FVarP(cmdFlag, &dedupeMode, "dedupe-mode", "", "Dedupe mode interactive|skip|first|newest|oldest|largest|smallest|rename.")
+	flags.BoolVarP(cmdFlag, &byHash, "by-hash", "", byHash, "Find files with identical contents whatever their names.")
+	flags.FVarP(cmdFlag, &byHashAction, "by-hash-action", "", "What to do with identical files found with --by-hash report|copy|link.")
 }
 
 var commandDefinition = &cobra.Command{
@@ -119,9 +123,38 @@ For example to rename all the identically named photos in your Google Photos dir
 Or
 
     rclone dedupe rename "drive:Google Photos"
+
+### Finding identical files with different names ###
+
+With the ` + "`--by-hash`" + ` flag ` + "`dedupe`" + ` looks for files with
+identical contents anywhere under the path, whatever their names and
+directories are, instead of files with duplicate names. This works on
+any backend which supports hashes. Files are considered identical if
+they have the same size and hash. Empty files are ignored.
+
+The oldest of each set of identical files is kept and what happens to
+the others is set with ` + "`--by-hash-action`" + `
+
+  * ` + "`" + `--by-hash-action report` + "`" + ` - log the identical files and the space they use (the default).
+  * ` + "`" + `--by-hash-action copy` + "`" + ` - replace them with server side copies of the file kept.
+  * ` + "`" + `--by-hash-action link` + "`" + ` - replace them with hard links to the file kept (local only).
+
+Replacing a file gives it the modification time of the file kept. For
+example to reclaim the space used by identical files scattered around
+a local disk, do
+
+    rclone dedupe --by-hash --by-hash-action link /path/to/photos
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 2, command, args)
+		if byHash {
+			cmd.CheckArgs(1, 1, command, args)
+			fdst := cmd.NewFsSrc(args)
+			cmd.Run(false, false, command, func() error {
+				return operations.DeduplicateByHash(context.Background(), fdst, byHashAction)
+			})
+			return
+		}
 		if len(args) > 1 {
 			err := dedupeMode.Set(args[0])
 			if err != nil {
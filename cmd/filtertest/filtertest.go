@@ -0,0 +1,117 @@
+// Package filtertest provides the filter-test command.
+package filtertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/list"
+	"github.com/spf13/cobra"
+)
+
+var (
+	excludedOnly = false
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &excludedOnly, "excluded", "", excludedOnly, "Only show the excluded files and directories")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "filter-test remote:path",
+	Short: `Show which files the filters include and why.`,
+	Long: `
+This walks remote:path in the same way a sync would and prints each
+file and directory found prefixed with ` + "`+`" + ` if the filters
+include it or ` + "`-`" + ` if they exclude it, followed by the reason
+- which filter rule matched or which other filter flag decided it.
+
+Use this to check complicated filter rules and filter files before
+using them with a sync which might delete things. For example
+
+    rclone filter-test --filter-from rules.txt remote:path
+
+might print
+
+    + file.jpg: matched rule "+ *.jpg"
+    - file.txt: matched rule "- *"
+    - tmp/: matched rule "- /tmp/**"
+    + dir/: no rule matched
+
+The rules are checked in the order they were given and the first
+matching one is used. Directories which are excluded aren't listed, as
+in a sync, so the files in them aren't shown.
+
+Use ` + "`--excluded`" + ` to only show the files and directories which
+are excluded and ` + "`--max-depth`" + ` to limit how deep the listing
+goes.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			return Explain(context.Background(), fsrc, os.Stdout)
+		})
+	},
+}
+
+// Explain lists f writing each file and directory found to out with
+// whether the filters include it and why.
+func Explain(ctx context.Context, f fs.Fs, out io.Writer) error {
+	return explainDir(ctx, f, "", 1, out)
+}
+
+// explainDir explains the entries of dir which is depth levels deep,
+// recursing into the included directories.
+func explainDir(ctx context.Context, f fs.Fs, dir string, depth int, out io.Writer) error {
+	entries, err := list.DirSorted(ctx, f, true, dir)
+	if err != nil {
+		return err
+	}
+	includeDirs, includeObjects, listingReason := filter.Active.ExplainListing(f, dir, entries)
+	for _, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			include, reason := false, listingReason
+			if includeObjects {
+				include, reason = filter.Active.ExplainObject(ctx, x)
+			}
+			show(out, x.Remote(), include, reason)
+		case fs.Directory:
+			include, reason := false, listingReason
+			if includeDirs {
+				include, reason, err = filter.Active.ExplainDirectory(ctx, f, x.Remote())
+				if err != nil {
+					return err
+				}
+			}
+			show(out, x.Remote()+"/", include, reason)
+			if include && (fs.Config.MaxDepth < 0 || depth < fs.Config.MaxDepth) {
+				err = explainDir(ctx, f, x.Remote(), depth+1, out)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// show writes a line for remote to out
+func show(out io.Writer, remote string, include bool, reason filter.Reason) {
+	sign := "+"
+	if !include {
+		sign = "-"
+	} else if excludedOnly {
+		return
+	}
+	_, _ = fmt.Fprintf(out, "%s %s: %s\n", sign, remote, reason)
+}
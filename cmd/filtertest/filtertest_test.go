@@ -0,0 +1,79 @@
+package filtertest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	fstest.Initialise()
+	dir, err := ioutil.TempDir("", "rclone-filter-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	for _, name := range []string{
+		"file.jpg",
+		"file.txt",
+		"sub/file.jpg",
+		"sub/big.jpg",
+		"tmp/file.jpg",
+		"skip/.nobackup",
+		"skip/file.jpg",
+	} {
+		data := []byte("hello")
+		if filepath.Base(name) == "big.jpg" {
+			data = bytes.Repeat(data, 100)
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0777))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0666))
+	}
+
+	oldActive := filter.Active
+	defer func() {
+		filter.Active = oldActive
+	}()
+	opt := filter.DefaultOpt
+	opt.FilterRule = []string{"- /tmp/**", "+ *.jpg", "- *"}
+	opt.ExcludeFile = []string{".nobackup"}
+	opt.MaxSize = 100 * fs.Byte
+	filter.Active, err = filter.NewFilter(&opt)
+	require.NoError(t, err)
+
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, Explain(context.Background(), f, &buf))
+	assert.Equal(t, `+ file.jpg: matched rule "+ *.jpg"
+- file.txt: matched rule "- *"
+- skip/: directory contains an --exclude-if-present file
++ sub/: matched rule "+ *.jpg"
+- sub/big.jpg: larger than --max-size
++ sub/file.jpg: matched rule "+ *.jpg"
+- tmp/: matched rule "- /tmp/**"
+`, buf.String())
+
+	excludedOnly = true
+	defer func() {
+		excludedOnly = false
+	}()
+	buf.Reset()
+	require.NoError(t, Explain(context.Background(), f, &buf))
+	assert.Equal(t, `- file.txt: matched rule "- *"
+- skip/: directory contains an --exclude-if-present file
+- sub/big.jpg: larger than --max-size
+- tmp/: matched rule "- /tmp/**"
+`, buf.String())
+}
@@ -0,0 +1,64 @@
+package merge
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/merge"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policy = merge.PolicyNewest
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &policy, "policy", "", policy, "Which file to copy if in more than one source, one of "+strings.Join(merge.Policies, ", "))
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "merge source:path [source:path]... dest:path",
+	Short: `Copy files from several sources into one destination.`,
+	Long: `
+Copy the files from all the sources into dest:path in a single
+operation, rather than running one copy for each source.
+
+All the sources and the destination are listed at the same time. If
+more than one source has a file with the same path then only one of
+them is copied, chosen by ` + "`--policy`" + `:
+
+- ` + "`newest`" + ` - the file with the latest modification time (the default)
+- ` + "`largest`" + ` - the biggest file
+- ` + "`first`" + ` - the file from the source given first on the command line
+
+If the files are equal by the chosen policy then the one from the
+source given first is used.
+
+Like ` + "`copy`" + `, files which are already the same in the destination
+aren't copied again and nothing is deleted from the destination.
+
+    rclone merge --policy newest laptop:photos phone:photos backup:photos
+
+The filtering flags (eg ` + "`--exclude`" + `) apply to all the sources.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 1e6, command, args)
+		if err := merge.CheckPolicy(policy); err != nil {
+			log.Fatal(err)
+		}
+		var fsrcs []fs.Fs
+		for i := range args[:len(args)-1] {
+			fsrcs = append(fsrcs, cmd.NewFsDir(args[i:i+1]))
+		}
+		fdst := cmd.NewFsDir(args[len(args)-1:])
+		cmd.Run(true, true, command, func() error {
+			return merge.Merge(context.Background(), fdst, fsrcs, policy)
+		})
+	},
+}
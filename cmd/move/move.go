@@ -3,7 +3,9 @@ package move
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -14,6 +16,7 @@ import (
 var (
 	deleteEmptySrcDirs = false
 	createEmptySrcDirs = false
+	rollback           = false
 )
 
 func init() {
@@ -21,6 +24,7 @@ func init() {
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &deleteEmptySrcDirs, "delete-empty-src-dirs", "", deleteEmptySrcDirs, "Delete empty source dirs after move")
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after move")
+	flags.BoolVarP(cmdFlags, &rollback, "rollback", "", rollback, "Undo the move recorded in --move-journal")
 }
 
 var commandDefinition = &cobra.Command{
@@ -52,14 +56,41 @@ can speed transfers up greatly.
 ` + "`--dry-run` or the `--interactive`/`-i`" + ` flag.
 
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics.
+
+If you supply ` + "`--move-journal file`" + ` then each file is moved by
+copying it, checking the copy by size and hash (or by reading both
+files if they don't have a hash in common) and only then deleting the
+original, with each step recorded in the journal file. Server side
+moves aren't used in this mode, which can only be used to move a
+directory.
+
+If the move is interrupted, run the same command again to resume it.
+To undo it instead, run it again with ` + "`--rollback`" + ` as well. This copies
+the files which were moved back to the source and removes the copies
+in the destination of files which hadn't been moved yet, then removes
+the journal. Files which were already in the destination before the
+move are left there, but can't be restored if they were overwritten
+unless ` + "`--backup-dir`" + ` was in use.
+
+    rclone move --move-journal move.journal source:path dest:path
+    rclone move --move-journal move.journal --rollback source:path dest:path
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
+			if rollback {
+				if fs.Config.MoveJournal == "" || srcFileName != "" {
+					return errors.New("--rollback needs --move-journal and a source directory")
+				}
+				return sync.RollbackMove(context.Background(), fdst, fsrc, fs.Config.MoveJournal)
+			}
 			if srcFileName == "" {
 				return sync.MoveDir(context.Background(), fdst, fsrc, deleteEmptySrcDirs, createEmptySrcDirs)
 			}
+			if fs.Config.MoveJournal != "" {
+				return errors.New("--move-journal can only be used to move a directory")
+			}
 			return operations.MoveFile(context.Background(), fdst, fsrc, srcFileName, srcFileName)
 		})
 	},
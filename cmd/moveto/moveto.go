@@ -3,7 +3,9 @@ package moveto
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
 	"github.com/spf13/cobra"
@@ -56,6 +58,9 @@ transfer.
 			if srcFileName == "" {
 				return sync.MoveDir(context.Background(), fdst, fsrc, false, false)
 			}
+			if fs.Config.MoveJournal != "" {
+				return errors.New("--move-journal can only be used to move a directory")
+			}
 			return operations.MoveFile(context.Background(), fdst, fsrc, dstFileName, srcFileName)
 		})
 	},
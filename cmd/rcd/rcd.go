@@ -5,6 +5,7 @@ import (
 
 	"github.com/rclone/rclone/cmd"
 	"github.com/rclone/rclone/fs/rc/rcflags"
+	"github.com/rclone/rclone/fs/rc/rcgrpc"
 	"github.com/rclone/rclone/fs/rc/rcserver"
 	"github.com/spf13/cobra"
 )
@@ -25,6 +26,9 @@ If you pass in a path to a directory, rclone will serve that directory
 for GET requests on the URL passed in.  It will also open the URL in
 the browser when rclone is run.
 
+If --rc-grpc-addr is set then rclone will also serve the remote
+control API over gRPC on that address.
+
 See the [rc documentation](/rc/) for more info on the rc flags.
 `,
 	Run: func(command *cobra.Command, args []string) {
@@ -46,6 +50,9 @@ See the [rc documentation](/rc/) for more info on the rc flags.
 		if s == nil {
 			log.Fatal("rc server not configured")
 		}
+		if _, err := rcgrpc.Start(&rcflags.Opt); err != nil {
+			log.Fatalf("Failed to start gRPC remote control: %v", err)
+		}
 
 		s.Wait()
 	},
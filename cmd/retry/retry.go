@@ -0,0 +1,50 @@
+package retry
+
+import (
+	"context"
+	"log"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "retry report.json",
+	Short: `Retry the failed operations in a report written by --failure-report.`,
+	Long: `
+Retry the copies, moves and deletes of the files which failed in an
+earlier run, as recorded in the report written with
+` + "`--failure-report`" + `. This saves running the whole sync again
+for a handful of failures. For example
+
+    rclone sync /path/to/local remote:path --failure-report report.json
+    rclone retry report.json
+
+Each file is copied, moved or deleted on its own as it would be with
+` + "`rclone copyto`" + `, ` + "`rclone moveto`" + ` or
+` + "`rclone deletefile`" + `, so files which have been put right in
+the meantime are skipped. The copies and moves are done before the
+deletes.
+
+To keep track of the failures which remain, give the same report
+with ` + "`--failure-report`" + ` and it will be overwritten with
+them when the retry finishes.
+
+    rclone retry report.json --failure-report report.json
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		report, err := operations.ReadFailureReport(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmd.Run(true, true, command, func() error {
+			return operations.RetryFailures(context.Background(), report)
+		})
+	},
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 )
@@ -39,6 +40,11 @@ The server will log errors.  Use -v to see access logs.
 
 --bwlimit will be respected for file transfers.  Use --stats to
 control the stats printing.
+
+When serving a local directory with --vfs-cache-mode off, files are
+sent straight from the disk with sendfile(2) where the OS supports it,
+so their data isn't copied through rclone.  This isn't done while a
+bandwidth limit is in use.
 ` + httplib.Help + vfs.Help,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
@@ -191,6 +197,12 @@ func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string
 		return
 	}
 
+	// Send the file straight from the local disk if we can. With the
+	// VFS cache the data may be in the cache instead.
+	if s.vfs.Opt.CacheMode == vfscommon.CacheModeOff && serve.OSFile(w, r, remote, node.ModTime(), obj) {
+		return
+	}
+
 	// open the object
 	in, err := file.Open(os.O_RDONLY)
 	if err != nil {
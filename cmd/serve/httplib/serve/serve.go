@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"path"
 	"strconv"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
@@ -89,3 +90,102 @@ func Object(w http.ResponseWriter, r *http.Request, o fs.Object) {
 		return
 	}
 }
+
+// sendChunkSize is the most data sent by each sendfile(2) when serving
+// an OSFile so the progress of the transfer can be seen
+const sendChunkSize = 4 * 1024 * 1024
+
+// OSFile serves o with http.ServeContent straight from the file on the
+// local disk it is stored in. Go sends the data of an *os.File with
+// sendfile(2) where it can, so large files aren't copied through
+// rclone. ServeContent deals with HEAD, ranges and conditional requests
+// and the caller should set any other headers it wants first.
+//
+// It returns false without writing anything if o isn't stored on the
+// local disk or the transfer is bandwidth limited, in which case o
+// should be served the normal way.
+func OSFile(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, o fs.Object) bool {
+	opener, ok := o.(fs.OSFileOpener)
+	if !ok || accounting.BandwidthLimited(accounting.TrafficInteractive) {
+		return false
+	}
+	ctx := accounting.WithTrafficClass(r.Context(), accounting.TrafficInteractive)
+	in, err := opener.OpenOSFile(ctx)
+	if err != nil {
+		fs.Debugf(o, "Serving without sendfile as failed to open file: %v", err)
+		return false
+	}
+	tr := accounting.Stats(ctx).NewTransfer(o)
+	out := &accountedResponseWriter{
+		ResponseWriter: w,
+		acc:            tr.Account(ctx, in), // closes in when the transfer is done
+	}
+	http.ServeContent(out, r, name, modTime, in)
+	tr.Done(out.err)
+	return true
+}
+
+// accountedResponseWriter accounts the data written to the
+// http.ResponseWriter, passing on ReadFrom so the http server can still
+// use sendfile(2)
+type accountedResponseWriter struct {
+	http.ResponseWriter
+	acc *accounting.Account
+	err error // first error writing the data
+}
+
+// account accounts n bytes written and records err
+func (w *accountedResponseWriter) account(n int, err error) error {
+	if n > 0 {
+		accErr := w.acc.AccountRead(n)
+		if err == nil {
+			err = accErr
+		}
+	}
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return err
+}
+
+// Write writes p to the response - see io.Writer
+func (w *accountedResponseWriter) Write(p []byte) (n int, err error) {
+	n, err = w.ResponseWriter.Write(p)
+	return n, w.account(n, err)
+}
+
+// ReadFrom writes the data from src to the response - see io.ReaderFrom
+//
+// http.ServeContent passes the *os.File in an io.LimitedReader which
+// is sent in chunks of sendChunkSize to show the progress.
+func (w *accountedResponseWriter) ReadFrom(src io.Reader) (n int64, err error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{w}, src)
+	}
+	in, remaining := src, int64(-1)
+	lr, isLimited := src.(*io.LimitedReader)
+	if isLimited {
+		in, remaining = lr.R, lr.N
+	}
+	for remaining != 0 {
+		chunk := int64(sendChunkSize)
+		if remaining >= 0 && remaining < chunk {
+			chunk = remaining
+		}
+		var sent int64
+		sent, err = rf.ReadFrom(&io.LimitedReader{R: in, N: chunk})
+		n += sent
+		if remaining >= 0 {
+			remaining -= sent
+		}
+		err = w.account(int(sent), err)
+		if err != nil || sent < chunk {
+			break
+		}
+	}
+	if isLimited {
+		lr.N = remaining
+	}
+	return n, err
+}
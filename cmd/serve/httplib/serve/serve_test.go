@@ -1,13 +1,21 @@
 package serve
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestObjectBadMethod(t *testing.T) {
@@ -74,3 +82,79 @@ func TestObjectBadRange(t *testing.T) {
 	body, _ := ioutil.ReadAll(resp.Body)
 	assert.Equal(t, "Bad Request\n", string(body))
 }
+
+// osFileObject is an object stored in a file on the local disk
+type osFileObject struct {
+	mockobject.Object
+	path string
+}
+
+// OpenOSFile opens the file the object is stored in
+func (o osFileObject) OpenOSFile(ctx context.Context) (*os.File, error) {
+	return os.Open(o.path)
+}
+
+var _ fs.OSFileOpener = osFileObject{}
+
+func TestOSFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	contents := bytes.Repeat([]byte("0123456789"), sendChunkSize/10+100)
+	path := filepath.Join(dir, "aFile")
+	require.NoError(t, ioutil.WriteFile(path, contents, 0600))
+	o := osFileObject{Object: mockobject.New("aFile"), path: path}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Not a local file
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://example.com/aFile", nil)
+	assert.False(t, OSFile(w, r, "aFile", modTime, mockobject.New("aFile")))
+	assert.False(t, w.Flushed)
+	assert.Equal(t, 0, w.Body.Len())
+
+	// Bandwidth limited
+	accounting.SetBwLimit(1024 * 1024)
+	assert.False(t, OSFile(w, r, "aFile", modTime, o))
+	accounting.SetBwLimit(0)
+	assert.Equal(t, 0, w.Body.Len())
+
+	// Through a real server so sendfile can be used
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, OSFile(w, r, "aFile", modTime, o))
+	}))
+	defer ts.Close()
+
+	before := accounting.GlobalStats().GetBytes()
+	resp, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, modTime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+	assert.True(t, bytes.Equal(contents, body))
+	assert.Equal(t, int64(len(contents)), accounting.GlobalStats().GetBytes()-before)
+
+	// Range request
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=3-5")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "345", string(body))
+
+	// Conditional request
+	req.Header.Del("Range")
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
@@ -3,6 +3,7 @@ package webdav
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -19,6 +20,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/errors"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/webdav"
@@ -62,6 +64,13 @@ supported hash on the backend or you can use a named hash such as
 
 Use "rclone hashsum" to see the full list.
 
+### Serving local files
+
+When serving a local directory with --vfs-cache-mode off, files
+fetched with GET are sent straight from the disk with sendfile(2)
+where the OS supports it, so their data isn't copied through rclone.
+This isn't done while a bandwidth limit is in use.
+
 ` + httplib.Help + vfs.Help + proxy.Help,
 	RunE: func(command *cobra.Command, args []string) error {
 		var f fs.Fs
@@ -180,9 +189,39 @@ func (w *WebDAV) handler(rw http.ResponseWriter, r *http.Request) {
 		w.serveDir(rw, r, remote)
 		return
 	}
+	if r.Method == "GET" && !isDir && w.serveOSFile(rw, r, remote) {
+		return
+	}
 	w.webdavhandler.ServeHTTP(rw, r)
 }
 
+// serveOSFile serves the file at remote straight from the local disk
+// if it can, returning false if it should be served the normal way.
+// This sets the same headers as the GET of the webdav handler.
+func (w *WebDAV) serveOSFile(rw http.ResponseWriter, r *http.Request, remote string) bool {
+	VFS, err := w.getVFS(r.Context())
+	if err != nil || VFS.Opt.CacheMode != vfscommon.CacheModeOff {
+		// With the VFS cache the data may be in the cache instead
+		return false
+	}
+	node, err := VFS.Stat(remote)
+	if err != nil || !node.IsFile() {
+		return false
+	}
+	o, ok := node.DirEntry().(fs.Object)
+	if !ok {
+		return false
+	}
+	etag, err := FileInfo{node}.ETag(r.Context())
+	if err != nil {
+		// Make the ETag from the modification time and size as
+		// the webdav handler does
+		etag = fmt.Sprintf(`"%x%x"`, node.ModTime().UnixNano(), node.Size())
+	}
+	rw.Header().Set("ETag", etag)
+	return serve.OSFile(rw, r, remote, node.ModTime(), o)
+}
+
 // serveDir serves a directory index at dirRemote
 // This is similar to serveDir in serve http.
 func (w *WebDAV) serveDir(rw http.ResponseWriter, r *http.Request, dirRemote string) {
@@ -3,7 +3,9 @@ package sync
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -12,12 +14,19 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	planFile           = ""
+	watch              = false
+	watchOpt           = sync.DefaultWatchOpt
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	flags.StringVarP(cmdFlags, &planFile, "plan", "", planFile, "Write what the sync would do to this file instead of doing it")
+	flags.BoolVarP(cmdFlags, &watch, "watch", "", watch, "Keep running after the sync, syncing the paths which change in the source")
+	flags.DurationVarP(cmdFlags, &watchOpt.Delay, "watch-delay", "", watchOpt.Delay, "Time to gather changes for before syncing them with --watch")
+	flags.DurationVarP(cmdFlags, &watchOpt.PollInterval, "watch-poll-interval", "", watchOpt.PollInterval, "Poll interval for changes, or time between syncs if the source can't notify, with --watch")
 }
 
 var commandDefinition = &cobra.Command{
@@ -46,11 +55,54 @@ If dest:path doesn't exist, it is created and the source:path contents
 go there.
 
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics
+
+If you supply the ` + "`--plan file.json`" + ` flag then rclone works out
+what the sync would do and writes it to file.json without changing
+anything - not even the modification times of files which are
+otherwise identical, which the sync would update. Each file to be copied or deleted is recorded along with its
+size and modification time (and hash if ` + "`--checksum`" + ` is in use).
+The plan can be reviewed then carried out with ` + "`rclone apply`" + `
+which refuses to do anything if any of the files have changed since
+the plan was made.
+
+    rclone sync --plan plan.json source:path dest:path
+    rclone apply plan.json
+
+If you supply the ` + "`--watch`" + ` flag then after the sync rclone keeps
+running and asks the source to notify it of changes. The paths which
+change are gathered for ` + "`--watch-delay`" + ` (default 5s) and then only
+they are synced, without listing everything again. A changed file is
+copied or deleted on its own and a changed directory is synced with
+everything in it. Remotes which poll for changes (eg Google Drive) do
+so every ` + "`--watch-poll-interval`" + ` (default 1m).
+
+Change notification is supported by remotes like Google Drive and
+Amazon Drive. If the source doesn't support it then a full sync is
+done every ` + "`--watch-poll-interval`" + ` instead.
+
+    rclone sync --watch drive:work /backup/work
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
+			if planFile != "" {
+				if srcFileName != "" {
+					return errors.New("can't use --plan when syncing a single file")
+				}
+				plan, err := sync.MakePlan(context.Background(), fdst, fsrc, fs.Config.DeleteMode, createEmptySrcDirs)
+				if err != nil {
+					return err
+				}
+				fs.Logf(fdst, "Writing plan of %d actions to %q", len(plan.Actions), planFile)
+				return plan.Save(planFile)
+			}
+			if watch {
+				if srcFileName != "" {
+					return errors.New("can't use --watch when syncing a single file")
+				}
+				return sync.Watch(context.Background(), fdst, fsrc, createEmptySrcDirs, &watchOpt)
+			}
 			if srcFileName == "" {
 				return sync.Sync(context.Background(), fdst, fsrc, createEmptySrcDirs)
 			}
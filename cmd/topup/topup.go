@@ -0,0 +1,74 @@
+package topup
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/topup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	opt = topup.DefaultOpt
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.DurationVarP(cmdFlags, &opt.Interval, "interval", "", opt.Interval, "Time between passes, 0 to run a single pass and exit")
+	flags.BoolVarP(cmdFlags, &opt.UseNotify, "use-notify", "", opt.UseNotify, "Start a pass when the source notifies of changes if it can")
+	flags.DurationVarP(cmdFlags, &opt.PollInterval, "poll-interval", "", opt.PollInterval, "Poll interval for change notification on remotes which poll")
+	flags.StringVarP(cmdFlags, &opt.StateFile, "state-file", "", opt.StateFile, "File to keep the high water mark in (default in the cache dir)")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "topup source:path dest:path",
+	Short: `Keep copying new files from source to dest.`,
+	Long: `
+Copy the files from source:path to dest:path, then keep running,
+copying the files which appear in source:path, until rclone is
+stopped.
+
+This is intended for pipelines like camera uploads where new files
+keep arriving in the source and should be copied to the destination
+without needing cron or another scheduler.
+
+After the first pass, which copies everything like ` + "`rclone copy`" + `,
+each pass only looks at the files modified since the newest file
+copied by the previous pass - the high water mark. Files in the
+destination are never deleted, and files whose modification time is
+older than the high water mark aren't copied even if they are new, so
+this isn't a replacement for ` + "`rclone sync`" + `.
+
+The high water mark is kept in a state file in the cache directory so
+if rclone is restarted it carries on where it left off. Use
+` + "`--state-file`" + ` to choose where it is kept. Delete the state file to
+start again with a full pass. The high water mark doesn't move on if
+any files in a pass fail to copy so they are retried on the next pass,
+and never moves past the time the pass started so a file with a
+modification time in the future doesn't hide the files arriving before
+then.
+
+A pass is started every ` + "`--interval`" + ` (default 1m). Use
+` + "`--interval 0`" + ` to run a single pass and exit, for example to run
+from a scheduler while still only looking at new files.
+
+With ` + "`--use-notify`" + ` a pass is also started as soon as the source
+reports a change, on remotes which support change notification (eg
+Google Drive, OneDrive, Dropbox). ` + "`--poll-interval`" + ` sets how often
+remotes which poll for changes do so.
+
+The filtering flags (eg ` + "`--include`" + `) can be used to choose which files
+are copied.
+
+    rclone topup /sdcard/DCIM remote:camera --interval 5m
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, fdst := cmd.NewFsSrcDst(args)
+		cmd.Run(true, true, command, func() error {
+			return topup.TopUp(context.Background(), fdst, fsrc, &opt)
+		})
+	},
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/asyncreader"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 // ErrorMaxTransferLimitReached defines error when transfer limit is reached.
@@ -44,6 +45,8 @@ type Account struct {
 	closed  bool          // set if the file is closed
 	exit    chan struct{} // channel that will be closed when transfer is finished
 	withBuf bool          // is using a buffered in
+	class   TrafficClass  // traffic class of the transfer
+	tuner   *sched.Tuner  // tuner of the transfer with --auto-tune, may be nil
 
 	tokenBucket *rate.Limiter // per file bandwidth limiter (may be nil)
 
@@ -74,6 +77,8 @@ func newAccountSizeName(ctx context.Context, stats *StatsInfo, in io.ReadCloser,
 		origIn: in,
 		size:   size,
 		name:   name,
+		class:  TrafficClassFromContext(ctx),
+		tuner:  sched.TunerFromContext(ctx),
 		exit:   make(chan struct{}),
 		values: accountValues{
 			avg:    0,
@@ -297,8 +302,9 @@ func (acc *Account) accountRead(n int) {
 	acc.values.mu.Unlock()
 
 	acc.stats.Bytes(int64(n))
+	acc.tuner.Bytes(n)
 
-	limitBandwidth(n)
+	limitBandwidth(acc.class, n)
 	acc.limitPerFileBandwidth(n)
 }
 
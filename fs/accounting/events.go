@@ -0,0 +1,124 @@
+package accounting
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// Types of Event
+const (
+	EventStart  = "start"  // a transfer has started
+	EventFinish = "finish" // a transfer has finished successfully
+	EventError  = "error"  // a transfer has failed
+	EventStats  = "stats"  // the periodic stats
+	EventResult = "result" // the final stats and result of the command
+)
+
+// Event is written as a line of JSON for each transfer and for the
+// stats with --output-format jsonl
+type Event struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Name    string    `json:"name,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	Bytes   int64     `json:"bytes,omitempty"`
+	Seconds float64   `json:"seconds,omitempty"` // time the transfer took
+	Group   string    `json:"group,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Stats   rc.Params `json:"stats,omitempty"`
+}
+
+var (
+	eventMu  sync.Mutex
+	eventOut io.Writer = os.Stderr
+)
+
+// SetEventOutput sets where the events are written, returning the
+// previous output. The default is stderr.
+func SetEventOutput(out io.Writer) (old io.Writer) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	old, eventOut = eventOut, out
+	return old
+}
+
+// WriteEvent writes the event as a line of JSON if
+// --output-format jsonl is in use.
+func WriteEvent(e Event) {
+	if fs.Config.OutputFormat != fs.OutputFormatJSONL {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		fs.Errorf(nil, "Failed to encode %s event: %v", e.Event, err)
+		return
+	}
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	_, _ = eventOut.Write(append(data, '\n'))
+}
+
+// WriteStatsEvent writes the stats as an event of type eventType
+// if --output-format jsonl is in use. If err is set it is written as
+// the error of the event.
+func (s *StatsInfo) WriteStatsEvent(eventType string, err error) {
+	if fs.Config.OutputFormat != fs.OutputFormatJSONL {
+		return
+	}
+	out, _ := s.RemoteStats()
+	e := Event{
+		Event: eventType,
+		Group: s.group,
+		Stats: out,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	WriteEvent(e)
+}
+
+// writeStartEvent writes the event for a transfer starting
+func (tr *Transfer) writeStartEvent() {
+	if tr.checking {
+		return
+	}
+	WriteEvent(Event{
+		Time:  tr.startedAt,
+		Event: EventStart,
+		Name:  tr.remote,
+		Size:  tr.size,
+		Group: tr.stats.group,
+	})
+}
+
+// writeDoneEvent writes the event for a transfer finishing
+func (tr *Transfer) writeDoneEvent(bytes int64) {
+	if tr.checking {
+		return
+	}
+	tr.mu.RLock()
+	e := Event{
+		Time:    tr.completedAt,
+		Event:   EventFinish,
+		Name:    tr.remote,
+		Size:    tr.size,
+		Bytes:   bytes,
+		Seconds: tr.completedAt.Sub(tr.startedAt).Seconds(),
+		Group:   tr.stats.group,
+	}
+	if tr.err != nil {
+		e.Event = EventError
+		e.Error = tr.err.Error()
+	}
+	tr.mu.RUnlock()
+	WriteEvent(e)
+}
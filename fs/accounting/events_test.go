@@ -0,0 +1,61 @@
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	var buf bytes.Buffer
+	old := SetEventOutput(&buf)
+	defer SetEventOutput(old)
+	oldFormat := fs.Config.OutputFormat
+	defer func() { fs.Config.OutputFormat = oldFormat }()
+
+	// Nothing written with the text format
+	fs.Config.OutputFormat = fs.OutputFormatText
+	s := NewStats()
+	s.NewTransferRemoteSize("ignored", 1).Done(nil)
+	assert.Equal(t, "", buf.String())
+
+	fs.Config.OutputFormat = fs.OutputFormatJSONL
+	s.NewTransferRemoteSize("ok", 10).Done(nil)
+	s.NewCheckingTransfer(mockobject.Object("checked")).Done(nil)
+	s.NewTransferRemoteSize("bad", 20).Done(errors.New("potato"))
+	s.WriteStatsEvent(EventResult, nil)
+
+	var events []Event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.Equal(t, 5, len(events))
+	for i, want := range []struct {
+		event string
+		name  string
+	}{
+		{EventStart, "ok"},
+		{EventFinish, "ok"},
+		{EventStart, "bad"},
+		{EventError, "bad"},
+		{EventResult, ""},
+	} {
+		assert.Equal(t, want.event, events[i].Event, i)
+		assert.Equal(t, want.name, events[i].Name, i)
+		assert.False(t, events[i].Time.IsZero(), i)
+	}
+	assert.Equal(t, int64(20), events[3].Size)
+	assert.Equal(t, "potato", events[3].Error)
+	assert.Equal(t, float64(1), events[4].Stats["errors"])
+	assert.Equal(t, float64(2), events[4].Stats["transfers"]) // including the one with the text format
+}
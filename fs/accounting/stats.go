@@ -44,6 +44,54 @@ type StatsInfo struct {
 	oldTimeRanges     timeRanges    // a merged list of time ranges for the transfers
 	oldDuration       time.Duration // duration of transfers we have culled
 	group             string
+	remotes           map[string]*RemoteCounters // counters for each remote by name
+}
+
+// RemoteCounters are the counters of the finished transfers and
+// checks of the objects of a remote
+type RemoteCounters struct {
+	Bytes     int64
+	Transfers int64
+	Checks    int64
+	Errors    int64
+}
+
+// doneRemote adds a finished transfer or check of an object on the
+// remote called fsName to its counters
+func (s *StatsInfo) doneRemote(fsName string, checking bool, bytes int64, err error) {
+	if fsName == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.remotes == nil {
+		s.remotes = make(map[string]*RemoteCounters)
+	}
+	c := s.remotes[fsName]
+	if c == nil {
+		c = new(RemoteCounters)
+		s.remotes[fsName] = c
+	}
+	c.Bytes += bytes
+	switch {
+	case err != nil:
+		c.Errors++
+	case checking:
+		c.Checks++
+	default:
+		c.Transfers++
+	}
+}
+
+// RemoteCounters returns a copy of the counters of each remote
+func (s *StatsInfo) RemoteCounters() map[string]RemoteCounters {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]RemoteCounters, len(s.remotes))
+	for name, c := range s.remotes {
+		out[name] = *c
+	}
+	return out
 }
 
 // NewStats creates an initialised StatsInfo
@@ -490,6 +538,7 @@ func (s *StatsInfo) ResetCounters() {
 	s.renames = 0
 	s.startedTransfers = nil
 	s.oldDuration = 0
+	s.remotes = nil
 }
 
 // ResetErrors sets the errors count to 0 and resets lastError, fatalError and retryError
@@ -575,7 +624,7 @@ func (s *StatsInfo) NewTransfer(obj fs.Object) *Transfer {
 
 // NewTransferRemoteSize adds a transfer to the stats based on remote and size.
 func (s *StatsInfo) NewTransferRemoteSize(remote string, size int64) *Transfer {
-	tr := newTransferRemoteSize(s, remote, size, false)
+	tr := newTransferRemoteSize(s, remote, "", size, false)
 	s.transferring.add(tr)
 	return tr
 }
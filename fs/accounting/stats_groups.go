@@ -366,6 +366,20 @@ func (sg *statsGroups) sum() *StatsInfo {
 				sum.lastError = stats.lastError
 			}
 			sum.startedTransfers = append(sum.startedTransfers, stats.startedTransfers...)
+			for name, c := range stats.remotes {
+				if sum.remotes == nil {
+					sum.remotes = make(map[string]*RemoteCounters)
+				}
+				total := sum.remotes[name]
+				if total == nil {
+					total = new(RemoteCounters)
+					sum.remotes[name] = total
+				}
+				total.Bytes += c.Bytes
+				total.Transfers += c.Transfers
+				total.Checks += c.Checks
+				total.Errors += c.Errors
+			}
 		}
 		stats.mu.RUnlock()
 	}
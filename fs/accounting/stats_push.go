@@ -0,0 +1,312 @@
+package accounting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// maxStatsdPacket is the most bytes sent in one statsd packet so it
+// isn't fragmented on a typical network
+const maxStatsdPacket = 1432
+
+// metric is a value pushed by --stats-push
+type metric struct {
+	name   string  // name of the metric, eg "bytes"
+	remote string  // the remote for per remote metrics, "" otherwise
+	value  float64 // the value
+	isInt  bool    // set if value is an integer counter
+}
+
+// metrics returns the core stats of s as metrics
+func (s *StatsInfo) metrics() []metric {
+	s.mu.RLock()
+	ms := []metric{
+		{name: "bytes", value: float64(s.bytes), isInt: true},
+		{name: "speed", value: s.Speed()},
+		{name: "errors", value: float64(s.errors), isInt: true},
+		{name: "checks", value: float64(s.checks), isInt: true},
+		{name: "transfers", value: float64(s.transfers), isInt: true},
+		{name: "deletes", value: float64(s.deletes), isInt: true},
+		{name: "renames", value: float64(s.renames), isInt: true},
+		{name: "fatal_error", value: bool2Float(s.fatalError), isInt: true},
+		{name: "retry_error", value: bool2Float(s.retryError), isInt: true},
+	}
+	names := make([]string, 0, len(s.remotes))
+	for name := range s.remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := s.remotes[name]
+		ms = append(ms,
+			metric{name: "bytes", remote: name, value: float64(c.Bytes), isInt: true},
+			metric{name: "transfers", remote: name, value: float64(c.Transfers), isInt: true},
+			metric{name: "checks", remote: name, value: float64(c.Checks), isInt: true},
+			metric{name: "errors", remote: name, value: float64(c.Errors), isInt: true},
+		)
+	}
+	s.mu.RUnlock()
+	return ms
+}
+
+// formatValue formats the value of m
+func (m *metric) formatValue() string {
+	if m.isInt {
+		return strconv.FormatInt(int64(m.value), 10)
+	}
+	return strconv.FormatFloat(m.value, 'f', -1, 64)
+}
+
+// influxEscaper escapes measurements and tag values in the InfluxDB
+// line protocol
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// formatInflux formats the metrics in the InfluxDB line protocol
+//
+// The core stats are the fields of the prefix measurement and the per
+// remote ones are the fields of prefix_remote with a remote tag.
+func formatInflux(prefix string, ms []metric, now time.Time) []byte {
+	var buf bytes.Buffer
+	prefix = influxEscaper.Replace(prefix)
+	lastRemote := "\x00"
+	for i := range ms {
+		m := &ms[i]
+		if m.remote != lastRemote {
+			if buf.Len() > 0 {
+				fmt.Fprintf(&buf, " %d\n", now.UnixNano())
+			}
+			if m.remote == "" {
+				buf.WriteString(prefix)
+			} else {
+				fmt.Fprintf(&buf, "%s_remote,remote=%s", prefix, influxEscaper.Replace(m.remote))
+			}
+			buf.WriteByte(' ')
+			lastRemote = m.remote
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(m.name)
+		buf.WriteByte('=')
+		buf.WriteString(m.formatValue())
+		if m.isInt {
+			buf.WriteByte('i')
+		}
+	}
+	if buf.Len() > 0 {
+		fmt.Fprintf(&buf, " %d\n", now.UnixNano())
+	}
+	return buf.Bytes()
+}
+
+// metricPath returns the dotted path of m for Graphite and StatsD
+func (m *metric) metricPath(prefix string) string {
+	if m.remote == "" {
+		return prefix + "." + m.name
+	}
+	remote := strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ' ', ':', '|', '@', '/', '\n':
+			return '_'
+		}
+		return r
+	}, m.remote)
+	return prefix + ".remote." + remote + "." + m.name
+}
+
+// formatGraphite formats the metrics in the Graphite plaintext protocol
+func formatGraphite(prefix string, ms []metric, now time.Time) []byte {
+	var buf bytes.Buffer
+	for i := range ms {
+		fmt.Fprintf(&buf, "%s %s %d\n", ms[i].metricPath(prefix), ms[i].formatValue(), now.Unix())
+	}
+	return buf.Bytes()
+}
+
+// formatStatsd formats the metrics as StatsD gauges, split into
+// packets which aren't too big to send
+func formatStatsd(prefix string, ms []metric) (packets [][]byte) {
+	var buf bytes.Buffer
+	for i := range ms {
+		line := fmt.Sprintf("%s:%s|g\n", ms[i].metricPath(prefix), ms[i].formatValue())
+		if buf.Len() > 0 && buf.Len()+len(line) > maxStatsdPacket {
+			packets = append(packets, append([]byte(nil), buf.Bytes()...))
+			buf.Reset()
+		}
+		buf.WriteString(line)
+	}
+	if buf.Len() > 0 {
+		packets = append(packets, buf.Bytes())
+	}
+	return packets
+}
+
+// statsPusher pushes the stats to a time series database
+type statsPusher struct {
+	u      *url.URL
+	prefix string
+	client *http.Client
+}
+
+// newStatsPusher makes a statsPusher for the URL passed to --stats-push
+func newStatsPusher(rawURL, prefix string) (*statsPusher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad --stats-push URL")
+	}
+	p := &statsPusher{
+		u:      u,
+		prefix: prefix,
+	}
+	switch u.Scheme {
+	case "http", "https":
+		p.client = fshttp.NewClient(fs.Config)
+	case "graphite", "statsd":
+		if u.Host == "" {
+			return nil, errors.Errorf("need a host in --stats-push %q", rawURL)
+		}
+		if u.Port() == "" {
+			port := "2003"
+			if u.Scheme == "statsd" {
+				port = "8125"
+			}
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+		}
+	default:
+		return nil, errors.Errorf("unknown scheme %q in --stats-push - use http, https, graphite or statsd", u.Scheme)
+	}
+	return p, nil
+}
+
+// push pushes the metrics
+func (p *statsPusher) push(ms []metric, now time.Time) error {
+	switch p.u.Scheme {
+	case "graphite":
+		return p.send("tcp", [][]byte{formatGraphite(p.prefix, ms, now)})
+	case "statsd":
+		return p.send("udp", formatStatsd(p.prefix, ms))
+	}
+	return p.pushInflux(formatInflux(p.prefix, ms, now))
+}
+
+// send sends each of the packets over a new connection to the host
+func (p *statsPusher) send(network string, packets [][]byte) (err error) {
+	conn, err := net.DialTimeout(network, p.u.Host, fs.Config.ConnectTimeout)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(conn, &err)
+	if fs.Config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(fs.Config.Timeout))
+	}
+	for _, packet := range packets {
+		_, err = conn.Write(packet)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushInflux posts the data to the InfluxDB write endpoint
+func (p *statsPusher) pushInflux(data []byte) error {
+	u := *p.u
+	u.User = nil
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.u.User != nil {
+		pass, _ := p.u.User.Password()
+		req.SetBasicAuth(p.u.User.Username(), pass)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.Errorf("HTTP error %v: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// redactURL returns u as a string with any password hidden
+func redactURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return u.String()
+	}
+	redacted := *u
+	redacted.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return redacted.String()
+}
+
+// pushNow pushes the stats of all the groups
+func (p *statsPusher) pushNow() {
+	err := p.push(groups.sum().metrics(), time.Now())
+	if err != nil {
+		fs.Errorf(nil, "Failed to push stats to %s: %v", redactURL(p.u), err)
+	}
+}
+
+// StartStatsPush starts pushing the stats every --stats-push-interval
+// to the time series database set with --stats-push, if any. They are
+// pushed a final time when rclone exits.
+func StartStatsPush() error {
+	if fs.Config.StatsPush == "" {
+		return nil
+	}
+	p, err := newStatsPusher(fs.Config.StatsPush, fs.Config.StatsPushPrefix)
+	if err != nil {
+		return err
+	}
+	interval := fs.Config.StatsPushInterval
+	if interval <= 0 {
+		return errors.New("--stats-push-interval must be greater than 0")
+	}
+	fs.Infof(nil, "Pushing stats to %s every %v", redactURL(p.u), interval)
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				p.pushNow()
+			case <-stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	atexit.Register(func() {
+		close(stop)
+		wg.Wait()
+		p.pushNow()
+	})
+	return nil
+}
@@ -36,6 +36,8 @@ func newTokenBucket(bandwidth fs.SizeSuffix) *rate.Limiter {
 
 // StartTokenBucket starts the token bucket if necessary
 func StartTokenBucket() {
+	startTrafficClasses()
+
 	currLimitMu.Lock()
 	currLimit := fs.Config.BwLimit.LimitAt(time.Now())
 	currLimitMu.Unlock()
@@ -100,19 +102,42 @@ func StartTokenTicker() {
 }
 
 // limitBandwith sleeps for the correct amount of time for the passage
-// of n bytes according to the current bandwidth limit
-func limitBandwidth(n int) {
-	tokenBucketMu.Lock()
+// of n bytes of traffic in class according to the current bandwidth
+// limit
+//
+// Classes sharing the --bwlimit token bucket wait for any classes with
+// a higher priority which are waiting for it.
+func limitBandwidth(class TrafficClass, n int) {
+	tb, shared := classTokenBucket(class)
+	if shared {
+		tokenBucketMu.Lock()
+		tb = tokenBucket
+		tokenBucketMu.Unlock()
+	}
 
 	// Limit the transfer speed if required
-	if tokenBucket != nil {
-		err := tokenBucket.WaitN(context.Background(), n)
+	if tb != nil {
+		if shared {
+			gate.enter(class)
+			defer gate.leave(class)
+		}
+		err := tb.WaitN(context.Background(), n)
 		if err != nil {
 			fs.Errorf(nil, "Token bucket error: %v", err)
 		}
 	}
+}
 
-	tokenBucketMu.Unlock()
+// BandwidthLimited returns whether the traffic of class is limited by
+// --bwlimit, a limit of its own or --bwlimit-file at the moment
+func BandwidthLimited(class TrafficClass) bool {
+	tb, shared := classTokenBucket(class)
+	if shared {
+		tokenBucketMu.Lock()
+		tb = tokenBucket
+		tokenBucketMu.Unlock()
+	}
+	return tb != nil || fs.Config.BwLimitFile.LimitAt(time.Now()).Bandwidth > 0
 }
 
 // SetBwLimit sets the current bandwidth limit
@@ -133,6 +158,17 @@ func init() {
 	rc.Add(rc.Call{
 		Path: "core/bwlimit",
 		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			class := TrafficBackground
+			if in["class"] != nil {
+				className, err := in.GetString("class")
+				if err != nil {
+					return out, err
+				}
+				class, err = ParseTrafficClass(className)
+				if err != nil {
+					return out, err
+				}
+			}
 			if in["rate"] != nil {
 				bwlimit, err := in.GetString("rate")
 				if err != nil {
@@ -147,7 +183,21 @@ func init() {
 					return out, errors.New("need exactly 1 bandwidth setting")
 				}
 				bw := bws[0]
-				SetBwLimit(bw.Bandwidth)
+				if class != TrafficBackground {
+					err = SetTrafficClassBwLimit(class, bw.Bandwidth)
+					if err != nil {
+						return out, err
+					}
+				} else {
+					SetBwLimit(bw.Bandwidth)
+				}
+			}
+			if class != TrafficBackground {
+				bandwidth := TrafficClassBwLimit(class)
+				return rc.Params{
+					"rate":           bandwidth.String(),
+					"bytesPerSecond": int64(bandwidth),
+				}, nil
 			}
 			bytesPerSecond := int64(-1)
 			if tokenBucket != nil {
@@ -188,6 +238,17 @@ If the rate parameter is not supplied then the bandwidth is queried
 The format of the parameter is exactly the same as passed to --bwlimit
 except only one bandwidth may be specified.
 
+If the class parameter is set to "interactive" or "write-back" then
+the limit of that traffic class is set or queried instead, as with
+--bwlimit-interactive and --bwlimit-write-back. A rate of 0 means the
+class shares the --bwlimit limit with priority.
+
+    rclone rc core/bwlimit class=interactive rate=off
+    {
+        "bytesPerSecond": -1,
+        "rate": "off"
+    }
+
 In either case "rate" is returned as a human readable string, and
 "bytesPerSecond" is returned as a number.
 `,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -57,3 +58,35 @@ func TestRcBwLimit(t *testing.T) {
 	}, out)
 
 }
+
+func TestBandwidthLimited(t *testing.T) {
+	oldBwLimitFile := fs.Config.BwLimitFile
+	defer func() {
+		fs.Config.BwLimitFile = oldBwLimitFile
+		SetBwLimit(0)
+		require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, 0))
+	}()
+
+	assert.False(t, BandwidthLimited(TrafficBackground))
+	assert.False(t, BandwidthLimited(TrafficInteractive))
+
+	// --bwlimit limits the classes sharing it
+	SetBwLimit(1024 * 1024)
+	assert.True(t, BandwidthLimited(TrafficBackground))
+	assert.True(t, BandwidthLimited(TrafficInteractive))
+
+	// Unless they have a limit of their own
+	require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, -1))
+	assert.True(t, BandwidthLimited(TrafficBackground))
+	assert.False(t, BandwidthLimited(TrafficInteractive))
+	SetBwLimit(0)
+	require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, 1024*1024))
+	assert.False(t, BandwidthLimited(TrafficBackground))
+	assert.True(t, BandwidthLimited(TrafficInteractive))
+	require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, 0))
+
+	// --bwlimit-file limits them all
+	require.NoError(t, fs.Config.BwLimitFile.Set("1M"))
+	assert.True(t, BandwidthLimited(TrafficBackground))
+	assert.True(t, BandwidthLimited(TrafficInteractive))
+}
@@ -0,0 +1,175 @@
+package accounting
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+// TrafficClass is the kind of traffic a transfer is part of
+//
+// The classes other than TrafficBackground can have bandwidth limits
+// of their own. Otherwise they share the --bwlimit one with the
+// background transfers, and the classes with higher priority get the
+// bandwidth first so a background sync can't starve the reads from a
+// mount.
+type TrafficClass int
+
+// Traffic classes in order of priority, lowest first
+const (
+	TrafficBackground  TrafficClass = iota // transfers by sync, copy etc
+	TrafficWriteBack                       // uploads of files written to the VFS cache
+	TrafficInteractive                     // transfers a mount or server is waiting on
+	numTrafficClasses
+)
+
+var trafficClassNames = [numTrafficClasses]string{"background", "write-back", "interactive"}
+
+// String turns a TrafficClass into a string
+func (class TrafficClass) String() string {
+	if class < 0 || class >= numTrafficClasses {
+		return "unknown"
+	}
+	return trafficClassNames[class]
+}
+
+// ParseTrafficClass parses the name of a TrafficClass
+func ParseTrafficClass(name string) (TrafficClass, error) {
+	for i, className := range trafficClassNames {
+		if strings.EqualFold(name, className) {
+			return TrafficClass(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown traffic class %q - must be one of %s", name, strings.Join(trafficClassNames[:], ", "))
+}
+
+// trafficClassKey is the context key for the traffic class
+type trafficClassKey struct{}
+
+// WithTrafficClass returns a copy of ctx with class set as the traffic
+// class of the transfers made with it
+func WithTrafficClass(ctx context.Context, class TrafficClass) context.Context {
+	return context.WithValue(ctx, trafficClassKey{}, class)
+}
+
+// TrafficClassFromContext returns the traffic class set in ctx, or
+// TrafficBackground if none is set
+func TrafficClassFromContext(ctx context.Context) TrafficClass {
+	if ctx != nil {
+		if class, ok := ctx.Value(trafficClassKey{}).(TrafficClass); ok {
+			return class
+		}
+	}
+	return TrafficBackground
+}
+
+var (
+	classMu     sync.Mutex                       // protects the class limits
+	classLimit  [numTrafficClasses]fs.SizeSuffix // 0 to share --bwlimit, negative for unlimited
+	classBucket [numTrafficClasses]*rate.Limiter // token buckets for the classes with their own limits
+	gate        = newPriorityGate()              // orders the classes sharing --bwlimit
+)
+
+// startTrafficClasses sets the limits of the traffic classes from the config
+func startTrafficClasses() {
+	if fs.Config.BwLimitWriteBack != 0 {
+		_ = SetTrafficClassBwLimit(TrafficWriteBack, fs.Config.BwLimitWriteBack)
+	}
+	if fs.Config.BwLimitInteractive != 0 {
+		_ = SetTrafficClassBwLimit(TrafficInteractive, fs.Config.BwLimitInteractive)
+	}
+}
+
+// SetTrafficClassBwLimit sets the bandwidth limit of class
+//
+// If bandwidth is 0 the class shares the --bwlimit limit with the
+// background transfers. If it is negative the class is unlimited.
+func SetTrafficClassBwLimit(class TrafficClass, bandwidth fs.SizeSuffix) error {
+	if class <= TrafficBackground || class >= numTrafficClasses {
+		return errors.Errorf("can't set a limit for %v traffic - use the bandwidth limit", class)
+	}
+	classMu.Lock()
+	defer classMu.Unlock()
+	classLimit[class] = bandwidth
+	classBucket[class] = nil
+	switch {
+	case bandwidth > 0:
+		classBucket[class] = newTokenBucket(bandwidth)
+		fs.Logf(nil, "Bandwidth limit for %v traffic set to %v", class, bandwidth)
+	case bandwidth < 0:
+		fs.Logf(nil, "Bandwidth limit for %v traffic set to unlimited", class)
+	default:
+		fs.Logf(nil, "Bandwidth limit for %v traffic set to share the bandwidth limit", class)
+	}
+	return nil
+}
+
+// TrafficClassBwLimit returns the bandwidth limit of class as set by
+// SetTrafficClassBwLimit
+func TrafficClassBwLimit(class TrafficClass) fs.SizeSuffix {
+	if class <= TrafficBackground || class >= numTrafficClasses {
+		return 0
+	}
+	classMu.Lock()
+	defer classMu.Unlock()
+	return classLimit[class]
+}
+
+// classTokenBucket returns the token bucket of class if it has its own
+// limit and whether it shares the --bwlimit one
+func classTokenBucket(class TrafficClass) (tb *rate.Limiter, shared bool) {
+	if class <= TrafficBackground || class >= numTrafficClasses {
+		return nil, true
+	}
+	classMu.Lock()
+	defer classMu.Unlock()
+	return classBucket[class], classLimit[class] == 0
+}
+
+// priorityGate makes the traffic classes sharing a token bucket wait
+// while any class with a higher priority is waiting for it
+type priorityGate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	waiting [numTrafficClasses]int // number waiting in each class
+}
+
+func newPriorityGate() *priorityGate {
+	g := &priorityGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// higherWaiting returns whether a class with a higher priority than
+// class is waiting - call with the lock held
+func (g *priorityGate) higherWaiting(class TrafficClass) bool {
+	for c := class + 1; c < numTrafficClasses; c++ {
+		if g.waiting[c] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enter waits until no class with a higher priority than class is
+// waiting then marks class as waiting
+func (g *priorityGate) enter(class TrafficClass) {
+	g.mu.Lock()
+	for g.higherWaiting(class) {
+		g.cond.Wait()
+	}
+	g.waiting[class]++
+	g.mu.Unlock()
+}
+
+// leave marks class as done waiting
+func (g *priorityGate) leave(class TrafficClass) {
+	g.mu.Lock()
+	g.waiting[class]--
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
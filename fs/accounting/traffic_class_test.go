@@ -0,0 +1,141 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficClassContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, TrafficBackground, TrafficClassFromContext(ctx))
+	ctx = WithTrafficClass(ctx, TrafficInteractive)
+	assert.Equal(t, TrafficInteractive, TrafficClassFromContext(ctx))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	assert.Equal(t, TrafficInteractive, TrafficClassFromContext(ctx))
+
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
+	acc := newAccountSizeName(ctx, NewStats(), in, 1, "test")
+	assert.Equal(t, TrafficInteractive, acc.class)
+	require.NoError(t, acc.Close())
+}
+
+func TestParseTrafficClass(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want TrafficClass
+		err  bool
+	}{
+		{"background", TrafficBackground, false},
+		{"write-back", TrafficWriteBack, false},
+		{"Interactive", TrafficInteractive, false},
+		{"potato", 0, true},
+	} {
+		got, err := ParseTrafficClass(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, strings.ToLower(test.in), got.String())
+	}
+	assert.Equal(t, "write-back", TrafficWriteBack.String())
+	assert.Equal(t, "unknown", TrafficClass(99).String())
+}
+
+func TestSetTrafficClassBwLimit(t *testing.T) {
+	defer func() {
+		require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, 0))
+	}()
+
+	assert.Error(t, SetTrafficClassBwLimit(TrafficBackground, 1024))
+
+	tb, shared := classTokenBucket(TrafficInteractive)
+	assert.Nil(t, tb)
+	assert.True(t, shared)
+
+	require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, 1024))
+	tb, shared = classTokenBucket(TrafficInteractive)
+	require.NotNil(t, tb)
+	assert.False(t, shared)
+	assert.Equal(t, fs.SizeSuffix(1024), TrafficClassBwLimit(TrafficInteractive))
+
+	require.NoError(t, SetTrafficClassBwLimit(TrafficInteractive, -1))
+	tb, shared = classTokenBucket(TrafficInteractive)
+	assert.Nil(t, tb)
+	assert.False(t, shared)
+	assert.Equal(t, fs.SizeSuffix(-1), TrafficClassBwLimit(TrafficInteractive))
+
+	// Background traffic always shares --bwlimit
+	tb, shared = classTokenBucket(TrafficBackground)
+	assert.Nil(t, tb)
+	assert.True(t, shared)
+}
+
+func TestRcBwLimitClass(t *testing.T) {
+	defer func() {
+		require.NoError(t, SetTrafficClassBwLimit(TrafficWriteBack, 0))
+	}()
+	call := rc.Calls.Get("core/bwlimit")
+	require.NotNil(t, call)
+
+	out, err := call.Fn(context.Background(), rc.Params{"class": "write-back", "rate": "1M"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"bytesPerSecond": int64(1048576),
+		"rate":           "1M",
+	}, out)
+	assert.Equal(t, fs.SizeSuffix(1048576), TrafficClassBwLimit(TrafficWriteBack))
+
+	out, err = call.Fn(context.Background(), rc.Params{"class": "write-back"})
+	require.NoError(t, err)
+	assert.Equal(t, "1M", out["rate"])
+
+	_, err = call.Fn(context.Background(), rc.Params{"class": "potato"})
+	assert.Error(t, err)
+	_, err = call.Fn(context.Background(), rc.Params{"class": "background", "rate": "1M"})
+	require.NoError(t, err)
+	SetBwLimit(-1)
+}
+
+func TestPriorityGate(t *testing.T) {
+	g := newPriorityGate()
+
+	// An interactive transfer is waiting for the token bucket
+	g.enter(TrafficInteractive)
+
+	// So a background one has to wait for it
+	entered := make(chan struct{})
+	go func() {
+		g.enter(TrafficBackground)
+		close(entered)
+		g.leave(TrafficBackground)
+	}()
+
+	// But another interactive one doesn't
+	g.enter(TrafficInteractive)
+	g.leave(TrafficInteractive)
+
+	select {
+	case <-entered:
+		t.Fatal("background traffic entered while interactive was waiting")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.leave(TrafficInteractive)
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background traffic didn't enter")
+	}
+}
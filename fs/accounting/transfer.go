@@ -47,6 +47,7 @@ type Transfer struct {
 	// these are initialised at creation and may be accessed without locking
 	stats     *StatsInfo
 	remote    string
+	fsName    string // name of the remote of the object if known
 	size      int64
 	startedAt time.Time
 	checking  bool
@@ -64,23 +65,33 @@ type Transfer struct {
 
 // newCheckingTransfer instantiates new checking of the object.
 func newCheckingTransfer(stats *StatsInfo, obj fs.Object) *Transfer {
-	return newTransferRemoteSize(stats, obj.Remote(), obj.Size(), true)
+	return newTransferRemoteSize(stats, obj.Remote(), fsName(obj), obj.Size(), true)
 }
 
 // newTransfer instantiates new transfer.
 func newTransfer(stats *StatsInfo, obj fs.Object) *Transfer {
-	return newTransferRemoteSize(stats, obj.Remote(), obj.Size(), false)
+	return newTransferRemoteSize(stats, obj.Remote(), fsName(obj), obj.Size(), false)
 }
 
-func newTransferRemoteSize(stats *StatsInfo, remote string, size int64, checking bool) *Transfer {
+// fsName returns the name of the remote obj is on or "" if not known
+func fsName(obj fs.Object) string {
+	if f := obj.Fs(); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+func newTransferRemoteSize(stats *StatsInfo, remote, fsName string, size int64, checking bool) *Transfer {
 	tr := &Transfer{
 		stats:     stats,
 		remote:    remote,
+		fsName:    fsName,
 		size:      size,
 		startedAt: time.Now(),
 		checking:  checking,
 	}
 	stats.AddTransfer(tr)
+	tr.writeStartEvent()
 	return tr
 }
 
@@ -99,7 +110,9 @@ func (tr *Transfer) Done(err error) {
 	acc := tr.acc
 	tr.mu.RUnlock()
 
+	var bytes int64
 	if acc != nil {
+		bytes, _ = acc.progress()
 		// Close the file if it is still open
 		if err := acc.Close(); err != nil {
 			fs.LogLevelPrintf(fs.Config.StatsLogLevel, nil, "can't close account: %+v\n", err)
@@ -113,6 +126,8 @@ func (tr *Transfer) Done(err error) {
 	tr.mu.Lock()
 	tr.completedAt = time.Now()
 	tr.mu.Unlock()
+	tr.writeDoneEvent(bytes)
+	tr.stats.doneRemote(tr.fsName, tr.checking, bytes, err)
 
 	if tr.checking {
 		tr.stats.DoneChecking(tr.remote)
@@ -0,0 +1,334 @@
+// Package archive creates and extracts tar and zip archives on
+// remotes without using local temporary files.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// Archive formats
+const (
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+	FormatZip   = "zip"
+)
+
+// Formats is the list of supported archive formats
+var Formats = []string{FormatTar, FormatTarGz, FormatZip}
+
+// FormatFromName works out the archive format from the extension of
+// name, returning an error if it isn't recognised
+func FormatFromName(name string) (string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	}
+	return "", errors.Errorf("can't work out the archive format of %q - use --format", name)
+}
+
+// CheckFormat returns an error if format isn't supported
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown archive format %q - must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Create writes the files in fsrc matching the filters into an archive
+// of format called remote in fdst.
+//
+// The archive is streamed to fdst as it is made.
+func Create(ctx context.Context, fsrc fs.Fs, fdst fs.Fs, remote string, format string) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+	var objs []fs.Object
+	err := walk.ListR(ctx, fsrc, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			if filter.Active.IncludeObject(ctx, o) {
+				objs = append(objs, o)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to list source")
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].Remote() < objs[j].Remote()
+	})
+	fs.Infof(fdst, "Archiving %d files into %q", len(objs), remote)
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeArchive(ctx, pw, objs, format))
+	}()
+	_, err = operations.Rcat(ctx, fdst, remote, pr, time.Now())
+	_ = pr.CloseWithError(err) // stop the writer if Rcat failed
+	if err != nil {
+		return errors.Wrap(err, "failed to write archive")
+	}
+	return nil
+}
+
+// writeArchive writes objs to out as an archive of format
+func writeArchive(ctx context.Context, out io.Writer, objs []fs.Object, format string) (err error) {
+	switch format {
+	case FormatTar, FormatTarGz:
+		if format == FormatTarGz {
+			gz := gzip.NewWriter(out)
+			defer fs.CheckClose(gz, &err)
+			out = gz
+		}
+		tw := tar.NewWriter(out)
+		defer fs.CheckClose(tw, &err)
+		for _, o := range objs {
+			err = tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     o.Remote(),
+				Size:     o.Size(),
+				Mode:     0644,
+				ModTime:  o.ModTime(ctx),
+			})
+			if err != nil {
+				return err
+			}
+			err = copyObject(ctx, tw, o)
+			if err != nil {
+				return err
+			}
+		}
+	case FormatZip:
+		zw := zip.NewWriter(out)
+		defer fs.CheckClose(zw, &err)
+		for _, o := range objs {
+			w, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     o.Remote(),
+				Method:   zip.Deflate,
+				Modified: o.ModTime(ctx),
+			})
+			if err != nil {
+				return err
+			}
+			err = copyObject(ctx, w, o)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyObject copies the contents of o to out
+func copyObject(ctx context.Context, out io.Writer, o fs.Object) (err error) {
+	in, err := operations.NewReOpen(ctx, o, fs.Config.LowLevelRetries)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", o.Remote())
+	}
+	defer fs.CheckClose(in, &err)
+	n, err := io.Copy(out, in)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", o.Remote())
+	}
+	if o.Size() >= 0 && n != o.Size() {
+		return errors.Errorf("%q changed size while archiving: expected %d bytes got %d", o.Remote(), o.Size(), n)
+	}
+	fs.Debugf(o, "Archived")
+	return nil
+}
+
+// cleanName returns name as a remote path or "" if it points outside
+// the destination
+func cleanName(name string) string {
+	name = path.Clean("/" + strings.Replace(name, "\\", "/", -1))[1:]
+	if name == "" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// Extract extracts the files matching the filters from the archive
+// o of format into fdst.
+//
+// If format is "" it is worked out from the name of o.
+func Extract(ctx context.Context, o fs.Object, format string, fdst fs.Fs) (err error) {
+	if format == "" {
+		format, err = FormatFromName(o.Remote())
+		if err != nil {
+			return err
+		}
+	}
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+	var (
+		errorCount int
+		extracted  int
+	)
+	extract := func(name string, size int64, modTime time.Time, in io.ReadCloser) error {
+		remote := cleanName(name)
+		if remote == "" {
+			fs.Logf(name, "Skipping invalid name in archive")
+			return nil
+		}
+		if !filter.Active.Include(remote, size, modTime) {
+			fs.Debugf(remote, "Excluded from extract")
+			return nil
+		}
+		_, err := operations.RcatSize(ctx, fdst, remote, in, size, modTime)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(remote, "Failed to extract: %v", err)
+			errorCount++
+			// carry on with the next file
+			return nil
+		}
+		extracted++
+		return nil
+	}
+	switch format {
+	case FormatTar, FormatTarGz:
+		err = extractTar(ctx, o, format == FormatTarGz, extract)
+	case FormatZip:
+		err = extractZip(ctx, o, extract)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read archive")
+	}
+	fs.Infof(fdst, "Extracted %d files", extracted)
+	if errorCount > 0 {
+		return errors.Errorf("failed to extract %d files", errorCount)
+	}
+	return nil
+}
+
+// extractFn is called for each file in the archive
+type extractFn func(name string, size int64, modTime time.Time, in io.ReadCloser) error
+
+// extractTar reads the tar archive o, passing each regular file to fn
+func extractTar(ctx context.Context, o fs.Object, gzipped bool, fn extractFn) (err error) {
+	rc, err := operations.NewReOpen(ctx, o, fs.Config.LowLevelRetries)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(rc, &err)
+	var in io.Reader = rc
+	if gzipped {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return err
+		}
+		defer fs.CheckClose(gz, &err)
+		in = gz
+	}
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			fs.Debugf(hdr.Name, "Skipping non regular file in archive")
+			continue
+		}
+		err = fn(hdr.Name, hdr.Size, hdr.ModTime, ioutil.NopCloser(tr))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip reads the zip archive o, passing each regular file to fn
+func extractZip(ctx context.Context, o fs.Object, fn extractFn) error {
+	ra := &readerAt{ctx: ctx, o: o}
+	defer func() {
+		_ = ra.Close()
+	}()
+	zr, err := zip.NewReader(ra, o.Size())
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			fs.Debugf(file.Name, "Skipping non regular file in archive")
+			continue
+		}
+		in, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(file.Name, int64(file.UncompressedSize64), file.Modified, in)
+		closeErr := in.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return nil
+}
+
+// readerAt reads an object at random offsets as needed by zip, keeping
+// the object open while the reads are sequential
+type readerAt struct {
+	ctx context.Context
+	o   fs.Object
+	in  io.ReadCloser // current stream or nil
+	pos int64         // offset of in
+}
+
+// ReadAt reads len(p) bytes at off
+func (r *readerAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= r.o.Size() {
+		return 0, io.EOF
+	}
+	if r.in == nil || off != r.pos {
+		_ = r.Close()
+		r.in, err = r.o.Open(r.ctx, &fs.RangeOption{Start: off, End: -1})
+		if err != nil {
+			return 0, err
+		}
+		r.pos = off
+	}
+	n, err = io.ReadFull(r.in, p)
+	r.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close closes the current stream if any
+func (r *readerAt) Close() error {
+	if r.in == nil {
+		return nil
+	}
+	err := r.in.Close()
+	r.in = nil
+	return err
+}
@@ -0,0 +1,84 @@
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/all" // import all backends
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestFormatFromName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"files.tar", FormatTar},
+		{"dir/files.TAR.GZ", FormatTarGz},
+		{"files.tgz", FormatTarGz},
+		{"files.zip", FormatZip},
+		{"files.rar", ""},
+	} {
+		got, err := FormatFromName(test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.want == "", err != nil, test.in)
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"dir/file.txt", "dir/file.txt"},
+		{"/abs/file.txt", "abs/file.txt"},
+		{"../../etc/passwd", "etc/passwd"},
+		{`dir\file.txt`, "dir/file.txt"},
+		{"..", ""},
+		{"", ""},
+	} {
+		assert.Equal(t, test.want, cleanName(test.in), test.in)
+	}
+}
+
+func TestCreateExtract(t *testing.T) {
+	ctx := context.Background()
+	t1 := fstest.Time("2001-02-03T04:05:06Z")
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			r := fstest.NewRun(t)
+			defer r.Finalise()
+
+			file1 := r.WriteFile("one.txt", random.String(1000), t1)
+			file2 := r.WriteFile("dir/two.txt", "two", t1)
+			file3 := r.WriteFile("empty.txt", "", t1)
+
+			name := "archive." + format
+			require.NoError(t, Create(ctx, r.Flocal, r.Fremote, name, format))
+			o, err := r.Fremote.NewObject(ctx, name)
+			require.NoError(t, err)
+
+			require.NoError(t, operations.Purge(ctx, r.Flocal, ""))
+			require.NoError(t, Extract(ctx, o, "", r.Flocal))
+			fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file1, file2, file3}, nil, time.Second)
+		})
+	}
+}
+
+func TestCreateBadFormat(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	err := Create(context.Background(), r.Flocal, r.Fremote, "out.rar", "rar")
+	assert.Error(t, err)
+}
@@ -32,6 +32,7 @@ type AsyncReader struct {
 	in      io.ReadCloser // Input reader
 	ready   chan *buffer  // Buffers ready to be handed to the reader
 	token   chan struct{} // Tokens which allow a buffer to be taken
+	free    chan *buffer  // Buffers handed back by the reader for reuse
 	exit    chan struct{} // Closes when finished
 	buffers int           // Number of buffers
 	err     error         // If an error has occurred it is here
@@ -64,6 +65,7 @@ func (a *AsyncReader) init(rd io.ReadCloser, buffers int) {
 	a.in = rd
 	a.ready = make(chan *buffer, buffers)
 	a.token = make(chan struct{}, buffers)
+	a.free = make(chan *buffer, buffers)
 	a.exit = make(chan struct{}, 0)
 	a.exited = make(chan struct{}, 0)
 	a.buffers = buffers
@@ -75,6 +77,11 @@ func (a *AsyncReader) init(rd io.ReadCloser, buffers int) {
 		a.token <- struct{}{}
 	}
 
+	// Wait for the first buffer now while the caller holds no other
+	// buffers. The reader keeps at least this one until it is closed
+	// so it never waits for --max-buffer-memory while holding buffers.
+	a.free <- a.getBuffer()
+
 	// Start async reader
 	go func() {
 		// Ensure that when we exit this is signalled.
@@ -83,7 +90,10 @@ func (a *AsyncReader) init(rd io.ReadCloser, buffers int) {
 		for {
 			select {
 			case <-a.token:
-				b := a.getBuffer()
+				b := a.nextBuffer()
+				if b == nil {
+					return
+				}
 				if a.size < BufferSize {
 					b.buf = b.buf[:a.size]
 					a.size <<= 1
@@ -110,23 +120,55 @@ func (a *AsyncReader) putBuffer(b *buffer) {
 	b.buf = nil
 }
 
-// get a buffer from the pool
-func (a *AsyncReader) getBuffer() *buffer {
+// initialise the buffer pool when used
+func initBufferPool() {
 	bufferPoolOnce.Do(func() {
-		// Initialise the buffer pool when used
-		bufferPool = pool.New(bufferCacheFlushTime, BufferSize, bufferCacheSize, fs.Config.UseMmap)
+		bufferPool = pool.New(bufferCacheFlushTime, BufferSize, bufferCacheSize, fs.Config.UseMmap).WithReadAheadBudget()
 	})
+}
+
+// get a buffer from the pool waiting for --max-buffer-memory if needed
+func (a *AsyncReader) getBuffer() *buffer {
+	initBufferPool()
 	return &buffer{
 		buf: bufferPool.Get(),
 	}
 }
 
+// nextBuffer gets a buffer to read into, reusing one handed back by
+// the reader if possible.
+//
+// If there isn't enough --max-buffer-memory for another buffer this
+// shrinks the read ahead by waiting for the reader to hand one back.
+// It returns nil if the reader exits first.
+func (a *AsyncReader) nextBuffer() (b *buffer) {
+	select {
+	case b = <-a.free:
+	default:
+		if buf := bufferPool.TryGet(); buf != nil {
+			return &buffer{buf: buf}
+		}
+		select {
+		case b = <-a.free:
+		case <-a.exit:
+			return nil
+		}
+	}
+	b.buf = b.buf[:cap(b.buf)]
+	return b
+}
+
+// hand the buffer back for reuse by the read ahead
+func (a *AsyncReader) recycle(b *buffer) {
+	a.free <- b
+	a.token <- struct{}{}
+}
+
 // Read will return the next available data.
 func (a *AsyncReader) fill() (err error) {
 	if a.cur.isEmpty() {
 		if a.cur != nil {
-			a.putBuffer(a.cur)
-			a.token <- struct{}{}
+			a.recycle(a.cur)
 			a.cur = nil
 		}
 		b, ok := <-a.ready
@@ -202,8 +244,14 @@ func (a *AsyncReader) WriteTo(w io.Writer) (n int64, err error) {
 // On success it returns true. If 'skip' is outside the current buffer data or
 // an error occurs, Abandon is called and false is returned.
 func (a *AsyncReader) SkipBytes(skip int) (ok bool) {
+	var recycle []*buffer // buffers skipped over
 	a.mu.Lock()
 	defer func() {
+		if !ok {
+			for _, b := range recycle {
+				a.putBuffer(b)
+			}
+		}
 		a.mu.Unlock()
 		if !ok {
 			a.Abandon()
@@ -226,12 +274,10 @@ func (a *AsyncReader) SkipBytes(skip int) (ok bool) {
 		return false
 	}
 
-	refillTokens := 0
 	for {
 		if a.cur.isEmpty() {
 			if a.cur != nil {
-				a.putBuffer(a.cur)
-				refillTokens++
+				recycle = append(recycle, a.cur)
 				a.cur = nil
 			}
 			select {
@@ -252,8 +298,8 @@ func (a *AsyncReader) SkipBytes(skip int) (ok bool) {
 		a.cur.increment(n)
 		skip -= n
 		if skip == 0 {
-			for ; refillTokens > 0; refillTokens-- {
-				a.token <- struct{}{}
+			for _, b := range recycle {
+				a.recycle(b)
 			}
 			// If at end of buffer, store any error, if present
 			if a.cur.isEmpty() && a.cur.err != nil {
@@ -305,6 +351,14 @@ func (a *AsyncReader) Abandon() {
 	for b := range a.ready {
 		a.putBuffer(b)
 	}
+	for {
+		select {
+		case b := <-a.free:
+			a.putBuffer(b)
+		default:
+			return
+		}
+	}
 }
 
 // Close will ensure that the underlying async reader is shut down.
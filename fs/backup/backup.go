@@ -0,0 +1,219 @@
+// Package backup implements incremental, deduplicated snapshot
+// backups to any remote.
+//
+// Files are split into content defined chunks which are stored in a
+// repository on the remote named by their SHA-256, so a chunk is
+// only ever uploaded once however many files or snapshots contain
+// it. Each backup records a snapshot listing the files in the source
+// and the chunks which make them up.
+//
+// The repository layout is
+//
+//     config.json             - repository version and chunker parameters
+//     chunks/xx/xxxxxxxx...   - chunks named by their SHA-256
+//     snapshots/ID.json       - one file per snapshot
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// backup is the state of a running backup
+type backup struct {
+	r         *Repo
+	prev      map[string]*File // files in the previous snapshot of this source
+	newChunks int64            // number of chunks uploaded
+	newBytes  int64            // bytes of chunks uploaded
+}
+
+// Backup makes a snapshot of fsrc in the repository at frepo, making
+// the repository if necessary.
+//
+// Files which are unchanged in size and modification time since the
+// last snapshot of the same source are not read again.
+//
+// If any files fail then the snapshot is not saved and an error is
+// returned.
+func Backup(ctx context.Context, fsrc fs.Fs, frepo fs.Fs) (snap *Snapshot, err error) {
+	r, err := OpenRepo(ctx, frepo, true)
+	if err != nil {
+		return nil, err
+	}
+	err = r.loadChunks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list chunks")
+	}
+	b := &backup{
+		r:    r,
+		prev: make(map[string]*File),
+	}
+	snap = &Snapshot{
+		Time:   time.Now(),
+		Source: fs.ConfigString(fsrc),
+	}
+	snap.ID, err = newSnapshotID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the last snapshot of this source
+	snaps, err := r.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if snaps[i].Source == snap.Source {
+			fs.Debugf(frepo, "Comparing against previous snapshot %s", snaps[i].ID)
+			for j := range snaps[i].Files {
+				file := &snaps[i].Files[j]
+				b.prev[file.Path] = file
+			}
+			break
+		}
+	}
+
+	// List the source
+	var objs []fs.Object
+	err = walk.ListR(ctx, fsrc, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			objs = append(objs, o)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list source")
+	}
+
+	// Back up the files using --transfers workers
+	var (
+		in         = make(chan fs.Object)
+		wg         sync.WaitGroup
+		filesMu    sync.Mutex
+		errorCount int
+	)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range in {
+				file, err := b.backupFile(ctx, o)
+				filesMu.Lock()
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(o, "backup: failed: %v", err)
+					errorCount++
+				} else {
+					snap.Files = append(snap.Files, file)
+				}
+				filesMu.Unlock()
+			}
+		}()
+	}
+	for _, o := range objs {
+		in <- o
+	}
+	close(in)
+	wg.Wait()
+	sort.Slice(snap.Files, func(i, j int) bool {
+		return snap.Files[i].Path < snap.Files[j].Path
+	})
+
+	// Don't save a snapshot which is missing files as it would
+	// look like they had been deleted from the source. The chunks
+	// uploaded will be reused by the next backup.
+	if errorCount > 0 {
+		fs.Logf(frepo, "Not saving snapshot as %d files failed, uploaded %d new chunks, %v", errorCount, b.newChunks, fs.SizeSuffix(b.newBytes))
+		return nil, errors.Errorf("failed to back up %d files", errorCount)
+	}
+	if fs.Config.DryRun {
+		fs.Logf(frepo, "Not saving snapshot as --dry-run")
+	} else {
+		err = r.saveSnapshot(ctx, snap)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to save snapshot")
+		}
+	}
+	fs.Infof(frepo, "Snapshot %s: %d files, %v, uploaded %d new chunks, %v", snap.ID, len(snap.Files), fs.SizeSuffix(snap.Size()), b.newChunks, fs.SizeSuffix(b.newBytes))
+	return snap, nil
+}
+
+// backupFile stores the chunks of o returning its entry for the
+// snapshot
+func (b *backup) backupFile(ctx context.Context, o fs.Object) (file File, err error) {
+	file = File{
+		Path:    o.Remote(),
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx),
+	}
+
+	// Reuse the chunks from the previous snapshot if unchanged
+	if prev := b.prev[file.Path]; prev != nil && prev.Size == file.Size && prev.ModTime.Equal(file.ModTime) {
+		ok := true
+		for _, id := range prev.Chunks {
+			if !b.r.hasChunk(id) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			fs.Debugf(o, "backup: unchanged")
+			file.Chunks = prev.Chunks
+			return file, nil
+		}
+	}
+
+	tr := accounting.Stats(ctx).NewTransfer(o)
+	defer func() {
+		tr.Done(err)
+	}()
+	in0, err := o.Open(ctx)
+	if err != nil {
+		return file, errors.Wrap(err, "failed to open source")
+	}
+	in := tr.Account(ctx, in0).WithBuffer()
+	defer fs.CheckClose(in, &err)
+
+	var (
+		c    = newChunker(in, b.r.config.ChunkMin, b.r.config.ChunkAvg, b.r.config.ChunkMax)
+		size int64
+	)
+	file.Chunks = []string{}
+	for {
+		data, err := c.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return file, err
+		}
+		size += int64(len(data))
+		sum := sha256.Sum256(data)
+		id := hex.EncodeToString(sum[:])
+		uploaded, err := b.r.putChunk(ctx, id, data)
+		if err != nil {
+			return file, err
+		}
+		if uploaded {
+			atomic.AddInt64(&b.newChunks, 1)
+			atomic.AddInt64(&b.newBytes, int64(len(data)))
+		}
+		file.Chunks = append(file.Chunks, id)
+	}
+	if file.Size >= 0 && size != file.Size {
+		return file, errors.Errorf("source changed size while reading: expecting %d bytes but read %d", file.Size, size)
+	}
+	file.Size = size
+	fs.Debugf(o, "backup: stored in %d chunks", len(file.Chunks))
+	return file, nil
+}
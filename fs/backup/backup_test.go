@@ -0,0 +1,146 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/all" // import all backends
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Some times used in the tests
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	defaultConfig.ChunkMin, defaultConfig.ChunkAvg, defaultConfig.ChunkMax = 16, 64, 256
+	fstest.TestMain(m)
+}
+
+// countChunks returns the number of chunks in the repository
+func countChunks(t *testing.T, f fs.Fs) (n int) {
+	err := walk.ListR(context.Background(), f, chunksDir, true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		n += len(entries)
+		return nil
+	})
+	require.NoError(t, err)
+	return n
+}
+
+func TestOpenRepoMissing(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	_, err := OpenRepo(context.Background(), r.Fremote, false)
+	assert.Equal(t, ErrorNoRepository, err)
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	big := random.String(1000)
+	file1 := r.WriteFile("one.txt", big, t1)
+	file2 := r.WriteFile("dir/two.txt", big, t1) // same contents
+	file3 := r.WriteFile("empty.txt", "", t1)
+
+	snap1, err := Backup(ctx, r.Flocal, r.Fremote)
+	require.NoError(t, err)
+	assert.Len(t, snap1.Files, 3)
+	nChunks := countChunks(t, r.Fremote)
+	assert.True(t, nChunks > 0)
+
+	// Nothing changed so nothing uploaded
+	_, err = Backup(ctx, r.Flocal, r.Fremote)
+	require.NoError(t, err)
+	assert.Equal(t, nChunks, countChunks(t, r.Fremote))
+
+	// Change the end of a file
+	file1 = r.WriteFile("one.txt", big[:900]+"changed", t2)
+	snap3, err := Backup(ctx, r.Flocal, r.Fremote)
+	require.NoError(t, err)
+	newChunks := countChunks(t, r.Fremote) - nChunks
+	assert.True(t, newChunks > 0 && newChunks < nChunks, "expecting only a few new chunks but got %d", newChunks)
+
+	repo, err := OpenRepo(ctx, r.Fremote, false)
+	require.NoError(t, err)
+	snaps, err := repo.Snapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snaps, 3)
+	assert.Equal(t, snap1.ID, snaps[0].ID)
+	latest, err := repo.Snapshot(ctx, "latest")
+	require.NoError(t, err)
+	assert.Equal(t, snap3.ID, latest.ID)
+
+	// Restore the latest snapshot into an empty directory
+	require.NoError(t, operations.Purge(ctx, r.Flocal, ""))
+	require.NoError(t, Restore(ctx, r.Fremote, snap3.ID[:6], r.Flocal))
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+
+	// Restore the first snapshot over it
+	require.NoError(t, Restore(ctx, r.Fremote, snap1.ID, r.Flocal))
+	file1 = fstest.NewItem("one.txt", big, t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+
+	_, err = repo.Snapshot(ctx, "potato")
+	assert.Error(t, err)
+}
+
+func TestPutChunkConcurrent(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	repo, err := OpenRepo(ctx, r.Fremote, true)
+	require.NoError(t, err)
+
+	data := []byte(random.String(1000))
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+
+	// Every caller must only return once the chunk is stored
+	const n = 8
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		uploads  int
+		missing  int
+		failures int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uploaded, err := repo.putChunk(ctx, id, data)
+			_, findErr := r.Fremote.NewObject(ctx, chunkPath(id))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures++
+			}
+			if uploaded {
+				uploads++
+			}
+			if findErr != nil {
+				missing++
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 0, failures)
+	assert.Equal(t, 1, uploads)
+	assert.Equal(t, 0, missing)
+	assert.True(t, repo.hasChunk(id))
+	assert.Len(t, repo.uploading, 0)
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Chunk size limits used by the content defined chunker
+const (
+	minChunkSize = 512 * 1024
+	avgChunkSize = 1024 * 1024 // must be a power of 2
+	maxChunkSize = 8 * 1024 * 1024
+)
+
+// gear is the table of random values used by the rolling hash
+//
+// It is generated with splitmix64 from a fixed seed so it never
+// changes, otherwise existing repositories would stop deduplicating.
+var gear = func() (table [256]uint64) {
+	x := uint64(0x72636c6f6e65) // "rclone"
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// chunker splits a stream into content defined chunks.
+//
+// The chunk boundaries are found with a gear based rolling hash so
+// they depend only on the nearby data. This means that inserting or
+// removing data in a file only changes the chunks around the edit
+// and the rest of the file deduplicates against the previous version.
+type chunker struct {
+	in    io.Reader
+	buf   []byte // buffer of maxSize
+	start int    // start of unreturned data in buf
+	end   int    // end of data in buf
+	eof   bool   // set if in has returned io.EOF
+	min   int    // minimum chunk size
+	mask  uint64 // mask for the rolling hash giving the average chunk size
+}
+
+// newChunker makes a chunker reading from in with the chunk size
+// limits passed in. avg must be a power of 2.
+func newChunker(in io.Reader, min, avg, max int) *chunker {
+	return &chunker{
+		in:   in,
+		buf:  make([]byte, max),
+		min:  min,
+		mask: uint64(avg - 1),
+	}
+}
+
+// cut returns the length of the first chunk in data
+func (c *chunker) cut(data []byte) int {
+	if len(data) <= c.min {
+		return len(data)
+	}
+	var h uint64
+	for i := c.min; i < len(data); i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&c.mask == 0 {
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// Next returns the next chunk or io.EOF if there are no more.
+//
+// The returned slice is only valid until the next call to Next.
+func (c *chunker) Next() ([]byte, error) {
+	// Move the unreturned data to the start and fill the buffer
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	for !c.eof && c.end < len(c.buf) {
+		n, err := c.in.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to read source")
+		}
+	}
+	if c.end == 0 {
+		return nil, io.EOF
+	}
+	c.start = c.cut(c.buf[:c.end])
+	return c.buf[:c.start], nil
+}
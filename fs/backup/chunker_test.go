@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunks splits data with a small chunker returning the chunks
+func chunks(t *testing.T, data []byte) (out []string) {
+	c := newChunker(bytes.NewReader(data), 64, 256, 1024)
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		out = append(out, string(chunk))
+	}
+	return out
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	got := chunks(t, data)
+	total := 0
+	for i, chunk := range got {
+		total += len(chunk)
+		assert.True(t, len(chunk) <= 1024, "chunk %d too big", i)
+		if i < len(got)-1 {
+			assert.True(t, len(chunk) > 64, "chunk %d too small", i)
+		}
+	}
+	assert.Equal(t, len(data), total)
+	assert.True(t, len(got) > 64*1024/1024, "expecting more chunks than the maximum size gives")
+
+	// Inserting data only changes the chunks near the insertion
+	edited := append(append(append([]byte{}, data[:32*1024]...), []byte("inserted")...), data[32*1024:]...)
+	gotEdited := chunks(t, edited)
+	seen := make(map[string]bool, len(got))
+	for _, chunk := range got {
+		seen[chunk] = true
+	}
+	changed := 0
+	for _, chunk := range gotEdited {
+		if !seen[chunk] {
+			changed++
+		}
+	}
+	assert.True(t, changed <= 3, "expecting few changed chunks but got %d of %d", changed, len(gotEdited))
+}
+
+func TestChunkerEmpty(t *testing.T) {
+	assert.Nil(t, chunks(t, nil))
+	assert.Equal(t, []string{"hello"}, chunks(t, []byte("hello")))
+}
@@ -0,0 +1,317 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// Layout of the repository
+const (
+	configName   = "config.json"
+	chunksDir    = "chunks"
+	snapshotsDir = "snapshots"
+	repoVersion  = 1
+)
+
+// ErrorNoRepository is returned when opening a path which isn't a
+// backup repository
+var ErrorNoRepository = errors.New("no backup repository found - make one with rclone backup")
+
+// repoConfig is stored in the root of the repository
+type repoConfig struct {
+	Version  int `json:"version"`
+	ChunkMin int `json:"chunkMin"`
+	ChunkAvg int `json:"chunkAvg"`
+	ChunkMax int `json:"chunkMax"`
+}
+
+// defaultConfig is used to make new repositories
+var defaultConfig = repoConfig{
+	Version:  repoVersion,
+	ChunkMin: minChunkSize,
+	ChunkAvg: avgChunkSize,
+	ChunkMax: maxChunkSize,
+}
+
+// File is a file recorded in a snapshot
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Chunks  []string  `json:"chunks"` // SHA-256 of each chunk in order
+}
+
+// Snapshot is the state of the source at the time of a backup
+type Snapshot struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Files  []File    `json:"files"`
+}
+
+// Size returns the total size of the files in the snapshot
+func (s *Snapshot) Size() (size int64) {
+	for _, file := range s.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// Repo is a backup repository stored on a remote
+type Repo struct {
+	f         fs.Fs
+	config    repoConfig
+	chunksMu  sync.Mutex
+	chunks    map[string]struct{}     // set of chunks in the repository
+	uploading map[string]*chunkUpload // chunks being uploaded now
+}
+
+// chunkUpload is an upload of a chunk in progress
+type chunkUpload struct {
+	done chan struct{} // closed when the upload has finished
+	err  error         // error from the upload, read after done is closed
+}
+
+// readFile reads the whole of remote from f
+func readFile(ctx context.Context, f fs.Fs, remote string) (data []byte, err error) {
+	o, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	return ioutil.ReadAll(in)
+}
+
+// writeFile writes data to remote on f
+func writeFile(ctx context.Context, f fs.Fs, remote string, data []byte) error {
+	info := object.NewStaticObjectInfo(remote, time.Now(), int64(len(data)), true, nil, f)
+	_, err := f.Put(ctx, bytes.NewReader(data), info)
+	return err
+}
+
+// OpenRepo opens the backup repository at f
+//
+// If create is set then a new repository is made if there isn't one,
+// otherwise ErrorNoRepository is returned.
+func OpenRepo(ctx context.Context, f fs.Fs, create bool) (*Repo, error) {
+	r := &Repo{
+		f:         f,
+		chunks:    make(map[string]struct{}),
+		uploading: make(map[string]*chunkUpload),
+	}
+	data, err := readFile(ctx, f, configName)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorDirNotFound {
+		if !create {
+			return nil, ErrorNoRepository
+		}
+		r.config = defaultConfig
+		if fs.Config.DryRun {
+			fs.Logf(f, "Not creating backup repository as --dry-run")
+			return r, nil
+		}
+		data, err = json.MarshalIndent(&r.config, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		err = writeFile(ctx, f, configName, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create backup repository")
+		}
+		fs.Infof(f, "Created backup repository")
+		return r, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read backup repository config")
+	}
+	err = json.Unmarshal(data, &r.config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse backup repository config")
+	}
+	if r.config.Version > repoVersion {
+		return nil, errors.Errorf("backup repository version %d is newer than supported version %d - upgrade rclone", r.config.Version, repoVersion)
+	}
+	return r, nil
+}
+
+// chunkPath returns the path of the chunk with id in the repository
+func chunkPath(id string) string {
+	return path.Join(chunksDir, id[:2], id)
+}
+
+// loadChunks reads the list of chunks in the repository
+func (r *Repo) loadChunks(ctx context.Context) error {
+	r.chunksMu.Lock()
+	defer r.chunksMu.Unlock()
+	err := walk.ListR(ctx, r.f, chunksDir, true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			r.chunks[path.Base(entry.Remote())] = struct{}{}
+		}
+		return nil
+	})
+	if err == fs.ErrorDirNotFound {
+		err = nil
+	}
+	return err
+}
+
+// hasChunk returns true if the chunk with id is in the repository
+func (r *Repo) hasChunk(id string) bool {
+	r.chunksMu.Lock()
+	_, found := r.chunks[id]
+	r.chunksMu.Unlock()
+	return found
+}
+
+// putChunk uploads the chunk with id if it isn't already stored. It
+// returns whether the chunk was uploaded.
+//
+// If the chunk is being uploaded by another caller it waits for that
+// upload to finish, uploading the chunk itself if that failed, so the
+// chunk is always stored when this returns without error.
+func (r *Repo) putChunk(ctx context.Context, id string, data []byte) (bool, error) {
+	r.chunksMu.Lock()
+	for {
+		if _, found := r.chunks[id]; found {
+			r.chunksMu.Unlock()
+			return false, nil
+		}
+		upload := r.uploading[id]
+		if upload == nil {
+			break
+		}
+		r.chunksMu.Unlock()
+		select {
+		case <-upload.done:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		r.chunksMu.Lock()
+	}
+	upload := &chunkUpload{done: make(chan struct{})}
+	r.uploading[id] = upload
+	r.chunksMu.Unlock()
+
+	if !fs.Config.DryRun {
+		upload.err = writeFile(ctx, r.f, chunkPath(id), data)
+	}
+
+	r.chunksMu.Lock()
+	delete(r.uploading, id)
+	if upload.err == nil {
+		r.chunks[id] = struct{}{}
+	}
+	r.chunksMu.Unlock()
+	close(upload.done)
+	if upload.err != nil {
+		return false, errors.Wrapf(upload.err, "failed to upload chunk %s", id)
+	}
+	return true, nil
+}
+
+// openChunk opens the chunk with id for reading
+func (r *Repo) openChunk(ctx context.Context, id string) (io.ReadCloser, error) {
+	o, err := r.f.NewObject(ctx, chunkPath(id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find chunk %s", id)
+	}
+	return o.Open(ctx)
+}
+
+// newSnapshotID makes a random ID for a snapshot
+func newSnapshotID() (string, error) {
+	var id [8]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// snapshotPath returns the path of the snapshot with id
+func snapshotPath(id string) string {
+	return path.Join(snapshotsDir, id+".json")
+}
+
+// saveSnapshot writes the snapshot to the repository
+func (r *Repo) saveSnapshot(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return writeFile(ctx, r.f, snapshotPath(snap.ID), data)
+}
+
+// Snapshots returns all the snapshots in the repository, oldest first
+func (r *Repo) Snapshots(ctx context.Context) (snaps []*Snapshot, err error) {
+	entries, err := r.f.List(ctx, snapshotsDir)
+	if err == fs.ErrorDirNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to list snapshots")
+	}
+	for _, entry := range entries {
+		if _, ok := entry.(fs.Object); !ok || !strings.HasSuffix(entry.Remote(), ".json") {
+			continue
+		}
+		data, err := readFile(ctx, r.f, entry.Remote())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read snapshot %q", entry.Remote())
+		}
+		snap := new(Snapshot)
+		err = json.Unmarshal(data, snap)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse snapshot %q", entry.Remote())
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].Time.Before(snaps[j].Time)
+	})
+	return snaps, nil
+}
+
+// Snapshot returns the snapshot identified by id which may be a
+// unique prefix of the ID or "latest"
+func (r *Repo) Snapshot(ctx context.Context, id string) (*Snapshot, error) {
+	snaps, err := r.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, errors.New("no snapshots found")
+	}
+	if id == "latest" {
+		return snaps[len(snaps)-1], nil
+	}
+	var found *Snapshot
+	for _, snap := range snaps {
+		if strings.HasPrefix(snap.ID, id) {
+			if found != nil {
+				return nil, errors.Errorf("snapshot ID %q is ambiguous", id)
+			}
+			found = snap
+		}
+	}
+	if found == nil {
+		return nil, errors.Errorf("snapshot %q not found", id)
+	}
+	return found, nil
+}
@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// chunkReader reads the chunks of a file in order, opening each one
+// when it is needed and checking its SHA-256 when it has been read.
+type chunkReader struct {
+	ctx    context.Context
+	r      *Repo
+	chunks []string      // chunks still to open
+	id     string        // ID of the current chunk
+	in     io.ReadCloser // current chunk, nil if none open
+	hash   hash.Hash     // hash of the current chunk
+}
+
+// Read reads from the current chunk moving onto the next at EOF
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	for {
+		if cr.in == nil {
+			if len(cr.chunks) == 0 {
+				return 0, io.EOF
+			}
+			cr.id, cr.chunks = cr.chunks[0], cr.chunks[1:]
+			cr.in, err = cr.r.openChunk(cr.ctx, cr.id)
+			if err != nil {
+				return 0, err
+			}
+			cr.hash = sha256.New()
+		}
+		n, err = cr.in.Read(p)
+		_, _ = cr.hash.Write(p[:n])
+		if err == io.EOF {
+			err = cr.in.Close()
+			cr.in = nil
+			if err != nil {
+				return n, err
+			}
+			if sum := hex.EncodeToString(cr.hash.Sum(nil)); sum != cr.id {
+				return n, errors.Errorf("chunk %s is corrupted: SHA-256 is %s", cr.id, sum)
+			}
+			if n == 0 {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+// Close closes the current chunk if any
+func (cr *chunkReader) Close() error {
+	if cr.in == nil {
+		return nil
+	}
+	err := cr.in.Close()
+	cr.in = nil
+	return err
+}
+
+// Restore writes the files in the snapshot id from the repository at
+// frepo to fdst. id may be a unique prefix of the snapshot ID or
+// "latest".
+//
+// Only files matching the filters are restored and files already in
+// fdst with the same size and modification time are skipped.
+func Restore(ctx context.Context, frepo fs.Fs, id string, fdst fs.Fs) error {
+	r, err := OpenRepo(ctx, frepo, false)
+	if err != nil {
+		return err
+	}
+	snap, err := r.Snapshot(ctx, id)
+	if err != nil {
+		return err
+	}
+	fs.Infof(fdst, "Restoring snapshot %s from %v", snap.ID, snap.Time)
+
+	var (
+		in         = make(chan *File)
+		wg         sync.WaitGroup
+		errorMu    sync.Mutex
+		errorCount int
+	)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range in {
+				if err := r.restoreFile(ctx, file, fdst); err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(file.Path, "restore: failed: %v", err)
+					errorMu.Lock()
+					errorCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range snap.Files {
+		file := &snap.Files[i]
+		if !filter.Active.Include(file.Path, file.Size, file.ModTime) {
+			continue
+		}
+		in <- file
+	}
+	close(in)
+	wg.Wait()
+	if errorCount > 0 {
+		return errors.Errorf("failed to restore %d files", errorCount)
+	}
+	return nil
+}
+
+// restoreFile writes a single file from the snapshot to fdst
+func (r *Repo) restoreFile(ctx context.Context, file *File, fdst fs.Fs) error {
+	dst, err := fdst.NewObject(ctx, file.Path)
+	if err == nil && dst.Size() == file.Size {
+		dt := dst.ModTime(ctx).Sub(file.ModTime)
+		if window := fs.GetModifyWindow(fdst); window != fs.ModTimeNotSupported && dt < window && dt > -window {
+			fs.Debugf(dst, "restore: unchanged skipping")
+			return nil
+		}
+	}
+	cr := &chunkReader{
+		ctx:    ctx,
+		r:      r,
+		chunks: file.Chunks,
+	}
+	_, err = operations.RcatSize(ctx, fdst, file.Path, cr, file.Size, file.ModTime)
+	closeErr := cr.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
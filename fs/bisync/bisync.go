@@ -0,0 +1,490 @@
+// Package bisync implements bidirectional sync between two remotes
+//
+// The state of both sides is recorded in listings at the end of each
+// successful run. On the next run each side is compared against its
+// listing to find what was created, modified or deleted on that side
+// and the changes are propagated to the other side. Files changed on
+// both sides are resolved according to the ConflictPolicy.
+package bisync
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	fssync "github.com/rclone/rclone/fs/sync"
+)
+
+// Options control the bisync
+type Options struct {
+	Resync         bool           // copy both ways to make the initial listings
+	ConflictPolicy ConflictPolicy // how to resolve files changed on both sides
+	MaxDelete      int            // abort if more than this percentage of files would be deleted on either side
+	Force          bool           // ignore MaxDelete
+	CheckSum       bool           // use hashes rather than modification times to detect changes
+	WorkDir        string         // directory to keep the listings in
+}
+
+// DefaultOpt is the default values for Options
+var DefaultOpt = Options{
+	ConflictPolicy: ConflictPolicyNewer,
+	MaxDelete:      50,
+}
+
+// ErrorNeedResync is returned if there are no listings to compare
+// against so the bisync needs to be started with --resync
+var ErrorNeedResync = errors.New("no prior listings found - run with --resync to make them")
+
+// ConflictPolicy describes how to resolve files changed on both sides
+type ConflictPolicy byte
+
+// ConflictPolicy constants
+const (
+	ConflictPolicyNewer  ConflictPolicy = iota // the most recently modified file wins
+	ConflictPolicyRename                       // keep both files, renamed with a ..path1 and ..path2 suffix
+	ConflictPolicyPath1                        // the file on path1 wins
+	ConflictPolicyPath2                        // the file on path2 wins
+)
+
+var conflictPolicyToString = []string{
+	ConflictPolicyNewer:  "newer",
+	ConflictPolicyRename: "rename",
+	ConflictPolicyPath1:  "path1",
+	ConflictPolicyPath2:  "path2",
+}
+
+// String turns a ConflictPolicy into a string
+func (p ConflictPolicy) String() string {
+	if p >= ConflictPolicy(len(conflictPolicyToString)) {
+		return fmt.Sprintf("ConflictPolicy(%d)", p)
+	}
+	return conflictPolicyToString[p]
+}
+
+// Set a ConflictPolicy
+func (p *ConflictPolicy) Set(s string) error {
+	for n, name := range conflictPolicyToString {
+		if s != "" && name == s {
+			*p = ConflictPolicy(n)
+			return nil
+		}
+	}
+	return errors.Errorf("unknown conflict policy %q", s)
+}
+
+// Type of the value
+func (p *ConflictPolicy) Type() string {
+	return "string"
+}
+
+// change describes how a file changed on one side since the last run
+type change byte
+
+const (
+	changeNone change = iota
+	changeNew
+	changeModified
+	changeDeleted
+)
+
+var changeToString = []string{
+	changeNone:     "unchanged",
+	changeNew:      "new",
+	changeModified: "modified",
+	changeDeleted:  "deleted",
+}
+
+func (c change) String() string {
+	return changeToString[c]
+}
+
+// bisync contains the state of a run
+type bisync struct {
+	opt        *Options
+	f1, f2     fs.Fs
+	ht1, ht2   hash.Type
+	listing1   string // path of the listing for f1
+	listing2   string // path of the listing for f2
+	journal    string // path of the journal marking a run in progress
+	errorMu    sync.Mutex
+	errorCount int
+}
+
+// Bisync makes f1 and f2 identical by propagating the changes made on
+// each side since the last run to the other side.
+func Bisync(ctx context.Context, f1, f2 fs.Fs, opt *Options) (err error) {
+	if operations.Overlapping(f1, f2) {
+		return fserrors.FatalError(fs.ErrorOverlapping)
+	}
+	b := &bisync{
+		opt: opt,
+		f1:  f1,
+		f2:  f2,
+	}
+	if opt.CheckSum {
+		b.ht1 = f1.Hashes().GetOne()
+		b.ht2 = f2.Hashes().GetOne()
+		if b.ht1 == hash.None || b.ht2 == hash.None {
+			return errors.New("--check-sum needs both remotes to support hashes")
+		}
+	}
+	workDir := opt.WorkDir
+	if workDir == "" {
+		workDir = filepath.Join(config.CacheDir, "bisync")
+	}
+	session := sessionName(f1, f2)
+	b.listing1 = filepath.Join(workDir, session+".path1.lst")
+	b.listing2 = filepath.Join(workDir, session+".path2.lst")
+	b.journal = filepath.Join(workDir, session+".journal")
+
+	if opt.Resync {
+		return b.resync(ctx)
+	}
+	return b.run(ctx)
+}
+
+// sessionName makes a file name safe name for the pair of remotes
+func sessionName(f1, f2 fs.Fs) string {
+	unsafe := regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	clean := func(f fs.Fs) string {
+		return unsafe.ReplaceAllString(fs.ConfigString(f), "_")
+	}
+	return clean(f1) + ".." + clean(f2)
+}
+
+// startJournal records that a run is in progress, noting if a
+// previous run was interrupted
+func (b *bisync) startJournal() error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	if _, err := os.Stat(b.journal); err == nil {
+		fs.Logf(nil, "bisync: previous run was interrupted - recovering by comparing both sides against the last good listings")
+	}
+	err := os.MkdirAll(filepath.Dir(b.journal), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make bisync work directory")
+	}
+	msg := fmt.Sprintf("bisync started %s\n", time.Now().Format(time.RFC3339))
+	return ioutil.WriteFile(b.journal, []byte(msg), 0600)
+}
+
+// finish saves new listings of both sides and removes the journal
+func (b *bisync) finish(ctx context.Context) error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	ls1, err := makeListing(ctx, b.f1, b.ht1)
+	if err != nil {
+		return err
+	}
+	ls2, err := makeListing(ctx, b.f2, b.ht2)
+	if err != nil {
+		return err
+	}
+	if err = ls1.save(b.listing1, b.f1, b.ht1); err != nil {
+		return err
+	}
+	if err = ls2.save(b.listing2, b.f2, b.ht2); err != nil {
+		return err
+	}
+	err = os.Remove(b.journal)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove journal")
+	}
+	return nil
+}
+
+// resync copies path1 to path2 then path2 to path1 and records the
+// result as the new listings
+func (b *bisync) resync(ctx context.Context) error {
+	fs.Infof(nil, "bisync: resync - copying %v to %v", b.f1, b.f2)
+	if err := b.startJournal(); err != nil {
+		return err
+	}
+	if err := fssync.CopyDir(ctx, b.f2, b.f1, false); err != nil {
+		return errors.Wrap(err, "resync path1 to path2 failed")
+	}
+	fs.Infof(nil, "bisync: resync - copying %v to %v", b.f2, b.f1)
+	if err := fssync.CopyDir(ctx, b.f1, b.f2, false); err != nil {
+		return errors.Wrap(err, "resync path2 to path1 failed")
+	}
+	return b.finish(ctx)
+}
+
+// findChanges compares the current listing of a side against the
+// previous one
+func (b *bisync) findChanges(f fs.Fs, prev, cur listing) map[string]change {
+	window := fs.GetModifyWindow(f)
+	changes := make(map[string]change)
+	for remote, now := range cur {
+		then, ok := prev[remote]
+		switch {
+		case !ok:
+			changes[remote] = changeNew
+		case now.Size != then.Size:
+			changes[remote] = changeModified
+		case now.Hash != "" && then.Hash != "":
+			if now.Hash != then.Hash {
+				changes[remote] = changeModified
+			}
+		case window != fs.ModTimeNotSupported:
+			dt := now.ModTime.Sub(then.ModTime)
+			if dt < -window || dt > window {
+				changes[remote] = changeModified
+			}
+		}
+	}
+	for remote := range prev {
+		if _, ok := cur[remote]; !ok {
+			changes[remote] = changeDeleted
+		}
+	}
+	return changes
+}
+
+// action is something to be done to bring the sides into line
+type action struct {
+	what   string // description for logging
+	remote string
+	fn     func(ctx context.Context) error
+}
+
+// run does an incremental bisync
+func (b *bisync) run(ctx context.Context) error {
+	prev1, err := loadListing(b.listing1)
+	if os.IsNotExist(errors.Cause(err)) {
+		return ErrorNeedResync
+	} else if err != nil {
+		return err
+	}
+	prev2, err := loadListing(b.listing2)
+	if os.IsNotExist(errors.Cause(err)) {
+		return ErrorNeedResync
+	} else if err != nil {
+		return err
+	}
+	if err = b.startJournal(); err != nil {
+		return err
+	}
+	cur1, err := makeListing(ctx, b.f1, b.ht1)
+	if err != nil {
+		return err
+	}
+	cur2, err := makeListing(ctx, b.f2, b.ht2)
+	if err != nil {
+		return err
+	}
+	changes1 := b.findChanges(b.f1, prev1, cur1)
+	changes2 := b.findChanges(b.f2, prev2, cur2)
+	fs.Infof(nil, "bisync: %d changes on path1, %d changes on path2", len(changes1), len(changes2))
+
+	actions, deletes1, deletes2, err := b.plan(ctx, changes1, changes2)
+	if err != nil {
+		return err
+	}
+	if !b.opt.Force {
+		if err = b.checkMaxDelete(b.f1, deletes1, len(prev1)); err != nil {
+			return err
+		}
+		if err = b.checkMaxDelete(b.f2, deletes2, len(prev2)); err != nil {
+			return err
+		}
+	}
+	b.apply(ctx, actions)
+	if b.errorCount > 0 {
+		return errors.Errorf("bisync: %d errors - listings not updated, the next run will retry", b.errorCount)
+	}
+	return b.finish(ctx)
+}
+
+// checkMaxDelete returns an error if too many files would be deleted
+// from f
+func (b *bisync) checkMaxDelete(f fs.Fs, deletes, total int) error {
+	if total == 0 || deletes == 0 {
+		return nil
+	}
+	percent := 100 * deletes / total
+	if percent > b.opt.MaxDelete {
+		return errors.Errorf("bisync: refusing to delete %d of %d files (%d%%) from %v which is more than --max-delete-percent %d%% - use --force to override", deletes, total, percent, f, b.opt.MaxDelete)
+	}
+	return nil
+}
+
+// plan works out the actions needed to reconcile the changes
+func (b *bisync) plan(ctx context.Context, changes1, changes2 map[string]change) (actions []action, deletes1, deletes2 int, err error) {
+	remotes := make([]string, 0, len(changes1)+len(changes2))
+	for remote := range changes1 {
+		remotes = append(remotes, remote)
+	}
+	for remote := range changes2 {
+		if _, ok := changes1[remote]; !ok {
+			remotes = append(remotes, remote)
+		}
+	}
+	sort.Strings(remotes)
+	for _, remote := range remotes {
+		c1, c2 := changes1[remote], changes2[remote]
+		switch {
+		case c2 == changeNone && c1 == changeDeleted:
+			actions = append(actions, b.deleteAction(b.f2, remote))
+			deletes2++
+		case c2 == changeNone:
+			actions = append(actions, b.copyAction(b.f1, b.f2, remote))
+		case c1 == changeNone && c2 == changeDeleted:
+			actions = append(actions, b.deleteAction(b.f1, remote))
+			deletes1++
+		case c1 == changeNone:
+			actions = append(actions, b.copyAction(b.f2, b.f1, remote))
+		case c1 == changeDeleted && c2 == changeDeleted:
+			// deleted on both sides - nothing to do
+		case c1 == changeDeleted:
+			// modified on path2 wins over a delete on path1
+			actions = append(actions, b.copyAction(b.f2, b.f1, remote))
+		case c2 == changeDeleted:
+			// modified on path1 wins over a delete on path2
+			actions = append(actions, b.copyAction(b.f1, b.f2, remote))
+		default:
+			action, err := b.resolveConflict(ctx, remote)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			if action != nil {
+				actions = append(actions, *action)
+			}
+		}
+	}
+	return actions, deletes1, deletes2, nil
+}
+
+// resolveConflict works out what to do with a file changed on both
+// sides, returning nil if nothing needs doing
+func (b *bisync) resolveConflict(ctx context.Context, remote string) (*action, error) {
+	o1, err := b.f1.NewObject(ctx, remote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q from path1", remote)
+	}
+	o2, err := b.f2.NewObject(ctx, remote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q from path2", remote)
+	}
+	// This is also the case when a previous run was interrupted
+	// after the copy but before the listings were saved.
+	if operations.Equal(ctx, o1, o2) {
+		fs.Debugf(remote, "bisync: changed identically on both sides")
+		return nil, nil
+	}
+	policy := b.opt.ConflictPolicy
+	if policy == ConflictPolicyNewer {
+		window := fs.GetModifyWindow(b.f1, b.f2)
+		dt := o1.ModTime(ctx).Sub(o2.ModTime(ctx))
+		switch {
+		case window == fs.ModTimeNotSupported, dt >= -window && dt <= window:
+			fs.Logf(remote, "bisync: conflict - can't tell which is newer so keeping both")
+			policy = ConflictPolicyRename
+		case dt > 0:
+			policy = ConflictPolicyPath1
+		default:
+			policy = ConflictPolicyPath2
+		}
+	}
+	switch policy {
+	case ConflictPolicyPath1:
+		fs.Logf(remote, "bisync: conflict - keeping path1 version")
+		a := b.copyAction(b.f1, b.f2, remote)
+		return &a, nil
+	case ConflictPolicyPath2:
+		fs.Logf(remote, "bisync: conflict - keeping path2 version")
+		a := b.copyAction(b.f2, b.f1, remote)
+		return &a, nil
+	case ConflictPolicyRename:
+		fs.Logf(remote, "bisync: conflict - renaming to %q and %q", remote+"..path1", remote+"..path2")
+		return &action{
+			what:   "rename conflict",
+			remote: remote,
+			fn: func(ctx context.Context) error {
+				if err := b.renameAndCopy(ctx, b.f1, b.f2, remote, remote+"..path1"); err != nil {
+					return err
+				}
+				return b.renameAndCopy(ctx, b.f2, b.f1, remote, remote+"..path2")
+			},
+		}, nil
+	}
+	return nil, errors.Errorf("unknown conflict policy %v", policy)
+}
+
+// copyAction makes an action to copy remote from fsrc to fdst
+func (b *bisync) copyAction(fsrc, fdst fs.Fs, remote string) action {
+	return action{
+		what:   "copy",
+		remote: remote,
+		fn: func(ctx context.Context) error {
+			return operations.CopyFile(ctx, fdst, fsrc, remote, remote)
+		},
+	}
+}
+
+// deleteAction makes an action to delete remote from f
+func (b *bisync) deleteAction(f fs.Fs, remote string) action {
+	return action{
+		what:   "delete",
+		remote: remote,
+		fn: func(ctx context.Context) error {
+			o, err := f.NewObject(ctx, remote)
+			if err == fs.ErrorObjectNotFound {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return operations.DeleteFile(ctx, o)
+		},
+	}
+}
+
+// renameAndCopy renames remote to newRemote on f then copies it to
+// other
+func (b *bisync) renameAndCopy(ctx context.Context, f, other fs.Fs, remote, newRemote string) error {
+	if err := operations.MoveFile(ctx, f, f, newRemote, remote); err != nil {
+		return err
+	}
+	return operations.CopyFile(ctx, other, f, newRemote, newRemote)
+}
+
+// apply runs the actions using --transfers workers
+func (b *bisync) apply(ctx context.Context, actions []action) {
+	in := make(chan action)
+	var wg sync.WaitGroup
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range in {
+				fs.Debugf(a.remote, "bisync: %s", a.what)
+				if err := a.fn(ctx); err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(a.remote, "bisync: %s failed: %v", a.what, err)
+					b.errorMu.Lock()
+					b.errorCount++
+					b.errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, a := range actions {
+		in <- a
+	}
+	close(in)
+	wg.Wait()
+}
@@ -0,0 +1,146 @@
+package bisync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/all" // import all backends
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Some times used in the tests
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+	t3 = fstest.Time("2011-12-30T12:59:59.000000000Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func newOpt(t *testing.T) (*Options, func()) {
+	dir, err := ioutil.TempDir("", "rclone-bisync-test")
+	require.NoError(t, err)
+	opt := DefaultOpt
+	opt.WorkDir = dir
+	return &opt, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestConflictPolicyString(t *testing.T) {
+	for _, name := range []string{"newer", "rename", "path1", "path2"} {
+		var p ConflictPolicy
+		require.NoError(t, p.Set(name))
+		assert.Equal(t, name, p.String())
+	}
+	var p ConflictPolicy
+	assert.Error(t, p.Set("potato"))
+}
+
+func TestBisyncNeedsResync(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+	r.Mkdir(context.Background(), r.Fremote)
+
+	err := Bisync(context.Background(), r.Flocal, r.Fremote, opt)
+	assert.Equal(t, ErrorNeedResync, err)
+}
+
+func TestBisync(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteFile("one", "one", t1)
+	file2 := r.WriteObject(ctx, "two", "two", t1)
+	file3 := r.WriteBoth(ctx, "three", "three", t1)
+
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	// new on path1, deleted on path2, modified on path2
+	opt.Resync = false
+	file4 := r.WriteFile("four", "four", t2)
+	obj, err := r.Fremote.NewObject(ctx, "one")
+	require.NoError(t, err)
+	require.NoError(t, operations.DeleteFile(ctx, obj))
+	file2 = r.WriteObject(ctx, "two", "two modified", t2)
+
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file2, file3, file4)
+	fstest.CheckItems(t, r.Fremote, file2, file3, file4)
+
+	// conflict - newer wins
+	r.WriteFile("three", "three local", t2)
+	file3 = r.WriteObject(ctx, "three", "three remote", t3)
+
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file2, file3, file4)
+	fstest.CheckItems(t, r.Fremote, file2, file3, file4)
+}
+
+func TestBisyncConflictRename(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteBoth(ctx, "file", "same", t1)
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	opt.Resync = false
+	opt.ConflictPolicy = ConflictPolicyRename
+	r.WriteFile("file", "changed on path1", t2)
+	r.WriteObject(ctx, "file", "changed on path2", t3)
+
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	path1 := fstest.NewItem("file..path1", "changed on path1", t2)
+	path2 := fstest.NewItem("file..path2", "changed on path2", t3)
+	fstest.CheckItems(t, r.Flocal, path1, path2)
+	fstest.CheckItems(t, r.Fremote, path1, path2)
+}
+
+func TestBisyncMaxDelete(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteBoth(ctx, "one", "one", t1)
+	file2 := r.WriteBoth(ctx, "two", "two", t1)
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+
+	opt.Resync = false
+	for _, item := range []fstest.Item{file1, file2} {
+		obj, err := r.Flocal.NewObject(ctx, item.Path)
+		require.NoError(t, err)
+		require.NoError(t, operations.DeleteFile(ctx, obj))
+	}
+	err := Bisync(ctx, r.Flocal, r.Fremote, opt)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max-delete")
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	opt.Force = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Fremote)
+}
@@ -0,0 +1,121 @@
+package bisync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// fileInfo is the state of a single file as recorded in a listing
+type fileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// listing is the state of one side of a bisync
+type listing map[string]fileInfo
+
+// listingFile is the on disk format of a listing
+type listingFile struct {
+	Version int     `json:"version"`
+	Fs      string  `json:"fs"`
+	Hash    string  `json:"hash,omitempty"`
+	Files   listing `json:"files"`
+}
+
+const listingVersion = 1
+
+// makeListing lists f recursively recording the state of every
+// object. If ht is not hash.None the hash of each object is recorded
+// too.
+func makeListing(ctx context.Context, f fs.Fs, ht hash.Type) (listing, error) {
+	ls := make(listing)
+	err := walk.ListR(ctx, f, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			o, ok := entry.(fs.Object)
+			if !ok {
+				continue
+			}
+			info := fileInfo{
+				Size:    o.Size(),
+				ModTime: o.ModTime(ctx),
+			}
+			if ht != hash.None {
+				sum, err := o.Hash(ctx, ht)
+				if err != nil {
+					fs.Debugf(o, "Failed to read hash: %v", err)
+				}
+				info.Hash = sum
+			}
+			ls[o.Remote()] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %v", f)
+	}
+	return ls, nil
+}
+
+// loadListing reads the listing from path
+//
+// It returns os.ErrNotExist (possibly wrapped) if there is no
+// listing.
+func loadListing(path string) (listing, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lf listingFile
+	err = json.Unmarshal(buf, &lf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse listing %q", path)
+	}
+	if lf.Version != listingVersion {
+		return nil, errors.Errorf("listing %q has unsupported version %d", path, lf.Version)
+	}
+	if lf.Files == nil {
+		lf.Files = make(listing)
+	}
+	return lf.Files, nil
+}
+
+// save writes the listing to path atomically so an interrupted run
+// always leaves either the old or the new listing behind.
+func (ls listing) save(path string, f fs.Fs, ht hash.Type) error {
+	lf := listingFile{
+		Version: listingVersion,
+		Fs:      fs.ConfigString(f),
+		Files:   ls,
+	}
+	if ht != hash.None {
+		lf.Hash = ht.String()
+	}
+	buf, err := json.MarshalIndent(&lf, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make bisync work directory")
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, buf, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write listing")
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return errors.Wrap(err, "failed to replace listing")
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package bisync
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs/rc"
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "sync/bisync",
+		AuthRequired: true,
+		Fn:           rcBisync,
+		Title:        "Bidirectional sync between two remotes",
+		Help: `This takes the following parameters
+
+- path1 - a remote name string eg "drive:path1"
+- path2 - a remote name string eg "drive:path2"
+- resync - copy both ways to make the initial listings (optional)
+- conflictPolicy - one of "newer", "rename", "path1", "path2" (optional)
+- maxDelete - abort if more than this percentage of files would be deleted (optional)
+- force - ignore maxDelete (optional)
+- checkSum - use hashes rather than modification times to detect changes (optional)
+- workDir - directory to keep the listings in (optional)
+
+See the [bisync command](/commands/rclone_bisync/) command for more information on the above.`,
+	})
+}
+
+// Bisync two remotes
+func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f1, err := rc.GetFsNamed(in, "path1")
+	if err != nil {
+		return nil, err
+	}
+	f2, err := rc.GetFsNamed(in, "path2")
+	if err != nil {
+		return nil, err
+	}
+	opt := DefaultOpt
+	if opt.Resync, err = in.GetBool("resync"); rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if opt.Force, err = in.GetBool("force"); rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if opt.CheckSum, err = in.GetBool("checkSum"); rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	maxDelete, err := in.GetInt64("maxDelete")
+	if err == nil {
+		opt.MaxDelete = int(maxDelete)
+	} else if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	policy, err := in.GetString("conflictPolicy")
+	if err == nil {
+		if err = opt.ConflictPolicy.Set(policy); err != nil {
+			return nil, err
+		}
+	} else if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if opt.WorkDir, err = in.GetString("workDir"); rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	return nil, Bisync(ctx, f1, f2, &opt)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 // io related errors returned by ChunkedReader
@@ -199,8 +200,9 @@ func (cr *ChunkedReader) openRange() error {
 		return ErrorFileClosed
 	}
 
+	ctx := sched.WithPace(cr.ctx, cr.o.Fs(), sched.OpRead)
 	if rs, ok := cr.rc.(fs.RangeSeeker); ok {
-		n, err := rs.RangeSeek(cr.ctx, offset, io.SeekStart, length)
+		n, err := rs.RangeSeek(ctx, offset, io.SeekStart, length)
 		if err == nil && n == offset {
 			cr.offset = offset
 			return nil
@@ -212,16 +214,18 @@ func (cr *ChunkedReader) openRange() error {
 		}
 	}
 
-	var rc io.ReadCloser
-	var err error
+	var (
+		rc  io.ReadCloser
+		err error
+	)
 	if length <= 0 {
 		if offset == 0 {
-			rc, err = cr.o.Open(cr.ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)})
+			rc, err = cr.o.Open(ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)})
 		} else {
-			rc, err = cr.o.Open(cr.ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)}, &fs.RangeOption{Start: offset, End: -1})
+			rc, err = cr.o.Open(ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)}, &fs.RangeOption{Start: offset, End: -1})
 		}
 	} else {
-		rc, err = cr.o.Open(cr.ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)}, &fs.RangeOption{Start: offset, End: offset + length - 1})
+		rc, err = cr.o.Open(ctx, &fs.HashesOption{Hashes: hash.Set(hash.None)}, &fs.RangeOption{Start: offset, End: offset + length - 1})
 	}
 	if err != nil {
 		return err
@@ -43,6 +43,8 @@ type ConfigInfo struct {
 	LogLevel               LogLevel
 	StatsLogLevel          LogLevel
 	UseJSONLog             bool
+	OutputFormat           OutputFormat
+	OutputFile             string
 	DryRun                 bool
 	Interactive            bool
 	CheckSum               bool
@@ -53,6 +55,8 @@ type ConfigInfo struct {
 	ModifyWindow           time.Duration
 	Checkers               int
 	Transfers              int
+	ListConcurrency        int           // Max directory listings in flight, 0 for Checkers per traversal
+	Priority               int           // Priority of transfers when sharing --transfers with other operations
 	ConnectTimeout         time.Duration // Connect timeout
 	Timeout                time.Duration // Data channel timeout
 	ExpectContinueTimeout  time.Duration
@@ -60,8 +64,12 @@ type ConfigInfo struct {
 	InsecureSkipVerify     bool // Skip server certificate verification
 	DeleteMode             DeleteMode
 	MaxDelete              int64
+	DeleteTPSLimit         float64
+	DeleteTPSLimitBurst    int
+	DeleteConcurrency      int
 	TrackRenames           bool   // Track file renames.
 	TrackRenamesStrategy   string // Comma separated list of stratgies used to track renames
+	TrackRenamesDB         bool   // Use the hash database to track renames if no common hash
 	LowLevelRetries        int
 	UpdateOlder            bool // Skip files that are newer on the destination
 	NoGzip                 bool // Disable compression
@@ -84,12 +92,22 @@ type ConfigInfo struct {
 	BufferSize             SizeSuffix
 	BwLimit                BwTimetable
 	BwLimitFile            BwTimetable
+	BwLimitInteractive     SizeSuffix
+	BwLimitWriteBack       SizeSuffix
 	TPSLimit               float64
 	TPSLimitBurst          int
+	TPSLimitPriority       string
+	AutoTune               bool
+	AutoTuneMaxTransfers   int
+	AutoTuneMaxCheckers    int
+	AutoTuneInterval       time.Duration
+	AutoTuneMemory         SizeSuffix
 	BindAddr               net.IP
 	DisableFeatures        []string
 	UserAgent              string
 	Immutable              bool
+	ImmutableReport        string
+	ImmutableQuarantine    string
 	AutoConfirm            bool
 	StreamingUploadCutoff  SizeSuffix
 	StatsFileNameLength    int
@@ -99,22 +117,43 @@ type ConfigInfo struct {
 	MaxTransfer            SizeSuffix
 	MaxDuration            time.Duration
 	CutoffMode             CutoffMode
+	CutoverJournal         string
+	Checkpoint             string
+	FailureReport          string
+	MoveJournal            string
+	SourceFallback         []string
+	UploadTier             []string
+	DestConflict           DestConflictMode
+	Verify                 bool   // check transferred files again after a sync
+	VerifyAll              bool   // check unchanged files too with --verify
+	VerifyManifest         string // file to write the manifest of verified files to
+	VerifyKey              string // key to sign the verify manifest with
+	Metadata               bool   // copy metadata with the files
 	MaxBacklog             int
 	MaxStatsGroups         int
 	StatsOneLine           bool
 	StatsOneLineDate       bool   // If we want a date prefix at all
 	StatsOneLineDateFormat string // If we want to customize the prefix
-	ErrorOnNoTransfer      bool   // Set appropriate exit code if no files transferred
+	StatsPush              string
+	StatsPushInterval      time.Duration
+	StatsPushPrefix        string
+	ErrorOnNoTransfer      bool // Set appropriate exit code if no files transferred
 	Progress               bool
 	Cookie                 bool
 	UseMmap                bool
+	MaxBufferMemory        SizeSuffix
 	CaCert                 string // Client Side CA
 	ClientCert             string // Client Side Cert
 	ClientKey              string // Client Side Key
 	MultiThreadCutoff      SizeSuffix
 	MultiThreadStreams     int
-	MultiThreadSet         bool   // whether MultiThreadStreams was set (set in fs/config/configflags)
-	OrderBy                string // instructions on how to order the transfer
+	MultiThreadSet         bool       // whether MultiThreadStreams was set (set in fs/config/configflags)
+	Sparse                 bool       // keep the holes of sparse files when copying to random access destinations
+	DeltaTransfer          bool       // update changed blocks in place where the backends support it
+	DeltaCutoff            SizeSuffix // files smaller than this are always transferred whole
+	DeltaBlockSize         SizeSuffix // size of the blocks compared for delta transfers
+	ResumeUploads          bool       // keep the state of multipart uploads so they can be resumed
+	OrderBy                string     // instructions on how to order the transfer
 	UploadHeaders          []*HTTPOption
 	DownloadHeaders        []*HTTPOption
 	Headers                []*HTTPOption
@@ -138,6 +177,7 @@ func NewConfig() *ConfigInfo {
 	c.ExpectContinueTimeout = 1 * time.Second
 	c.DeleteMode = DeleteModeDefault
 	c.MaxDelete = -1
+	c.DeleteTPSLimitBurst = 1
 	c.LowLevelRetries = 10
 	c.MaxDepth = -1
 	c.DataRateUnit = "bytes"
@@ -148,12 +188,21 @@ func NewConfig() *ConfigInfo {
 	c.StatsFileNameLength = 45
 	c.AskPassword = true
 	c.TPSLimitBurst = 1
+	c.StatsPushInterval = 10 * time.Second
+	c.AutoTuneMaxTransfers = 32
+	c.AutoTuneMaxCheckers = 64
+	c.AutoTuneInterval = 10 * time.Second
+	c.AutoTuneMemory = -1
+	c.MaxBufferMemory = -1
+	c.StatsPushPrefix = "rclone"
 	c.MaxTransfer = -1
 	c.MaxBacklog = 10000
 	// We do not want to set the default here. We use this variable being empty as part of the fall-through of options.
 	//	c.StatsOneLineDateFormat = "2006/01/02 15:04:05 - "
 	c.MultiThreadCutoff = SizeSuffix(250 * 1024 * 1024)
 	c.MultiThreadStreams = 4
+	c.DeltaCutoff = SizeSuffix(16 * 1024 * 1024)
+	c.DeltaBlockSize = SizeSuffix(128 * 1024)
 
 	c.TrackRenamesStrategy = "hash"
 
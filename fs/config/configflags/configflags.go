@@ -43,6 +43,8 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.DurationVarP(flagSet, &fs.Config.ModifyWindow, "modify-window", "", fs.Config.ModifyWindow, "Max time diff to be considered the same")
 	flags.IntVarP(flagSet, &fs.Config.Checkers, "checkers", "", fs.Config.Checkers, "Number of checkers to run in parallel.")
 	flags.IntVarP(flagSet, &fs.Config.Transfers, "transfers", "", fs.Config.Transfers, "Number of file transfers to run in parallel.")
+	flags.IntVarP(flagSet, &fs.Config.Priority, "priority", "", fs.Config.Priority, "Priority of the transfers when running with other operations, higher goes first.")
+	flags.IntVarP(flagSet, &fs.Config.ListConcurrency, "list-concurrency", "", fs.Config.ListConcurrency, "Max number of directory listings to run in parallel (default --checkers per traversal, no global limit).")
 	flags.StringVarP(flagSet, &config.ConfigPath, "config", "", config.ConfigPath, "Config file.")
 	flags.StringVarP(flagSet, &config.CacheDir, "cache-dir", "", config.CacheDir, "Directory rclone will use for caching.")
 	flags.BoolVarP(flagSet, &fs.Config.CheckSum, "checksum", "c", fs.Config.CheckSum, "Skip based on checksum (if available) & size, not mod-time & size")
@@ -64,8 +66,12 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &deleteDuring, "delete-during", "", false, "When synchronizing, delete files during transfer")
 	flags.BoolVarP(flagSet, &deleteAfter, "delete-after", "", false, "When synchronizing, delete files on destination after transferring (default)")
 	flags.Int64VarP(flagSet, &fs.Config.MaxDelete, "max-delete", "", -1, "When synchronizing, limit the number of deletes")
+	flags.Float64VarP(flagSet, &fs.Config.DeleteTPSLimit, "delete-tpslimit", "", fs.Config.DeleteTPSLimit, "Limit file deletions per second to this.")
+	flags.IntVarP(flagSet, &fs.Config.DeleteTPSLimitBurst, "delete-tpslimit-burst", "", fs.Config.DeleteTPSLimitBurst, "Max burst of deletions for --delete-tpslimit.")
+	flags.IntVarP(flagSet, &fs.Config.DeleteConcurrency, "delete-concurrency", "", fs.Config.DeleteConcurrency, "Number of file deletions to run in parallel (default --transfers).")
 	flags.BoolVarP(flagSet, &fs.Config.TrackRenames, "track-renames", "", fs.Config.TrackRenames, "When synchronizing, track file renames and do a server side move if possible")
 	flags.StringVarP(flagSet, &fs.Config.TrackRenamesStrategy, "track-renames-strategy", "", fs.Config.TrackRenamesStrategy, "Strategies to use when synchronizing using track-renames hash|modtime|leaf")
+	flags.BoolVarP(flagSet, &fs.Config.TrackRenamesDB, "track-renames-db", "", fs.Config.TrackRenamesDB, "Remember source hashes of destination files so --track-renames works without a common hash")
 	flags.IntVarP(flagSet, &fs.Config.LowLevelRetries, "low-level-retries", "", fs.Config.LowLevelRetries, "Number of low level retries to do.")
 	flags.BoolVarP(flagSet, &fs.Config.UpdateOlder, "update", "u", fs.Config.UpdateOlder, "Skip files that are newer on the destination.")
 	flags.BoolVarP(flagSet, &fs.Config.UseServerModTime, "use-server-modtime", "", fs.Config.UseServerModTime, "Use server modified time instead of object metadata")
@@ -87,37 +93,70 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &fs.Config.UseListR, "fast-list", "", fs.Config.UseListR, "Use recursive list if available. Uses more memory but fewer transactions.")
 	flags.Float64VarP(flagSet, &fs.Config.TPSLimit, "tpslimit", "", fs.Config.TPSLimit, "Limit HTTP transactions per second to this.")
 	flags.IntVarP(flagSet, &fs.Config.TPSLimitBurst, "tpslimit-burst", "", fs.Config.TPSLimitBurst, "Max burst of transactions for --tpslimit.")
+	flags.BoolVarP(flagSet, &fs.Config.AutoTune, "auto-tune", "", fs.Config.AutoTune, "Adjust the transfers and checkers of each remote as the sync runs.")
+	flags.IntVarP(flagSet, &fs.Config.AutoTuneMaxTransfers, "auto-tune-max-transfers", "", fs.Config.AutoTuneMaxTransfers, "Most transfers --auto-tune will run on a remote.")
+	flags.IntVarP(flagSet, &fs.Config.AutoTuneMaxCheckers, "auto-tune-max-checkers", "", fs.Config.AutoTuneMaxCheckers, "Most checkers --auto-tune will run on a remote.")
+	flags.DurationVarP(flagSet, &fs.Config.AutoTuneInterval, "auto-tune-interval", "", fs.Config.AutoTuneInterval, "Interval between adjustments by --auto-tune.")
+	flags.FVarP(flagSet, &fs.Config.AutoTuneMemory, "auto-tune-memory", "", "Reduce the transfers and checkers with --auto-tune when using more memory than this.")
+	flags.StringVarP(flagSet, &fs.Config.TPSLimitPriority, "tpslimit-priority", "", fs.Config.TPSLimitPriority, "Priorities of the operations on remotes with a tpslimit, eg read=1,list=-1")
 	flags.StringVarP(flagSet, &bindAddr, "bind", "", "", "Local address to bind to for outgoing connections, IPv4, IPv6 or name.")
 	flags.StringVarP(flagSet, &disableFeatures, "disable", "", "", "Disable a comma separated list of features.  Use help to see a list.")
 	flags.StringVarP(flagSet, &fs.Config.UserAgent, "user-agent", "", fs.Config.UserAgent, "Set the user-agent to a specified string. The default is rclone/ version")
 	flags.BoolVarP(flagSet, &fs.Config.Immutable, "immutable", "", fs.Config.Immutable, "Do not modify files. Fail if existing files have been modified.")
+	flags.StringVarP(flagSet, &fs.Config.ImmutableReport, "immutable-report", "", fs.Config.ImmutableReport, "Append the details of modified immutable files to this file.")
+	flags.StringVarP(flagSet, &fs.Config.ImmutableQuarantine, "immutable-quarantine", "", fs.Config.ImmutableQuarantine, "Copy modified versions of immutable files into this remote:path.")
 	flags.BoolVarP(flagSet, &fs.Config.AutoConfirm, "auto-confirm", "", fs.Config.AutoConfirm, "If enabled, do not request console confirmation.")
 	flags.IntVarP(flagSet, &fs.Config.StatsFileNameLength, "stats-file-name-length", "", fs.Config.StatsFileNameLength, "Max file name length in stats. 0 for no limit")
 	flags.FVarP(flagSet, &fs.Config.LogLevel, "log-level", "", "Log level DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &fs.Config.StatsLogLevel, "stats-log-level", "", "Log level to show --stats output DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &fs.Config.BwLimit, "bwlimit", "", "Bandwidth limit in kBytes/s, or use suffix b|k|M|G or a full timetable.")
 	flags.FVarP(flagSet, &fs.Config.BwLimitFile, "bwlimit-file", "", "Bandwidth limit per file in kBytes/s, or use suffix b|k|M|G or a full timetable.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitInteractive, "bwlimit-interactive", "", "Bandwidth limit for mount and serve traffic, 0 to share --bwlimit with priority or off.")
+	flags.FVarP(flagSet, &fs.Config.BwLimitWriteBack, "bwlimit-write-back", "", "Bandwidth limit for VFS cache uploads, 0 to share --bwlimit with priority or off.")
 	flags.FVarP(flagSet, &fs.Config.BufferSize, "buffer-size", "", "In memory buffer size when reading files for each --transfer.")
 	flags.FVarP(flagSet, &fs.Config.StreamingUploadCutoff, "streaming-upload-cutoff", "", "Cutoff for switching to chunked upload if file size is unknown. Upload starts after reaching cutoff or when file ends.")
 	flags.FVarP(flagSet, &fs.Config.Dump, "dump", "", "List of items to dump from: "+fs.DumpFlagsList)
 	flags.FVarP(flagSet, &fs.Config.MaxTransfer, "max-transfer", "", "Maximum size of data to transfer.")
 	flags.DurationVarP(flagSet, &fs.Config.MaxDuration, "max-duration", "", 0, "Maximum duration rclone will transfer data for.")
 	flags.FVarP(flagSet, &fs.Config.CutoffMode, "cutoff-mode", "", "Mode to stop transfers when reaching the max transfer limit HARD|SOFT|CAUTIOUS")
+	flags.StringVarP(flagSet, &fs.Config.MoveJournal, "move-journal", "", fs.Config.MoveJournal, "Move by copying, verifying then deleting each file, recording progress in this journal.")
+	flags.StringArrayVarP(flagSet, &fs.Config.SourceFallback, "source-fallback", "", nil, "Read files from this copy of the source if reading from the source fails (can be repeated).")
+	flags.StringVarP(flagSet, &fs.Config.CutoverJournal, "cutover-journal", "", fs.Config.CutoverJournal, "Save the files not transferred at the --max-transfer or --max-duration cutoff here for the next run.")
+	flags.StringVarP(flagSet, &fs.Config.FailureReport, "failure-report", "", fs.Config.FailureReport, "Write a JSON report of the files which failed to copy, move or delete here, for use with rclone retry.")
+	flags.StringVarP(flagSet, &fs.Config.Checkpoint, "checkpoint", "", fs.Config.Checkpoint, "Record completed directories here so an interrupted sync can skip them when run again.")
+	flags.FVarP(flagSet, &fs.Config.DestConflict, "dest-conflict", "", "What to do with destination files changed since the last sync off|error|rename")
+	flags.BoolVarP(flagSet, &fs.Config.Verify, "verify", "", fs.Config.Verify, "Check transferred files against the source again at the end of a sync or copy")
+	flags.BoolVarP(flagSet, &fs.Config.VerifyAll, "verify-all", "", fs.Config.VerifyAll, "Check unchanged files too with --verify")
+	flags.StringVarP(flagSet, &fs.Config.VerifyManifest, "verify-manifest", "", fs.Config.VerifyManifest, "Write a manifest of the files checked by --verify to this file")
+	flags.StringVarP(flagSet, &fs.Config.VerifyKey, "verify-key", "", fs.Config.VerifyKey, "Sign the --verify-manifest with HMAC-SHA256 using this key")
+	flags.BoolVarP(flagSet, &fs.Config.Metadata, "metadata", "", fs.Config.Metadata, "Copy metadata such as permissions, owner and xattrs with the files")
+	flags.StringArrayVarP(flagSet, &fs.Config.UploadTier, "upload-tier", "", nil, "Store files matching a glob in a storage tier, as glob=tier, first match wins (can be repeated).")
 	flags.IntVarP(flagSet, &fs.Config.MaxBacklog, "max-backlog", "", fs.Config.MaxBacklog, "Maximum number of objects in sync or check backlog.")
 	flags.IntVarP(flagSet, &fs.Config.MaxStatsGroups, "max-stats-groups", "", fs.Config.MaxStatsGroups, "Maximum number of stats groups to keep in memory. On max oldest is discarded.")
 	flags.BoolVarP(flagSet, &fs.Config.StatsOneLine, "stats-one-line", "", fs.Config.StatsOneLine, "Make the stats fit on one line.")
+	flags.StringVarP(flagSet, &fs.Config.StatsPush, "stats-push", "", fs.Config.StatsPush, "Push the stats to this InfluxDB write URL or graphite:// or statsd:// address.")
+	flags.DurationVarP(flagSet, &fs.Config.StatsPushInterval, "stats-push-interval", "", fs.Config.StatsPushInterval, "Interval between pushes of the stats with --stats-push.")
+	flags.StringVarP(flagSet, &fs.Config.StatsPushPrefix, "stats-push-prefix", "", fs.Config.StatsPushPrefix, "Prefix of the names of the stats pushed with --stats-push.")
 	flags.BoolVarP(flagSet, &fs.Config.StatsOneLineDate, "stats-one-line-date", "", fs.Config.StatsOneLineDate, "Enables --stats-one-line and add current date/time prefix.")
 	flags.StringVarP(flagSet, &fs.Config.StatsOneLineDateFormat, "stats-one-line-date-format", "", fs.Config.StatsOneLineDateFormat, "Enables --stats-one-line-date and uses custom formatted date. Enclose date string in double quotes (\"). See https://golang.org/pkg/time/#Time.Format")
 	flags.BoolVarP(flagSet, &fs.Config.ErrorOnNoTransfer, "error-on-no-transfer", "", fs.Config.ErrorOnNoTransfer, "Sets exit code 9 if no files are transferred, useful in scripts")
 	flags.BoolVarP(flagSet, &fs.Config.Progress, "progress", "P", fs.Config.Progress, "Show progress during transfer.")
 	flags.BoolVarP(flagSet, &fs.Config.Cookie, "use-cookies", "", fs.Config.Cookie, "Enable session cookiejar.")
 	flags.BoolVarP(flagSet, &fs.Config.UseMmap, "use-mmap", "", fs.Config.UseMmap, "Use mmap allocator (see docs).")
+	flags.FVarP(flagSet, &fs.Config.MaxBufferMemory, "max-buffer-memory", "", "Most memory to use for transfer buffers, waiting for buffers to be freed if more is needed.")
 	flags.StringVarP(flagSet, &fs.Config.CaCert, "ca-cert", "", fs.Config.CaCert, "CA certificate used to verify servers")
 	flags.StringVarP(flagSet, &fs.Config.ClientCert, "client-cert", "", fs.Config.ClientCert, "Client SSL certificate (PEM) for mutual TLS auth")
 	flags.StringVarP(flagSet, &fs.Config.ClientKey, "client-key", "", fs.Config.ClientKey, "Client SSL private key (PEM) for mutual TLS auth")
 	flags.FVarP(flagSet, &fs.Config.MultiThreadCutoff, "multi-thread-cutoff", "", "Use multi-thread downloads for files above this size.")
 	flags.IntVarP(flagSet, &fs.Config.MultiThreadStreams, "multi-thread-streams", "", fs.Config.MultiThreadStreams, "Max number of streams to use for multi-thread downloads.")
+	flags.BoolVarP(flagSet, &fs.Config.Sparse, "sparse", "", fs.Config.Sparse, "Keep the holes of sparse source files when copying to destinations with random access writes.")
+	flags.BoolVarP(flagSet, &fs.Config.DeltaTransfer, "delta", "", fs.Config.DeltaTransfer, "Only transfer the changed blocks of existing files where the backends support it.")
+	flags.FVarP(flagSet, &fs.Config.DeltaCutoff, "delta-cutoff", "", "Use delta transfers for files above this size.")
+	flags.FVarP(flagSet, &fs.Config.DeltaBlockSize, "delta-block-size", "", "Block size to compare files in for delta transfers.")
+	flags.BoolVarP(flagSet, &fs.Config.ResumeUploads, "resume-uploads", "", fs.Config.ResumeUploads, "Resume interrupted multipart uploads where the backends support it.")
 	flags.BoolVarP(flagSet, &fs.Config.UseJSONLog, "use-json-log", "", fs.Config.UseJSONLog, "Use json log format.")
+	flags.FVarP(flagSet, &fs.Config.OutputFormat, "output-format", "", "Format for progress and results text|jsonl")
+	flags.StringVarP(flagSet, &fs.Config.OutputFile, "output-file", "", fs.Config.OutputFile, "Append the --output-format jsonl events to this file instead of stderr.")
 	flags.StringVarP(flagSet, &fs.Config.OrderBy, "order-by", "", fs.Config.OrderBy, "Instructions on how to order the transfers, eg 'size,descending'")
 	flags.StringArrayVarP(flagSet, &uploadHeaders, "header-upload", "", nil, "Set HTTP header for upload transactions")
 	flags.StringArrayVarP(flagSet, &downloadHeaders, "header-download", "", nil, "Set HTTP header for download transactions")
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DestConflictMode describes what a sync does with destination files
+// which have changed since the last sync
+type DestConflictMode byte
+
+// DestConflictMode constants
+const (
+	DestConflictOff DestConflictMode = iota
+	DestConflictError
+	DestConflictRename
+	DestConflictDefault = DestConflictOff
+)
+
+var destConflictModeToString = []string{
+	DestConflictOff:    "off",
+	DestConflictError:  "error",
+	DestConflictRename: "rename",
+}
+
+// String turns a DestConflictMode into a string
+func (m DestConflictMode) String() string {
+	if m >= DestConflictMode(len(destConflictModeToString)) {
+		return fmt.Sprintf("DestConflictMode(%d)", m)
+	}
+	return destConflictModeToString[m]
+}
+
+// Set a DestConflictMode
+func (m *DestConflictMode) Set(s string) error {
+	for n, name := range destConflictModeToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = DestConflictMode(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown dest conflict mode %q", s)
+}
+
+// Type of the value
+func (m *DestConflictMode) Type() string {
+	return "string"
+}
@@ -0,0 +1,20 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// Check it satisfies the interface
+var _ pflag.Value = (*DestConflictMode)(nil)
+
+func TestDestConflictModeSet(t *testing.T) {
+	var m DestConflictMode
+	assert.NoError(t, m.Set("RENAME"))
+	assert.Equal(t, DestConflictRename, m)
+	assert.Equal(t, "rename", m.String())
+	assert.Error(t, m.Set("potato"))
+	assert.Equal(t, "DestConflictMode(7)", DestConflictMode(7).String())
+}
@@ -0,0 +1,275 @@
+// Package filesfrom copies files named in a continuous stream of
+// paths, like --files-from but without waiting for the end of the
+// list.
+//
+// Paths are gathered into batches as they arrive and each batch is
+// copied with --transfers files at once. A line of JSON with the
+// result for each file is written as it finishes.
+package filesfrom
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Results for each file
+const (
+	ResultCopied    = "copied"    // the file was copied
+	ResultUnchanged = "unchanged" // the file was already in the destination
+	ResultMissing   = "missing"   // the file wasn't found in the source
+	ResultError     = "error"     // the file failed to copy
+)
+
+// Options control the stream
+type Options struct {
+	BatchSize int           // most files to copy in a batch
+	BatchWait time.Duration // longest to wait for a batch to fill up
+}
+
+// DefaultOpt is the default values for Options
+var DefaultOpt = Options{
+	BatchSize: 100,
+	BatchWait: time.Second,
+}
+
+// Result is written as a line of JSON for each file
+type Result struct {
+	Time   time.Time `json:"time"`
+	Path   string    `json:"path"`
+	Result string    `json:"result"`
+	Size   int64     `json:"size,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// stream is the state of a running stream
+type stream struct {
+	fdst  fs.Fs
+	fsrc  fs.Fs
+	opt   Options
+	outMu sync.Mutex
+	out   io.Writer
+}
+
+// readPaths reads paths from in, one per line, and sends them to
+// paths. Blank lines and comments starting with # or ; are ignored
+// like --files-from.
+func readPaths(ctx context.Context, in io.Reader, paths chan<- string) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		line = strings.TrimLeft(path.Clean("/"+line), "/")
+		select {
+		case paths <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// readFile reads paths from the file called name, or stdin if it is
+// "-". A named pipe is opened again each time the writer closes it
+// so it is read until ctx is cancelled.
+func readFile(ctx context.Context, name string, paths chan<- string) error {
+	if name == "-" {
+		return readPaths(ctx, os.Stdin, paths)
+	}
+	for {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = readPaths(ctx, in, paths)
+		_ = in.Close()
+		if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fs.Debugf(nil, "Reopening named pipe %q", name)
+	}
+}
+
+// write outputs result as a line of JSON
+func (s *stream) write(result Result) {
+	result.Time = time.Now()
+	data, err := json.Marshal(result)
+	if err != nil {
+		fs.Errorf(nil, "Failed to encode result: %v", err)
+		return
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	_, _ = s.out.Write(append(data, '\n'))
+}
+
+// copyFile copies remote from the source to the destination if it is
+// missing or different. If it fails the error is returned as well as
+// being recorded in the result so it can be counted once.
+func (s *stream) copyFile(ctx context.Context, remote string) (result Result, err error) {
+	result = Result{Path: remote}
+	src, err := s.fsrc.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		result.Result = ResultMissing
+		fs.Errorf(remote, "Not found in source")
+		return result, nil
+	} else if err != nil {
+		result.Result = ResultError
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Size = src.Size()
+	dst, err := s.fdst.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		result.Result = ResultError
+		result.Error = err.Error()
+		return result, err
+	}
+	if dst != nil && operations.Equal(ctx, src, dst) {
+		fs.Debugf(src, "Unchanged skipping")
+		result.Result = ResultUnchanged
+		return result, nil
+	}
+	_, err = operations.Copy(ctx, s.fdst, dst, remote, src)
+	if err != nil {
+		result.Result = ResultError
+		result.Error = err.Error()
+		return result, err
+	}
+	result.Result = ResultCopied
+	return result, nil
+}
+
+// copyBatch copies the files in batch with --transfers at once
+// returning the number of files which failed
+func (s *stream) copyBatch(ctx context.Context, batch []string) (errorCount int) {
+	fs.Debugf(s.fsrc, "Copying batch of %d files", len(batch))
+	var (
+		in = make(chan string, len(batch))
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, remote := range batch {
+		in <- remote
+	}
+	close(in)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for remote := range in {
+				result, err := s.copyFile(ctx, remote)
+				if result.Result == ResultError || result.Result == ResultMissing {
+					if err != nil {
+						// operations.Copy has counted its errors already
+						err = fs.CountError(err)
+						fs.Errorf(remote, "Failed to copy: %v", err)
+					}
+					mu.Lock()
+					errorCount++
+					mu.Unlock()
+				}
+				s.write(result)
+			}
+		}()
+	}
+	wg.Wait()
+	return errorCount
+}
+
+// Stream copies the files named in the file called name (or stdin if
+// it is "-") from fsrc to fdst as their paths arrive, writing a line
+// of JSON with the result of each one to out.
+//
+// It returns when the input ends, or carries on until ctx is
+// cancelled if the input is a named pipe.
+func Stream(ctx context.Context, fdst, fsrc fs.Fs, name string, out io.Writer, opt *Options) error {
+	s := &stream{
+		fdst: fdst,
+		fsrc: fsrc,
+		opt:  *opt,
+		out:  out,
+	}
+	if s.opt.BatchSize <= 0 {
+		s.opt.BatchSize = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	paths := make(chan string, s.opt.BatchSize)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- readFile(ctx, name, paths)
+		close(paths)
+	}()
+
+	var (
+		batch      []string
+		seen       = map[string]struct{}{}
+		errorCount int
+		timer      = time.NewTimer(time.Hour)
+	)
+	timer.Stop()
+	flush := func() {
+		if len(batch) > 0 {
+			errorCount += s.copyBatch(ctx, batch)
+		}
+		batch = nil
+		seen = map[string]struct{}{}
+	}
+	for {
+		select {
+		case remote, ok := <-paths:
+			if !ok {
+				timer.Stop()
+				flush()
+				err := <-readErr
+				if err != nil && err != context.Canceled {
+					return errors.Wrap(err, "failed to read paths")
+				}
+				if errorCount > 0 {
+					return errors.Errorf("failed to copy %d files", errorCount)
+				}
+				return nil
+			}
+			// Copy each path once per batch
+			if _, found := seen[remote]; found {
+				continue
+			}
+			seen[remote] = struct{}{}
+			batch = append(batch, remote)
+			if len(batch) == 1 {
+				timer.Reset(s.opt.BatchWait)
+			}
+			if len(batch) >= s.opt.BatchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package filesfrom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/rclone/rclone/backend/local"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestReadPaths(t *testing.T) {
+	paths := make(chan string, 10)
+	err := readPaths(context.Background(), strings.NewReader("a.txt\n\n# comment\n; comment\n  /dir/b.txt  \ndir/../c.txt\n"), paths)
+	require.NoError(t, err)
+	close(paths)
+	var got []string
+	for p := range paths {
+		got = append(got, p)
+	}
+	assert.Equal(t, []string{"a.txt", "dir/b.txt", "c.txt"}, got)
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer accounting.GlobalStats().ResetCounters()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteFile("one.txt", "one", t1)
+	file2 := r.WriteFile("dir/two.txt", "two", t1)
+	r.WriteFile("not-listed.txt", "not listed", t1)
+	file3 := r.WriteBoth(ctx, "three.txt", "three", t1)
+
+	dir, err := ioutil.TempDir("", "rclone-filesfrom")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	listPath := filepath.Join(dir, "list")
+	list := "one.txt\ndir/two.txt\nthree.txt\none.txt\nmissing.txt\n"
+	require.NoError(t, ioutil.WriteFile(listPath, []byte(list), 0600))
+
+	var out bytes.Buffer
+	err = Stream(ctx, r.Fremote, r.Flocal, listPath, &out, &Options{BatchSize: 2, BatchWait: DefaultOpt.BatchWait})
+	require.Error(t, err, "missing file")
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	results := map[string][]string{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var result Result
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results[result.Path] = append(results[result.Path], result.Result)
+	}
+	assert.Equal(t, map[string][]string{
+		"one.txt":     {ResultCopied, ResultUnchanged}, // in a later batch the second time
+		"dir/two.txt": {ResultCopied},
+		"three.txt":   {ResultUnchanged},
+		"missing.txt": {ResultMissing},
+	}, results)
+}
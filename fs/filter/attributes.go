@@ -0,0 +1,128 @@
+// Filtering on the attributes stored with the objects
+
+package filter
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// attributeRules are the rules for one attribute of an object
+type attributeRules struct {
+	include []string // the attribute must match one of these if set
+	exclude []string // the attribute mustn't match any of these
+}
+
+// newAttributeRules checks and lower cases the patterns passed in
+func newAttributeRules(name string, include, exclude []string) (rules attributeRules, err error) {
+	check := func(patterns []string) ([]string, error) {
+		var out []string
+		for _, pattern := range patterns {
+			pattern = strings.ToLower(pattern)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, "bad %s pattern %q", name, pattern)
+			}
+			out = append(out, pattern)
+		}
+		return out, nil
+	}
+	rules.include, err = check(include)
+	if err != nil {
+		return rules, err
+	}
+	rules.exclude, err = check(exclude)
+	return rules, err
+}
+
+// active returns true if there are any rules
+func (rules *attributeRules) active() bool {
+	return len(rules.include) > 0 || len(rules.exclude) > 0
+}
+
+// matchAny returns true if value matches any of patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// includeValue returns whether value passes the rules. An empty value
+// means the attribute isn't known so only passes if there are no
+// include rules.
+func (rules *attributeRules) includeValue(value string) bool {
+	if value == "" {
+		return len(rules.include) == 0
+	}
+	value = strings.ToLower(value)
+	if len(rules.include) > 0 && !matchAny(rules.include, value) {
+		return false
+	}
+	return !matchAny(rules.exclude, value)
+}
+
+// objectTier returns the storage tier or class of o or "" if not known
+func objectTier(o fs.Object) string {
+	if do, ok := o.(fs.GetTierer); ok {
+		return do.GetTier()
+	}
+	return ""
+}
+
+// objectOwner returns the owner of o or "" if not known. This is the
+// owner the backend reports if it supports the Ownerer interface,
+// otherwise the user ID in its metadata.
+func objectOwner(ctx context.Context, o fs.Object) string {
+	if do, ok := o.(fs.Ownerer); ok {
+		return do.Owner(ctx)
+	}
+	metadata, err := fs.GetMetadata(ctx, o)
+	if err != nil {
+		fs.Debugf(o, "Failed to read metadata for owner filter: %v", err)
+		return ""
+	}
+	return metadata[fs.MetadataUID]
+}
+
+// UsesAttributes returns true if the filter has rules on the MIME
+// type, storage tier or owner of objects. Backends which need extra
+// work to find these can use this to only do it when needed.
+func (f *Filter) UsesAttributes() bool {
+	return f.mimeTypeRules.active() || f.tierRules.active() || f.ownerRules.active()
+}
+
+// UsesOwner returns true if the filter has rules on the owner of
+// objects
+func (f *Filter) UsesOwner() bool {
+	return f.ownerRules.active()
+}
+
+// includeAttributes returns whether the attributes of o pass the
+// rules and if not why. Each attribute is only read if there are rules
+// for it as reading it may need a call to the backend.
+func (f *Filter) includeAttributes(ctx context.Context, o fs.Object) (bool, Reason) {
+	if f.mimeTypeRules.active() {
+		// Ignore any parameters, eg "text/plain; charset=utf-8"
+		mimeType := strings.TrimSpace(strings.SplitN(fs.MimeType(ctx, o), ";", 2)[0])
+		if !f.mimeTypeRules.includeValue(mimeType) {
+			return false, Reason{Why: reasonMimeType, Detail: mimeType}
+		}
+	}
+	if f.tierRules.active() {
+		if tier := objectTier(o); !f.tierRules.includeValue(tier) {
+			return false, Reason{Why: reasonTier, Detail: tier}
+		}
+	}
+	if f.ownerRules.active() {
+		if owner := objectOwner(ctx, o); !f.ownerRules.includeValue(owner) {
+			return false, Reason{Why: reasonOwner, Detail: owner}
+		}
+	}
+	return true, Reason{}
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attrObject is a mock Object with a MIME type, tier and owner
+type attrObject struct {
+	mockobject.Object
+	mimeType string
+	tier     string
+	owner    string
+}
+
+func (o attrObject) MimeType(ctx context.Context) string { return o.mimeType }
+func (o attrObject) GetTier() string                     { return o.tier }
+func (o attrObject) Owner(ctx context.Context) string    { return o.owner }
+
+// metadataObject is a mock Object with metadata
+type metadataObject struct {
+	mockobject.Object
+	metadata fs.Metadata
+}
+
+func (o metadataObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return o.metadata, nil
+}
+
+func TestNewFilterAttributes(t *testing.T) {
+	ctx := context.Background()
+	opt := DefaultOpt
+	opt.IncludeMime = []string{"image/*", "application/pdf"}
+	opt.ExcludeMime = []string{"image/gif"}
+	opt.ExcludeTier = []string{"glacier", "deep_*"}
+	opt.IncludeOwner = []string{"*@example.com", "1000"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	assert.True(t, f.UsesAttributes())
+	assert.True(t, f.UsesOwner())
+
+	obj := func(mimeType, tier, owner string) fs.Object {
+		return attrObject{
+			Object:   mockobject.New("file"),
+			mimeType: mimeType,
+			tier:     tier,
+			owner:    owner,
+		}
+	}
+	for _, test := range []struct {
+		o    fs.Object
+		want bool
+	}{
+		{obj("image/jpeg", "STANDARD", "bob@example.com"), true},
+		{obj("application/pdf", "", "Bob@Example.com"), true},
+		{obj("text/plain; charset=utf-8", "STANDARD", "bob@example.com"), false},
+		{obj("image/gif", "STANDARD", "bob@example.com"), false},
+		{obj("image/png", "GLACIER", "bob@example.com"), false},
+		{obj("image/png", "DEEP_ARCHIVE", "bob@example.com"), false},
+		{obj("image/png", "STANDARD", "bob@example.org"), false},
+		{obj("image/png", "STANDARD", ""), false},
+		// MIME type from the extension and owner from the metadata
+		{metadataObject{Object: mockobject.New("file.jpg"), metadata: fs.Metadata{fs.MetadataUID: "1000"}}, true},
+		{metadataObject{Object: mockobject.New("file.jpg"), metadata: fs.Metadata{fs.MetadataUID: "0"}}, false},
+		{mockobject.New("file.jpg"), false},
+	} {
+		assert.Equal(t, test.want, f.IncludeObject(ctx, test.o), "%+v", test.o)
+	}
+
+	// Only the attributes with rules are read
+	opt = DefaultOpt
+	opt.ExcludeTier = []string{"glacier"}
+	f, err = NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.UsesOwner())
+	assert.True(t, f.IncludeObject(ctx, obj("", "", "")))
+	assert.False(t, f.IncludeObject(ctx, obj("", "GLACIER", "")))
+
+	opt = DefaultOpt
+	opt.IncludeTier = []string{"[a-"}
+	_, err = NewFilter(&opt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+// Explaining why files are included or excluded
+
+package filter
+
+import (
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Reasons a file or directory is included or excluded
+const (
+	reasonNoRule          = "no rule matched"
+	reasonRule            = "matched rule"
+	reasonExcludeFile     = "directory contains an --exclude-if-present file"
+	reasonNoIncludeFile   = "no --include-only-if-present file in directory or above"
+	reasonFilesFrom       = "in --files-from"
+	reasonNotFilesFrom    = "not in --files-from"
+	reasonFilesFromDir    = "has files in --files-from"
+	reasonNotFilesFromDir = "has no files in --files-from"
+	reasonTooOld          = "older than --max-age"
+	reasonTooNew          = "newer than --min-age"
+	reasonTooSmall        = "smaller than --min-size"
+	reasonTooBig          = "larger than --max-size"
+	reasonExpr            = "filter expression false"
+	reasonMimeType        = "MIME type filtered"
+	reasonTier            = "storage tier filtered"
+	reasonOwner           = "owner filtered"
+	reasonHashNotListed   = "hash not in --files-from-hashes"
+	reasonHashListed      = "hash in --exclude-from-hashes"
+	reasonIgnoreFile      = "matched ignore file rule"
+)
+
+// Reason is why a file or directory was included or excluded by the
+// filter
+type Reason struct {
+	Why    string // what decided it
+	Detail string // the rule, expression or value responsible if any
+}
+
+// String returns the reason in human readable form
+func (r Reason) String() string {
+	if r.Detail == "" {
+		return r.Why
+	}
+	return fmt.Sprintf("%s %q", r.Why, r.Detail)
+}
+
+// ruleReason returns the reason for rule matching
+func ruleReason(rule *rule) Reason {
+	return Reason{Why: reasonRule, Detail: rule.Source}
+}
+
+// ExplainListing returns whether the entries in the listing of dir in
+// fremote should be considered at all because of
+// --exclude-if-present and --include-only-if-present and if not why.
+//
+// includeDirs is false if none of the entries should be included and
+// includeObjects is false if only the directories should be.
+func (f *Filter) ExplainListing(fremote fs.Fs, dir string, entries fs.DirEntries) (includeDirs, includeObjects bool, reason Reason) {
+	if f.ListContainsExcludeFile(entries) {
+		return false, false, Reason{Why: reasonExcludeFile}
+	}
+	if !f.IncludeFileDir(fremote, dir, entries) {
+		return true, false, Reason{Why: reasonNoIncludeFile}
+	}
+	return true, true, Reason{}
+}
@@ -0,0 +1,410 @@
+// Filter expressions combining conditions on files
+
+package filter
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// exprItem is the file a filter expression is evaluated on
+type exprItem struct {
+	ctx     context.Context
+	remote  string
+	size    int64
+	modTime time.Time
+	o       fs.Object // nil if only the above are known
+}
+
+// exprNode is a node of a parsed filter expression
+type exprNode interface {
+	eval(item *exprItem) bool
+}
+
+// andNode is true if both sides are
+type andNode struct {
+	left, right exprNode
+}
+
+func (n *andNode) eval(item *exprItem) bool {
+	return n.left.eval(item) && n.right.eval(item)
+}
+
+// orNode is true if either side is
+type orNode struct {
+	left, right exprNode
+}
+
+func (n *orNode) eval(item *exprItem) bool {
+	return n.left.eval(item) || n.right.eval(item)
+}
+
+// notNode inverts the node under it
+type notNode struct {
+	node exprNode
+}
+
+func (n *notNode) eval(item *exprItem) bool {
+	return !n.node.eval(item)
+}
+
+// numberNode compares a numeric field with a value
+type numberNode struct {
+	get   func(item *exprItem) int64
+	op    string
+	value int64
+}
+
+func (n *numberNode) eval(item *exprItem) bool {
+	x := n.get(item)
+	switch n.op {
+	case "==":
+		return x == n.value
+	case "!=":
+		return x != n.value
+	case "<":
+		return x < n.value
+	case "<=":
+		return x <= n.value
+	case ">":
+		return x > n.value
+	case ">=":
+		return x >= n.value
+	}
+	panic("bad operator " + n.op)
+}
+
+// stringNode compares a string field with a value or matches it
+// against a regular expression
+type stringNode struct {
+	get        func(item *exprItem) string
+	op         string
+	value      string
+	re         *regexp.Regexp
+	ignoreCase bool
+}
+
+func (n *stringNode) eval(item *exprItem) bool {
+	x := n.get(item)
+	switch n.op {
+	case "==", "!=":
+		equal := x == n.value
+		if n.ignoreCase {
+			equal = strings.EqualFold(x, n.value)
+		}
+		return equal == (n.op == "==")
+	case "=~":
+		return n.re.MatchString(x)
+	case "!~":
+		return !n.re.MatchString(x)
+	}
+	panic("bad operator " + n.op)
+}
+
+// numberFields are the fields which can be compared as numbers and
+// how to parse their values
+var numberFields = map[string]struct {
+	get   func(item *exprItem) int64
+	parse func(value string) (int64, error)
+}{
+	"size": {
+		get: func(item *exprItem) int64 {
+			return item.size
+		},
+		parse: func(value string) (int64, error) {
+			var size fs.SizeSuffix
+			err := size.Set(value)
+			return int64(size), err
+		},
+	},
+	"age": {
+		get: func(item *exprItem) int64 {
+			return int64(time.Since(item.modTime))
+		},
+		parse: func(value string) (int64, error) {
+			d, err := fs.ParseDuration(value)
+			return int64(d), err
+		},
+	},
+}
+
+// stringFields are the fields which can be compared as strings
+var stringFields = map[string]func(item *exprItem) string{
+	"name": func(item *exprItem) string {
+		return path.Base(item.remote)
+	},
+	"path": func(item *exprItem) string {
+		return item.remote
+	},
+	"mime": func(item *exprItem) string {
+		if item.o != nil {
+			return fs.MimeType(item.ctx, item.o)
+		}
+		return fs.MimeTypeFromName(item.remote)
+	},
+	"tier": func(item *exprItem) string {
+		if item.o != nil {
+			return objectTier(item.o)
+		}
+		return ""
+	},
+}
+
+// filterExpr is a parsed filter expression
+type filterExpr struct {
+	text         string
+	root         exprNode
+	needsModTime bool // set if the expression uses the modification time
+}
+
+// exprParser parses a filter expression
+type exprParser struct {
+	text         string
+	tokens       []string
+	pos          int
+	ignoreCase   bool
+	needsModTime bool
+}
+
+// exprOperators are the operator tokens, longest first
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// tokenize splits the expression into tokens. Quoted strings are
+// returned with the quotes on so they can be told apart from
+// operators.
+func tokenizeExpr(text string) (tokens []string, err error) {
+	s := text
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return tokens, nil
+		}
+		if c := s[0]; c == '\'' || c == '"' {
+			// Quoted string - a backslash only escapes the quote
+			var value strings.Builder
+			_ = value.WriteByte(c)
+			i := 1
+			for ; i < len(s) && s[i] != c; i++ {
+				if s[i] == '\\' && i+1 < len(s) && s[i+1] == c {
+					i++
+				}
+				_ = value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.Errorf("unterminated string in filter expression %q", text)
+			}
+			tokens = append(tokens, value.String())
+			s = s[i+1:]
+			continue
+		}
+		found := false
+		for _, op := range exprOperators {
+			if strings.HasPrefix(s, op) {
+				tokens = append(tokens, op)
+				s = s[len(op):]
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		// A bare word runs until a space or operator
+		end := strings.IndexFunc(s, func(r rune) bool {
+			return unicode.IsSpace(r) || strings.ContainsRune("&|=!<>()'\"", r)
+		})
+		if end < 0 {
+			end = len(s)
+		}
+		tokens = append(tokens, s[:end])
+		s = s[end:]
+	}
+}
+
+// peek returns the next token or "" at the end
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// next returns the next token and moves on
+func (p *exprParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// errorf returns an error about the expression being parsed
+func (p *exprParser) errorf(format string, a ...interface{}) error {
+	return errors.Errorf("filter expression %q: %s", p.text, errors.Errorf(format, a...))
+}
+
+// parseOr parses a || b || ...
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a && b && ...
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot parses !a, (a) or a comparison
+func (p *exprParser) parseNot() (exprNode, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: node}, nil
+	case "(":
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, p.errorf("missing ')'")
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+// unquote returns the value of a token which may be quoted
+func unquote(token string) string {
+	if len(token) >= 1 && (token[0] == '\'' || token[0] == '"') {
+		return token[1:]
+	}
+	return token
+}
+
+// parseComparison parses field op value
+func (p *exprParser) parseComparison() (exprNode, error) {
+	field := p.next()
+	if field == "" {
+		return nil, p.errorf("unexpected end")
+	}
+	op := p.next()
+	value := p.next()
+	if value == "" {
+		return nil, p.errorf("expecting field, operator and value after %q", field)
+	}
+	value = unquote(value)
+	if number, ok := numberFields[field]; ok {
+		switch op {
+		case "==", "!=", "<", "<=", ">", ">=":
+		default:
+			return nil, p.errorf("can't use %q with %s", op, field)
+		}
+		x, err := number.parse(value)
+		if err != nil {
+			return nil, p.errorf("bad value for %s: %v", field, err)
+		}
+		if field == "age" {
+			p.needsModTime = true
+		}
+		return &numberNode{get: number.get, op: op, value: x}, nil
+	}
+	if get, ok := stringFields[field]; ok {
+		node := &stringNode{get: get, op: op, value: value, ignoreCase: p.ignoreCase}
+		switch op {
+		case "==", "!=":
+		case "=~", "!~":
+			if p.ignoreCase {
+				value = "(?i)" + value
+			}
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, p.errorf("bad regexp for %s: %v", field, err)
+			}
+			node.re = re
+		default:
+			return nil, p.errorf("can't use %q with %s", op, field)
+		}
+		return node, nil
+	}
+	return nil, p.errorf("unknown field %q", field)
+}
+
+// parseExpr parses a filter expression
+func parseExpr(text string, ignoreCase bool) (*filterExpr, error) {
+	tokens, err := tokenizeExpr(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{
+		text:       text,
+		tokens:     tokens,
+		ignoreCase: ignoreCase,
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return &filterExpr{
+		text:         text,
+		root:         root,
+		needsModTime: p.needsModTime,
+	}, nil
+}
+
+// failedExpr returns the first filter expression which is false for
+// item or nil if item passes them all
+func (f *Filter) failedExpr(item *exprItem) *filterExpr {
+	for _, expr := range f.exprs {
+		if !expr.root.eval(item) {
+			return expr
+		}
+	}
+	return nil
+}
+
+// exprsNeedModTime returns true if any of the filter expressions use
+// the modification time
+func (f *Filter) exprsNeedModTime() bool {
+	for _, expr := range f.exprs {
+		if expr.needsModTime {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeExpr(t *testing.T) {
+	tokens, err := tokenizeExpr(`size>100M && !(name =~ '\.mkv$'||path=="a b")`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"size", ">", "100M", "&&", "!", "(", "name", "=~", `'\.mkv$`, "||", "path", "==", `"a b`, ")"}, tokens)
+
+	tokens, err = tokenizeExpr(`name == 'it\'s'`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "==", `'it's`}, tokens)
+
+	_, err = tokenizeExpr(`name == 'potato`)
+	assert.Error(t, err)
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"size",
+		"size >",
+		"size > potato",
+		"size =~ 100",
+		"name < potato",
+		"colour == red",
+		"name =~ '('",
+		"(size > 1",
+		"size > 1 size > 2",
+		"size > 1 &&",
+	} {
+		_, err := parseExpr(text, false)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestParseExpr(t *testing.T) {
+	now := time.Now()
+	item := func(remote string, size int64, age time.Duration) *exprItem {
+		return &exprItem{
+			ctx:     context.Background(),
+			remote:  remote,
+			size:    size,
+			modTime: now.Add(-age),
+		}
+	}
+	day := 24 * time.Hour
+	for _, test := range []struct {
+		text       string
+		ignoreCase bool
+		item       *exprItem
+		want       bool
+	}{
+		{"size > 100M && age < 7d && name =~ '\\.mkv$'", false, item("dir/film.mkv", 200<<20, day), true},
+		{"size > 100M && age < 7d && name =~ '\\.mkv$'", false, item("dir/film.mkv", 50<<20, day), false},
+		{"size > 100M && age < 7d && name =~ '\\.mkv$'", false, item("dir/film.mkv", 200<<20, 8*day), false},
+		{"size > 100M && age < 7d && name =~ '\\.mkv$'", false, item("dir/film.avi", 200<<20, day), false},
+		{"size < 1k || age > 1y", false, item("file", 100, day), true},
+		{"size < 1k || age > 1y", false, item("file", 2000, 400*day), true},
+		{"size < 1k || age > 1y", false, item("file", 2000, day), false},
+		{"!(size >= 1k) && path != 'dir/file'", false, item("dir/file", 100, day), false},
+		{"!(size >= 1k) && path != 'dir/file'", false, item("file", 100, day), true},
+		{"size == 0 || size > 10b && size < 20b", false, item("file", 0, day), true},
+		{"size == 0 || size > 10b && size < 20b", false, item("file", 15, day), true},
+		{"size == 0 || size > 10b && size < 20b", false, item("file", 5, day), false},
+		{"name == FILE.txt", false, item("dir/file.txt", 0, day), false},
+		{"name == FILE.txt", true, item("dir/file.txt", 0, day), true},
+		{"name !~ '^FILE'", true, item("file.txt", 0, day), false},
+		{"mime =~ '^image/'", false, item("photo.jpg", 0, day), true},
+		{"mime =~ '^image/'", false, item("film.mkv", 0, day), false},
+		{"tier == ''", false, item("file", 0, day), true},
+	} {
+		expr, err := parseExpr(test.text, test.ignoreCase)
+		require.NoError(t, err, test.text)
+		assert.Equal(t, test.want, expr.root.eval(test.item), "%s on %+v", test.text, test.item)
+	}
+}
+
+func TestNewFilterExpr(t *testing.T) {
+	ctx := context.Background()
+	opt := DefaultOpt
+	opt.FilterExpr = []string{"size > 1k", "name =~ '\\.txt$'"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	assert.False(t, f.exprsNeedModTime())
+	testInclude(t, f, []includeTest{
+		{"file.txt", 2000, 0, true},
+		{"file.txt", 100, 0, false},
+		{"file.jpg", 2000, 0, false},
+	})
+	assert.False(t, f.IncludeObject(ctx, mockobject.New("file.txt"))) // size 0
+	assert.Contains(t, f.DumpFilters(), "size > 1k")
+
+	opt.FilterExpr = []string{"age > 1d"}
+	f, err = NewFilter(&opt)
+	require.NoError(t, err)
+	assert.True(t, f.exprsNeedModTime())
+
+	opt.FilterExpr = []string{"size >"}
+	_, err = NewFilter(&opt)
+	assert.Error(t, err)
+}
@@ -4,16 +4,20 @@ package filter
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -24,6 +28,7 @@ var Active = mustNewFilter(nil)
 type rule struct {
 	Include bool
 	Regexp  *regexp.Regexp
+	Source  string // the rule as given which this was made from
 }
 
 // Match returns true if rule matches path
@@ -46,14 +51,16 @@ type rules struct {
 	existing map[string]struct{}
 }
 
-// add adds a rule if it doesn't exist already
-func (rs *rules) add(Include bool, re *regexp.Regexp) {
+// add adds a rule if it doesn't exist already. source is the rule as
+// given, eg "+ *.jpg", for explaining which rule matched.
+func (rs *rules) add(Include bool, re *regexp.Regexp, source string) {
 	if rs.existing == nil {
 		rs.existing = make(map[string]struct{})
 	}
 	newRule := rule{
 		Include: Include,
 		Regexp:  re,
+		Source:  source,
 	}
 	newRuleString := newRule.String()
 	if _, ok := rs.existing[newRuleString]; ok {
@@ -63,6 +70,16 @@ func (rs *rules) add(Include bool, re *regexp.Regexp) {
 	rs.existing[newRuleString] = struct{}{}
 }
 
+// match returns the first rule which matches path or nil if none do
+func (rs *rules) match(path string) *rule {
+	for i := range rs.rules {
+		if rs.rules[i].Match(path) {
+			return &rs.rules[i]
+		}
+	}
+	return nil
+}
+
 // clear clears all the rules
 func (rs *rules) clear() {
 	rs.rules = nil
@@ -84,9 +101,12 @@ type Opt struct {
 	FilterFrom     []string
 	ExcludeRule    []string
 	ExcludeFrom    []string
-	ExcludeFile    string
+	ExcludeFile    []string
+	IncludeFile    []string
 	IncludeRule    []string
 	IncludeFrom    []string
+	IncludeRegex   []string
+	ExcludeRegex   []string
 	FilesFrom      []string
 	FilesFromRaw   []string
 	MinAge         fs.Duration
@@ -94,6 +114,47 @@ type Opt struct {
 	MinSize        fs.SizeSuffix
 	MaxSize        fs.SizeSuffix
 	IgnoreCase     bool
+	IncludeMime    []string
+	ExcludeMime    []string
+	IncludeTier    []string
+	ExcludeTier    []string
+	IncludeOwner   []string
+	ExcludeOwner   []string
+	FilterExpr     []string
+	FilesFromHash  []string
+	ExcludeHash    []string
+	IgnoreFileName []string
+}
+
+// UnmarshalJSON reads the options from JSON as used by the rc.
+//
+// ExcludeFile may be given as a single string as it was before
+// --exclude-if-present could be repeated.
+func (opt *Opt) UnmarshalJSON(data []byte) error {
+	type plainOpt Opt // without this method
+	aux := struct {
+		*plainOpt
+		ExcludeFile json.RawMessage
+	}{
+		plainOpt: (*plainOpt)(opt),
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	if len(aux.ExcludeFile) == 0 {
+		return nil
+	}
+	var excludeFile string
+	if json.Unmarshal(aux.ExcludeFile, &excludeFile) == nil {
+		opt.ExcludeFile = nil
+		if excludeFile != "" {
+			opt.ExcludeFile = []string{excludeFile}
+		}
+		return nil
+	}
+	opt.ExcludeFile = nil
+	return json.Unmarshal(aux.ExcludeFile, &opt.ExcludeFile)
 }
 
 // DefaultOpt is the default config for the filter
@@ -106,13 +167,24 @@ var DefaultOpt = Opt{
 
 // Filter describes any filtering in operation
 type Filter struct {
-	Opt         Opt
-	ModTimeFrom time.Time
-	ModTimeTo   time.Time
-	fileRules   rules
-	dirRules    rules
-	files       FilesMap // files if filesFrom
-	dirs        FilesMap // dirs from filesFrom
+	Opt           Opt
+	ModTimeFrom   time.Time
+	ModTimeTo     time.Time
+	fileRules     rules
+	dirRules      rules
+	files         FilesMap       // files if filesFrom
+	dirs          FilesMap       // dirs from filesFrom
+	mimeTypeRules attributeRules // rules on the MIME type of objects
+	tierRules     attributeRules // rules on the storage tier of objects
+	ownerRules    attributeRules // rules on the owner of objects
+	exprs         []*filterExpr  // filter expressions which must all be true
+	hashRules     hashRules      // lists of hashes to include or exclude
+
+	includeFileMu   sync.Mutex
+	includeFileDirs map[string]struct{} // directories with an include file in or above them
+
+	ignoreMu    sync.Mutex
+	ignoreRules map[string]ignoreFileRules // rules from the ignore files indexed by directory
 }
 
 // NewFilter parses the command line options and creates a Filter
@@ -159,6 +231,13 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 		addImplicitExclude = true
 	}
+	for _, rule := range f.Opt.IncludeRegex {
+		err = f.AddRegexp(true, rule)
+		if err != nil {
+			return nil, err
+		}
+		addImplicitExclude = true
+	}
 	for _, rule := range f.Opt.ExcludeRule {
 		err = f.Add(false, rule)
 		if err != nil {
@@ -175,6 +254,13 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 		foundExcludeRule = true
 	}
+	for _, rule := range f.Opt.ExcludeRegex {
+		err = f.AddRegexp(false, rule)
+		if err != nil {
+			return nil, err
+		}
+		foundExcludeRule = true
+	}
 
 	if addImplicitExclude && foundExcludeRule {
 		fs.Errorf(nil, "Using --filter is recommended instead of both --include and --exclude as the order they are parsed in is indeterminate")
@@ -223,6 +309,31 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 	}
 
+	// These can be used with --files-from
+	for _, text := range f.Opt.FilterExpr {
+		expr, err := parseExpr(text, f.Opt.IgnoreCase)
+		if err != nil {
+			return nil, err
+		}
+		f.exprs = append(f.exprs, expr)
+	}
+	f.mimeTypeRules, err = newAttributeRules("MIME type", f.Opt.IncludeMime, f.Opt.ExcludeMime)
+	if err != nil {
+		return nil, err
+	}
+	f.tierRules, err = newAttributeRules("tier", f.Opt.IncludeTier, f.Opt.ExcludeTier)
+	if err != nil {
+		return nil, err
+	}
+	f.ownerRules, err = newAttributeRules("owner", f.Opt.IncludeOwner, f.Opt.ExcludeOwner)
+	if err != nil {
+		return nil, err
+	}
+	f.hashRules, err = newHashRules(f.Opt.FilesFromHash, f.Opt.ExcludeHash)
+	if err != nil {
+		return nil, err
+	}
+
 	if addImplicitExclude {
 		err = f.Add(false, "/**")
 		if err != nil {
@@ -245,6 +356,14 @@ func mustNewFilter(opt *Opt) *Filter {
 	return f
 }
 
+// ruleSource returns the rule as given for explaining which rule matched
+func ruleSource(Include bool, glob string) string {
+	if Include {
+		return "+ " + glob
+	}
+	return "- " + glob
+}
+
 // addDirGlobs adds directory globs from the file glob passed in
 func (f *Filter) addDirGlobs(Include bool, glob string) error {
 	for _, dirGlob := range globToDirGlobs(glob) {
@@ -252,28 +371,34 @@ func (f *Filter) addDirGlobs(Include bool, glob string) error {
 		if dirGlob == "/" {
 			continue
 		}
-		dirRe, err := globToRegexp(dirGlob, f.Opt.IgnoreCase)
+		dirRe, err := GlobToRegexp(dirGlob, f.Opt.IgnoreCase)
 		if err != nil {
 			return err
 		}
-		f.dirRules.add(Include, dirRe)
+		f.dirRules.add(Include, dirRe, ruleSource(Include, glob))
 	}
 	return nil
 }
 
 // Add adds a filter rule with include or exclude status indicated
+//
+// If glob starts with "//" then the rest of it is a regular
+// expression, see AddRegexp.
 func (f *Filter) Add(Include bool, glob string) error {
+	if strings.HasPrefix(glob, "//") {
+		return f.AddRegexp(Include, glob[2:])
+	}
 	isDirRule := strings.HasSuffix(glob, "/")
 	isFileRule := !isDirRule
 	if strings.Contains(glob, "**") {
 		isDirRule, isFileRule = true, true
 	}
-	re, err := globToRegexp(glob, f.Opt.IgnoreCase)
+	re, err := GlobToRegexp(glob, f.Opt.IgnoreCase)
 	if err != nil {
 		return err
 	}
 	if isFileRule {
-		f.fileRules.add(Include, re)
+		f.fileRules.add(Include, re, ruleSource(Include, glob))
 		// If include rule work out what directories are needed to scan
 		// if exclude rule, we can't rule anything out
 		// Unless it is `*` which matches everything
@@ -286,7 +411,38 @@ func (f *Filter) Add(Include bool, glob string) error {
 		}
 	}
 	if isDirRule {
-		f.dirRules.add(Include, re)
+		f.dirRules.add(Include, re, ruleSource(Include, glob))
+	}
+	return nil
+}
+
+// AddRegexp adds a filter rule matching the regular expression expr
+// against the path of the file relative to the root with include or
+// exclude status indicated.
+//
+// If expr ends with "/$" then it only matches directories. An
+// include rule for files makes every directory be read as there is no
+// way of telling which directories the files could be in.
+func (f *Filter) AddRegexp(Include bool, expr string) error {
+	if f.Opt.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return errors.Wrapf(err, "bad regexp filter %q", expr)
+	}
+	source := ruleSource(Include, "//"+expr)
+	if strings.HasSuffix(expr, "/$") {
+		f.dirRules.add(Include, re, source)
+		return nil
+	}
+	f.fileRules.add(Include, re, source)
+	if Include {
+		dirRe, err := GlobToRegexp("/**", false)
+		if err != nil {
+			return err
+		}
+		f.dirRules.add(true, dirRe, source)
 	}
 	return nil
 }
@@ -297,6 +453,8 @@ func (f *Filter) Add(Include bool, glob string) error {
 //
 //   + glob
 //   - glob
+//   + //regexp
+//   - //regexp
 //   !
 //
 // '+' includes the glob, '-' excludes it and '!' resets the filter list
@@ -364,73 +522,176 @@ func (f *Filter) InActive() bool {
 		f.Opt.MaxSize < 0 &&
 		f.fileRules.len() == 0 &&
 		f.dirRules.len() == 0 &&
-		len(f.Opt.ExcludeFile) == 0)
+		len(f.Opt.ExcludeFile) == 0 &&
+		len(f.Opt.IncludeFile) == 0 &&
+		!f.UsesAttributes() &&
+		!f.hashRules.active() &&
+		!f.UsesIgnoreFiles() &&
+		len(f.exprs) == 0)
 }
 
-// includeRemote returns whether this remote passes the filter rules.
-func (f *Filter) includeRemote(remote string) bool {
-	for _, rule := range f.fileRules.rules {
-		if rule.Match(remote) {
-			return rule.Include
+// includeRemote returns whether this remote passes the filter rules
+// and why.
+func (f *Filter) includeRemote(remote string) (bool, Reason) {
+	if rule := f.fileRules.match(remote); rule != nil {
+		return rule.Include, ruleReason(rule)
+	}
+	return true, Reason{Why: reasonNoRule}
+}
+
+// matchMarker returns true if the leaf name of remote matches any of
+// the marker file names or glob patterns in markers
+func (f *Filter) matchMarker(markers []string, remote string) bool {
+	basename := path.Base(remote)
+	if f.Opt.IgnoreCase {
+		basename = strings.ToLower(basename)
+	}
+	for _, marker := range markers {
+		if f.Opt.IgnoreCase {
+			marker = strings.ToLower(marker)
+		}
+		if ok, _ := path.Match(marker, basename); ok {
+			return true
 		}
 	}
-	return true
+	return false
 }
 
-// ListContainsExcludeFile checks if exclude file is present in the list.
-func (f *Filter) ListContainsExcludeFile(entries fs.DirEntries) bool {
-	if len(f.Opt.ExcludeFile) == 0 {
+// listContainsMarker checks if any of markers is present in the list
+func (f *Filter) listContainsMarker(markers []string, entries fs.DirEntries) bool {
+	if len(markers) == 0 {
 		return false
 	}
 	for _, entry := range entries {
 		obj, ok := entry.(fs.Object)
-		if ok {
-			basename := path.Base(obj.Remote())
-			if basename == f.Opt.ExcludeFile {
-				return true
-			}
+		if ok && f.matchMarker(markers, obj.Remote()) {
+			return true
 		}
 	}
 	return false
 }
 
+// IsExcludeFile returns true if remote is a file whose presence
+// excludes its directory.
+func (f *Filter) IsExcludeFile(remote string) bool {
+	return f.matchMarker(f.Opt.ExcludeFile, remote)
+}
+
+// ListContainsExcludeFile checks if exclude file is present in the list.
+func (f *Filter) ListContainsExcludeFile(entries fs.DirEntries) bool {
+	return f.listContainsMarker(f.Opt.ExcludeFile, entries)
+}
+
+// IsIncludeFile returns true if remote is a file whose presence
+// includes its directory.
+func (f *Filter) IsIncludeFile(remote string) bool {
+	return f.matchMarker(f.Opt.IncludeFile, remote)
+}
+
+// IncludeFileDir returns whether the files in dir of fremote, whose
+// listing is entries, should be included according to
+// --include-only-if-present. This is true if dir or any directory
+// above it contains an include file.
+//
+// The directories above dir must have been passed to this before dir
+// which is the case when walking a directory tree.
+func (f *Filter) IncludeFileDir(fremote fs.Fs, dir string, entries fs.DirEntries) bool {
+	if len(f.Opt.IncludeFile) == 0 {
+		return true
+	}
+	prefix := fs.ConfigString(fremote) + "\x00"
+	f.includeFileMu.Lock()
+	defer f.includeFileMu.Unlock()
+	if f.includeFileDirs == nil {
+		f.includeFileDirs = make(map[string]struct{})
+	}
+	included := f.listContainsMarker(f.Opt.IncludeFile, entries)
+	if !included && dir != "" {
+		_, included = f.includeFileDirs[prefix+parentDir(dir)]
+	}
+	if included {
+		f.includeFileDirs[prefix+dir] = struct{}{}
+	}
+	return included
+}
+
+// parentDir returns the parent directory of remote or "" for the root
+func parentDir(remote string) string {
+	parent := path.Dir(remote)
+	if parent == "." || parent == "/" {
+		parent = ""
+	}
+	return parent
+}
+
 // IncludeDirectory returns a function which checks whether this
 // directory should be included in the sync or not.
 func (f *Filter) IncludeDirectory(ctx context.Context, fs fs.Fs) func(string) (bool, error) {
 	return func(remote string) (bool, error) {
-		remote = strings.Trim(remote, "/")
-		// first check if we need to remove directory based on
-		// the exclude file
-		excl, err := f.DirContainsExcludeFile(ctx, fs, remote)
-		if err != nil {
-			return false, err
-		}
-		if excl {
-			return false, nil
-		}
+		include, _, err := f.ExplainDirectory(ctx, fs, remote)
+		return include, err
+	}
+}
 
-		// filesFrom takes precedence
-		if f.files != nil {
-			_, include := f.dirs[remote]
-			return include, nil
-		}
-		remote += "/"
-		for _, rule := range f.dirRules.rules {
-			if rule.Match(remote) {
-				return rule.Include, nil
-			}
+// ExplainDirectory returns whether the directory remote of fremote
+// should be included in the sync or not and why.
+func (f *Filter) ExplainDirectory(ctx context.Context, fremote fs.Fs, remote string) (bool, Reason, error) {
+	remote = strings.Trim(remote, "/")
+	include, reason, err := f.explainDirectory(ctx, fremote, remote)
+	if include && err == nil && f.UsesIgnoreFiles() {
+		if ignored, ignoreReason := f.ignored(remote, true); ignored {
+			return false, ignoreReason, nil
 		}
+	}
+	return include, reason, err
+}
+
+// explainDirectory does the work of ExplainDirectory apart from the
+// ignore files
+func (f *Filter) explainDirectory(ctx context.Context, fremote fs.Fs, remote string) (bool, Reason, error) {
+	// first check if we need to remove directory based on
+	// the exclude file
+	excl, err := f.DirContainsExcludeFile(ctx, fremote, remote)
+	if err != nil {
+		return false, Reason{}, err
+	}
+	if excl {
+		return false, Reason{Why: reasonExcludeFile}, nil
+	}
 
-		return true, nil
+	// filesFrom takes precedence
+	if f.files != nil {
+		if _, include := f.dirs[remote]; include {
+			return true, Reason{Why: reasonFilesFromDir}, nil
+		}
+		return false, Reason{Why: reasonNotFilesFromDir}, nil
 	}
+	if rule := f.dirRules.match(remote + "/"); rule != nil {
+		return rule.Include, ruleReason(rule), nil
+	}
+	return true, Reason{Why: reasonNoRule}, nil
 }
 
 // DirContainsExcludeFile checks if exclude file is present in a
-// directory. If fs is nil, it works properly if ExcludeFile is an
-// empty string (for testing).
+// directory. If fs is nil, it works properly if ExcludeFile is empty
+// (for testing).
+//
+// Exclude files which are plain names are looked for directly, but if
+// any are glob patterns the directory is listed to find them.
 func (f *Filter) DirContainsExcludeFile(ctx context.Context, fremote fs.Fs, remote string) (bool, error) {
-	if len(f.Opt.ExcludeFile) > 0 {
-		exists, err := fs.FileExists(ctx, fremote, path.Join(remote, f.Opt.ExcludeFile))
+	for _, excludeFile := range f.Opt.ExcludeFile {
+		if strings.ContainsAny(excludeFile, "*?[\\") || f.Opt.IgnoreCase {
+			entries, err := fremote.List(ctx, remote)
+			if err == fs.ErrorDirNotFound {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+			return f.ListContainsExcludeFile(entries), nil
+		}
+	}
+	for _, excludeFile := range f.Opt.ExcludeFile {
+		exists, err := fs.FileExists(ctx, fremote, path.Join(remote, excludeFile))
 		if err != nil {
 			return false, err
 		}
@@ -444,22 +705,37 @@ func (f *Filter) DirContainsExcludeFile(ctx context.Context, fremote fs.Fs, remo
 // Include returns whether this object should be included into the
 // sync or not
 func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
+	include, _ := f.include(context.Background(), remote, size, modTime, nil)
+	return include
+}
+
+// include does the work for Include and IncludeObject, returning
+// why the file was included or not. o is the object being checked or
+// nil if not known.
+func (f *Filter) include(ctx context.Context, remote string, size int64, modTime time.Time, o fs.Object) (bool, Reason) {
+	if len(f.exprs) > 0 {
+		if expr := f.failedExpr(&exprItem{ctx: ctx, remote: remote, size: size, modTime: modTime, o: o}); expr != nil {
+			return false, Reason{Why: reasonExpr, Detail: expr.text}
+		}
+	}
 	// filesFrom takes precedence
 	if f.files != nil {
-		_, include := f.files[remote]
-		return include
+		if _, include := f.files[remote]; include {
+			return true, Reason{Why: reasonFilesFrom}
+		}
+		return false, Reason{Why: reasonNotFilesFrom}
 	}
 	if !f.ModTimeFrom.IsZero() && modTime.Before(f.ModTimeFrom) {
-		return false
+		return false, Reason{Why: reasonTooOld}
 	}
 	if !f.ModTimeTo.IsZero() && modTime.After(f.ModTimeTo) {
-		return false
+		return false, Reason{Why: reasonTooNew}
 	}
 	if f.Opt.MinSize >= 0 && size < int64(f.Opt.MinSize) {
-		return false
+		return false, Reason{Why: reasonTooSmall}
 	}
 	if f.Opt.MaxSize >= 0 && size > int64(f.Opt.MaxSize) {
-		return false
+		return false, Reason{Why: reasonTooBig}
 	}
 	return f.includeRemote(remote)
 }
@@ -468,26 +744,75 @@ func (f *Filter) Include(remote string, size int64, modTime time.Time) bool {
 // the sync or not. This is a convenience function to avoid calling
 // o.ModTime(), which is an expensive operation.
 func (f *Filter) IncludeObject(ctx context.Context, o fs.Object) bool {
+	include, _ := f.ExplainObject(ctx, o)
+	return include
+}
+
+// ExplainObject returns whether this object should be included into
+// the sync or not and why.
+func (f *Filter) ExplainObject(ctx context.Context, o fs.Object) (bool, Reason) {
 	var modTime time.Time
 
-	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() {
+	if !f.ModTimeFrom.IsZero() || !f.ModTimeTo.IsZero() || f.exprsNeedModTime() {
 		modTime = o.ModTime(ctx)
 	} else {
 		modTime = time.Unix(0, 0)
 	}
 
-	return f.Include(o.Remote(), o.Size(), modTime)
+	include, reason := f.include(ctx, o.Remote(), o.Size(), modTime, o)
+	if !include {
+		return include, reason
+	}
+	if f.UsesIgnoreFiles() {
+		if ignored, ignoreReason := f.ignored(o.Remote(), false); ignored {
+			return false, ignoreReason
+		}
+	}
+	if attrInclude, attrReason := f.includeAttributes(ctx, o); !attrInclude {
+		return attrInclude, attrReason
+	}
+	if f.hashRules.active() {
+		if hashInclude, hashReason := f.hashRules.includeObject(ctx, o); !hashInclude {
+			return hashInclude, hashReason
+		}
+	}
+	return include, reason
+}
+
+// openRemoteFile opens the file at path, which may be on a remote,
+// eg "remote:config/rules.txt", or a local path
+func openRemoteFile(path string) (io.ReadCloser, error) {
+	configName, _, err := fspath.Parse(path)
+	if err != nil || configName == "" {
+		return os.Open(path)
+	}
+	ctx := context.Background()
+	parent, leaf, err := fspath.Split(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.NewFs(parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %q", path)
+	}
+	o, err := f.NewObject(ctx, leaf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %q", path)
+	}
+	return o.Open(ctx)
 }
 
 // forEachLine calls fn on every line in the file pointed to by path
 //
+// The file is read from a remote if path is a remote path.
+//
 // It ignores empty lines and lines starting with '#' or ';' if raw is false
 func forEachLine(path string, raw bool, fn func(string) error) (err error) {
 	var scanner *bufio.Scanner
 	if path == "-" {
 		scanner = bufio.NewScanner(os.Stdin)
 	} else {
-		in, err := os.Open(path)
+		in, err := openRemoteFile(path)
 		if err != nil {
 			return err
 		}
@@ -519,6 +844,15 @@ func (f *Filter) DumpFilters() string {
 	if !f.ModTimeTo.IsZero() {
 		rules = append(rules, fmt.Sprintf("Last-modified date must be equal or less than: %s", f.ModTimeTo.String()))
 	}
+	for _, expr := range f.exprs {
+		rules = append(rules, fmt.Sprintf("Filter expression must be true: %s", expr.text))
+	}
+	for ht, set := range f.hashRules.include {
+		rules = append(rules, fmt.Sprintf("%v hash must be one of %d hashes from --files-from-hashes", ht, len(set)))
+	}
+	for ht, set := range f.hashRules.exclude {
+		rules = append(rules, fmt.Sprintf("%v hash must not be one of %d hashes from --exclude-from-hashes", ht, len(set)))
+	}
 	rules = append(rules, "--- File filter rules ---")
 	for _, rule := range f.fileRules.rules {
 		rules = append(rules, rule.String())
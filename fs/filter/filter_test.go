@@ -2,6 +2,7 @@ package filter
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -10,7 +11,9 @@ import (
 	"testing"
 	"time"
 
+	_ "github.com/rclone/rclone/backend/local"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -503,6 +506,57 @@ func TestNewFilterMatchesIgnoreCase(t *testing.T) {
 	assert.False(t, f.InActive())
 }
 
+func TestNewFilterMatchesRegexp(t *testing.T) {
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	add := func(s string) {
+		err := f.AddRule(s)
+		require.NoError(t, err)
+	}
+	add(`- //^tmp/$`)
+	add(`+ //^logs/20[0-9]{2}-(0[1-9]|1[0-2])/`)
+	add(`+ //^file[1-3]\.txt$`)
+	add("- *")
+	testInclude(t, f, []includeTest{
+		{"logs/2020-07/file.log", 100, 0, true},
+		{"logs/2020-13/file.log", 100, 0, false},
+		{"logs/1999-07/file.log", 100, 0, false},
+		{"file2.txt", 100, 0, true},
+		{"file4.txt", 100, 0, false},
+		{"dir/file2.txt", 100, 0, false},
+	})
+	testDirInclude(t, f, []includeDirTest{
+		{"tmp", false},
+		{"tmp/sub", true},
+		{"logs", true},
+		{"other", true},
+	})
+	assert.Error(t, f.AddRule("+ //(unclosed"))
+}
+
+func TestNewFilterRegexpFlags(t *testing.T) {
+	opt := DefaultOpt
+	opt.IncludeRegex = []string{`\.(jpe?g|png)$`}
+	opt.ExcludeRegex = []string{`^secret`}
+	opt.IgnoreCase = true
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	testInclude(t, f, []includeTest{
+		{"photo.jpg", 100, 0, true},
+		{"PHOTO.JPEG", 100, 0, true},
+		{"dir/photo.png", 100, 0, true},
+		{"secret.jpg", 100, 0, true}, // includes are processed first
+		{"photo.gif", 100, 0, false},
+		{"secret.txt", 100, 0, false},
+	})
+	assert.False(t, f.UsesDirectoryFilters())
+
+	opt = DefaultOpt
+	opt.ExcludeRegex = []string{"[a-"}
+	_, err = NewFilter(&opt)
+	assert.Error(t, err)
+}
+
 func TestFilterAddDirRuleOrFileRule(t *testing.T) {
 	for _, test := range []struct {
 		included bool
@@ -626,6 +680,117 @@ func TestFilterForEachLineStdinWithRaw(t *testing.T) {
 	testFilterForEachLine(t, true, true)
 }
 
+func TestFilterForEachLineRemote(t *testing.T) {
+	file := testFile(t, "one\n# comment\ntwo\n")
+	defer func() {
+		err := os.Remove(file)
+		require.NoError(t, err)
+	}()
+	lines := []string{}
+	err := forEachLine(":local:"+file, false, func(s string) error {
+		lines = append(lines, s)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, lines)
+
+	err = forEachLine(":local:"+file+"-not-found", false, func(s string) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestOptUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{`{"ExcludeFile":".nobackup"}`, []string{".nobackup"}},
+		{`{"ExcludeFile":""}`, nil},
+		{`{"ExcludeFile":["CACHEDIR.TAG",".nobackup*"]}`, []string{"CACHEDIR.TAG", ".nobackup*"}},
+		{`{"ExcludeFile":null}`, nil},
+		{`{"MinSize":1024}`, []string{"keep"}},
+	} {
+		opt := DefaultOpt
+		opt.ExcludeFile = []string{"keep"}
+		err := json.Unmarshal([]byte(test.in), &opt)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, opt.ExcludeFile, test.in)
+	}
+
+	// Other fields are read as normal
+	opt := DefaultOpt
+	err := json.Unmarshal([]byte(`{"MinSize":1024,"IncludeFile":[".backup"],"DeleteExcluded":true}`), &opt)
+	require.NoError(t, err)
+	assert.Equal(t, fs.SizeSuffix(1024), opt.MinSize)
+	assert.Equal(t, []string{".backup"}, opt.IncludeFile)
+	assert.True(t, opt.DeleteExcluded)
+	assert.Equal(t, fs.DurationOff, opt.MaxAge)
+
+	// The JSON written is unchanged
+	out, err := json.Marshal(&opt)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"ExcludeFile":null`)
+
+	err = json.Unmarshal([]byte(`{"ExcludeFile":1}`), &opt)
+	assert.Error(t, err)
+}
+
+func TestFilterMarkerFiles(t *testing.T) {
+	opt := DefaultOpt
+	opt.ExcludeFile = []string{"CACHEDIR.TAG", ".nobackup*"}
+	opt.IncludeFile = []string{".backup"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+
+	assert.True(t, f.IsExcludeFile("dir/CACHEDIR.TAG"))
+	assert.True(t, f.IsExcludeFile("dir/.nobackup-please"))
+	assert.True(t, f.IsExcludeFile(".nobackup"))
+	assert.False(t, f.IsExcludeFile("dir/cachedir.tag"))
+	assert.False(t, f.IsExcludeFile("dir/.backup"))
+	assert.True(t, f.IsIncludeFile("dir/.backup"))
+	assert.False(t, f.IsIncludeFile("dir/CACHEDIR.TAG"))
+
+	f.Opt.IgnoreCase = true
+	assert.True(t, f.IsExcludeFile("dir/cachedir.tag"))
+	f.Opt.IgnoreCase = false
+
+	assert.True(t, f.ListContainsExcludeFile(fs.DirEntries{
+		mockobject.Object("dir/a"),
+		mockobject.Object("dir/.nobackup"),
+	}))
+	assert.False(t, f.ListContainsExcludeFile(fs.DirEntries{
+		mockobject.Object("dir/a"),
+	}))
+
+	// Files are included in directories with the include file in
+	// or above them
+	fremote := mockfs.NewFs("mock", "root")
+	other := mockfs.NewFs("mock", "other")
+	assert.False(t, f.IncludeFileDir(fremote, "", fs.DirEntries{
+		mockobject.Object("a"),
+	}))
+	assert.True(t, f.IncludeFileDir(fremote, "b", fs.DirEntries{
+		mockobject.Object("b/.backup"),
+	}))
+	assert.True(t, f.IncludeFileDir(fremote, "b/c", fs.DirEntries{
+		mockobject.Object("b/c/d"),
+	}))
+	assert.False(t, f.IncludeFileDir(fremote, "d", fs.DirEntries{
+		mockobject.Object("d/e"),
+	}))
+	assert.False(t, f.IncludeFileDir(other, "b/c", fs.DirEntries{
+		mockobject.Object("b/c/d"),
+	}))
+
+	// Everything is included without include files
+	opt.IncludeFile = nil
+	f, err = NewFilter(&opt)
+	require.NoError(t, err)
+	assert.True(t, f.IncludeFileDir(fremote, "d", nil))
+}
+
 func TestFilterMatchesFromDocs(t *testing.T) {
 	for _, test := range []struct {
 		glob       string
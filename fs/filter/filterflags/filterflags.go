@@ -27,15 +27,28 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringArrayVarP(flagSet, &Opt.FilterFrom, "filter-from", "", nil, "Read filtering patterns from a file (use - to read from stdin)")
 	flags.StringArrayVarP(flagSet, &Opt.ExcludeRule, "exclude", "", nil, "Exclude files matching pattern")
 	flags.StringArrayVarP(flagSet, &Opt.ExcludeFrom, "exclude-from", "", nil, "Read exclude patterns from file (use - to read from stdin)")
-	flags.StringVarP(flagSet, &Opt.ExcludeFile, "exclude-if-present", "", "", "Exclude directories if filename is present")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeFile, "exclude-if-present", "", nil, "Exclude directories if filename or glob is present")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeFile, "include-only-if-present", "", nil, "Only include files in directories with filename or glob present in them or above them")
 	flags.StringArrayVarP(flagSet, &Opt.IncludeRule, "include", "", nil, "Include files matching pattern")
 	flags.StringArrayVarP(flagSet, &Opt.IncludeFrom, "include-from", "", nil, "Read include patterns from file (use - to read from stdin)")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeRegex, "include-regex", "", nil, "Include files whose path matches this regular expression")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeRegex, "exclude-regex", "", nil, "Exclude files whose path matches this regular expression")
 	flags.StringArrayVarP(flagSet, &Opt.FilesFrom, "files-from", "", nil, "Read list of source-file names from file (use - to read from stdin)")
 	flags.StringArrayVarP(flagSet, &Opt.FilesFromRaw, "files-from-raw", "", nil, "Read list of source-file names from file without any processing of lines (use - to read from stdin)")
 	flags.FVarP(flagSet, &Opt.MinAge, "min-age", "", "Only transfer files older than this in s or suffix ms|s|m|h|d|w|M|y")
 	flags.FVarP(flagSet, &Opt.MaxAge, "max-age", "", "Only transfer files younger than this in s or suffix ms|s|m|h|d|w|M|y")
 	flags.FVarP(flagSet, &Opt.MinSize, "min-size", "", "Only transfer files bigger than this in k or suffix b|k|M|G")
 	flags.FVarP(flagSet, &Opt.MaxSize, "max-size", "", "Only transfer files smaller than this in k or suffix b|k|M|G")
+	flags.StringArrayVarP(flagSet, &Opt.FilterExpr, "filter-expr", "", nil, "Only include files for which this expression is true, eg \"size > 100M && age < 7d\"")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeMime, "include-mime-type", "", nil, "Only include files whose MIME type matches this pattern, eg image/*")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeMime, "exclude-mime-type", "", nil, "Exclude files whose MIME type matches this pattern")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeTier, "include-tier", "", nil, "Only include files whose storage tier or class matches this pattern")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeTier, "exclude-tier", "", nil, "Exclude files whose storage tier or class matches this pattern")
+	flags.StringArrayVarP(flagSet, &Opt.IncludeOwner, "include-owner", "", nil, "Only include files whose owner matches this pattern")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeOwner, "exclude-owner", "", nil, "Exclude files whose owner matches this pattern")
+	flags.StringArrayVarP(flagSet, &Opt.FilesFromHash, "files-from-hashes", "", nil, "Only include files whose hash is in this file, eg sha1:list.txt")
+	flags.StringArrayVarP(flagSet, &Opt.ExcludeHash, "exclude-from-hashes", "", nil, "Exclude files whose hash is in this file, eg sha1:list.txt")
+	flags.StringArrayVarP(flagSet, &Opt.IgnoreFileName, "ignore-file-name", "", nil, "Read gitignore style rules from files with this name in each directory, eg .rcloneignore")
 	flags.BoolVarP(flagSet, &Opt.IgnoreCase, "ignore-case", "", false, "Ignore case in filters (case insensitive)")
 	//cvsExclude     = BoolP("cvs-exclude", "C", false, "Exclude files in the same way CVS does")
 }
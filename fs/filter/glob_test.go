@@ -42,7 +42,7 @@ func TestGlobToRegexp(t *testing.T) {
 		{`a\\b`, `(^|/)a\\b$`, ``},
 	} {
 		for _, ignoreCase := range []bool{false, true} {
-			gotRe, err := globToRegexp(test.in, ignoreCase)
+			gotRe, err := GlobToRegexp(test.in, ignoreCase)
 			if test.error == "" {
 				prefix := ""
 				if ignoreCase {
@@ -102,7 +102,7 @@ func TestGlobToDirGlobs(t *testing.T) {
 		{"/sausage3**", []string{`/sausage3**/`, "/"}},
 		{"/a/*.jpg", []string{`/a/`, "/"}},
 	} {
-		_, err := globToRegexp(test.in, false)
+		_, err := GlobToRegexp(test.in, false)
 		assert.NoError(t, err)
 		got := globToDirGlobs(test.in)
 		assert.Equal(t, test.want, got, test.in)
@@ -0,0 +1,126 @@
+// Filtering on lists of hashes
+
+package filter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// hashSets are sets of hashes indexed by hash type
+type hashSets map[hash.Type]map[string]struct{}
+
+// hashRules are the hashes read with --files-from-hashes and
+// --exclude-from-hashes
+type hashRules struct {
+	include hashSets // objects must have one of these hashes if set
+	exclude hashSets // objects mustn't have any of these hashes
+}
+
+// parseHashType returns the hash type called name, ignoring case and
+// dashes so "sha1" can be used for "SHA-1"
+func parseHashType(name string) (hash.Type, error) {
+	normalise := func(s string) string {
+		return strings.ToLower(strings.Replace(s, "-", "", -1))
+	}
+	for _, ht := range hash.Supported().Array() {
+		if normalise(ht.String()) == normalise(name) {
+			return ht, nil
+		}
+	}
+	return hash.None, errors.Errorf("unknown hash type %q", name)
+}
+
+// readHashes reads the hashes from the files in specs which are of
+// the form "type:path", eg "sha1:list.txt".
+//
+// Each line of the file should start with a hash. Anything after it is
+// ignored so the output of the md5sum and sha1sum commands can be used.
+func readHashes(specs []string) (hashSets, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	sets := make(hashSets)
+	for _, spec := range specs {
+		i := strings.IndexRune(spec, ':')
+		if i < 0 {
+			return nil, errors.Errorf("need hash type and file, eg sha1:list.txt, not %q", spec)
+		}
+		ht, err := parseHashType(spec[:i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad hash type in %q", spec)
+		}
+		set := sets[ht]
+		if set == nil {
+			set = make(map[string]struct{})
+			sets[ht] = set
+		}
+		err = forEachLine(spec[i+1:], false, func(line string) error {
+			// md5sum puts a \ before the hash of escaped file names
+			sum := strings.TrimPrefix(strings.Fields(line)[0], "\\")
+			set[strings.ToLower(sum)] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// newHashRules reads the hash lists passed in
+func newHashRules(include, exclude []string) (rules hashRules, err error) {
+	rules.include, err = readHashes(include)
+	if err != nil {
+		return rules, err
+	}
+	rules.exclude, err = readHashes(exclude)
+	return rules, err
+}
+
+// active returns true if there are any hashes to check
+func (rules *hashRules) active() bool {
+	return len(rules.include) > 0 || len(rules.exclude) > 0
+}
+
+// matchHash returns the hash of o which is in sets or "" if none
+// are. Hashes which o's remote doesn't support are skipped.
+func matchHash(ctx context.Context, sets hashSets, o fs.Object) string {
+	supported := o.Fs().Hashes()
+	for ht, set := range sets {
+		if !supported.Contains(ht) {
+			continue
+		}
+		sum, err := o.Hash(ctx, ht)
+		if err != nil {
+			fs.Debugf(o, "Failed to read %v hash for hash filter: %v", ht, err)
+			continue
+		}
+		if sum == "" {
+			continue
+		}
+		if _, found := set[strings.ToLower(sum)]; found {
+			return sum
+		}
+	}
+	return ""
+}
+
+// includeObject returns whether the hashes of o pass the rules and if
+// not why. An object whose hash can't be read is excluded if there are
+// any --files-from-hashes.
+func (rules *hashRules) includeObject(ctx context.Context, o fs.Object) (bool, Reason) {
+	if len(rules.include) > 0 && matchHash(ctx, rules.include, o) == "" {
+		return false, Reason{Why: reasonHashNotListed}
+	}
+	if len(rules.exclude) > 0 {
+		if sum := matchHash(ctx, rules.exclude, o); sum != "" {
+			return false, Reason{Why: reasonHashListed, Detail: sum}
+		}
+	}
+	return true, Reason{}
+}
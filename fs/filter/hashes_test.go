@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFilterHashes(t *testing.T) {
+	ctx := context.Background()
+	// md5 of "hello" in md5sum format and sha1 of "world"
+	md5s := testFile(t, "5D41402ABC4B2A76B9719D911017C592  hello.txt\n# comment\n")
+	defer func() {
+		require.NoError(t, os.Remove(md5s))
+	}()
+	sha1s := testFile(t, "7c211433f02071597741e6ff5a8ea34789abbf43\n")
+	defer func() {
+		require.NoError(t, os.Remove(sha1s))
+	}()
+
+	hello := object.NewMemoryObject("hello.txt", time.Now(), []byte("hello"))
+	world := object.NewMemoryObject("world.txt", time.Now(), []byte("world"))
+	other := object.NewMemoryObject("other.txt", time.Now(), []byte("other"))
+
+	opt := DefaultOpt
+	opt.FilesFromHash = []string{"md5:" + md5s, "sha1:" + sha1s}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	assert.True(t, f.IncludeObject(ctx, hello))
+	assert.True(t, f.IncludeObject(ctx, world))
+	assert.False(t, f.IncludeObject(ctx, other))
+	include, reason := f.ExplainObject(ctx, other)
+	assert.False(t, include)
+	assert.Equal(t, "hash not in --files-from-hashes", reason.String())
+
+	opt = DefaultOpt
+	opt.ExcludeHash = []string{"md5:" + md5s}
+	f, err = NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.IncludeObject(ctx, hello))
+	assert.True(t, f.IncludeObject(ctx, world))
+	assert.True(t, f.IncludeObject(ctx, other))
+	include, reason = f.ExplainObject(ctx, hello)
+	assert.False(t, include)
+	assert.Equal(t, `hash in --exclude-from-hashes "5d41402abc4b2a76b9719d911017c592"`, reason.String())
+
+	for _, spec := range []string{md5s, "potato:" + md5s, "md5:/does/not/exist"} {
+		opt = DefaultOpt
+		opt.ExcludeHash = []string{spec}
+		_, err = NewFilter(&opt)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,174 @@
+// Reading gitignore style rules from files in the directories
+
+package filter
+
+import (
+	"bufio"
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// ignoreRule is a rule read from an ignore file
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool   // set if the rule starts with ! to include again
+	dirOnly bool   // set if the rule ends with / to match directories only
+	source  string // ignore file and line the rule came from
+}
+
+// parseIgnoreRule converts a line of an ignore file using gitignore
+// syntax into a rule. It returns nil if the line has no rule on it.
+func parseIgnoreRule(line string, ignoreCase bool) (*ignoreRule, error) {
+	r := &ignoreRule{source: line}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return nil, nil
+	}
+	// A leading **/ matches in all directories as does a pattern
+	// without a / in, otherwise the pattern is relative to the
+	// directory of the ignore file.
+	switch {
+	case strings.HasPrefix(line, "**/"):
+		line = line[3:]
+	case strings.HasPrefix(line, "/**/"):
+		line = line[4:]
+	case strings.Contains(line, "/") && !strings.HasPrefix(line, "/"):
+		line = "/" + line
+	}
+	// Braces aren't special in ignore files
+	line = strings.Replace(line, "{", `\{`, -1)
+	line = strings.Replace(line, "}", `\}`, -1)
+	// a/**/b matches a/b too
+	line = strings.Replace(line, "/**/", "{/,/**/}", -1)
+	re, err := GlobToRegexp(line, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+	r.re = re
+	return r, nil
+}
+
+// ignoreFileRules are the rules read from the ignore files in a
+// directory
+type ignoreFileRules []*ignoreRule
+
+// UsesIgnoreFiles returns true if rules are read from ignore files
+// with --ignore-file-name
+func (f *Filter) UsesIgnoreFiles() bool {
+	return len(f.Opt.IgnoreFileName) > 0
+}
+
+// readIgnoreFile reads the rules from the ignore file o
+func (f *Filter) readIgnoreFile(ctx context.Context, o fs.Object) (rules ignoreFileRules, err error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open ignore file")
+	}
+	defer fs.CheckClose(in, &err)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		rule, err := parseIgnoreRule(scanner.Text(), f.Opt.IgnoreCase)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad rule in ignore file %q", o.Remote())
+		}
+		if rule != nil {
+			rule.source = o.Remote() + ": " + rule.source
+			rules = append(rules, rule)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read ignore file %q", o.Remote())
+	}
+	return rules, nil
+}
+
+// LoadIgnoreFiles reads the rules from the ignore files in entries,
+// the listing of dir, so they can be applied to the entries and
+// everything below dir.
+//
+// The rules are stored by the path of dir and only read from the first
+// remote to list it. This means that when syncing, where the source is
+// listed before the destination, the ignore files on the source are
+// used for both.
+func (f *Filter) LoadIgnoreFiles(ctx context.Context, dir string, entries fs.DirEntries) error {
+	if !f.UsesIgnoreFiles() {
+		return nil
+	}
+	f.ignoreMu.Lock()
+	_, loaded := f.ignoreRules[dir]
+	f.ignoreMu.Unlock()
+	if loaded {
+		return nil
+	}
+	var rules ignoreFileRules
+	for _, name := range f.Opt.IgnoreFileName {
+		for _, entry := range entries {
+			o, ok := entry.(fs.Object)
+			if !ok || path.Base(o.Remote()) != name {
+				continue
+			}
+			fs.Debugf(o, "Reading ignore file")
+			fileRules, err := f.readIgnoreFile(ctx, o)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, fileRules...)
+		}
+	}
+	f.ignoreMu.Lock()
+	defer f.ignoreMu.Unlock()
+	if f.ignoreRules == nil {
+		f.ignoreRules = make(map[string]ignoreFileRules)
+	}
+	if _, loaded = f.ignoreRules[dir]; !loaded {
+		f.ignoreRules[dir] = rules
+	}
+	return nil
+}
+
+// ignored returns whether remote is ignored by the rules in the ignore
+// files in the directories above it and if so why.
+//
+// The rules in the deepest directory are checked first, and the last
+// rule which matches in each directory decides.
+func (f *Filter) ignored(remote string, isDir bool) (bool, Reason) {
+	f.ignoreMu.Lock()
+	defer f.ignoreMu.Unlock()
+	dir := remote
+	for dir != "" {
+		dir = parentDir(dir)
+		rules := f.ignoreRules[dir]
+		relative := remote
+		if dir != "" {
+			relative = remote[len(dir)+1:]
+		}
+		for i := len(rules) - 1; i >= 0; i-- {
+			rule := rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.re.MatchString(relative) {
+				return !rule.negate, Reason{Why: reasonIgnoreFile, Detail: rule.source}
+			}
+		}
+	}
+	return false, Reason{}
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	for _, test := range []struct {
+		rule    string
+		match   []string
+		noMatch []string
+		negate  bool
+		dirOnly bool
+	}{
+		{rule: "*.log", match: []string{"a.log", "dir/a.log"}, noMatch: []string{"a.logx", "a.log/b"}},
+		{rule: "/build", match: []string{"build"}, noMatch: []string{"dir/build"}},
+		{rule: "doc/*.txt", match: []string{"doc/a.txt"}, noMatch: []string{"x/doc/a.txt", "doc/x/a.txt"}},
+		{rule: "**/tmp", match: []string{"tmp", "a/b/tmp"}, noMatch: []string{"tmpx"}},
+		{rule: "/**/tmp", match: []string{"tmp", "a/tmp"}},
+		{rule: "a/**/b", match: []string{"a/b", "a/x/b", "a/x/y/b"}, noMatch: []string{"x/a/b"}},
+		{rule: "cache/", match: []string{"cache", "x/cache"}, dirOnly: true},
+		{rule: "!keep.log", match: []string{"keep.log"}, negate: true},
+		{rule: `\!bang`, match: []string{"!bang"}},
+		{rule: `\#hash`, match: []string{"#hash"}},
+		{rule: "{a,b}", match: []string{"{a,b}"}, noMatch: []string{"a"}},
+		{rule: "trailing   ", match: []string{"trailing"}},
+	} {
+		r, err := parseIgnoreRule(test.rule, false)
+		require.NoError(t, err, test.rule)
+		require.NotNil(t, r, test.rule)
+		assert.Equal(t, test.negate, r.negate, test.rule)
+		assert.Equal(t, test.dirOnly, r.dirOnly, test.rule)
+		for _, remote := range test.match {
+			assert.True(t, r.re.MatchString(remote), "%q should match %q", test.rule, remote)
+		}
+		for _, remote := range test.noMatch {
+			assert.False(t, r.re.MatchString(remote), "%q shouldn't match %q", test.rule, remote)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		r, err := parseIgnoreRule(line, false)
+		require.NoError(t, err)
+		assert.Nil(t, r, line)
+	}
+}
+
+func TestFilterIgnoreFiles(t *testing.T) {
+	ctx := context.Background()
+	opt := DefaultOpt
+	opt.IgnoreFileName = []string{".rcloneignore"}
+	opt.ExcludeRule = []string{"*.bak"}
+	f, err := NewFilter(&opt)
+	require.NoError(t, err)
+	assert.False(t, f.InActive())
+	assert.True(t, f.UsesIgnoreFiles())
+
+	ignoreFile := func(remote, rules string) fs.Object {
+		return object.NewMemoryObject(remote, time.Now(), []byte(rules))
+	}
+	obj := func(remote string) fs.Object {
+		return object.NewMemoryObject(remote, time.Now(), nil)
+	}
+	require.NoError(t, f.LoadIgnoreFiles(ctx, "", fs.DirEntries{
+		ignoreFile(".rcloneignore", "*.log\n!keep.log\ncache/\n"),
+	}))
+	require.NoError(t, f.LoadIgnoreFiles(ctx, "sub", fs.DirEntries{
+		ignoreFile("sub/.rcloneignore", "# in sub\n!*.log\n/secret\n"),
+	}))
+	// Rules for a directory are only read once
+	require.NoError(t, f.LoadIgnoreFiles(ctx, "sub", fs.DirEntries{
+		ignoreFile("sub/.rcloneignore", "*"),
+	}))
+
+	for _, test := range []struct {
+		remote string
+		want   bool
+	}{
+		{"file.txt", true},
+		{"file.bak", false},
+		{"file.log", false},
+		{"keep.log", true},
+		{"dir/file.log", false},
+		{"dir/keep.log", true},
+		{"cache", true}, // not a directory
+		{"sub/file.log", true},
+		{"sub/deeper/file.log", true},
+		{"sub/secret", false},
+		{"sub/deeper/secret", true},
+		{"secret", true},
+	} {
+		assert.Equal(t, test.want, f.IncludeObject(ctx, obj(test.remote)), test.remote)
+	}
+
+	include, reason, err := f.ExplainDirectory(ctx, nil, "dir/cache")
+	require.NoError(t, err)
+	assert.False(t, include)
+	assert.Equal(t, `matched ignore file rule ".rcloneignore: cache/"`, reason.String())
+	include, _, err = f.ExplainDirectory(ctx, nil, "dir/other")
+	require.NoError(t, err)
+	assert.True(t, include)
+
+	include, reason = f.ExplainObject(ctx, obj("sub/secret"))
+	assert.False(t, include)
+	assert.Equal(t, `matched ignore file rule "sub/.rcloneignore: /secret"`, reason.String())
+}
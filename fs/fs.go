@@ -51,6 +51,7 @@ var (
 	ErrorCantCopy                    = errors.New("can't copy object - incompatible remotes")
 	ErrorCantMove                    = errors.New("can't move object - incompatible remotes")
 	ErrorCantDirMove                 = errors.New("can't move directory - incompatible remotes")
+	ErrorCantHardLink                = errors.New("can't hard link object - incompatible remotes")
 	ErrorCantUploadEmptyFiles        = errors.New("can't upload empty files to this remote")
 	ErrorDirExists                   = errors.New("can't copy directory - destination already exists")
 	ErrorCantSetModTime              = errors.New("can't set modified time")
@@ -67,6 +68,7 @@ var (
 	ErrorOverlapping                 = errors.New("can't sync or move files on overlapping remotes")
 	ErrorDirectoryNotEmpty           = errors.New("directory not empty")
 	ErrorImmutableModified           = errors.New("immutable file modified")
+	ErrorDestConflict                = errors.New("destination file changed since the last sync")
 	ErrorPermissionDenied            = errors.New("permission denied")
 	ErrorCantShareDirectories        = errors.New("this backend can't share directories with link")
 	ErrorNotImplemented              = errors.New("optional feature not implemented")
@@ -415,6 +417,66 @@ type GetTierer interface {
 	GetTier() string
 }
 
+// BlockHasher is an optional interface for Object
+type BlockHasher interface {
+	// BlockHashes returns the MD5 of each blockSize block of the
+	// Object in order, calculated where the Object is stored
+	BlockHashes(ctx context.Context, blockSize int64) ([]string, error)
+}
+
+// PartialUpdater is an optional interface for Object
+type PartialUpdater interface {
+	// OpenPartialUpdate opens the existing Object for random
+	// access writes without truncating it. The Object is
+	// truncated to size when the returned handle is closed.
+	OpenPartialUpdate(ctx context.Context, size int64) (WriterAtCloser, error)
+}
+
+// PartialUpdateAborter is an optional interface for the WriterAtCloser
+// returned by OpenPartialUpdate
+type PartialUpdateAborter interface {
+	// Abort discards the writes, leaving the Object as it was,
+	// and is called instead of Close if the update fails
+	Abort() error
+}
+
+// DataRanger is an optional interface for Object
+type DataRanger interface {
+	// DataRanges returns the parts of the Object which contain
+	// data in order. The rest of the Object is holes which read
+	// as zeros, as in a sparse file.
+	DataRanges(ctx context.Context) ([]RangeOption, error)
+}
+
+// OSFileOpener is an optional interface for Object
+type OSFileOpener interface {
+	// OpenOSFile opens the file on the local disk which the Object
+	// is stored in for reading, so its data can be sent without
+	// copying it through rclone, eg with sendfile(2).
+	OpenOSFile(ctx context.Context) (*os.File, error)
+}
+
+// Ownerer is an optional interface for Object
+type Ownerer interface {
+	// Owner returns the owner of the Object, eg an email address,
+	// or "" if not known
+	Owner(ctx context.Context) string
+}
+
+// Metadataer is an optional interface for Object
+type Metadataer interface {
+	// Metadata returns the metadata of the Object translated to
+	// the standard keys where possible
+	Metadata(ctx context.Context) (Metadata, error)
+}
+
+// SetMetadataer is an optional interface for Object
+type SetMetadataer interface {
+	// SetMetadata sets as much of the metadata passed in on the
+	// Object as the backend can store, ignoring the rest
+	SetMetadata(ctx context.Context, metadata Metadata) error
+}
+
 // FullObjectInfo contains all the read-only optional interfaces
 //
 // Use for checking making wrapping ObjectInfos implement everything
@@ -501,6 +563,30 @@ type WriterAtCloser interface {
 	io.Closer
 }
 
+// ChunkWriterInfo describes how the chunks passed to a ChunkWriter
+// should be written
+type ChunkWriterInfo struct {
+	ChunkSize   int64 // size of the chunks - all but the last must be this size
+	Concurrency int   // how many chunks the backend would like written at once
+}
+
+// ChunkWriter is returned by OpenChunkWriter to write the chunks of
+// an object in any order and at once, assembling them when it is
+// closed
+type ChunkWriter interface {
+	// WriteChunk writes chunk number chunkNumber (from 0) which
+	// starts at chunkNumber * ChunkSize in the object, returning
+	// the number of bytes written. It may be retried so reader
+	// may be read more than once.
+	WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error)
+
+	// Close assembles the chunks written into the object
+	Close(ctx context.Context) error
+
+	// Abort cancels the upload, removing the chunks written
+	Abort(ctx context.Context) error
+}
+
 // Features describe the optional features of the Fs
 type Features struct {
 	// Feature flags, whether Fs
@@ -634,12 +720,29 @@ type Features struct {
 	// It truncates any existing object
 	OpenWriterAt func(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 
+	// OpenChunkWriter returns a ChunkWriter to write the object
+	// at remote from src in chunks at once
+	//
+	// src.Size() must be known. It replaces any existing object
+	// when it is closed.
+	OpenChunkWriter func(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (ChunkWriterInfo, ChunkWriter, error)
+
 	// UserInfo returns info about the connected user
 	UserInfo func(ctx context.Context) (map[string]string, error)
 
 	// Disconnect the current user
 	Disconnect func(ctx context.Context) error
 
+	// DeleteBatch deletes the objects passed in with as few calls
+	// as possible, returning an error for each object in order
+	DeleteBatch func(ctx context.Context, objs []Object) []error
+
+	// HardLink makes remote a hard link to src, replacing any
+	// existing object at remote
+	//
+	// If it isn't possible then return fs.ErrorCantHardLink
+	HardLink func(ctx context.Context, src Object, remote string) (Object, error)
+
 	// Command the backend to run a named command
 	//
 	// The command run is name
@@ -765,12 +868,21 @@ func (ft *Features) Fill(f Fs) *Features {
 	if do, ok := f.(OpenWriterAter); ok {
 		ft.OpenWriterAt = do.OpenWriterAt
 	}
+	if do, ok := f.(OpenChunkWriterer); ok {
+		ft.OpenChunkWriter = do.OpenChunkWriter
+	}
 	if do, ok := f.(UserInfoer); ok {
 		ft.UserInfo = do.UserInfo
 	}
 	if do, ok := f.(Disconnecter); ok {
 		ft.Disconnect = do.Disconnect
 	}
+	if do, ok := f.(BatchDeleter); ok {
+		ft.DeleteBatch = do.DeleteBatch
+	}
+	if do, ok := f.(HardLinker); ok {
+		ft.HardLink = do.HardLink
+	}
 	if do, ok := f.(Commander); ok {
 		ft.Command = do.Command
 	}
@@ -847,12 +959,21 @@ func (ft *Features) Mask(f Fs) *Features {
 	if mask.OpenWriterAt == nil {
 		ft.OpenWriterAt = nil
 	}
+	if mask.OpenChunkWriter == nil {
+		ft.OpenChunkWriter = nil
+	}
 	if mask.UserInfo == nil {
 		ft.UserInfo = nil
 	}
 	if mask.Disconnect == nil {
 		ft.Disconnect = nil
 	}
+	if mask.DeleteBatch == nil {
+		ft.DeleteBatch = nil
+	}
+	if mask.HardLink == nil {
+		ft.HardLink = nil
+	}
 	// Command is always local so we don't mask it
 	return ft.DisableList(Config.DisableFeatures)
 }
@@ -1063,6 +1184,16 @@ type OpenWriterAter interface {
 	OpenWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 }
 
+// OpenChunkWriterer is an optional interface for Fs
+type OpenChunkWriterer interface {
+	// OpenChunkWriter returns a ChunkWriter to write the object
+	// at remote from src in chunks at once
+	//
+	// src.Size() must be known. It replaces any existing object
+	// when it is closed.
+	OpenChunkWriter(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (ChunkWriterInfo, ChunkWriter, error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
@@ -1075,6 +1206,26 @@ type Disconnecter interface {
 	Disconnect(ctx context.Context) error
 }
 
+// BatchDeleter is an optional interface for Fs
+type BatchDeleter interface {
+	// DeleteBatch deletes the objects passed in with as few calls
+	// as possible, returning an error for each object in order
+	//
+	// The objects will all be from this Fs
+	DeleteBatch(ctx context.Context, objs []Object) []error
+}
+
+// HardLinker is an optional interface for Fs
+type HardLinker interface {
+	// HardLink makes remote a hard link to src, replacing any
+	// existing object at remote
+	//
+	// Will only be called if src.Fs().Name() == f.Name()
+	//
+	// If it isn't possible then return fs.ErrorCantHardLink
+	HardLink(ctx context.Context, src Object, remote string) (Object, error)
+}
+
 // CommandHelp describes a single backend Command
 //
 // These are automatically inserted in the docs
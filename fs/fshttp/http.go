@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/sched"
 	"github.com/rclone/rclone/lib/structs"
 	"golang.org/x/net/publicsuffix"
 	"golang.org/x/time/rate"
@@ -306,12 +307,17 @@ func cleanAuths(buf []byte) []byte {
 // RoundTrip implements the RoundTripper interface.
 func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
 	// Get transactions per second token first if limiting
-	if tpsBucket != nil {
+	if tpsBucket != nil && !sched.IgnoreTPSLimit(req.Context()) {
 		tbErr := tpsBucket.Wait(req.Context())
 		if tbErr != nil && tbErr != context.Canceled {
 			fs.Errorf(nil, "HTTP token bucket error: %v", tbErr)
 		}
 	}
+	// Then wait for the tpslimit of the remote if it has one
+	err = sched.PaceRequest(req.Context())
+	if err != nil {
+		return nil, err
+	}
 	// Force user agent
 	req.Header.Set("User-Agent", t.userAgent)
 	// Set user defined headers
@@ -349,6 +355,9 @@ func (t *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error
 	}
 	if err == nil {
 		checkServerTime(req, resp)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			sched.TunerFromContext(req.Context()).Throttled()
+		}
 	}
 	return resp, err
 }
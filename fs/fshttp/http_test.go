@@ -1,9 +1,20 @@
 package fshttp
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/sched"
+	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 func TestCleanAuth(t *testing.T) {
@@ -45,3 +56,64 @@ func TestCleanAuths(t *testing.T) {
 		assert.Equal(t, test.want, got, test.in)
 	}
 }
+
+func TestRoundTripRemoteTPSLimit(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer ts.Close()
+
+	name := "fshttp-tpslimit-test"
+	env := fs.ConfigToEnv(name, "tpslimit")
+	require.NoError(t, os.Setenv(env, "0.001"))
+	defer func() { _ = os.Unsetenv(env) }()
+
+	client := NewClient(fs.Config)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ctx = sched.WithPace(ctx, mockfs.NewFs(name, ""), sched.OpList)
+
+	// Each request waits for the limit of the remote, not just the
+	// first one of an operation
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if i == 0 {
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+		} else {
+			assert.Error(t, err)
+		}
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestRoundTripWithoutTPSLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	oldTPSBucket := tpsBucket
+	defer func() { tpsBucket = oldTPSBucket }()
+	client := NewClient(fs.Config)
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		ctx       context.Context
+		wantToken bool
+	}{
+		{ctx, true},
+		{sched.WithoutTPSLimit(ctx), false},
+	} {
+		tpsBucket = rate.NewLimiter(rate.Limit(0.001), 1)
+		req, err := http.NewRequestWithContext(test.ctx, "GET", ts.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		// The request only takes a token from the global limiter
+		// if it isn't exempt from it
+		assert.Equal(t, !test.wantToken, tpsBucket.Allow())
+	}
+}
@@ -3,15 +3,19 @@ package hash
 import (
 	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
 	"hash/crc32"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/jzelinskie/whirlpool"
 	"github.com/pkg/errors"
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
 )
 
 // Type indicates a standard hashing algorithm
@@ -60,6 +64,15 @@ var (
 
 	// CRC32 indicates CRC-32 support
 	CRC32 Type
+
+	// SHA256 indicates SHA-256 support
+	SHA256 Type
+
+	// BLAKE3 indicates BLAKE3 support
+	BLAKE3 Type
+
+	// XXH3 indicates XXH3 (64 bit) support
+	XXH3 Type
 )
 
 func init() {
@@ -67,6 +80,9 @@ func init() {
 	SHA1 = RegisterHash("SHA-1", 40, sha1.New)
 	Whirlpool = RegisterHash("Whirlpool", 128, whirlpool.New)
 	CRC32 = RegisterHash("CRC-32", 8, func() hash.Hash { return crc32.NewIEEE() })
+	SHA256 = RegisterHash("SHA-256", 64, sha256.New)
+	BLAKE3 = RegisterHash("BLAKE3", 64, func() hash.Hash { return blake3.New() })
+	XXH3 = RegisterHash("XXH3", 16, func() hash.Hash { return xxh3.New() })
 }
 
 // Supported returns a set of all the supported hashes by
@@ -97,21 +113,38 @@ func Stream(r io.Reader) (map[Type]string, error) {
 }
 
 // StreamTypes will calculate hashes of the requested hash types.
+//
+// The data is hashed with a MultiHasher so reading the next data
+// overlaps hashing the last.
 func StreamTypes(r io.Reader, set Set) (map[Type]string, error) {
-	hashers, err := fromTypes(set)
+	hasher, err := NewMultiHasherTypes(set)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = io.Copy(toMultiWriter(hashers), r)
+	_, err = io.Copy(hasher, r)
 	if err != nil {
 		return nil, err
 	}
-	var ret = make(map[Type]string)
-	for k, v := range hashers {
-		ret[k] = hex.EncodeToString(v.Sum(nil))
+	return hasher.Sums(), nil
+}
+
+// StreamBlocks will calculate the MD5 of each blockSize block read
+// from r in order.
+func StreamBlocks(r io.Reader, blockSize int64) (sums []string, err error) {
+	h := md5.New()
+	for {
+		h.Reset()
+		n, err := io.CopyN(h, r, blockSize)
+		if n > 0 {
+			sums = append(sums, hex.EncodeToString(h.Sum(nil)))
+		}
+		if err == io.EOF {
+			return sums, nil
+		} else if err != nil {
+			return nil, err
+		}
 	}
-	return ret, nil
 }
 
 // String returns a string representation of the hash type.
@@ -193,12 +226,31 @@ func toMultiWriter(h map[Type]hash.Hash) io.Writer {
 	return io.MultiWriter(w...)
 }
 
+// asyncMinSize is the smallest write which the MultiHasher hashes in
+// the background
+const asyncMinSize = 16 * 1024
+
+// maxQueued is the most writes which can be queued to be hashed in the
+// background before Write waits
+const maxQueued = 4
+
 // A MultiHasher will construct various hashes on
 // all incoming writes.
+//
+// Large writes are copied and hashed in the background, with each hash
+// type hashed in parallel, so the writer can get on with reading the
+// next data while the last is hashed. Sums waits for the hashing to
+// finish.
 type MultiHasher struct {
 	w    io.Writer
 	size int64
 	h    map[Type]hash.Hash // Hashes
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte // writes waiting to be hashed, the first being hashed
+	free    [][]byte // buffers to reuse for the queue
+	running bool     // set if the queue is being hashed
 }
 
 // NewMultiHasher will return a hash writer that will write all
@@ -219,18 +271,86 @@ func NewMultiHasherTypes(set Set) (*MultiHasher, error) {
 		return nil, err
 	}
 	m := MultiHasher{h: hashers, w: toMultiWriter(hashers)}
+	m.cond = sync.NewCond(&m.mu)
 	return &m, nil
 }
 
 func (m *MultiHasher) Write(p []byte) (n int, err error) {
-	n, err = m.w.Write(p)
-	m.size += int64(n)
-	return n, err
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.size += int64(len(p))
+	if (len(m.h) == 1 || len(p) < asyncMinSize) && !m.running {
+		// Not worth hashing in the background - copying the
+		// data costs more than a single hash saves
+		m.hash(p)
+		return len(p), nil
+	}
+	for len(m.queue) >= maxQueued {
+		m.cond.Wait()
+	}
+	var buf []byte
+	if n := len(m.free); n > 0 && cap(m.free[n-1]) >= len(p) {
+		buf, m.free = m.free[n-1][:len(p)], m.free[:n-1]
+	} else {
+		buf = make([]byte, len(p))
+	}
+	copy(buf, p)
+	m.queue = append(m.queue, buf)
+	if !m.running {
+		m.running = true
+		go m.hashQueue()
+	}
+	return len(p), nil
+}
+
+// hash hashes p with all the hashers, in parallel if worth it
+func (m *MultiHasher) hash(p []byte) {
+	if len(m.h) == 1 || len(p) < asyncMinSize {
+		_, _ = m.w.Write(p)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, h := range m.h {
+		wg.Add(1)
+		go func(h hash.Hash) {
+			defer wg.Done()
+			_, _ = h.Write(p)
+		}(h)
+	}
+	wg.Wait()
+}
+
+// hashQueue hashes the queued writes in order until there are none
+// left
+func (m *MultiHasher) hashQueue() {
+	m.mu.Lock()
+	for len(m.queue) > 0 {
+		buf := m.queue[0]
+		m.mu.Unlock()
+		m.hash(buf)
+		m.mu.Lock()
+		m.queue[0] = nil
+		m.queue = m.queue[1:]
+		if len(m.free) < maxQueued {
+			m.free = append(m.free, buf)
+		}
+		m.cond.Broadcast()
+	}
+	m.running = false
+	m.cond.Broadcast()
+	m.mu.Unlock()
 }
 
 // Sums returns the sums of all accumulated hashes as hex encoded
 // strings.
 func (m *MultiHasher) Sums() map[Type]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Wait for the background hashing to finish
+	for m.running {
+		m.cond.Wait()
+	}
+	m.free = nil
 	dst := make(map[Type]string)
 	for k, v := range m.h {
 		dst[k] = hex.EncodeToString(v.Sum(nil))
@@ -240,6 +360,8 @@ func (m *MultiHasher) Sums() map[Type]string {
 
 // Size returns the number of bytes written
 func (m *MultiHasher) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.size
 }
 
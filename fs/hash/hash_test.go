@@ -2,6 +2,8 @@ package hash_test
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"io"
 	"log"
 	"testing"
@@ -75,6 +77,9 @@ var hashTestSet = []hashTest{
 			hash.SHA1:      "3ab6543c08a75f292a5ecedac87ec41642d12166",
 			hash.Whirlpool: "eddf52133d4566d763f716e853d6e4efbabd29e2c2e63f56747b1596172851d34c2df9944beb6640dbdbe3d9b4eb61180720a79e3d15baff31c91e43d63869a4",
 			hash.CRC32:     "a6041d7e",
+			hash.SHA256:    "c839e57675862af5c21bd0a15413c3ec579e0d5522dab600bc6c3489b05b8f54",
+			hash.BLAKE3:    "0a7276a407a3be1b4d31488318ee05a335aad5a3b82c4420e592a8178c9e86bb",
+			hash.XXH3:      "4b83b0c51c543525",
 		},
 	},
 	// Empty data set
@@ -85,6 +90,9 @@ var hashTestSet = []hashTest{
 			hash.SHA1:      "da39a3ee5e6b4b0d3255bfef95601890afd80709",
 			hash.Whirlpool: "19fa61d75522a4669b44e39c1d2e1726c530232130d407f89afee0964997f7a73e83be698b288febcf88e3e03c4f0757ea8964e59b63d93708b138cc42a66eb3",
 			hash.CRC32:     "00000000",
+			hash.SHA256:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			hash.BLAKE3:    "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+			hash.XXH3:      "2d06800538d394c2",
 		},
 	},
 }
@@ -126,6 +134,47 @@ func TestMultiHasherTypes(t *testing.T) {
 	}
 }
 
+func TestMultiHasherBackground(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	want, err := hash.StreamTypes(bytes.NewBuffer(data), hash.Supported())
+	require.NoError(t, err)
+	wantSHA1 := sha1.Sum(data)
+	assert.Equal(t, hex.EncodeToString(wantSHA1[:]), want[hash.SHA1])
+
+	// Write a mixture of small and large writes from a buffer which
+	// is overwritten after each write
+	for _, set := range []hash.Set{hash.NewHashSet(hash.SHA1), hash.Supported()} {
+		mh, err := hash.NewMultiHasherTypes(set)
+		require.NoError(t, err)
+		buf := make([]byte, 256*1024)
+		for i, size := 0, 1; i < len(data); size *= 3 {
+			if size > len(buf) {
+				size = 1
+			}
+			if i+size > len(data) {
+				size = len(data) - i
+			}
+			copy(buf, data[i:i+size])
+			n, err := mh.Write(buf[:size])
+			require.NoError(t, err)
+			assert.Equal(t, size, n)
+			for j := range buf[:size] {
+				buf[j] = 0xFF
+			}
+			i += size
+		}
+		assert.Equal(t, int64(len(data)), mh.Size())
+		sums := mh.Sums()
+		assert.Equal(t, set.Count(), len(sums))
+		for ht, sum := range sums {
+			assert.Equal(t, want[ht], sum, ht.String())
+		}
+	}
+}
+
 func TestHashStream(t *testing.T) {
 	for _, test := range hashTestSet {
 		sums, err := hash.Stream(bytes.NewBuffer(test.input))
@@ -154,6 +203,20 @@ func TestHashStreamTypes(t *testing.T) {
 	}
 }
 
+func TestStreamBlocks(t *testing.T) {
+	sums, err := hash.StreamBlocks(bytes.NewBufferString("aaaabbbbcc"), 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"74b87337454200d4d33f80c4663dc5e5", // aaaa
+		"65ba841e01d6db7733e90a5b7f9e6f80", // bbbb
+		"e0323a9039add2978bf5b49550572c7c", // cc
+	}, sums)
+
+	sums, err = hash.StreamBlocks(bytes.NewBufferString(""), 4)
+	require.NoError(t, err)
+	assert.Len(t, sums, 0)
+}
+
 func TestHashSetStringer(t *testing.T) {
 	h := hash.NewHashSet(hash.SHA1, hash.MD5)
 	assert.Equal(t, h.String(), "[MD5, SHA-1]")
@@ -0,0 +1,180 @@
+// Package hashdb is a persistent database of object hashes
+//
+// It remembers a hash of each object recorded in it along with the
+// size and modification time the object had when it was recorded.
+// The hash is only returned while the object still has that size and
+// modification time. This means hashes can be known for objects on
+// remotes which don't support that hash type, for example the MD5 of
+// the source file an object was copied from.
+package hashdb
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/hash"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket is the name of the bolt bucket the hashes are kept in
+const bucket = "hashes"
+
+// DB is an open hash database
+type DB struct {
+	db   *bolt.DB
+	path string // absolute path of the database
+	refs int    // number of Opens not yet closed, protected by openMu
+}
+
+// The databases open in this process by path
+//
+// The database file is locked while it is open, so the operations in
+// a process, eg the hashdb/warm rc job and a sync, share the one open
+// database rather than waiting for each other.
+var (
+	openMu sync.Mutex
+	opened = map[string]*DB{}
+)
+
+// entry is what is stored for each object
+type entry struct {
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"modTime"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+// DefaultPath returns the path of the hash database in the cache
+// directory
+func DefaultPath() string {
+	return filepath.Join(config.CacheDir, "hashdb", "hashes.db")
+}
+
+// Open opens the hash database at path, creating it if it doesn't
+// exist
+func Open(path string) (*DB, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	openMu.Lock()
+	defer openMu.Unlock()
+	if d := opened[path]; d != nil {
+		d.refs++
+		return d, nil
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make hash database directory")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open hash database %q", path)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "failed to initialise hash database")
+	}
+	d := &DB{db: db, path: path, refs: 1}
+	opened[path] = d
+	return d, nil
+}
+
+// Close closes the hash database once all the Opens of it are closed
+func (d *DB) Close() error {
+	openMu.Lock()
+	defer openMu.Unlock()
+	d.refs--
+	if d.refs > 0 {
+		return nil
+	}
+	delete(opened, d.path)
+	return d.db.Close()
+}
+
+// key returns the database key for o
+//
+// This uses the full path of the object so it is the same whichever
+// directory of the remote it was listed from.
+func key(o fs.ObjectInfo) []byte {
+	f := o.Fs()
+	return []byte(f.Name() + ":" + path.Join(f.Root(), o.Remote()))
+}
+
+// get reads the entry for o or returns nil if there isn't one
+func (d *DB) get(o fs.ObjectInfo) (e *entry, err error) {
+	err = d.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(bucket)).Get(key(o))
+		if data == nil {
+			return nil
+		}
+		e = new(entry)
+		return json.Unmarshal(data, e)
+	})
+	return e, err
+}
+
+// Get returns the hash of type ht recorded for o. It returns false if
+// there isn't one or o has changed size or modification time since
+// it was recorded.
+func (d *DB) Get(ctx context.Context, o fs.ObjectInfo, ht hash.Type) (sum string, ok bool) {
+	e, err := d.get(o)
+	if err != nil {
+		fs.Debugf(o, "Failed to read hash database: %v", err)
+		return "", false
+	}
+	if e == nil || e.Size != o.Size() || !e.ModTime.Equal(o.ModTime(ctx)) {
+		return "", false
+	}
+	sum, ok = e.Hashes[ht.String()]
+	return sum, ok
+}
+
+// Put records sum as the hash of type ht for o
+//
+// Any hashes of other types recorded for o are kept if o hasn't
+// changed since they were recorded.
+func (d *DB) Put(ctx context.Context, o fs.ObjectInfo, ht hash.Type, sum string) error {
+	size, modTime := o.Size(), o.ModTime(ctx)
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		k := key(o)
+		e := new(entry)
+		if data := b.Get(k); data != nil {
+			if err := json.Unmarshal(data, e); err != nil {
+				e = new(entry)
+			}
+		}
+		if e.Size != size || !e.ModTime.Equal(modTime) || e.Hashes == nil {
+			e = &entry{
+				Size:    size,
+				ModTime: modTime,
+				Hashes:  make(map[string]string, 1),
+			}
+		}
+		e.Hashes[ht.String()] = sum
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return b.Put(k, data)
+	})
+}
+
+// Delete removes anything recorded for o
+func (d *DB) Delete(o fs.ObjectInfo) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Delete(key(o))
+	})
+}
@@ -0,0 +1,157 @@
+package hashdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-hashdb-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	dbPath := filepath.Join(dir, "hashes.db")
+	db, err := Open(dbPath)
+	require.NoError(t, err)
+
+	f := mockfs.NewFs("remote", "root")
+	t1 := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	o := object.NewStaticObjectInfo("dir/file.txt", t1, 100, true, nil, f)
+
+	_, ok := db.Get(ctx, o, hash.MD5)
+	assert.False(t, ok)
+
+	require.NoError(t, db.Put(ctx, o, hash.MD5, "md5sum"))
+	require.NoError(t, db.Put(ctx, o, hash.SHA1, "sha1sum"))
+	sum, ok := db.Get(ctx, o, hash.MD5)
+	assert.True(t, ok)
+	assert.Equal(t, "md5sum", sum)
+	sum, ok = db.Get(ctx, o, hash.SHA1)
+	assert.True(t, ok)
+	assert.Equal(t, "sha1sum", sum)
+
+	// Same object listed from a different root
+	f2 := mockfs.NewFs("remote", "")
+	o2 := object.NewStaticObjectInfo("root/dir/file.txt", t1, 100, true, nil, f2)
+	sum, ok = db.Get(ctx, o2, hash.MD5)
+	assert.True(t, ok)
+	assert.Equal(t, "md5sum", sum)
+
+	// Persists when reopened
+	require.NoError(t, db.Close())
+	db, err = Open(dbPath)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close())
+	}()
+	_, ok = db.Get(ctx, o, hash.MD5)
+	assert.True(t, ok)
+
+	// Changed objects don't match and forget the old hashes
+	changed := object.NewStaticObjectInfo("dir/file.txt", t1.Add(time.Second), 100, true, nil, f)
+	_, ok = db.Get(ctx, changed, hash.MD5)
+	assert.False(t, ok)
+	require.NoError(t, db.Put(ctx, changed, hash.MD5, "newsum"))
+	_, ok = db.Get(ctx, changed, hash.SHA1)
+	assert.False(t, ok)
+
+	require.NoError(t, db.Delete(changed))
+	_, ok = db.Get(ctx, changed, hash.MD5)
+	assert.False(t, ok)
+}
+
+func TestOpenShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-hashdb-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	dbPath := filepath.Join(dir, "hashes.db")
+
+	// A second Open in the process shares the database rather than
+	// waiting for its lock
+	db1, err := Open(dbPath)
+	require.NoError(t, err)
+	db2, err := Open(dbPath)
+	require.NoError(t, err)
+	assert.True(t, db1 == db2)
+
+	// It stays open until the last Close
+	require.NoError(t, db1.Close())
+	_, ok := db2.Get(context.Background(), object.NewStaticObjectInfo("file.txt", time.Now(), 1, true, nil, mockfs.NewFs("remote", "")), hash.MD5)
+	assert.False(t, ok)
+	require.NoError(t, db2.Close())
+	openMu.Lock()
+	assert.Len(t, opened, 0)
+	openMu.Unlock()
+}
+
+func TestWarm(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-hashdb-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	dataDir := filepath.Join(dir, "data")
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "sub"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "one.txt"), []byte("one"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dataDir, "sub", "two.txt"), []byte("two"), 0666))
+	f, err := fs.NewFs(dataDir)
+	require.NoError(t, err)
+	dbPath := filepath.Join(dir, "hashes.db")
+	db, err := Open(dbPath)
+	require.NoError(t, err)
+
+	stats, err := db.Warm(ctx, f, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, WarmStats{Hashed: 2}, stats)
+	o, err := f.NewObject(ctx, "sub/two.txt")
+	require.NoError(t, err)
+	sum, ok := db.Get(ctx, o, hash.MD5)
+	assert.True(t, ok)
+	assert.Equal(t, "b8a9f715dbb64fd5c56e7783c6820a61", sum)
+
+	// Only the missing hashes are calculated
+	stats, err = db.Warm(ctx, f, hash.MD5)
+	require.NoError(t, err)
+	assert.Equal(t, WarmStats{Known: 2}, stats)
+	require.NoError(t, db.Close())
+
+	// The rc call does the same
+	call := rc.Calls.Get("hashdb/warm")
+	require.NotNil(t, call)
+	out, err := call.Fn(ctx, rc.Params{
+		"fs":       dataDir,
+		"hashType": "MD5",
+		"db":       dbPath,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"hashed": int64(0), "known": int64(2), "errors": int64(0)}, out)
+
+	// Until it is stopped with an interval
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = call.Fn(ctx, rc.Params{
+		"fs":       dataDir,
+		"db":       dbPath,
+		"interval": "10ms",
+	})
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+}
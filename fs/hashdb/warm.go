@@ -0,0 +1,165 @@
+package hashdb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/sched"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// WarmStats are the results of a pass of Warm
+type WarmStats struct {
+	Hashed int64 // hashes calculated and recorded
+	Known  int64 // hashes already recorded
+	Errors int64 // hashes which couldn't be calculated or recorded
+}
+
+// Warm walks f calculating the hashes of type ht which aren't recorded
+// in d and recording them, so later checks and syncs find them there.
+//
+// Each hash is calculated in a transfer slot from sched with the
+// priority of ctx, so warming gives way to higher priority transfers.
+// Errors hashing an object are logged and counted and the walk
+// carries on.
+func (d *DB) Warm(ctx context.Context, f fs.Fs, ht hash.Type) (stats WarmStats, err error) {
+	if !f.Hashes().Contains(ht) {
+		return stats, errors.Errorf("%v doesn't support %v hashes", f, ht)
+	}
+	err = walk.ListR(ctx, f, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		var err error
+		entries.ForObject(func(o fs.Object) {
+			if err != nil {
+				return
+			}
+			if _, ok := d.Get(ctx, o, ht); ok {
+				atomic.AddInt64(&stats.Known, 1)
+				return
+			}
+			var release func()
+			release, err = sched.Acquire(ctx)
+			if err != nil {
+				return
+			}
+			sum, hashErr := o.Hash(ctx, ht)
+			release()
+			if hashErr == nil && sum != "" {
+				hashErr = d.Put(ctx, o, ht, sum)
+			}
+			switch {
+			case hashErr != nil:
+				fs.Errorf(o, "Failed to record %v in hash database: %v", ht, hashErr)
+				atomic.AddInt64(&stats.Errors, 1)
+			case sum != "":
+				atomic.AddInt64(&stats.Hashed, 1)
+			}
+		})
+		return err
+	})
+	return stats, err
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "hashdb/warm",
+		AuthRequired: true,
+		Fn:           rcWarm,
+		Title:        "Record the missing hashes of a remote in the hash database",
+		Help: `This walks a remote calculating the hashes which aren't
+recorded in the hash database used by "check --cache-db" and
+"--track-renames-db" and records them, so those find the hashes
+already there.
+
+This takes the following parameters
+
+- fs - a remote name string eg "drive:path"
+- hashType - the hash type to record, eg "MD5" (default the first hash the remote supports)
+- interval - if set, walk the remote again this long after each walk finishes, eg "1h"
+- db - path of the hash database (default the one in the cache directory)
+
+Unless "_priority" is set the hashes are calculated at one below
+--priority so they give way to other transfers.
+
+With "interval" set this keeps walking the remote until the job is
+stopped, so run it with "_async": true and stop it with "job/stop".
+
+Returns
+
+- hashed - number of hashes recorded
+- known - number of hashes which were already recorded
+- errors - number of hashes which failed
+`,
+	})
+}
+
+// rcWarm runs the hashdb/warm rc call
+func rcWarm(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := rc.GetFs(in)
+	if err != nil {
+		return nil, err
+	}
+	ht := f.Hashes().GetOne()
+	hashType, err := in.GetString("hashType")
+	if err == nil {
+		err = ht.Set(hashType)
+	}
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if ht == hash.None {
+		return nil, errors.Errorf("%v doesn't support any hashes", f)
+	}
+	interval, err := in.GetDuration("interval")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	path, err := in.GetString("db")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if path == "" {
+		path = DefaultPath()
+	}
+	if _, err := in.Get("_priority"); rc.IsErrParamNotFound(err) {
+		ctx = sched.WithPriority(ctx, fs.Config.Priority-1)
+	}
+	db, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := db.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	var total WarmStats
+	for {
+		stats, err := db.Warm(ctx, f, ht)
+		total.Hashed += stats.Hashed
+		total.Known += stats.Known
+		total.Errors += stats.Errors
+		if err != nil {
+			return nil, err
+		}
+		fs.Infof(f, "hashdb/warm: recorded %d %v hashes, %d already recorded, %d errors", stats.Hashed, ht, stats.Known, stats.Errors)
+		if interval <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+	return rc.Params{
+		"hashed": total.Hashed,
+		"known":  total.Known,
+		"errors": total.Errors,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package list
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Directory listings are limited globally by --list-concurrency if set
+// and per remote by the list_concurrency config key so that recursive
+// listings of deep trees can run many listings on high latency
+// remotes without overwhelming remotes which can't take it. Without
+// these each traversal runs --checkers listings as it always has.
+//
+// When the limits are reached the listing go routines block until a
+// listing finishes, which provides back pressure to the traversal.
+var (
+	limitMu      sync.Mutex
+	globalLimit  chan struct{} // nil if --list-concurrency isn't set
+	remoteLimits = map[string]chan struct{}{}
+)
+
+// Concurrency returns the number of directory listings which should
+// be run in parallel by each traversal
+func Concurrency() int {
+	if fs.Config.ListConcurrency > 0 {
+		return fs.Config.ListConcurrency
+	}
+	if fs.Config.Checkers > 0 {
+		return fs.Config.Checkers
+	}
+	return 1
+}
+
+// remoteConcurrency returns the list_concurrency set for the remote
+// f or 0 if not set.
+//
+// This can be set in the config file or with the
+// RCLONE_CONFIG_<REMOTE>_LIST_CONCURRENCY environment variable.
+func remoteConcurrency(f fs.Fs) int {
+	value, ok := fs.ConfigMap(nil, f.Name()).Get("list_concurrency")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		fs.Errorf(f, "Ignoring bad list_concurrency %q", value)
+		return 0
+	}
+	return n
+}
+
+// getLimits returns the semaphores limiting listings of f, creating
+// them if necessary. Either may be nil if not limited.
+func getLimits(f fs.Fs) (global, remote chan struct{}) {
+	limitMu.Lock()
+	defer limitMu.Unlock()
+	if n := fs.Config.ListConcurrency; n <= 0 {
+		globalLimit = nil
+	} else if globalLimit == nil || cap(globalLimit) != n {
+		globalLimit = make(chan struct{}, n)
+	}
+	name := f.Name()
+	remote, ok := remoteLimits[name]
+	if !ok {
+		if n := remoteConcurrency(f); n > 0 {
+			remote = make(chan struct{}, n)
+		}
+		remoteLimits[name] = remote
+	}
+	return globalLimit, remote
+}
+
+// acquire waits until a listing of f is allowed, returning a function
+// to call when the listing is finished.
+func acquire(ctx context.Context, f fs.Fs) (release func(), err error) {
+	global, remote := getLimits(f)
+	if remote != nil {
+		select {
+		case remote <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if global != nil {
+		select {
+		case global <- struct{}{}:
+		case <-ctx.Done():
+			if remote != nil {
+				<-remote
+			}
+			return nil, ctx.Err()
+		}
+	}
+	return func() {
+		if global != nil {
+			<-global
+		}
+		if remote != nil {
+			<-remote
+		}
+	}, nil
+}
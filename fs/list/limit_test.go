@@ -0,0 +1,98 @@
+package list
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrency(t *testing.T) {
+	oldChecker, oldList := fs.Config.Checkers, fs.Config.ListConcurrency
+	defer func() {
+		fs.Config.Checkers, fs.Config.ListConcurrency = oldChecker, oldList
+	}()
+	fs.Config.Checkers = 3
+	fs.Config.ListConcurrency = 0
+	assert.Equal(t, 3, Concurrency())
+	fs.Config.ListConcurrency = 17
+	assert.Equal(t, 17, Concurrency())
+}
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	oldList := fs.Config.ListConcurrency
+	defer func() {
+		fs.Config.ListConcurrency = oldList
+	}()
+	fs.Config.ListConcurrency = 4
+
+	require.NoError(t, os.Setenv("RCLONE_CONFIG_LIMITTEST_LIST_CONCURRENCY", "2"))
+	defer func() {
+		_ = os.Unsetenv("RCLONE_CONFIG_LIMITTEST_LIST_CONCURRENCY")
+	}()
+	limited := mockfs.NewFs("limittest", "")
+	other := mockfs.NewFs("othertest", "")
+	assert.Equal(t, 2, remoteConcurrency(limited))
+	assert.Equal(t, 0, remoteConcurrency(other))
+
+	// The remote limit applies first
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := acquire(ctx, limited)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := acquire(cancelled, limited)
+	assert.Equal(t, context.Canceled, err)
+
+	// Then the global limit
+	for i := 0; i < 2; i++ {
+		release, err := acquire(ctx, other)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+	_, err = acquire(cancelled, other)
+	assert.Equal(t, context.Canceled, err)
+
+	// Releasing lets more listings run
+	releases[0]()
+	release, err := acquire(ctx, limited)
+	require.NoError(t, err)
+	release()
+	for _, release := range releases[1:] {
+		release()
+	}
+	assert.Equal(t, 0, len(globalLimit))
+	assert.Equal(t, 0, len(remoteLimits["limittest"]))
+}
+
+func TestAcquireUnlimited(t *testing.T) {
+	ctx := context.Background()
+	oldChecker, oldList := fs.Config.Checkers, fs.Config.ListConcurrency
+	defer func() {
+		fs.Config.Checkers, fs.Config.ListConcurrency = oldChecker, oldList
+	}()
+	fs.Config.Checkers = 1
+	fs.Config.ListConcurrency = 0
+
+	// Without --list-concurrency there is no global limit so the
+	// source and destination listings don't wait for each other
+	f := mockfs.NewFs("unlimitedtest", "")
+	var releases []func()
+	for i := 0; i < 10; i++ {
+		release, err := acquire(ctx, f)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+	assert.Nil(t, globalLimit)
+	for _, release := range releases {
+		release()
+	}
+}
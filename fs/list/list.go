@@ -9,8 +9,27 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/sched"
 )
 
+// sourceKey is the context key marking listings of a source
+type sourceKey struct{}
+
+// Source returns a copy of ctx marking the listings made with it as
+// listings of the source of a sync, copy, move or check.
+//
+// Backends may list sources from reports which can be out of date,
+// like S3 Inventory, but must list anything else, in particular the
+// destinations, from the remote itself.
+func Source(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sourceKey{}, true)
+}
+
+// IsSource returns true if ctx was marked by Source.
+func IsSource(ctx context.Context) bool {
+	return ctx.Value(sourceKey{}) != nil
+}
+
 // DirSorted reads Object and *Dir into entries for the given Fs.
 //
 // dir is the start directory, "" for root
@@ -19,9 +38,21 @@ import (
 // files and directories passing the filter will be added.
 //
 // Files will be returned in sorted order
+//
+// This will wait if too many listings are already running - see
+// Concurrency.
 func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entries fs.DirEntries, err error) {
+	release, err := acquire(ctx, f)
+	if err != nil {
+		return nil, err
+	}
 	// Get unfiltered entries from the fs
-	entries, err = f.List(ctx, dir)
+	entries, err = f.List(sched.WithPace(ctx, f, sched.OpList), dir)
+	release()
+	if err != nil {
+		return nil, err
+	}
+	err = filter.Active.LoadIgnoreFiles(ctx, dir, entries)
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +63,15 @@ func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entri
 		fs.Debugf(dir, "Excluded")
 		return nil, nil
 	}
-	return filterAndSortDir(ctx, entries, includeAll, dir, filter.Active.IncludeObject, filter.Active.IncludeDirectory(ctx, f))
+	includeObject := filter.Active.IncludeObject
+	if !includeAll && !filter.Active.IncludeFileDir(f, dir, entries) {
+		// Keep the directories to look for include files in
+		// but none of the files
+		includeObject = func(ctx context.Context, o fs.Object) bool {
+			return false
+		}
+	}
+	return filterAndSortDir(ctx, entries, includeAll, dir, includeObject, filter.Active.IncludeDirectory(ctx, f))
 }
 
 // filter (if required) and check the entries, then sort them
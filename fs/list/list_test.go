@@ -103,3 +103,9 @@ func TestFilterAndSortUnknown(t *testing.T) {
 	assert.Error(t, err, "error")
 	assert.Nil(t, newEntries)
 }
+
+func TestSource(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, IsSource(ctx))
+	assert.True(t, IsSource(Source(ctx)))
+}
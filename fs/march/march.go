@@ -48,11 +48,18 @@ type Marcher interface {
 	Match(ctx context.Context, dst, src fs.DirEntry) (recurse bool)
 }
 
+// DirDoner is an optional interface for Marcher
+type DirDoner interface {
+	// DirDone is called when all the entries in the source
+	// directory dir have been passed to the Marcher
+	DirDone(dir string)
+}
+
 // init sets up a march over opt.Fsrc, and opt.Fdst calling back callback for each match
 func (m *March) init() {
-	m.srcListDir = m.makeListDir(m.Fsrc, m.SrcIncludeAll)
+	m.srcListDir = m.makeListDir(list.Source(m.Ctx), m.Fsrc, m.SrcIncludeAll)
 	if !m.NoTraverse {
-		m.dstListDir = m.makeListDir(m.Fdst, m.DstIncludeAll)
+		m.dstListDir = m.makeListDir(m.Ctx, m.Fdst, m.DstIncludeAll)
 	}
 	// Now create the matching transform
 	// ..normalise the UTF8 first
@@ -75,11 +82,11 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 
 // makeListDir makes constructs a listing function for the given fs
 // and includeAll flags for marching through the file system.
-func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
-	if !(fs.Config.UseListR && f.Features().ListR != nil) && // !--fast-list active and
+func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listDirFn {
+	if !(fs.Config.UseListR && f.Features().ListR != nil && !filter.Active.UsesIgnoreFiles()) && // !--fast-list active and
 		!(fs.Config.NoTraverse && filter.Active.HaveFilesFrom()) { // !(--files-from and --no-traverse)
 		return func(dir string) (entries fs.DirEntries, err error) {
-			return list.DirSorted(m.Ctx, f, includeAll, dir)
+			return list.DirSorted(ctx, f, includeAll, dir)
 		}
 	}
 
@@ -95,7 +102,7 @@ func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 		mu.Lock()
 		defer mu.Unlock()
 		if !started {
-			dirs, dirsErr = walk.NewDirTree(m.Ctx, f, m.Dir, includeAll, fs.Config.MaxDepth)
+			dirs, dirsErr = walk.NewDirTree(ctx, f, m.Dir, includeAll, fs.Config.MaxDepth)
 			started = true
 		}
 		if dirsErr != nil {
@@ -141,8 +148,8 @@ func (m *March) Run() error {
 	// Start some directory listing go routines
 	var wg sync.WaitGroup         // sync closing of go routines
 	var traversing sync.WaitGroup // running directory traversals
-	in := make(chan listDirJob, fs.Config.Checkers)
-	for i := 0; i < fs.Config.Checkers; i++ {
+	in := make(chan listDirJob, list.Concurrency())
+	for i := 0; i < list.Concurrency(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -385,6 +392,11 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 			defer wg.Done()
 			srcList, srcListErr = m.srcListDir(job.srcRemote)
 		}()
+		// Read the ignore files from the source before the
+		// destination is listed so they are used for both
+		if filter.Active.UsesIgnoreFiles() {
+			wg.Wait()
+		}
 	}
 	if !m.NoTraverse && !job.noDst {
 		wg.Add(1)
@@ -468,5 +480,8 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 			})
 		}
 	}
+	if doer, ok := m.Callback.(DirDoner); ok && !job.noSrc {
+		doer.DirDone(job.srcRemote)
+	}
 	return jobs, nil
 }
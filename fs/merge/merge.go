@@ -0,0 +1,203 @@
+// Package merge copies the files from several sources into one
+// destination in a single pass.
+//
+// All the sources and the destination are listed at once. Where more
+// than one source has a file with the same path the policy picks
+// which one is copied.
+package merge
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// Policies for choosing between files with the same path
+const (
+	PolicyNewest  = "newest"  // the file with the latest modification time
+	PolicyLargest = "largest" // the biggest file
+	PolicyFirst   = "first"   // the file from the first source given
+)
+
+// Policies is the list of supported policies
+var Policies = []string{PolicyNewest, PolicyLargest, PolicyFirst}
+
+// CheckPolicy returns an error if policy isn't supported
+func CheckPolicy(policy string) error {
+	for _, p := range Policies {
+		if p == policy {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown merge policy %q - must be one of %s", policy, strings.Join(Policies, ", "))
+}
+
+// candidate is a source file and the index of the source it is in
+type candidate struct {
+	o fs.Object
+	i int
+}
+
+// better returns true if a should be copied in preference to b
+//
+// Ties are broken by taking the file from the earlier source so the
+// result doesn't depend on the order the sources were listed in.
+func better(ctx context.Context, policy string, a, b candidate) bool {
+	switch policy {
+	case PolicyNewest:
+		aTime, bTime := a.o.ModTime(ctx), b.o.ModTime(ctx)
+		if !aTime.Equal(bTime) {
+			return aTime.After(bTime)
+		}
+	case PolicyLargest:
+		if a.o.Size() != b.o.Size() {
+			return a.o.Size() > b.o.Size()
+		}
+	}
+	return a.i < b.i
+}
+
+// merger holds the state of a merge
+type merger struct {
+	fdst    fs.Fs
+	fsrcs   []fs.Fs
+	policy  string
+	mu      sync.Mutex
+	winners map[string]candidate // best source file for each path
+	dst     map[string]fs.Object // files in the destination
+}
+
+// listSource lists the i-th source adding its files to the winners
+func (m *merger) listSource(ctx context.Context, i int) error {
+	f := m.fsrcs[i]
+	return walk.ListR(ctx, f, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			if !filter.Active.IncludeObject(ctx, o) {
+				return
+			}
+			c := candidate{o: o, i: i}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			old, found := m.winners[o.Remote()]
+			if !found {
+				m.winners[o.Remote()] = c
+				return
+			}
+			winner, loser := old, c
+			if better(ctx, m.policy, c, old) {
+				winner, loser = c, old
+				m.winners[o.Remote()] = c
+			}
+			fs.Debugf(o.Remote(), "Using %v not %v by policy %q", winner.o.Fs(), loser.o.Fs(), m.policy)
+		})
+		return nil
+	})
+}
+
+// listDest lists the destination
+func (m *merger) listDest(ctx context.Context) error {
+	err := walk.ListR(ctx, m.fdst, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			m.mu.Lock()
+			m.dst[o.Remote()] = o
+			m.mu.Unlock()
+		})
+		return nil
+	})
+	if err == fs.ErrorDirNotFound {
+		return nil
+	}
+	return err
+}
+
+// Merge copies the files from all of fsrcs into fdst, choosing between
+// files with the same path in different sources with policy.
+//
+// Files in fdst which are the same as the chosen file aren't copied
+// again. Nothing is deleted from fdst.
+func Merge(ctx context.Context, fdst fs.Fs, fsrcs []fs.Fs, policy string) error {
+	err := CheckPolicy(policy)
+	if err != nil {
+		return err
+	}
+	for _, fsrc := range fsrcs {
+		if operations.Overlapping(fdst, fsrc) {
+			return errors.Errorf("can't merge %v into %v as they overlap", fsrc, fdst)
+		}
+	}
+	m := &merger{
+		fdst:    fdst,
+		fsrcs:   fsrcs,
+		policy:  policy,
+		winners: make(map[string]candidate),
+		dst:     make(map[string]fs.Object),
+	}
+
+	// List everything at once
+	var (
+		wg      sync.WaitGroup
+		errorMu sync.Mutex
+		listErr error
+	)
+	list := func(f fs.Fs, fn func() error) {
+		defer wg.Done()
+		if err := fn(); err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(f, "Failed to list: %v", err)
+			errorMu.Lock()
+			listErr = err
+			errorMu.Unlock()
+		}
+	}
+	wg.Add(len(fsrcs) + 1)
+	for i := range fsrcs {
+		i := i
+		go list(fsrcs[i], func() error { return m.listSource(ctx, i) })
+	}
+	go list(fdst, func() error { return m.listDest(ctx) })
+	wg.Wait()
+	if listErr != nil {
+		return errors.Wrap(listErr, "not merging as listing failed")
+	}
+	fs.Infof(fdst, "Merging %d files from %d sources", len(m.winners), len(fsrcs))
+
+	// Copy the winners
+	var (
+		in         = make(chan fs.Object, fs.Config.Transfers)
+		errorCount int
+	)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for src := range in {
+				dst := m.dst[src.Remote()]
+				if dst != nil && operations.Equal(ctx, src, dst) {
+					fs.Debugf(src, "Unchanged skipping")
+					continue
+				}
+				_, err := operations.Copy(ctx, fdst, dst, src.Remote(), src)
+				if err != nil {
+					errorMu.Lock()
+					errorCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, c := range m.winners {
+		in <- c.o
+	}
+	close(in)
+	wg.Wait()
+	if errorCount > 0 {
+		return errors.Errorf("failed to copy %d files", errorCount)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package merge
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/rclone/rclone/backend/local"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestCheckPolicy(t *testing.T) {
+	for _, policy := range Policies {
+		assert.NoError(t, CheckPolicy(policy))
+	}
+	assert.Error(t, CheckPolicy("potato"))
+}
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	// Make a second local source
+	dir, err := ioutil.TempDir("", "rclone-merge")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	fsrc2, err := fs.NewFs(dir)
+	require.NoError(t, err)
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	only1 := r.WriteFile("only1", "only in source 1", t1)
+	old1 := r.WriteFile("both", "older and bigger in source 1", t1)
+	put := func(remote, content string, modTime time.Time) fstest.Item {
+		item := fstest.NewItem(remote, content, modTime)
+		_, err := operations.Rcat(ctx, fsrc2, remote, ioutil.NopCloser(strings.NewReader(content)), modTime)
+		require.NoError(t, err)
+		return item
+	}
+	new2 := put("both", "newer in source 2", t2)
+	only2 := put("dir/only2", "only in source 2", t1)
+
+	for _, test := range []struct {
+		policy string
+		want   fstest.Item
+	}{
+		{PolicyFirst, old1},
+		{PolicyLargest, old1},
+		{PolicyNewest, new2},
+	} {
+		t.Run(test.policy, func(t *testing.T) {
+			require.NoError(t, Merge(ctx, r.Fremote, []fs.Fs{r.Flocal, fsrc2}, test.policy))
+			fstest.CheckItems(t, r.Fremote, only1, only2, test.want)
+		})
+	}
+}
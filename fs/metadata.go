@@ -0,0 +1,72 @@
+package fs
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Metadata is the metadata of an object as a set of lower case keys
+// and values.
+//
+// The standard keys below are translated to and from the native
+// metadata of the backends which support them. Any other keys are
+// kept as user metadata by backends which can store it.
+type Metadata map[string]string
+
+// Standard Metadata keys
+const (
+	MetadataMode        = "mode"         // permissions in octal, eg "0644"
+	MetadataUID         = "uid"          // user ID of the owner
+	MetadataGID         = "gid"          // group ID of the owner
+	MetadataMtime       = "mtime"        // modification time in RFC 3339 format
+	MetadataAtime       = "atime"        // access time in RFC 3339 format
+	MetadataCtime       = "ctime"        // change time in RFC 3339 format
+	MetadataBtime       = "btime"        // creation time in RFC 3339 format
+	MetadataContentType = "content-type" // MIME type
+	MetadataACL         = "acl"          // POSIX ACL in short text form, eg "user::rw-,user:1000:r--,group::r--,mask::r--,other::---"
+	MetadataXattrPrefix = "xattr-"       // prefix for extended attributes, eg "xattr-user.comment"
+)
+
+// SetTime sets key to t if t is not zero
+func (m Metadata) SetTime(key string, t time.Time) {
+	if !t.IsZero() {
+		m[key] = t.Format(time.RFC3339Nano)
+	}
+}
+
+// Time returns the time in key and whether it was present and valid
+func (m Metadata) Time(key string) (t time.Time, ok bool) {
+	value, found := m[key]
+	if !found {
+		return t, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		Debugf(nil, "Ignoring invalid time %q in metadata %q: %v", value, key, err)
+		return t, false
+	}
+	return t, true
+}
+
+// Xattrs returns the extended attributes in the metadata with the
+// MetadataXattrPrefix removed from their names
+func (m Metadata) Xattrs() map[string]string {
+	xattrs := make(map[string]string)
+	for key, value := range m {
+		if strings.HasPrefix(key, MetadataXattrPrefix) {
+			xattrs[key[len(MetadataXattrPrefix):]] = value
+		}
+	}
+	return xattrs
+}
+
+// GetMetadata returns the Metadata of o or nil if o doesn't support
+// the Metadataer interface
+func GetMetadata(ctx context.Context, o ObjectInfo) (Metadata, error) {
+	do, ok := o.(Metadataer)
+	if !ok {
+		return nil, nil
+	}
+	return do.Metadata(ctx)
+}
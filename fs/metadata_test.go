@@ -0,0 +1,37 @@
+package fs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataTime(t *testing.T) {
+	when := time.Date(2020, 7, 8, 9, 10, 11, 123456789, time.UTC)
+	m := Metadata{}
+	m.SetTime(MetadataMtime, when)
+	m.SetTime(MetadataAtime, time.Time{})
+	assert.Equal(t, Metadata{MetadataMtime: "2020-07-08T09:10:11.123456789Z"}, m)
+
+	got, ok := m.Time(MetadataMtime)
+	assert.True(t, ok)
+	assert.True(t, when.Equal(got))
+
+	_, ok = m.Time(MetadataAtime)
+	assert.False(t, ok)
+
+	m[MetadataBtime] = "potato"
+	_, ok = m.Time(MetadataBtime)
+	assert.False(t, ok)
+}
+
+func TestMetadataXattrs(t *testing.T) {
+	m := Metadata{
+		MetadataMode:                      "0644",
+		MetadataXattrPrefix + "user.a":    "1",
+		MetadataXattrPrefix + "user.b.c":  "2",
+		"x" + MetadataXattrPrefix + "not": "3",
+	}
+	assert.Equal(t, map[string]string{"user.a": "1", "user.b.c": "2"}, m.Xattrs())
+}
@@ -0,0 +1,244 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// backupTemplate is a date template which can be used in --backup-dir
+// and --suffix
+type backupTemplate struct {
+	name   string // as written by the user
+	layout string // time layout to expand it with
+	re     string // regexp to match the expansion
+}
+
+var backupTemplates = []backupTemplate{
+	{name: "{date}", layout: "2006-01-02", re: `(?P<YYYY>\d{4})-(?P<MM>\d{2})-(?P<DD>\d{2})`},
+	{name: "{time}", layout: "150405", re: `(?P<hh>\d{2})(?P<mm>\d{2})(?P<ss>\d{2})`},
+	{name: "{YYYY}", layout: "2006", re: `(?P<YYYY>\d{4})`},
+	{name: "{MM}", layout: "01", re: `(?P<MM>\d{2})`},
+	{name: "{DD}", layout: "02", re: `(?P<DD>\d{2})`},
+	{name: "{hh}", layout: "15", re: `(?P<hh>\d{2})`},
+	{name: "{mm}", layout: "04", re: `(?P<mm>\d{2})`},
+	{name: "{ss}", layout: "05", re: `(?P<ss>\d{2})`},
+}
+
+// The time used to expand the date templates. This is fixed at the
+// start of each operation so all the files it backs up go to the same
+// place.
+var (
+	backupTimeMu sync.Mutex
+	backupTime   time.Time
+)
+
+// resetBackupTime sets the time used to expand the date templates to
+// now
+func resetBackupTime() {
+	backupTimeMu.Lock()
+	backupTime = time.Now()
+	backupTimeMu.Unlock()
+}
+
+// getBackupTime returns the time used to expand the date templates
+func getBackupTime() time.Time {
+	backupTimeMu.Lock()
+	defer backupTimeMu.Unlock()
+	if backupTime.IsZero() {
+		backupTime = time.Now()
+	}
+	return backupTime
+}
+
+// ExpandBackupTemplate replaces the date templates, eg {date}, in s
+// with t formatted in local time.
+func ExpandBackupTemplate(s string, t time.Time) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+	t = t.Local()
+	for _, tmpl := range backupTemplates {
+		s = strings.Replace(s, tmpl.name, t.Format(tmpl.layout), -1)
+	}
+	return s
+}
+
+// backupTemplateRegexp makes a regexp which matches the expansions of
+// template and captures the date parts
+func backupTemplateRegexp(template string) (*regexp.Regexp, error) {
+	var out strings.Builder
+	out.WriteString("^")
+	found := false
+	for len(template) > 0 {
+		matched := false
+		for _, tmpl := range backupTemplates {
+			if strings.HasPrefix(template, tmpl.name) {
+				out.WriteString(tmpl.re)
+				template = template[len(tmpl.name):]
+				matched, found = true, true
+				break
+			}
+		}
+		if !matched {
+			out.WriteString(regexp.QuoteMeta(template[:1]))
+			template = template[1:]
+		}
+	}
+	out.WriteString("$")
+	if !found {
+		return nil, errors.New("template must contain a date, eg {date}")
+	}
+	return regexp.Compile(out.String())
+}
+
+// parseBackupTemplate parses name using template returning the time
+// it was made or false if it doesn't match
+func parseBackupTemplate(re *regexp.Regexp, name string) (t time.Time, ok bool) {
+	match := re.FindStringSubmatch(name)
+	if match == nil {
+		return t, false
+	}
+	parts := make(map[string]int)
+	for i, group := range re.SubexpNames() {
+		if group == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i])
+		if err != nil {
+			return t, false
+		}
+		if old, seen := parts[group]; seen && old != n {
+			return t, false // same part repeated with different values
+		}
+		parts[group] = n
+	}
+	// Default to the start of the year or month if not present
+	for _, group := range []string{"MM", "DD"} {
+		if _, seen := parts[group]; !seen {
+			parts[group] = 1
+		}
+	}
+	t = time.Date(parts["YYYY"], time.Month(parts["MM"]), parts["DD"], parts["hh"], parts["mm"], parts["ss"], 0, time.Local)
+	return t, true
+}
+
+// PruneOpt is the retention policy for PruneBackupDirs
+type PruneOpt struct {
+	Template    string // template the directory names were made with, eg {date}
+	KeepDaily   int    // number of days to keep the newest directory for
+	KeepWeekly  int    // number of weeks to keep the newest directory for
+	KeepMonthly int    // number of months to keep the newest directory for
+}
+
+// datedDir is a directory with the time parsed from its name
+type datedDir struct {
+	name string
+	t    time.Time
+}
+
+// keepBackupDirs returns the set of dirs to keep under opt
+//
+// For each period type the newest directory in each of the most
+// recent periods which have a directory is kept, up to the count
+// given. A directory is kept if any of the periods keep it.
+func keepBackupDirs(dirs []datedDir, opt PruneOpt) map[string]bool {
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].t.After(dirs[j].t)
+	})
+	keep := make(map[string]bool)
+	for _, policy := range []struct {
+		n      int
+		period func(t time.Time) string
+	}{
+		{opt.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{opt.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{opt.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	} {
+		last := ""
+		n := policy.n
+		for _, dir := range dirs {
+			if n <= 0 {
+				break
+			}
+			if period := policy.period(dir.t); period != last {
+				keep[dir.name] = true
+				last = period
+				n--
+			}
+		}
+	}
+	return keep
+}
+
+// PruneBackupDirs removes the directories in f named with a date
+// template, eg made with --backup-dir remote:old/{date}, which aren't
+// kept by the retention policy in opt.
+//
+// The template may contain "/", eg {YYYY}/{MM}/{DD}, in which case
+// the directories that deep are matched on their path relative to f.
+//
+// Directories whose names don't match the template are left alone.
+func PruneBackupDirs(ctx context.Context, f fs.Fs, opt PruneOpt) error {
+	if opt.KeepDaily <= 0 && opt.KeepWeekly <= 0 && opt.KeepMonthly <= 0 {
+		return errors.New("need at least one of --keep-daily, --keep-weekly or --keep-monthly")
+	}
+	template := strings.Trim(opt.Template, "/")
+	re, err := backupTemplateRegexp(template)
+	if err != nil {
+		return err
+	}
+	depth := strings.Count(template, "/") + 1
+	var dirs []datedDir
+	err = walk.ListR(ctx, f, "", true, depth, walk.ListDirs, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			if _, ok := entry.(fs.Directory); !ok {
+				continue
+			}
+			remote := entry.Remote()
+			if strings.Count(remote, "/") != depth-1 {
+				continue
+			}
+			t, ok := parseBackupTemplate(re, remote)
+			if !ok {
+				fs.Debugf(entry, "Ignoring as path doesn't match %q", template)
+				continue
+			}
+			dirs = append(dirs, datedDir{name: remote, t: t})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	keep := keepBackupDirs(dirs, opt)
+	var errorCount int
+	for _, dir := range dirs {
+		if keep[dir.name] {
+			fs.Debugf(fs.LogDirName(f, dir.name), "Keeping")
+			continue
+		}
+		fs.Infof(fs.LogDirName(f, dir.name), "Pruning")
+		err = Purge(ctx, f, dir.name)
+		if err != nil {
+			fs.Errorf(fs.LogDirName(f, dir.name), "Failed to prune: %v", err)
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return errors.Errorf("failed to prune %d directories", errorCount)
+	}
+	return nil
+}
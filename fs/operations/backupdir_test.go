@@ -0,0 +1,128 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandBackupTemplate(t *testing.T) {
+	when := time.Date(2020, 7, 8, 9, 10, 11, 0, time.Local)
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"remote:old", "remote:old"},
+		{"remote:old/{date}", "remote:old/2020-07-08"},
+		{"remote:old/{date}-{time}", "remote:old/2020-07-08-091011"},
+		{"-{YYYY}{MM}{DD}T{hh}{mm}{ss}", "-20200708T091011"},
+		{"{potato}", "{potato}"},
+	} {
+		assert.Equal(t, test.want, ExpandBackupTemplate(test.in, when), test.in)
+	}
+}
+
+func TestParseBackupTemplate(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		name     string
+		want     time.Time
+		ok       bool
+	}{
+		{"{date}", "2020-07-08", time.Date(2020, 7, 8, 0, 0, 0, 0, time.Local), true},
+		{"backup-{date}-{time}", "backup-2020-07-08-091011", time.Date(2020, 7, 8, 9, 10, 11, 0, time.Local), true},
+		{"{YYYY}/{MM}", "2020/07", time.Date(2020, 7, 1, 0, 0, 0, 0, time.Local), true},
+		{"{date}.{YYYY}", "2020-07-08.2020", time.Date(2020, 7, 8, 0, 0, 0, 0, time.Local), true},
+		{"{date}.{YYYY}", "2020-07-08.2021", time.Time{}, false},
+		{"{date}", "backup-2020-07-08", time.Time{}, false},
+		{"{date}", "potato", time.Time{}, false},
+	} {
+		re, err := backupTemplateRegexp(test.template)
+		require.NoError(t, err)
+		got, ok := parseBackupTemplate(re, test.name)
+		assert.Equal(t, test.ok, ok, test.name)
+		if ok {
+			assert.True(t, test.want.Equal(got), "%s: want %v got %v", test.name, test.want, got)
+		}
+	}
+	_, err := backupTemplateRegexp("no-date")
+	assert.Error(t, err)
+}
+
+func TestKeepBackupDirs(t *testing.T) {
+	// A directory every day for 100 days
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	var dirs []datedDir
+	for i := 0; i < 100; i++ {
+		day := start.AddDate(0, 0, i)
+		dirs = append(dirs, datedDir{name: day.Format("2006-01-02"), t: day})
+	}
+	count := func(opt PruneOpt) int {
+		return len(keepBackupDirs(dirs, opt))
+	}
+	assert.Equal(t, 7, count(PruneOpt{KeepDaily: 7}))
+	assert.Equal(t, 4, count(PruneOpt{KeepWeekly: 4}))
+	assert.Equal(t, 4, count(PruneOpt{KeepMonthly: 12})) // only 4 months of dirs
+	keep := keepBackupDirs(dirs, PruneOpt{KeepDaily: 2, KeepMonthly: 2})
+	assert.Equal(t, map[string]bool{
+		"2020-04-09": true, // newest - daily and monthly
+		"2020-04-08": true, // daily
+		"2020-03-31": true, // monthly
+	}, keep)
+}
+
+func TestPruneBackupDirs(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	var items []fstest.Item
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("2020-07-%02d/file.txt", i)
+		items = append(items, r.WriteObject(ctx, name, "contents", t1))
+	}
+	other := r.WriteObject(ctx, "not-a-backup/file.txt", "contents", t1)
+	fstest.CheckItems(t, r.Fremote, append(items, other)...)
+
+	err := PruneBackupDirs(ctx, r.Fremote, PruneOpt{Template: "{date}"})
+	assert.Error(t, err, "no keep options")
+
+	err = PruneBackupDirs(ctx, r.Fremote, PruneOpt{Template: "{date}", KeepDaily: 2})
+	require.NoError(t, err)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{items[3], items[4], other}, []string{
+		"2020-07-04",
+		"2020-07-05",
+		"not-a-backup",
+	}, fs.GetModifyWindow(r.Fremote))
+}
+
+func TestPruneBackupDirsNested(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	var items []fstest.Item
+	for i := 1; i <= 3; i++ {
+		name := fmt.Sprintf("2020/07/%02d/file.txt", i)
+		items = append(items, r.WriteObject(ctx, name, "contents", t1))
+	}
+	other := r.WriteObject(ctx, "2020/notes/file.txt", "contents", t1)
+	fstest.CheckItems(t, r.Fremote, append(items, other)...)
+
+	err := PruneBackupDirs(ctx, r.Fremote, PruneOpt{Template: "{YYYY}/{MM}/{DD}", KeepDaily: 1})
+	require.NoError(t, err)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{items[2], other}, []string{
+		"2020",
+		"2020/07",
+		"2020/07/03",
+		"2020/notes",
+	}, fs.GetModifyWindow(r.Fremote))
+}
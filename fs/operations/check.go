@@ -12,8 +12,10 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/hashdb"
 	"github.com/rclone/rclone/fs/march"
 	"github.com/rclone/rclone/lib/readers"
+	"golang.org/x/sync/errgroup"
 )
 
 // checkFn is the type of the checking function used in CheckFn()
@@ -28,15 +30,16 @@ type checkFn func(ctx context.Context, a, b fs.Object) (differ bool, noHash bool
 
 // CheckOpt contains options for the Check functions
 type CheckOpt struct {
-	Fdst, Fsrc   fs.Fs     // fses to check
-	Check        checkFn   // function to use for checking
-	OneWay       bool      // one way only?
-	Combined     io.Writer // a file with file names with leading sigils
-	MissingOnSrc io.Writer // files only in the destination
-	MissingOnDst io.Writer // files only in the source
-	Match        io.Writer // matching files
-	Differ       io.Writer // differing files
-	Error        io.Writer // files with errors of some kind
+	Fdst, Fsrc   fs.Fs      // fses to check
+	Check        checkFn    // function to use for checking
+	OneWay       bool       // one way only?
+	Combined     io.Writer  // a file with file names with leading sigils
+	MissingOnSrc io.Writer  // files only in the destination
+	MissingOnDst io.Writer  // files only in the source
+	Match        io.Writer  // matching files
+	Differ       io.Writer  // differing files
+	Error        io.Writer  // files with errors of some kind
+	HashDB       *hashdb.DB // if set, hashes are read from and saved to this
 }
 
 // checkMarch is used to march over two Fses in the same way as
@@ -248,7 +251,15 @@ func CheckFn(ctx context.Context, opt *CheckOpt) error {
 func Check(ctx context.Context, opt *CheckOpt) error {
 	optCopy := *opt
 	optCopy.Check = func(ctx context.Context, dst, src fs.Object) (differ bool, noHash bool, err error) {
-		same, ht, err := CheckHashes(ctx, src, dst)
+		var (
+			same bool
+			ht   hash.Type
+		)
+		if opt.HashDB != nil {
+			same, ht, err = checkHashesDB(ctx, opt.HashDB, src, dst)
+		} else {
+			same, ht, err = CheckHashes(ctx, src, dst)
+		}
 		if err != nil {
 			return true, false, err
 		}
@@ -266,6 +277,68 @@ func Check(ctx context.Context, opt *CheckOpt) error {
 	return CheckFn(ctx, &optCopy)
 }
 
+// checkHashesDB is like CheckHashes but reads the hashes from db if
+// they were recorded there when the objects had the same size and
+// modification time as they do now. Only the hashes which weren't
+// recorded are calculated and these are saved in db for next time.
+func checkHashesDB(ctx context.Context, db *hashdb.DB, src, dst fs.Object) (equal bool, ht hash.Type, err error) {
+	common := src.Fs().Hashes().Overlap(dst.Fs().Hashes())
+	if common.Count() == 0 {
+		return true, hash.None, nil
+	}
+	ht = common.GetOne()
+	var srcHash, dstHash string
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		srcHash, err = hashWithDB(gCtx, db, src, ht)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(src, "Failed to calculate src hash: %v", err)
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		dstHash, err = hashWithDB(gCtx, db, dst, ht)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(dst, "Failed to calculate dst hash: %v", err)
+		}
+		return err
+	})
+	err = g.Wait()
+	if err != nil {
+		return false, ht, err
+	}
+	if srcHash == "" || dstHash == "" {
+		return true, hash.None, nil
+	}
+	if srcHash != dstHash {
+		fs.Debugf(src, "%v = %s (%v)", ht, srcHash, src.Fs())
+		fs.Debugf(dst, "%v = %s (%v)", ht, dstHash, dst.Fs())
+	} else {
+		fs.Debugf(src, "%v = %s OK", ht, srcHash)
+	}
+	return srcHash == dstHash, ht, nil
+}
+
+// hashWithDB returns the hash of type ht of o from db if it is
+// recorded there, otherwise it calculates it and records it in db.
+func hashWithDB(ctx context.Context, db *hashdb.DB, o fs.Object, ht hash.Type) (string, error) {
+	if sum, ok := db.Get(ctx, o, ht); ok {
+		fs.Debugf(o, "Using %v from hash database", ht)
+		return sum, nil
+	}
+	sum, err := o.Hash(ctx, ht)
+	if err != nil || sum == "" {
+		return sum, err
+	}
+	err = db.Put(ctx, o, ht, sum)
+	if err != nil {
+		fs.Errorf(o, "Failed to save %v in hash database: %v", ht, err)
+	}
+	return sum, nil
+}
+
 // CheckEqualReaders checks to see if in1 and in2 have the same
 // content when read.
 //
@@ -348,3 +421,102 @@ func CheckDownload(ctx context.Context, opt *CheckOpt) error {
 	}
 	return CheckFn(ctx, &optCopy)
 }
+
+// QuickHash returns the MD5 hash of the first and last blockSize
+// bytes of o, reading only those parts of it. If o is no bigger than
+// two blocks or its size is unknown then all of it is hashed.
+func QuickHash(ctx context.Context, o fs.Object, blockSize int64) (sum string, err error) {
+	err = Retry(o, fs.Config.LowLevelRetries, func() error {
+		sum, err = quickHash(ctx, o, blockSize)
+		return err
+	})
+	return sum, err
+}
+
+// Does the work for QuickHash
+func quickHash(ctx context.Context, o fs.Object, blockSize int64) (string, error) {
+	size := o.Size()
+	var ranges []*fs.RangeOption
+	if size < 0 || size <= 2*blockSize {
+		ranges = []*fs.RangeOption{nil}
+	} else {
+		ranges = []*fs.RangeOption{
+			{Start: 0, End: blockSize - 1},
+			{Start: size - blockSize, End: size - 1},
+		}
+	}
+	hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(hash.MD5))
+	if err != nil {
+		return "", err
+	}
+	tr := accounting.Stats(ctx).NewTransfer(o)
+	defer func() {
+		tr.Done(nil) // error handling is done by the caller
+	}()
+	for _, rangeOption := range ranges {
+		var options []fs.OpenOption
+		if rangeOption != nil {
+			options = append(options, rangeOption)
+		}
+		in, err := o.Open(ctx, options...)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to open %q", o)
+		}
+		in = tr.Account(ctx, in).WithBuffer() // account and buffer the transfer
+		_, err = io.Copy(hasher, in)
+		closeErr := in.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %q", o)
+		}
+	}
+	return hasher.Sums()[hash.MD5], nil
+}
+
+// CheckIdenticalQuick checks to see if dst and src are identical by
+// comparing their sizes and QuickHash.
+//
+// it returns true if differences were found
+func CheckIdenticalQuick(ctx context.Context, dst, src fs.Object, blockSize int64) (differ bool, err error) {
+	if src.Size() >= 0 && dst.Size() >= 0 && src.Size() != dst.Size() {
+		return true, nil
+	}
+	var srcSum, dstSum string
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		srcSum, err = QuickHash(gCtx, src, blockSize)
+		return err
+	})
+	g.Go(func() (err error) {
+		dstSum, err = QuickHash(gCtx, dst, blockSize)
+		return err
+	})
+	if err = g.Wait(); err != nil {
+		return true, err
+	}
+	return srcSum != dstSum, nil
+}
+
+// CheckQuick checks the files in fsrc and fdst according to Size and
+// a hash of the first and last blockSize bytes of the files read from
+// both remotes.
+//
+// This is much quicker than CheckDownload for big files and catches
+// truncated and badly started or finished transfers, but not
+// corruption in the middle of files.
+func CheckQuick(ctx context.Context, opt *CheckOpt, blockSize int64) error {
+	if blockSize <= 0 {
+		return errors.New("quick compare block size must be positive")
+	}
+	optCopy := *opt
+	optCopy.Check = func(ctx context.Context, a, b fs.Object) (differ bool, noHash bool, err error) {
+		differ, err = CheckIdenticalQuick(ctx, a, b, blockSize)
+		if err != nil {
+			return true, true, errors.Wrap(err, "failed to download")
+		}
+		return differ, false, nil
+	}
+	return CheckFn(ctx, &optCopy)
+}
@@ -5,15 +5,20 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/hashdb"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/lib/readers"
@@ -181,6 +186,60 @@ func TestCheck(t *testing.T) {
 	testCheck(t, operations.Check)
 }
 
+func TestCheckHashDB(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ht := r.Flocal.Hashes().Overlap(r.Fremote.Hashes()).GetOne()
+	if ht == hash.None {
+		t.Skip("no common hash")
+	}
+
+	dir, err := ioutil.TempDir("", "rclone-hashdb")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	db, err := hashdb.Open(filepath.Join(dir, "hashes.db"))
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteBoth(ctx, "one", "hello", t1)
+	fstest.CheckItems(t, r.Flocal, file1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	check := func() error {
+		accounting.GlobalStats().ResetCounters()
+		return operations.Check(ctx, &operations.CheckOpt{
+			Fdst:   r.Fremote,
+			Fsrc:   r.Flocal,
+			HashDB: db,
+		})
+	}
+
+	// First check hashes both sides and records them
+	require.NoError(t, check())
+	for _, f := range []fs.Fs{r.Flocal, r.Fremote} {
+		o, err := f.NewObject(ctx, "one")
+		require.NoError(t, err)
+		sum, ok := db.Get(ctx, o, ht)
+		assert.True(t, ok, f)
+		assert.Equal(t, file1.Hashes[ht], sum, f)
+	}
+
+	// Change the source without changing its size or modtime -
+	// the recorded hash is used so this isn't noticed
+	r.WriteFile("one", "HELLO", t1)
+	require.NoError(t, check())
+
+	// Once the modtime changes the source is hashed again
+	r.WriteFile("one", "HELLO", t1.Add(time.Hour))
+	assert.Error(t, check())
+}
+
 func TestCheckFsError(t *testing.T) {
 	dstFs, err := fs.NewFs("non-existent")
 	if err != nil {
@@ -203,6 +262,45 @@ func TestCheckDownload(t *testing.T) {
 	testCheck(t, operations.CheckDownload)
 }
 
+func TestCheckQuick(t *testing.T) {
+	testCheck(t, func(ctx context.Context, opt *operations.CheckOpt) error {
+		return operations.CheckQuick(ctx, opt, 4)
+	})
+}
+
+func TestCheckIdenticalQuick(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer accounting.GlobalStats().ResetCounters()
+
+	file1 := r.WriteBoth(ctx, "same", "0123456789abcdef", t1)
+	file2 := r.WriteFile("middle", "0123456789abcdef", t1)
+	r.WriteObject(ctx, "middle", "0123XXXXXXXXcdef", t1)
+	file3 := r.WriteFile("tail", "0123456789abcdef", t1)
+	r.WriteObject(ctx, "tail", "0123456789abcdeX", t1)
+	file4 := r.WriteFile("small", "0123456", t1)
+	r.WriteObject(ctx, "small", "012X456", t1)
+
+	for _, test := range []struct {
+		remote string
+		differ bool
+	}{
+		{file1.Path, false},
+		{file2.Path, false}, // only the middle differs
+		{file3.Path, true},
+		{file4.Path, true}, // small enough to read all of
+	} {
+		src, err := r.Flocal.NewObject(ctx, test.remote)
+		require.NoError(t, err)
+		dst, err := r.Fremote.NewObject(ctx, test.remote)
+		require.NoError(t, err)
+		differ, err := operations.CheckIdenticalQuick(ctx, dst, src, 4)
+		require.NoError(t, err)
+		assert.Equal(t, test.differ, differ, test.remote)
+	}
+}
+
 func TestCheckSizeOnly(t *testing.T) {
 	fs.Config.SizeOnly = true
 	defer func() { fs.Config.SizeOnly = false }()
@@ -9,6 +9,7 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
@@ -362,3 +363,204 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode) error {
 	}
 	return nil
 }
+
+// DedupeHashAction is what DeduplicateByHash does with the identical
+// copies it finds
+type DedupeHashAction int
+
+// DeduplicateByHash actions
+const (
+	DedupeHashReport DedupeHashAction = iota // just report the identical copies
+	DedupeHashCopy                           // replace them with server side copies
+	DedupeHashLink                           // replace them with hard links
+)
+
+func (x DedupeHashAction) String() string {
+	switch x {
+	case DedupeHashReport:
+		return "report"
+	case DedupeHashCopy:
+		return "copy"
+	case DedupeHashLink:
+		return "link"
+	}
+	return "unknown"
+}
+
+// Set a DedupeHashAction from a string
+func (x *DedupeHashAction) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "report":
+		*x = DedupeHashReport
+	case "copy":
+		*x = DedupeHashCopy
+	case "link":
+		*x = DedupeHashLink
+	default:
+		return errors.Errorf("Unknown action for dedupe by hash %q.", s)
+	}
+	return nil
+}
+
+// Type of the value
+func (x *DedupeHashAction) Type() string {
+	return "string"
+}
+
+// dedupeHashGroups finds the objects in f with identical contents,
+// returning groups of two or more objects with the same size and hash.
+// Each group is sorted oldest first.
+func dedupeHashGroups(ctx context.Context, f fs.Fs, ht hash.Type) (groups [][]fs.Object, err error) {
+	// Find files with the same size first so only those need hashing
+	bySize := map[int64][]fs.Object{}
+	seenIDs := map[string]struct{}{}
+	err = walk.ListR(ctx, f, "", true, fs.Config.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			// Empty files and files of unknown size are ignored
+			if o.Size() <= 0 {
+				return
+			}
+			// Ignore objects appearing more than once in the listing
+			if do, ok := o.(fs.IDer); ok {
+				if ID := do.ID(); ID != "" {
+					if _, found := seenIDs[ID]; found {
+						return
+					}
+					seenIDs[ID] = struct{}{}
+				}
+			}
+			bySize[o.Size()] = append(bySize[o.Size()], o)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash the files of the same size
+	var (
+		in     = make(chan fs.Object, fs.Config.Checkers)
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		byHash = map[string][]fs.Object{}
+	)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for o := range in {
+				hashValue, err := o.Hash(ctx, ht)
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(o, "Failed to read hash: %v", err)
+					continue
+				}
+				if hashValue == "" {
+					continue
+				}
+				ID := fmt.Sprintf("%d %s", o.Size(), hashValue)
+				mu.Lock()
+				byHash[ID] = append(byHash[ID], o)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, objs := range bySize {
+		if len(objs) > 1 {
+			for _, o := range objs {
+				in <- o
+			}
+		}
+	}
+	close(in)
+	wg.Wait()
+
+	for _, objs := range byHash {
+		if len(objs) > 1 {
+			sort.Slice(objs, func(i, j int) bool {
+				ti, tj := objs[i].ModTime(ctx), objs[j].ModTime(ctx)
+				if !ti.Equal(tj) {
+					return ti.Before(tj)
+				}
+				return objs[i].Remote() < objs[j].Remote()
+			})
+			groups = append(groups, objs)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Remote() < groups[j][0].Remote()
+	})
+	return groups, nil
+}
+
+// DeduplicateByHash finds files with identical contents anywhere in f
+// whatever their names, using the size and hash of the files.
+//
+// The oldest of each set of identical files is kept and the others
+// are reported, or replaced with server side copies or hard links to
+// it depending on action.
+func DeduplicateByHash(ctx context.Context, f fs.Fs, action DedupeHashAction) error {
+	ht := f.Hashes().GetOne()
+	if ht == hash.None {
+		return errors.Errorf("%v: can't dedupe by hash as the backend doesn't support hashes", f)
+	}
+	var replace func(ctx context.Context, src fs.Object, remote string) (fs.Object, error)
+	switch action {
+	case DedupeHashReport:
+	case DedupeHashCopy:
+		replace = f.Features().Copy
+		if replace == nil {
+			return errors.Errorf("%v: can't replace duplicates with server side copies", f)
+		}
+	case DedupeHashLink:
+		replace = f.Features().HardLink
+		if replace == nil {
+			return errors.Errorf("%v: can't replace duplicates with hard links", f)
+		}
+	default:
+		return errors.Errorf("unknown dedupe by hash action %v", action)
+	}
+	fs.Infof(f, "Looking for identical files using %v hashes and %v action.", ht, action)
+
+	groups, err := dedupeHashGroups(ctx, f, ht)
+	if err != nil {
+		return err
+	}
+	var (
+		duplicates int
+		bytes      int64
+		errorCount int
+	)
+	for _, objs := range groups {
+		keep := objs[0]
+		fs.Logf(keep, "Found %d identical copies", len(objs)-1)
+		for _, o := range objs[1:] {
+			duplicates++
+			bytes += o.Size()
+			if replace == nil {
+				fs.Logf(o, "Identical to %q", keep.Remote())
+				continue
+			}
+			if SkipDestructive(ctx, o, fmt.Sprintf("replace with %v of %q", action, keep.Remote())) {
+				continue
+			}
+			newObj, err := replace(ctx, keep, o.Remote())
+			if err == nil && newObj != nil && f.Features().DuplicateFiles {
+				// The replacement doesn't overwrite the original
+				err = o.Remove(ctx)
+			}
+			if err != nil {
+				err = fs.CountError(err)
+				fs.Errorf(o, "Failed to replace with %v of %q: %v", action, keep.Remote(), err)
+				errorCount++
+				continue
+			}
+			fs.Infof(o, "Replaced with %v of %q", action, keep.Remote())
+		}
+	}
+	fs.Logf(f, "Found %d identical copies using %v", duplicates, fs.SizeSuffix(bytes))
+	if errorCount > 0 {
+		return errors.Errorf("failed to replace %d identical copies", errorCount)
+	}
+	return nil
+}
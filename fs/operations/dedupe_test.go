@@ -227,6 +227,43 @@ func TestDeduplicateRename(t *testing.T) {
 	}))
 }
 
+func TestDeduplicateByHash(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	skipIfNoHash(t, r.Fremote)
+	ctx := context.Background()
+
+	file1 := r.WriteObject(ctx, "a/one.txt", "This is one", t2)
+	file2 := r.WriteObject(ctx, "b/copy of one.txt", "This is one", t1)
+	file3 := r.WriteObject(ctx, "c/d/one again.txt", "This is one", t3)
+	file4 := r.WriteObject(ctx, "a/two.txt", "This is two", t1)
+	file5 := r.WriteObject(ctx, "empty.txt", "", t1)
+	file6 := r.WriteObject(ctx, "b/empty.txt", "", t1)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file5, file6)
+
+	// Reporting changes nothing
+	err := operations.DeduplicateByHash(ctx, r.Fremote, operations.DedupeHashReport)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file5, file6)
+
+	var action operations.DedupeHashAction
+	switch {
+	case r.Fremote.Features().HardLink != nil:
+		action = operations.DedupeHashLink
+	case r.Fremote.Features().Copy != nil:
+		action = operations.DedupeHashCopy
+	default:
+		t.Skip("Can't replace duplicates on this remote")
+	}
+	err = operations.DeduplicateByHash(ctx, r.Fremote, action)
+	require.NoError(t, err)
+
+	// The duplicates of the oldest file get its modification time
+	file1.ModTime = t1
+	file3.ModTime = t1
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, file4, file5, file6)
+}
+
 // This should really be a unit test, but the test framework there
 // doesn't have enough tools to make it easy
 func TestMergeDirs(t *testing.T) {
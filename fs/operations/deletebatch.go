@@ -0,0 +1,106 @@
+package operations
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// maxDeleteBatch is the most objects passed to DeleteBatch at once
+const maxDeleteBatch = 1000
+
+// deleteBatchWait is how long to wait for more objects to arrive
+// before deleting a batch which isn't full
+var deleteBatchWait = 100 * time.Millisecond
+
+// fillDeleteBatch reads objects from toBeDeleted to go in a batch with
+// first until the batch is full, the channel is closed or no more
+// objects arrive for deleteBatchWait
+func fillDeleteBatch(first fs.Object, toBeDeleted fs.ObjectsChan) []fs.Object {
+	batch := []fs.Object{first}
+	timer := time.NewTimer(deleteBatchWait)
+	defer timer.Stop()
+	for len(batch) < maxDeleteBatch {
+		select {
+		case dst, ok := <-toBeDeleted:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, dst)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// deleteBatch deletes objs respecting --dry-run and accumulating
+// stats and errors in the same way as DeleteFile, but using doBatch to
+// delete the objects from the same Fs as the first in one go.
+//
+// It returns an error for each object in objs in order.
+func deleteBatch(ctx context.Context, doBatch func(ctx context.Context, objs []fs.Object) []error, objs []fs.Object) []error {
+	var (
+		errs     = make([]error, len(objs))
+		f        = objs[0].Fs()
+		toDelete []fs.Object
+		indexes  []int
+		trs      []*accounting.Transfer
+	)
+	for i, dst := range objs {
+		if dst.Fs() != f {
+			errs[i] = DeleteFile(ctx, dst)
+			continue
+		}
+		tr := accounting.Stats(ctx).NewCheckingTransfer(dst)
+		numDeletes := accounting.Stats(ctx).Deletes(1)
+		if fs.Config.MaxDelete != -1 && numDeletes > fs.Config.MaxDelete {
+			errs[i] = fserrors.FatalError(errors.New("--max-delete threshold reached"))
+			tr.Done(errs[i])
+			continue
+		}
+		if SkipDestructive(ctx, dst, "delete") {
+			tr.Done(nil)
+			continue
+		}
+		toDelete = append(toDelete, dst)
+		indexes = append(indexes, i)
+		trs = append(trs, tr)
+	}
+	if len(toDelete) == 0 {
+		return errs
+	}
+	fs.Debugf(f, "Deleting %d files in a batch", len(toDelete))
+	var batchErrs []error
+	err := waitDeleteToken(ctx)
+	if err != nil {
+		batchErrs = make([]error, len(toDelete))
+		for j := range batchErrs {
+			batchErrs[j] = err
+		}
+	} else {
+		batchErrs = doBatch(deleteContext(ctx, f), toDelete)
+	}
+	for j, dst := range toDelete {
+		var err error
+		if j < len(batchErrs) {
+			err = batchErrs[j]
+		} else {
+			err = errors.New("no result returned from batch delete")
+		}
+		if err != nil {
+			fs.Errorf(dst, "Couldn't delete: %v", err)
+			err = fs.CountError(err)
+			recordFailure(ctx, FailedDelete, nil, dst.Fs(), dst.Remote(), err)
+		} else {
+			fs.Infof(dst, "Deleted")
+		}
+		trs[j].Done(err)
+		errs[indexes[j]] = err
+	}
+	return errs
+}
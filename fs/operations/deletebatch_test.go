@@ -0,0 +1,133 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/sched"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchFs is a mock Fs which supports DeleteBatch
+type batchFs struct {
+	*mockfs.Fs
+	features *fs.Features
+	mu       sync.Mutex
+	batches  [][]string
+}
+
+func newBatchFs() *batchFs {
+	return newBatchFsNamed("batch")
+}
+
+func newBatchFsNamed(name string) *batchFs {
+	f := &batchFs{Fs: mockfs.NewFs(name, "root")}
+	f.features = (&fs.Features{}).Fill(f)
+	return f
+}
+
+func (f *batchFs) Features() *fs.Features {
+	return f.features
+}
+
+func (f *batchFs) DeleteBatch(ctx context.Context, objs []fs.Object) []error {
+	errs := make([]error, len(objs))
+	// Wait for the tpslimit like the HTTP request of a batch would
+	if err := sched.PaceRequest(ctx); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	var names []string
+	for i, o := range objs {
+		names = append(names, o.Remote())
+		if o.Remote() == "fail" {
+			errs[i] = errors.New("failed")
+		}
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, names)
+	f.mu.Unlock()
+	return errs
+}
+
+// batchObject is a mock Object in a batchFs
+type batchObject struct {
+	mockobject.Object
+	f *batchFs
+}
+
+func (o batchObject) Fs() fs.Info {
+	return o.f
+}
+
+func TestDeleteFilesBatch(t *testing.T) {
+	ctx := context.Background()
+	oldTransfers, oldDryRun := fs.Config.Transfers, fs.Config.DryRun
+	defer func() {
+		fs.Config.Transfers, fs.Config.DryRun = oldTransfers, oldDryRun
+	}()
+	fs.Config.Transfers = 1
+	names := []string{"a", "b", "fail", "c"}
+	deleteAll := func(f *batchFs) error {
+		toBeDeleted := make(fs.ObjectsChan, len(names))
+		for _, name := range names {
+			toBeDeleted <- batchObject{Object: mockobject.New(name), f: f}
+		}
+		close(toBeDeleted)
+		return DeleteFiles(ctx, toBeDeleted)
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	f := newBatchFs()
+	err := deleteAll(f)
+	require.Error(t, err)
+	assert.Equal(t, "failed to delete 1 files", err.Error())
+	assert.Equal(t, [][]string{names}, f.batches)
+	assert.Equal(t, int64(len(names)), accounting.GlobalStats().Deletes(0))
+
+	fs.Config.DryRun = true
+	f = newBatchFs()
+	require.NoError(t, deleteAll(f))
+	assert.Nil(t, f.batches)
+}
+
+func TestDeleteBatchPaced(t *testing.T) {
+	name := "batch-paced"
+	for key, value := range map[string]string{
+		"tpslimit":       "1",
+		"tpslimit_burst": "1",
+	} {
+		env := fs.ConfigToEnv(name, key)
+		require.NoError(t, os.Setenv(env, value))
+		defer func() { _ = os.Unsetenv(env) }()
+	}
+	f := newBatchFsNamed(name)
+	objs := []fs.Object{
+		batchObject{Object: mockobject.New("a"), f: f},
+		batchObject{Object: mockobject.New("b"), f: f},
+	}
+
+	// The request of the first batch uses the burst and the second
+	// would have to wait a second for the tpslimit, longer than the
+	// timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errs := deleteBatch(ctx, f.DeleteBatch, objs)
+	assert.Equal(t, []error{nil, nil}, errs)
+	errs = deleteBatch(ctx, f.DeleteBatch, objs)
+	require.Len(t, errs, 2)
+	assert.Error(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.Equal(t, [][]string{{"a", "b"}}, f.batches)
+}
@@ -0,0 +1,63 @@
+package operations
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/sched"
+	"golang.org/x/time/rate"
+)
+
+// The token bucket for --delete-tpslimit. It is made when first
+// needed and again if the limits change.
+var (
+	deleteBucketMu    sync.Mutex
+	deleteBucket      *rate.Limiter // nil if deletions aren't limited
+	deleteBucketLimit float64       // --delete-tpslimit deleteBucket was made for
+	deleteBucketBurst int           // --delete-tpslimit-burst deleteBucket was made for
+)
+
+// waitDeleteToken waits until a deletion is allowed by
+// --delete-tpslimit. A batch of deletions made in one call counts as
+// one.
+func waitDeleteToken(ctx context.Context) error {
+	deleteBucketMu.Lock()
+	if fs.Config.DeleteTPSLimit != deleteBucketLimit || fs.Config.DeleteTPSLimitBurst != deleteBucketBurst {
+		deleteBucketLimit, deleteBucketBurst = fs.Config.DeleteTPSLimit, fs.Config.DeleteTPSLimitBurst
+		deleteBucket = nil
+		if deleteBucketLimit > 0 {
+			burst := deleteBucketBurst
+			if burst < 1 {
+				burst = 1
+			}
+			deleteBucket = rate.NewLimiter(rate.Limit(deleteBucketLimit), burst)
+			fs.Infof(nil, "Starting deletion limiter: max %g deletions/s with burst %d", deleteBucketLimit, burst)
+		}
+	}
+	bucket := deleteBucket
+	deleteBucketMu.Unlock()
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Wait(ctx)
+}
+
+// deleteContext returns the context to delete objects in f with,
+// paced by the tpslimit of f. Deletions limited by --delete-tpslimit
+// don't wait for the global --tpslimit as well.
+func deleteContext(ctx context.Context, f fs.Info) context.Context {
+	ctx = sched.WithPace(ctx, f, sched.OpDelete)
+	if fs.Config.DeleteTPSLimit > 0 {
+		ctx = sched.WithoutTPSLimit(ctx)
+	}
+	return ctx
+}
+
+// deleteConcurrency returns the number of deletions to run at once
+func deleteConcurrency() int {
+	if fs.Config.DeleteConcurrency > 0 {
+		return fs.Config.DeleteConcurrency
+	}
+	return fs.Config.Transfers
+}
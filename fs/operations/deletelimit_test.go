@@ -0,0 +1,66 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteConcurrency(t *testing.T) {
+	oldTransfers, oldConcurrency := fs.Config.Transfers, fs.Config.DeleteConcurrency
+	defer func() {
+		fs.Config.Transfers, fs.Config.DeleteConcurrency = oldTransfers, oldConcurrency
+	}()
+	fs.Config.Transfers = 4
+	fs.Config.DeleteConcurrency = 0
+	assert.Equal(t, 4, deleteConcurrency())
+	fs.Config.DeleteConcurrency = 1
+	assert.Equal(t, 1, deleteConcurrency())
+}
+
+func TestDeleteTPSLimit(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldLimit, oldBurst := fs.Config.DeleteTPSLimit, fs.Config.DeleteTPSLimitBurst
+	defer func() {
+		fs.Config.DeleteTPSLimit, fs.Config.DeleteTPSLimitBurst = oldLimit, oldBurst
+	}()
+	fs.Config.DeleteTPSLimit = 20
+	fs.Config.DeleteTPSLimitBurst = 1
+
+	const n = 5
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	var items []fstest.Item
+	for i := 0; i < n; i++ {
+		items = append(items, r.WriteObject(ctx, fmt.Sprintf("file%d", i), "contents", t1))
+	}
+	fstest.CheckItems(t, r.Fremote, items...)
+
+	objs := make(fs.ObjectsChan, n)
+	for _, item := range items {
+		o, err := r.Fremote.NewObject(ctx, item.Path)
+		require.NoError(t, err)
+		objs <- o
+	}
+	close(objs)
+
+	// The first is allowed straight away then one every 50ms
+	start := time.Now()
+	require.NoError(t, DeleteFiles(ctx, objs))
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= (n-1)*50*time.Millisecond-10*time.Millisecond, "too quick: %v", elapsed)
+	fstest.CheckItems(t, r.Fremote)
+
+	// Turning the limit off removes the bucket
+	fs.Config.DeleteTPSLimit = 0
+	require.NoError(t, waitDeleteToken(ctx))
+	assert.Nil(t, deleteBucket)
+}
@@ -0,0 +1,195 @@
+package operations
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// Return a boolean as to whether we should use a delta transfer to
+// update dst with src
+func doDeltaCopy(dst fs.Object, src fs.Object) bool {
+	// Disable delta transfers if...
+
+	// ...they aren't configured
+	if !fs.Config.DeltaTransfer || fs.Config.DeltaBlockSize <= 0 {
+		return false
+	}
+	// ...there is nothing to compare against
+	if dst == nil || src.Size() < 0 || dst.Size() <= 0 {
+		return false
+	}
+	// ...size of object is less than cutoff
+	if src.Size() < int64(fs.Config.DeltaCutoff) {
+		return false
+	}
+	// ...destination can't do it
+	if _, ok := dst.(fs.BlockHasher); !ok {
+		return false
+	}
+	if _, ok := dst.(fs.PartialUpdater); !ok {
+		return false
+	}
+	return true
+}
+
+// deltaCopyState is the state of a delta transfer
+type deltaCopyState struct {
+	src       fs.Object
+	blockSize int64
+	size      int64
+	dstHashes []string
+	wc        fs.WriterAtCloser
+	changed   int // number of blocks written
+	blocks    int // total number of blocks
+}
+
+// blockChanged returns whether block i with hash sum needs writing
+func (ds *deltaCopyState) blockChanged(i int, sum string) bool {
+	return i >= len(ds.dstHashes) || ds.dstHashes[i] != sum
+}
+
+// copyAll streams the whole of the source comparing each block with
+// the destination and writing only the blocks which differ
+func (ds *deltaCopyState) copyAll(ctx context.Context, tr *accounting.Transfer) (err error) {
+	in0, err := NewReOpen(ctx, ds.src, fs.Config.LowLevelRetries)
+	if err != nil {
+		return errors.Wrap(err, "delta copy: failed to open source")
+	}
+	in := tr.Account(ctx, in0).WithBuffer()
+	defer fs.CheckClose(in, &err)
+
+	buf := make([]byte, ds.blockSize)
+	for i, offset := 0, int64(0); offset < ds.size; i, offset = i+1, offset+ds.blockSize {
+		n, err := io.ReadFull(in, buf)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			if int64(n) != ds.size-offset {
+				return errors.Wrap(io.ErrUnexpectedEOF, "delta copy: source truncated")
+			}
+		} else if err != nil {
+			return errors.Wrap(err, "delta copy: failed to read source")
+		}
+		block := buf[:n]
+		sum := md5.Sum(block)
+		ds.blocks++
+		if !ds.blockChanged(i, hex.EncodeToString(sum[:])) {
+			continue
+		}
+		ds.changed++
+		_, err = ds.wc.WriteAt(block, offset)
+		if err != nil {
+			return errors.Wrap(err, "delta copy: failed to write block")
+		}
+	}
+	return nil
+}
+
+// copyChanged compares the block hashes of source and destination and
+// reads only the changed blocks from the source
+func (ds *deltaCopyState) copyChanged(ctx context.Context, tr *accounting.Transfer, srcHashes []string) (err error) {
+	buf := make([]byte, ds.blockSize)
+	for i, sum := range srcHashes {
+		ds.blocks++
+		if !ds.blockChanged(i, sum) {
+			continue
+		}
+		ds.changed++
+		offset := int64(i) * ds.blockSize
+		end := offset + ds.blockSize
+		if end > ds.size {
+			end = ds.size
+		}
+		err = func() (err error) {
+			in0, err := NewReOpen(ctx, ds.src, fs.Config.LowLevelRetries, &fs.RangeOption{Start: offset, End: end - 1})
+			if err != nil {
+				return errors.Wrap(err, "delta copy: failed to open source")
+			}
+			in := tr.Account(ctx, in0)
+			defer fs.CheckClose(in, &err)
+			n, err := io.ReadFull(in, buf[:end-offset])
+			if err != nil {
+				return errors.Wrap(err, "delta copy: failed to read source")
+			}
+			_, err = ds.wc.WriteAt(buf[:n], offset)
+			if err != nil {
+				return errors.Wrap(err, "delta copy: failed to write block")
+			}
+			return nil
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deltaCopy updates dst from src writing only the blocks
+// which have changed.
+//
+// If the source can calculate its own block hashes then only the
+// changed blocks are read from it, otherwise the source is read in
+// full but only the changed blocks are written to the destination.
+func deltaCopy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Object, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	ds := &deltaCopyState{
+		src:       src,
+		blockSize: int64(fs.Config.DeltaBlockSize),
+		size:      src.Size(),
+	}
+	ds.dstHashes, err = dst.(fs.BlockHasher).BlockHashes(ctx, ds.blockSize)
+	if err == hash.ErrUnsupported {
+		fs.Debugf(dst, "delta copy: destination can't read block hashes - writing all blocks")
+		ds.dstHashes = nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "delta copy: failed to read destination block hashes")
+	}
+	var srcHashes []string
+	if do, ok := src.(fs.BlockHasher); ok {
+		srcHashes, err = do.BlockHashes(ctx, ds.blockSize)
+		wantBlocks := int((ds.size + ds.blockSize - 1) / ds.blockSize)
+		if err != nil {
+			fs.Debugf(src, "delta copy: failed to read source block hashes - reading whole source: %v", err)
+			srcHashes = nil
+		} else if len(srcHashes) != wantBlocks {
+			fs.Debugf(src, "delta copy: expecting %d source block hashes but got %d - reading whole source", wantBlocks, len(srcHashes))
+			srcHashes = nil
+		}
+	}
+
+	ds.wc, err = dst.(fs.PartialUpdater).OpenPartialUpdate(ctx, ds.size)
+	if err != nil {
+		return nil, errors.Wrap(err, "delta copy: failed to open destination")
+	}
+	if srcHashes != nil {
+		err = ds.copyChanged(ctx, tr, srcHashes)
+	} else {
+		err = ds.copyAll(ctx, tr)
+	}
+	if err != nil {
+		if do, ok := ds.wc.(fs.PartialUpdateAborter); ok {
+			abortErr := do.Abort()
+			if abortErr != nil {
+				fs.Debugf(dst, "delta copy: failed to abort update: %v", abortErr)
+			}
+		} else {
+			_ = ds.wc.Close()
+		}
+		return nil, err
+	}
+	closeErr := ds.wc.Close()
+	if closeErr != nil {
+		return nil, errors.Wrap(closeErr, "delta copy: failed to close destination")
+	}
+	fs.Debugf(src, "delta copy: wrote %d/%d blocks of %v", ds.changed, ds.blocks, fs.SizeSuffix(ds.blockSize))
+
+	err = dst.SetModTime(ctx, src.ModTime(ctx))
+	if err != nil && err != fs.ErrorCantSetModTime && err != fs.ErrorCantSetModTimeWithoutDelete {
+		return nil, errors.Wrap(err, "delta copy: failed to set modification time")
+	}
+	return f.NewObject(ctx, remote)
+}
@@ -0,0 +1,158 @@
+package operations
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoDeltaCopy(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldDelta := fs.Config.DeltaTransfer
+	oldCutoff := fs.Config.DeltaCutoff
+	oldBlockSize := fs.Config.DeltaBlockSize
+	defer func() {
+		fs.Config.DeltaTransfer = oldDelta
+		fs.Config.DeltaCutoff = oldCutoff
+		fs.Config.DeltaBlockSize = oldBlockSize
+	}()
+	fs.Config.DeltaTransfer, fs.Config.DeltaCutoff, fs.Config.DeltaBlockSize = true, 50, 16
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	r.WriteFile("file1", strings.Repeat("a", 100), t1)
+	dst, err := r.Flocal.NewObject(ctx, "file1")
+	require.NoError(t, err)
+
+	src := mockobject.New("file").WithContent(make([]byte, 100), mockobject.SeekModeNone)
+	assert.True(t, doDeltaCopy(dst, src))
+
+	assert.False(t, doDeltaCopy(nil, src))
+	assert.False(t, doDeltaCopy(mockobject.New("file").WithContent(make([]byte, 100), mockobject.SeekModeNone), src))
+
+	small := mockobject.New("file").WithContent(make([]byte, 49), mockobject.SeekModeNone)
+	assert.False(t, doDeltaCopy(dst, small))
+
+	fs.Config.DeltaTransfer = false
+	assert.False(t, doDeltaCopy(dst, src))
+	fs.Config.DeltaTransfer = true
+
+	fs.Config.DeltaBlockSize = 0
+	assert.False(t, doDeltaCopy(dst, src))
+}
+
+func TestDeltaCopy(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	oldBlockSize := fs.Config.DeltaBlockSize
+	defer func() {
+		fs.Config.DeltaBlockSize = oldBlockSize
+	}()
+	const blockSize = 64
+	fs.Config.DeltaBlockSize = blockSize
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	base := random.String(blockSize * 4)
+	for _, test := range []struct {
+		name     string
+		contents string
+	}{
+		{name: "same", contents: base},
+		{name: "changed block", contents: base[:blockSize] + strings.Repeat("x", blockSize) + base[2*blockSize:]},
+		{name: "grown", contents: base + "extra"},
+		{name: "shrunk", contents: base[:blockSize*2+5]},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var err error
+			r.WriteFile("file1", base, t1)
+			file1 := r.WriteObject(ctx, "file1", test.contents, t2)
+
+			src, err := r.Fremote.NewObject(ctx, "file1")
+			require.NoError(t, err)
+			dst, err := r.Flocal.NewObject(ctx, "file1")
+			require.NoError(t, err)
+			accounting.GlobalStats().ResetCounters()
+			tr := accounting.GlobalStats().NewTransfer(src)
+			defer func() {
+				tr.Done(err)
+			}()
+
+			newDst, err := deltaCopy(ctx, r.Flocal, dst, "file1", src, tr)
+			require.NoError(t, err)
+			assert.Equal(t, src.Size(), newDst.Size())
+			fstest.CheckListingWithPrecision(t, r.Flocal, []fstest.Item{file1}, nil, fs.GetModifyWindow(r.Flocal, r.Fremote))
+		})
+	}
+}
+
+// abortDst is a destination for a delta copy which records whether
+// the update was closed or aborted
+type abortDst struct {
+	fs.Object
+	closed  bool
+	aborted bool
+}
+
+func (o *abortDst) BlockHashes(ctx context.Context, blockSize int64) ([]string, error) {
+	return nil, hash.ErrUnsupported
+}
+
+func (o *abortDst) OpenPartialUpdate(ctx context.Context, size int64) (fs.WriterAtCloser, error) {
+	return o, nil
+}
+
+func (o *abortDst) WriteAt(p []byte, off int64) (int, error) {
+	return len(p), nil
+}
+
+func (o *abortDst) Close() error {
+	o.closed = true
+	return nil
+}
+
+func (o *abortDst) Abort() error {
+	o.aborted = true
+	return nil
+}
+
+// truncatedObject is a source which is shorter than its size
+type truncatedObject struct {
+	fs.Object
+}
+
+func (o truncatedObject) Size() int64 {
+	return o.Object.Size() + 10
+}
+
+func TestDeltaCopyAbort(t *testing.T) {
+	ctx := context.Background()
+	oldBlockSize := fs.Config.DeltaBlockSize
+	defer func() {
+		fs.Config.DeltaBlockSize = oldBlockSize
+	}()
+	fs.Config.DeltaBlockSize = 16
+
+	src := truncatedObject{mockobject.New("file").WithContent(make([]byte, 100), mockobject.SeekModeNone)}
+	dst := &abortDst{Object: mockobject.New("file").WithContent(make([]byte, 100), mockobject.SeekModeNone)}
+	tr := accounting.GlobalStats().NewTransfer(src)
+	_, err := deltaCopy(ctx, nil, dst, "file", src, tr)
+	tr.Done(err)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "source truncated")
+	assert.True(t, dst.aborted)
+	assert.False(t, dst.closed)
+}
@@ -0,0 +1,248 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+)
+
+// Operations recorded in the failure report
+const (
+	FailedCopy   = "copy"
+	FailedMove   = "move"
+	FailedDelete = "delete"
+	FailedBackup = "backup"
+)
+
+// Failure is an operation on a file which failed
+type Failure struct {
+	Op        string `json:"op"`                  // FailedCopy, FailedMove, FailedDelete or FailedBackup
+	Src       string `json:"src,omitempty"`       // remote copied or moved from
+	SrcRemote string `json:"srcRemote,omitempty"` // path of the file in Src
+	Dst       string `json:"dst"`                 // remote copied or moved to or deleted from
+	DstRemote string `json:"dstRemote"`           // path of the file in Dst
+	Error     string `json:"error"`
+}
+
+// FailureReport is the report of failed operations written with
+// --failure-report
+type FailureReport struct {
+	Time     time.Time `json:"time"`
+	Failures []Failure `json:"failures"`
+}
+
+// failures records the failed operations for --failure-report
+var failures = struct {
+	mu sync.Mutex
+	m  map[string]Failure // indexed by op and destination
+}{
+	m: map[string]Failure{},
+}
+
+// nestedOperationKey marks a context as being used for an operation
+// which is part of a bigger one which records its own failure
+type nestedOperationKey struct{}
+
+// nestedOperation returns a context for the operations making up a
+// bigger one so their failures aren't recorded separately
+func nestedOperation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nestedOperationKey{}, true)
+}
+
+// infoString returns the config string for f
+func infoString(f fs.Info) string {
+	if f, ok := f.(fs.Fs); ok {
+		return fs.ConfigString(f)
+	}
+	return f.Name() + ":" + f.Root()
+}
+
+// recordFailure records op failing on dstRemote in fdst with err if
+// --failure-report is in use. src is the object copied, moved or
+// backed up or nil for a delete.
+func recordFailure(ctx context.Context, op string, src fs.Object, fdst fs.Info, dstRemote string, err error) {
+	if err == nil || fs.Config.FailureReport == "" || ctx.Value(nestedOperationKey{}) != nil {
+		return
+	}
+	failure := Failure{
+		Op:        op,
+		Dst:       infoString(fdst),
+		DstRemote: dstRemote,
+		Error:     err.Error(),
+	}
+	if src != nil {
+		failure.Src = infoString(src.Fs())
+		failure.SrcRemote = src.Remote()
+	}
+	failures.mu.Lock()
+	failures.m[op+"\x00"+failure.Dst+"\x00"+dstRemote] = failure
+	failures.mu.Unlock()
+}
+
+// ResetFailures forgets the failed operations recorded so far
+func ResetFailures() {
+	failures.mu.Lock()
+	failures.m = map[string]Failure{}
+	failures.mu.Unlock()
+}
+
+// Failures returns the failed operations recorded so far
+func Failures() *FailureReport {
+	failures.mu.Lock()
+	defer failures.mu.Unlock()
+	report := &FailureReport{
+		Time:     time.Now(),
+		Failures: []Failure{},
+	}
+	for _, failure := range failures.m {
+		report.Failures = append(report.Failures, failure)
+	}
+	sort.Slice(report.Failures, func(i, j int) bool {
+		a, b := report.Failures[i], report.Failures[j]
+		if a.Dst != b.Dst {
+			return a.Dst < b.Dst
+		}
+		if a.DstRemote != b.DstRemote {
+			return a.DstRemote < b.DstRemote
+		}
+		return a.Op < b.Op
+	})
+	return report
+}
+
+// WriteFailureReport writes the failed operations recorded so far to
+// path
+func WriteFailureReport(path string) error {
+	report := Failures()
+	data, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, append(data, '\n'), 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write failure report")
+	}
+	if len(report.Failures) > 0 {
+		fs.Logf(nil, "Wrote %d failed operations to failure report %q", len(report.Failures), path)
+	}
+	return nil
+}
+
+// ReadFailureReport reads the failure report at path
+func ReadFailureReport(path string) (*FailureReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read failure report")
+	}
+	var report FailureReport
+	err = json.Unmarshal(data, &report)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse failure report %q", path)
+	}
+	return &report, nil
+}
+
+// retryFailure tries the failed operation again
+func retryFailure(ctx context.Context, failure Failure) error {
+	fdst, err := cache.Get(failure.Dst)
+	if err != nil {
+		return err
+	}
+	switch failure.Op {
+	case FailedCopy, FailedMove:
+		fsrc, err := cache.Get(failure.Src)
+		if err != nil {
+			return err
+		}
+		if failure.Op == FailedMove {
+			return MoveFile(ctx, fdst, fsrc, failure.DstRemote, failure.SrcRemote)
+		}
+		return CopyFile(ctx, fdst, fsrc, failure.DstRemote, failure.SrcRemote)
+	case FailedDelete:
+		dst, err := fdst.NewObject(ctx, failure.DstRemote)
+		if err == fs.ErrorObjectNotFound {
+			fs.Debugf(failure.DstRemote, "Already deleted")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return DeleteFile(ctx, dst)
+	case FailedBackup:
+		// Never retry a backup with a delete - if the file can't be
+		// moved into the backup dir it must be left where it is.
+		fsrc, err := cache.Get(failure.Src)
+		if err != nil {
+			return err
+		}
+		src, err := fsrc.NewObject(ctx, failure.SrcRemote)
+		if err == fs.ErrorObjectNotFound {
+			fs.Debugf(failure.SrcRemote, "Already moved into backup dir")
+			return nil
+		} else if err != nil {
+			return err
+		}
+		overwritten, _ := fdst.NewObject(ctx, failure.DstRemote)
+		_, err = Move(ctx, fdst, overwritten, failure.DstRemote, src)
+		return err
+	}
+	return errors.Errorf("unknown operation %q", failure.Op)
+}
+
+// RetryFailures tries the operations in report again, using
+// --transfers at once.
+//
+// The copies and moves are done before the deletes and the moves into
+// the backup dir as they would be in a sync.
+func RetryFailures(ctx context.Context, report *FailureReport) error {
+	var transfers, deletes []Failure
+	for _, failure := range report.Failures {
+		if failure.Op == FailedDelete || failure.Op == FailedBackup {
+			deletes = append(deletes, failure)
+		} else {
+			transfers = append(transfers, failure)
+		}
+	}
+	var (
+		errorMu    sync.Mutex
+		errorCount int
+	)
+	retry := func(failures []Failure) {
+		var (
+			in = make(chan Failure, fs.Config.Transfers)
+			wg sync.WaitGroup
+		)
+		wg.Add(fs.Config.Transfers)
+		for i := 0; i < fs.Config.Transfers; i++ {
+			go func() {
+				defer wg.Done()
+				for failure := range in {
+					err := retryFailure(ctx, failure)
+					if err != nil {
+						fs.Errorf(failure.DstRemote, "Failed to retry %s: %v", failure.Op, err)
+						errorMu.Lock()
+						errorCount++
+						errorMu.Unlock()
+					}
+				}
+			}()
+		}
+		for _, failure := range failures {
+			in <- failure
+		}
+		close(in)
+		wg.Wait()
+	}
+	retry(transfers)
+	retry(deletes)
+	if errorCount > 0 {
+		return errors.Errorf("failed to retry %d of %d operations", errorCount, len(report.Failures))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package operations
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureReport(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer accounting.GlobalStats().ResetCounters()
+
+	dir, err := ioutil.TempDir("", "rclone-failure-report")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "report.json")
+	fs.Config.FailureReport = path
+	defer func() {
+		fs.Config.FailureReport = ""
+		ResetFailures()
+	}()
+	ResetFailures()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	r.WriteObject(ctx, "file2", "file2 contents", t1)
+
+	// Copy a source which has gone
+	src, err := r.Flocal.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	require.NoError(t, src.Remove(ctx))
+	_, err = Copy(ctx, r.Fremote, nil, "file1", src)
+	require.Error(t, err)
+
+	// Delete a file which has gone
+	dst, err := r.Fremote.NewObject(ctx, "file2")
+	require.NoError(t, err)
+	require.NoError(t, dst.Remove(ctx))
+	require.Error(t, DeleteFile(ctx, dst))
+
+	require.NoError(t, WriteFailureReport(path))
+	report, err := ReadFailureReport(path)
+	require.NoError(t, err)
+	require.Len(t, report.Failures, 2)
+	assert.Equal(t, FailedCopy, report.Failures[0].Op)
+	assert.Equal(t, fs.ConfigString(r.Flocal), report.Failures[0].Src)
+	assert.Equal(t, "file1", report.Failures[0].SrcRemote)
+	assert.Equal(t, fs.ConfigString(r.Fremote), report.Failures[0].Dst)
+	assert.Equal(t, "file1", report.Failures[0].DstRemote)
+	assert.NotEqual(t, "", report.Failures[0].Error)
+	assert.Equal(t, Failure{
+		Op:        FailedDelete,
+		Dst:       fs.ConfigString(r.Fremote),
+		DstRemote: "file2",
+		Error:     report.Failures[1].Error,
+	}, report.Failures[1])
+
+	// Put things right and retry
+	r.WriteFile("file1", "file1 contents", t1)
+	r.WriteObject(ctx, "file2", "file2 contents", t1)
+	ResetFailures()
+	require.NoError(t, RetryFailures(ctx, report))
+	fstest.CheckItems(t, r.Fremote, file1)
+	assert.Len(t, Failures().Failures, 0)
+}
+
+func TestFailureReportBackupDir(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer accounting.GlobalStats().ResetCounters()
+	if !CanServerSideMove(r.Fremote) {
+		t.Skip("Skipping test as remote does not support server side move or copy")
+	}
+
+	fs.Config.FailureReport = "unused"
+	defer func() {
+		fs.Config.FailureReport = ""
+		ResetFailures()
+	}()
+	ResetFailures()
+
+	backupDir, err := fs.NewFs(r.FremoteName + "/backup")
+	require.NoError(t, err)
+
+	// Move a file which has gone into the backup dir
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	dst, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	require.NoError(t, dst.Remove(ctx))
+	require.Error(t, DeleteFileWithBackupDir(ctx, dst, backupDir))
+
+	report := Failures()
+	require.Len(t, report.Failures, 1)
+	assert.Equal(t, FailedBackup, report.Failures[0].Op)
+	assert.Equal(t, fs.ConfigString(r.Fremote), report.Failures[0].Src)
+	assert.Equal(t, "file1", report.Failures[0].SrcRemote)
+	assert.Equal(t, fs.ConfigString(backupDir), report.Failures[0].Dst)
+	assert.Equal(t, "file1", report.Failures[0].DstRemote)
+
+	// Put things right and retry - the file must be backed up not deleted
+	r.WriteObject(ctx, "file1", "file1 contents", t1)
+	ResetFailures()
+	require.NoError(t, RetryFailures(ctx, report))
+	file1.Path = "backup/file1"
+	fstest.CheckItems(t, r.Fremote, file1)
+	assert.Len(t, Failures().Failures, 0)
+}
@@ -0,0 +1,83 @@
+package operations
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+var (
+	fallbackMu     sync.Mutex
+	fallbackConfig string  // the --source-fallback values the Fses were made from
+	fallbackFses   []fs.Fs // the Fses made from --source-fallback
+)
+
+// sourceFallbacks returns the Fses made from --source-fallback
+func sourceFallbacks() []fs.Fs {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	config := strings.Join(fs.Config.SourceFallback, "\x00")
+	if config == fallbackConfig {
+		return fallbackFses
+	}
+	fallbackConfig = config
+	fallbackFses = nil
+	for _, remote := range fs.Config.SourceFallback {
+		f, err := cache.Get(remote)
+		if err != nil {
+			fs.Errorf(nil, "Ignoring --source-fallback %q: %v", remote, err)
+			continue
+		}
+		fallbackFses = append(fallbackFses, f)
+	}
+	return fallbackFses
+}
+
+// SourceFallback finds a copy of src in the --source-fallback remotes
+// to read instead of src, returning nil if there isn't one.
+//
+// The copy must be at the same path as src and be the same size. If
+// they have a hash in common it must match, otherwise the
+// modification times must match.
+func SourceFallback(ctx context.Context, src fs.Object) fs.Object {
+	if len(fs.Config.SourceFallback) == 0 {
+		return nil
+	}
+	srcFs := src.Fs()
+	for _, f := range sourceFallbacks() {
+		if f.Name() == srcFs.Name() && f.Root() == srcFs.Root() {
+			continue
+		}
+		o, err := f.NewObject(ctx, src.Remote())
+		if err != nil {
+			fs.Debugf(src, "Can't use fallback %v: %v", f, err)
+			continue
+		}
+		if o.Size() != src.Size() {
+			fs.Debugf(src, "Can't use fallback %v: sizes differ", f)
+			continue
+		}
+		equal, ht, err := CheckHashes(ctx, src, o)
+		if err == nil && ht != hash.None {
+			if !equal {
+				fs.Debugf(src, "Can't use fallback %v: %v hashes differ", f, ht)
+				continue
+			}
+			return o
+		}
+		dt := o.ModTime(ctx).Sub(src.ModTime(ctx))
+		if dt < 0 {
+			dt = -dt
+		}
+		if dt > fs.GetModifyWindow(srcFs, f) {
+			fs.Debugf(src, "Can't use fallback %v: modification times differ", f)
+			continue
+		}
+		return o
+	}
+	return nil
+}
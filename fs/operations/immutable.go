@@ -0,0 +1,162 @@
+package operations
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// tamperFile is the state of one side of an immutable file in the
+// tamper report
+type tamperFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// tamperReport is written to --immutable-report for each immutable
+// file found to be modified
+type tamperReport struct {
+	Time       time.Time  `json:"time"`
+	Remote     string     `json:"remote"`
+	HashType   string     `json:"hashType,omitempty"`
+	Source     tamperFile `json:"source"`
+	Dest       tamperFile `json:"dest"`
+	Quarantine string     `json:"quarantine,omitempty"` // where the source was copied to
+}
+
+// tamperReportMu serialises writes to --immutable-report
+var tamperReportMu sync.Mutex
+
+// objectPath returns o as remote:path
+func objectPath(o fs.Object) string {
+	f := o.Fs()
+	return f.Name() + ":" + path.Join(f.Root(), o.Remote())
+}
+
+// newTamperFile reads the state of o for the tamper report
+func newTamperFile(ctx context.Context, o fs.Object, ht hash.Type) (tf tamperFile, err error) {
+	tf = tamperFile{
+		Path:    objectPath(o),
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx),
+	}
+	if ht != hash.None {
+		tf.Hash, err = o.Hash(ctx, ht)
+	}
+	return tf, err
+}
+
+// CheckImmutable is called with --immutable when the existing dst
+// doesn't match src so would have been updated.
+//
+// It compares the size, hash and modification time of src and dst.
+// If they are all the same it returns nil. Otherwise, or if src and
+// dst don't have a hash in common so their contents can't be compared
+// without reading both, it writes the details to --immutable-report,
+// copies src into --immutable-quarantine if set and returns
+// fs.ErrorImmutableModified.
+func CheckImmutable(ctx context.Context, dst, src fs.Object) error {
+	if !fs.Config.Immutable {
+		return nil
+	}
+	ht := src.Fs().Hashes().Overlap(dst.Fs().Hashes()).GetOne()
+	report := tamperReport{
+		Time:   time.Now(),
+		Remote: src.Remote(),
+	}
+	if ht != hash.None {
+		report.HashType = ht.String()
+	}
+	var err error
+	report.Source, err = newTamperFile(ctx, src, ht)
+	if err != nil {
+		return errors.Wrap(err, "failed to read source hash")
+	}
+	report.Dest, err = newTamperFile(ctx, dst, ht)
+	if err != nil {
+		return errors.Wrap(err, "failed to read destination hash")
+	}
+	modifyWindow := fs.GetModifyWindow(src.Fs(), dst.Fs())
+	var differ bool
+	switch {
+	case report.Source.Size >= 0 && report.Dest.Size >= 0 && report.Source.Size != report.Dest.Size:
+		differ = true
+	case report.Source.Hash == "" || report.Dest.Hash == "":
+		fs.Errorf(dst, "No hash in common with the source to check the immutable file with")
+		differ = true
+	case report.Source.Hash != report.Dest.Hash:
+		differ = true
+	case modifyWindow != fs.ModTimeNotSupported:
+		dt := report.Source.ModTime.Sub(report.Dest.ModTime)
+		differ = dt >= modifyWindow || dt <= -modifyWindow
+	}
+	if !differ {
+		fs.Debugf(dst, "Immutable file has the same size, hash and modification time as the source")
+		return nil
+	}
+	fs.Errorf(dst, "Source and destination exist but do not match: immutable file modified")
+	if fs.Config.ImmutableQuarantine != "" {
+		report.Quarantine, err = quarantine(ctx, src)
+		if err != nil {
+			fs.Errorf(src, "Failed to quarantine: %v", err)
+		}
+	}
+	if fs.Config.ImmutableReport != "" {
+		err = writeTamperReport(&report)
+		if err != nil {
+			fs.Errorf(dst, "Failed to write to immutable report: %v", err)
+		}
+	}
+	return fs.ErrorImmutableModified
+}
+
+// quarantine copies src into --immutable-quarantine, returning where
+// it was copied to
+func quarantine(ctx context.Context, src fs.Object) (string, error) {
+	name := ExpandBackupTemplate(fs.Config.ImmutableQuarantine, getBackupTime())
+	fq, err := cache.Get(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to make fs for --immutable-quarantine %q", name)
+	}
+	existing, _ := fq.NewObject(ctx, src.Remote())
+	newDst, err := Copy(ctx, fq, existing, src.Remote(), src)
+	if err != nil {
+		return "", err
+	}
+	if newDst == nil {
+		return "", nil // --dry-run
+	}
+	fs.Logf(src, "Quarantined modified version to %s", objectPath(newDst))
+	return objectPath(newDst), nil
+}
+
+// writeTamperReport appends report as a line of JSON to
+// --immutable-report
+func writeTamperReport(report *tamperReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	tamperReportMu.Lock()
+	defer tamperReportMu.Unlock()
+	out, err := os.OpenFile(fs.Config.ImmutableReport, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	closeErr := out.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
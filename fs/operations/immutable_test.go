@@ -0,0 +1,96 @@
+package operations
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckImmutable(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-immutable")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	reportPath := filepath.Join(dir, "report.json")
+	quarantinePath := filepath.Join(dir, "quarantine")
+	oldImmutable, oldReport, oldQuarantine := fs.Config.Immutable, fs.Config.ImmutableReport, fs.Config.ImmutableQuarantine
+	fs.Config.ImmutableReport = reportPath
+	fs.Config.ImmutableQuarantine = quarantinePath
+	defer func() {
+		fs.Config.Immutable, fs.Config.ImmutableReport, fs.Config.ImmutableQuarantine = oldImmutable, oldReport, oldQuarantine
+	}()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	r.WriteFile("same", "same contents", t1)
+	r.WriteObject(ctx, "same", "same contents", t1)
+	r.WriteFile("touched", "same contents", t2)
+	r.WriteObject(ctx, "touched", "same contents", t1)
+	r.WriteFile("changed", "new contents!", t2)
+	r.WriteObject(ctx, "changed", "old contents!", t1)
+
+	get := func(f fs.Fs, remote string) fs.Object {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		return o
+	}
+
+	// Nothing is checked without --immutable
+	fs.Config.Immutable = false
+	assert.NoError(t, CheckImmutable(ctx, get(r.Fremote, "changed"), get(r.Flocal, "changed")))
+	fs.Config.Immutable = true
+
+	// Size, hash and modification time all the same
+	assert.NoError(t, CheckImmutable(ctx, get(r.Fremote, "same"), get(r.Flocal, "same")))
+	_, err = os.Stat(reportPath)
+	assert.True(t, os.IsNotExist(err), "no report expected")
+
+	// Only the modification time differs
+	assert.Equal(t, fs.ErrorImmutableModified, CheckImmutable(ctx, get(r.Fremote, "touched"), get(r.Flocal, "touched")))
+	require.NoError(t, os.Remove(reportPath))
+
+	// The contents differ
+	src := get(r.Flocal, "changed")
+	err = CheckImmutable(ctx, get(r.Fremote, "changed"), src)
+	assert.Equal(t, fs.ErrorImmutableModified, err)
+	accounting.GlobalStats().ResetCounters()
+
+	in, err := os.Open(reportPath)
+	require.NoError(t, err)
+	defer func() {
+		_ = in.Close()
+	}()
+	scanner := bufio.NewScanner(in)
+	require.True(t, scanner.Scan())
+	var report tamperReport
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &report))
+	assert.False(t, scanner.Scan(), "only one report expected")
+	assert.Equal(t, "changed", report.Remote)
+	assert.Equal(t, int64(13), report.Source.Size)
+	assert.True(t, t2.Equal(report.Source.ModTime))
+	assert.True(t, t1.Equal(report.Dest.ModTime))
+	if report.HashType != "" {
+		assert.NotEqual(t, report.Source.Hash, report.Dest.Hash)
+	}
+
+	// The new version is quarantined
+	assert.NotEqual(t, "", report.Quarantine)
+	data, err := ioutil.ReadFile(filepath.Join(quarantinePath, "changed"))
+	require.NoError(t, err)
+	assert.Equal(t, "new contents!", string(data))
+}
@@ -79,7 +79,7 @@ func TestListDirSorted(t *testing.T) {
 	assert.Equal(t, "sub dir/sub sub dir/", str(1))
 
 	// testing ignore file
-	filter.Active.Opt.ExcludeFile = ".ignore"
+	filter.Active.Opt.ExcludeFile = []string{".ignore"}
 
 	items, err = list.DirSorted(context.Background(), r.Fremote, false, "sub dir")
 	require.NoError(t, err)
@@ -96,7 +96,39 @@ func TestListDirSorted(t *testing.T) {
 	assert.Equal(t, "sub dir/ignore dir/.ignore", str(0))
 	assert.Equal(t, "sub dir/ignore dir/should be ignored", str(1))
 
-	filter.Active.Opt.ExcludeFile = ""
+	// testing ignore file glob
+	filter.Active.Opt.ExcludeFile = []string{"CACHEDIR.TAG", ".ign*"}
+
+	items, err = list.DirSorted(context.Background(), r.Fremote, false, "sub dir")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "sub dir/sub sub dir/", str(0))
+
+	filter.Active.Opt.ExcludeFile = nil
+	items, err = list.DirSorted(context.Background(), r.Fremote, false, "sub dir/ignore dir")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "sub dir/ignore dir/.ignore", str(0))
+	assert.Equal(t, "sub dir/ignore dir/should be ignored", str(1))
+
+	// testing include file - only files in directories with it
+	// in or above them are kept
+	filter.Active.Opt.IncludeFile = []string{".ignore"}
+	defer func() {
+		filter.Active.Opt.IncludeFile = nil
+	}()
+
+	items, err = list.DirSorted(context.Background(), r.Fremote, false, "")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "sub dir/", str(0))
+
+	items, err = list.DirSorted(context.Background(), r.Fremote, false, "sub dir")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "sub dir/ignore dir/", str(0))
+	assert.Equal(t, "sub dir/sub sub dir/", str(1))
+
 	items, err = list.DirSorted(context.Background(), r.Fremote, false, "sub dir/ignore dir")
 	require.NoError(t, err)
 	require.Len(t, items, 2)
@@ -0,0 +1,73 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+)
+
+// MetadataSidecarSuffix is added to the name of a file to make the name
+// of the sidecar file its metadata is stored in when the destination
+// can't store metadata itself
+const MetadataSidecarSuffix = ".rclonemeta"
+
+// IsMetadataSidecar returns true if remote is the name of a metadata
+// sidecar file
+func IsMetadataSidecar(remote string) bool {
+	return strings.HasSuffix(remote, MetadataSidecarSuffix)
+}
+
+// MetadataSidecarRemote returns the name of the sidecar file for remote
+func MetadataSidecarRemote(remote string) string {
+	return remote + MetadataSidecarSuffix
+}
+
+// copyMetadata copies the metadata of src onto dst in f if dst can
+// store it, or into a sidecar file next to dst if it can't.
+//
+// Nothing is done if src has no metadata.
+func copyMetadata(ctx context.Context, f fs.Fs, src fs.ObjectInfo, dst fs.Object) error {
+	if dst == nil || IsMetadataSidecar(src.Remote()) {
+		return nil
+	}
+	metadata, err := fs.GetMetadata(ctx, src)
+	if err != nil {
+		return errors.Wrap(err, "failed to read metadata")
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	if do, ok := dst.(fs.SetMetadataer); ok {
+		fs.Debugf(dst, "Setting %d metadata items", len(metadata))
+		return do.SetMetadata(ctx, metadata)
+	}
+	return writeMetadataSidecar(ctx, f, dst.Remote(), metadata)
+}
+
+// writeMetadataSidecar writes metadata as JSON to the sidecar file for
+// remote in f, replacing it if it exists
+func writeMetadataSidecar(ctx context.Context, f fs.Fs, remote string, metadata fs.Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "\t")
+	if err != nil {
+		return err
+	}
+	sidecar := MetadataSidecarRemote(remote)
+	fs.Debugf(fs.LogDirName(f, sidecar), "Writing metadata sidecar")
+	info := object.NewStaticObjectInfo(sidecar, time.Now(), int64(len(data)), true, nil, f)
+	existing, err := f.NewObject(ctx, sidecar)
+	if err == nil {
+		err = existing.Update(ctx, bytes.NewReader(data), info)
+	} else if err == fs.ErrorObjectNotFound {
+		_, err = f.Put(ctx, bytes.NewReader(data), info)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write metadata sidecar")
+	}
+	return nil
+}
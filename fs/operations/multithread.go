@@ -1,12 +1,18 @@
 package operations
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/pacer"
+	"github.com/rclone/rclone/lib/pool"
+	"github.com/rclone/rclone/lib/readers"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -14,8 +20,25 @@ const (
 	multithreadChunkSize     = 64 << 10
 	multithreadChunkSizeMask = multithreadChunkSize - 1
 	multithreadBufferSize    = 32 * 1024
+	multithreadPoolSize      = 64              // max number of buffers to keep in the pool
+	multithreadPoolFlushTime = 5 * time.Second // flush the pooled buffers after this long
 )
 
+// multithreadPool is the pool of the buffers used by the streams
+var (
+	multithreadPool     *pool.Pool
+	multithreadPoolOnce sync.Once
+)
+
+// getMultithreadPool returns the buffer pool for the streams, making it
+// if needed
+func getMultithreadPool() *pool.Pool {
+	multithreadPoolOnce.Do(func() {
+		multithreadPool = pool.New(multithreadPoolFlushTime, multithreadBufferSize, multithreadPoolSize, fs.Config.UseMmap)
+	})
+	return multithreadPool
+}
+
 // Return a boolean as to whether we should use multi thread copy for
 // this transfer
 func doMultiThreadCopy(f fs.Fs, src fs.Object) bool {
@@ -31,7 +54,13 @@ func doMultiThreadCopy(f fs.Fs, src fs.Object) bool {
 	}
 	// ...source doesn't support it
 	dstFeatures := f.Features()
-	if dstFeatures.OpenWriterAt == nil {
+	if dstFeatures.OpenWriterAt == nil && dstFeatures.OpenChunkWriter == nil {
+		return false
+	}
+	// ...if --multi-thread-streams not in use and the destination
+	// can only write chunks and the source is local as its uploads
+	// are done in parallel anyway
+	if !fs.Config.MultiThreadSet && dstFeatures.OpenWriterAt == nil && src.Fs().Features().IsLocal {
 		return false
 	}
 	// ...if --multi-thread-streams not in use and source and
@@ -78,7 +107,12 @@ func (mc *multiThreadCopyState) copyStream(ctx context.Context, stream int) (err
 	defer fs.CheckClose(rc, &err)
 
 	// Copy the data
-	buf := make([]byte, multithreadBufferSize)
+	bufferPool := getMultithreadPool()
+	buf, err := bufferPool.GetContext(mc.ctx)
+	if err != nil {
+		return err
+	}
+	defer bufferPool.Put(buf)
 	offset := start
 	for {
 		// Check if context cancelled and exit if so
@@ -135,10 +169,139 @@ func (mc *multiThreadCopyState) calculateChunks() {
 	}
 }
 
-// Copy src to (f, remote) using streams download threads and the OpenWriterAt feature
+// state for a multi-thread copy using the OpenChunkWriter feature
+type multiThreadChunkState struct {
+	src       fs.Object
+	size      int64
+	chunkSize int64
+	chunks    int
+	cw        fs.ChunkWriter
+	acc       *accounting.Account
+	pool      *pool.Pool
+}
+
+// Copy a single chunk into place
+func (mc *multiThreadChunkState) copyChunk(ctx context.Context, chunk int) (err error) {
+	defer func() {
+		if err != nil {
+			fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d failed: %v", chunk+1, mc.chunks, err)
+		}
+	}()
+	start := int64(chunk) * mc.chunkSize
+	end := start + mc.chunkSize
+	if end > mc.size {
+		end = mc.size
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v starting", chunk+1, mc.chunks, start, end, fs.SizeSuffix(end-start))
+
+	buf, err := mc.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer mc.pool.Put(buf)
+	buf = buf[:end-start]
+
+	// Read the chunk into memory
+	rc, err := NewReOpen(ctx, mc.src, fs.Config.LowLevelRetries, &fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return errors.Wrap(err, "multi-thread copy: failed to open source")
+	}
+	n, err := readers.ReadFill(mc.acc.WrapStream(rc), buf)
+	closeErr := rc.Close()
+	if err == io.EOF && n < len(buf) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "multi-thread copy: read failed")
+	}
+	if closeErr != nil {
+		return errors.Wrap(closeErr, "multi-thread copy: failed to close source")
+	}
+
+	// Then write it
+	_, err = mc.cw.WriteChunk(ctx, chunk, bytes.NewReader(buf))
+	if err != nil {
+		return errors.Wrap(err, "multi-thread copy: write failed")
+	}
+
+	fs.Debugf(mc.src, "multi-thread copy: chunk %d/%d (%d-%d) size %v finished", chunk+1, mc.chunks, start, end, fs.SizeSuffix(end-start))
+	return nil
+}
+
+// Copy src to (f, remote) using streams download threads and the
+// OpenChunkWriter feature, writing each chunk as it is read
+func multiThreadCopyChunks(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	info, cw, err := f.Features().OpenChunkWriter(ctx, remote, src)
+	if err != nil {
+		return nil, errors.Wrap(err, "multi-thread copy: failed to open destination")
+	}
+	abort := func() {
+		abortErr := cw.Abort(context.Background())
+		if abortErr != nil {
+			fs.Debugf(src, "multi-thread copy: failed to abort: %v", abortErr)
+		}
+	}
+	if info.ChunkSize <= 0 {
+		abort()
+		return nil, errors.Errorf("multi-thread copy: bad chunk size %d", info.ChunkSize)
+	}
+	mc := &multiThreadChunkState{
+		src:       src,
+		size:      src.Size(),
+		chunkSize: info.ChunkSize,
+		cw:        cw,
+		acc:       tr.Account(ctx, nil),
+		pool:      pool.New(multithreadPoolFlushTime, int(info.ChunkSize), streams, fs.Config.UseMmap),
+	}
+	defer mc.pool.Flush()
+	mc.chunks = int((mc.size + mc.chunkSize - 1) / mc.chunkSize)
+
+	fs.Debugf(src, "Starting multi-thread copy with %d chunks of size %v using %d streams", mc.chunks, fs.SizeSuffix(mc.chunkSize), streams)
+	g, gCtx := errgroup.WithContext(ctx)
+	tokens := pacer.NewTokenDispenser(streams)
+	for chunk := 0; chunk < mc.chunks; chunk++ {
+		tokens.Get()
+		// Stop early if a chunk has failed
+		if gCtx.Err() != nil {
+			tokens.Put()
+			break
+		}
+		chunk := chunk
+		g.Go(func() error {
+			defer tokens.Put()
+			return mc.copyChunk(gCtx, chunk)
+		})
+	}
+	err = g.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		abort()
+		return nil, err
+	}
+	err = cw.Close(ctx)
+	if err != nil {
+		abort()
+		return nil, errors.Wrap(err, "multi-thread copy: failed to close object after copy")
+	}
+
+	obj, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "multi-thread copy: failed to find object after copy")
+	}
+
+	fs.Debugf(src, "Finished multi-thread copy with %d chunks of size %v", mc.chunks, fs.SizeSuffix(mc.chunkSize))
+	return obj, nil
+}
+
+// Copy src to (f, remote) using streams download threads and the
+// OpenWriterAt feature, or the OpenChunkWriter feature if the
+// destination doesn't support random access writes
 func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
 	openWriterAt := f.Features().OpenWriterAt
-	if openWriterAt == nil {
+	if openWriterAt == nil && f.Features().OpenChunkWriter == nil {
 		return nil, errors.New("multi-thread copy: OpenWriterAt not supported")
 	}
 	if src.Size() < 0 {
@@ -147,6 +310,9 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	if src.Size() == 0 {
 		return nil, errors.New("multi-thread copy: can't copy zero sized file")
 	}
+	if openWriterAt == nil {
+		return multiThreadCopyChunks(ctx, f, remote, src, streams, tr)
+	}
 
 	g, gCtx := errgroup.WithContext(ctx)
 	mc := &multiThreadCopyState{
@@ -3,6 +3,9 @@ package operations
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 	"testing"
 
 	"github.com/rclone/rclone/fs/accounting"
@@ -75,6 +78,17 @@ func TestDoMultiThreadCopy(t *testing.T) {
 	assert.True(t, doMultiThreadCopy(f, src))
 	srcFs.Features().IsLocal = false
 	assert.True(t, doMultiThreadCopy(f, src))
+
+	// Destinations which write chunks are used unless the source is local
+	f.Features().OpenWriterAt = nil
+	f.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		panic("don't call me")
+	}
+	assert.True(t, doMultiThreadCopy(f, src))
+	srcFs.Features().IsLocal = true
+	assert.False(t, doMultiThreadCopy(f, src))
+	fs.Config.MultiThreadSet = true
+	assert.True(t, doMultiThreadCopy(f, src))
 }
 
 func TestMultithreadCalculateChunks(t *testing.T) {
@@ -144,3 +158,94 @@ func TestMultithreadCopy(t *testing.T) {
 	}
 
 }
+
+// testChunkWriter writes the chunks with a WriterAtCloser
+type testChunkWriter struct {
+	f         fs.Fs
+	src       fs.ObjectInfo
+	wc        fs.WriterAtCloser
+	chunkSize int64
+	mu        sync.Mutex
+	written   map[int]int64
+	aborted   bool
+}
+
+func (w *testChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.wc.WriteAt(data, int64(chunkNumber)*w.chunkSize)
+	if err != nil {
+		return 0, err
+	}
+	w.mu.Lock()
+	w.written[chunkNumber] = int64(len(data))
+	w.mu.Unlock()
+	return int64(len(data)), nil
+}
+
+func (w *testChunkWriter) Close(ctx context.Context) error {
+	err := w.wc.Close()
+	if err != nil {
+		return err
+	}
+	o, err := w.f.NewObject(ctx, w.src.Remote())
+	if err != nil {
+		return err
+	}
+	return o.SetModTime(ctx, w.src.ModTime(ctx))
+}
+
+func (w *testChunkWriter) Abort(ctx context.Context) error {
+	w.aborted = true
+	return w.wc.Close()
+}
+
+func TestMultithreadCopyChunks(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	// Make the local Fs write chunks only
+	features := r.Flocal.Features()
+	openWriterAt := features.OpenWriterAt
+	var cw *testChunkWriter
+	features.OpenWriterAt = nil
+	features.OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (fs.ChunkWriterInfo, fs.ChunkWriter, error) {
+		wc, err := openWriterAt(ctx, remote, src.Size())
+		if err != nil {
+			return fs.ChunkWriterInfo{}, nil, err
+		}
+		cw = &testChunkWriter{f: r.Flocal, src: src, wc: wc, chunkSize: 1000, written: map[int]int64{}}
+		return fs.ChunkWriterInfo{ChunkSize: cw.chunkSize, Concurrency: 4}, cw, nil
+	}
+	defer func() {
+		features.OpenWriterAt = openWriterAt
+		features.OpenChunkWriter = nil
+	}()
+
+	contents := random.String(4500)
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteObject(ctx, "file1", contents, t1)
+	src, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+
+	tr := accounting.GlobalStats().NewTransfer(src)
+	dst, err := multiThreadCopy(ctx, r.Flocal, "file1", src, 3, tr)
+	tr.Done(err)
+	require.NoError(t, err)
+	assert.Equal(t, src.Size(), dst.Size())
+	assert.Equal(t, map[int]int64{0: 1000, 1: 1000, 2: 1000, 3: 1000, 4: 500}, cw.written)
+	assert.False(t, cw.aborted)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// A failed read aborts the upload
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	tr = accounting.GlobalStats().NewTransfer(src)
+	_, err = multiThreadCopy(ctx, r.Flocal, "file2", src, 3, tr)
+	tr.Done(err)
+	assert.Error(t, err)
+	assert.True(t, cw.aborted)
+}
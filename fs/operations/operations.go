@@ -30,6 +30,7 @@ import (
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/sched"
 	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/rclone/rclone/lib/random"
@@ -119,7 +120,7 @@ func checkHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object, ht hash.
 // Otherwise the file is considered to be not equal including if there
 // were errors reading info.
 func Equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object) bool {
-	return equal(ctx, src, dst, defaultEqualOpt())
+	return equal(ctx, src, dst, defaultEqualOpt(ctx))
 }
 
 // sizeDiffers compare the size of src and dst taking into account the
@@ -141,12 +142,24 @@ type equalOpt struct {
 	forceModTimeMatch bool // if set assume modtimes match
 }
 
+// noUpdateModTimeKey marks a context in which equal() mustn't change
+// the destination
+type noUpdateModTimeKey struct{}
+
+// NoUpdateModTime returns a context in which the checks to see if
+// files need transferring don't update the modification time of
+// destination files found to be identical, as if --no-update-modtime
+// was set.
+func NoUpdateModTime(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noUpdateModTimeKey{}, true)
+}
+
 // default set of options for equal()
-func defaultEqualOpt() equalOpt {
+func defaultEqualOpt(ctx context.Context) equalOpt {
 	return equalOpt{
 		sizeOnly:          fs.Config.SizeOnly,
 		checkSum:          fs.Config.CheckSum,
-		updateModTime:     !fs.Config.NoUpdateModTime,
+		updateModTime:     !fs.Config.NoUpdateModTime && ctx.Value(noUpdateModTimeKey{}) == nil,
 		forceModTimeMatch: false,
 	}
 }
@@ -318,6 +331,12 @@ func (o *OverrideRemote) GetTier() string {
 	return ""
 }
 
+// Metadata returns the metadata of the underlying object or nil if it
+// doesn't have any
+func (o *OverrideRemote) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.ObjectInfo)
+}
+
 // Check all optional interfaces satisfied
 var _ fs.FullObjectInfo = (*OverrideRemote)(nil)
 
@@ -346,15 +365,21 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 	tr := accounting.Stats(ctx).NewTransfer(src)
 	defer func() {
 		tr.Done(err)
+		recordFailure(ctx, FailedCopy, src, f, remote, err)
 	}()
 	newDst = dst
 	if SkipDestructive(ctx, src, "copy") {
 		return newDst, nil
 	}
+	tier, err := uploadTier(remote)
+	if err != nil {
+		return newDst, fs.CountError(err)
+	}
 	maxTries := fs.Config.LowLevelRetries
 	tries := 0
 	doUpdate := dst != nil
 	hashType, hashOption := CommonHash(f, src.Fs())
+	writeCtx := sched.WithPace(ctx, f, sched.OpWrite)
 
 	var actionTaken string
 	for {
@@ -368,7 +393,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		if doCopy := f.Features().Copy; doCopy != nil && (SameConfig(src.Fs(), f) || (SameRemoteType(src.Fs(), f) && f.Features().ServerSideAcrossConfigs)) {
 			in := tr.Account(ctx, nil) // account the transfer
 			in.ServerSideCopyStart()
-			newDst, err = doCopy(ctx, src, remote)
+			newDst, err = doCopy(writeCtx, src, remote)
 			if err == nil {
 				dst = newDst
 				in.ServerSideCopyEnd(dst.Size()) // account the bytes for the server side transfer
@@ -384,7 +409,10 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		}
 		// If can't server side copy, do it manually
 		if err == fs.ErrorCantCopy {
-			if doMultiThreadCopy(f, src) {
+			if doUpdate && doDeltaCopy(dst, src) {
+				dst, err = deltaCopy(ctx, f, dst, remote, src, tr)
+				actionTaken = "Delta copied (updated existing)"
+			} else if doMultiThreadCopy(f, src) {
 				// Number of streams proportional to size
 				streams := src.Size() / int64(fs.Config.MultiThreadCutoff)
 				// With maximum
@@ -400,6 +428,13 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 				} else {
 					actionTaken = "Multi-thread Copied (new)"
 				}
+			} else if ranges := sparseRanges(ctx, f, src); ranges != nil {
+				dst, err = sparseCopy(ctx, f, remote, src, ranges, tr)
+				if doUpdate {
+					actionTaken = "Sparse copied (replaced existing)"
+				} else {
+					actionTaken = "Sparse copied (new)"
+				}
 			} else {
 				var in0 io.ReadCloser
 				options := []fs.OpenOption{hashOption}
@@ -431,12 +466,15 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 						for _, option := range fs.Config.UploadHeaders {
 							options = append(options, option)
 						}
+						if tier != "" {
+							options = append(options, &fs.TierOption{Tier: tier})
+						}
 						if doUpdate {
 							actionTaken = "Copied (replaced existing)"
-							err = dst.Update(ctx, in, wrappedSrc, options...)
+							err = dst.Update(writeCtx, in, wrappedSrc, options...)
 						} else {
 							actionTaken = "Copied (new)"
-							dst, err = f.Put(ctx, in, wrappedSrc, options...)
+							dst, err = f.Put(writeCtx, in, wrappedSrc, options...)
 						}
 						closeErr := in.Close()
 						if err == nil {
@@ -488,6 +526,26 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		}
 	}
 
+	// Put the file in its tier if it wasn't uploaded there
+	if tier != "" && dst != nil {
+		err = setUploadTier(ctx, dst, tier)
+		if err != nil {
+			fs.Errorf(dst, "%v", err)
+			err = fs.CountError(err)
+			return newDst, err
+		}
+	}
+
+	// Copy the metadata after the data has been checked
+	if fs.Config.Metadata {
+		err = copyMetadata(ctx, f, src, dst)
+		if err != nil {
+			fs.Errorf(dst, "Failed to copy metadata: %v", err)
+			err = fs.CountError(err)
+			return newDst, err
+		}
+	}
+
 	fs.Infof(src, actionTaken)
 	return newDst, err
 }
@@ -524,22 +582,25 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 			accounting.Stats(ctx).Renames(1)
 		}
 		tr.Done(err)
+		recordFailure(ctx, FailedMove, src, fdst, remote, err)
 	}()
 	newDst = dst
 	if SkipDestructive(ctx, src, "move") {
 		return newDst, nil
 	}
+	// The move records any failure of the copy and deletes it is made of
+	nestedCtx := nestedOperation(ctx)
 	// See if we have Move available
 	if doMove := fdst.Features().Move; doMove != nil && (SameConfig(src.Fs(), fdst) || (SameRemoteType(src.Fs(), fdst) && fdst.Features().ServerSideAcrossConfigs)) {
 		// Delete destination if it exists and is not the same file as src (could be same file while seemingly different if the remote is case insensitive)
 		if dst != nil && !SameObject(src, dst) {
-			err = DeleteFile(ctx, dst)
+			err = DeleteFile(nestedCtx, dst)
 			if err != nil {
 				return newDst, err
 			}
 		}
 		// Move dst <- src
-		newDst, err = doMove(ctx, src, remote)
+		newDst, err = doMove(sched.WithPace(ctx, fdst, sched.OpWrite), src, remote)
 		switch err {
 		case nil:
 			fs.Infof(src, "Moved (server side)")
@@ -553,13 +614,13 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		}
 	}
 	// Move not found or didn't work so copy dst <- src
-	newDst, err = Copy(ctx, fdst, dst, remote, src)
+	newDst, err = Copy(nestedCtx, fdst, dst, remote, src)
 	if err != nil {
 		fs.Errorf(src, "Not deleting source as copy failed: %v", err)
 		return newDst, err
 	}
 	// Delete src if no error on copy
-	return newDst, DeleteFile(ctx, src)
+	return newDst, DeleteFile(nestedCtx, src)
 }
 
 // CanServerSideMove returns true if fdst support server side moves or
@@ -575,16 +636,19 @@ func CanServerSideMove(fdst fs.Fs) bool {
 
 // SuffixName adds the current --suffix to the remote, obeying
 // --suffix-keep-extension if set
+//
+// Any date templates in the suffix are expanded.
 func SuffixName(remote string) string {
 	if fs.Config.Suffix == "" {
 		return remote
 	}
+	suffix := ExpandBackupTemplate(fs.Config.Suffix, getBackupTime())
 	if fs.Config.SuffixKeepExtension {
 		ext := path.Ext(remote)
 		base := remote[:len(remote)-len(ext)]
-		return base + fs.Config.Suffix + ext
+		return base + suffix + ext
 	}
-	return remote + fs.Config.Suffix
+	return remote + suffix
 }
 
 // DeleteFileWithBackupDir deletes a single file respecting --dry-run
@@ -608,14 +672,21 @@ func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs
 	skip := SkipDestructive(ctx, dst, action)
 	if skip {
 		// do nothing
+	} else if err = waitDeleteToken(ctx); err != nil {
+		// cancelled while waiting for --delete-tpslimit
 	} else if backupDir != nil {
-		err = MoveBackupDir(ctx, backupDir, dst)
+		err = MoveBackupDir(nestedOperation(ctx), backupDir, dst)
 	} else {
-		err = dst.Remove(ctx)
+		err = dst.Remove(deleteContext(ctx, dst.Fs()))
 	}
 	if err != nil {
 		fs.Errorf(dst, "Couldn't %s: %v", action, err)
 		err = fs.CountError(err)
+		if backupDir != nil {
+			recordFailure(ctx, FailedBackup, dst, backupDir, SuffixName(dst.Remote()), err)
+		} else {
+			recordFailure(ctx, FailedDelete, nil, dst.Fs(), dst.Remote(), err)
+		}
 	} else if !skip {
 		fs.Infof(dst, actioned)
 	}
@@ -635,23 +706,34 @@ func DeleteFile(ctx context.Context, dst fs.Object) (err error) {
 //
 // If backupDir is set the files will be placed into that directory
 // instead of being deleted.
+//
+// If backupDir isn't set and the Fs the files are in supports
+// DeleteBatch then the files are deleted in batches.
 func DeleteFilesWithBackupDir(ctx context.Context, toBeDeleted fs.ObjectsChan, backupDir fs.Fs) error {
 	var wg sync.WaitGroup
-	wg.Add(fs.Config.Transfers)
+	concurrency := deleteConcurrency()
+	wg.Add(concurrency)
 	var errorCount int32
 	var fatalErrorCount int32
 
-	for i := 0; i < fs.Config.Transfers; i++ {
+	for i := 0; i < concurrency; i++ {
 		go func() {
 			defer wg.Done()
 			for dst := range toBeDeleted {
-				err := DeleteFileWithBackupDir(ctx, dst, backupDir)
-				if err != nil {
-					atomic.AddInt32(&errorCount, 1)
-					if fserrors.IsFatalError(err) {
-						fs.Errorf(nil, "Got fatal error on delete: %s", err)
-						atomic.AddInt32(&fatalErrorCount, 1)
-						return
+				var errs []error
+				if doBatch := dst.Fs().Features().DeleteBatch; doBatch != nil && backupDir == nil {
+					errs = deleteBatch(ctx, doBatch, fillDeleteBatch(dst, toBeDeleted))
+				} else {
+					errs = []error{DeleteFileWithBackupDir(ctx, dst, backupDir)}
+				}
+				for _, err := range errs {
+					if err != nil {
+						atomic.AddInt32(&errorCount, 1)
+						if fserrors.IsFatalError(err) {
+							fs.Errorf(nil, "Got fatal error on delete: %s", err)
+							atomic.AddInt32(&fatalErrorCount, 1)
+							return
+						}
 					}
 				}
 			}
@@ -1041,7 +1123,7 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 		for _, option := range fs.Config.DownloadHeaders {
 			options = append(options, option)
 		}
-		in, err := o.Open(ctx, options...)
+		in, err := o.Open(sched.WithPace(ctx, f, sched.OpRead), options...)
 		if err != nil {
 			err = fs.CountError(err)
 			fs.Errorf(o, "Failed to open: %v", err)
@@ -1143,7 +1225,7 @@ func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser,
 	}
 
 	objInfo := object.NewStaticObjectInfo(dstFileName, modTime, -1, false, nil, nil)
-	if dst, err = fStreamTo.Features().PutStream(ctx, in, objInfo, options...); err != nil {
+	if dst, err = fStreamTo.Features().PutStream(sched.WithPace(ctx, fStreamTo, sched.OpWrite), in, objInfo, options...); err != nil {
 		return dst, err
 	}
 	if err = compare(dst); err != nil {
@@ -1298,7 +1380,7 @@ func copyDest(ctx context.Context, fdst fs.Fs, dst, src fs.Object, CopyDest, bac
 	default:
 		return false, err
 	}
-	opt := defaultEqualOpt()
+	opt := defaultEqualOpt(ctx)
 	opt.updateModTime = false
 	if equal(ctx, src, CopyDestFile, opt) {
 		if dst == nil || !Equal(ctx, src, dst) {
@@ -1377,7 +1459,7 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 			return false
 		case dt <= -modifyWindow:
 			// force --checksum on for the check and do update modtimes by default
-			opt := defaultEqualOpt()
+			opt := defaultEqualOpt(ctx)
 			opt.forceModTimeMatch = true
 			if equal(ctx, src, dst, opt) {
 				fs.Debugf(src, "Unchanged skipping")
@@ -1385,7 +1467,7 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 			}
 		default:
 			// Do a size only compare unless --checksum is set
-			opt := defaultEqualOpt()
+			opt := defaultEqualOpt(ctx)
 			opt.sizeOnly = !fs.Config.CheckSum
 			if equal(ctx, src, dst, opt) {
 				fs.Debugf(src, "Destination mod time is within %v of source and files identical, skipping", modifyWindow)
@@ -1425,7 +1507,7 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 		}
 
 		info := object.NewStaticObjectInfo(dstFileName, modTime, size, true, nil, fdst)
-		obj, err = fdst.Put(ctx, in, info)
+		obj, err = fdst.Put(sched.WithPace(ctx, fdst, sched.OpWrite), in, info)
 		if err != nil {
 			fs.Errorf(dstFileName, "Post request put error: %v", err)
 
@@ -1496,11 +1578,16 @@ func CopyURLToWriter(ctx context.Context, url string, out io.Writer) (err error)
 }
 
 // BackupDir returns the correctly configured --backup-dir
+//
+// Any date templates in --backup-dir and --suffix are expanded with
+// the current time which is then used for the rest of the operation.
 func BackupDir(fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err error) {
+	resetBackupTime()
 	if fs.Config.BackupDir != "" {
-		backupDir, err = cache.Get(fs.Config.BackupDir)
+		backupDirName := ExpandBackupTemplate(fs.Config.BackupDir, getBackupTime())
+		backupDir, err = cache.Get(backupDirName)
 		if err != nil {
-			return nil, fserrors.FatalError(errors.Errorf("Failed to make fs for --backup-dir %q: %v", fs.Config.BackupDir, err))
+			return nil, fserrors.FatalError(errors.Errorf("Failed to make fs for --backup-dir %q: %v", backupDirName, err))
 		}
 		if !SameConfig(fdst, backupDir) {
 			return nil, fserrors.FatalError(errors.New("parameter to --backup-dir has to be on the same remote as destination"))
@@ -1634,7 +1721,13 @@ func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName str
 			dstObj = nil
 		}
 
+		var release func()
+		release, err = sched.Acquire(ctx)
+		if err != nil {
+			return err
+		}
 		_, err = Op(ctx, fdst, dstObj, dstFileName, srcObj)
+		release()
 	} else {
 		tr := accounting.Stats(ctx).NewCheckingTransfer(srcObj)
 		if !cp {
@@ -22,13 +22,16 @@ package operations_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -784,6 +787,95 @@ func TestCopyFile(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file2)
 }
 
+func TestCopyFileMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test as file modes aren't supported on Windows")
+	}
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	oldMetadata := fs.Config.Metadata
+	fs.Config.Metadata = true
+	defer func() {
+		fs.Config.Metadata = oldMetadata
+	}()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	require.NoError(t, os.Chmod(filepath.Join(r.LocalName, file1.Path), 0640))
+
+	// The memory backend can't store metadata so it should be
+	// written to a sidecar
+	fmem, err := fs.NewFs(":memory:metadata")
+	require.NoError(t, err)
+	err = operations.CopyFile(ctx, fmem, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+
+	sidecar, err := fmem.NewObject(ctx, operations.MetadataSidecarRemote(file1.Path))
+	require.NoError(t, err)
+	in, err := sidecar.Open(ctx)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(in)
+	require.NoError(t, in.Close())
+	require.NoError(t, err)
+	var metadata fs.Metadata
+	require.NoError(t, json.Unmarshal(data, &metadata))
+	assert.Equal(t, "0640", metadata[fs.MetadataMode])
+	mtime, ok := metadata.Time(fs.MetadataMtime)
+	assert.True(t, ok)
+	assert.True(t, t1.Equal(mtime), "want %v got %v", t1, mtime)
+}
+
+func TestCopyFileSparse(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	oldSparse := fs.Config.Sparse
+	defer func() {
+		fs.Config.Sparse = oldSparse
+	}()
+	fs.Config.Sparse = true
+
+	// Write a file with data in the middle of a hole
+	const size = 4 << 20
+	require.NoError(t, os.MkdirAll(r.LocalName, 0777))
+	fd, err := os.Create(filepath.Join(r.LocalName, "sparse"))
+	require.NoError(t, err)
+	require.NoError(t, fd.Truncate(size))
+	_, err = fd.WriteAt([]byte("hello"), 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	require.NoError(t, os.Chtimes(filepath.Join(r.LocalName, "sparse"), t1, t1))
+
+	src, err := r.Flocal.NewObject(ctx, "sparse")
+	require.NoError(t, err)
+	ranges, err := src.(fs.DataRanger).DataRanges(ctx)
+	require.NoError(t, err)
+	if len(ranges) == 1 && ranges[0].Start == 0 && ranges[0].End == size-1 {
+		t.Skip("Skipping test as sparse files aren't supported here")
+	}
+	if r.Fremote.Features().OpenWriterAt == nil {
+		t.Skip("Skipping test as remote doesn't support OpenWriterAt")
+	}
+
+	accounting.GlobalStats().ResetCounters()
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, "sparse", "sparse")
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), accounting.GlobalStats().GetBytes(), "holes not accounted")
+	contents := make([]byte, size)
+	copy(contents[1<<20:], "hello")
+	file1 := fstest.NewItem("sparse", string(contents), t1)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// Check the holes were kept if the remote can tell us
+	dst, err := r.Fremote.NewObject(ctx, "sparse")
+	require.NoError(t, err)
+	if do, ok := dst.(fs.DataRanger); ok {
+		dstRanges, err := do.DataRanges(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ranges, dstRanges, "holes not kept")
+	}
+}
+
 func TestCopyFileBackupDir(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 // ReOpen is a wrapper for an object reader which reopens the stream on error
@@ -22,6 +23,7 @@ type ReOpen struct {
 	tries    int             // number of retries we've had so far in this stream
 	err      error           // if this is set then Read/Close calls will return it
 	opened   bool            // if set then rc is valid and needs closing
+	fellBack bool            // set if we've looked for a --source-fallback copy of src
 }
 
 var (
@@ -93,7 +95,11 @@ func (h *ReOpen) open() error {
 	if h.tries > h.maxTries {
 		h.err = errorTooManyTries
 	} else {
-		h.rc, h.err = h.src.Open(h.ctx, opts...)
+		ctx := sched.WithPace(h.ctx, h.src.Fs(), sched.OpRead)
+		h.rc, h.err = h.src.Open(ctx, opts...)
+		if h.err != nil && h.fallback() {
+			h.rc, h.err = h.src.Open(ctx, opts...)
+		}
 	}
 	if h.err != nil {
 		if h.tries > 1 {
@@ -105,6 +111,23 @@ func (h *ReOpen) open() error {
 	return nil
 }
 
+// fallback switches to reading a copy of src from --source-fallback
+// if there is one. It only looks for a copy once. It returns true if
+// it switched - call with lock held
+func (h *ReOpen) fallback() bool {
+	if h.fellBack {
+		return false
+	}
+	h.fellBack = true
+	o := SourceFallback(h.ctx, h.src)
+	if o == nil {
+		return false
+	}
+	fs.Logf(h.src, "Reading from fallback %v after %d bytes", o.Fs(), h.read)
+	h.src = o
+	return true
+}
+
 // Read bytes retrying as necessary
 func (h *ReOpen) Read(p []byte) (n int, err error) {
 	h.mu.Lock()
@@ -124,6 +147,7 @@ func (h *ReOpen) Read(p []byte) (n int, err error) {
 		_ = h.rc.Close()
 		// reopen stream, clearing error if successful
 		fs.Debugf(h.src, "Reopening on read failure after %d bytes: retry %d/%d: %v", h.read, h.tries, h.maxTries, err)
+		h.fallback()
 		if h.open() == nil {
 			err = nil
 		}
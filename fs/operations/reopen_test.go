@@ -9,9 +9,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/rclone/rclone/lib/readers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // check interface
@@ -148,3 +150,50 @@ func TestReOpen(t *testing.T) {
 		})
 	}
 }
+
+func TestReOpenSourceFallback(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer func() { fs.Config.SourceFallback = nil }()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	r.WriteObject(ctx, "file.txt", "0123456789", t1)
+	r.WriteObject(ctx, "changed.txt", "0123456789", t1)
+	r.WriteFile("file.txt", "0123456789", t1)
+	r.WriteFile("changed.txt", "012345678X", t1)
+
+	open := func(remote string) (io.ReadCloser, error) {
+		o, err := r.Fremote.NewObject(ctx, remote)
+		require.NoError(t, err)
+		// Break after 3 bytes then on every open
+		src := &reOpenTestObject{
+			Object: o,
+			breaks: []int64{3, 0, 0, 0, 0},
+		}
+		return NewReOpen(ctx, src, 3)
+	}
+
+	// Without a fallback the read fails
+	h, err := open("file.txt")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(h)
+	assert.Error(t, err)
+
+	// With a fallback it is read from there
+	fs.Config.SourceFallback = []string{r.LocalName}
+	h, err = open("file.txt")
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(h)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(got))
+	require.NoError(t, h.Close())
+
+	// A fallback with different contents isn't used
+	if r.Fremote.Hashes().Overlap(r.Flocal.Hashes()).GetOne() != hash.None {
+		h, err = open("changed.txt")
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(h)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,185 @@
+package operations
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+)
+
+// truncater is implemented by WriterAtClosers which can change the size
+// of the file, eg *os.File
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// sparseRanges returns the data ranges of src if --sparse is set and it
+// is a sparse file which can be copied to f leaving out the holes, or
+// nil if it should be copied normally
+func sparseRanges(ctx context.Context, f fs.Fs, src fs.Object) []fs.RangeOption {
+	if !fs.Config.Sparse || f.Features().OpenWriterAt == nil || src.Size() <= 0 {
+		return nil
+	}
+	do, ok := src.(fs.DataRanger)
+	if !ok {
+		return nil
+	}
+	ranges, err := do.DataRanges(ctx)
+	if err != nil {
+		fs.Debugf(src, "Failed to read data ranges - copying normally: %v", err)
+		return nil
+	}
+	var data int64
+	for _, r := range ranges {
+		data += r.End - r.Start + 1
+	}
+	if data >= src.Size() {
+		return nil // no holes
+	}
+	return ranges
+}
+
+// sparseCopy copies the data ranges of src to (f, remote) using the
+// OpenWriterAt feature so the holes in src stay as holes in the
+// destination if it can be truncated, otherwise the holes are written
+// as zeros
+func sparseCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object, ranges []fs.RangeOption, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	openWriterAt := f.Features().OpenWriterAt
+	if openWriterAt == nil {
+		return nil, errors.New("sparse copy: OpenWriterAt not supported")
+	}
+	wc, err := openWriterAt(ctx, remote, src.Size())
+	if err != nil {
+		return nil, errors.Wrap(err, "sparse copy: failed to open destination")
+	}
+	err = writeSparse(ctx, wc, src, ranges, tr)
+	closeErr := wc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, errors.Wrap(closeErr, "sparse copy: failed to close object after copy")
+	}
+
+	obj, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, errors.Wrap(err, "sparse copy: failed to find object after copy")
+	}
+	err = obj.SetModTime(ctx, src.ModTime(ctx))
+	switch err {
+	case nil, fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
+	default:
+		return nil, errors.Wrap(err, "sparse copy: failed to set modification time")
+	}
+	return obj, nil
+}
+
+// writeSparse writes the ranges of src to wc, making the rest of wc
+// holes if possible
+func writeSparse(ctx context.Context, wc fs.WriterAtCloser, src fs.Object, ranges []fs.RangeOption, tr *accounting.Transfer) error {
+	size := src.Size()
+	// Make the whole destination a hole, dropping any preallocated
+	// space, or if that isn't possible fill in the holes with zeros
+	if t, ok := wc.(truncater); ok {
+		err := t.Truncate(0)
+		if err == nil {
+			err = t.Truncate(size)
+		}
+		if err != nil {
+			return errors.Wrap(err, "sparse copy: failed to truncate destination")
+		}
+	} else {
+		fs.Debugf(src, "sparse copy: destination can't be truncated so writing holes as zeros")
+		err := writeHoles(wc, ranges, size)
+		if err != nil {
+			return err
+		}
+	}
+
+	acc := tr.Account(ctx, nil)
+	var data int64
+	for i := range ranges {
+		r := &ranges[i]
+		err := copyRange(ctx, wc, src, r, acc)
+		if err != nil {
+			return err
+		}
+		data += r.End - r.Start + 1
+	}
+	// Account the holes as transferred so the transfer reaches its
+	// size, without applying --bwlimit to the bytes which weren't
+	// read or written
+	acc.ServerSideCopyEnd(size - data)
+	fs.Debugf(src, "sparse copy: copied %v of data in %d ranges leaving %v of holes", fs.SizeSuffix(data), len(ranges), fs.SizeSuffix(size-data))
+	return nil
+}
+
+// copyRange copies the range r of src to the same place in wc
+func copyRange(ctx context.Context, wc io.WriterAt, src fs.Object, r *fs.RangeOption, acc *accounting.Account) (err error) {
+	rc, err := NewReOpen(ctx, src, fs.Config.LowLevelRetries, r)
+	if err != nil {
+		return errors.Wrap(err, "sparse copy: failed to open source")
+	}
+	defer fs.CheckClose(rc, &err)
+	buf := make([]byte, multithreadBufferSize)
+	offset := r.Start
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		nr, er := rc.Read(buf)
+		if nr > 0 {
+			err = acc.AccountRead(nr)
+			if err != nil {
+				return errors.Wrap(err, "sparse copy: accounting failed")
+			}
+			nw, ew := wc.WriteAt(buf[:nr], offset)
+			offset += int64(nw)
+			if ew != nil {
+				return errors.Wrap(ew, "sparse copy: write failed")
+			}
+			if nw != nr {
+				return errors.Wrap(io.ErrShortWrite, "sparse copy")
+			}
+		}
+		if er == io.EOF {
+			break
+		} else if er != nil {
+			return errors.Wrap(er, "sparse copy: read failed")
+		}
+	}
+	if offset != r.End+1 {
+		return errors.Errorf("sparse copy: wrote %d bytes but expected to write %d", offset-r.Start, r.End-r.Start+1)
+	}
+	return nil
+}
+
+// writeHoles writes zeros to wc in the gaps between ranges up to size
+func writeHoles(wc io.WriterAt, ranges []fs.RangeOption, size int64) error {
+	zeros := make([]byte, multithreadBufferSize)
+	writeZeros := func(start, end int64) error {
+		for start < end {
+			n := int64(len(zeros))
+			if end-start < n {
+				n = end - start
+			}
+			_, err := wc.WriteAt(zeros[:n], start)
+			if err != nil {
+				return errors.Wrap(err, "sparse copy: failed to write zeros")
+			}
+			start += n
+		}
+		return nil
+	}
+	offset := int64(0)
+	for _, r := range ranges {
+		err := writeZeros(offset, r.Start)
+		if err != nil {
+			return err
+		}
+		offset = r.End + 1
+	}
+	return writeZeros(offset, size)
+}
@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+)
+
+// uploadTierRule is a parsed --upload-tier rule
+type uploadTierRule struct {
+	re   *regexp.Regexp
+	tier string
+}
+
+// uploadTierRules caches the parsed --upload-tier rules
+var uploadTierRules = struct {
+	mu    sync.Mutex
+	text  string // the rules parsed, joined with newlines
+	rules []uploadTierRule
+	err   error
+}{}
+
+// parseUploadTierRules parses rules of the form glob=tier
+func parseUploadTierRules(text []string) (rules []uploadTierRule, err error) {
+	for _, rule := range text {
+		i := strings.LastIndex(rule, "=")
+		if i < 0 {
+			return nil, errors.Errorf("bad --upload-tier %q: expecting glob=tier", rule)
+		}
+		glob, tier := strings.TrimSpace(rule[:i]), strings.TrimSpace(rule[i+1:])
+		if glob == "" || tier == "" {
+			return nil, errors.Errorf("bad --upload-tier %q: expecting glob=tier", rule)
+		}
+		re, err := filter.GlobToRegexp(glob, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad --upload-tier %q", rule)
+		}
+		rules = append(rules, uploadTierRule{re: re, tier: tier})
+	}
+	return rules, nil
+}
+
+// uploadTier returns the tier to store remote in from the first
+// --upload-tier rule which matches it or "" if none do
+func uploadTier(remote string) (string, error) {
+	if len(fs.Config.UploadTier) == 0 {
+		return "", nil
+	}
+	uploadTierRules.mu.Lock()
+	text := strings.Join(fs.Config.UploadTier, "\n")
+	if text != uploadTierRules.text {
+		uploadTierRules.text = text
+		uploadTierRules.rules, uploadTierRules.err = parseUploadTierRules(fs.Config.UploadTier)
+	}
+	rules, err := uploadTierRules.rules, uploadTierRules.err
+	uploadTierRules.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	for _, rule := range rules {
+		if rule.re.MatchString(remote) {
+			return rule.tier, nil
+		}
+	}
+	return "", nil
+}
+
+// setUploadTier moves dst into tier if it isn't there already. This is
+// for objects the backend didn't upload with the TierOption, eg server
+// side copies.
+func setUploadTier(ctx context.Context, dst fs.Object, tier string) error {
+	do, ok := dst.(fs.SetTierer)
+	if !ok {
+		fs.Debugf(dst, "Can't set tier %q as the destination doesn't support tiers", tier)
+		return nil
+	}
+	if getTier, ok := dst.(fs.GetTierer); ok && strings.EqualFold(getTier.GetTier(), tier) {
+		return nil
+	}
+	if SkipDestructive(ctx, dst, "set tier") {
+		return nil
+	}
+	err := do.SetTier(tier)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set tier %q", tier)
+	}
+	fs.Debugf(dst, "Set tier to %q", tier)
+	return nil
+}
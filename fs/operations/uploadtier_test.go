@@ -0,0 +1,43 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadTier(t *testing.T) {
+	oldUploadTier := fs.Config.UploadTier
+	defer func() {
+		fs.Config.UploadTier = oldUploadTier
+	}()
+
+	fs.Config.UploadTier = nil
+	tier, err := uploadTier("film.mkv")
+	require.NoError(t, err)
+	assert.Equal(t, "", tier)
+
+	fs.Config.UploadTier = []string{"*.mkv=GLACIER_IR", "/backup/**=DEEP_ARCHIVE", " * = STANDARD "}
+	for _, test := range []struct {
+		remote string
+		want   string
+	}{
+		{"film.mkv", "GLACIER_IR"},
+		{"dir/film.mkv", "GLACIER_IR"},
+		{"backup/dir/file.txt", "DEEP_ARCHIVE"},
+		{"dir/backup/file.txt", "STANDARD"},
+		{"file.txt", "STANDARD"},
+	} {
+		tier, err := uploadTier(test.remote)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, tier, test.remote)
+	}
+
+	for _, bad := range []string{"*.mkv", "=GLACIER", "*.mkv=", "[.mkv=GLACIER"} {
+		fs.Config.UploadTier = []string{bad}
+		_, err := uploadTier("film.mkv")
+		assert.Error(t, err, bad)
+	}
+}
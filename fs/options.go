@@ -230,6 +230,27 @@ func (o *HashesOption) Mandatory() bool {
 	return false
 }
 
+// TierOption asks the backend to store an uploaded object in a storage
+// tier or class. Backends without tiers ignore it.
+type TierOption struct {
+	Tier string
+}
+
+// Header formats the option as an http header
+func (o *TierOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// String formats the option into human readable form
+func (o *TierOption) String() string {
+	return fmt.Sprintf("TierOption(%q)", o.Tier)
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o *TierOption) Mandatory() bool {
+	return false
+}
+
 // NullOption defines an Option which does nothing
 type NullOption struct {
 }
@@ -0,0 +1,47 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// OutputFormat describes the possible formats for progress and results
+type OutputFormat byte
+
+// OutputFormat constants
+const (
+	OutputFormatText OutputFormat = iota
+	OutputFormatJSONL
+	OutputFormatDefault = OutputFormatText
+)
+
+var outputFormatToString = []string{
+	OutputFormatText:  "text",
+	OutputFormatJSONL: "jsonl",
+}
+
+// String turns an OutputFormat into a string
+func (m OutputFormat) String() string {
+	if m >= OutputFormat(len(outputFormatToString)) {
+		return fmt.Sprintf("OutputFormat(%d)", m)
+	}
+	return outputFormatToString[m]
+}
+
+// Set an OutputFormat
+func (m *OutputFormat) Set(s string) error {
+	for n, name := range outputFormatToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = OutputFormat(n)
+			return nil
+		}
+	}
+	return errors.Errorf("Unknown output format %q", s)
+}
+
+// Type of the value
+func (m *OutputFormat) Type() string {
+	return "string"
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 // Job describes an asynchronous task started via the rc package
@@ -160,6 +161,20 @@ func getGroup(in rc.Params) string {
 	return group
 }
 
+// withPriority sets the transfer priority in ctx from the _priority
+// parameter if set
+func withPriority(ctx context.Context, in rc.Params) context.Context {
+	priority, err := in.GetInt64("_priority")
+	delete(in, "_priority")
+	if rc.IsErrParamNotFound(err) {
+		return ctx
+	} else if err != nil {
+		fs.Errorf(nil, "Can't get _priority param %+v", err)
+		return ctx
+	}
+	return sched.WithPriority(ctx, int(priority))
+}
+
 // NewAsyncJob start a new asynchronous Job off
 func (jobs *Jobs) NewAsyncJob(fn rc.Func, in rc.Params) *Job {
 	id := atomic.AddInt64(&jobID, 1)
@@ -169,6 +184,7 @@ func (jobs *Jobs) NewAsyncJob(fn rc.Func, in rc.Params) *Job {
 		group = fmt.Sprintf("job/%d", id)
 	}
 	ctx := accounting.WithStatsGroup(context.Background(), group)
+	ctx = withPriority(ctx, in)
 	ctx, cancel := context.WithCancel(ctx)
 	stop := func() {
 		cancel()
@@ -196,6 +212,7 @@ func (jobs *Jobs) NewSyncJob(ctx context.Context, in rc.Params) (*Job, context.C
 		group = fmt.Sprintf("job/%d", id)
 	}
 	ctxG := accounting.WithStatsGroup(ctx, fmt.Sprintf("job/%d", id))
+	ctxG = withPriority(ctxG, in)
 	ctx, cancel := context.WithCancel(ctxG)
 	stop := func() {
 		cancel()
@@ -32,6 +32,7 @@ type Options struct {
 	EnableMetrics            bool   // set to disable prometheus metrics on /metrics
 	JobExpireDuration        time.Duration
 	JobExpireInterval        time.Duration
+	GRPCAddr                 string // set to enable the gRPC server on this address
 }
 
 // DefaultOpt is the default values used for Options
@@ -0,0 +1,518 @@
+// Package rcgrpc implements a gRPC endpoint to serve the remote control
+//
+// The service mirrors the JSON rc API - see rc.proto for the
+// definitions.
+package rcgrpc
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. rc.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	auth "github.com/abbot/go-http-auth"
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Start the gRPC remote control server if configured
+//
+// If the server wasn't configured the *Server returned may be nil
+func Start(opt *rc.Options) (*Server, error) {
+	if !opt.Enabled || opt.GRPCAddr == "" {
+		return nil, nil
+	}
+	s, err := newServer(opt)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.Serve()
+}
+
+// Server contains everything to run the gRPC rc server
+type Server struct {
+	opt      *rc.Options
+	server   *grpc.Server
+	listener net.Listener
+	waitChan chan struct{}
+}
+
+func newServer(opt *rc.Options) (*Server, error) {
+	var serverOpts []grpc.ServerOption
+	if opt.HTTPOptions.SslCert != "" || opt.HTTPOptions.SslKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(opt.HTTPOptions.SslCert, opt.HTTPOptions.SslKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load TLS certificate")
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	s := &Server{
+		opt:    opt,
+		server: grpc.NewServer(serverOpts...),
+	}
+	RegisterRCServer(s.server, newService(opt))
+	return s, nil
+}
+
+// Serve runs the gRPC server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *Server) Serve() error {
+	ln, err := net.Listen("tcp", s.opt.GRPCAddr)
+	if err != nil {
+		return errors.Wrap(err, "start gRPC server failed")
+	}
+	s.listener = ln
+	s.waitChan = make(chan struct{})
+	go func() {
+		defer close(s.waitChan)
+		if err := s.server.Serve(ln); err != nil {
+			fs.Errorf(nil, "Error on serving gRPC server: %v", err)
+		}
+	}()
+	fs.Logf(nil, "Serving remote control gRPC on %s", s.Addr())
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.opt.GRPCAddr
+}
+
+// Wait blocks while the server is running
+func (s *Server) Wait() {
+	<-s.waitChan
+}
+
+// Close shuts the running server down
+func (s *Server) Close() {
+	s.server.Stop()
+}
+
+// service implements RCServer
+type service struct {
+	opt      *rc.Options
+	htpasswd *auth.BasicAuth // checks credentials against --rc-htpasswd if set
+}
+
+// newService makes a service for opt
+func newService(opt *rc.Options) *service {
+	svc := &service{opt: opt}
+	if opt.HTTPOptions.HtPasswd != "" {
+		fs.Infof(nil, "Using %q as htpasswd storage for gRPC", opt.HTTPOptions.HtPasswd)
+		svc.htpasswd = auth.NewBasicAuthenticator(opt.HTTPOptions.Realm, auth.HtpasswdFileProvider(opt.HTTPOptions.HtPasswd))
+	}
+	return svc
+}
+
+// usingAuth returns true if the server has credentials configured
+func (svc *service) usingAuth() bool {
+	return svc.opt.HTTPOptions.BasicUser != "" || svc.opt.HTTPOptions.HtPasswd != ""
+}
+
+// checkAuth checks the basic auth credentials passed in the
+// "authorization" metadata if authentication is configured
+func (svc *service) checkAuth(ctx context.Context) error {
+	if !svc.usingAuth() {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if svc.validCredentials(value) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing credentials")
+}
+
+// validCredentials returns whether value, a basic auth authorization
+// header, has valid credentials. As for the HTTP rc these are checked
+// against the htpasswd file if set, otherwise the user and password.
+func (svc *service) validCredentials(value string) bool {
+	if svc.htpasswd != nil {
+		r := &http.Request{Header: http.Header{}}
+		r.Header.Set("Authorization", value)
+		return svc.htpasswd.CheckAuth(r) != ""
+	}
+	const prefix = "Basic "
+	if !strings.HasPrefix(value, prefix) {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value[len(prefix):])
+	if err != nil {
+		return false
+	}
+	want := svc.opt.HTTPOptions.BasicUser + ":" + svc.opt.HTTPOptions.BasicPass
+	return subtle.ConstantTimeCompare(decoded, []byte(want)) == 1
+}
+
+// checkCall checks the call may be run in this context
+func (svc *service) checkCall(ctx context.Context, call *rc.Call) error {
+	if err := svc.checkAuth(ctx); err != nil {
+		return err
+	}
+	if !svc.opt.NoAuth && call.AuthRequired && !svc.usingAuth() {
+		return status.Errorf(codes.PermissionDenied, "authentication must be set up on the rc server to use %q or the --rc-no-auth flag must be in use", call.Path)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return status.Errorf(codes.Unimplemented, "%q can only be called over HTTP", call.Path)
+	}
+	return nil
+}
+
+// structToParams converts a protobuf Struct into rc.Params
+func structToParams(in *structpb.Struct) (rc.Params, error) {
+	out := make(rc.Params)
+	if in == nil {
+		return out, nil
+	}
+	buf, err := protojson.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(buf, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// paramsToStruct converts rc.Params into a protobuf Struct
+//
+// This goes via JSON so that the values are converted exactly as
+// they would be for the JSON rc.
+func paramsToStruct(in rc.Params) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	if in == nil {
+		return out, nil
+	}
+	buf, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	err = protojson.Unmarshal(buf, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// callError converts err into an Error with the status the JSON rc
+// would have returned
+func callError(err error) *Error {
+	httpStatus := http.StatusInternalServerError
+	errOrig := errors.Cause(err)
+	switch {
+	case errOrig == fs.ErrorDirNotFound || errOrig == fs.ErrorObjectNotFound:
+		httpStatus = http.StatusNotFound
+	case rc.IsErrParamInvalid(err) || rc.IsErrParamNotFound(err):
+		httpStatus = http.StatusBadRequest
+	}
+	return &Error{
+		Status:  int32(httpStatus),
+		Message: err.Error(),
+	}
+}
+
+// call runs the rc call described by req
+//
+// Errors with the call itself are returned in the reply - only
+// errors which should abort the RPC are returned as errors.
+func (svc *service) call(ctx context.Context, req *CallRequest) (*CallReply, error) {
+	reply := &CallReply{Tag: req.Tag}
+	path := strings.Trim(req.Path, "/")
+	call := rc.Calls.Get(path)
+	if call == nil {
+		reply.Error = &Error{
+			Status:  http.StatusNotFound,
+			Message: errors.Errorf("couldn't find method %q", path).Error(),
+		}
+		return reply, nil
+	}
+	if err := svc.checkCall(ctx, call); err != nil {
+		return nil, err
+	}
+	in, err := structToParams(req.Params)
+	if err != nil {
+		reply.Error = &Error{
+			Status:  http.StatusBadRequest,
+			Message: errors.Wrap(err, "failed to read input parameters").Error(),
+		}
+		return reply, nil
+	}
+	fs.Debugf(nil, "rc: grpc: %q: with parameters %+v", path, in)
+	var out rc.Params
+	if req.Async {
+		out, err = jobs.StartAsyncJob(call.Fn, in)
+		if err == nil {
+			jobID, _ := out.GetInt64("jobid")
+			reply.JobId = jobID
+		}
+	} else {
+		out, reply.JobId, err = jobs.ExecuteJob(ctx, call.Fn, in)
+	}
+	if err != nil {
+		fs.Errorf(nil, "rc: grpc: %q: error: %v", path, err)
+		reply.Error = callError(err)
+		return reply, nil
+	}
+	reply.Result, err = paramsToStruct(out)
+	if err != nil {
+		reply.Error = callError(errors.Wrap(err, "failed to encode output"))
+	}
+	return reply, nil
+}
+
+// Call runs a single rc call and returns its result
+func (svc *service) Call(ctx context.Context, req *CallRequest) (*CallReply, error) {
+	return svc.call(ctx, req)
+}
+
+// Session runs rc calls as they arrive on the stream
+//
+// Calls are run concurrently so replies may arrive in a different
+// order to the requests - use the tag to match them up.
+func (svc *service) Session(stream RC_SessionServer) error {
+	ctx := stream.Context()
+	var (
+		wg     sync.WaitGroup
+		sendMu sync.Mutex
+		errMu  sync.Mutex
+		outErr error
+	)
+	setErr := func(err error) {
+		errMu.Lock()
+		if outErr == nil {
+			outErr = err
+		}
+		errMu.Unlock()
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			setErr(err)
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := svc.call(ctx, req)
+			if err != nil {
+				setErr(err)
+				return
+			}
+			sendMu.Lock()
+			err = stream.Send(reply)
+			sendMu.Unlock()
+			if err != nil {
+				setErr(err)
+			}
+		}()
+	}
+	wg.Wait()
+	return outErr
+}
+
+// Calls lists all the available rc calls
+func (svc *service) Calls(ctx context.Context, req *CallsRequest) (*CallsReply, error) {
+	if err := svc.checkAuth(ctx); err != nil {
+		return nil, err
+	}
+	reply := &CallsReply{}
+	for _, call := range rc.Calls.List() {
+		reply.Calls = append(reply.Calls, &CallInfo{
+			Path:         call.Path,
+			Title:        call.Title,
+			Help:         call.Help,
+			AuthRequired: call.AuthRequired,
+		})
+	}
+	return reply, nil
+}
+
+// statsJSON is the decoded output of core/stats
+type statsJSON struct {
+	Bytes        int64   `json:"bytes"`
+	Errors       int64   `json:"errors"`
+	FatalError   bool    `json:"fatalError"`
+	RetryError   bool    `json:"retryError"`
+	Checks       int64   `json:"checks"`
+	Transfers    int64   `json:"transfers"`
+	Deletes      int64   `json:"deletes"`
+	Renames      int64   `json:"renames"`
+	Speed        float64 `json:"speed"`
+	TransferTime float64 `json:"transferTime"`
+	ElapsedTime  float64 `json:"elapsedTime"`
+	LastError    string  `json:"lastError"`
+	Checking     []string
+	Transferring []struct {
+		Name       string  `json:"name"`
+		Size       int64   `json:"size"`
+		Bytes      int64   `json:"bytes"`
+		Eta        float64 `json:"eta"`
+		Group      string  `json:"group"`
+		Percentage int32   `json:"percentage"`
+		Speed      float64 `json:"speed"`
+		SpeedAvg   float64 `json:"speedAvg"`
+	} `json:"transferring"`
+}
+
+// stats reads the current stats using the core/stats call
+func stats(ctx context.Context, group string) (*StatsReply, error) {
+	call := rc.Calls.Get("core/stats")
+	if call == nil {
+		return nil, status.Error(codes.Unimplemented, "core/stats not available")
+	}
+	in := rc.Params{}
+	if group != "" {
+		in["group"] = group
+	}
+	out, err := call.Fn(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	var s statsJSON
+	err = rc.Reshape(&s, out)
+	if err != nil {
+		return nil, err
+	}
+	reply := &StatsReply{
+		Bytes:        s.Bytes,
+		Errors:       s.Errors,
+		FatalError:   s.FatalError,
+		RetryError:   s.RetryError,
+		Checks:       s.Checks,
+		Transfers:    s.Transfers,
+		Deletes:      s.Deletes,
+		Renames:      s.Renames,
+		Speed:        s.Speed,
+		TransferTime: s.TransferTime,
+		ElapsedTime:  s.ElapsedTime,
+		LastError:    s.LastError,
+		Checking:     s.Checking,
+	}
+	for _, tr := range s.Transferring {
+		reply.Transferring = append(reply.Transferring, &TransferStats{
+			Name:       tr.Name,
+			Size:       tr.Size,
+			Bytes:      tr.Bytes,
+			Eta:        tr.Eta,
+			Group:      tr.Group,
+			Percentage: tr.Percentage,
+			Speed:      tr.Speed,
+			SpeedAvg:   tr.SpeedAvg,
+		})
+	}
+	return reply, nil
+}
+
+// Stats streams the transfer stats at regular intervals until the
+// client cancels the stream
+func (svc *service) Stats(req *StatsRequest, stream RC_StatsServer) error {
+	ctx := stream.Context()
+	if err := svc.checkAuth(ctx); err != nil {
+		return err
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reply, err := stats(ctx, req.Group)
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(reply); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// List streams the entries of a remote path as they are found
+func (svc *service) List(req *ListRequest, stream RC_ListServer) error {
+	ctx := stream.Context()
+	call := rc.Calls.Get("operations/list")
+	if call == nil {
+		return status.Error(codes.Unimplemented, "operations/list not available")
+	}
+	if err := svc.checkCall(ctx, call); err != nil {
+		return err
+	}
+	f, remote, err := rc.GetFsAndRemote(rc.Params{"fs": req.Fs, "remote": req.Remote})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	var opt operations.ListJSONOpt
+	if o := req.Opt; o != nil {
+		opt = operations.ListJSONOpt{
+			Recurse:       o.Recurse,
+			NoModTime:     o.NoModTime,
+			NoMimeType:    o.NoMimeType,
+			ShowEncrypted: o.ShowEncrypted,
+			ShowOrigIDs:   o.ShowOrigIds,
+			ShowHash:      o.ShowHash,
+			DirsOnly:      o.DirsOnly,
+			FilesOnly:     o.FilesOnly,
+			HashTypes:     o.HashTypes,
+		}
+	}
+	err = operations.ListJSON(ctx, f, remote, &opt, func(item *operations.ListJSONItem) error {
+		out := &ListItem{
+			Path:          item.Path,
+			Name:          item.Name,
+			EncryptedPath: item.EncryptedPath,
+			Encrypted:     item.Encrypted,
+			Size:          item.Size,
+			MimeType:      item.MimeType,
+			IsDir:         item.IsDir,
+			Hashes:        item.Hashes,
+			Id:            item.ID,
+			OrigId:        item.OrigID,
+			Tier:          item.Tier,
+			IsBucket:      item.IsBucket,
+		}
+		if !item.ModTime.When.IsZero() {
+			out.ModTime = item.ModTime.When.Format(item.ModTime.Format)
+		}
+		return stream.Send(out)
+	})
+	if err != nil {
+		errOrig := errors.Cause(err)
+		if errOrig == fs.ErrorDirNotFound {
+			return status.Error(codes.NotFound, err.Error())
+		}
+		return err
+	}
+	return nil
+}
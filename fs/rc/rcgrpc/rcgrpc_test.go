@@ -0,0 +1,217 @@
+package rcgrpc
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	_ "github.com/rclone/rclone/backend/local"
+	_ "github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+const testBindAddress = "localhost:0"
+
+// startServer starts a test server returning a client connected to it
+func startServer(t *testing.T) (RCClient, func()) {
+	opt := rc.DefaultOpt
+	opt.Enabled = true
+	opt.NoAuth = true
+	opt.GRPCAddr = testBindAddress
+	s, err := Start(&opt)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	conn, err := grpc.Dial(s.Addr(), grpc.WithInsecure())
+	require.NoError(t, err)
+	return NewRCClient(conn), func() {
+		_ = conn.Close()
+		s.Close()
+		s.Wait()
+	}
+}
+
+func TestStartNotConfigured(t *testing.T) {
+	opt := rc.DefaultOpt
+	opt.Enabled = true
+	s, err := Start(&opt)
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+	client, cleanup := startServer(t)
+	defer cleanup()
+
+	params, err := structpb.NewStruct(map[string]interface{}{"potato": "sausage", "n": 42})
+	require.NoError(t, err)
+	reply, err := client.Call(ctx, &CallRequest{Path: "rc/noop", Params: params, Tag: 7})
+	require.NoError(t, err)
+	assert.Nil(t, reply.Error)
+	assert.Equal(t, int64(7), reply.Tag)
+	assert.Equal(t, "sausage", reply.Result.AsMap()["potato"])
+	assert.Equal(t, float64(42), reply.Result.AsMap()["n"])
+
+	reply, err = client.Call(ctx, &CallRequest{Path: "rc/error"})
+	require.NoError(t, err)
+	require.NotNil(t, reply.Error)
+	assert.Equal(t, int32(500), reply.Error.Status)
+
+	reply, err = client.Call(ctx, &CallRequest{Path: "not/found"})
+	require.NoError(t, err)
+	require.NotNil(t, reply.Error)
+	assert.Equal(t, int32(404), reply.Error.Status)
+}
+
+func TestCalls(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+
+	reply, err := client.Calls(context.Background(), &CallsRequest{})
+	require.NoError(t, err)
+	found := false
+	for _, call := range reply.Calls {
+		if call.Path == "rc/noop" {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestSession(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+
+	stream, err := client.Session(context.Background())
+	require.NoError(t, err)
+	for tag := int64(1); tag <= 3; tag++ {
+		require.NoError(t, stream.Send(&CallRequest{Path: "rc/noop", Tag: tag}))
+	}
+	require.NoError(t, stream.CloseSend())
+	tags := map[int64]bool{}
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Nil(t, reply.Error)
+		tags[reply.Tag] = true
+	}
+	assert.Equal(t, map[int64]bool{1: true, 2: true, 3: true}, tags)
+}
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, cleanup := startServer(t)
+	defer cleanup()
+
+	stream, err := client.Stats(ctx, &StatsRequest{IntervalMs: 10})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		reply, err := stream.Recv()
+		require.NoError(t, err)
+		assert.True(t, reply.ElapsedTime > 0)
+	}
+}
+
+func TestList(t *testing.T) {
+	client, cleanup := startServer(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "rclone-rcgrpc-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700))
+
+	stream, err := client.List(context.Background(), &ListRequest{Fs: dir})
+	require.NoError(t, err)
+	items := map[string]*ListItem{}
+	for {
+		item, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		items[item.Path] = item
+	}
+	require.Len(t, items, 2)
+	assert.Equal(t, int64(5), items["file.txt"].Size)
+	assert.False(t, items["file.txt"].IsDir)
+	assert.NotEqual(t, "", items["file.txt"].ModTime)
+	assert.True(t, items["subdir"].IsDir)
+}
+
+// basicAuthContext returns a context with basic auth credentials in
+// the incoming metadata
+func basicAuthContext(user, pass string) context.Context {
+	value := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", value))
+}
+
+func testAuth(t *testing.T, svc *service) {
+	call := rc.Calls.Get("rc/noopauth")
+	require.NotNil(t, call)
+
+	// No credentials
+	err := svc.checkCall(context.Background(), call)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	err = svc.checkCall(context.Background(), rc.Calls.Get("rc/noop"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Bad credentials
+	err = svc.checkCall(basicAuthContext("user", "wrong"), call)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	err = svc.checkCall(basicAuthContext("nobody", "pass"), call)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Good credentials
+	assert.NoError(t, svc.checkCall(basicAuthContext("user", "pass"), call))
+}
+
+func TestAuthBasic(t *testing.T) {
+	opt := rc.DefaultOpt
+	opt.HTTPOptions.BasicUser = "user"
+	opt.HTTPOptions.BasicPass = "pass"
+	testAuth(t, newService(&opt))
+}
+
+func TestAuthHtPasswd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-rcgrpc-test")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	sum := sha1.Sum([]byte("pass"))
+	htpasswd := filepath.Join(dir, "htpasswd")
+	require.NoError(t, ioutil.WriteFile(htpasswd, []byte("user:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600))
+
+	opt := rc.DefaultOpt
+	opt.HTTPOptions.HtPasswd = htpasswd
+	testAuth(t, newService(&opt))
+}
+
+func TestAuthNone(t *testing.T) {
+	opt := rc.DefaultOpt
+	svc := newService(&opt)
+	assert.NoError(t, svc.checkCall(context.Background(), rc.Calls.Get("rc/noop")))
+	err := svc.checkCall(context.Background(), rc.Calls.Get("rc/noopauth"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
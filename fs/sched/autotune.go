@@ -0,0 +1,277 @@
+package sched
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// With --auto-tune the number of transfers and checkers of the
+// operations on each remote are adjusted as they run.
+//
+// Each interval the throughput of the last interval is compared with
+// the one before. While it improves the limit keeps moving the same
+// way, when it gets worse the limit moves back the other way, so it
+// settles around the sweet spot for the remote. Errors, rate limit
+// responses and using more than --auto-tune-memory back the limits off
+// quickly instead.
+
+// tunedLimit is a concurrency limit adjusted by hill climbing
+type tunedLimit struct {
+	slots    scheduler // the slots, with size as the current limit
+	max      int       // most slots
+	dir      int       // +1 or -1 for the way the limit moved last
+	lastRate float64   // rate in the last interval, 0 if not known
+	lastSeq  uint64    // slots.seq at the last tune
+}
+
+// newTunedLimit makes a tunedLimit starting at start slots
+func newTunedLimit(start, max int) tunedLimit {
+	if max < 1 {
+		max = 1
+	}
+	if start < 1 {
+		start = 1
+	}
+	if start > max {
+		start = max
+	}
+	return tunedLimit{
+		slots: scheduler{size: start},
+		max:   max,
+		dir:   1,
+	}
+}
+
+// size returns the current limit
+func (l *tunedLimit) size() int {
+	l.slots.mu.Lock()
+	defer l.slots.mu.Unlock()
+	return l.slots.size
+}
+
+// tune adjusts the limit given the rate of the last interval and
+// whether to back off, returning the new limit
+func (l *tunedLimit) tune(rate float64, backOff bool) int {
+	l.slots.mu.Lock()
+	size := l.slots.size
+	// The limit was only in the way if something had to wait
+	saturated := l.slots.seq != l.lastSeq
+	l.lastSeq = l.slots.seq
+	l.slots.mu.Unlock()
+
+	newSize := size
+	switch {
+	case backOff:
+		newSize = size * 3 / 4
+		l.dir = 1
+		rate = 0 // start climbing afresh
+	case !saturated:
+		// Not using all the slots so changing the limit won't help
+	case l.lastRate == 0 || rate >= l.lastRate*1.05:
+		// Better or nothing to compare with so carry on
+		newSize = size + l.dir
+	case rate <= l.lastRate*0.95:
+		// Worse so go back the other way
+		l.dir = -l.dir
+		newSize = size + l.dir
+	}
+	if newSize < 1 {
+		newSize = 1
+	}
+	if newSize > l.max {
+		newSize = l.max
+	}
+	l.lastRate = rate
+	l.slots.resize(newSize)
+	return newSize
+}
+
+// Tuner adjusts the concurrency of the transfers and checks on a
+// remote with --auto-tune
+type Tuner struct {
+	// These are accessed atomically and reset each interval so
+	// must be first for 64 bit alignment
+	bytes     int64 // bytes transferred
+	checks    int64 // checks finished
+	errors    int64 // errors
+	throttled int64 // rate limit responses
+
+	name      string
+	transfers tunedLimit
+	checkers  tunedLimit
+}
+
+// Bytes records n bytes transferred
+func (t *Tuner) Bytes(n int) {
+	if t != nil {
+		atomic.AddInt64(&t.bytes, int64(n))
+	}
+}
+
+// Error records an error
+func (t *Tuner) Error() {
+	if t != nil {
+		atomic.AddInt64(&t.errors, 1)
+	}
+}
+
+// Throttled records a rate limit response from the remote
+func (t *Tuner) Throttled() {
+	if t != nil {
+		atomic.AddInt64(&t.throttled, 1)
+	}
+}
+
+// MaxTransfers returns the most transfers the tuner will allow, or
+// --transfers if t is nil
+func (t *Tuner) MaxTransfers() int {
+	if t == nil {
+		return fs.Config.Transfers
+	}
+	return t.transfers.max
+}
+
+// MaxCheckers returns the most checkers the tuner will allow, or
+// --checkers if t is nil
+func (t *Tuner) MaxCheckers() int {
+	if t == nil {
+		return fs.Config.Checkers
+	}
+	return t.checkers.max
+}
+
+// tune adjusts the limits given the counters of the last interval
+func (t *Tuner) tune(interval time.Duration, memoryPressure bool) {
+	seconds := interval.Seconds()
+	bytes := atomic.SwapInt64(&t.bytes, 0)
+	checks := atomic.SwapInt64(&t.checks, 0)
+	errors := atomic.SwapInt64(&t.errors, 0)
+	throttled := atomic.SwapInt64(&t.throttled, 0)
+	backOff := errors > 0 || throttled > 0 || memoryPressure
+	oldTransfers, oldCheckers := t.transfers.size(), t.checkers.size()
+	transfers := t.transfers.tune(float64(bytes)/seconds, backOff)
+	checkers := t.checkers.tune(float64(checks)/seconds, backOff)
+	if transfers != oldTransfers || checkers != oldCheckers {
+		fs.Debugf(nil, "%s: auto-tune: transfers %d, checkers %d (%v/s, %.1f checks/s, %d errors, %d throttled, memory pressure %v)",
+			t.name, transfers, checkers, fs.SizeSuffix(float64(bytes)/seconds), float64(checks)/seconds, errors, throttled, memoryPressure)
+	}
+}
+
+// The tuners of the remotes, made when first needed
+var (
+	tunersMu sync.Mutex
+	tuners   = map[string]*Tuner{}
+)
+
+// TunerFor returns the Tuner for the remote f, or nil if --auto-tune
+// isn't in use
+func TunerFor(f fs.Info) *Tuner {
+	if !fs.Config.AutoTune || f == nil {
+		return nil
+	}
+	tunersMu.Lock()
+	defer tunersMu.Unlock()
+	name := f.Name()
+	t := tuners[name]
+	if t == nil {
+		t = &Tuner{
+			name:      name,
+			transfers: newTunedLimit(fs.Config.Transfers, fs.Config.AutoTuneMaxTransfers),
+			checkers:  newTunedLimit(fs.Config.Checkers, fs.Config.AutoTuneMaxCheckers),
+		}
+		if len(tuners) == 0 {
+			go tuneLoop()
+		}
+		tuners[name] = t
+		resizeGlobal()
+		fs.Infof(nil, "%s: auto-tuning transfers up to %d and checkers up to %d", name, t.transfers.max, t.checkers.max)
+	}
+	return t
+}
+
+// resizeGlobal sizes the global scheduler so it has room for the
+// transfers the tuners allow, but never fewer slots than --transfers.
+//
+// Call with tunersMu held.
+func resizeGlobal() {
+	total := 0
+	for _, t := range tuners {
+		total += t.transfers.size()
+	}
+	if total <= slots() {
+		total = 0 // use --transfers
+	}
+	global.resize(total)
+}
+
+// memoryPressure returns whether rclone is using more memory than
+// --auto-tune-memory
+func memoryPressure() bool {
+	if fs.Config.AutoTuneMemory <= 0 {
+		return false
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse > uint64(fs.Config.AutoTuneMemory)
+}
+
+// tuneLoop tunes all the tuners every --auto-tune-interval
+func tuneLoop() {
+	interval := fs.Config.AutoTuneInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	for range time.Tick(interval) {
+		pressure := memoryPressure()
+		tunersMu.Lock()
+		for _, t := range tuners {
+			t.tune(interval, pressure)
+		}
+		resizeGlobal()
+		tunersMu.Unlock()
+	}
+}
+
+// tunerKey is the context key for the Tuner
+type tunerKey struct{}
+
+// WithTuner returns a copy of ctx whose transfers and checks are tuned
+// by t. If t is nil ctx is returned.
+func WithTuner(ctx context.Context, t *Tuner) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tunerKey{}, t)
+}
+
+// TunerFromContext returns the Tuner set in ctx or nil if there isn't
+// one. The methods of a nil Tuner do nothing.
+func TunerFromContext(ctx context.Context) *Tuner {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tunerKey{}).(*Tuner)
+	return t
+}
+
+// AcquireCheck waits until the check with ctx can start if it is
+// being tuned, returning a function to call when it has finished.
+func AcquireCheck(ctx context.Context) (release func(), err error) {
+	t := TunerFromContext(ctx)
+	if t == nil {
+		return func() {}, nil
+	}
+	releaseSlot, err := t.checkers.slots.acquire(ctx, Priority(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		atomic.AddInt64(&t.checks, 1)
+		releaseSlot()
+	}, nil
+}
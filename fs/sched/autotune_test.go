@@ -0,0 +1,178 @@
+package sched
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saturate makes a waiter queue for the slots of l so the next tune
+// sees them as in the way
+func saturate(l *tunedLimit) {
+	l.slots.mu.Lock()
+	l.slots.seq++
+	l.slots.mu.Unlock()
+}
+
+func TestTunedLimit(t *testing.T) {
+	l := newTunedLimit(4, 6)
+	assert.Equal(t, 4, l.size())
+
+	// Not saturated so nothing changes
+	assert.Equal(t, 4, l.tune(100, false))
+
+	// Climbs while the rate improves
+	saturate(&l)
+	assert.Equal(t, 5, l.tune(110, false))
+	saturate(&l)
+	assert.Equal(t, 6, l.tune(200, false))
+	// But no further than max
+	saturate(&l)
+	assert.Equal(t, 6, l.tune(300, false))
+
+	// About the same so stays put
+	saturate(&l)
+	assert.Equal(t, 6, l.tune(302, false))
+
+	// Worse so goes back
+	saturate(&l)
+	assert.Equal(t, 5, l.tune(200, false))
+	// Better again so keeps going down
+	saturate(&l)
+	assert.Equal(t, 4, l.tune(250, false))
+
+	// Backs off quickly
+	assert.Equal(t, 3, l.tune(250, true))
+	assert.Equal(t, 2, l.tune(250, true))
+	assert.Equal(t, 1, l.tune(250, true))
+	assert.Equal(t, 1, l.tune(250, true))
+
+	// Then climbs again
+	saturate(&l)
+	assert.Equal(t, 2, l.tune(10, false))
+}
+
+func TestTunedLimitResize(t *testing.T) {
+	ctx := context.Background()
+	l := newTunedLimit(1, 2)
+	release, err := l.slots.acquire(ctx, 0)
+	require.NoError(t, err)
+
+	acquired := make(chan func())
+	go func() {
+		release, err := l.slots.acquire(ctx, 0)
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	waitQueued(t, &l.slots, 1)
+
+	// Growing the limit lets the waiter in
+	assert.Equal(t, 2, l.tune(100, false))
+	release2 := <-acquired
+
+	// Shrinking it drops the slots as they are released
+	assert.Equal(t, 1, l.tune(0, true))
+	release()
+	release2()
+	l.slots.mu.Lock()
+	assert.Equal(t, 0, l.slots.inUse)
+	l.slots.mu.Unlock()
+}
+
+func TestTuner(t *testing.T) {
+	ctx := context.Background()
+	oldAutoTune := fs.Config.AutoTune
+	defer func() {
+		fs.Config.AutoTune = oldAutoTune
+	}()
+
+	// A nil tuner does nothing
+	var nilTuner *Tuner
+	nilTuner.Bytes(1)
+	nilTuner.Error()
+	nilTuner.Throttled()
+	assert.Equal(t, fs.Config.Transfers, nilTuner.MaxTransfers())
+	assert.Equal(t, ctx, WithTuner(ctx, nil))
+	release, err := AcquireCheck(ctx)
+	require.NoError(t, err)
+	release()
+
+	f := mockfs.NewFs("sched-tuner-test", "")
+	fs.Config.AutoTune = false
+	assert.Nil(t, TunerFor(f))
+
+	fs.Config.AutoTune = true
+	tuner := TunerFor(f)
+	require.NotNil(t, tuner)
+	assert.True(t, tuner == TunerFor(f))
+	assert.Equal(t, fs.Config.AutoTuneMaxTransfers, tuner.MaxTransfers())
+	assert.Equal(t, fs.Config.AutoTuneMaxCheckers, tuner.MaxCheckers())
+
+	ctx = WithTuner(ctx, tuner)
+	assert.True(t, tuner == TunerFromContext(ctx))
+
+	// Checks and transfers take slots from the tuner
+	release, err = AcquireCheck(ctx)
+	require.NoError(t, err)
+	release()
+	release, err = Acquire(ctx)
+	require.NoError(t, err)
+	tuner.transfers.slots.mu.Lock()
+	assert.Equal(t, 1, tuner.transfers.slots.inUse)
+	tuner.transfers.slots.mu.Unlock()
+	release()
+
+	tuner.Bytes(100)
+	tuner.Throttled()
+	tunersMu.Lock()
+	transfers := tuner.transfers.size()
+	saturate(&tuner.transfers)
+	tuner.tune(1, false)
+	tunersMu.Unlock()
+	assert.True(t, tuner.transfers.size() < transfers || transfers == 1)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&tuner.bytes))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&tuner.throttled))
+}
+
+func TestTunerGlobalSlots(t *testing.T) {
+	oldTransfers := fs.Config.Transfers
+	tunersMu.Lock()
+	oldTuners := tuners
+	defer func() {
+		tuners = oldTuners
+		resizeGlobal()
+		tunersMu.Unlock()
+		fs.Config.Transfers = oldTransfers
+	}()
+	fs.Config.Transfers = 4
+	tuners = map[string]*Tuner{}
+	globalSlots := func() int {
+		global.mu.Lock()
+		defer global.mu.Unlock()
+		return global.slots()
+	}
+
+	// Without tuners there are --transfers slots
+	resizeGlobal()
+	assert.Equal(t, 4, globalSlots())
+
+	// The tuners raise them to fit their transfers
+	tuners["a"] = &Tuner{transfers: newTunedLimit(3, 10)}
+	tuners["b"] = &Tuner{transfers: newTunedLimit(2, 10)}
+	resizeGlobal()
+	assert.Equal(t, 5, globalSlots())
+
+	// But never below --transfers
+	tuners["b"].transfers.tune(0, true)
+	resizeGlobal()
+	assert.Equal(t, 4, globalSlots())
+	fs.Config.Transfers = 2
+	assert.Equal(t, 2, globalSlots())
+	resizeGlobal()
+	assert.Equal(t, 4, globalSlots())
+}
@@ -0,0 +1,211 @@
+package sched
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+// Op is a class of operation paced by the tpslimit of a remote
+type Op int
+
+// Classes of operation
+const (
+	OpList   Op = iota // directory listings
+	OpRead             // opening objects to read them
+	OpWrite            // uploads and server side copies and moves
+	OpDelete           // deletions
+	numOps
+)
+
+var opNames = [numOps]string{"list", "read", "write", "delete"}
+
+// String turns an Op into a string
+func (op Op) String() string {
+	if op < 0 || op >= numOps {
+		return "unknown"
+	}
+	return opNames[op]
+}
+
+// Priorities are the priorities of the classes of operation, indexed
+// by Op. Higher numbers are higher priority.
+type Priorities [numOps]int
+
+// ParsePriorities parses priorities in the form "list=-1,read=2".
+// Classes which aren't mentioned get priority 0.
+func ParsePriorities(s string) (priorities Priorities, err error) {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		equals := strings.IndexRune(item, '=')
+		if equals < 0 {
+			return priorities, errors.Errorf("bad priority %q - expecting op=priority", item)
+		}
+		name := strings.ToLower(strings.TrimSpace(item[:equals]))
+		op := Op(-1)
+		for i, opName := range opNames {
+			if name == opName {
+				op = Op(i)
+			}
+		}
+		if op < 0 {
+			return priorities, errors.Errorf("unknown operation %q in priority - must be one of %s", name, strings.Join(opNames[:], ", "))
+		}
+		priorities[op], err = strconv.Atoi(strings.TrimSpace(item[equals+1:]))
+		if err != nil {
+			return priorities, errors.Wrapf(err, "bad priority %q", item)
+		}
+	}
+	return priorities, nil
+}
+
+// pacer limits the transactions per second of a remote, giving the
+// token to the waiting operation with the highest priority each time
+type pacer struct {
+	limiter    *rate.Limiter
+	priorities Priorities
+	queue      scheduler // one slot for the operation waiting for the limiter
+}
+
+// newPacer makes a pacer for tps transactions per second
+func newPacer(tps float64, burst int, priorities Priorities) *pacer {
+	if burst < 1 {
+		burst = 1
+	}
+	return &pacer{
+		limiter:    rate.NewLimiter(rate.Limit(tps), burst),
+		priorities: priorities,
+		queue:      scheduler{size: 1},
+	}
+}
+
+// wait waits until the operation op may run
+func (p *pacer) wait(ctx context.Context, op Op) error {
+	release, err := p.queue.acquire(ctx, p.priorities[op])
+	if err != nil {
+		return err
+	}
+	defer release()
+	return p.limiter.Wait(ctx)
+}
+
+// The pacers for the remotes, nil for those without a tpslimit. They
+// are made the first time a remote is used.
+var (
+	pacersMu sync.Mutex
+	pacers   = map[string]*pacer{}
+)
+
+// remotePacer makes the pacer for the remote called name from its
+// config or returns nil if it has no tpslimit.
+//
+// This can be set in the config file or with the
+// RCLONE_CONFIG_<REMOTE>_TPSLIMIT environment variables.
+func remotePacer(name string) *pacer {
+	config := fs.ConfigMap(nil, name)
+	value, ok := config.Get("tpslimit")
+	if !ok {
+		return nil
+	}
+	tps, err := strconv.ParseFloat(value, 64)
+	if err != nil || tps <= 0 {
+		if err != nil || tps < 0 {
+			fs.Errorf(nil, "%s: ignoring bad tpslimit %q", name, value)
+		}
+		return nil
+	}
+	burst := 1
+	if value, ok := config.Get("tpslimit_burst"); ok {
+		burst, err = strconv.Atoi(value)
+		if err != nil {
+			fs.Errorf(nil, "%s: ignoring bad tpslimit_burst %q", name, value)
+			burst = 1
+		}
+	}
+	priority := fs.Config.TPSLimitPriority
+	if value, ok := config.Get("tpslimit_priority"); ok {
+		priority = value
+	}
+	priorities, err := ParsePriorities(priority)
+	if err != nil {
+		fs.Errorf(nil, "%s: ignoring tpslimit_priority: %v", name, err)
+		priorities = Priorities{}
+	}
+	fs.Infof(nil, "%s: starting transaction limiter: max %g transactions/s with burst %d", name, tps, burst)
+	return newPacer(tps, burst, priorities)
+}
+
+// getPacer returns the pacer for the remote called name
+func getPacer(name string) *pacer {
+	pacersMu.Lock()
+	defer pacersMu.Unlock()
+	p, ok := pacers[name]
+	if !ok {
+		p = remotePacer(name)
+		pacers[name] = p
+	}
+	return p
+}
+
+// paceKey is the context key for the pacer of the remote the HTTP
+// requests are made to
+type paceKey struct{}
+
+// paceValue is the value stored under paceKey
+type paceValue struct {
+	p  *pacer
+	op Op
+}
+
+// WithPace returns a copy of ctx which makes the HTTP requests done
+// with it wait for the tpslimit of f, if the remote has one, as part
+// of the operation op. Use it for the calls to f only.
+func WithPace(ctx context.Context, f fs.Info, op Op) context.Context {
+	if f == nil {
+		return ctx
+	}
+	p := getPacer(f.Name())
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, paceKey{}, paceValue{p: p, op: op})
+}
+
+// PaceRequest waits until an HTTP request done with ctx may run if it
+// was made by WithPace for a remote with a tpslimit.
+//
+// Requests waiting for the limit run in order of the priority of the
+// class of their operation, set by tpslimit_priority or
+// --tpslimit-priority, so for example listings can be made to wait for
+// reads on a remote with strict API limits.
+func PaceRequest(ctx context.Context) error {
+	value, ok := ctx.Value(paceKey{}).(paceValue)
+	if !ok {
+		return nil
+	}
+	return value.p.wait(ctx, value.op)
+}
+
+// noTPSLimitKey is the context key set by WithoutTPSLimit
+type noTPSLimitKey struct{}
+
+// WithoutTPSLimit returns a copy of ctx whose HTTP requests don't wait
+// for the global --tpslimit. Use it for operations already paced by a
+// limit of their own, such as --delete-tpslimit, so they aren't
+// throttled twice. The tpslimit of the remote still applies.
+func WithoutTPSLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTPSLimitKey{}, true)
+}
+
+// IgnoreTPSLimit returns true if ctx was made by WithoutTPSLimit
+func IgnoreTPSLimit(ctx context.Context) bool {
+	return ctx.Value(noTPSLimitKey{}) != nil
+}
@@ -0,0 +1,116 @@
+package sched
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriorities(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Priorities
+		err  bool
+	}{
+		{"", Priorities{}, false},
+		{"read=2", Priorities{OpRead: 2}, false},
+		{"list=-1, Write=3,delete=1", Priorities{OpList: -1, OpWrite: 3, OpDelete: 1}, false},
+		{"read", Priorities{}, true},
+		{"potato=1", Priorities{}, true},
+		{"read=high", Priorities{}, true},
+	} {
+		got, err := ParsePriorities(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+	}
+	assert.Equal(t, "delete", OpDelete.String())
+	assert.Equal(t, "unknown", Op(99).String())
+}
+
+func TestPacerOrder(t *testing.T) {
+	ctx := context.Background()
+	p := newPacer(100, 1, Priorities{OpList: -1, OpRead: 1})
+
+	// Take the token and the slot so the others queue up
+	release, err := p.queue.acquire(ctx, 0)
+	require.NoError(t, err)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []Op
+	)
+	for i, op := range []Op{OpList, OpWrite, OpRead, OpList} {
+		wg.Add(1)
+		go func(op Op) {
+			defer wg.Done()
+			assert.NoError(t, p.wait(ctx, op))
+			mu.Lock()
+			order = append(order, op)
+			mu.Unlock()
+		}(op)
+		waitQueued(t, &p.queue, i+1)
+	}
+	release()
+	wg.Wait()
+	assert.Equal(t, []Op{OpRead, OpWrite, OpList, OpList}, order)
+}
+
+func TestPacerCancel(t *testing.T) {
+	p := newPacer(0.001, 1, Priorities{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, p.wait(ctx, OpRead)) // uses the burst
+	assert.Error(t, p.wait(ctx, OpRead))
+}
+
+func TestPace(t *testing.T) {
+	ctx := context.Background()
+
+	// No tpslimit so no pacing
+	f := mockfs.NewFs("sched-pace-none", "")
+	assert.Equal(t, ctx, WithPace(ctx, f, OpList))
+	assert.Nil(t, getPacer("sched-pace-none"))
+	assert.Equal(t, ctx, WithPace(ctx, nil, OpList))
+	require.NoError(t, PaceRequest(ctx))
+
+	name := "sched-pace-test"
+	for key, value := range map[string]string{
+		"tpslimit":          "1000",
+		"tpslimit_burst":    "5",
+		"tpslimit_priority": "list=-1",
+	} {
+		env := fs.ConfigToEnv(name, key)
+		require.NoError(t, os.Setenv(env, value))
+		defer func() { _ = os.Unsetenv(env) }()
+	}
+	f = mockfs.NewFs(name, "")
+	paceCtx := WithPace(ctx, f, OpRead)
+	p := getPacer(name)
+	require.NotNil(t, p)
+	assert.Equal(t, Priorities{OpList: -1}, p.priorities)
+	assert.Equal(t, 5, p.limiter.Burst())
+	assert.Equal(t, float64(1000), float64(p.limiter.Limit()))
+
+	require.NoError(t, PaceRequest(paceCtx))
+
+	// Each request takes a token and gives up waiting when cancelled
+	slow := newPacer(0.001, 2, Priorities{})
+	cancelCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	paceCtx = context.WithValue(cancelCtx, paceKey{}, paceValue{p: slow, op: OpList})
+	require.NoError(t, PaceRequest(paceCtx))
+	require.NoError(t, PaceRequest(paceCtx))
+	assert.Error(t, PaceRequest(paceCtx))
+}
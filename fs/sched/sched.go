@@ -0,0 +1,199 @@
+// Package sched schedules transfers between the operations running in
+// an rclone process.
+//
+// All the transfers share --transfers slots. When they are all in use
+// the transfers waiting for a slot are given one in priority order, so
+// a high priority operation, eg an urgent copy started over rc, jumps
+// ahead of the queued transfers of a long running low priority sync.
+//
+// The HTTP requests to remotes with a tpslimit are paced in the same
+// way, see WithPace.
+package sched
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// priorityKey is the context key for the priority
+type priorityKey struct{}
+
+// WithPriority returns a context which gives the transfers done with
+// it priority p. Higher numbers are higher priority.
+func WithPriority(ctx context.Context, p int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// Priority returns the priority of the transfers done with ctx. This
+// is the one set with WithPriority or --priority if not set.
+func Priority(ctx context.Context) int {
+	if p, ok := ctx.Value(priorityKey{}).(int); ok {
+		return p
+	}
+	return fs.Config.Priority
+}
+
+// waiter is a transfer waiting for a slot
+type waiter struct {
+	priority int
+	seq      uint64        // order of arrival to keep it fair
+	granted  chan struct{} // closed when given a slot
+	index    int           // index in the heap, -1 if not in it
+}
+
+// waiters is a heap of waiters, highest priority first
+type waiters []*waiter
+
+func (ws waiters) Len() int { return len(ws) }
+
+func (ws waiters) Less(i, j int) bool {
+	if ws[i].priority != ws[j].priority {
+		return ws[i].priority > ws[j].priority
+	}
+	return ws[i].seq < ws[j].seq
+}
+
+func (ws waiters) Swap(i, j int) {
+	ws[i], ws[j] = ws[j], ws[i]
+	ws[i].index = i
+	ws[j].index = j
+}
+
+func (ws *waiters) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*ws)
+	*ws = append(*ws, w)
+}
+
+func (ws *waiters) Pop() interface{} {
+	old := *ws
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*ws = old[:n-1]
+	return w
+}
+
+// scheduler hands out the transfer slots
+type scheduler struct {
+	mu      sync.Mutex
+	size    int     // number of slots, 0 for --transfers
+	inUse   int     // slots in use
+	seq     uint64  // next waiter sequence number
+	waiting waiters // transfers waiting for a slot
+}
+
+// global is the scheduler shared by all operations
+var global = &scheduler{}
+
+// slots returns the number of slots of s
+func (s *scheduler) slots() int {
+	if s.size > 0 {
+		return s.size
+	}
+	return slots()
+}
+
+// slots returns the number of transfer slots
+//
+// With --auto-tune the tuners may raise the slots of the global
+// scheduler above this.
+func slots() int {
+	if fs.Config.Transfers > 0 {
+		return fs.Config.Transfers
+	}
+	return 1
+}
+
+// resize sets the number of slots to size, handing any new slots to
+// the waiters
+func (s *scheduler) resize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.size = size
+	for len(s.waiting) > 0 && s.inUse < s.slots() {
+		s.inUse++
+		w := heap.Pop(&s.waiting).(*waiter)
+		close(w.granted)
+	}
+}
+
+// acquire waits for a slot for a transfer with priority p
+func (s *scheduler) acquire(ctx context.Context, p int) (release func(), err error) {
+	s.mu.Lock()
+	if s.inUse < s.slots() && len(s.waiting) == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return s.release, nil
+	}
+	w := &waiter{
+		priority: p,
+		seq:      s.seq,
+		granted:  make(chan struct{}),
+	}
+	s.seq++
+	heap.Push(&s.waiting, w)
+	s.mu.Unlock()
+	select {
+	case <-w.granted:
+		return s.release, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&s.waiting, w.index)
+			s.mu.Unlock()
+		} else {
+			// Given a slot as we were cancelled so pass it on
+			s.mu.Unlock()
+			s.release()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release gives up a slot, handing it to the highest priority waiter
+// if there is one
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// If --transfers has been reduced then drop slots rather than
+	// passing them on
+	if len(s.waiting) > 0 && s.inUse <= s.slots() {
+		w := heap.Pop(&s.waiting).(*waiter)
+		close(w.granted)
+		return
+	}
+	s.inUse--
+}
+
+// Acquire waits until the transfer with ctx can start, returning a
+// function to call when it has finished.
+//
+// Transfers are started in order of the Priority of ctx then in the
+// order they arrived once all the --transfers slots are in use.
+//
+// If ctx has a Tuner the transfer waits for one of its slots too.
+func Acquire(ctx context.Context) (release func(), err error) {
+	p := Priority(ctx)
+	t := TunerFromContext(ctx)
+	if t == nil {
+		return global.acquire(ctx, p)
+	}
+	releaseTuned, err := t.transfers.slots.acquire(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	releaseGlobal, err := global.acquire(ctx, p)
+	if err != nil {
+		releaseTuned()
+		return nil, err
+	}
+	return func() {
+		releaseGlobal()
+		releaseTuned()
+	}, nil
+}
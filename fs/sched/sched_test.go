@@ -0,0 +1,105 @@
+package sched
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriority(t *testing.T) {
+	ctx := context.Background()
+	oldPriority := fs.Config.Priority
+	defer func() {
+		fs.Config.Priority = oldPriority
+	}()
+	fs.Config.Priority = 3
+	assert.Equal(t, 3, Priority(ctx))
+	assert.Equal(t, -7, Priority(WithPriority(ctx, -7)))
+}
+
+// waitQueued waits until n transfers are waiting for s
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	for i := 0; i < 1000; i++ {
+		s.mu.Lock()
+		queued := len(s.waiting)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued", n)
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	ctx := context.Background()
+	oldTransfers := fs.Config.Transfers
+	defer func() {
+		fs.Config.Transfers = oldTransfers
+	}()
+	fs.Config.Transfers = 1
+	s := &scheduler{}
+
+	release, err := s.acquire(ctx, 0)
+	require.NoError(t, err)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []int
+	)
+	for i, p := range []int{0, 5, 1, 5} {
+		wg.Add(1)
+		go func(id, p int) {
+			defer wg.Done()
+			release, err := s.acquire(ctx, p)
+			require.NoError(t, err)
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+			release()
+		}(i, p)
+		waitQueued(t, s, i+1)
+	}
+
+	// A cancelled transfer gives up its place
+	cancelCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		waitQueued(t, s, 5)
+		cancel()
+	}()
+	_, err = s.acquire(cancelCtx, 10)
+	assert.Equal(t, context.Canceled, err)
+	waitQueued(t, s, 4)
+
+	release()
+	wg.Wait()
+	assert.Equal(t, []int{1, 3, 2, 0}, order)
+	assert.Equal(t, 0, s.inUse)
+}
+
+func TestSchedulerSlots(t *testing.T) {
+	ctx := context.Background()
+	oldTransfers := fs.Config.Transfers
+	defer func() {
+		fs.Config.Transfers = oldTransfers
+	}()
+	fs.Config.Transfers = 3
+	s := &scheduler{}
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		release, err := s.acquire(ctx, 0)
+		require.NoError(t, err)
+		releases = append(releases, release)
+	}
+	assert.Equal(t, 3, s.inUse)
+	for _, release := range releases {
+		release()
+	}
+	assert.Equal(t, 0, s.inUse)
+}
@@ -0,0 +1,273 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+)
+
+// checkpointHeader is the first line of the checkpoint file
+type checkpointHeader struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+}
+
+// checkpointEntry is a line of the checkpoint file recording a
+// directory whose whole subtree has been synced
+type checkpointEntry struct {
+	Dir string `json:"dir"`
+}
+
+// checkpointDir is a directory whose subtree is being synced
+type checkpointDir struct {
+	pending int   // files and subdirectories still to finish, plus one until listed
+	errors  int64 // the sync error count when the directory was started
+	failed  bool  // set if the subtree can't be recorded as complete
+}
+
+// checkpoint records the directories whose whole subtree has been
+// synced with --checkpoint so an interrupted sync can skip them when it
+// is run again.
+//
+// A directory is complete when it has been listed, all the files in it
+// have been checked and transferred and all its subdirectories are
+// complete, without any errors in the sync in the meantime. A
+// directory with anything to delete in it isn't recorded.
+//
+// The checkpoint file is a header followed by a line of JSON for each
+// complete directory. It is removed when the sync succeeds.
+type checkpoint struct {
+	path   string
+	header checkpointHeader
+	done   map[string]struct{} // directories completed by the last run
+	mu     sync.Mutex
+	dirs   map[string]*checkpointDir // directories in progress
+	out    *os.File                  // opened on the first write
+}
+
+// loadCheckpoint reads the checkpoint at path if it exists, checking it
+// is for syncing fsrc to fdst
+func loadCheckpoint(path string, fdst, fsrc fs.Fs) (c *checkpoint, err error) {
+	c = &checkpoint{
+		path: path,
+		header: checkpointHeader{
+			Source: fs.ConfigString(fsrc),
+			Dest:   fs.ConfigString(fdst),
+		},
+		done: map[string]struct{}{},
+		dirs: map[string]*checkpointDir{},
+	}
+	in, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to open checkpoint")
+	}
+	defer fs.CheckClose(in, &err)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+	if !scanner.Scan() {
+		return nil, errors.Errorf("checkpoint %q is empty", path)
+	}
+	var header checkpointHeader
+	if err = json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse checkpoint %q", path)
+	}
+	if header != c.header {
+		return nil, errors.Errorf("checkpoint %q is for %s -> %s", path, header.Source, header.Dest)
+	}
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A partly written last line if interrupted
+			fs.Debugf(nil, "Ignoring bad line in checkpoint: %v", err)
+			continue
+		}
+		c.done[entry.Dir] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read checkpoint %q", path)
+	}
+	fs.Infof(fdst, "Skipping %d directories completed by the last run from checkpoint %q", len(c.done), path)
+	return c, nil
+}
+
+// parentDir returns the directory remote is in
+func parentDir(remote string) string {
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	return dir
+}
+
+// isDone returns true if dir was completed by the last run
+func (c *checkpoint) isDone(dir string) bool {
+	_, found := c.done[dir]
+	return found
+}
+
+// write appends a line of JSON to the checkpoint file, creating it
+// if necessary - call with the lock held
+func (c *checkpoint) write(v interface{}) error {
+	if c.out == nil {
+		_, statErr := os.Stat(c.path)
+		out, err := os.OpenFile(c.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return errors.Wrap(err, "failed to open checkpoint")
+		}
+		c.out = out
+		if os.IsNotExist(statErr) {
+			if err = c.write(c.header); err != nil {
+				return err
+			}
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = c.out.Write(append(data, '\n'))
+	if err != nil {
+		return errors.Wrap(err, "failed to write checkpoint")
+	}
+	return nil
+}
+
+// startDir records that the subtree at dir is being synced. errorCount is
+// the current sync error count.
+func (c *checkpoint) startDir(dir string, errorCount int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if dir != "" {
+		if parent := c.dirs[parentDir(dir)]; parent != nil {
+			parent.pending++
+		}
+	}
+	c.dirs[dir] = &checkpointDir{pending: 1, errors: errorCount}
+}
+
+// addFile records that a file in dir is being synced
+func (c *checkpoint) addFile(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d := c.dirs[dir]; d != nil {
+		d.pending++
+	}
+}
+
+// fail records that dir can't be complete in this run
+func (c *checkpoint) fail(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d := c.dirs[dir]; d != nil {
+		d.failed = true
+	}
+}
+
+// finish records that one of the things pending in dir has finished,
+// recording dir and its parents as complete if nothing is left
+// pending in them. errorCount is the current sync error count.
+func (c *checkpoint) finish(dir string, errorCount int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		d := c.dirs[dir]
+		if d == nil {
+			return
+		}
+		d.pending--
+		if d.pending > 0 {
+			return
+		}
+		delete(c.dirs, dir)
+		failed := d.failed || errorCount != d.errors
+		if !failed {
+			if err := c.write(checkpointEntry{Dir: dir}); err != nil {
+				fs.Errorf(nil, "Failed to record %q in checkpoint: %v", dir, err)
+				failed = true
+			}
+		}
+		if dir == "" {
+			return
+		}
+		dir = parentDir(dir)
+		if failed {
+			if parent := c.dirs[dir]; parent != nil {
+				parent.failed = true
+			}
+		}
+	}
+}
+
+// close closes the checkpoint file, removing it if the sync succeeded
+func (c *checkpoint) close(f fs.Fs, succeeded bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.out != nil {
+		if err := c.out.Close(); err != nil {
+			return errors.Wrap(err, "failed to close checkpoint")
+		}
+		c.out = nil
+	}
+	if !succeeded {
+		return nil
+	}
+	err := os.Remove(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil {
+		fs.Infof(f, "Sync complete - removed checkpoint %q", c.path)
+	}
+	return err
+}
+
+// getErrorCount returns the number of errors seen so far
+func (s *syncCopyMove) getErrorCount() int64 {
+	s.errorMu.Lock()
+	defer s.errorMu.Unlock()
+	return s.errorCount
+}
+
+// checkpointStartDir records that the subtree at dir is being synced
+func (s *syncCopyMove) checkpointStartDir(dir string) {
+	if s.checkpoint != nil {
+		s.checkpoint.startDir(dir, s.getErrorCount())
+	}
+}
+
+// checkpointAddFile records that src is being synced
+func (s *syncCopyMove) checkpointAddFile(src fs.Object) {
+	if s.checkpoint != nil {
+		s.checkpoint.addFile(parentDir(src.Remote()))
+	}
+}
+
+// checkpointFinishFile records that src has finished syncing
+func (s *syncCopyMove) checkpointFinishFile(src fs.Object) {
+	if s.checkpoint != nil {
+		s.checkpoint.finish(parentDir(src.Remote()), s.getErrorCount())
+	}
+}
+
+// checkpointFail records that the directory remote is in can't be
+// complete in this run
+func (s *syncCopyMove) checkpointFail(remote string) {
+	if s.checkpoint != nil {
+		s.checkpoint.fail(parentDir(remote))
+	}
+}
+
+// DirDone is called by march when all the entries in the source
+// directory dir have been passed to the callbacks
+func (s *syncCopyMove) DirDone(dir string) {
+	if s.checkpoint != nil {
+		s.checkpoint.finish(dir, s.getErrorCount())
+	}
+}
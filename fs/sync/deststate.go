@@ -0,0 +1,219 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// destFileInfo is the state of a destination file at the end of a
+// sync
+type destFileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// destStateFile is the on disk format of the destination state
+type destStateFile struct {
+	Source string                  `json:"source"`
+	Dest   string                  `json:"dest"`
+	Files  map[string]destFileInfo `json:"files"`
+}
+
+// destState tracks the files in the destination for --dest-conflict
+//
+// It is loaded at the start of a sync from the state saved at the end
+// of the previous one and updated with the destination files the sync
+// leaves behind.
+type destState struct {
+	path string
+	file destStateFile
+	mu   sync.Mutex
+	old  map[string]destFileInfo // state from the last sync, nil if none
+	seen map[string]bool         // files recorded or removed this sync
+}
+
+// destStatePath returns the path of the destination state for syncing
+// source to dest
+func destStatePath(source, dest string) string {
+	sum := md5.Sum([]byte(source + "\x00" + dest))
+	return filepath.Join(config.CacheDir, "deststate", hex.EncodeToString(sum[:])+".json")
+}
+
+// loadDestState loads the destination state recorded by the last sync
+// of fsrc to fdst if any
+func loadDestState(fdst, fsrc fs.Fs) (*destState, error) {
+	d := &destState{
+		file: destStateFile{
+			Source: fs.ConfigString(fsrc),
+			Dest:   fs.ConfigString(fdst),
+			Files:  make(map[string]destFileInfo),
+		},
+		seen: make(map[string]bool),
+	}
+	d.path = destStatePath(d.file.Source, d.file.Dest)
+	data, err := ioutil.ReadFile(d.path)
+	if os.IsNotExist(err) {
+		fs.Infof(fdst, "No destination state from a previous sync - not checking for conflicts this time")
+		return d, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read destination state")
+	}
+	var old destStateFile
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse destination state %q", d.path)
+	}
+	d.old = old.Files
+	if d.old == nil {
+		d.old = make(map[string]destFileInfo)
+	}
+	return d, nil
+}
+
+// changed returns true if dst has been created or modified since the
+// last sync
+func (d *destState) changed(ctx context.Context, dst fs.Object) bool {
+	if d.old == nil {
+		return false
+	}
+	info, ok := d.old[dst.Remote()]
+	return !ok || info.Size != dst.Size() || !info.ModTime.Equal(dst.ModTime(ctx))
+}
+
+// record notes that o is in the destination at the end of the sync
+func (d *destState) record(ctx context.Context, o fs.Object) {
+	info := destFileInfo{
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx),
+	}
+	d.mu.Lock()
+	d.file.Files[o.Remote()] = info
+	d.seen[o.Remote()] = true
+	d.mu.Unlock()
+}
+
+// remove notes that remote is no longer in the destination
+func (d *destState) remove(remote string) {
+	d.mu.Lock()
+	delete(d.file.Files, remote)
+	d.seen[remote] = true
+	d.mu.Unlock()
+}
+
+// save writes the destination state atomically
+//
+// Files from the last sync which weren't seen in this one, eg because
+// they were excluded or the sync was interrupted, keep their old
+// state.
+func (d *destState) save() error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	d.mu.Lock()
+	for remote, info := range d.old {
+		if !d.seen[remote] {
+			d.file.Files[remote] = info
+		}
+	}
+	data, err := json.Marshal(&d.file)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(d.path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make destination state directory")
+	}
+	tmp := d.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write destination state")
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// conflictSuffixRe matches the names of files renamed aside by
+// --dest-conflict rename
+var conflictSuffixRe = regexp.MustCompile(`\.conflict-\d{8}-\d{6}$`)
+
+// conflictName returns the name to rename remote aside to
+func conflictName(remote string) string {
+	return remote + ".conflict-" + time.Now().Format("20060102-150405")
+}
+
+// checkOverwrite checks dst, which is about to be overwritten, for a
+// conflict with --dest-conflict.
+//
+// It returns false if dst should be left alone, otherwise the object
+// to overwrite which is nil if dst was renamed aside.
+func (s *syncCopyMove) checkOverwrite(dst fs.Object) (fs.Object, bool) {
+	if s.destState == nil || dst == nil || !s.destState.changed(s.ctx, dst) {
+		return dst, true
+	}
+	if !s.destConflict(dst, "overwritten") {
+		return dst, false
+	}
+	return nil, true
+}
+
+// checkDelete checks dst, which is about to be deleted, for a conflict
+// with --dest-conflict, returning true if it should be deleted.
+func (s *syncCopyMove) checkDelete(dst fs.Object) bool {
+	if s.destState == nil {
+		return true
+	}
+	if conflictSuffixRe.MatchString(dst.Remote()) {
+		fs.Debugf(dst, "Not deleting file renamed aside by --dest-conflict")
+		return false
+	}
+	if !s.destState.changed(s.ctx, dst) {
+		return true
+	}
+	// Whether or not it was renamed aside it mustn't be deleted now
+	_ = s.destConflict(dst, "deleted")
+	return false
+}
+
+// destConflict deals with dst which has changed since the last sync
+// and was about to be overwritten or deleted.
+//
+// It returns true if the caller should carry on, in which case dst
+// has been renamed aside and is no longer at its old name.
+func (s *syncCopyMove) destConflict(dst fs.Object, action string) bool {
+	switch fs.Config.DestConflict {
+	case fs.DestConflictRename:
+		newName := conflictName(dst.Remote())
+		newDst, err := operations.Move(s.ctx, s.fdst, nil, newName, dst)
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(dst, "Changed since the last sync and failed to rename aside: %v", err)
+			s.processError(err)
+			return false
+		}
+		fs.Logf(dst, "Changed since the last sync so renamed to %q instead of being %s", newName, action)
+		if newDst != nil {
+			s.destState.record(s.ctx, newDst)
+		}
+		s.destState.remove(dst.Remote())
+		return true
+	default:
+		err := fs.CountError(fserrors.NoRetryError(fs.ErrorDestConflict))
+		fs.Errorf(dst, "Not %s: %v", action, err)
+		s.processError(err)
+		return false
+	}
+}
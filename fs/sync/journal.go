@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+)
+
+// journalFile is the on disk format of the cutover journal
+type journalFile struct {
+	Source string    `json:"source"`
+	Dest   string    `json:"dest"`
+	Time   time.Time `json:"time"`
+	Files  []string  `json:"files"`
+}
+
+// cutoverJournal records the files which still need transferring
+// when a sync is stopped by --max-transfer or --max-duration so the
+// next run can transfer just those without listing everything again.
+//
+// Only the transfers stop at the cutoff. The listing and checking
+// carry on so everything which still needs transferring is recorded.
+type cutoverJournal struct {
+	path    string
+	file    journalFile
+	mu      sync.Mutex
+	pending []string // files from the last run to transfer this run, nil if none
+	loaded  bool     // set if the journal was read from the last run
+}
+
+// loadJournal reads the journal at path left by the last sync of fsrc
+// to fdst if there is one.
+func loadJournal(path string, fdst, fsrc fs.Fs) (*cutoverJournal, error) {
+	j := &cutoverJournal{
+		path: path,
+		file: journalFile{
+			Source: fs.ConfigString(fsrc),
+			Dest:   fs.ConfigString(fdst),
+		},
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read cutover journal")
+	}
+	var old journalFile
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse cutover journal %q", path)
+	}
+	if old.Source != j.file.Source || old.Dest != j.file.Dest {
+		return nil, errors.Errorf("cutover journal %q is for %s -> %s", path, old.Source, old.Dest)
+	}
+	j.loaded = true
+	j.pending = old.Files
+	if j.pending == nil {
+		j.pending = []string{}
+	}
+	fs.Infof(fdst, "Transferring %d files left from the run at %v from cutover journal", len(j.pending), old.Time)
+	return j, nil
+}
+
+// add records that remote still needs transferring
+func (j *cutoverJournal) add(remote string) {
+	j.mu.Lock()
+	j.file.Files = append(j.file.Files, remote)
+	j.mu.Unlock()
+}
+
+// save writes the journal atomically if there are files still to
+// transfer, otherwise it removes the journal from the last run.
+func (j *cutoverJournal) save(f fs.Fs) error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.file.Files) == 0 {
+		if !j.loaded {
+			return nil
+		}
+		fs.Infof(f, "All files in cutover journal transferred - removing it")
+		err := os.Remove(j.path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	sort.Strings(j.file.Files)
+	j.file.Time = time.Now()
+	data, err := json.MarshalIndent(&j.file, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(j.path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make cutover journal directory")
+	}
+	tmp := j.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write cutover journal")
+	}
+	fs.Logf(f, "Saved %d files still to transfer in cutover journal %q", len(j.file.Files), j.path)
+	return os.Rename(tmp, j.path)
+}
+
+// cutoff returns true if err means the transfers were stopped by
+// --max-transfer or --max-duration
+func (s *syncCopyMove) cutoff(err error) bool {
+	return s.transferCtx.Err() != nil || errors.Cause(err) == accounting.ErrorMaxTransferLimitReached
+}
+
+// runJournal checks the files in the journal from the last run
+// instead of listing the source and destination.
+func (s *syncCopyMove) runJournal() error {
+	var (
+		in         = make(chan string, fs.Config.Checkers)
+		wg         sync.WaitGroup
+		errorMu    sync.Mutex
+		errorCount int
+	)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for remote := range in {
+				err := s.checkJournalFile(s.ctx, remote)
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(remote, "Failed to read file from cutover journal: %v", err)
+					errorMu.Lock()
+					errorCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, remote := range s.journal.pending {
+		if s.aborting() {
+			break
+		}
+		in <- remote
+	}
+	close(in)
+	wg.Wait()
+	if errorCount > 0 {
+		return errors.Errorf("failed to read %d files from cutover journal", errorCount)
+	}
+	return nil
+}
+
+// checkJournalFile finds remote in the source and destination and
+// sends it to the checkers if it is still in the source.
+func (s *syncCopyMove) checkJournalFile(ctx context.Context, remote string) error {
+	src, err := s.fsrc.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		fs.Debugf(remote, "Skipping file from cutover journal no longer in source")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	dst, err := s.fdst.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+	s.toBeChecked.Put(ctx, fs.ObjectPair{Src: src, Dst: dst})
+	return nil
+}
@@ -0,0 +1,299 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// States of a file in the move journal
+const (
+	moveCopying  = "copying"  // copying to the destination has started
+	moveVerified = "verified" // the destination has been checked against the source
+	moveDone     = "done"     // the source has been deleted
+)
+
+// moveJournalHeader is the first line of the move journal
+type moveJournalHeader struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+}
+
+// moveJournalEntry is a line of the move journal recording a file
+// reaching a state
+type moveJournalEntry struct {
+	Time    time.Time `json:"time"`
+	Remote  string    `json:"remote"`
+	State   string    `json:"state"`
+	Existed bool      `json:"existed"` // set if the destination existed before the move
+}
+
+// moveJournal records the progress of a move with --move-journal so
+// an interrupted move can be resumed by running it again or rolled
+// back with RollbackMove.
+//
+// The journal is a file of JSON lines, a header then an entry each
+// time a file changes state. Each line is synced to disk before the
+// operation it records is started, or after it has finished.
+type moveJournal struct {
+	mu  sync.Mutex
+	out *os.File
+}
+
+// openMoveJournal opens the journal at path for appending, checking it
+// is for moving fsrc to fdst if it exists already
+func openMoveJournal(path string, fdst, fsrc fs.Fs) (*moveJournal, error) {
+	header := moveJournalHeader{
+		Source: fs.ConfigString(fsrc),
+		Dest:   fs.ConfigString(fdst),
+	}
+	old, _, err := readMoveJournal(path)
+	newJournal := os.IsNotExist(errors.Cause(err))
+	if err == nil {
+		if old != header {
+			return nil, errors.Errorf("move journal %q is for %s -> %s", path, old.Source, old.Dest)
+		}
+		fs.Infof(fdst, "Resuming move recorded in %q", path)
+	} else if !newJournal {
+		return nil, err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open move journal")
+	}
+	j := &moveJournal{out: out}
+	if newJournal {
+		if err = j.write(header); err != nil {
+			_ = out.Close()
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// readMoveJournal reads the journal at path returning the latest
+// entry for each file
+func readMoveJournal(path string) (header moveJournalHeader, entries map[string]moveJournalEntry, err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return header, nil, errors.Wrap(err, "failed to open move journal")
+	}
+	defer fs.CheckClose(in, &err)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+	if !scanner.Scan() {
+		return header, nil, errors.Errorf("move journal %q is empty", path)
+	}
+	if err = json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return header, nil, errors.Wrapf(err, "failed to parse move journal %q", path)
+	}
+	entries = make(map[string]moveJournalEntry)
+	for scanner.Scan() {
+		var entry moveJournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A partly written last line if interrupted
+			fs.Debugf(nil, "Ignoring bad line in move journal: %v", err)
+			continue
+		}
+		// Keep whether the destination existed from the first
+		// entry as a resumed move finds its own earlier copy
+		if old, ok := entries[entry.Remote]; ok {
+			entry.Existed = old.Existed
+		}
+		entries[entry.Remote] = entry
+	}
+	return header, entries, scanner.Err()
+}
+
+// write appends v as a line of JSON and syncs it to disk
+func (j *moveJournal) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.out.Write(append(data, '\n'))
+	if err == nil {
+		err = j.out.Sync()
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write move journal")
+	}
+	return nil
+}
+
+// record notes that remote has reached state
+func (j *moveJournal) record(remote, state string, existed bool) error {
+	return j.write(moveJournalEntry{
+		Time:    time.Now(),
+		Remote:  remote,
+		State:   state,
+		Existed: existed,
+	})
+}
+
+// Close closes the journal
+func (j *moveJournal) Close() error {
+	return j.out.Close()
+}
+
+// verifyCopy checks dst is a complete copy of src by size and hash, or
+// by reading both if they don't have a hash in common.
+func verifyCopy(ctx context.Context, src, dst fs.Object) error {
+	if src.Size() >= 0 && dst.Size() >= 0 && src.Size() != dst.Size() {
+		return errors.Errorf("verify failed: size %d should be %d", dst.Size(), src.Size())
+	}
+	equal, ht, err := operations.CheckHashes(ctx, src, dst)
+	if err != nil {
+		return errors.Wrap(err, "verify failed")
+	}
+	if ht == hash.None {
+		var differ bool
+		differ, err = operations.CheckIdenticalDownload(ctx, dst, src)
+		if err != nil {
+			return errors.Wrap(err, "verify failed")
+		}
+		equal = !differ
+	}
+	if !equal {
+		return errors.New("verify failed: contents differ")
+	}
+	return nil
+}
+
+// journaledMove moves src to remote in fdst by copying it, verifying
+// the copy then deleting src, recording each step in the journal.
+func (s *syncCopyMove) journaledMove(ctx context.Context, fdst fs.Fs, dst, src fs.Object) (newDst fs.Object, err error) {
+	existed := dst != nil
+	if err = s.moveJournal.record(src.Remote(), moveCopying, existed); err != nil {
+		return nil, err
+	}
+	newDst, err = operations.Copy(ctx, fdst, dst, src.Remote(), src)
+	if err != nil || newDst == nil {
+		return newDst, err
+	}
+	return newDst, s.journaledDeleteSrc(ctx, src, newDst, existed)
+}
+
+// journaledDeleteSrc verifies dst is a copy of src then deletes src,
+// recording each step in the journal.
+func (s *syncCopyMove) journaledDeleteSrc(ctx context.Context, src, dst fs.Object, existed bool) error {
+	if operations.SkipDestructive(ctx, src, "verify and delete") {
+		return nil
+	}
+	err := verifyCopy(ctx, src, dst)
+	if err != nil {
+		fs.Errorf(dst, "Not deleting source: %v", err)
+		return fs.CountError(err)
+	}
+	if err = s.moveJournal.record(src.Remote(), moveVerified, existed); err != nil {
+		return err
+	}
+	if err = operations.DeleteFile(ctx, src); err != nil {
+		return err
+	}
+	return s.moveJournal.record(src.Remote(), moveDone, existed)
+}
+
+// RollbackMove undoes the move of fsrc to fdst recorded in the journal
+// at path by --move-journal.
+//
+// Files which were moved are copied back to fsrc and files which were
+// copied to fdst but whose source hasn't been deleted yet are removed
+// from fdst. Files in fdst which existed before the move are left
+// there. The journal is removed if the roll back succeeds.
+func RollbackMove(ctx context.Context, fdst, fsrc fs.Fs, path string) error {
+	header, entries, err := readMoveJournal(path)
+	if err != nil {
+		return err
+	}
+	if header.Source != fs.ConfigString(fsrc) || header.Dest != fs.ConfigString(fdst) {
+		return errors.Errorf("move journal %q is for %s -> %s", path, header.Source, header.Dest)
+	}
+	var (
+		in         = make(chan moveJournalEntry, fs.Config.Transfers)
+		wg         sync.WaitGroup
+		errorMu    sync.Mutex
+		errorCount int
+	)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for entry := range in {
+				err := rollbackFile(ctx, fdst, fsrc, entry)
+				if err != nil {
+					fs.Errorf(entry.Remote, "Failed to roll back: %v", err)
+					errorMu.Lock()
+					errorCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, entry := range entries {
+		in <- entry
+	}
+	close(in)
+	wg.Wait()
+	if errorCount > 0 {
+		return errors.Errorf("failed to roll back %d files", errorCount)
+	}
+	if fs.Config.DryRun {
+		return nil
+	}
+	fs.Infof(fdst, "Roll back complete - removing move journal")
+	return os.Remove(path)
+}
+
+// rollbackFile undoes the move of a single file
+func rollbackFile(ctx context.Context, fdst, fsrc fs.Fs, entry moveJournalEntry) error {
+	dst, err := fdst.NewObject(ctx, entry.Remote)
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+	srcDeleted := entry.State == moveDone
+	if entry.State == moveVerified {
+		// The source may have been deleted before the move was
+		// interrupted and the done state recorded
+		_, err = fsrc.NewObject(ctx, entry.Remote)
+		if err == fs.ErrorObjectNotFound {
+			srcDeleted = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if srcDeleted {
+		// Put the source back
+		if dst == nil {
+			return errors.New("source was deleted and destination is missing")
+		}
+		var src fs.Object
+		src, err = operations.Copy(ctx, fsrc, nil, entry.Remote, dst)
+		if err != nil {
+			return err
+		}
+		if src != nil {
+			if err = verifyCopy(ctx, dst, src); err != nil {
+				return err
+			}
+		}
+	} else if entry.Existed && entry.State == moveCopying {
+		fs.Logf(entry.Remote, "Can't roll back destination which existed before the move and may have been overwritten")
+	}
+	if entry.Existed || dst == nil {
+		return nil
+	}
+	return operations.DeleteFile(ctx, dst)
+}
@@ -3,6 +3,7 @@ package sync
 import (
 	"context"
 	"math/bits"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/aalpar/deheap"
 	"github.com/pkg/errors"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
 )
 
@@ -178,6 +180,79 @@ func (p *pipe) Close() {
 	p.mu.Unlock()
 }
 
+// splitOrderBy splits orderBy on the commas which aren't inside {} or
+// [] so that the glob and regexp classes may contain commas.
+func splitOrderBy(orderBy string) (parts []string) {
+	var (
+		start     = 0
+		braces    = 0
+		inBracket = false
+	)
+	for i := 0; i < len(orderBy); i++ {
+		switch c := orderBy[i]; {
+		case c == '\\':
+			i++ // skip the escaped character
+		case inBracket:
+			inBracket = c != ']'
+		case c == '[':
+			inBracket = true
+		case c == '{':
+			braces++
+		case c == '}' && braces > 0:
+			braces--
+		case c == ',' && braces == 0:
+			parts = append(parts, orderBy[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, orderBy[start:])
+}
+
+// parseClasses parses the priority classes off the start of parts
+// returning them and the remaining parts
+func parseClasses(parts []string) (classes []*regexp.Regexp, rest []string, err error) {
+	for len(parts) > 0 {
+		var re *regexp.Regexp
+		part := parts[0]
+		switch {
+		case strings.HasPrefix(strings.ToLower(part), "glob="):
+			re, err = filter.GlobToRegexp(part[5:], filter.Active.Opt.IgnoreCase)
+		case strings.HasPrefix(strings.ToLower(part), "regexp="):
+			re, err = regexp.Compile(part[7:])
+		default:
+			return classes, parts, nil
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "bad --order-by class %q", part)
+		}
+		classes = append(classes, re)
+		parts = parts[1:]
+	}
+	return classes, parts, nil
+}
+
+// classLess wraps less so that pairs are ordered by the first of the
+// classes they match, with pairs matching none of them last, and then
+// by less within a class.
+func classLess(classes []*regexp.Regexp, less lessFn) lessFn {
+	class := func(pair fs.ObjectPair) int {
+		remote := pair.Src.Remote()
+		for i, re := range classes {
+			if re.MatchString(remote) {
+				return i
+			}
+		}
+		return len(classes)
+	}
+	return func(a, b fs.ObjectPair) bool {
+		classA, classB := class(a), class(b)
+		if classA != classB {
+			return classA < classB
+		}
+		return less != nil && less(a, b)
+	}
+}
+
 // newLess returns a less function for the heap comparison or nil if
 // one is not required
 func newLess(orderBy string) (less lessFn, fraction int, err error) {
@@ -185,7 +260,16 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 	if orderBy == "" {
 		return nil, fraction, nil
 	}
-	parts := strings.Split(strings.ToLower(orderBy), ",")
+	classes, parts, err := parseClasses(splitOrderBy(orderBy))
+	if err != nil {
+		return nil, fraction, err
+	}
+	if len(parts) == 0 {
+		return classLess(classes, nil), fraction, nil
+	}
+	for i := range parts {
+		parts[i] = strings.ToLower(parts[i])
+	}
 	switch parts[0] {
 	case "name":
 		less = func(a, b fs.ObjectPair) bool {
@@ -231,5 +315,11 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 			return !oldLess(a, b)
 		}
 	}
+	if len(classes) > 0 {
+		if fraction >= 0 {
+			return nil, fraction, errors.New("can't use mixed with --order-by classes")
+		}
+		less = classLess(classes, less)
+	}
 	return less, fraction, nil
 }
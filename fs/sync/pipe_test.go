@@ -276,3 +276,81 @@ func TestNewLess(t *testing.T) {
 	}
 
 }
+
+func TestSplitOrderBy(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"size", []string{"size"}},
+		{"size,desc", []string{"size", "desc"}},
+		{"glob=*.{db,sqlite},size,desc", []string{"glob=*.{db,sqlite}", "size", "desc"}},
+		{"regexp=a{1,3},name", []string{"regexp=a{1,3}", "name"}},
+		{"regexp=[,}],name", []string{"regexp=[,}]", "name"}},
+		{"glob=\\{a,b", []string{"glob=\\{a", "b"}},
+		{"glob={a,{b,c}},glob=d", []string{"glob={a,{b,c}}", "glob=d"}},
+		{",", []string{"", ""}},
+	} {
+		assert.Equal(t, test.want, splitOrderBy(test.in), test.in)
+	}
+}
+
+func TestNewLessClasses(t *testing.T) {
+	t.Run("badGlob", func(t *testing.T) {
+		_, _, err := newLess("glob=***")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bad --order-by class")
+	})
+
+	t.Run("badRegexp", func(t *testing.T) {
+		_, _, err := newLess("regexp=(")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bad --order-by class")
+	})
+
+	t.Run("mixed", func(t *testing.T) {
+		_, _, err := newLess("glob=*.db,size,mixed")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't use mixed")
+	})
+
+	var (
+		small = func(remote string) fs.ObjectPair {
+			return fs.ObjectPair{Src: mockobject.New(remote).WithContent([]byte("1"), mockobject.SeekModeNone)}
+		}
+		big = func(remote string) fs.ObjectPair {
+			return fs.ObjectPair{Src: mockobject.New(remote).WithContent([]byte("22"), mockobject.SeekModeNone)}
+		}
+	)
+
+	for _, test := range []struct {
+		orderBy string
+		a, b    fs.ObjectPair
+		want    bool // a < b
+	}{
+		{"glob=*.db", small("z.db"), small("a.jpg"), true},
+		{"glob=*.db", small("a.jpg"), small("z.db"), false},
+		{"glob=*.db", small("a.jpg"), small("b.txt"), false},
+		{"glob=*.db,glob=*.jpg", small("a.jpg"), small("b.txt"), true},
+		{"glob=*.db,glob=*.jpg", small("a.jpg"), small("b.db"), false},
+		{"glob=/dir/**,name", small("dir/b"), small("a"), true},
+		{"glob=/dir/**,name", small("dir/b"), small("dir/a"), false},
+		{"glob=/dir/**,name", small("dir/a"), small("dir/b"), true},
+		{"regexp=\\.(db|sqlite)$,size,desc", small("a.sqlite"), big("b.db"), false},
+		{"regexp=\\.(db|sqlite)$,size,desc", big("a.sqlite"), small("b.db"), true},
+		{"regexp=\\.(db|sqlite)$,size,desc", small("a.sqlite"), big("b.txt"), true},
+		{"GLOB=*.DB", small("a.DB"), small("b.db"), true},
+		{"glob=*.{db,sqlite}", small("a.sqlite"), small("b.txt"), true},
+		{"glob=*.{db,sqlite},size,desc", small("a.db"), big("b.sqlite"), false},
+		{"glob=*.{db,sqlite},size,desc", big("a.db"), small("b.sqlite"), true},
+		{"regexp=^a{1,3}$", small("aaa"), small("aaaa"), true},
+		{"regexp=^a{1,3}$,name", small("b"), small("aa"), false},
+	} {
+		t.Run(test.orderBy, func(t *testing.T) {
+			less, fraction, err := newLess(test.orderBy)
+			require.NoError(t, err)
+			assert.Equal(t, -1, fraction)
+			assert.Equal(t, test.want, less(test.a, test.b), "%s < %s", test.a.Src, test.b.Src)
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Plan actions
+const (
+	PlanCopy   = "copy"   // copy the source file to the destination
+	PlanDelete = "delete" // delete the destination file
+	PlanMkdir  = "mkdir"  // make the destination directory
+	PlanRmdir  = "rmdir"  // remove the destination directory if empty
+)
+
+// PlanObject is the state of a file when the plan was made
+type PlanObject struct {
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"modTime"`
+	Hashes  map[string]string `json:"hashes,omitempty"`
+}
+
+// PlanAction is a single operation in a Plan
+type PlanAction struct {
+	Action string      `json:"action"`        // one of the Plan actions
+	Remote string      `json:"remote"`        // path relative to the source and destination roots
+	Src    *PlanObject `json:"src,omitempty"` // source file for copy
+	Dst    *PlanObject `json:"dst,omitempty"` // destination file replaced or deleted, nil if none
+}
+
+// Plan is a record of the operations a sync would do made by MakePlan
+// which can be carried out later with ApplyPlan.
+type Plan struct {
+	Source  string       `json:"source"`
+	Dest    string       `json:"dest"`
+	Time    time.Time    `json:"time"`
+	Actions []PlanAction `json:"actions"`
+
+	mu sync.Mutex
+}
+
+// add records an action in the plan
+func (p *Plan) add(action PlanAction) {
+	fs.Logf(action.Remote, "Planned %s", action.Action)
+	p.mu.Lock()
+	p.Actions = append(p.Actions, action)
+	p.mu.Unlock()
+}
+
+// addDirs records action for each of the directories in entries
+func (p *Plan) addDirs(action string, entries map[string]fs.DirEntry) {
+	for remote, entry := range entries {
+		if _, ok := entry.(fs.Directory); ok {
+			p.add(PlanAction{Action: action, Remote: remote})
+		}
+	}
+}
+
+// planObject returns the state of o to store in the plan, with its
+// hash of type ht unless that is hash.None
+func planObject(ctx context.Context, o fs.Object, ht hash.Type) (*PlanObject, error) {
+	if o == nil {
+		return nil, nil
+	}
+	po := &PlanObject{
+		Size:    o.Size(),
+		ModTime: o.ModTime(ctx),
+	}
+	if ht != hash.None {
+		sum, err := o.Hash(ctx, ht)
+		if err != nil {
+			return nil, err
+		}
+		if sum != "" {
+			po.Hashes = map[string]string{ht.String(): sum}
+		}
+	}
+	return po, nil
+}
+
+// planHash returns the hash type to record in the plan
+func (s *syncCopyMove) planHash() hash.Type {
+	if fs.Config.CheckSum {
+		return s.commonHash
+	}
+	return hash.None
+}
+
+// planCopy records that src will be copied over dst
+func (s *syncCopyMove) planCopy(src, dst fs.Object) error {
+	srcState, err := planObject(s.ctx, src, s.planHash())
+	if err != nil {
+		return err
+	}
+	dstState, err := planObject(s.ctx, dst, s.planHash())
+	if err != nil {
+		return err
+	}
+	s.plan.add(PlanAction{Action: PlanCopy, Remote: src.Remote(), Src: srcState, Dst: dstState})
+	return nil
+}
+
+// planDeletes records the deletion of the files in toDelete
+func (s *syncCopyMove) planDeletes(toDelete fs.ObjectsChan) error {
+	var err error
+	for dst := range toDelete {
+		var dstState *PlanObject
+		dstState, err = planObject(s.ctx, dst, hash.None)
+		if err != nil {
+			break
+		}
+		s.plan.add(PlanAction{Action: PlanDelete, Remote: dst.Remote(), Dst: dstState})
+	}
+	// drain the channel so the sender can finish
+	for range toDelete {
+	}
+	return err
+}
+
+// MakePlan works out what syncing fsrc to fdst would do without doing
+// it. If deleteMode is fs.DeleteModeOff then the plan is for a copy.
+//
+// The plan can be reviewed then carried out with ApplyPlan.
+func MakePlan(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, copyEmptySrcDirs bool) (*Plan, error) {
+	switch {
+	case fs.Config.BackupDir != "" || fs.Config.Suffix != "":
+		return nil, errors.New("can't make a plan with --backup-dir or --suffix")
+	case fs.Config.CompareDest != "" || fs.Config.CopyDest != "":
+		return nil, errors.New("can't make a plan with --compare-dest or --copy-dest")
+	case fs.Config.TrackRenames:
+		return nil, errors.New("can't make a plan with --track-renames")
+	case fs.Config.CutoverJournal != "":
+		return nil, errors.New("can't make a plan with --cutover-journal")
+	case fs.Config.Checkpoint != "":
+		return nil, errors.New("can't make a plan with --checkpoint")
+	}
+	if deleteMode != fs.DeleteModeOff {
+		// All the deletes are done after the copies when applied
+		deleteMode = fs.DeleteModeAfter
+	}
+	// Making the plan mustn't change the destination
+	ctx = operations.NoUpdateModTime(ctx)
+	s, err := newSyncCopyMove(ctx, fdst, fsrc, deleteMode, false, false, copyEmptySrcDirs)
+	if err != nil {
+		return nil, err
+	}
+	s.plan = &Plan{
+		Source: fs.ConfigString(fsrc),
+		Dest:   fs.ConfigString(fdst),
+		Time:   time.Now(),
+	}
+	s.verify = false
+	s.destState = nil
+	err = s.run()
+	if err != nil {
+		return nil, err
+	}
+	order := map[string]int{PlanMkdir: 0, PlanCopy: 1, PlanDelete: 2, PlanRmdir: 3}
+	sort.Slice(s.plan.Actions, func(i, j int) bool {
+		a, b := &s.plan.Actions[i], &s.plan.Actions[j]
+		if a.Action != b.Action {
+			return order[a.Action] < order[b.Action]
+		}
+		return a.Remote < b.Remote
+	})
+	return s.plan, nil
+}
+
+// Save writes the plan to path
+func (p *Plan) Save(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write plan")
+	}
+	return nil
+}
+
+// LoadPlan reads a plan saved with Save from path
+func LoadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plan")
+	}
+	p := new(Plan)
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse plan %q", path)
+	}
+	for _, action := range p.Actions {
+		switch action.Action {
+		case PlanCopy:
+			if action.Src == nil {
+				return nil, errors.Errorf("plan %q: copy of %q has no source", path, action.Remote)
+			}
+		case PlanDelete:
+			if action.Dst == nil {
+				return nil, errors.Errorf("plan %q: delete of %q has no destination", path, action.Remote)
+			}
+		case PlanMkdir, PlanRmdir:
+		default:
+			return nil, errors.Errorf("plan %q: unknown action %q", path, action.Action)
+		}
+	}
+	return p, nil
+}
+
+// planChanged returns an error if o isn't in the state it was in when
+// the plan was made. want is nil if o shouldn't exist.
+func planChanged(ctx context.Context, o fs.Object, want *PlanObject, modifyWindow time.Duration) error {
+	if want == nil {
+		if o != nil {
+			return errors.New("file has been created")
+		}
+		return nil
+	}
+	if o == nil {
+		return errors.New("file has been removed")
+	}
+	if o.Size() != want.Size {
+		return errors.Errorf("size changed from %d to %d", want.Size, o.Size())
+	}
+	if modifyWindow != fs.ModTimeNotSupported {
+		modTime := o.ModTime(ctx)
+		if dt := modTime.Sub(want.ModTime); dt >= modifyWindow || dt <= -modifyWindow {
+			return errors.Errorf("modification time changed from %v to %v", want.ModTime, modTime)
+		}
+	}
+	for name, want := range want.Hashes {
+		var ht hash.Type
+		if err := ht.Set(name); err != nil {
+			return err
+		}
+		sum, err := o.Hash(ctx, ht)
+		if err != nil {
+			return err
+		}
+		if sum != want {
+			return errors.Errorf("%v changed from %s to %s", ht, want, sum)
+		}
+	}
+	return nil
+}
+
+// findObject returns the object at remote in f or nil if it doesn't
+// exist
+func findObject(ctx context.Context, f fs.Fs, remote string) (fs.Object, error) {
+	o, err := f.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound {
+		return nil, nil
+	}
+	return o, err
+}
+
+// ApplyPlan carries out the plan p made by MakePlan for syncing fsrc
+// to fdst.
+//
+// Every file in the plan is checked first and if any have changed
+// since the plan was made then nothing is done and an error is
+// returned. Otherwise the files are copied, then the files deleted,
+// then empty directories removed.
+func ApplyPlan(ctx context.Context, fdst, fsrc fs.Fs, p *Plan) error {
+	if source, dest := fs.ConfigString(fsrc), fs.ConfigString(fdst); p.Source != source || p.Dest != dest {
+		return errors.Errorf("plan is for %s -> %s not %s -> %s", p.Source, p.Dest, source, dest)
+	}
+	modifyWindow := fs.GetModifyWindow(fsrc, fdst)
+
+	// Check every file is as it was when the plan was made
+	var (
+		pairs       = make([]fs.ObjectPair, len(p.Actions))
+		in          = make(chan int, fs.Config.Checkers)
+		wg          sync.WaitGroup
+		errorMu     sync.Mutex
+		changeCount int
+	)
+	wg.Add(fs.Config.Checkers)
+	for i := 0; i < fs.Config.Checkers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range in {
+				action := &p.Actions[i]
+				err := func() (err error) {
+					switch action.Action {
+					case PlanCopy:
+						pairs[i].Src, err = findObject(ctx, fsrc, action.Remote)
+						if err != nil {
+							return err
+						}
+						if err = planChanged(ctx, pairs[i].Src, action.Src, modifyWindow); err != nil {
+							return errors.Wrap(err, "source")
+						}
+						fallthrough
+					case PlanDelete:
+						pairs[i].Dst, err = findObject(ctx, fdst, action.Remote)
+						if err != nil {
+							return err
+						}
+						if err = planChanged(ctx, pairs[i].Dst, action.Dst, modifyWindow); err != nil {
+							return errors.Wrap(err, "destination")
+						}
+					}
+					return nil
+				}()
+				if err != nil {
+					fs.Errorf(action.Remote, "Changed since the plan was made: %v", err)
+					errorMu.Lock()
+					changeCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range p.Actions {
+		in <- i
+	}
+	close(in)
+	wg.Wait()
+	if changeCount > 0 {
+		return fs.CountError(errors.Errorf("%d files have changed since the plan was made - not applying it", changeCount))
+	}
+
+	// Do the copies
+	var (
+		copies     = make(chan fs.ObjectPair, fs.Config.Transfers)
+		mkdirs     = make(map[string]fs.DirEntry)
+		rmdirs     = make(map[string]fs.DirEntry)
+		toDelete   []fs.Object
+		errorCount int
+	)
+	wg.Add(fs.Config.Transfers)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for pair := range copies {
+				_, err := operations.Copy(ctx, fdst, pair.Dst, pair.Src.Remote(), pair.Src)
+				if err != nil {
+					errorMu.Lock()
+					errorCount++
+					errorMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i, action := range p.Actions {
+		switch action.Action {
+		case PlanCopy:
+			copies <- pairs[i]
+		case PlanDelete:
+			toDelete = append(toDelete, pairs[i].Dst)
+		case PlanMkdir:
+			mkdirs[action.Remote] = fs.NewDir(action.Remote, time.Time{})
+		case PlanRmdir:
+			rmdirs[action.Remote] = fs.NewDir(action.Remote, time.Time{})
+		}
+	}
+	close(copies)
+	wg.Wait()
+	err := copyEmptyDirectories(ctx, fdst, mkdirs)
+	if err != nil {
+		return err
+	}
+	if errorCount > 0 {
+		if len(toDelete) > 0 || len(rmdirs) > 0 {
+			fs.Errorf(fdst, "%v", fs.ErrorNotDeleting)
+		}
+		// Retrying can't help as the destination no longer matches
+		// the checks recorded in the plan
+		return fserrors.NoRetryError(errors.Errorf("failed to copy %d files", errorCount))
+	}
+
+	// Then the deletes
+	if len(toDelete) > 0 {
+		objs := make(fs.ObjectsChan, len(toDelete))
+		for _, o := range toDelete {
+			objs <- o
+		}
+		close(objs)
+		err = operations.DeleteFiles(ctx, objs)
+		if err != nil {
+			return err
+		}
+	}
+	return deleteEmptyDirectories(ctx, fdst, rmdirs)
+}
@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanApply(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	same := r.WriteBoth(ctx, "same", "same", t1)
+	newFile := r.WriteFile("new", "new file", t1)
+	changedSrc := r.WriteFile("changed", "changed in source", t2)
+	changedDst := r.WriteObject(ctx, "changed", "old version", t1)
+	extra := r.WriteObject(ctx, "dir/extra", "only in destination", t1)
+	touchedSrc := r.WriteFile("touched", "same contents", t2)
+	touchedDst := r.WriteObject(ctx, "touched", "same contents", t1)
+	fstest.CheckItems(t, r.Flocal, same, newFile, changedSrc, touchedSrc)
+	fstest.CheckItems(t, r.Fremote, same, changedDst, extra, touchedDst)
+
+	accounting.GlobalStats().ResetCounters()
+	plan, err := MakePlan(ctx, r.Fremote, r.Flocal, fs.DeleteModeDefault, false)
+	require.NoError(t, err)
+
+	// Nothing should have changed, not even the modification time
+	// of touched
+	fstest.CheckItems(t, r.Fremote, same, changedDst, extra, touchedDst)
+
+	var got []string
+	for _, action := range plan.Actions {
+		got = append(got, action.Action+" "+action.Remote)
+	}
+	assert.Equal(t, []string{"copy changed", "copy new", "delete dir/extra", "rmdir dir"}, got)
+	assert.Equal(t, int64(len("old version")), plan.Actions[0].Dst.Size)
+	assert.Nil(t, plan.Actions[1].Dst)
+
+	// Round trip the plan through a file
+	dir, err := ioutil.TempDir("", "rclone-plan")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "plan.json")
+	require.NoError(t, plan.Save(path))
+	plan, err = LoadPlan(path)
+	require.NoError(t, err)
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, ApplyPlan(ctx, r.Fremote, r.Flocal, plan))
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{same, newFile, changedSrc, touchedDst}, []string{}, fs.GetModifyWindow(r.Fremote))
+}
+
+func TestPlanApplyChanged(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteFile("file1", "file1", t1)
+	file2 := r.WriteFile("file2", "file2", t1)
+	fstest.CheckItems(t, r.Fremote)
+
+	accounting.GlobalStats().ResetCounters()
+	plan, err := MakePlan(ctx, r.Fremote, r.Flocal, fs.DeleteModeDefault, false)
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 2)
+
+	// Someone else creates file2 in the destination
+	file2dst := r.WriteObject(ctx, "file2", "file2 from elsewhere", t2)
+
+	accounting.GlobalStats().ResetCounters()
+	err = ApplyPlan(ctx, r.Fremote, r.Flocal, plan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 files have changed")
+	accounting.GlobalStats().ResetCounters()
+
+	// Nothing should have been copied
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote, file2dst)
+}
+
+func TestPlanApplyCopyFailed(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	r.WriteFile("file1", "file1", t1)
+	r.WriteFile("file2", "file2", t1)
+	gone := r.WriteObject(ctx, "gone", "only in destination", t1)
+
+	accounting.GlobalStats().ResetCounters()
+	plan, err := MakePlan(ctx, r.Fremote, r.Flocal, fs.DeleteModeDefault, false)
+	require.NoError(t, err)
+	require.Len(t, plan.Actions, 3)
+
+	// Reaching --max-transfer makes both copies fail
+	oldMaxTransfer, oldTransfers := fs.Config.MaxTransfer, fs.Config.Transfers
+	defer func() {
+		fs.Config.MaxTransfer, fs.Config.Transfers = oldMaxTransfer, oldTransfers
+	}()
+	fs.Config.MaxTransfer = 1
+	fs.Config.Transfers = 1
+
+	accounting.GlobalStats().ResetCounters()
+	err = ApplyPlan(ctx, r.Fremote, r.Flocal, plan)
+	accounting.GlobalStats().ResetCounters()
+	require.Error(t, err)
+	assert.Equal(t, "failed to copy 2 files", err.Error())
+	assert.True(t, fserrors.IsNoRetryError(err))
+
+	// Nothing is deleted after the copies failed
+	fstest.CheckItems(t, r.Fremote, gone)
+}
@@ -18,8 +18,10 @@ import (
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/hashdb"
 	"github.com/rclone/rclone/fs/march"
 	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 type syncCopyMove struct {
@@ -34,6 +36,13 @@ type syncCopyMove struct {
 	// internal state
 	ctx                    context.Context        // internal context for controlling go-routines
 	cancel                 func()                 // cancel the context
+	transferCtx            context.Context        // context for the transfers, stopped at the cutoff
+	transferCancel         func()                 // cancel the transfer context
+	tuner                  *sched.Tuner           // tuner of the transfers and checks with --auto-tune, may be nil
+	journal                *cutoverJournal        // files to transfer next time if cut off, nil if not in use
+	plan                   *Plan                  // if set record the operations here instead of doing them
+	moveJournal            *moveJournal           // journal of the files moved with --move-journal, nil if not in use
+	checkpoint             *checkpoint            // directories completed with --checkpoint, nil if not in use
 	noTraverse             bool                   // if set don't traverse the dst
 	noCheckDest            bool                   // if set transfer all objects regardless without checking dst
 	noUnicodeNormalization bool                   // don't normalize unicode characters in filenames
@@ -59,7 +68,10 @@ type syncCopyMove struct {
 	err                    error                  // normal error from copy process
 	noRetryErr             error                  // error with NoRetry set
 	fatalErr               error                  // fatal error
+	errorCount             int64                  // number of errors seen by processError
 	commonHash             hash.Type              // common hash type between src and dst
+	hashDB                 *hashdb.DB             // hashes of dst files recorded from the src if no common hash
+	dbHash                 hash.Type              // hash type recorded in hashDB
 	modifyWindow           time.Duration          // modify window between fsrc, fdst
 	renameMapMu            sync.Mutex             // mutex to protect the below
 	renameMap              map[string][]fs.Object // dst files by hash - only used by trackRenames
@@ -71,6 +83,10 @@ type syncCopyMove struct {
 	compareCopyDest        fs.Fs                  // place to check for files to server side copy
 	backupDir              fs.Fs                  // place to store overwrites/deletes
 	checkFirst             bool                   // if set run all the checkers before starting transfers
+	destState              *destState             // state of fdst for --dest-conflict, nil if not in use
+	verify                 bool                   // set if checking files again after the sync
+	verifyMu               sync.Mutex             // protect toVerify
+	toVerify               []fs.ObjectPair        // files to check for --verify
 
 	srcOnlyDirsMu sync.Mutex             // protect srcOnlyDirs
 	srcOnlyDirs   map[string]fs.DirEntry // src only dirs
@@ -141,13 +157,29 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	if err != nil {
 		return nil, err
 	}
+	// Tune the transfers and checks on the destination if required
+	s.tuner = sched.TunerFor(fdst)
+	ctx = sched.WithTuner(ctx, s.tuner)
 	// If a max session duration has been defined add a deadline to the context
+	useJournal := fs.Config.CutoverJournal != "" && deleteMode != fs.DeleteModeOnly
 	if fs.Config.MaxDuration > 0 {
 		endTime := time.Now().Add(fs.Config.MaxDuration)
 		fs.Infof(s.fdst, "Transfer session deadline: %s", endTime.Format("2006/01/02 15:04:05"))
-		s.ctx, s.cancel = context.WithDeadline(ctx, endTime)
+		if useJournal {
+			// Only stop the transfers so the remaining work can be journaled
+			s.ctx, s.cancel = context.WithCancel(ctx)
+			s.transferCtx, s.transferCancel = context.WithDeadline(s.ctx, endTime)
+		} else {
+			s.ctx, s.cancel = context.WithDeadline(ctx, endTime)
+		}
 	} else {
 		s.ctx, s.cancel = context.WithCancel(ctx)
+		if useJournal {
+			s.transferCtx, s.transferCancel = context.WithCancel(s.ctx)
+		}
+	}
+	if s.transferCtx == nil {
+		s.transferCtx, s.transferCancel = s.ctx, s.cancel
 	}
 	if s.noTraverse && s.deleteMode != fs.DeleteModeOff {
 		fs.Errorf(nil, "Ignoring --no-traverse with sync")
@@ -175,8 +207,12 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 			s.trackRenames = false
 		}
 		if s.trackRenamesStrategy.hash() && s.commonHash == hash.None {
-			fs.Errorf(fdst, "Ignoring --track-renames as the source and destination do not have a common hash")
-			s.trackRenames = false
+			if fs.Config.TrackRenamesDB && fsrc.Hashes().GetOne() != hash.None {
+				s.dbHash = fsrc.Hashes().GetOne()
+			} else {
+				fs.Errorf(fdst, "Ignoring --track-renames as the source and destination do not have a common hash")
+				s.trackRenames = false
+			}
 		}
 
 		if s.trackRenamesStrategy.modTime() && s.modifyWindow == fs.ModTimeNotSupported {
@@ -220,6 +256,63 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 			return nil, err
 		}
 	}
+	if fs.Config.Verify {
+		if s.DoMove {
+			fs.Errorf(fdst, "Ignoring --verify with move as the source files are removed")
+		} else {
+			s.verify = true
+		}
+	}
+	if fs.Config.DestConflict != fs.DestConflictOff {
+		s.destState, err = loadDestState(fdst, fsrc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if useJournal {
+		s.journal, err = loadJournal(fs.Config.CutoverJournal, fdst, fsrc)
+		if err != nil {
+			return nil, err
+		}
+		if s.journal.pending != nil {
+			if s.deleteMode != fs.DeleteModeOff {
+				fs.Logf(fdst, "Not deleting files until the cutover journal is finished")
+				s.deleteMode = fs.DeleteModeOff
+			}
+			s.trackRenames = false
+			s.copyEmptySrcDirs = false
+		}
+	}
+	if fs.Config.Checkpoint != "" && deleteMode != fs.DeleteModeOnly {
+		switch {
+		case s.trackRenames:
+			return nil, errors.New("can't use --checkpoint with --track-renames")
+		case s.journal != nil:
+			return nil, errors.New("can't use --checkpoint with --cutover-journal")
+		}
+		s.checkpoint, err = loadCheckpoint(fs.Config.Checkpoint, fdst, fsrc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Open the hash database and move journal last so they don't need
+	// closing on error
+	if s.trackRenames && s.dbHash != hash.None {
+		fs.Infof(fdst, "Using hash database to track renames by %v hash", s.dbHash)
+		s.hashDB, err = hashdb.Open(hashdb.DefaultPath())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s.DoMove && fs.Config.MoveJournal != "" {
+		s.moveJournal, err = openMoveJournal(fs.Config.MoveJournal, fdst, fsrc)
+		if err != nil {
+			if s.hashDB != nil {
+				_ = s.hashDB.Close()
+			}
+			return nil, err
+		}
+	}
 	return s, nil
 }
 
@@ -251,14 +344,19 @@ func (s *syncCopyMove) processError(err error) {
 	if err == nil {
 		return
 	}
+	s.tuner.Error()
 	if err == context.DeadlineExceeded {
 		err = fserrors.NoRetryError(err)
 	}
 	s.errorMu.Lock()
 	defer s.errorMu.Unlock()
+	s.errorCount++
 	switch {
 	case fserrors.IsFatalError(err):
-		if !s.aborting() {
+		if s.journal != nil && errors.Cause(err) == accounting.ErrorMaxTransferLimitReached {
+			// Stop the transfers but carry on checking to fill the journal
+			s.transferCancel()
+		} else if !s.aborting() {
 			fs.Errorf(nil, "Cancelling sync due to fatal error: %v", err)
 			s.cancel()
 		}
@@ -297,7 +395,12 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 			return
 		}
 		src := pair.Src
-		var err error
+		release, err := sched.AcquireCheck(s.ctx)
+		if err != nil {
+			// cancelled while waiting
+			return
+		}
+		transferring := false // set if passed to the transfers
 		tr := accounting.Stats(s.ctx).NewCheckingTransfer(src)
 		// Check to see if can store this
 		if src.Storable() {
@@ -308,8 +411,9 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 			if !NoNeedTransfer && operations.NeedTransfer(s.ctx, pair.Dst, pair.Src) {
 				// If files are treated as immutable, fail if destination exists and does not match
 				if fs.Config.Immutable && pair.Dst != nil {
-					fs.Errorf(pair.Dst, "Source and destination exist but do not match: immutable file modified")
-					s.processError(fs.ErrorImmutableModified)
+					s.processError(operations.CheckImmutable(s.ctx, pair.Dst, src))
+				} else if pair.Dst, ok = s.checkOverwrite(pair.Dst); !ok {
+					// Leave the changed destination alone
 				} else {
 					// If destination already exists, then we must move it into --backup-dir if required
 					if pair.Dst != nil && s.backupDir != nil {
@@ -321,25 +425,49 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 							pair.Dst = nil
 							ok = out.Put(s.ctx, pair)
 							if !ok {
+								release()
 								return
 							}
+							transferring = true
 						}
 					} else {
 						ok = out.Put(s.ctx, pair)
 						if !ok {
+							release()
 							return
 						}
+						transferring = true
 					}
 				}
 			} else {
+				if s.destState != nil && pair.Dst != nil {
+					s.destState.record(s.ctx, pair.Dst)
+				}
+				if fs.Config.VerifyAll {
+					s.addVerify(src, pair.Dst)
+				}
+				// Remember the hash of the src for the dst if not known
+				if s.hashDB != nil && pair.Dst != nil {
+					if _, ok := s.hashDB.Get(s.ctx, pair.Dst, s.dbHash); !ok {
+						s.recordHash(src, pair.Dst)
+					}
+				}
 				// If moving need to delete the files we don't need to copy
 				if s.DoMove {
 					// Delete src if no error on copy
-					s.processError(operations.DeleteFile(s.ctx, src))
+					if s.moveJournal != nil && pair.Dst != nil {
+						s.processError(s.journaledDeleteSrc(s.ctx, src, pair.Dst, true))
+					} else {
+						s.processError(operations.DeleteFile(s.ctx, src))
+					}
 				}
 			}
 		}
+		if !transferring {
+			s.checkpointFinishFile(src)
+		}
 		tr.Done(err)
+		release()
 	}
 }
 
@@ -366,27 +494,59 @@ func (s *syncCopyMove) pairRenamer(in *pipe, out *pipe, fraction int, wg *sync.W
 // pairCopyOrMove reads Objects on in and moves or copies them.
 func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs, fraction int, wg *sync.WaitGroup) {
 	defer wg.Done()
-	var err error
 	for {
 		pair, ok := in.GetMax(s.ctx, fraction)
 		if !ok {
 			return
 		}
 		src := pair.Src
-		if s.DoMove {
-			_, err = operations.Move(ctx, fdst, pair.Dst, src.Remote(), src)
+		if s.plan != nil {
+			s.processError(s.planCopy(src, pair.Dst))
+			continue
+		}
+		if s.journal != nil && ctx.Err() != nil {
+			s.journal.add(src.Remote())
+			continue
+		}
+		var newDst fs.Object
+		release, err := sched.Acquire(ctx)
+		if err != nil {
+			if s.journal != nil && s.cutoff(err) {
+				s.journal.add(src.Remote())
+			}
+			s.processError(err)
+			s.checkpointFinishFile(src)
+			continue
+		}
+		if s.DoMove && s.moveJournal != nil {
+			newDst, err = s.journaledMove(ctx, fdst, pair.Dst, src)
+		} else if s.DoMove {
+			newDst, err = operations.Move(ctx, fdst, pair.Dst, src.Remote(), src)
 		} else {
-			_, err = operations.Copy(ctx, fdst, pair.Dst, src.Remote(), src)
+			newDst, err = operations.Copy(ctx, fdst, pair.Dst, src.Remote(), src)
+			if err == nil && newDst != nil {
+				s.recordHash(src, newDst)
+				s.addVerify(src, newDst)
+			}
+		}
+		release()
+		if err == nil && newDst != nil && s.destState != nil {
+			s.destState.record(ctx, newDst)
+		}
+		if err != nil && s.journal != nil && s.cutoff(err) {
+			s.journal.add(src.Remote())
 		}
 		s.processError(err)
+		s.checkpointFinishFile(src)
 	}
 }
 
 // This starts the background checkers.
 func (s *syncCopyMove) startCheckers() {
-	s.checkerWg.Add(fs.Config.Checkers)
-	for i := 0; i < fs.Config.Checkers; i++ {
-		fraction := (100 * i) / fs.Config.Checkers
+	checkers := s.tuner.MaxCheckers()
+	s.checkerWg.Add(checkers)
+	for i := 0; i < checkers; i++ {
+		fraction := (100 * i) / checkers
 		go s.pairChecker(s.toBeChecked, s.toBeUploaded, fraction, &s.checkerWg)
 	}
 }
@@ -400,10 +560,11 @@ func (s *syncCopyMove) stopCheckers() {
 
 // This starts the background transfers
 func (s *syncCopyMove) startTransfers() {
-	s.transfersWg.Add(fs.Config.Transfers)
-	for i := 0; i < fs.Config.Transfers; i++ {
-		fraction := (100 * i) / fs.Config.Transfers
-		go s.pairCopyOrMove(s.ctx, s.toBeUploaded, s.fdst, fraction, &s.transfersWg)
+	transfers := s.tuner.MaxTransfers()
+	s.transfersWg.Add(transfers)
+	for i := 0; i < transfers; i++ {
+		fraction := (100 * i) / transfers
+		go s.pairCopyOrMove(s.transferCtx, s.toBeUploaded, s.fdst, fraction, &s.transfersWg)
 	}
 
 }
@@ -515,6 +676,9 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 		}
 		close(toDelete)
 	}()
+	if s.plan != nil {
+		return s.planDeletes(toDelete)
+	}
 	return operations.DeleteFilesWithBackupDir(s.ctx, toDelete, s.backupDir)
 }
 
@@ -673,17 +837,50 @@ func parseTrackRenamesStrategy(strategies string) (strategy trackRenamesStrategy
 	return strategy, nil
 }
 
+// recordHash records the hash of src in the hash database as the hash
+// of dst, its copy in the destination, for use by --track-renames in a
+// later sync.
+func (s *syncCopyMove) recordHash(src, dst fs.Object) {
+	if s.hashDB == nil {
+		return
+	}
+	sum, err := src.Hash(s.ctx, s.dbHash)
+	if err != nil || sum == "" {
+		fs.Debugf(src, "Not recording hash: %v", err)
+		return
+	}
+	err = s.hashDB.Put(s.ctx, dst, s.dbHash, sum)
+	if err != nil {
+		fs.Errorf(dst, "Failed to record hash in hash database: %v", err)
+	}
+}
+
+// renameHash returns the hash of obj to use for tracking renames
+//
+// If using the hash database then the hashes of objects in the
+// destination are read from there.
+func (s *syncCopyMove) renameHash(obj fs.Object, isDst bool) (string, error) {
+	if s.hashDB == nil {
+		return obj.Hash(s.ctx, s.commonHash)
+	}
+	if isDst {
+		sum, _ := s.hashDB.Get(s.ctx, obj, s.dbHash)
+		return sum, nil
+	}
+	return obj.Hash(s.ctx, s.dbHash)
+}
+
 // renameID makes a string with the size and the other identifiers of the requested rename strategies
 //
 // it may return an empty string in which case no hash could be made
-func (s *syncCopyMove) renameID(obj fs.Object, renamesStrategy trackRenamesStrategy, precision time.Duration) string {
+func (s *syncCopyMove) renameID(obj fs.Object, renamesStrategy trackRenamesStrategy, precision time.Duration, isDst bool) string {
 	var builder strings.Builder
 
 	fmt.Fprintf(&builder, "%d", obj.Size())
 
 	if renamesStrategy.hash() {
 		var err error
-		hash, err := obj.Hash(s.ctx, s.commonHash)
+		hash, err := s.renameHash(obj, isDst)
 
 		if err != nil {
 			fs.Debugf(obj, "Hash failed: %v", err)
@@ -781,7 +978,7 @@ func (s *syncCopyMove) makeRenameMap() {
 				// only create hash for dst fs.Object if its size could match
 				if _, found := possibleSizes[obj.Size()]; found {
 					tr := accounting.Stats(s.ctx).NewCheckingTransfer(obj)
-					hash := s.renameID(obj, s.trackRenamesStrategy, s.modifyWindow)
+					hash := s.renameID(obj, s.trackRenamesStrategy, s.modifyWindow, true)
 
 					if hash != "" {
 						s.pushRenameMap(hash, obj)
@@ -800,7 +997,7 @@ func (s *syncCopyMove) makeRenameMap() {
 // possible, it returns true if the object was renamed.
 func (s *syncCopyMove) tryRename(src fs.Object) bool {
 	// Calculate the hash of the src object
-	hash := s.renameID(src, s.trackRenamesStrategy, fs.GetModifyWindow(s.fsrc, s.fdst))
+	hash := s.renameID(src, s.trackRenamesStrategy, fs.GetModifyWindow(s.fsrc, s.fdst), false)
 
 	if hash == "" {
 		return false
@@ -816,12 +1013,27 @@ func (s *syncCopyMove) tryRename(src fs.Object) bool {
 	dstOverwritten, _ := s.fdst.NewObject(s.ctx, src.Remote())
 
 	// Rename dst to have name src.Remote()
-	_, err := operations.Move(s.ctx, s.fdst, dstOverwritten, src.Remote(), dst)
+	newDst, err := operations.Move(s.ctx, s.fdst, dstOverwritten, src.Remote(), dst)
 	if err != nil {
 		fs.Debugf(src, "Failed to rename to %q: %v", dst.Remote(), err)
 		return false
 	}
 
+	// Move the hash database entry to the new name
+	if s.hashDB != nil {
+		if newDst != nil {
+			s.recordHash(src, newDst)
+		}
+		_ = s.hashDB.Delete(dst)
+	}
+	if s.destState != nil {
+		if newDst != nil {
+			s.destState.record(s.ctx, newDst)
+		}
+		s.destState.remove(dst.Remote())
+	}
+	s.addVerify(src, newDst)
+
 	// remove file from dstFiles if present
 	s.dstFilesMu.Lock()
 	delete(s.dstFiles, dst.Remote())
@@ -839,6 +1051,20 @@ func (s *syncCopyMove) tryRename(src fs.Object) bool {
 //
 // dir is the start directory, "" for root
 func (s *syncCopyMove) run() error {
+	if s.hashDB != nil {
+		defer func() {
+			if err := s.hashDB.Close(); err != nil {
+				fs.Errorf(s.fdst, "Failed to close hash database: %v", err)
+			}
+		}()
+	}
+	if s.moveJournal != nil {
+		defer func() {
+			if err := s.moveJournal.Close(); err != nil {
+				fs.Errorf(s.fdst, "Failed to close move journal: %v", err)
+			}
+		}()
+	}
 	if operations.Same(s.fdst, s.fsrc) {
 		fs.Errorf(s.fdst, "Nothing to do as source and destination are the same")
 		return nil
@@ -867,7 +1093,14 @@ func (s *syncCopyMove) run() error {
 		NoCheckDest:            s.noCheckDest,
 		NoUnicodeNormalization: s.noUnicodeNormalization,
 	}
-	s.processError(m.Run())
+	var listErr error
+	if s.journal != nil && s.journal.pending != nil {
+		listErr = s.runJournal()
+	} else {
+		s.checkpointStartDir(s.dir)
+		listErr = m.Run()
+	}
+	s.processError(listErr)
 
 	s.stopTrackRenames()
 	if s.trackRenames {
@@ -892,7 +1125,9 @@ func (s *syncCopyMove) run() error {
 				}
 			}
 		}
-		s.processError(createNewDirectories(s.ctx, s.fdst, s.srcOnlyDirs))
+		if s.plan == nil {
+			s.processError(createNewDirectories(s.ctx, s.fdst, s.srcOnlyDirs))
+		}
 
 		fs.Infof(s.fdst, "Checks finished, now starting transfers")
 		s.startTransfers()
@@ -901,8 +1136,17 @@ func (s *syncCopyMove) run() error {
 	s.stopTransfers()
 	s.stopDeleters()
 
+	// Check the files again if required before deleting anything
+	if s.verify && len(s.toVerify) > 0 {
+		s.processError(s.verifyFiles())
+	}
+
 	if s.copyEmptySrcDirs {
-		s.processError(copyEmptyDirectories(s.ctx, s.fdst, s.srcEmptyDirs))
+		if s.plan != nil {
+			s.plan.addDirs(PlanMkdir, s.srcEmptyDirs)
+		} else {
+			s.processError(copyEmptyDirectories(s.ctx, s.fdst, s.srcEmptyDirs))
+		}
 	}
 
 	// Delete files after
@@ -910,7 +1154,13 @@ func (s *syncCopyMove) run() error {
 		if s.currentError() != nil && !fs.Config.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
 		} else {
-			s.processError(s.deleteFiles(false))
+			err := s.deleteFiles(false)
+			s.processError(err)
+			if err == nil && s.destState != nil {
+				for remote := range s.dstFiles {
+					s.destState.remove(remote)
+				}
+			}
 		}
 	}
 
@@ -918,6 +1168,8 @@ func (s *syncCopyMove) run() error {
 	if s.deleteMode != fs.DeleteModeOff {
 		if s.currentError() != nil && !fs.Config.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeletingDirs)
+		} else if s.plan != nil {
+			s.plan.addDirs(PlanRmdir, s.dstEmptyDirs)
 		} else {
 			s.processError(deleteEmptyDirectories(s.ctx, s.fdst, s.dstEmptyDirs))
 		}
@@ -932,16 +1184,61 @@ func (s *syncCopyMove) run() error {
 
 	// Read the error out of the context if there is one
 	s.processError(s.ctx.Err())
+	if s.journal != nil {
+		s.processError(s.transferCtx.Err())
+	}
 
-	if s.deleteMode != fs.DeleteModeOnly && accounting.Stats(s.ctx).GetTransfers() == 0 {
+	// Save the files still to transfer if the checks were completed
+	if s.journal != nil {
+		if listErr != nil || s.ctx.Err() != nil {
+			fs.Errorf(s.fdst, "Not saving cutover journal as the checks didn't complete")
+		} else if err := s.journal.save(s.fdst); err != nil {
+			fs.Errorf(s.fdst, "Failed to save cutover journal: %v", err)
+			s.processError(err)
+		}
+	}
+
+	if s.deleteMode != fs.DeleteModeOnly && accounting.Stats(s.ctx).GetTransfers() == 0 && s.plan == nil {
 		fs.Infof(nil, "There was nothing to transfer")
 	}
 
+	// Save the state of the destination for the next sync
+	if s.destState != nil {
+		err := s.destState.save()
+		if err != nil {
+			fs.Errorf(s.fdst, "Failed to save destination state: %v", err)
+			s.processError(err)
+		}
+	}
+
+	// Remove the checkpoint if the sync succeeded
+	if s.checkpoint != nil {
+		err := s.checkpoint.close(s.fdst, s.currentError() == nil)
+		if err != nil {
+			fs.Errorf(s.fdst, "Failed to close checkpoint: %v", err)
+			s.processError(err)
+		}
+	}
+
 	// cancel the context to free resources
 	s.cancel()
 	return s.currentError()
 }
 
+// withSidecar returns dst and, if --metadata is in use, the metadata
+// sidecar file of dst if it has one so they can be deleted together
+func (s *syncCopyMove) withSidecar(dst fs.Object) []fs.Object {
+	objs := []fs.Object{dst}
+	if !fs.Config.Metadata {
+		return objs
+	}
+	sidecar, err := s.fdst.NewObject(s.ctx, operations.MetadataSidecarRemote(dst.Remote()))
+	if err == nil {
+		objs = append(objs, sidecar)
+	}
+	return objs
+}
+
 // DstOnly have an object which is in the destination only
 func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	if s.deleteMode == fs.DeleteModeOff {
@@ -949,24 +1246,35 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 	}
 	switch x := dst.(type) {
 	case fs.Object:
-		switch s.deleteMode {
-		case fs.DeleteModeAfter:
-			// record object as needs deleting
-			s.dstFilesMu.Lock()
-			s.dstFiles[x.Remote()] = x
-			s.dstFilesMu.Unlock()
-		case fs.DeleteModeDuring, fs.DeleteModeOnly:
-			select {
-			case <-s.ctx.Done():
-				return
-			case s.deleteFilesCh <- x:
+		if fs.Config.Metadata && operations.IsMetadataSidecar(x.Remote()) {
+			// Sidecars are deleted with the file they belong to
+			return false
+		}
+		if !s.checkDelete(x) {
+			return false
+		}
+		s.checkpointFail(x.Remote())
+		for _, obj := range s.withSidecar(x) {
+			switch s.deleteMode {
+			case fs.DeleteModeAfter:
+				// record object as needs deleting
+				s.dstFilesMu.Lock()
+				s.dstFiles[obj.Remote()] = obj
+				s.dstFilesMu.Unlock()
+			case fs.DeleteModeDuring, fs.DeleteModeOnly:
+				select {
+				case <-s.ctx.Done():
+					return
+				case s.deleteFilesCh <- obj:
+				}
+			default:
+				panic(fmt.Sprintf("unexpected delete mode %d", s.deleteMode))
 			}
-		default:
-			panic(fmt.Sprintf("unexpected delete mode %d", s.deleteMode))
 		}
 	case fs.Directory:
 		// Do the same thing to the entire contents of the directory
 		// Record directory as it is potentially empty and needs deleting
+		s.checkpointFail(dst.Remote())
 		if s.fdst.Features().CanHaveEmptyDirectories {
 			s.dstEmptyDirsMu.Lock()
 			s.dstEmptyDirs[dst.Remote()] = dst
@@ -1009,6 +1317,7 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 			}
 			if !NoNeedTransfer {
 				// No need to check since doesn't exist
+				s.checkpointAddFile(x)
 				ok := s.toBeUploaded.Put(s.ctx, fs.ObjectPair{Src: x, Dst: nil})
 				if !ok {
 					return
@@ -1026,6 +1335,7 @@ func (s *syncCopyMove) SrcOnly(src fs.DirEntry) (recurse bool) {
 		s.srcOnlyDirsMu.Lock()
 		s.srcOnlyDirs[src.Remote()] = src
 		s.srcOnlyDirsMu.Unlock()
+		s.checkpointStartDir(src.Remote())
 		return true
 	default:
 		panic("Bad object in DirEntries")
@@ -1046,6 +1356,7 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 		}
 		dstX, ok := dst.(fs.Object)
 		if ok {
+			s.checkpointAddFile(srcX)
 			ok = s.toBeChecked.Put(s.ctx, fs.ObjectPair{Src: srcX, Dst: dstX})
 			if !ok {
 				return false
@@ -1060,6 +1371,11 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 		// Do the same thing to the entire contents of the directory
 		_, ok := dst.(fs.Directory)
 		if ok {
+			// Skip directories completed by an interrupted sync
+			if s.checkpoint != nil && s.checkpoint.isDone(src.Remote()) {
+				fs.Debugf(src, "Skipping directory completed in checkpoint")
+				return false
+			}
 			// Only record matched (src & dst) empty dirs when performing move
 			if s.DoMove {
 				// Record the src directory for deletion
@@ -1068,7 +1384,7 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 				s.srcEmptyDirs[src.Remote()] = src
 				s.srcEmptyDirsMu.Unlock()
 			}
-
+			s.checkpointStartDir(src.Remote())
 			return true
 		}
 		// FIXME src is dir, dst is file
@@ -1139,7 +1455,7 @@ func MoveDir(ctx context.Context, fdst, fsrc fs.Fs, deleteEmptySrcDirs bool, cop
 	}
 
 	// First attempt to use DirMover if exists, same Fs and no filters are active
-	if fdstDirMove := fdst.Features().DirMove; fdstDirMove != nil && operations.SameConfig(fsrc, fdst) && filter.Active.InActive() {
+	if fdstDirMove := fdst.Features().DirMove; fdstDirMove != nil && operations.SameConfig(fsrc, fdst) && filter.Active.InActive() && fs.Config.MoveJournal == "" {
 		if operations.SkipDestructive(ctx, fdst, "server side directory move") {
 			return nil
 		}
@@ -4,7 +4,11 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -14,10 +18,13 @@ import (
 	_ "github.com/rclone/rclone/backend/all" // import all backends
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/hashdb"
 	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/fstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -931,6 +938,37 @@ func TestSyncWithExclude(t *testing.T) {
 	fstest.CheckItems(t, r.Flocal, file2, file1, file3)
 }
 
+// Test with ignore files read from the source
+func TestSyncWithIgnoreFiles(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile(".rcloneignore", "*.log\n", t1)
+	file2 := r.WriteFile("a/b.log", "log", t1)
+	file3 := r.WriteFile("a/c.txt", "text", t1)
+	file4 := r.WriteObject(context.Background(), "a/old.log", "old log", t1)
+	file5 := r.WriteObject(context.Background(), "a/old.txt", "old text", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file4, file5)
+
+	oldActive := filter.Active
+	defer func() {
+		filter.Active = oldActive
+	}()
+	opt := filter.DefaultOpt
+	opt.IgnoreFileName = []string{".rcloneignore"}
+	var err error
+	filter.Active, err = filter.NewFilter(&opt)
+	require.NoError(t, err)
+
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(context.Background(), r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+
+	// The ignored log isn't copied and the ignored log on the
+	// destination isn't deleted
+	fstest.CheckItems(t, r.Fremote, file1, file3, file4)
+}
+
 // Test with exclude and delete excluded
 func TestSyncWithExcludeAndDeleteExcluded(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -1089,6 +1127,191 @@ func TestSyncWithTrackRenames(t *testing.T) {
 	}
 }
 
+func TestTrackRenamesHashDB(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	fs.Config.TrackRenames = true
+	defer func() {
+		fs.Config.TrackRenames = false
+	}()
+	dir, err := ioutil.TempDir("", "rclone-hashdb-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	r.WriteFile("potato", "Potato Content", t1)
+	r.WriteObject(ctx, "potato", "Potato Content", t1)
+	src, err := r.Flocal.NewObject(ctx, "potato")
+	require.NoError(t, err)
+	dst, err := r.Fremote.NewObject(ctx, "potato")
+	require.NoError(t, err)
+
+	s, err := newSyncCopyMove(ctx, r.Fremote, r.Flocal, fs.DeleteModeDefault, false, false, false)
+	require.NoError(t, err)
+	if !s.trackRenames {
+		t.Skip("remote can't track renames")
+	}
+
+	// Pretend there is no common hash and use the hash database
+	s.commonHash = hash.None
+	s.dbHash = hash.MD5
+	s.hashDB, err = hashdb.Open(filepath.Join(dir, "hashes.db"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.hashDB.Close())
+	}()
+
+	srcID := s.renameID(src, s.trackRenamesStrategy, s.modifyWindow, false)
+	assert.NotEqual(t, "", srcID)
+	assert.Equal(t, "", s.renameID(dst, s.trackRenamesStrategy, s.modifyWindow, true))
+
+	s.recordHash(src, dst)
+	assert.Equal(t, srcID, s.renameID(dst, s.trackRenamesStrategy, s.modifyWindow, true))
+}
+
+func TestSyncDestConflict(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-deststate-test")
+	require.NoError(t, err)
+	oldCacheDir := config.CacheDir
+	config.CacheDir = dir
+	defer func() {
+		config.CacheDir = oldCacheDir
+		fs.Config.DestConflict = fs.DestConflictOff
+		_ = os.RemoveAll(dir)
+	}()
+	fs.Config.DestConflict = fs.DestConflictError
+
+	// First sync records the destination state
+	file1 := r.WriteFile("potato", "Potato Content", t1)
+	file2 := r.WriteFile("yam", "Yam Content", t1)
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// Change potato in both, add a file only in the destination
+	// and change yam only in the source
+	file1 = r.WriteFile("potato", "Potato Content Changed", t2)
+	remote1 := r.WriteObject(ctx, "potato", "Potato Content Changed On Dest", t3)
+	extra := r.WriteObject(ctx, "extra", "Extra Content", t2)
+	file2 = r.WriteFile("yam", "Yam Content Changed", t2)
+
+	// Refuses to overwrite or delete the changed files
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.True(t, errors.Cause(err) == fs.ErrorDestConflict, err)
+	fstest.CheckItems(t, r.Fremote, remote1, extra, file2)
+
+	// Still refuses next time
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	fstest.CheckItems(t, r.Fremote, remote1, extra, file2)
+
+	// Renames the changed files aside
+	fs.Config.DestConflict = fs.DestConflictRename
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	objs, _, err := walk.GetAll(ctx, r.Fremote, "", true, -1)
+	require.NoError(t, err)
+	var names []string
+	for _, o := range objs {
+		names = append(names, o.Remote())
+	}
+	require.Equal(t, 4, len(names), names)
+	assert.Regexp(t, `^extra\.conflict-\d{8}-\d{6}$`, names[0])
+	assert.Equal(t, "potato", names[1])
+	assert.Regexp(t, `^potato\.conflict-\d{8}-\d{6}$`, names[2])
+	assert.Equal(t, "yam", names[3])
+
+	// Conflict files are left alone by the next sync
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	objs, _, err = walk.GetAll(ctx, r.Fremote, "", true, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, len(objs))
+}
+
+func TestSyncVerify(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-verify-test")
+	require.NoError(t, err)
+	manifest := filepath.Join(dir, "manifest.txt")
+	defer func() {
+		fs.Config.Verify = false
+		fs.Config.VerifyManifest = ""
+		fs.Config.VerifyKey = ""
+		_ = os.RemoveAll(dir)
+	}()
+	fs.Config.Verify = true
+	fs.Config.VerifyManifest = manifest
+	fs.Config.VerifyKey = "potato"
+
+	file1 := r.WriteFile("potato", "Potato Content", t1)
+	file2 := r.WriteFile("sub/yam", "Yam Content", t2)
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	data, err := ioutil.ReadFile(manifest)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Equal(t, 5, len(lines), string(data))
+	assert.Equal(t, "# rclone verify manifest", lines[0])
+	assert.True(t, strings.HasSuffix(lines[2], "  14  potato"), lines[2])
+	assert.True(t, strings.HasSuffix(lines[3], "  11  sub/yam"), lines[3])
+	signed := strings.Join(lines[:4], "\n") + "\n"
+	assert.Equal(t, signManifest([]byte(signed), "potato"), lines[4])
+	assert.True(t, strings.HasPrefix(lines[4], "# hmac-sha256: "))
+
+	// Check a destination which doesn't match is reported
+	fs.Config.VerifyManifest = ""
+	r.WriteObject(ctx, "potato", "Potato Corrupted", t1)
+	src, err := r.Flocal.NewObject(ctx, "potato")
+	require.NoError(t, err)
+	dst, err := r.Fremote.NewObject(ctx, "potato")
+	require.NoError(t, err)
+	s, err := newSyncCopyMove(ctx, r.Fremote, r.Flocal, fs.DeleteModeDefault, false, false, false)
+	require.NoError(t, err)
+	s.addVerify(src, dst)
+	err = s.verifyFiles()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 files failed verification")
+	assert.True(t, fserrors.IsNoRetryError(err))
+}
+
+func TestSyncMetadataSidecars(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer func() {
+		fs.Config.Metadata = false
+	}()
+	fs.Config.Metadata = true
+
+	file1 := r.WriteBoth(ctx, "potato", "Potato Content", t1)
+	sidecar1 := r.WriteObject(ctx, "potato"+operations.MetadataSidecarSuffix, "{}", t1)
+	r.WriteObject(ctx, "yam", "Yam Content", t2)
+	r.WriteObject(ctx, "yam"+operations.MetadataSidecarSuffix, "{}", t2)
+	fstest.CheckItems(t, r.Flocal, file1)
+
+	// The sidecar of the deleted file should be deleted but the
+	// other kept
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, file1, sidecar1)
+}
+
 func TestParseRenamesStrategyModtime(t *testing.T) {
 	for _, test := range []struct {
 		in      string
@@ -1886,3 +2109,255 @@ func TestAbort(t *testing.T) {
 	fserrors.Count(expectedErr)
 	assert.Equal(t, expectedErr, err)
 }
+
+// Test that the files not transferred at the --max-transfer cutoff
+// are journaled and transferred by the next run without listing
+func TestSyncCutoverJournal(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	if r.Fremote.Name() != "local" {
+		t.Skip("This test only runs on local")
+	}
+
+	dir, err := ioutil.TempDir("", "rclone-journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	journalPath := filepath.Join(dir, "journal.json")
+
+	oldMaxTransfer := fs.Config.MaxTransfer
+	oldCutoffMode := fs.Config.CutoffMode
+	oldTransfers := fs.Config.Transfers
+	fs.Config.MaxTransfer = 3 * 1024
+	fs.Config.CutoffMode = fs.CutoffModeCautious
+	fs.Config.Transfers = 1
+	fs.Config.CutoverJournal = journalPath
+	defer func() {
+		fs.Config.MaxTransfer = oldMaxTransfer
+		fs.Config.CutoffMode = oldCutoffMode
+		fs.Config.Transfers = oldTransfers
+		fs.Config.CutoverJournal = ""
+	}()
+
+	var files []fstest.Item
+	for i := 1; i <= 3; i++ {
+		files = append(files, r.WriteFile(fmt.Sprintf("file%d", i), string(make([]byte, 2*1024)), t1))
+	}
+
+	// First run transfers one file and journals the others
+	accounting.GlobalStats().ResetCounters()
+	err = CopyDir(ctx, r.Fremote, r.Flocal, false)
+	assert.True(t, fserrors.IsFatalError(err), "expecting fatal error, got %v", err)
+	assert.Equal(t, int64(1), accounting.GlobalStats().GetTransfers())
+
+	data, err := ioutil.ReadFile(journalPath)
+	require.NoError(t, err)
+	var journal journalFile
+	require.NoError(t, json.Unmarshal(data, &journal))
+	require.Len(t, journal.Files, 2)
+	for _, remote := range journal.Files {
+		_, err := r.Fremote.NewObject(ctx, remote)
+		assert.Equal(t, fs.ErrorObjectNotFound, err, remote)
+	}
+
+	// Second run only transfers the files in the journal
+	fs.Config.MaxTransfer = -1
+	file4 := r.WriteFile("file4", "new since the first run", t1)
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, files...)
+	_, err = os.Stat(journalPath)
+	assert.True(t, os.IsNotExist(err), "journal should be removed")
+
+	// Third run is a normal copy again
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, append(files, file4)...)
+}
+
+// Test moving with --move-journal then rolling it back
+func TestMoveJournalRollback(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-move-journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	journalPath := filepath.Join(dir, "move.journal")
+	fs.Config.MoveJournal = journalPath
+	defer func() {
+		fs.Config.MoveJournal = ""
+	}()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	file2 := r.WriteFile("sub/file2", "file2 contents", t2)
+	file3 := r.WriteBoth(ctx, "file3", "already there", t1)
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file3)
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, MoveDir(ctx, r.Fremote, r.Flocal, false, false))
+	fstest.CheckItems(t, r.Flocal)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	_, entries, err := readMoveJournal(journalPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]moveJournalEntry{
+		"file1":     {Remote: "file1", State: moveDone},
+		"sub/file2": {Remote: "sub/file2", State: moveDone},
+		"file3":     {Remote: "file3", State: moveDone, Existed: true},
+	}, func() map[string]moveJournalEntry {
+		for remote, entry := range entries {
+			entry.Time = time.Time{}
+			entries[remote] = entry
+		}
+		return entries
+	}())
+
+	// Roll back restores the source and leaves files which were
+	// already in the destination
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, RollbackMove(ctx, r.Fremote, r.Flocal, journalPath))
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file3)
+	_, err = os.Stat(journalPath)
+	assert.True(t, os.IsNotExist(err), "journal should be removed")
+}
+
+// Test rolling back a move interrupted after files were verified
+func TestMoveJournalRollbackVerified(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-move-journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	journalPath := filepath.Join(dir, "move.journal")
+
+	// file1 was verified and its source deleted, file2 was verified
+	// but its source not deleted yet
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	file2 := r.WriteBoth(ctx, "file2", "file2 contents", t2)
+	j, err := openMoveJournal(journalPath, r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	for _, remote := range []string{"file1", "file2"} {
+		require.NoError(t, j.record(remote, moveCopying, false))
+		require.NoError(t, j.record(remote, moveVerified, false))
+	}
+	require.NoError(t, j.Close())
+	fstest.CheckItems(t, r.Flocal, file2)
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, RollbackMove(ctx, r.Fremote, r.Flocal, journalPath))
+	fstest.CheckItems(t, r.Flocal, file1, file2)
+	fstest.CheckItems(t, r.Fremote)
+}
+
+// Test the checkpoint records directories when their subtrees complete
+func TestCheckpointRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-checkpoint")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "checkpoint.json")
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+
+	c, err := loadCheckpoint(path, f, f)
+	require.NoError(t, err)
+	c.startDir("", 0)
+	c.startDir("a", 0)
+	c.startDir("a/b", 0)
+	c.startDir("c", 0)
+	c.addFile("a")
+	c.finish("a/b", 0) // a/b listed with nothing in
+	c.finish("a", 0)   // a listed
+	assert.Equal(t, map[string]*checkpointDir{
+		"":  {pending: 3},
+		"a": {pending: 1},
+		"c": {pending: 1},
+	}, c.dirs)
+	c.finish("a", 0) // file in a done
+	c.fail("c")
+	c.finish("c", 0)
+	c.finish("", 0)
+	assert.Equal(t, map[string]*checkpointDir{}, c.dirs)
+	require.NoError(t, c.close(f, false))
+
+	c, err = loadCheckpoint(path, f, f)
+	require.NoError(t, err)
+	assert.True(t, c.isDone("a"))
+	assert.True(t, c.isDone("a/b"))
+	assert.False(t, c.isDone("c"))
+	assert.False(t, c.isDone(""))
+
+	// An error while the directory is in progress stops it being recorded
+	c.startDir("d", 0)
+	c.finish("d", 1)
+	require.NoError(t, c.close(f, false))
+	c, err = loadCheckpoint(path, f, f)
+	require.NoError(t, err)
+	assert.False(t, c.isDone("d"))
+
+	// Only used for the remotes it was made for
+	other, err := fs.NewFs(filepath.Join(dir, "other"))
+	require.NoError(t, err)
+	_, err = loadCheckpoint(path, f, other)
+	assert.Error(t, err)
+}
+
+// Test a sync with --checkpoint skips the completed directories
+func TestSyncCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	if r.Fremote.Name() != "local" {
+		t.Skip("This test only runs on local")
+	}
+
+	dir, err := ioutil.TempDir("", "rclone-checkpoint")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "checkpoint.json")
+	fs.Config.Checkpoint = path
+	defer func() {
+		fs.Config.Checkpoint = ""
+	}()
+
+	// Pretend an interrupted sync completed "a"
+	file1 := r.WriteFile("a/file1", "file1", t1)
+	file2 := r.WriteFile("a/file2", "not copied as a is complete", t1)
+	file3 := r.WriteFile("b/file3", "file3", t1)
+	r.WriteObject(ctx, "a/file1", "file1", t1)
+	header, err := json.Marshal(checkpointHeader{
+		Source: fs.ConfigString(r.Flocal),
+		Dest:   fs.ConfigString(r.Fremote),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, []byte(string(header)+"\n{\"dir\":\"a\"}\n"), 0600))
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, file1, file3)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "checkpoint should be removed")
+
+	// The next run is a normal sync
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+}
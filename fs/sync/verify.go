@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// verifiedFile is a line of the --verify-manifest
+type verifiedFile struct {
+	remote string
+	size   int64
+	sum    string
+}
+
+// addVerify notes that dst should have the same contents as src for
+// --verify
+func (s *syncCopyMove) addVerify(src, dst fs.Object) {
+	if !s.verify || src == nil || dst == nil {
+		return
+	}
+	s.verifyMu.Lock()
+	s.toVerify = append(s.toVerify, fs.ObjectPair{Src: src, Dst: dst})
+	s.verifyMu.Unlock()
+}
+
+// verifyFiles reads each file noted by addVerify from the destination
+// again and checks it against the source, by hash if possible and by
+// downloading both if not.
+//
+// It writes the --verify-manifest if required and returns an error if
+// any files failed.
+func (s *syncCopyMove) verifyFiles() error {
+	fs.Infof(s.fdst, "Verifying %d files", len(s.toVerify))
+	var (
+		in       = make(chan fs.ObjectPair, fs.Config.Checkers)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified []verifiedFile
+		failed   int
+	)
+	manifestHash := s.fsrc.Hashes().Overlap(s.fdst.Hashes()).GetOne()
+	if manifestHash == hash.None {
+		manifestHash = s.fdst.Hashes().GetOne()
+	}
+	for i := 0; i < fs.Config.Checkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pair := range in {
+				sum, err := s.verifyFile(pair.Src, pair.Dst.Remote(), manifestHash)
+				mu.Lock()
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(pair.Dst, "Verify failed: %v", err)
+					failed++
+				} else {
+					verified = append(verified, verifiedFile{
+						remote: pair.Dst.Remote(),
+						size:   pair.Src.Size(),
+						sum:    sum,
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, pair := range s.toVerify {
+		if s.aborting() {
+			break
+		}
+		in <- pair
+	}
+	close(in)
+	wg.Wait()
+	if fs.Config.VerifyManifest != "" {
+		err := writeManifest(fs.Config.VerifyManifest, manifestHash, verified)
+		if err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fserrors.NoRetryError(errors.Errorf("%d files failed verification", failed))
+	}
+	fs.Infof(s.fdst, "Verified %d files", len(verified))
+	return nil
+}
+
+// verifyFile checks the file at remote in the destination against
+// src returning its hash of type ht for the manifest
+func (s *syncCopyMove) verifyFile(src fs.Object, remote string, ht hash.Type) (sum string, err error) {
+	tr := accounting.Stats(s.ctx).NewCheckingTransfer(src)
+	defer func() {
+		tr.Done(err)
+	}()
+	// Find the object again rather than trusting the one from the
+	// transfer
+	dst, err := s.fdst.NewObject(s.ctx, remote)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find destination")
+	}
+	if src.Size() >= 0 && dst.Size() >= 0 && src.Size() != dst.Size() {
+		return "", errors.Errorf("sizes differ: source %d destination %d", src.Size(), dst.Size())
+	}
+	equal, commonHash, err := operations.CheckHashes(s.ctx, src, dst)
+	if err != nil {
+		return "", err
+	}
+	if commonHash == hash.None {
+		differ, err := operations.CheckIdenticalDownload(s.ctx, dst, src)
+		if err != nil {
+			return "", err
+		}
+		equal = !differ
+	}
+	if !equal {
+		return "", errors.New("contents differ")
+	}
+	if ht != hash.None {
+		sum, err = dst.Hash(s.ctx, ht)
+		if err != nil {
+			fs.Debugf(dst, "Failed to read hash for manifest: %v", err)
+			sum = ""
+		}
+	}
+	return sum, nil
+}
+
+// writeManifest writes the verified files to path in the format
+//
+//     # rclone verify manifest
+//     # hash: md5
+//     <hash>  <size>  <path>
+//     ...
+//     # hmac-sha256: <signature>
+//
+// The last line is an HMAC-SHA256 of the lines above using the
+// --verify-key if set, otherwise a plain SHA-256 of them.
+func writeManifest(path string, ht hash.Type, files []verifiedFile) error {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].remote < files[j].remote
+	})
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# rclone verify manifest\n")
+	fmt.Fprintf(&buf, "# hash: %v\n", ht)
+	for _, file := range files {
+		sum := file.sum
+		if sum == "" {
+			sum = "-"
+		}
+		fmt.Fprintf(&buf, "%s  %d  %s\n", sum, file.size, file.remote)
+	}
+	fmt.Fprintf(&buf, "%s\n", signManifest(buf.Bytes(), fs.Config.VerifyKey))
+	err := ioutil.WriteFile(path, buf.Bytes(), 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write verify manifest")
+	}
+	return nil
+}
+
+// signManifest returns the signature line for the manifest contents
+// in data
+func signManifest(data []byte, key string) string {
+	if key == "" {
+		sum := sha256.Sum256(data)
+		return "# sha256: " + hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	_, _ = mac.Write(data)
+	return "# hmac-sha256: " + hex.EncodeToString(mac.Sum(nil))
+}
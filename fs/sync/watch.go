@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// WatchOpt controls Watch
+type WatchOpt struct {
+	PollInterval time.Duration // poll interval for ChangeNotify, or time between syncs if the source can't notify
+	Delay        time.Duration // time to gather changes for before syncing them
+}
+
+// DefaultWatchOpt is the default values for WatchOpt
+var DefaultWatchOpt = WatchOpt{
+	PollInterval: time.Minute,
+	Delay:        5 * time.Second,
+}
+
+// syncDir syncs dir in fsrc into fdst
+func syncDir(ctx context.Context, fdst, fsrc fs.Fs, dir string, copyEmptySrcDirs bool) error {
+	s, err := newSyncCopyMove(ctx, fdst, fsrc, fs.Config.DeleteMode, false, false, copyEmptySrcDirs)
+	if err != nil {
+		return err
+	}
+	s.dir = dir
+	// Only syncing part of the tree so can't record complete directories
+	s.checkpoint = nil
+	return s.run()
+}
+
+// existingDir returns dir or the nearest parent of it which exists in f
+func existingDir(ctx context.Context, f fs.Fs, dir string) string {
+	for dir != "" {
+		_, err := f.List(ctx, dir)
+		if err != fs.ErrorDirNotFound {
+			break
+		}
+		dir = path.Dir(dir)
+		if dir == "." {
+			dir = ""
+		}
+	}
+	return dir
+}
+
+// syncChangedFile syncs the single file remote from fsrc into fdst,
+// returning false if remote isn't a file in either.
+func syncChangedFile(ctx context.Context, fdst, fsrc fs.Fs, remote string) (isFile bool, err error) {
+	src, err := fsrc.NewObject(ctx, remote)
+	if err == nil {
+		if !filter.Active.IncludeObject(ctx, src) {
+			return true, nil
+		}
+		return true, operations.CopyFile(ctx, fdst, fsrc, remote, remote)
+	} else if err == fs.ErrorNotAFile {
+		return false, nil
+	} else if err != fs.ErrorObjectNotFound {
+		return true, err
+	}
+	dst, err := fdst.NewObject(ctx, remote)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorNotAFile {
+		return false, nil
+	} else if err != nil {
+		return true, err
+	}
+	if !filter.Active.IncludeObject(ctx, dst) && !filter.Active.Opt.DeleteExcluded {
+		return true, nil
+	}
+	if fs.Config.BackupDir != "" || fs.Config.Suffix != "" {
+		// Sync the directory so the file is backed up
+		return false, nil
+	}
+	return true, operations.DeleteFile(ctx, dst)
+}
+
+// syncChanges syncs the paths which have changed
+func syncChanges(ctx context.Context, fdst, fsrc fs.Fs, changes map[string]fs.EntryType, copyEmptySrcDirs bool) error {
+	// Sync the changed files, making a list of directories to sync
+	dirs := map[string]struct{}{}
+	var lastErr error
+	for remote, entryType := range changes {
+		if entryType == fs.EntryObject {
+			isFile, err := syncChangedFile(ctx, fdst, fsrc, remote)
+			if err != nil {
+				fs.Errorf(remote, "Failed to sync changed file: %v", err)
+				lastErr = err
+			}
+			if isFile {
+				continue
+			}
+			// Not a file so sync the directory it is in
+			remote = path.Dir(remote)
+			if remote == "." {
+				remote = ""
+			}
+		}
+		dirs[existingDir(ctx, fsrc, remote)] = struct{}{}
+	}
+
+	// Sync the directories, skipping any inside others being synced
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	var synced []string
+outer:
+	for _, dir := range sorted {
+		for _, parent := range synced {
+			if parent == "" || strings.HasPrefix(dir, parent+"/") {
+				continue outer
+			}
+		}
+		synced = append(synced, dir)
+		fs.Infof(fs.LogDirName(fsrc, dir), "Syncing changed directory")
+		err := syncDir(ctx, fdst, fsrc, dir, copyEmptySrcDirs)
+		if err != nil {
+			fs.Errorf(fs.LogDirName(fsrc, dir), "Failed to sync changed directory: %v", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Watch syncs fsrc into fdst then keeps running, syncing the paths
+// which change in fsrc, until ctx is cancelled.
+//
+// Changes are found with the source's change notification, if it
+// has it, and gathered for opt.Delay before being synced. If the
+// source doesn't support change notification then it is synced again
+// every opt.PollInterval.
+func Watch(ctx context.Context, fdst, fsrc fs.Fs, copyEmptySrcDirs bool, opt *WatchOpt) error {
+	err := Sync(ctx, fdst, fsrc, copyEmptySrcDirs)
+	if err != nil {
+		fs.Errorf(fdst, "Initial sync failed: %v", err)
+	}
+
+	doChangeNotify := fsrc.Features().ChangeNotify
+	if doChangeNotify == nil {
+		fs.Logf(fsrc, "Source doesn't support change notification - syncing every %v", opt.PollInterval)
+		ticker := time.NewTicker(opt.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			err = Sync(ctx, fdst, fsrc, copyEmptySrcDirs)
+			if err != nil {
+				fs.Errorf(fdst, "Sync failed: %v", err)
+			}
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		changes = map[string]fs.EntryType{}
+		changed = make(chan struct{}, 1)
+	)
+	pollInterval := make(chan time.Duration, 1)
+	pollInterval <- opt.PollInterval
+	doChangeNotify(ctx, func(remote string, entryType fs.EntryType) {
+		fs.Debugf(fsrc, "Change notified for %q", remote)
+		mu.Lock()
+		// A directory change covers a file change at the same path
+		if old, found := changes[remote]; !found || old != fs.EntryDirectory {
+			changes[remote] = entryType
+		}
+		mu.Unlock()
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}, pollInterval)
+	fs.Logf(fsrc, "Watching for changes")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+		// Gather changes for a while before syncing them
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opt.Delay):
+		}
+		mu.Lock()
+		toSync := changes
+		changes = map[string]fs.EntryType{}
+		mu.Unlock()
+		fs.Infof(fsrc, "Syncing %d changed paths", len(toSync))
+		err = syncChanges(ctx, fdst, fsrc, toSync, copyEmptySrcDirs)
+		if err != nil {
+			fs.Errorf(fdst, "Failed to sync changes: %v", err)
+		}
+	}
+}
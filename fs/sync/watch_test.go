@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncChanges(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer accounting.GlobalStats().ResetCounters()
+
+	newFile := r.WriteFile("new.txt", "new file", t1)
+	newInDir := r.WriteFile("dir/new.txt", "new file in dir", t1)
+	unchanged := r.WriteBoth(ctx, "sub/unchanged.txt", "unchanged", t1)
+	notNotified := r.WriteFile("not-notified.txt", "not notified", t1)
+	r.WriteObject(ctx, "deleted.txt", "deleted from source", t1)
+	r.WriteObject(ctx, "sub/gone/file.txt", "deleted directory", t1)
+
+	err := syncChanges(ctx, r.Fremote, r.Flocal, map[string]fs.EntryType{
+		"new.txt":     fs.EntryObject,
+		"deleted.txt": fs.EntryObject,
+		"dir":         fs.EntryDirectory,
+		"sub/gone":    fs.EntryDirectory,
+	}, false)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Flocal, newFile, newInDir, unchanged, notNotified)
+	fstest.CheckItems(t, r.Fremote, newFile, newInDir, unchanged)
+}
+
+func TestExistingDir(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	r.WriteFile("a/b/file.txt", "file", t1)
+	assert.Equal(t, "a/b", existingDir(ctx, r.Flocal, "a/b"))
+	assert.Equal(t, "a/b", existingDir(ctx, r.Flocal, "a/b/c/d"))
+	assert.Equal(t, "", existingDir(ctx, r.Flocal, "potato"))
+}
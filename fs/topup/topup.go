@@ -0,0 +1,263 @@
+// Package topup implements continuous copying of new files
+//
+// After an initial pass which copies everything, each pass only
+// copies the files in the source modified after the newest file seen
+// by the previous pass - the high water mark. The high water mark is
+// kept in a state file so a restarted rclone carries on where it
+// left off.
+package topup
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// Options control the top up
+type Options struct {
+	Interval     time.Duration // time between passes, 0 to run a single pass
+	UseNotify    bool          // start a pass when the source notifies of changes
+	PollInterval time.Duration // poll interval to pass to ChangeNotify
+	StateFile    string        // file to keep the high water mark in
+}
+
+// DefaultOpt is the default values for Options
+var DefaultOpt = Options{
+	Interval:     time.Minute,
+	PollInterval: time.Minute,
+}
+
+// state is stored in the state file between passes
+type state struct {
+	Source    string    `json:"source"`
+	Dest      string    `json:"dest"`
+	HighWater time.Time `json:"highWater"`
+}
+
+// topup is the state of a running top up
+type topup struct {
+	fdst  fs.Fs
+	fsrc  fs.Fs
+	opt   Options
+	state state
+}
+
+// stateFile returns the path of the state file to use
+func (t *topup) stateFile() string {
+	if t.opt.StateFile != "" {
+		return t.opt.StateFile
+	}
+	sum := md5.Sum([]byte(t.state.Source + "\x00" + t.state.Dest))
+	return filepath.Join(config.CacheDir, "topup", hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads the high water mark from the state file if there is one
+func (t *topup) load() error {
+	data, err := ioutil.ReadFile(t.stateFile())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to read state file")
+	}
+	var s state
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse state file")
+	}
+	if s.Source != t.state.Source || s.Dest != t.state.Dest {
+		return errors.Errorf("state file %q is for %s -> %s", t.stateFile(), s.Source, s.Dest)
+	}
+	t.state = s
+	return nil
+}
+
+// save writes the high water mark to the state file atomically
+func (t *topup) save() error {
+	if fs.Config.DryRun {
+		return nil
+	}
+	path := t.stateFile()
+	data, err := json.MarshalIndent(&t.state, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to make state directory")
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write state file")
+	}
+	return os.Rename(tmp, path)
+}
+
+// pass copies the files modified since the high water mark, moving
+// the high water mark up to the newest file seen.
+//
+// The high water mark is never moved past the start of the pass so a
+// file with a modification time in the future doesn't stop the files
+// which arrive before then being copied.
+func (t *topup) pass(ctx context.Context) error {
+	start := time.Now()
+	highWater := t.state.HighWater
+	fs.Infof(t.fsrc, "Top up pass copying files modified since %v", highWater)
+	var (
+		in         = make(chan fs.Object, fs.Config.Transfers)
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		newest     = highWater
+		copied     int
+		unchanged  int
+		errorCount int
+	)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range in {
+				modTime := src.ModTime(ctx)
+				didCopy, err := t.copy(ctx, src)
+				mu.Lock()
+				if err != nil {
+					err = fs.CountError(err)
+					fs.Errorf(src, "Top up failed to copy: %v", err)
+					errorCount++
+				} else {
+					if didCopy {
+						copied++
+					} else {
+						unchanged++
+					}
+					if modTime.After(newest) {
+						newest = modTime
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	err := walk.ListR(ctx, t.fsrc, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			// Files at the high water mark are checked again
+			// in case more arrived with the same time
+			if !o.ModTime(ctx).Before(highWater) && filter.Active.IncludeObject(ctx, o) {
+				in <- o
+			}
+		})
+		return nil
+	})
+	close(in)
+	wg.Wait()
+	if err != nil {
+		return errors.Wrap(err, "failed to list source")
+	}
+	if errorCount > 0 {
+		// Don't move the high water mark so the failed files are
+		// retried on the next pass
+		return errors.Errorf("failed to copy %d files", errorCount)
+	}
+	fs.Infof(t.fsrc, "Top up pass copied %d new files and skipped %d unchanged", copied, unchanged)
+	if newest.After(start) {
+		fs.Debugf(t.fsrc, "Not moving high water mark to %v as it is in the future", newest)
+		newest = start
+	}
+	if newest.After(highWater) {
+		t.state.HighWater = newest
+		err = t.save()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copy copies src to the destination if it is missing or different
+// returning whether it was copied
+func (t *topup) copy(ctx context.Context, src fs.Object) (copied bool, err error) {
+	dst, err := t.fdst.NewObject(ctx, src.Remote())
+	if err == fs.ErrorObjectNotFound {
+		dst = nil
+	} else if err != nil {
+		return false, err
+	}
+	if dst != nil && operations.Equal(ctx, src, dst) {
+		fs.Debugf(src, "Unchanged skipping")
+		return false, nil
+	}
+	_, err = operations.Copy(ctx, t.fdst, dst, src.Remote(), src)
+	return err == nil, err
+}
+
+// TopUp copies new files from fsrc to fdst, then if opt.Interval is
+// set carries on copying the files which appear in fsrc until ctx is
+// cancelled.
+//
+// If opt.UseNotify is set and the source supports it then a pass is
+// started as soon as the source notifies of changes as well as every
+// opt.Interval.
+func TopUp(ctx context.Context, fdst, fsrc fs.Fs, opt *Options) error {
+	t := &topup{
+		fdst: fdst,
+		fsrc: fsrc,
+		opt:  *opt,
+		state: state{
+			Source: fs.ConfigString(fsrc),
+			Dest:   fs.ConfigString(fdst),
+		},
+	}
+	err := t.load()
+	if err != nil {
+		return err
+	}
+	if t.opt.Interval <= 0 {
+		return t.pass(ctx)
+	}
+
+	// Set up change notification
+	changed := make(chan struct{}, 1)
+	if t.opt.UseNotify {
+		if doChangeNotify := fsrc.Features().ChangeNotify; doChangeNotify != nil {
+			pollInterval := make(chan time.Duration, 1)
+			pollInterval <- t.opt.PollInterval
+			doChangeNotify(ctx, func(path string, entryType fs.EntryType) {
+				fs.Debugf(fsrc, "Change notified for %q", path)
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}, pollInterval)
+		} else {
+			fs.Logf(fsrc, "Source doesn't support change notification - using --interval only")
+		}
+	}
+
+	ticker := time.NewTicker(t.opt.Interval)
+	defer ticker.Stop()
+	for {
+		err = t.pass(ctx)
+		if err != nil {
+			fs.Errorf(fsrc, "Top up pass failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
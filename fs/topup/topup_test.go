@@ -0,0 +1,92 @@
+package topup
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/all"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestTopUp(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-topup-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	opt := DefaultOpt
+	opt.Interval = 0
+	opt.StateFile = filepath.Join(dir, "state.json")
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 := fstest.Time("2011-12-25T12:59:59.123456789Z")
+	t3 := fstest.Time("2021-07-08T09:10:11.000000000Z")
+
+	// First pass copies everything
+	file1 := r.WriteFile("old.txt", "old file", t1)
+	file2 := r.WriteFile("dir/newer.txt", "newer file", t2)
+	require.NoError(t, TopUp(ctx, r.Fremote, r.Flocal, &opt))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+
+	// A newer file is copied but a file older than the high water
+	// mark isn't
+	file3 := r.WriteFile("newest.txt", "newest file", t3)
+	r.WriteFile("late.txt", "arrived late", t1)
+	require.NoError(t, TopUp(ctx, r.Fremote, r.Flocal, &opt))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	// Starting again without the state file copies everything
+	opt.StateFile = filepath.Join(dir, "other.json")
+	require.NoError(t, TopUp(ctx, r.Fremote, r.Flocal, &opt))
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3, fstest.NewItem("late.txt", "arrived late", t1))
+}
+
+func TestTopUpFutureModTime(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	dir, err := ioutil.TempDir("", "rclone-topup-test")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	opt := DefaultOpt
+	opt.Interval = 0
+	opt.StateFile = filepath.Join(dir, "state.json")
+
+	// A file from the future doesn't move the high water mark past now
+	future := time.Now().Add(24 * time.Hour)
+	file1 := r.WriteFile("future.txt", "from the future", future)
+	before := time.Now()
+	require.NoError(t, TopUp(ctx, r.Fremote, r.Flocal, &opt))
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	tu := &topup{opt: opt, state: state{Source: fs.ConfigString(r.Flocal), Dest: fs.ConfigString(r.Fremote)}}
+	require.NoError(t, tu.load())
+	assert.False(t, tu.state.HighWater.After(time.Now()), "high water mark in the future")
+	assert.False(t, tu.state.HighWater.Before(before))
+
+	// So a file arriving now is still copied
+	file2 := r.WriteFile("now.txt", "arrived now", time.Now().Add(time.Second))
+	require.NoError(t, TopUp(ctx, r.Fremote, r.Flocal, &opt))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+}
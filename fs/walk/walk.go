@@ -14,6 +14,7 @@ import (
 	"github.com/rclone/rclone/fs/dirtree"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/list"
+	"github.com/rclone/rclone/fs/sched"
 )
 
 // ErrorSkipDir is used as a return value from Walk to indicate that the
@@ -63,7 +64,7 @@ func Walk(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel i
 		return walkR(ctx, f, path, includeAll, maxLevel, fn, filter.Active.MakeListR(ctx, f.NewObject))
 	}
 	// FIXME should this just be maxLevel < 0 - why the maxLevel > 1
-	if (maxLevel < 0 || maxLevel > 1) && fs.Config.UseListR && f.Features().ListR != nil {
+	if (maxLevel < 0 || maxLevel > 1) && fs.Config.UseListR && f.Features().ListR != nil && !filter.Active.UsesIgnoreFiles() {
 		return walkListR(ctx, f, path, includeAll, maxLevel, fn)
 	}
 	return walkListDirSorted(ctx, f, path, includeAll, maxLevel, fn)
@@ -146,6 +147,8 @@ func ListR(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel
 		filter.Active.HaveFilesFrom() || // ...using --files-from
 		maxLevel >= 0 || // ...using bounded recursion
 		len(filter.Active.Opt.ExcludeFile) > 0 || // ...using --exclude-file
+		len(filter.Active.Opt.IncludeFile) > 0 || // ...using --include-only-if-present
+		filter.Active.UsesIgnoreFiles() || // ...using --ignore-file-name
 		filter.Active.UsesDirectoryFilters() { // ...using any directory filters
 		return listRwalk(ctx, f, path, includeAll, maxLevel, listType, fn)
 	}
@@ -283,7 +286,7 @@ func listR(ctx context.Context, f fs.Fs, path string, includeAll bool, listType
 		dm = newDirMap(path)
 	}
 	var mu sync.Mutex
-	err := doListR(ctx, path, func(entries fs.DirEntries) (err error) {
+	err := doListR(sched.WithPace(ctx, f, sched.OpList), path, func(entries fs.DirEntries) (err error) {
 		if synthesizeDirs {
 			err = dm.addEntries(entries)
 			if err != nil {
@@ -364,7 +367,7 @@ func walk(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel i
 		depth  int
 	}
 
-	in := make(chan listJob, fs.Config.Checkers)
+	in := make(chan listJob, list.Concurrency())
 	errs := make(chan error, 1)
 	quit := make(chan struct{})
 	closeQuit := func() {
@@ -377,7 +380,7 @@ func walk(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel i
 			}()
 		})
 	}
-	for i := 0; i < fs.Config.Checkers; i++ {
+	for i := 0; i < list.Concurrency(); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -450,6 +453,8 @@ func walkRDirTree(ctx context.Context, f fs.Fs, startPath string, includeAll boo
 	// Entries can come in arbitrary order. We use toPrune to keep
 	// all directories to exclude later.
 	toPrune := make(map[string]bool)
+	// includeDirs are the directories with an include file in
+	includeDirs := make(map[string]bool)
 	includeDirectory := filter.Active.IncludeDirectory(ctx, f)
 	var mu sync.Mutex
 	err := listR(ctx, startPath, func(entries fs.DirEntries) error {
@@ -475,13 +480,14 @@ func walkRDirTree(ctx context.Context, f fs.Fs, startPath string, includeAll boo
 					fs.Debugf(x, "Excluded from sync (and deletion)")
 				}
 				// Check if we need to prune a directory later.
-				if !includeAll && len(filter.Active.Opt.ExcludeFile) > 0 {
+				if !includeAll && filter.Active.IsExcludeFile(x.Remote()) {
 					basename := path.Base(x.Remote())
-					if basename == filter.Active.Opt.ExcludeFile {
-						excludeDir := parentDir(x.Remote())
-						toPrune[excludeDir] = true
-						fs.Debugf(basename, "Excluded from sync (and deletion) based on exclude file")
-					}
+					excludeDir := parentDir(x.Remote())
+					toPrune[excludeDir] = true
+					fs.Debugf(basename, "Excluded from sync (and deletion) based on exclude file")
+				}
+				if !includeAll && filter.Active.IsIncludeFile(x.Remote()) {
+					includeDirs[parentDir(x.Remote())] = true
 				}
 			case fs.Directory:
 				inc, err := includeDirectory(x.Remote())
@@ -517,10 +523,43 @@ func walkRDirTree(ctx context.Context, f fs.Fs, startPath string, includeAll boo
 	if err != nil {
 		return nil, err
 	}
+	if !includeAll && len(filter.Active.Opt.IncludeFile) > 0 {
+		pruneIncludeFile(dirs, includeDirs)
+	}
 	dirs.Sort()
 	return dirs, nil
 }
 
+// pruneIncludeFile removes the objects from the directories in dirs
+// which don't have an include file in them or in a directory above
+// them. The directories are kept as they may have include files in.
+func pruneIncludeFile(dirs dirtree.DirTree, includeDirs map[string]bool) {
+	for dir, entries := range dirs {
+		included := false
+		for d := dir; ; d = parentDir(d) {
+			if includeDirs[d] {
+				included = true
+				break
+			}
+			if d == "" {
+				break
+			}
+		}
+		if included {
+			continue
+		}
+		newEntries := entries[:0]
+		for _, entry := range entries {
+			if _, ok := entry.(fs.Directory); ok {
+				newEntries = append(newEntries, entry)
+			} else {
+				fs.Debugf(entry, "Excluded as no include file present")
+			}
+		}
+		dirs[dir] = newEntries
+	}
+}
+
 // Create a DirTree using List
 func walkNDirTree(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel int, listDir listDirFunc) (dirtree.DirTree, error) {
 	dirs := make(dirtree.DirTree)
@@ -558,7 +597,7 @@ func NewDirTree(ctx context.Context, f fs.Fs, path string, includeAll bool, maxL
 		return walkRDirTree(ctx, f, path, includeAll, maxLevel, filter.Active.MakeListR(ctx, f.NewObject))
 	}
 	// if have ListR; and recursing; and not using --files-from; then build a DirTree with ListR
-	if ListR := f.Features().ListR; (maxLevel < 0 || maxLevel > 1) && ListR != nil && !filter.Active.HaveFilesFrom() {
+	if ListR := f.Features().ListR; (maxLevel < 0 || maxLevel > 1) && ListR != nil && !filter.Active.HaveFilesFrom() && !filter.Active.UsesIgnoreFiles() {
 		return walkRDirTree(ctx, f, path, includeAll, maxLevel, ListR)
 	}
 	// otherwise just use List
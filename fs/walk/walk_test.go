@@ -648,13 +648,66 @@ b/c/d/
   e
 `, nil, "", -1, "ign", true},
 	} {
-		filter.Active.Opt.ExcludeFile = test.excludeFile
+		filter.Active.Opt.ExcludeFile = nil
+		if test.excludeFile != "" {
+			filter.Active.Opt.ExcludeFile = []string{test.excludeFile}
+		}
 		r, err := walkRDirTree(context.Background(), nil, test.root, test.includeAll, test.level, makeListRCallback(test.entries, test.err))
 		assert.Equal(t, test.err, err, fmt.Sprintf("%+v", test))
 		assert.Equal(t, test.want, r.String(), fmt.Sprintf("%+v", test))
 	}
 	// Set to default value, to avoid side effects
-	filter.Active.Opt.ExcludeFile = ""
+	filter.Active.Opt.ExcludeFile = nil
+}
+
+func TestWalkRDirTreeIncludeFile(t *testing.T) {
+	for _, test := range []struct {
+		entries     fs.DirEntries
+		want        string
+		includeFile []string
+		includeAll  bool
+	}{
+		{fs.DirEntries{
+			mockobject.Object("a"),
+			mockobject.Object("b/b"),
+		}, `/
+  b/
+b/
+`, []string{".backup"}, false},
+		{fs.DirEntries{
+			mockobject.Object("a"),
+			mockobject.Object("b/c/d"),
+			mockobject.Object("b/b"),
+			mockobject.Object("b/.backup-me"),
+			mockobject.Object("c/c"),
+		}, `/
+  b/
+  c/
+b/
+  .backup-me
+  b
+  c/
+b/c/
+  d
+c/
+`, []string{"CACHEDIR.TAG", ".backup*"}, false},
+		{fs.DirEntries{
+			mockobject.Object("a"),
+			mockobject.Object("b/b"),
+		}, `/
+  a
+  b/
+b/
+  b
+`, []string{".backup"}, true},
+	} {
+		filter.Active.Opt.IncludeFile = test.includeFile
+		r, err := walkRDirTree(context.Background(), nil, "", test.includeAll, -1, makeListRCallback(test.entries, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, test.want, r.String(), fmt.Sprintf("%+v", test))
+	}
+	// Set to default value, to avoid side effects
+	filter.Active.Opt.IncludeFile = nil
 }
 
 func TestListType(t *testing.T) {
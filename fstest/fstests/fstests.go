@@ -593,6 +593,7 @@ func Run(t *testing.T, opt *Opt) {
 				{"trailing HT", "trailing HT\t"},
 				{"trailing VT", "trailing VT\v"},
 				{"trailing dot", "trailing dot."},
+				{"reserved name", "CON"},
 				{"invalid UTF-8", "invalid utf-8\xfe"},
 			} {
 				t.Run(test.name, func(t *testing.T) {
@@ -701,6 +702,42 @@ func Run(t *testing.T, opt *Opt) {
 			assert.NoError(t, remote.Rmdir(ctx, "writer-at-subdir"))
 		})
 
+		t.Run("FsOpenChunkWriter", func(t *testing.T) {
+			skipIfNotOk(t)
+			openChunkWriter := remote.Features().OpenChunkWriter
+			if openChunkWriter == nil {
+				t.Skip("FS has no OpenChunkWriter interface")
+			}
+			path := "chunk-writer-subdir/chunk-writer-file"
+			// Find the chunk size with a big enough object then
+			// write one which is a chunk and a bit
+			info, out, err := openChunkWriter(ctx, path, object.NewStaticObjectInfo(path, fstest.Time("2001-02-03T04:05:06.499999999Z"), 1<<30, true, nil, nil))
+			require.NoError(t, err)
+			require.NoError(t, out.Abort(ctx))
+			require.True(t, info.ChunkSize > 0)
+			contents := random.String(int(info.ChunkSize) + 3)
+			src := object.NewStaticObjectInfo(path, fstest.Time("2001-02-03T04:05:06.499999999Z"), int64(len(contents)), true, nil, nil)
+			info, out, err = openChunkWriter(ctx, path, src)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(contents))-3, info.ChunkSize)
+
+			// Write the chunks out of order
+			n, err := out.WriteChunk(ctx, 1, strings.NewReader(contents[info.ChunkSize:]))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), n)
+			n, err = out.WriteChunk(ctx, 0, strings.NewReader(contents[:info.ChunkSize]))
+			assert.NoError(t, err)
+			assert.Equal(t, info.ChunkSize, n)
+
+			assert.NoError(t, out.Close(ctx))
+
+			obj := findObject(ctx, t, remote, path)
+			assert.Equal(t, contents, readObject(ctx, t, obj, -1), "contents of file differ")
+
+			assert.NoError(t, obj.Remove(ctx))
+			assert.NoError(t, remote.Rmdir(ctx, "chunk-writer-subdir"))
+		})
+
 		// TestFsChangeNotify tests that changes are properly
 		// propagated
 		//
@@ -63,6 +63,7 @@ const (
 	EncodeRightCrLfHtVt                          // Trailing CR LF HT VT
 	EncodeInvalidUtf8                            // Invalid UTF-8 bytes
 	EncodeDot                                    // . and .. names
+	EncodeWinReserved                            // CON, NUL, COM1 etc names reserved by Windows
 
 	// Synthetic
 	EncodeWin         = EncodeColon | EncodeQuestion | EncodeDoubleQuote | EncodeAsterisk | EncodeLtGt | EncodePipe // :?"*<>|
@@ -143,6 +144,7 @@ func init() {
 	alias("RightCrLfHtVt", EncodeRightCrLfHtVt)
 	alias("InvalidUtf8", EncodeInvalidUtf8)
 	alias("Dot", EncodeDot)
+	alias("WinReserved", EncodeWinReserved)
 }
 
 // validStrings returns all the valid MultiEncoder strings
@@ -216,6 +218,10 @@ func (mask MultiEncoder) Encode(in string) string {
 		return ""
 	}
 
+	if mask.Has(EncodeWinReserved) {
+		return encodeWinReserved((mask &^ EncodeWinReserved).Encode(in))
+	}
+
 	if mask.Has(EncodeDot) {
 		switch in {
 		case ".":
@@ -634,6 +640,10 @@ func (mask MultiEncoder) Encode(in string) string {
 
 // Decode takes a name and undoes any substitutions made by Encode
 func (mask MultiEncoder) Decode(in string) string {
+	if mask.Has(EncodeWinReserved) {
+		return (mask &^ EncodeWinReserved).Decode(decodeWinReserved(in))
+	}
+
 	if mask.Has(EncodeDot) {
 		switch in {
 		case "．":
@@ -1060,6 +1070,70 @@ func (mask MultiEncoder) ToStandardName(s string) string {
 	return ToStandardName(mask, s)
 }
 
+// isWinReserved returns whether stem, the part of a name before the
+// first ".", is one of the device names Windows reserves
+//
+// Windows won't make files with these names, whatever their extension.
+func isWinReserved(stem string) bool {
+	switch len(stem) {
+	case 3:
+		switch strings.ToUpper(stem) {
+		case "CON", "PRN", "AUX", "NUL":
+			return true
+		}
+	case 4:
+		switch strings.ToUpper(stem[:3]) {
+		case "COM", "LPT":
+			return stem[3] >= '1' && stem[3] <= '9'
+		}
+	}
+	return false
+}
+
+// winReservedStem returns the part of the name in before the first "."
+func winReservedStem(in string) string {
+	if i := strings.IndexByte(in, '.'); i >= 0 {
+		return in[:i]
+	}
+	return in
+}
+
+// encodeWinReserved replaces the last character of the stem of a name
+// reserved by Windows with its FULLWIDTH variant, eg "CON.txt" becomes
+// "COＮ.txt"
+//
+// Names which would decode to a reserved name, like "COＮ.txt", are
+// quoted instead.
+func encodeWinReserved(in string) string {
+	stem := winReservedStem(in)
+	if isWinReserved(stem) {
+		last := len(stem) - 1
+		return stem[:last] + string(rune(stem[last])+fullOffset) + in[len(stem):]
+	}
+	r, l := utf8.DecodeLastRuneInString(stem)
+	if r >= '！' && r <= '～' && isWinReserved(stem[:len(stem)-l]+string(r-fullOffset)) {
+		return stem[:len(stem)-l] + string(QuoteRune) + in[len(stem)-l:]
+	}
+	return in
+}
+
+// decodeWinReserved undoes encodeWinReserved
+func decodeWinReserved(in string) string {
+	stem := winReservedStem(in)
+	r, l := utf8.DecodeLastRuneInString(stem)
+	if r < '！' || r > '～' {
+		return in
+	}
+	base := stem[:len(stem)-l]
+	if isWinReserved(base + string(r-fullOffset)) {
+		return base + string(r-fullOffset) + in[len(stem):]
+	}
+	if q, ql := utf8.DecodeLastRuneInString(base); q == QuoteRune && isWinReserved(base[:len(base)-ql]+string(r-fullOffset)) {
+		return base[:len(base)-ql] + in[len(base):]
+	}
+	return in
+}
+
 func appendQuotedBytes(w io.Writer, s string) {
 	for _, b := range []byte(s) {
 		_, _ = fmt.Fprintf(w, string(QuoteRune)+"%02X", b)
@@ -26,6 +26,7 @@ func TestEncodeString(t *testing.T) {
 		{EncodeZero, "None"},
 		{EncodeDoubleQuote, "DoubleQuote"},
 		{EncodeDot, "Dot"},
+		{EncodeWinReserved, "WinReserved"},
 		{EncodeWin, "LtGt,DoubleQuote,Colon,Question,Asterisk,Pipe"},
 		{EncodeHashPercent, "Hash,Percent"},
 		{EncodeSlash | EncodeDollar | EncodeColon, "Slash,Dollar,Colon"},
@@ -48,6 +49,7 @@ func TestEncodeSet(t *testing.T) {
 		{"None", EncodeZero, false},
 		{"DoubleQuote", EncodeDoubleQuote, false},
 		{"Dot", EncodeDot, false},
+		{"WinReserved", EncodeWinReserved, false},
 		{"LtGt,DoubleQuote,Colon,Question,Asterisk,Pipe", EncodeWin, false},
 		{"Hash,Percent", EncodeHashPercent, false},
 		{"Slash,Dollar,Colon", EncodeSlash | EncodeDollar | EncodeColon, false},
@@ -217,6 +219,76 @@ func TestEncodeDot(t *testing.T) {
 	}
 }
 
+func TestEncodeWinReserved(t *testing.T) {
+	for i, tc := range []testCase{
+		{
+			mask: 0,
+			in:   "CON",
+			out:  "CON",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "CON",
+			out:  "COＮ",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "nul.tar.gz",
+			out:  "nuｌ.tar.gz",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "COM1",
+			out:  "COM１",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "LPT9.txt",
+			out:  "LPT９.txt",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "COM0",
+			out:  "COM0",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "CONSOLE",
+			out:  "CONSOLE",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "dir.CON",
+			out:  "dir.CON",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "AUＸ",
+			out:  "AU‛Ｘ",
+		}, {
+			mask: EncodeWinReserved,
+			in:   "PR‛Ｎ.txt",
+			out:  "PR‛‛Ｎ.txt",
+		}, {
+			mask: EncodeWinReserved | EncodeRightPeriod,
+			in:   "CON.",
+			out:  "CON．",
+		}, {
+			mask: EncodeWinReserved | EncodeRightSpace,
+			in:   "PRN ",
+			out:  "PRN␠",
+		}, {
+			mask: EncodeWinReserved | EncodeLtGt,
+			in:   "AUX.<>",
+			out:  "AUＸ.＜＞",
+		},
+	} {
+		e := tc.mask
+		t.Run(strconv.FormatInt(int64(i), 10), func(t *testing.T) {
+			got := e.Encode(tc.in)
+			if got != tc.out {
+				t.Errorf("Encode(%q) want %q got %q", tc.in, tc.out, got)
+			}
+			got2 := e.Decode(got)
+			if got2 != tc.in {
+				t.Errorf("Decode(%q) want %q got %q", got, tc.in, got2)
+			}
+		})
+	}
+}
+
 func TestDecodeHalf(t *testing.T) {
 	for i, tc := range []testCase{
 		{
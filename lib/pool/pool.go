@@ -3,14 +3,97 @@
 package pool
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/lib/mmap"
+	"golang.org/x/sync/semaphore"
 )
 
+// The memory of the buffers allocated by all the pools, whether in
+// use or idle in their caches, is limited to --max-buffer-memory so the
+// transfers wait for buffers rather than running out of memory. It is
+// read when the first pool is made.
+//
+// Read-ahead buffers are held while their holder waits for buffers
+// from other pools, like a transfer feeding a chunked upload, so if
+// they shared the budget the transfers could use all of it for read
+// ahead and wait forever for upload buffers. Half the budget is kept
+// for the pools using WithReadAheadBudget and half for the rest.
+var (
+	budgetOnce      sync.Once
+	mainBudget      *budget // nil for no limit
+	readAheadBudget *budget // nil for no limit
+)
+
+// budget is a share of --max-buffer-memory
+type budget struct {
+	waiting int32 // number of Gets waiting for the budget - use atomic
+	sem     *semaphore.Weighted
+	size    int64
+	mu      sync.Mutex
+	idle    map[*Pool]struct{} // pools with buffers in their caches
+}
+
+// newBudget makes a budget of size bytes
+func newBudget(size int64) *budget {
+	return &budget{
+		sem:  semaphore.NewWeighted(size),
+		size: size,
+		idle: make(map[*Pool]struct{}),
+	}
+}
+
+// setBudget limits the memory of the buffers allocated to size, or
+// removes the limit if size <= 0
+func setBudget(size int64) {
+	if size <= 0 {
+		mainBudget, readAheadBudget = nil, nil
+		return
+	}
+	readAhead := size / 2
+	mainBudget, readAheadBudget = newBudget(size-readAhead), newBudget(readAhead)
+}
+
+// getBudgets returns the budgets for the pools and for the read-ahead
+// pools
+func getBudgets() (main, readAhead *budget) {
+	budgetOnce.Do(func() {
+		setBudget(int64(fs.Config.MaxBufferMemory))
+	})
+	return mainBudget, readAheadBudget
+}
+
+// setIdle records whether bp has buffers in its cache
+func (b *budget) setIdle(bp *Pool, idle bool) {
+	b.mu.Lock()
+	if idle {
+		b.idle[bp] = struct{}{}
+	} else {
+		delete(b.idle, bp)
+	}
+	b.mu.Unlock()
+}
+
+// flushIdle frees the buffers in the caches of the pools using b to
+// give their memory to a pool which needs it
+func (b *budget) flushIdle() {
+	b.mu.Lock()
+	pools := make([]*Pool, 0, len(b.idle))
+	for bp := range b.idle {
+		pools = append(pools, bp)
+	}
+	b.mu.Unlock()
+	for _, bp := range pools {
+		bp.Flush()
+	}
+}
+
 // Pool of internal buffers
 //
 // We hold buffers in cache. Every time we Get or Put we update
@@ -31,6 +114,8 @@ type Pool struct {
 	flushPending bool
 	alloc        func(int) ([]byte, error)
 	free         func([]byte) error
+	budget       *budget // memory budget, nil for no limit
+	charge       int64   // memory taken from the budget by each buffer
 }
 
 // New makes a buffer pool
@@ -57,10 +142,79 @@ func New(flushTime time.Duration, bufferSize, poolSize int, useMmap bool) *Pool
 			return nil
 		}
 	}
+	main, _ := getBudgets()
+	bp.setBudget(main)
 	bp.timer = time.AfterFunc(flushTime, bp.flushAged)
 	return bp
 }
 
+// setBudget charges the buffers of bp to b
+func (bp *Pool) setBudget(b *budget) {
+	bp.budget, bp.charge = b, 0
+	if b == nil {
+		return
+	}
+	// A buffer bigger than the budget takes all of it
+	bp.charge = b.size
+	if int64(bp.bufferSize) < bp.charge {
+		bp.charge = int64(bp.bufferSize)
+	}
+}
+
+// WithReadAheadBudget charges the buffers of bp to the half of
+// --max-buffer-memory kept for read-ahead buffers, returning bp. Call
+// it before the first Get.
+func (bp *Pool) WithReadAheadBudget() *Pool {
+	_, readAhead := getBudgets()
+	bp.setBudget(readAhead)
+	return bp
+}
+
+// acquire waits for the budget for a new buffer, freeing the idle
+// buffers of the other pools if there isn't enough of it
+func (bp *Pool) acquire(ctx context.Context) error {
+	if bp.budget == nil || bp.budget.sem.TryAcquire(bp.charge) {
+		return nil
+	}
+	// Stop buffers being put into the caches while waiting and free
+	// those already there
+	atomic.AddInt32(&bp.budget.waiting, 1)
+	defer atomic.AddInt32(&bp.budget.waiting, -1)
+	bp.budget.flushIdle()
+	fs.Debugf(nil, "Waiting for %v of --max-buffer-memory", fs.SizeSuffix(bp.charge))
+	return bp.budget.sem.Acquire(ctx, bp.charge)
+}
+
+// tryAcquire takes the budget for a new buffer if it is available,
+// freeing the idle buffers of the other pools if necessary
+func (bp *Pool) tryAcquire() bool {
+	if bp.budget == nil || bp.budget.sem.TryAcquire(bp.charge) {
+		return true
+	}
+	bp.budget.flushIdle()
+	return bp.budget.sem.TryAcquire(bp.charge)
+}
+
+// release returns the budget of a freed buffer
+func (bp *Pool) release() {
+	if bp.budget != nil {
+		bp.budget.sem.Release(bp.charge)
+	}
+}
+
+// pressure returns true if a Get is waiting for the budget of bp
+func (bp *Pool) pressure() bool {
+	return bp.budget != nil && atomic.LoadInt32(&bp.budget.waiting) > 0
+}
+
+// updateIdle records whether bp has idle buffers in its budget - call
+// with mu held
+func (bp *Pool) updateIdle() {
+	if bp.budget != nil {
+		bp.budget.setIdle(bp, len(bp.cache) > 0)
+	}
+}
+
 // get gets the last buffer in bp.cache
 //
 // Call with mu held
@@ -69,6 +223,9 @@ func (bp *Pool) get() []byte {
 	buf := bp.cache[n]
 	bp.cache[n] = nil // clear buffer pointer from bp.cache
 	bp.cache = bp.cache[:n]
+	if n == 0 {
+		bp.updateIdle()
+	}
 	return buf
 }
 
@@ -77,6 +234,9 @@ func (bp *Pool) get() []byte {
 // Call with mu held
 func (bp *Pool) put(buf []byte) {
 	bp.cache = append(bp.cache, buf)
+	if len(bp.cache) == 1 {
+		bp.updateIdle()
+	}
 }
 
 // flush n entries from the entire buffer pool
@@ -146,13 +306,45 @@ func (bp *Pool) updateMinFill() {
 }
 
 // Get a buffer from the pool or allocate one
+//
+// If --max-buffer-memory is set this waits until there is enough of
+// it for the buffer. Use GetContext to be able to give up waiting.
 func (bp *Pool) Get() []byte {
+	buf, _ := bp.GetContext(context.Background())
+	return buf
+}
+
+// getCached gets a buffer from the cache if there is one
+func (bp *Pool) getCached() (buf []byte) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	if len(bp.cache) > 0 {
+		buf = bp.get()
+		bp.inUse++
+		bp.updateMinFill()
+	}
+	return buf
+}
+
+// GetContext gets a buffer from the pool or allocates one like Get
+//
+// It returns an error only if ctx is cancelled while waiting for
+// --max-buffer-memory.
+func (bp *Pool) GetContext(ctx context.Context) ([]byte, error) {
+	if buf := bp.getCached(); buf != nil {
+		return buf, nil
+	}
+	err := bp.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
 	bp.mu.Lock()
 	var buf []byte
 	waitTime := time.Millisecond
 	for {
 		if len(bp.cache) > 0 {
 			buf = bp.get()
+			bp.release()
 			break
 		} else {
 			var err error
@@ -171,6 +363,28 @@ func (bp *Pool) Get() []byte {
 	bp.inUse++
 	bp.updateMinFill()
 	bp.mu.Unlock()
+	return buf, nil
+}
+
+// TryGet gets a buffer from the pool or allocates one like Get but
+// returns nil rather than waiting if there isn't enough
+// --max-buffer-memory for it or the allocation fails.
+func (bp *Pool) TryGet() []byte {
+	if buf := bp.getCached(); buf != nil {
+		return buf
+	}
+	if !bp.tryAcquire() {
+		return nil
+	}
+	buf, err := bp.alloc(bp.bufferSize)
+	if err != nil {
+		bp.release()
+		return nil
+	}
+	bp.mu.Lock()
+	bp.alloced++
+	bp.inUse++
+	bp.mu.Unlock()
 	return buf
 }
 
@@ -181,6 +395,7 @@ func (bp *Pool) freeBuffer(mem []byte) {
 		log.Printf("Failed to free memory: %v", err)
 	}
 	bp.alloced--
+	bp.release()
 }
 
 // Put returns the buffer to the buffer cache or frees it
@@ -194,7 +409,7 @@ func (bp *Pool) Put(buf []byte) {
 	if len(buf) != bp.bufferSize {
 		panic(fmt.Sprintf("Returning buffer sized %d but expecting %d", len(buf), bp.bufferSize))
 	}
-	if len(bp.cache) < bp.poolSize {
+	if len(bp.cache) < bp.poolSize && !bp.pressure() {
 		bp.put(buf)
 	} else {
 		bp.freeBuffer(buf)
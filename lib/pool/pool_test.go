@@ -1,6 +1,7 @@
 package pool
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/rclone/rclone/fstest/testy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // makes the allocations be unreliable
@@ -225,3 +227,128 @@ func TestPool(t *testing.T) {
 		})
 	}
 }
+
+func TestBudget(t *testing.T) {
+	getBudgets()
+	setBudget(6 * 4096)
+	defer setBudget(0)
+
+	bp := New(60*time.Second, 4096, 2, false)
+	big := New(60*time.Second, 16384, 2, false)
+	assert.Equal(t, int64(4096), bp.charge)
+	assert.Equal(t, int64(3*4096), big.charge)
+
+	b1 := bp.Get()
+	b2 := bp.Get()
+	b3 := bp.Get()
+
+	// Waits for the budget and gives up when cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := bp.GetContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 3, bp.InUse())
+
+	// Gets a buffer when one is returned
+	got := make(chan []byte)
+	go func() {
+		got <- bp.Get()
+	}()
+	select {
+	case <-got:
+		t.Fatal("got buffer over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	bp.Put(b1)
+	b4 := <-got
+
+	// A buffer bigger than the budget waits for all of it, freeing
+	// the idle buffers in the other pools
+	bp.Put(b2)
+	bp.Put(b3)
+	bp.Put(b4)
+	assert.Equal(t, 2, bp.InPool())
+	assert.Equal(t, 2, bp.Alloced())
+	b5, err := big.GetContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, bp.InPool())
+	assert.Equal(t, 0, bp.Alloced())
+	assert.Equal(t, 16384, len(b5))
+	_, err = bp.GetContext(ctx)
+	assert.Error(t, err)
+	big.Put(b5)
+}
+
+func TestTryGet(t *testing.T) {
+	getBudgets()
+	setBudget(2 * 4096)
+	defer setBudget(0)
+
+	bp := New(60*time.Second, 4096, 2, false)
+	b1 := bp.TryGet()
+	require.NotNil(t, b1)
+	assert.Nil(t, bp.TryGet())
+
+	// Gets the idle buffer from the cache
+	bp.Put(b1)
+	b2 := bp.TryGet()
+	require.NotNil(t, b2)
+	assert.Equal(t, 1, bp.InUse())
+	assert.Equal(t, 1, bp.Alloced())
+
+	// The read-ahead buffers have their own half of the budget
+	readAhead := New(60*time.Second, 4096, 2, false).WithReadAheadBudget()
+	b3 := readAhead.TryGet()
+	require.NotNil(t, b3)
+	assert.Nil(t, readAhead.TryGet())
+	bp.Put(b2)
+	readAhead.Put(b3)
+}
+
+func TestBudgetConcurrentTransfers(t *testing.T) {
+	getBudgets()
+	setBudget(4 * 4096)
+	defer setBudget(0)
+
+	// Each transfer holds read-ahead buffers while it waits for
+	// upload buffers, which would deadlock if they shared the budget.
+	// Like the async reader the transfers only wait for their first
+	// read-ahead buffer, before they hold any others.
+	readAhead := New(60*time.Second, 4096, 8, false).WithReadAheadBudget()
+	upload := New(60*time.Second, 4096, 2, false)
+	assert.Equal(t, int64(4096), readAhead.charge)
+
+	const transfers = 4
+	errs := make(chan error, transfers)
+	for i := 0; i < transfers; i++ {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			ra1, ra2 := readAhead.Get(), readAhead.TryGet()
+			var err error
+			for part := 0; part < 10 && err == nil; part++ {
+				var buf []byte
+				buf, err = upload.GetContext(ctx)
+				if err == nil {
+					time.Sleep(time.Millisecond)
+					upload.Put(buf)
+				}
+			}
+			readAhead.Put(ra1)
+			if ra2 != nil {
+				readAhead.Put(ra2)
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < transfers; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(20 * time.Second):
+			t.Fatal("transfers deadlocked waiting for buffers")
+		}
+	}
+	assert.Equal(t, 0, upload.InUse())
+	assert.Equal(t, 0, readAhead.InUse())
+}
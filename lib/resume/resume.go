@@ -0,0 +1,303 @@
+// Package resume persists the state of large multipart uploads so
+// that an upload interrupted by rclone exiting can carry on from the
+// last completed part when it is restarted.
+//
+// The state of each upload is kept in a journal file in the cache
+// directory. The first line is a JSON header with the backend
+// specific upload ID and the chunk size, and each following line is a
+// JSON record of a completed part. Parts are appended as they
+// complete so a crash can at worst lose the part being written.
+package resume
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// Part is a completed part of an upload
+type Part struct {
+	Number int64  `json:"number"`       // part number as used by the backend
+	Offset int64  `json:"offset"`       // offset of the part in the source
+	Size   int64  `json:"size"`         // size of the part
+	Hash   string `json:"hash"`         // MD5 of the source data, hex
+	ID     string `json:"id,omitempty"` // backend specific ID, eg ETag
+}
+
+// header is the first line of the journal
+type header struct {
+	Key       string `json:"key"`
+	UploadID  string `json:"uploadId"`
+	ChunkSize int64  `json:"chunkSize"`
+}
+
+// Upload is the persisted state of a single upload.
+//
+// A nil *Upload is valid and does nothing, so backends can call its
+// methods unconditionally.
+type Upload struct {
+	mu     sync.Mutex
+	obj    string // name of the object for logging
+	path   string // path of the journal
+	header header
+	parts  map[int64]Part
+	loaded bool // set if a valid journal was read
+	saved  bool // set if the journal holds the upload ID
+}
+
+// Dir returns the directory the journals are kept in
+func Dir() string {
+	return filepath.Join(config.CacheDir, "resume")
+}
+
+// New returns the Upload state for uploading src to remote on f,
+// loading any journal left by a previous attempt.
+//
+// It returns nil if resumable uploads are disabled or the size of src
+// is unknown.
+func New(ctx context.Context, f fs.Fs, remote string, src fs.ObjectInfo) *Upload {
+	if !fs.Config.ResumeUploads || src.Size() < 0 {
+		return nil
+	}
+	key := fmt.Sprintf("%s\x00%s\x00%s", fs.ConfigString(f), remote, fs.Fingerprint(ctx, src, true))
+	sum := md5.Sum([]byte(key))
+	u := &Upload{
+		obj:    fs.ConfigString(f) + "/" + remote,
+		path:   filepath.Join(Dir(), hex.EncodeToString(sum[:])+".json"),
+		header: header{Key: key},
+		parts:  make(map[int64]Part),
+	}
+	err := u.load()
+	if err != nil && !os.IsNotExist(err) {
+		fs.Debugf(u.obj, "resume: ignoring unreadable journal: %v", err)
+	}
+	return u
+}
+
+// load reads the journal if there is one
+func (u *Upload) load() error {
+	fd, err := os.Open(u.path)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(fd, &err)
+	scanner := bufio.NewScanner(fd)
+	scanner.Buffer(nil, 1024*1024)
+	if !scanner.Scan() {
+		return errors.New("empty journal")
+	}
+	var h header
+	err = json.Unmarshal(scanner.Bytes(), &h)
+	if err != nil {
+		return errors.Wrap(err, "bad header")
+	}
+	if h.Key != u.header.Key || h.UploadID == "" {
+		return errors.New("journal is for a different upload")
+	}
+	for scanner.Scan() {
+		var p Part
+		// A partly written last line is ignored
+		if json.Unmarshal(scanner.Bytes(), &p) == nil {
+			u.parts[p.Number] = p
+		}
+	}
+	u.header = h
+	u.loaded = true
+	u.saved = true
+	return nil
+}
+
+// Resume returns the upload ID of a previous attempt made with the
+// same chunkSize if there is one.
+func (u *Upload) Resume(chunkSize int64) (uploadID string, ok bool) {
+	if u == nil {
+		return "", false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.loaded || u.header.ChunkSize != chunkSize {
+		return "", false
+	}
+	fs.Infof(u.obj, "Resuming upload with %d parts already uploaded", len(u.parts))
+	return u.header.UploadID, true
+}
+
+// Start records a new upload, discarding any previous state.
+func (u *Upload) Start(uploadID string, chunkSize int64) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.header.UploadID = uploadID
+	u.header.ChunkSize = chunkSize
+	u.parts = make(map[int64]Part)
+	u.loaded = true
+	err := u.write(os.O_WRONLY|os.O_CREATE|os.O_TRUNC, u.header)
+	u.saved = err == nil
+	if err != nil {
+		fs.Errorf(u.obj, "resume: failed to save upload state: %v", err)
+	}
+}
+
+// write appends v to the journal as a line of JSON
+func (u *Upload) write(flags int, v interface{}) (err error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(u.path), 0700)
+	if err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(u.path, flags, 0600)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(fd, &err)
+	_, err = fd.Write(append(buf, '\n'))
+	return err
+}
+
+// AddPart records that part number containing data read from offset
+// of the source has been uploaded. id is a backend specific
+// identifier for the part which is returned by Verify.
+func (u *Upload) AddPart(number, offset int64, data []byte, id string) {
+	if u == nil {
+		return
+	}
+	sum := md5.Sum(data)
+	p := Part{
+		Number: number,
+		Offset: offset,
+		Size:   int64(len(data)),
+		Hash:   hex.EncodeToString(sum[:]),
+		ID:     id,
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.parts[number] = p
+	err := u.write(os.O_WRONLY|os.O_APPEND, p)
+	if err != nil {
+		fs.Errorf(u.obj, "resume: failed to save part %d: %v", number, err)
+	}
+}
+
+// Verify checks whether part number was uploaded by a previous
+// attempt with exactly data, returning its ID if so.
+func (u *Upload) Verify(number int64, data []byte) (id string, ok bool) {
+	if u == nil {
+		return "", false
+	}
+	u.mu.Lock()
+	p, found := u.parts[number]
+	u.mu.Unlock()
+	if !found || p.Size != int64(len(data)) {
+		return "", false
+	}
+	sum := md5.Sum(data)
+	if hex.EncodeToString(sum[:]) != p.Hash {
+		fs.Debugf(u.obj, "resume: part %d has changed since it was uploaded", number)
+		return "", false
+	}
+	return p.ID, true
+}
+
+// Skip reads pos bytes from in using buf checking them against the
+// parts uploaded by a previous attempt.
+//
+// This is for backends which upload sequentially so can't replace a
+// part once it has been sent. Parts are numbered from 1 in chunkSize
+// blocks. Any bytes before pos which aren't in a recorded part are
+// assumed to be correct.
+func (u *Upload) Skip(in io.Reader, pos int64, buf []byte) error {
+	chunkSize := int64(len(buf))
+	for offset, number := int64(0), int64(1); offset < pos; offset, number = offset+chunkSize, number+1 {
+		n := chunkSize
+		if pos-offset < n {
+			n = pos - offset
+		}
+		_, err := io.ReadFull(in, buf[:n])
+		if err != nil {
+			return errors.Wrap(err, "resume: failed to read source")
+		}
+		u.mu.Lock()
+		p, found := u.parts[number]
+		u.mu.Unlock()
+		if !found || p.Size != n {
+			continue
+		}
+		if _, ok := u.Verify(number, buf[:n]); !ok {
+			return errors.Errorf("resume: source has changed since part %d was uploaded", number)
+		}
+	}
+	return nil
+}
+
+// Keep returns whether an upload which failed with err should be left
+// on the backend for a later attempt to resume instead of being
+// aborted.
+//
+// This is only so if the state of the upload was saved and err is
+// worth retrying, either because retry is set by the backend or
+// because err is generically retryable. err is nil if rclone is
+// exiting. If the upload isn't to be kept its state is removed.
+func (u *Upload) Keep(err error, retry bool) bool {
+	if u == nil {
+		return false
+	}
+	u.mu.Lock()
+	saved := u.saved
+	u.mu.Unlock()
+	if saved && (retry || retryable(err)) {
+		fs.Debugf(u.obj, "resume: leaving upload to be resumed: %v", err)
+		return true
+	}
+	u.Done()
+	return false
+}
+
+// retryable returns whether err means the upload could succeed if
+// tried again
+func retryable(err error) bool {
+	if err == nil {
+		return true
+	}
+	if fserrors.IsFatalError(err) || fserrors.IsNoRetryError(err) {
+		return false
+	}
+	cause := errors.Cause(err)
+	if cause == context.Canceled || cause == context.DeadlineExceeded {
+		return true
+	}
+	return fserrors.IsRetryError(err) || fserrors.ShouldRetry(err)
+}
+
+// Done removes the state of the upload, either because it has
+// finished or because it can't be resumed.
+func (u *Upload) Done() {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.loaded = false
+	u.saved = false
+	u.parts = make(map[int64]Part)
+	err := os.Remove(u.path)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(u.obj, "resume: failed to remove upload state: %v", err)
+	}
+}
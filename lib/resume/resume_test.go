@@ -0,0 +1,142 @@
+package resume
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+
+// setup enables resumable uploads using a temporary cache directory
+func setup(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "rclone-resume-test")
+	require.NoError(t, err)
+	oldCacheDir, oldResume := config.CacheDir, fs.Config.ResumeUploads
+	config.CacheDir, fs.Config.ResumeUploads = dir, true
+	return func() {
+		config.CacheDir, fs.Config.ResumeUploads = oldCacheDir, oldResume
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+	defer setup(t)()
+	ctx := context.Background()
+	f := mockfs.NewFs("remote", "root")
+	src := object.NewStaticObjectInfo("file", t1, 5, true, nil, f)
+
+	fs.Config.ResumeUploads = false
+	u := New(ctx, f, "file", src)
+	assert.Nil(t, u)
+
+	// A nil Upload does nothing
+	u.Start("id", 5)
+	u.AddPart(1, 0, []byte("hello"), "etag")
+	_, ok := u.Resume(5)
+	assert.False(t, ok)
+	_, ok = u.Verify(1, []byte("hello"))
+	assert.False(t, ok)
+	assert.False(t, u.Keep(nil, true))
+	u.Done()
+}
+
+func TestResume(t *testing.T) {
+	defer setup(t)()
+	ctx := context.Background()
+	f := mockfs.NewFs("remote", "root")
+	src := object.NewStaticObjectInfo("file", t1, 11, true, nil, f)
+
+	u := New(ctx, f, "file", src)
+	require.NotNil(t, u)
+	_, ok := u.Resume(4)
+	assert.False(t, ok)
+
+	u.Start("upload-id", 4)
+	u.AddPart(1, 0, []byte("hell"), "etag1")
+	u.AddPart(2, 4, []byte("o wo"), "etag2")
+
+	// Simulate a restart
+	u = New(ctx, f, "file", src)
+	_, ok = u.Resume(8)
+	assert.False(t, ok, "different chunk size")
+	id, ok := u.Resume(4)
+	require.True(t, ok)
+	assert.Equal(t, "upload-id", id)
+
+	id, ok = u.Verify(1, []byte("hell"))
+	assert.True(t, ok)
+	assert.Equal(t, "etag1", id)
+	_, ok = u.Verify(2, []byte("XXXX"))
+	assert.False(t, ok, "changed data")
+	_, ok = u.Verify(3, []byte("rld"))
+	assert.False(t, ok, "not uploaded")
+
+	// Different file isn't resumed
+	other := New(ctx, f, "other", src)
+	_, ok = other.Resume(4)
+	assert.False(t, ok)
+
+	u.Done()
+	u = New(ctx, f, "file", src)
+	_, ok = u.Resume(4)
+	assert.False(t, ok)
+}
+
+func TestSkip(t *testing.T) {
+	defer setup(t)()
+	ctx := context.Background()
+	f := mockfs.NewFs("remote", "root")
+	src := object.NewStaticObjectInfo("file", t1, 11, true, nil, f)
+
+	u := New(ctx, f, "file", src)
+	u.Start("upload-id", 4)
+	u.AddPart(1, 0, []byte("hell"), "")
+	u.AddPart(2, 4, []byte("o wo"), "")
+
+	in := bytes.NewBufferString("hello world")
+	require.NoError(t, u.Skip(in, 8, make([]byte, 4)))
+	assert.Equal(t, "rld", in.String())
+
+	in = bytes.NewBufferString("hello WORLD")
+	err := u.Skip(in, 8, make([]byte, 4))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "part 2")
+}
+
+func TestKeep(t *testing.T) {
+	defer setup(t)()
+	ctx := context.Background()
+	f := mockfs.NewFs("remote", "root")
+	src := object.NewStaticObjectInfo("file", t1, 11, true, nil, f)
+
+	// Not kept if the state wasn't saved
+	u := New(ctx, f, "file", src)
+	require.NotNil(t, u)
+	assert.False(t, u.Keep(context.Canceled, true))
+
+	u.Start("id", 5)
+	assert.True(t, u.Keep(nil, false))
+	assert.True(t, u.Keep(context.Canceled, false))
+	assert.True(t, u.Keep(errors.New("503 Service Unavailable"), true))
+	assert.True(t, u.Keep(fserrors.RetryErrorf("try again"), false))
+
+	// A permanent error removes the state
+	assert.False(t, u.Keep(errors.New("access denied"), false))
+	u = New(ctx, f, "file", src)
+	_, ok := u.Resume(5)
+	assert.False(t, ok)
+	assert.False(t, u.Keep(nil, false))
+}
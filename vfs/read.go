@@ -79,7 +79,7 @@ func (fh *ReadFileHandle) openPending() (err error) {
 	}
 	tr := accounting.GlobalStats().NewTransfer(o)
 	fh.done = tr.Done
-	fh.r = tr.Account(context.TODO(), r).WithBuffer() // account the transfer
+	fh.r = tr.Account(accounting.WithTrafficClass(context.TODO(), accounting.TrafficInteractive), r).WithBuffer() // account the transfer
 	fh.opened = true
 
 	return nil
@@ -101,7 +101,9 @@ func New(item Item, opt *vfscommon.Options, remote string, src fs.Object) (dls *
 	if src == nil {
 		panic("internal error: newDownloaders called with nil src object")
 	}
-	ctx, cancel := context.WithCancel(context.Background())
+	// The reads from the cache are waiting on the downloads
+	ctx := accounting.WithTrafficClass(context.Background(), accounting.TrafficInteractive)
+	ctx, cancel := context.WithCancel(ctx)
 	dls = &Downloaders{
 		ctx:    ctx,
 		cancel: cancel,